@@ -1,6 +1,7 @@
 package vega
 
 import (
+	"context"
 	"time"
 
 	"github.com/everydev1618/govega/llm"
@@ -55,6 +56,20 @@ type Agent struct {
 
 	// MaxIterations limits tool call loop iterations (default: DefaultMaxIterations)
 	MaxIterations int
+
+	// MaxHistoryTokens compacts the conversation history before a request
+	// once it holds more than this many estimated tokens (optional)
+	MaxHistoryTokens int
+
+	// MaxHistoryMessages compacts the conversation history before a request
+	// once it holds more than this many messages (optional)
+	MaxHistoryMessages int
+
+	// MaxHistory keeps only the last MaxHistory turns of the conversation
+	// history, dropping older turns without summarizing them. A turn is a
+	// user message and the replies to it. Ignored when Context is set
+	// (optional)
+	MaxHistory int
 }
 
 // Default configuration values
@@ -71,7 +86,14 @@ const (
 	// DefaultStreamBufferSize is the default buffer size for streaming responses
 	DefaultStreamBufferSize = 100
 
-	// DefaultSupervisorPollInterval is the default interval for supervisor health checks
+	// DefaultAsyncStreamTimeout bounds a SendStreamAsync generation, which
+	// ignores its caller's cancellation
+	DefaultAsyncStreamTimeout = 60 * time.Minute
+
+	// DefaultSupervisorPollInterval is the default interval for supervisor health checks.
+	//
+	// Deprecated: supervisors react to their children's exit signals and no
+	// longer poll.
 	DefaultSupervisorPollInterval = 100 * time.Millisecond
 )
 
@@ -81,6 +103,23 @@ type SystemPrompt interface {
 	Prompt() string
 }
 
+// ContextPrompt is a SystemPrompt whose content depends on the call it is
+// built for, such as a template filled from request-scoped values. A
+// process building messages for a call uses PromptContext with that call's
+// context; Prompt is used where there is no call.
+type ContextPrompt interface {
+	SystemPrompt
+	PromptContext(ctx context.Context) string
+}
+
+// promptFor returns sp's prompt for a call made with ctx.
+func promptFor(ctx context.Context, sp SystemPrompt) string {
+	if cp, ok := sp.(ContextPrompt); ok {
+		return cp.PromptContext(ctx)
+	}
+	return sp.Prompt()
+}
+
 // StaticPrompt is a fixed system prompt string.
 type StaticPrompt string
 
@@ -171,6 +210,8 @@ const (
 	ErrClassInvalidRequest
 	ErrClassAuthentication
 	ErrClassBudgetExceeded
+	ErrClassRefusal
+	ErrClassContextWindow
 )
 
 // RateLimit configures request throttling.
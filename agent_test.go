@@ -79,6 +79,8 @@ func TestErrorClass(t *testing.T) {
 		{ErrClassInvalidRequest, 4},
 		{ErrClassAuthentication, 5},
 		{ErrClassBudgetExceeded, 6},
+		{ErrClassRefusal, 7},
+		{ErrClassContextWindow, 8},
 	}
 
 	for _, tt := range tests {
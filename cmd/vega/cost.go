@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+// costCheckInterval is how often the cost ceiling is checked between steps.
+const costCheckInterval = 500 * time.Millisecond
+
+// costCeilingError reports a run aborted by --max-cost.
+type costCeilingError struct {
+	Limit float64
+	Spent float64
+}
+
+func (e *costCeilingError) Error() string {
+	return fmt.Sprintf("cost ceiling of $%.2f exceeded: spent $%.4f", e.Limit, e.Spent)
+}
+
+// totalCost sums the cost of every process the interpreter has run.
+func totalCost(interp *dsl.Interpreter) float64 {
+	var total float64
+	for _, p := range interp.Orchestrator().List() {
+		total += p.Metrics().CostUSD
+	}
+	return total
+}
+
+// executeWithCostCeiling runs a workflow and cancels it once the cumulative
+// cost across all agents exceeds maxCost. A maxCost of zero or less
+// disables the ceiling. When the ceiling trips, the returned error is a
+// *costCeilingError carrying the cost spent so far.
+func executeWithCostCeiling(ctx context.Context, interp *dsl.Interpreter, workflow string, inputs map[string]any, maxCost float64) (any, error) {
+	if maxCost <= 0 {
+		return interp.Execute(ctx, workflow, inputs)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		exceeded *costCeilingError
+	)
+	check := func() {
+		spent := totalCost(interp)
+		if spent <= maxCost {
+			return
+		}
+		mu.Lock()
+		if exceeded == nil {
+			exceeded = &costCeilingError{Limit: maxCost, Spent: spent}
+		}
+		mu.Unlock()
+		cancel()
+	}
+
+	// Check at every step boundary, and on a timer so a long-running
+	// step is cut off mid-flight.
+	interp.OnStep(func(dsl.StepEvent) { check() })
+	go func() {
+		ticker := time.NewTicker(costCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				check()
+			}
+		}
+	}()
+
+	result, err := interp.Execute(ctx, workflow, inputs)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if exceeded != nil {
+		exceeded.Spent = totalCost(interp)
+		return result, exceeded
+	}
+	return result, err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/everydev1618/govega/dsl"
+	"github.com/everydev1618/govega/llm"
+)
+
+// costlyLLM bills every call at list price for a fixed token count.
+type costlyLLM struct {
+	mu    sync.Mutex
+	calls int
+}
+
+const (
+	costlyModel        = "claude-sonnet-4-20250514"
+	costlyInputTokens  = 100_000
+	costlyOutputTokens = 10_000
+)
+
+func (c *costlyLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	return &llm.LLMResponse{
+		Content:      "done",
+		InputTokens:  costlyInputTokens,
+		OutputTokens: costlyOutputTokens,
+		CostUSD:      llm.CalculateCost(costlyModel, costlyInputTokens, costlyOutputTokens, 0, 0),
+	}, nil
+}
+
+func (c *costlyLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	resp, _ := c.Generate(ctx, messages, tools)
+	ch := make(chan llm.StreamEvent, 1)
+	ch <- llm.StreamEvent{Delta: resp.Content}
+	close(ch)
+	return ch, nil
+}
+
+func (c *costlyLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestMaxCostAbortsRun(t *testing.T) {
+	t.Setenv("VEGA_HOME", t.TempDir())
+
+	doc, err := dsl.NewParser().Parse([]byte(`
+name: Test
+agents:
+  worker:
+    model: claude-sonnet-4-20250514
+    system: You work.
+workflows:
+  flow:
+    steps:
+      - worker: "step one"
+      - worker: "step two"
+      - worker: "step three"
+      - worker: "step four"
+      - worker: "step five"
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	backend := &costlyLLM{}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(backend))
+	if err != nil {
+		t.Fatalf("NewInterpreter: %v", err)
+	}
+	defer interp.Shutdown()
+
+	// Each call costs $0.45 (100k in at $3/M + 10k out at $15/M), so a
+	// $1.00 ceiling is crossed by the third call.
+	perCall := llm.CalculateCost(costlyModel, costlyInputTokens, costlyOutputTokens, 0, 0)
+	limit := 1.00
+
+	_, err = executeWithCostCeiling(context.Background(), interp, "flow", nil, limit)
+	var ceiling *costCeilingError
+	if !errors.As(err, &ceiling) {
+		t.Fatalf("err = %v, want *costCeilingError", err)
+	}
+	if ceiling.Spent <= limit {
+		t.Errorf("spent = %.4f, want above limit %.2f", ceiling.Spent, limit)
+	}
+	if ceiling.Spent > limit+perCall {
+		t.Errorf("spent = %.4f, want at most one call past the limit", ceiling.Spent)
+	}
+	if backend.calls != 3 {
+		t.Errorf("LLM calls = %d, want 3 (run should stop at the ceiling)", backend.calls)
+	}
+}
+
+func TestMaxCostZeroDisablesCeiling(t *testing.T) {
+	t.Setenv("VEGA_HOME", t.TempDir())
+
+	doc, err := dsl.NewParser().Parse([]byte(`
+name: Test
+agents:
+  worker:
+    model: claude-sonnet-4-20250514
+    system: You work.
+workflows:
+  flow:
+    steps:
+      - worker: "one"
+      - worker: "two"
+`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	backend := &costlyLLM{}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(backend))
+	if err != nil {
+		t.Fatalf("NewInterpreter: %v", err)
+	}
+	defer interp.Shutdown()
+
+	if _, err := executeWithCostCeiling(context.Background(), interp, "flow", nil, 0); err != nil {
+		t.Fatalf("run without ceiling failed: %v", err)
+	}
+	if backend.calls != 2 {
+		t.Errorf("LLM calls = %d, want 2", backend.calls)
+	}
+}
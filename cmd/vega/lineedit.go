@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Control keys the line editor handles.
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyCtrlK     = 11
+	keyEnter     = 13
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+// terminalReader is a dsl.LineReader for an interactive terminal. It puts
+// the terminal in raw mode while a line is read so the arrow keys can move
+// the cursor and recall history.
+type terminalReader struct {
+	fd  int
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// newTerminalReader returns a terminalReader for stdin, or nil if stdin is
+// not a terminal that supports raw mode.
+func newTerminalReader() *terminalReader {
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		return nil
+	}
+	return &terminalReader{fd: fd, in: bufio.NewReader(os.Stdin), out: os.Stdout}
+}
+
+func (t *terminalReader) ReadLine(prompt string, history []string) (string, error) {
+	restore, err := makeRaw(t.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	return editLine(t.in, t.out, prompt, history)
+}
+
+// editLine reads one line from in, a terminal in raw mode, echoing edits
+// to out. It supports cursor movement, Ctrl-A/E/U/K, and history recall
+// with the up and down arrows. Ctrl-C abandons the line; Ctrl-D on an
+// empty line returns io.EOF.
+func editLine(in *bufio.Reader, out io.Writer, prompt string, history []string) (string, error) {
+	var buf []rune
+	pos := 0
+	// recall indexes history while browsing it; len(history) is the draft.
+	recall := len(history)
+	var draft []rune
+
+	redraw := func() {
+		fmt.Fprintf(out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(out, "\x1b[%dD", back)
+		}
+	}
+	show := func(line []rune) {
+		buf = append([]rune(nil), line...)
+		pos = len(buf)
+		redraw()
+	}
+
+	fmt.Fprint(out, prompt)
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(buf) > 0 {
+				fmt.Fprint(out, "\r\n")
+				return string(buf), nil
+			}
+			return "", err
+		}
+
+		switch r {
+		case keyEnter, '\n':
+			fmt.Fprint(out, "\r\n")
+			return string(buf), nil
+
+		case keyCtrlC:
+			fmt.Fprint(out, "^C\r\n")
+			return "", nil
+
+		case keyCtrlD:
+			if len(buf) == 0 {
+				fmt.Fprint(out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+				redraw()
+			}
+
+		case keyDelete, keyBackspace:
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+				redraw()
+			}
+
+		case keyCtrlA:
+			pos = 0
+			redraw()
+
+		case keyCtrlE:
+			pos = len(buf)
+			redraw()
+
+		case keyCtrlU:
+			buf = append([]rune(nil), buf[pos:]...)
+			pos = 0
+			redraw()
+
+		case keyCtrlK:
+			buf = buf[:pos]
+			redraw()
+
+		case keyEscape:
+			switch readEscape(in) {
+			case "A": // up
+				if recall > 0 {
+					if recall == len(history) {
+						draft = buf
+					}
+					recall--
+					show([]rune(history[recall]))
+				}
+			case "B": // down
+				if recall < len(history) {
+					recall++
+					if recall == len(history) {
+						show(draft)
+					} else {
+						show([]rune(history[recall]))
+					}
+				}
+			case "C": // right
+				if pos < len(buf) {
+					pos++
+					redraw()
+				}
+			case "D": // left
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			case "H", "1~": // home
+				pos = 0
+				redraw()
+			case "F", "4~": // end
+				pos = len(buf)
+				redraw()
+			case "3~": // delete
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+					redraw()
+				}
+			}
+
+		default:
+			if r < ' ' {
+				continue
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+			redraw()
+		}
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC and returns
+// its final part: "A" for ESC [ A, "3~" for ESC [ 3 ~, and so on.
+func readEscape(in *bufio.Reader) string {
+	b, err := in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return ""
+	}
+	var seq strings.Builder
+	for {
+		b, err := in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		seq.WriteByte(b)
+		if b >= 0x40 && b <= 0x7e {
+			return seq.String()
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEditLine(t *testing.T) {
+	history := []string{"/agents", "/run review topic=go"}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"typed", "hello\r", "hello"},
+		{"backspace", "help\x7f\x7fllo\r", "hello"},
+		{"insert after moving left", "hllo\x1b[D\x1b[D\x1b[De\r", "hello"},
+		{"home and end", "ello\x01h\x05!\r", "hello!"},
+		{"kill to end", "hello world\x01\x1b[C\x1b[C\x1b[C\x1b[C\x1b[C\x0b\r", "hello"},
+		{"recall previous", "\x1b[A\r", "/run review topic=go"},
+		{"recall older then edit", "\x1b[A\x1b[A /extra\r", "/agents /extra"},
+		{"down returns to the draft", "draft\x1b[A\x1b[B\r", "draft"},
+		{"delete key", "hxello\x01\x1b[C\x1b[3~\r", "hello"},
+		{"utf-8", "héllé\x7fo\r", "héllo"},
+		{"ctrl-c abandons the line", "oops\x03", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			got, err := editLine(bufio.NewReader(strings.NewReader(tt.input)), &out, "> ", history)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("editLine(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	_, err := editLine(bufio.NewReader(strings.NewReader("\x04")), io.Discard, "> ", history)
+	if !errors.Is(err, io.EOF) {
+		t.Errorf("Ctrl-D on an empty line: err = %v, want io.EOF", err)
+	}
+}
@@ -5,12 +5,17 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
+	vega "github.com/everydev1618/govega"
 	"github.com/everydev1618/govega/dsl"
 )
 
@@ -42,6 +47,8 @@ func main() {
 		replCmd(args)
 	case "serve":
 		serveCmd(args)
+	case "worker":
+		workerCmd(args)
 	case "reset":
 		resetCmd(args)
 	case "version":
@@ -68,6 +75,7 @@ Commands:
   validate  Validate a .vega.yaml file
   repl      Interactive REPL for exploring agents
   serve     Start web dashboard and REST API server
+  worker    Run workflows from a shared job queue
   reset     Delete all agents, files, chat history, and memory
   version   Print version information
   help      Show this help message
@@ -80,6 +88,7 @@ Examples:
   vega repl team.vega.yaml
   vega serve
   vega serve team.vega.yaml --addr :8080
+  vega worker team.vega.yaml --queue /mnt/shared/vega-jobs
 
 Run 'vega <command> --help' for more information on a command.`)
 }
@@ -87,12 +96,16 @@ Run 'vega <command> --help' for more information on a command.`)
 // runCmd executes a workflow from a .vega.yaml file.
 func runCmd(args []string) {
 	fs := flag.NewFlagSet("run", flag.ExitOnError)
-	workflow := fs.String("workflow", "", "Workflow to execute")
-	task := fs.String("task", "", "Task description to pass to workflow")
-	timeout := fs.Duration("timeout", 30*time.Minute, "Maximum execution time")
-	output := fs.String("output", "", "Output format: json, yaml, or text (default)")
-	inputFile := fs.String("input", "", "JSON file containing workflow inputs")
-	verbose := fs.Bool("verbose", false, "Enable verbose output")
+	var opts runOptions
+	fs.StringVar(&opts.workflow, "workflow", "", "Workflow to execute")
+	fs.StringVar(&opts.task, "task", "", "Task description to pass to workflow")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Minute, "Maximum execution time")
+	fs.StringVar(&opts.output, "output", "", "Output format: json, yaml, or text (default)")
+	fs.StringVar(&opts.inputFile, "input", "", "JSON file containing workflow inputs")
+	fs.BoolVar(&opts.verbose, "verbose", false, "Enable verbose output")
+	fs.BoolVar(&opts.stream, "stream", false, "Print step progress, token usage and agent output as it is generated to stderr while running")
+	fs.Float64Var(&opts.maxCost, "max-cost", 0, "Abort the run once cumulative cost across all agents exceeds this many USD (0 = no limit)")
+	watch := fs.Bool("watch", false, "Re-run the workflow whenever the file or a file it loads changes")
 
 	fs.Usage = func() {
 		fmt.Println(`Usage: vega run <file.vega.yaml> [options]
@@ -104,7 +117,10 @@ Options:`)
 		fmt.Println(`
 Examples:
   vega run team.vega.yaml --workflow code-review --task "Build a REST API"
-  vega run team.vega.yaml --workflow process-data --input params.json`)
+  vega run team.vega.yaml --workflow process-data --input params.json
+  vega run team.vega.yaml --stream --task "Build a REST API" > result.txt
+  vega run team.vega.yaml --task "Build a REST API" --max-cost 2.00
+  vega run team.vega.yaml --task "Build a REST API" --watch`)
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -120,67 +136,81 @@ Examples:
 
 	file := fs.Arg(0)
 
+	if *watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		watchRun(ctx, file, opts)
+		return
+	}
+
+	if err := runWorkflowFile(context.Background(), file, opts); err != nil {
+		printRunError(err)
+		os.Exit(1)
+	}
+}
+
+// runOptions are the vega run flags that shape a single run.
+type runOptions struct {
+	workflow  string
+	task      string
+	timeout   time.Duration
+	output    string
+	inputFile string
+	verbose   bool
+	stream    bool
+	maxCost   float64
+}
+
+// runWorkflowFile parses file and runs one of its workflows, writing the
+// result to stdout. The interpreter is shut down before it returns.
+func runWorkflowFile(ctx context.Context, file string, opts runOptions) error {
 	// Parse the file
 	parser := dsl.NewParser()
 	doc, err := parser.ParseFile(file)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", file, err)
-		os.Exit(1)
+		return fmt.Errorf("parsing %s: %w", file, err)
 	}
 
-	if *verbose {
+	if opts.verbose {
 		fmt.Printf("Loaded %s: %d agents, %d workflows\n",
 			doc.Name, len(doc.Agents), len(doc.Workflows))
 	}
 
 	// Determine which workflow to run
-	workflowName := *workflow
+	workflowName := opts.workflow
 	if workflowName == "" {
 		// If only one workflow, use it
-		if len(doc.Workflows) == 1 {
-			for name := range doc.Workflows {
-				workflowName = name
-			}
-		} else {
-			fmt.Fprintln(os.Stderr, "Error: multiple workflows found, specify one with --workflow")
-			fmt.Fprintln(os.Stderr, "Available workflows:")
-			for name := range doc.Workflows {
-				fmt.Fprintf(os.Stderr, "  - %s\n", name)
-			}
-			os.Exit(1)
+		if len(doc.Workflows) != 1 {
+			return fmt.Errorf("multiple workflows found, specify one with --workflow\n%s", availableWorkflows(doc))
+		}
+		for name := range doc.Workflows {
+			workflowName = name
 		}
 	}
 
 	// Check workflow exists
 	wf, ok := doc.Workflows[workflowName]
 	if !ok {
-		fmt.Fprintf(os.Stderr, "Error: workflow '%s' not found\n", workflowName)
-		fmt.Fprintln(os.Stderr, "Available workflows:")
-		for name := range doc.Workflows {
-			fmt.Fprintf(os.Stderr, "  - %s\n", name)
-		}
-		os.Exit(1)
+		return fmt.Errorf("workflow '%s' not found\n%s", workflowName, availableWorkflows(doc))
 	}
 
 	// Build inputs
 	inputs := make(map[string]any)
 
 	// Load from file if specified
-	if *inputFile != "" {
-		data, err := os.ReadFile(*inputFile)
+	if opts.inputFile != "" {
+		data, err := os.ReadFile(opts.inputFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading input file: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("reading input file: %w", err)
 		}
 		if err := json.Unmarshal(data, &inputs); err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing input file: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("parsing input file: %w", err)
 		}
 	}
 
 	// Override with --task if provided
-	if *task != "" {
-		inputs["task"] = *task
+	if opts.task != "" {
+		inputs["task"] = opts.task
 	}
 
 	// Validate required inputs
@@ -190,8 +220,7 @@ Examples:
 				if input.Default != nil {
 					inputs[name] = input.Default
 				} else {
-					fmt.Fprintf(os.Stderr, "Error: required input '%s' not provided\n", name)
-					os.Exit(1)
+					return fmt.Errorf("required input '%s' not provided", name)
 				}
 			}
 		}
@@ -200,43 +229,60 @@ Examples:
 	// Create interpreter
 	interp, err := dsl.NewInterpreter(doc)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error creating interpreter: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("creating interpreter: %w", err)
 	}
 	defer interp.Shutdown()
 
-	if *verbose {
+	if opts.verbose {
 		fmt.Printf("Running workflow: %s\n", workflowName)
 	}
 
+	// Progress and streamed text go to stderr so stdout carries only the
+	// result.
+	if opts.stream {
+		progress := &progressWriter{w: os.Stderr, tokens: func() (in, out int) {
+			for _, p := range interp.Orchestrator().List() {
+				m := p.Metrics()
+				in += m.InputTokens
+				out += m.OutputTokens
+			}
+			return in, out
+		}}
+		interp.OnStep(progress.step)
+		interp.OnStepOutput(progress.output)
+	}
+
 	// Execute with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	ctx, cancel := context.WithTimeout(ctx, opts.timeout)
 	defer cancel()
 
-	result, err := interp.Execute(ctx, workflowName, inputs)
+	result, err := executeWithCostCeiling(ctx, interp, workflowName, inputs, opts.maxCost)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
 	// Output result
-	switch *output {
-	case "json":
-		data, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(data))
-	case "yaml":
-		// Simple YAML output for primitives
-		switch v := result.(type) {
-		case string:
-			fmt.Println(v)
-		case map[string]any:
-			data, _ := json.MarshalIndent(v, "", "  ")
-			fmt.Println(string(data))
-		default:
-			fmt.Printf("%v\n", result)
-		}
-	default:
-		fmt.Printf("%v\n", result)
+	writeResult(os.Stdout, result, opts.output)
+	return nil
+}
+
+// availableWorkflows lists the document's workflows for error messages.
+func availableWorkflows(doc *dsl.Document) string {
+	var b strings.Builder
+	b.WriteString("Available workflows:")
+	for name := range doc.Workflows {
+		fmt.Fprintf(&b, "\n  - %s", name)
+	}
+	return b.String()
+}
+
+// printRunError reports a failed run on stderr.
+func printRunError(err error) {
+	var ceiling *costCeilingError
+	if errors.As(err, &ceiling) {
+		fmt.Fprintf(os.Stderr, "Aborted: %v\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 	}
 }
 
@@ -274,7 +320,15 @@ Examples:
 	parser := dsl.NewParser()
 	doc, err := parser.ParseFile(file)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Validation failed: %v\n", err)
+		var problems interface{ Unwrap() []error }
+		if errors.As(err, &problems) && len(problems.Unwrap()) > 1 {
+			fmt.Fprintf(os.Stderr, "Validation failed with %d problems:\n", len(problems.Unwrap()))
+			for _, problem := range problems.Unwrap() {
+				fmt.Fprintf(os.Stderr, "  - %s\n", strings.ReplaceAll(problem.Error(), "\n", "\n  "))
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Validation failed: %v\n", err)
+		}
 		os.Exit(1)
 	}
 
@@ -341,10 +395,16 @@ If a .vega.yaml file is provided, agents and workflows will be pre-loaded.
 Commands:
   /agents          List available agents
   /workflows       List available workflows
-  /run <workflow>  Run a workflow
+  /run <workflow> [key=value ...]
+                   Run a workflow with inputs
   /ask <agent>     Start a conversation with an agent
+  /save <file>     Save the current conversation
+  /load <file>     Restore a saved conversation
   /help            Show REPL help
-  /quit            Exit the REPL`)
+  /quit            Exit the REPL
+
+Input history is kept in ~/.vega/repl_history; use the up and down
+arrows to recall it.`)
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -375,7 +435,14 @@ Commands:
 	fmt.Printf("Loaded: %s (%d agents, %d workflows)\n",
 		doc.Name, len(doc.Agents), len(doc.Workflows))
 
-	repl := dsl.NewREPL(interp, dsl.WithREPLPrompt("vega"))
+	opts := []dsl.REPLOption{
+		dsl.WithREPLPrompt("vega"),
+		dsl.WithREPLHistory(filepath.Join(vega.Home(), "repl_history")),
+	}
+	if term := newTerminalReader(); term != nil {
+		opts = append(opts, dsl.WithREPLLineReader(term))
+	}
+	repl := dsl.NewREPL(interp, opts...)
 	repl.Run()
 }
 
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	vega "github.com/everydev1618/govega"
 	"github.com/everydev1618/govega/dsl"
@@ -29,7 +31,9 @@ func defaultDocument() *dsl.Document {
 func serveCmd(args []string) {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
 	addr := fs.String("addr", "", "HTTP listen address (default: auto-assign free port)")
-	dbPath := fs.String("db", vega.DefaultDBPath(), "SQLite database path")
+	dbPath := fs.String("db", vega.DefaultDBPath(), "SQLite database path, or a postgres:// URL to share state between instances")
+	warm := fs.String("warm", "", "Comma-separated agents to spawn at startup instead of on first chat")
+	streamRetention := fs.Duration("stream-retention", 30*time.Second, "How long a finished chat stream can still be reconnected to and replayed")
 
 	fs.Usage = func() {
 		fmt.Println(`Usage: vega serve [file.vega.yaml] [options]
@@ -46,7 +50,10 @@ Examples:
   vega serve
   vega serve team.vega.yaml
   vega serve team.vega.yaml --addr :8080
-  vega serve team.vega.yaml --db ~/.vega/custom.db`)
+  vega serve team.vega.yaml --db ~/.vega/custom.db
+  vega serve team.vega.yaml --db postgres://vega@db:5432/vega
+  vega serve team.vega.yaml --warm iris,support
+  vega serve team.vega.yaml --stream-retention 2m`)
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -75,7 +82,12 @@ Examples:
 		fmt.Fprintf(os.Stderr, "Error creating interpreter: %v\n", err)
 		os.Exit(1)
 	}
-	defer interp.Shutdown()
+	defer func() {
+		// Let in-flight agent calls finish before exiting.
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		interp.Drain(ctx)
+	}()
 
 	// Ensure ~/.vega directory exists for the database.
 	if err := vega.EnsureHome(); err != nil {
@@ -115,7 +127,15 @@ Examples:
 		DBPath:        *dbPath,
 		TelegramToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
 		TelegramAgent: os.Getenv("TELEGRAM_AGENT"),
+		AdminToken:    os.Getenv("VEGA_ADMIN_TOKEN"),
 		Company:       company,
+
+		StreamRetention: *streamRetention,
+	}
+	for _, name := range strings.Split(*warm, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.WarmAgents = append(cfg.WarmAgents, name)
+		}
 	}
 
 	srv := serve.New(interp, cfg)
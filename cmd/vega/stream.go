@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+// progressPrinter returns a step observer that writes one line per step
+// transition to w. tokens reports the running input/output token totals
+// and is sampled whenever a step finishes.
+func progressPrinter(w io.Writer, tokens func() (in, out int)) func(dsl.StepEvent) {
+	return (&progressWriter{w: w, tokens: tokens}).step
+}
+
+// progressWriter writes --stream output: a line per step transition and
+// the text of agent steps as it streams in.
+type progressWriter struct {
+	w      io.Writer
+	tokens func() (in, out int)
+
+	mu      sync.Mutex
+	source  string // step whose output was last written or announced
+	midLine bool   // streamed text has not ended with a newline
+}
+
+// stepKey identifies a step for progressWriter.source.
+func stepKey(index, depth int, agent string) string {
+	return fmt.Sprintf("%d/%d/%s", index, depth, agent)
+}
+
+func (p *progressWriter) step(ev dsl.StepEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.endLine()
+
+	label := ev.Type
+	if ev.Agent != "" {
+		label += " " + ev.Agent
+	}
+	line := fmt.Sprintf("%s[step %d] %s %s", strings.Repeat("  ", ev.Depth), ev.Index+1, label, ev.Status)
+
+	switch ev.Status {
+	case dsl.StepStarted:
+		p.source = stepKey(ev.Index, ev.Depth, ev.Agent)
+	case dsl.StepCompleted, dsl.StepFailed:
+		if p.tokens != nil {
+			in, out := p.tokens()
+			line += fmt.Sprintf(" (tokens: %d in, %d out)", in, out)
+		}
+		if ev.Error != "" {
+			line += ": " + ev.Error
+		}
+	}
+	fmt.Fprintln(p.w, line)
+}
+
+// output writes streamed agent text. When it comes from a different step
+// than the last line written, as with parallel steps, a header names the
+// step first.
+func (p *progressWriter) output(out dsl.StepOutput) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := stepKey(out.Index, out.Depth, out.Agent); key != p.source {
+		p.endLine()
+		fmt.Fprintf(p.w, "%s[step %d] agent %s:\n", strings.Repeat("  ", out.Depth), out.Index+1, out.Agent)
+		p.source = key
+	}
+	fmt.Fprint(p.w, out.Delta)
+	p.midLine = !strings.HasSuffix(out.Delta, "\n")
+}
+
+// endLine finishes a partly written line of streamed text.
+func (p *progressWriter) endLine() {
+	if p.midLine {
+		fmt.Fprintln(p.w)
+		p.midLine = false
+	}
+}
+
+// writeResult prints a workflow result to w in the requested format.
+func writeResult(w io.Writer, result any, format string) {
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintln(w, string(data))
+	case "yaml":
+		// Simple YAML output for primitives
+		switch v := result.(type) {
+		case string:
+			fmt.Fprintln(w, v)
+		case map[string]any:
+			data, _ := json.MarshalIndent(v, "", "  ")
+			fmt.Fprintln(w, string(data))
+		default:
+			fmt.Fprintf(w, "%v\n", result)
+		}
+	default:
+		fmt.Fprintf(w, "%v\n", result)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+func TestStreamProgressSeparateFromResult(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	tokens := 0
+	observe := progressPrinter(&stderr, func() (int, int) {
+		tokens += 100
+		return tokens, tokens / 2
+	})
+	observe(dsl.StepEvent{Workflow: "flow", Index: 0, Type: "agent", Agent: "writer", Status: dsl.StepStarted})
+	observe(dsl.StepEvent{Workflow: "flow", Index: 0, Type: "agent", Agent: "writer", Status: dsl.StepCompleted})
+	observe(dsl.StepEvent{Workflow: "flow", Index: 1, Type: "agent", Agent: "editor", Status: dsl.StepFailed, Error: "boom"})
+	writeResult(&stdout, "final answer", "")
+
+	if got := stdout.String(); got != "final answer\n" {
+		t.Errorf("stdout = %q, want only the result", got)
+	}
+
+	progress := stderr.String()
+	for _, want := range []string{
+		"[step 1] agent writer started",
+		"[step 1] agent writer completed (tokens: 100 in, 50 out)",
+		"[step 2] agent editor failed (tokens: 200 in, 100 out): boom",
+	} {
+		if !strings.Contains(progress, want) {
+			t.Errorf("stderr missing %q, got:\n%s", want, progress)
+		}
+	}
+	if strings.Contains(progress, "final answer") {
+		t.Error("result leaked into stderr")
+	}
+}
+
+func TestStreamAgentOutput(t *testing.T) {
+	var stderr bytes.Buffer
+	p := &progressWriter{w: &stderr}
+
+	p.step(dsl.StepEvent{Index: 0, Type: "agent", Agent: "writer", Status: dsl.StepStarted})
+	p.output(dsl.StepOutput{Index: 0, Agent: "writer", Delta: "Hello, "})
+	p.output(dsl.StepOutput{Index: 0, Agent: "writer", Delta: "world"})
+	p.step(dsl.StepEvent{Index: 0, Type: "agent", Agent: "writer", Status: dsl.StepCompleted})
+
+	// Parallel steps interleave, so each switch is labeled.
+	p.step(dsl.StepEvent{Index: 1, Depth: 1, Type: "agent", Agent: "a", Status: dsl.StepStarted})
+	p.step(dsl.StepEvent{Index: 1, Depth: 1, Type: "agent", Agent: "b", Status: dsl.StepStarted})
+	p.output(dsl.StepOutput{Index: 1, Depth: 1, Agent: "a", Delta: "from a\n"})
+	p.output(dsl.StepOutput{Index: 1, Depth: 1, Agent: "b", Delta: "from b"})
+
+	want := `[step 1] agent writer started
+Hello, world
+[step 1] agent writer completed
+  [step 2] agent a started
+  [step 2] agent b started
+  [step 2] agent a:
+from a
+  [step 2] agent b:
+from b`
+	if got := stderr.String(); got != want {
+		t.Errorf("stderr =\n%s\nwant\n%s", got, want)
+	}
+}
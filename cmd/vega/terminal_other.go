@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import "errors"
+
+// isTerminal reports false: the line editor needs a Unix terminal, so the
+// REPL reads plain lines instead.
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
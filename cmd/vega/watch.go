@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+const (
+	// watchPollInterval is how often --watch checks the files for changes.
+	watchPollInterval = 500 * time.Millisecond
+	// watchDebounce is how long the files must stay unchanged before a
+	// re-run, so an editor's burst of writes triggers one run.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// watchRun runs file, then re-runs it each time it or a file it loads
+// changes, until ctx is done. Each run gets a fresh interpreter, which is
+// shut down before the next starts.
+func watchRun(ctx context.Context, file string, opts runOptions) {
+	for {
+		if err := runWorkflowFile(ctx, file, opts); err != nil && ctx.Err() == nil {
+			printRunError(err)
+		}
+
+		paths := watchedFiles(file)
+		fmt.Fprintf(os.Stderr, "\nWatching %s for changes (Ctrl-C to stop)\n", strings.Join(paths, ", "))
+		if !waitForChange(ctx, paths, watchPollInterval, watchDebounce) {
+			return
+		}
+		fmt.Fprintf(os.Stderr, "\n──── %s changed, re-running at %s ────\n\n", file, time.Now().Format("15:04:05"))
+	}
+}
+
+// watchedFiles returns file and the local files it loads: file://
+// knowledge sources and tool includes. If file does not parse, only file
+// itself is watched.
+func watchedFiles(file string) []string {
+	paths := []string{file}
+	doc, err := dsl.NewParser().ParseFile(file)
+	if err != nil {
+		return paths
+	}
+
+	seen := map[string]bool{file: true}
+	add := func(path string) {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	for _, agent := range doc.Agents {
+		for _, uri := range agent.Knowledge {
+			if path, ok := strings.CutPrefix(uri, "file://"); ok {
+				add(path)
+			}
+		}
+	}
+	for _, tool := range doc.Tools {
+		for _, path := range tool.Include {
+			add(path)
+		}
+	}
+	return paths
+}
+
+// fileStamp identifies a version of a file; the zero value means the file
+// is missing.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFiles(paths []string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			stamps[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+	}
+	return stamps
+}
+
+// waitForChange polls paths until one of them changes and then stays
+// unchanged for debounce. It returns false if ctx is done first.
+func waitForChange(ctx context.Context, paths []string, interval, debounce time.Duration) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := statFiles(paths)
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+
+		now := statFiles(paths)
+		if !sameStamps(now, last) {
+			last, changedAt = now, time.Now()
+			continue
+		}
+		if !changedAt.IsZero() && time.Since(changedAt) >= debounce {
+			return true
+		}
+	}
+}
+
+func sameStamps(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, stamp := range a {
+		if other, ok := b[path]; !ok || !stamp.modTime.Equal(other.modTime) || stamp.size != other.size {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "team.vega.yaml")
+	notes := filepath.Join(dir, "notes.md")
+	os.WriteFile(notes, []byte("v1"), 0644)
+	os.WriteFile(file, []byte(`
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+    knowledge: ["file://`+notes+`"]
+`), 0644)
+
+	paths := watchedFiles(file)
+	if !slices.Equal(paths, []string{file, notes}) {
+		t.Fatalf("watched %v, want the file and its knowledge", paths)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changed := make(chan bool)
+	go func() { changed <- waitForChange(ctx, paths, 10*time.Millisecond, 50*time.Millisecond) }()
+
+	// A burst of saves is one change, reported once they settle.
+	time.Sleep(30 * time.Millisecond)
+	var last time.Time
+	for i := range 3 {
+		os.WriteFile(notes, []byte("v2"+string(rune('a'+i))), 0644)
+		last = time.Now()
+		time.Sleep(20 * time.Millisecond)
+	}
+	if !<-changed {
+		t.Fatal("change not detected")
+	}
+	if waited := time.Since(last); waited < 50*time.Millisecond {
+		t.Errorf("reported %v after the last save, want the debounce to pass", waited)
+	}
+
+	stopped, stop := context.WithCancel(context.Background())
+	stop()
+	if waitForChange(stopped, paths, 10*time.Millisecond, 50*time.Millisecond) {
+		t.Error("cancelled wait reported a change")
+	}
+}
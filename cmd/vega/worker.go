@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/dsl"
+)
+
+// workerCmd runs workflows from a shared job queue until interrupted.
+func workerCmd(args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	queueDir := fs.String("queue", "", "Job queue directory shared with other workers (required)")
+	id := fs.String("id", "", "Worker ID (default: hostname-pid)")
+	poll := fs.Duration("poll", time.Second, "How often an idle worker checks the queue")
+	timeout := fs.Duration("timeout", 30*time.Minute, "Maximum execution time per job")
+	claimTimeout := fs.Duration("claim-timeout", 0, "Requeue jobs claimed longer than this, e.g. by a crashed worker; keep it above --timeout (0 = never)")
+	callbackDir := fs.String("callback-dir", "", "Directory to write job events to")
+	callbackURL := fs.String("callback-url", "", "URL to POST job events to")
+
+	fs.Usage = func() {
+		fmt.Println(`Usage: vega worker <file.vega.yaml> --queue <dir> [options]
+
+Claim workflow runs from a job queue, execute them, and post the results
+back. Start several workers on the same queue to run workflows in parallel
+across processes or machines. See docs/WORKERS.md for the job protocol.
+
+Options:`)
+		fs.PrintDefaults()
+		fmt.Println(`
+Examples:
+  vega worker team.vega.yaml --queue /mnt/shared/vega-jobs
+  vega worker team.vega.yaml --queue /mnt/shared/vega-jobs --callback-url http://orchestrator:3001/events
+  vega worker team.vega.yaml --queue /mnt/shared/vega-jobs --timeout 30m --claim-timeout 45m`)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	requireAPIKey()
+
+	if fs.NArg() < 1 || *queueDir == "" {
+		fmt.Fprintln(os.Stderr, "Error: a .vega.yaml file and --queue are required")
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	doc, err := dsl.NewParser().ParseFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", fs.Arg(0), err)
+		os.Exit(1)
+	}
+
+	queue, err := vega.NewDirJobQueue(*queueDir, vega.WithClaimTimeout(*claimTimeout))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	interp, err := dsl.NewInterpreter(doc)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating interpreter: %v\n", err)
+		os.Exit(1)
+	}
+	defer interp.Shutdown()
+
+	opts := []vega.WorkerOption{vega.WithPollInterval(*poll)}
+	if *id != "" {
+		opts = append(opts, vega.WithWorkerID(*id))
+	}
+	if *callbackDir != "" || *callbackURL != "" {
+		opts = append(opts, vega.WithWorkerCallback(vega.NewCallbackConfig(*callbackDir, *callbackURL)))
+	}
+
+	worker := vega.NewWorker(queue, func(ctx context.Context, job *vega.Job) (string, error) {
+		if _, ok := doc.Workflows[job.Workflow]; !ok {
+			return "", fmt.Errorf("workflow '%s' not found", job.Workflow)
+		}
+		ctx, cancel := context.WithTimeout(ctx, *timeout)
+		defer cancel()
+		ctx = dsl.ContextWithRunID(ctx, job.ID)
+
+		result, err := interp.Execute(ctx, job.Workflow, job.Inputs)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%v", result), nil
+	}, opts...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Fprintf(os.Stderr, "Worker %s polling %s\n", worker.ID(), *queueDir)
+	worker.Run(ctx)
+}
@@ -51,14 +51,14 @@
 // Supervision strategies:
 //   - Restart: Automatically restart the process on failure
 //   - Stop: Stop the process permanently on failure
-//   - Escalate: Pass the failure to a supervisor agent
+//   - Escalate: Pass the failure to the nearest ancestor that traps exits
 //
 // # Tools
 //
 // Register tools for agents to use:
 //
 //	tools := tools.NewTools()
-//	tools.RegisterBuiltins() // read_file, write_file, run_command
+//	tools.RegisterBuiltins() // read_file, write_file, append_file, list_files, exec, ...
 //
 //	tools.Register("greet", func(name string) string {
 //	    return "Hello, " + name + "!"
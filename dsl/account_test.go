@@ -0,0 +1,92 @@
+package dsl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAgentAccounts(t *testing.T) {
+	var mu sync.Mutex
+	keys := make(map[string]string) // company in the system prompt -> API key
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			System any `json:"system"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		system, _ := json.Marshal(req.System)
+		who := "unknown"
+		for _, name := range []string{"Acme", "Globex"} {
+			if strings.Contains(string(system), "You work for "+name) {
+				who = name
+			}
+		}
+		mu.Lock()
+		keys[who] = r.Header.Get("x-api-key")
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"type":"message","role":"assistant","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn"}`))
+	}))
+	defer api.Close()
+
+	t.Setenv("GLOBEX_KEY", "sk-globex")
+	doc := mustParse(t, `
+name: Test
+settings:
+  accounts:
+    acme:
+      api_key: sk-acme
+      base_url: `+api.URL+`
+    globex:
+      api_key: ${GLOBEX_KEY}
+      base_url: `+api.URL+`
+agents:
+  acme-support:
+    model: test-model
+    system: You work for Acme.
+    account: acme
+  globex-support:
+    model: test-model
+    system: You work for Globex.
+    account: globex
+`)
+	interp, err := NewInterpreter(doc, WithLLM(&echoLLM{}), WithLazySpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	for _, agent := range []string{"acme-support", "globex-support"} {
+		if _, err := interp.SendToAgent(context.Background(), agent, "hello"); err != nil {
+			t.Fatalf("%s: %v", agent, err)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if keys["Acme"] != "sk-acme" || keys["Globex"] != "sk-globex" {
+		t.Errorf("API keys by agent = %v, want each agent's own account key", keys)
+	}
+}
+
+func TestAgentAccountValidation(t *testing.T) {
+	_, err := NewParser().Parse([]byte(`
+name: Test
+settings:
+  accounts:
+    acme:
+      api_key: sk-acme
+agents:
+  support:
+    model: test-model
+    system: You help.
+    account: acmee
+`))
+	if err == nil || !strings.Contains(err.Error(), "unknown account 'acmee'") {
+		t.Fatalf("Parse() error = %v, want unknown account", err)
+	}
+}
@@ -570,3 +570,7 @@ func (m *stubLLM) GenerateStream(ctx context.Context, messages []llm.Message, to
 	}()
 	return ch, nil
 }
+
+func (m *stubLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
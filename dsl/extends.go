@@ -0,0 +1,77 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveAgent returns def, the definition of agent name, with the
+// definitions it extends merged in. Each field the child sets overrides its
+// parent's; each field it leaves unset is inherited, all the way up the
+// extends chain. Lists (tools, knowledge, team) are inherited whole or
+// replaced whole: a child that sets tools gets exactly those tools, not the
+// parent's plus its own. Identity fields (display_name, title, avatar) are
+// never inherited.
+//
+// def itself is not modified. A missing parent or a cycle is an error.
+func resolveAgent(agents map[string]*Agent, name string, def *Agent) (*Agent, error) {
+	if def.Extends == "" {
+		return def, nil
+	}
+
+	merged := *def
+	seen := map[string]bool{name: true}
+	chain := []string{name}
+	for parentName := def.Extends; parentName != ""; {
+		chain = append(chain, parentName)
+		if seen[parentName] {
+			return nil, fmt.Errorf("circular extends: %s", strings.Join(chain, " -> "))
+		}
+		seen[parentName] = true
+
+		parent, ok := agents[parentName]
+		if !ok {
+			return nil, fmt.Errorf("extends unknown agent %q", parentName)
+		}
+		inheritAgent(&merged, parent)
+		parentName = parent.Extends
+	}
+	return &merged, nil
+}
+
+// inheritAgent fills the fields child leaves unset from parent.
+func inheritAgent(child, parent *Agent) {
+	inherit(&child.Model, parent.Model)
+	inherit(&child.FallbackModel, parent.FallbackModel)
+	inherit(&child.Account, parent.Account)
+	inherit(&child.System, parent.System)
+	inherit(&child.Temperature, parent.Temperature)
+	inherit(&child.Budget, parent.Budget)
+	inherit(&child.MaxHistory, parent.MaxHistory)
+	inherit(&child.PersistChat, parent.PersistChat)
+	inherit(&child.Memory, parent.Memory)
+	inherit(&child.Supervision, parent.Supervision)
+	inherit(&child.Retry, parent.Retry)
+	inherit(&child.RateLimit, parent.RateLimit)
+	inherit(&child.CircuitBreaker, parent.CircuitBreaker)
+	inherit(&child.Skills, parent.Skills)
+	inherit(&child.Delegation, parent.Delegation)
+
+	if len(child.Tools) == 0 {
+		child.Tools = parent.Tools
+	}
+	if len(child.Knowledge) == 0 {
+		child.Knowledge = parent.Knowledge
+	}
+	if len(child.Team) == 0 {
+		child.Team = parent.Team
+	}
+}
+
+// inherit sets *field to value if *field is the zero value.
+func inherit[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
+	}
+}
@@ -0,0 +1,99 @@
+package dsl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtendsMultiLevel(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  base:
+    model: base-model
+    system: You are part of the support team.
+    temperature: 0.2
+    tools: [read_file, write_file]
+    budget: "$1.00"
+    retry:
+      max_attempts: 3
+  billing:
+    extends: base
+    system: You handle billing questions.
+    tools: [read_file]
+  refunds:
+    extends: billing
+    display_name: Refunds
+    model: refunds-model
+`)
+
+	refunds, err := resolveAgent(doc.Agents, "refunds", doc.Agents["refunds"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refunds.Model != "refunds-model" {
+		t.Errorf("Model = %q, want the child's own", refunds.Model)
+	}
+	if refunds.System != "You handle billing questions." {
+		t.Errorf("System = %q, want the nearer ancestor's", refunds.System)
+	}
+	if refunds.Temperature == nil || *refunds.Temperature != 0.2 {
+		t.Errorf("Temperature = %v, want 0.2 from base", refunds.Temperature)
+	}
+	if strings.Join(refunds.Tools, ",") != "read_file" {
+		t.Errorf("Tools = %v, want billing's list replacing base's", refunds.Tools)
+	}
+	if refunds.Budget != "$1.00" || refunds.Retry == nil || refunds.Retry.MaxAttempts != 3 {
+		t.Errorf("Budget = %q, Retry = %+v, want base's", refunds.Budget, refunds.Retry)
+	}
+	if doc.Agents["refunds"].System != "" {
+		t.Error("resolving modified the definition")
+	}
+
+	billing, err := resolveAgent(doc.Agents, "billing", doc.Agents["billing"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if billing.DisplayName != "" || billing.Model != "base-model" {
+		t.Errorf("billing = %+v, want base's model and no display name", billing)
+	}
+
+	interp, err := NewInterpreter(doc, WithLLM(&echoLLM{}), WithLazySpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+	proc, err := interp.EnsureAgent("refunds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(proc.Agent.System.Prompt(), "You handle billing questions.") {
+		t.Errorf("spawned system prompt = %q", proc.Agent.System.Prompt())
+	}
+	if proc.Agent.Temperature == nil || *proc.Agent.Temperature != 0.2 {
+		t.Errorf("spawned temperature = %v, want 0.2", proc.Agent.Temperature)
+	}
+	for _, schema := range proc.Agent.Tools.Schema() {
+		if schema.Name == "write_file" {
+			t.Error("spawned agent has write_file from base's replaced tools list")
+		}
+	}
+}
+
+func TestExtendsRejectsCycles(t *testing.T) {
+	_, err := NewParser().Parse([]byte(`
+name: Test
+agents:
+  a:
+    extends: c
+    model: test-model
+    system: A.
+  b:
+    extends: a
+  c:
+    extends: b
+`))
+	if err == nil || !strings.Contains(err.Error(), "circular extends") {
+		t.Fatalf("Parse() error = %v, want circular extends", err)
+	}
+}
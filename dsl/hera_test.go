@@ -71,10 +71,13 @@ func TestHeraCreateAgent(t *testing.T) {
 	ctx := context.Background()
 
 	result, err := interp.Tools().Execute(ctx, "create_agent", map[string]any{
-		"name":   "reviewer",
-		"system": "You review code carefully.",
-		"model":  "test-model",
-		"tools":  []any{"read_file"},
+		"name":         "reviewer",
+		"system":       "You review code carefully.",
+		"model":        "test-model",
+		"display_name": "Reviewer",
+		"title":        "Tester",
+		"avatar":       "n1",
+		"tools":        []any{"read_file"},
 	})
 	if err != nil {
 		t.Fatalf("create_agent: %v", err)
@@ -104,11 +107,28 @@ func TestHeraCreateAgentProtectsHera(t *testing.T) {
 	ctx := context.Background()
 
 	_, err := interp.Tools().Execute(ctx, "create_agent", map[string]any{
-		"name":   "hera",
-		"system": "Trying to overwrite hera",
+		"name":         "hera",
+		"system":       "Trying to overwrite hera",
+		"display_name": "Hera",
+		"title":        "Impostor",
+		"avatar":       "n1",
 	})
-	if err == nil {
-		t.Fatal("should not be able to create agent named 'hera'")
+	if err == nil || !strings.Contains(err.Error(), "hera") {
+		t.Fatalf("create_agent(hera) error = %v, want it refused", err)
+	}
+}
+
+func TestHeraCreateAgentRequiresPersona(t *testing.T) {
+	interp := newHeraTestInterpreter(t)
+	defer interp.Shutdown()
+
+	RegisterHeraTools(interp, nil)
+	_, err := interp.Tools().Execute(context.Background(), "create_agent", map[string]any{
+		"name":   "nameless",
+		"system": "You have no face.",
+	})
+	if err == nil || !strings.Contains(err.Error(), "display_name") {
+		t.Errorf("create_agent without a persona error = %v, want display_name required", err)
 	}
 }
 
@@ -128,9 +148,12 @@ func TestHeraDeleteAgent(t *testing.T) {
 
 	// Create an agent first.
 	interp.Tools().Execute(ctx, "create_agent", map[string]any{
-		"name":   "temp",
-		"system": "Temporary agent.",
-		"model":  "test-model",
+		"name":         "temp",
+		"system":       "Temporary agent.",
+		"model":        "test-model",
+		"display_name": "Temp",
+		"title":        "Tester",
+		"avatar":       "n1",
 	})
 
 	// Delete it.
@@ -180,9 +203,12 @@ func TestHeraUpdateAgent(t *testing.T) {
 
 	// Create an agent.
 	interp.Tools().Execute(ctx, "create_agent", map[string]any{
-		"name":   "helper",
-		"system": "You help with things.",
-		"model":  "test-model",
+		"name":         "helper",
+		"system":       "You help with things.",
+		"model":        "test-model",
+		"display_name": "Helper",
+		"title":        "Tester",
+		"avatar":       "n1",
 	})
 
 	// Update its system prompt.
@@ -220,14 +246,20 @@ func TestHeraListAgents(t *testing.T) {
 
 	// Create two agents.
 	interp.Tools().Execute(ctx, "create_agent", map[string]any{
-		"name":   "alice",
-		"system": "You are Alice.",
-		"model":  "test-model",
+		"name":         "alice",
+		"system":       "You are Alice.",
+		"model":        "test-model",
+		"display_name": "Alice",
+		"title":        "Tester",
+		"avatar":       "n1",
 	})
 	interp.Tools().Execute(ctx, "create_agent", map[string]any{
-		"name":   "bob",
-		"system": "You are Bob.",
-		"model":  "test-model",
+		"name":         "bob",
+		"system":       "You are Bob.",
+		"model":        "test-model",
+		"display_name": "Bob",
+		"title":        "Tester",
+		"avatar":       "n1",
 	})
 
 	result, err := interp.Tools().Execute(ctx, "list_agents", map[string]any{})
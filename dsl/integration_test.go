@@ -621,8 +621,12 @@ func mustParse(t *testing.T, yamlStr string) *Document {
 
 func newTestInterpreter(t *testing.T, doc *Document) *Interpreter {
 	t.Helper()
-	mockLLM := &stubLLM{response: "ok"}
-	orch := vega.NewOrchestrator(vega.WithLLM(mockLLM))
+	return newTestInterpreterWithLLM(t, doc, &stubLLM{response: "ok"})
+}
+
+func newTestInterpreterWithLLM(t *testing.T, doc *Document, backend llm.LLM, opts ...InterpreterOption) *Interpreter {
+	t.Helper()
+	orch := vega.NewOrchestrator(vega.WithLLM(backend))
 
 	toolSet := tools.NewTools()
 	toolSet.RegisterBuiltins()
@@ -633,6 +637,10 @@ func newTestInterpreter(t *testing.T, doc *Document) *Interpreter {
 		agents:            make(map[string]*vega.Process),
 		tools:             toolSet,
 		delegationConfigs: make(map[string]*DelegationDef),
+		promptVars:        make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(interp)
 	}
 
 	// Spawn all agents
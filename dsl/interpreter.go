@@ -2,10 +2,12 @@ package dsl
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +30,50 @@ func WithLazySpawn() InterpreterOption {
 	}
 }
 
+// WithPromptVars sets variables available to {{...}} placeholders in agent
+// system prompts. A workflow run's own inputs and variables take precedence
+// over them; see templatePrompt.
+func WithPromptVars(vars map[string]any) InterpreterOption {
+	return func(i *Interpreter) {
+		if i.promptVars == nil {
+			i.promptVars = make(map[string]any)
+		}
+		for k, v := range vars {
+			i.promptVars[k] = v
+		}
+	}
+}
+
+// WithKnowledgePrivateHosts lets http(s) knowledge sources be fetched from
+// loopback, private and link-local addresses, such as an internal wiki.
+// They are refused by default, so a knowledge URL can't be used to reach
+// internal services or the cloud metadata endpoint.
+func WithKnowledgePrivateHosts() InterpreterOption {
+	return func(i *Interpreter) {
+		i.knowledgePrivateHosts = true
+	}
+}
+
+// WithLLM sets the LLM backend used by agents that don't configure their
+// own. By default the backend is picked from the environment via llm.New.
+func WithLLM(backend llm.LLM) InterpreterOption {
+	return func(i *Interpreter) {
+		i.llm = backend
+	}
+}
+
+// WithAccountLLM sets the backend for the named account, overriding its
+// settings.accounts entry. Hosts use it to route agents with that account
+// to a client they build, such as one per tenant.
+func WithAccountLLM(account string, backend llm.LLM) InterpreterOption {
+	return func(i *Interpreter) {
+		if i.accountLLMs == nil {
+			i.accountLLMs = make(map[string]llm.LLM)
+		}
+		i.accountLLMs[account] = backend
+	}
+}
+
 // DelegationObserver is called after each agent-to-agent delegation completes.
 // It receives the caller agent name, target agent name, the delegation message,
 // and the response. Implementations should not block.
@@ -52,6 +98,16 @@ type Interpreter struct {
 	onDispatchComplete func(agentName string) // fires when a dispatched agent finishes
 	serverBaseURL      string                 // set by serve package so agents know their public URL
 	yamlAgents         map[string]bool        // original YAML-defined agent names (survives reset)
+	promptVars         map[string]any         // values for {{...}} placeholders in agent system prompts
+	stepObservers      []func(StepEvent)      // notified as workflow steps progress
+	outputObservers    []func(StepOutput)     // receive agent step responses as they stream
+	postProcessors     []ResponsePostProcessor // rewrite responses, in order
+	turnObservers      []func(agent, userMsg, assistantMsg string) // notified after each completed turn
+	llm                llm.LLM                // default backend; nil means llm.New()
+	accountLLMs        map[string]llm.LLM     // backends by account name, built on first use
+	knowledgeCache     map[string]knowledgeEntry // fetched knowledge by URI
+	knowledgeMu        sync.Mutex
+	knowledgePrivateHosts bool // fetch knowledge from private addresses
 	mu                sync.RWMutex
 }
 
@@ -67,8 +123,46 @@ func (i *Interpreter) SetDelegationObserver(fn DelegationObserver) {
 	i.delegationObserver = fn
 }
 
-// NewInterpreter creates a new interpreter for a document.
+// OnTurnComplete registers a callback that fires once after each completed
+// agent turn: a SendToAgent call, a StreamToAgent stream once it finishes,
+// or a workflow agent step. It receives the agent name, the message sent
+// and the agent's response; failed turns are not reported. Callbacks run on
+// their own goroutine so they never delay the response, which makes this
+// the place to hang memory extraction, logging or analytics.
+func (i *Interpreter) OnTurnComplete(fn func(agent, userMsg, assistantMsg string)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.turnObservers = append(i.turnObservers, fn)
+}
+
+// emitTurnComplete runs the OnTurnComplete callbacks in the background.
+func (i *Interpreter) emitTurnComplete(agent, userMsg, assistantMsg string) {
+	i.mu.RLock()
+	observers := append([]func(agent, userMsg, assistantMsg string){}, i.turnObservers...)
+	i.mu.RUnlock()
+	for _, fn := range observers {
+		go fn(agent, userMsg, assistantMsg)
+	}
+}
+
+// NewInterpreter creates a new interpreter for a document. It fails if the
+// document does not pass Validate.
 func NewInterpreter(doc *Document, opts ...InterpreterOption) (*Interpreter, error) {
+	if err := doc.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Apply options first so they can shape the orchestrator and tools.
+	interp := &Interpreter{
+		doc:               doc,
+		agents:            make(map[string]*vega.Process),
+		delegationConfigs: make(map[string]*DelegationDef),
+		promptVars:        make(map[string]any),
+	}
+	for _, opt := range opts {
+		opt(interp)
+	}
+
 	// Create orchestrator with settings
 	orchOpts := []vega.OrchestratorOption{}
 
@@ -79,7 +173,10 @@ func NewInterpreter(doc *Document, opts ...InterpreterOption) (*Interpreter, err
 	}
 
 	// Create default LLM (picks OpenAI-compatible or Anthropic based on env)
-	defaultLLM := llm.New()
+	defaultLLM := interp.llm
+	if defaultLLM == nil {
+		defaultLLM = llm.New()
+	}
 	orchOpts = append(orchOpts, vega.WithLLM(defaultLLM))
 
 	orch := vega.NewOrchestrator(orchOpts...)
@@ -95,6 +192,13 @@ func NewInterpreter(doc *Document, opts ...InterpreterOption) (*Interpreter, err
 		}
 	}
 
+	if doc.Settings != nil && doc.Settings.MaxToolResultBytes != 0 {
+		toolOpts = append(toolOpts, tools.WithMaxResultBytes(doc.Settings.MaxToolResultBytes))
+	}
+	if doc.Settings != nil && doc.Settings.MaxToolArgBytes != 0 {
+		toolOpts = append(toolOpts, tools.WithMaxArgBytes(doc.Settings.MaxToolArgBytes))
+	}
+
 	// Add MCP servers if configured
 	if doc.Settings != nil && doc.Settings.MCP != nil {
 		for _, serverDef := range doc.Settings.MCP.Servers {
@@ -146,6 +250,7 @@ func NewInterpreter(doc *Document, opts ...InterpreterOption) (*Interpreter, err
 
 	t := tools.NewTools(toolOpts...)
 	t.RegisterBuiltins()
+	t.Register("workflow_state", newWorkflowStateTool(interp))
 
 	// Connect MCP servers
 	if doc.Settings != nil && doc.Settings.MCP != nil && len(doc.Settings.MCP.Servers) > 0 {
@@ -171,19 +276,10 @@ func NewInterpreter(doc *Document, opts ...InterpreterOption) (*Interpreter, err
 		yamlAgents[name] = true
 	}
 
-	interp := &Interpreter{
-		doc:               doc,
-		orch:              orch,
-		agents:            make(map[string]*vega.Process),
-		tools:             t,
-		skillsLoader:      skillsLoader,
-		delegationConfigs: make(map[string]*DelegationDef),
-		yamlAgents:        yamlAgents,
-	}
-
-	for _, opt := range opts {
-		opt(interp)
-	}
+	interp.orch = orch
+	interp.tools = t
+	interp.skillsLoader = skillsLoader
+	interp.yamlAgents = yamlAgents
 
 	// Spawn agents upfront unless lazy spawn is enabled.
 	if !interp.lazySpawn {
@@ -197,10 +293,20 @@ func NewInterpreter(doc *Document, opts ...InterpreterOption) (*Interpreter, err
 	return interp, nil
 }
 
-// spawnAgent creates a Vega process for a DSL agent.
-func (i *Interpreter) spawnAgent(name string, def *Agent) error {
+// spawnAgent creates a Vega process for a DSL agent. extra options, such as
+// the spawn reason, are applied after those built from the definition.
+func (i *Interpreter) spawnAgent(name string, def *Agent, extra ...vega.SpawnOption) error {
+	def, err := resolveAgent(i.doc.Agents, name, def)
+	if err != nil {
+		return err
+	}
+
 	// Build the base system string, enriching with team section if needed.
+	// Placeholders in def.System stay unrendered here; templatePrompt
+	// renders them per call, so systemStr is kept as prefix + def.System +
+	// suffix.
 	systemStr := def.System
+	var knowledgePrefix string
 
 	if len(def.Team) > 0 {
 		// Store delegation config for this agent.
@@ -229,7 +335,9 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 				i.mu.RLock()
 				defer i.mu.RUnlock()
 				if def, ok := i.doc.Agents[proc.Agent.Name]; ok {
-					return def.Team
+					if def, err := resolveAgent(i.doc.Agents, proc.Agent.Name, def); err == nil {
+						return def.Team
+					}
 				}
 			}
 			return nil
@@ -239,6 +347,9 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 		descs := make(map[string]string, len(def.Team))
 		for _, member := range def.Team {
 			if memberDef, ok := i.doc.Agents[member]; ok {
+				if resolved, err := resolveAgent(i.doc.Agents, member, memberDef); err == nil {
+					memberDef = resolved
+				}
 				if first, _, ok := strings.Cut(strings.TrimSpace(memberDef.System), "\n"); ok {
 					descs[member] = first
 				} else {
@@ -255,7 +366,8 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 		knowledgeSection := i.resolveKnowledge(ctx, def.Knowledge)
 		cancel()
 		if knowledgeSection != "" {
-			systemStr = knowledgeSection + "\n\n" + systemStr
+			knowledgePrefix = knowledgeSection + "\n\n"
+			systemStr = knowledgePrefix + systemStr
 		}
 	}
 
@@ -303,7 +415,17 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 	}
 
 	// Build base system prompt
-	var systemPrompt vega.SystemPrompt = vega.StaticPrompt(systemStr)
+	var basePrompt vega.SystemPrompt = vega.StaticPrompt(systemStr)
+	if ContainsExpression(def.System) {
+		basePrompt = &templatePrompt{
+			interp:   i,
+			agent:    name,
+			prefix:   knowledgePrefix,
+			template: def.System,
+			suffix:   systemStr[len(knowledgePrefix)+len(def.System):],
+		}
+	}
+	systemPrompt := basePrompt
 
 	// Wrap with skills if configured
 	if def.Skills != nil {
@@ -332,7 +454,7 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 			if def.Skills.MaxActive > 0 {
 				opts = append(opts, vega.WithMaxActiveSkills(def.Skills.MaxActive))
 			}
-			systemPrompt = vega.NewSkillsPrompt(vega.StaticPrompt(systemStr), loader, opts...)
+			systemPrompt = vega.NewSkillsPrompt(basePrompt, loader, opts...)
 		}
 	}
 
@@ -362,12 +484,21 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 		FallbackModel: def.FallbackModel,
 		System:        systemPrompt,
 		Tools:         agentTools,
+		MaxHistory:    def.MaxHistory,
 	}
 
 	if def.Temperature != nil {
 		agent.Temperature = def.Temperature
 	}
 
+	if def.Account != "" {
+		backend, err := i.accountLLM(def.Account)
+		if err != nil {
+			return err
+		}
+		agent.LLM = backend
+	}
+
 	// Map DSL retry config to core retry policy
 	if def.Retry != nil {
 		bp := vega.BackoffExponential
@@ -414,17 +545,6 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 		}
 	}
 
-	// Handle extends (merge parent config)
-	if def.Extends != "" {
-		parent, ok := i.doc.Agents[def.Extends]
-		if ok {
-			if agent.Model == "" {
-				agent.Model = parent.Model
-			}
-			// Could merge other fields too
-		}
-	}
-
 	// Apply defaults from settings
 	if agent.Model == "" && i.doc.Settings != nil {
 		agent.Model = i.doc.Settings.DefaultModel
@@ -447,6 +567,16 @@ func (i *Interpreter) spawnAgent(name string, def *Agent) error {
 		}
 		opts = append(opts, vega.WithSupervision(sup))
 	}
+	budget := def.Budget
+	if budget == "" && i.doc.Settings != nil {
+		budget = i.doc.Settings.Budget
+	}
+	if budget != "" {
+		if usd, err := parseBudget(budget); err == nil {
+			opts = append(opts, vega.WithBudget(usd))
+		}
+	}
+	opts = append(opts, extra...)
 
 	// Spawn the process
 	proc, err := i.orch.Spawn(agent, opts...)
@@ -543,6 +673,8 @@ func (i *Interpreter) RunWorkflow(ctx context.Context, name string, inputs map[s
 
 	// Create execution context
 	execCtx := &ExecutionContext{
+		Workflow:  name,
+		RunID:     RunIDFromContext(ctx),
 		Inputs:    inputs,
 		Variables: make(map[string]any),
 		StartTime: time.Now(),
@@ -571,10 +703,7 @@ func (i *Interpreter) RunWorkflow(ctx context.Context, name string, inputs map[s
 			return i.evaluateExpression(step.Return, execCtx)
 		}
 
-		// Save result if step has save
-		if step.Save != "" && result != nil {
-			execCtx.Variables[step.Save] = result
-		}
+		storeStepResult(&step, result, execCtx)
 	}
 
 	// Evaluate output
@@ -582,24 +711,61 @@ func (i *Interpreter) RunWorkflow(ctx context.Context, name string, inputs map[s
 		return i.evaluateOutput(wf.Output, execCtx)
 	}
 
-	// Return last saved variable or nil
-	return execCtx.Variables["result"], nil
+	// Return the explicitly saved result, falling back to the last step's value
+	if v, ok := execCtx.Variables["result"]; ok {
+		return v, nil
+	}
+	return execCtx.Variables[lastResultVar], nil
+}
+
+// lastResultVar holds the value produced by the most recent step. It lets
+// linear pipelines chain steps via {{_last}} (or {{result}} when nothing was
+// explicitly saved as result) without a save on every step.
+const lastResultVar = "_last"
+
+// storeStepResult records a step's result in the execution context: under
+// the step's save name if it has one, and always as the implicit last result.
+func storeStepResult(step *Step, result any, execCtx *ExecutionContext) {
+	if result == nil {
+		return
+	}
+	if step.Save != "" {
+		execCtx.Variables[step.Save] = result
+	}
+	execCtx.Variables[lastResultVar] = result
 }
 
-// executeStep executes a single workflow step.
+// executeStep executes a single workflow step, reporting its progress to
+// any registered step observers.
 func (i *Interpreter) executeStep(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
 	// Check condition
 	if step.If != "" {
 		result, err := i.evaluateCondition(step.If, execCtx)
 		if err != nil {
-			return nil, fmt.Errorf("evaluate condition: %w", err)
+			err = fmt.Errorf("evaluate condition: %w", err)
+			i.emitStep(step, execCtx, StepFailed, nil, err)
+			return nil, err
 		}
 		if !result {
+			i.emitStep(step, execCtx, StepSkipped, nil, nil)
 			return nil, nil // Skip step
 		}
 	}
 
-	// Handle different step types
+	i.emitStep(step, execCtx, StepStarted, nil, nil)
+	execCtx.Depth++ // steps run by this one are nested
+	result, err := i.dispatchStep(ctx, step, execCtx)
+	execCtx.Depth--
+	if err != nil {
+		i.emitStep(step, execCtx, StepFailed, nil, err)
+		return nil, err
+	}
+	i.emitStep(step, execCtx, StepCompleted, result, nil)
+	return result, nil
+}
+
+// dispatchStep runs a step according to its type.
+func (i *Interpreter) dispatchStep(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
 	switch {
 	case step.Condition != "": // if/then/else
 		return i.executeConditional(ctx, step, execCtx)
@@ -613,6 +779,12 @@ func (i *Interpreter) executeStep(ctx context.Context, step *Step, execCtx *Exec
 	case step.ForEach != "":
 		return i.executeForEach(ctx, step, execCtx)
 
+	case step.Map != "":
+		return i.executeMap(ctx, step, execCtx)
+
+	case step.Filter != "":
+		return i.executeFilter(ctx, step, execCtx)
+
 	case step.Workflow != "":
 		return i.executeSubWorkflow(ctx, step, execCtx)
 
@@ -625,6 +797,9 @@ func (i *Interpreter) executeStep(ctx context.Context, step *Step, execCtx *Exec
 	case len(step.Try) > 0:
 		return i.executeTryCatch(ctx, step, execCtx)
 
+	case step.Decide != "":
+		return i.executeDecide(ctx, step, execCtx)
+
 	case step.Agent != "":
 		return i.executeAgentStep(ctx, step, execCtx)
 
@@ -635,8 +810,9 @@ func (i *Interpreter) executeStep(ctx context.Context, step *Step, execCtx *Exec
 
 // ensureAgent spawns an agent process on demand if it doesn't exist yet.
 // If the existing process has failed (e.g. due to context cancellation), it is
-// removed and a fresh process is spawned so callers don't get stuck.
-func (i *Interpreter) ensureAgent(name string) (*vega.Process, error) {
+// removed and a fresh process is spawned so callers don't get stuck. opts
+// apply only when a process is spawned.
+func (i *Interpreter) ensureAgent(name string, opts ...vega.SpawnOption) (*vega.Process, error) {
 	i.mu.RLock()
 	proc, ok := i.agents[name]
 	i.mu.RUnlock()
@@ -656,7 +832,7 @@ func (i *Interpreter) ensureAgent(name string) (*vega.Process, error) {
 		return nil, fmt.Errorf("agent '%s' not found", name)
 	}
 
-	if err := i.spawnAgent(name, agentDef); err != nil {
+	if err := i.spawnAgent(name, agentDef, opts...); err != nil {
 		return nil, fmt.Errorf("spawn agent %s: %w", name, err)
 	}
 
@@ -668,15 +844,34 @@ func (i *Interpreter) ensureAgent(name string) (*vega.Process, error) {
 
 // executeAgentStep sends a message to an agent.
 func (i *Interpreter) executeAgentStep(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
-	proc, err := i.ensureAgent(step.Agent)
+	// Interpolate the message
+	message, err := i.interpolate(step.Send, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("interpolate message: %w", err)
+	}
+
+	response, err := i.sendAgentStep(ctx, step, message, execCtx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Interpolate the message
-	message, err := i.interpolate(step.Send, execCtx)
+	// Parse response if format specified
+	if step.Format == "json" {
+		// TODO: Parse JSON response
+	}
+
+	return response, nil
+}
+
+// sendAgentStep sends message to the step's agent, applying the step's
+// timeout, context and model, and returns the response.
+func (i *Interpreter) sendAgentStep(ctx context.Context, step *Step, message string, execCtx *ExecutionContext) (string, error) {
+	proc, err := i.ensureAgent(step.Agent,
+		vega.WithSpawnKind(vega.SpawnWorkflow),
+		vega.WithSpawnDetail(execCtx.Workflow),
+	)
 	if err != nil {
-		return nil, fmt.Errorf("interpolate message: %w", err)
+		return "", err
 	}
 
 	// Apply timeout if specified
@@ -689,18 +884,145 @@ func (i *Interpreter) executeAgentStep(ctx context.Context, step *Step, execCtx
 		}
 	}
 
-	// Send message
-	response, err := proc.Send(ctx, message)
+	// Expose selected workflow variables as system context for this call
+	// only. The context carries it, bound to this agent's process, so steps
+	// sharing the agent and agents it delegates to don't see it.
+	if len(step.Context) > 0 {
+		ctx = vega.ContextWithSystemAddendum(ctx, proc.ID, i.formatStepContext(step.Context, execCtx))
+	}
+
+	// Let the agent's tools see the workflow it is running in.
+	ctx = ContextWithExecution(ctx, execCtx)
+
+	// Send message. A per-step model override goes through the stateless
+	// Query path so the one-off exchange stays out of the agent's history.
+	var response string
+	if step.Model != "" {
+		response, err = proc.Query(llm.ContextWithModel(ctx, step.Model), message)
+	} else if observers := i.stepOutputObservers(); len(observers) > 0 {
+		response, err = i.streamAgentStep(ctx, proc, message, step, execCtx, observers)
+	} else {
+		response, err = proc.Send(ctx, message)
+	}
 	if err != nil {
-		return nil, err
+		return "", err
 	}
+	i.emitTurnComplete(step.Agent, message, response)
+	return response, nil
+}
 
-	// Parse response if format specified
-	if step.Format == "json" {
-		// TODO: Parse JSON response
+// defaultDecideRetries is how many times a decide step re-asks an agent
+// whose answer is not one of the allowed outcomes.
+const defaultDecideRetries = 2
+
+// executeDecide asks the step's agent to pick one of the step's outcomes
+// and returns the outcome exactly as declared. An answer that doesn't match
+// an outcome, ignoring case, surrounding whitespace, quotes and trailing
+// punctuation, is rejected and the agent asked again, up to step.Retry
+// more times.
+func (i *Interpreter) executeDecide(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
+	question, err := i.interpolate(step.Send, execCtx)
+	if err != nil {
+		return nil, fmt.Errorf("interpolate message: %w", err)
 	}
 
-	return response, nil
+	choices := strings.Join(step.Outcomes, ", ")
+	instruction := fmt.Sprintf("Reply with exactly one of these outcomes and nothing else: %s", choices)
+	message := question + "\n\n" + instruction
+
+	retries := step.Retry
+	if retries <= 0 {
+		retries = defaultDecideRetries
+	}
+	var answer string
+	for attempt := 0; attempt <= retries; attempt++ {
+		answer, err = i.sendAgentStep(ctx, step, message, execCtx)
+		if err != nil {
+			return nil, err
+		}
+		if outcome, ok := matchOutcome(answer, step.Outcomes); ok {
+			return outcome, nil
+		}
+		// Repeat the question so a stateless (per-step model) agent still has it.
+		message = fmt.Sprintf("%q is not one of the allowed outcomes.\n\n%s\n\n%s", answer, question, instruction)
+	}
+	return nil, fmt.Errorf("agent '%s' answered %q, want one of: %s", step.Agent, truncateStr(answer, 200), choices)
+}
+
+// matchOutcome returns the outcome that answer names, as declared.
+func matchOutcome(answer string, outcomes []string) (string, bool) {
+	answer = strings.TrimSpace(answer)
+	answer = strings.Trim(answer, "\"'`*_")
+	answer = strings.TrimRight(answer, ".!")
+	answer = strings.TrimSpace(answer)
+	for _, outcome := range outcomes {
+		if strings.EqualFold(answer, outcome) {
+			return outcome, true
+		}
+	}
+	return "", false
+}
+
+// streamAgentStep sends message to proc with streaming, passing each text
+// delta to observers, and returns the complete response.
+func (i *Interpreter) streamAgentStep(ctx context.Context, proc *vega.Process, message string, step *Step, execCtx *ExecutionContext, observers []func(StepOutput)) (string, error) {
+	stream, err := proc.SendStreamRich(ctx, message)
+	if err != nil {
+		return "", err
+	}
+	for event := range stream.Events() {
+		if event.Type != vega.ChatEventTextDelta || event.Delta == "" {
+			continue
+		}
+		out := StepOutput{
+			RunID:    execCtx.RunID,
+			Workflow: execCtx.Workflow,
+			Index:    execCtx.CurrentStep,
+			Depth:    execCtx.Depth,
+			Agent:    step.Agent,
+			Delta:    event.Delta,
+		}
+		for _, fn := range observers {
+			fn(out)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", err
+	}
+	return stream.Response(), nil
+}
+
+// formatStepContext renders the named workflow variables as a system prompt
+// section. Names that don't resolve to a variable or input are skipped.
+func (i *Interpreter) formatStepContext(names []string, execCtx *ExecutionContext) string {
+	var b strings.Builder
+	for _, name := range names {
+		root, _, _ := strings.Cut(name, ".")
+		if _, ok := execCtx.Variables[root]; !ok {
+			if _, ok := execCtx.Inputs[root]; !ok {
+				continue
+			}
+		}
+		val, err := i.evaluateExpression(name, execCtx)
+		if err != nil {
+			continue
+		}
+		switch v := val.(type) {
+		case string:
+			fmt.Fprintf(&b, "- %s: %s\n", name, v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				fmt.Fprintf(&b, "- %s: %v\n", name, v)
+			} else {
+				fmt.Fprintf(&b, "- %s: %s\n", name, data)
+			}
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "## Workflow context\n" + strings.TrimRight(b.String(), "\n")
 }
 
 // executeConditional handles if/then/else.
@@ -723,53 +1045,88 @@ func (i *Interpreter) executeConditional(ctx context.Context, step *Step, execCt
 		if err != nil {
 			return nil, err
 		}
-		if s.Save != "" && lastResult != nil {
-			execCtx.Variables[s.Save] = lastResult
-		}
+		storeStepResult(&s, lastResult, execCtx)
 	}
 
 	return lastResult, nil
 }
 
-// executeParallel runs steps in parallel.
+// executeParallel runs steps in parallel. Each branch works on its own
+// copy of the variables; a branch's save is written back to execCtx when
+// it finishes. By default the first branch error fails the step. With
+// on_error: partial the step returns every branch's result, nil for the
+// ones that failed, and lists the failures in the "errors" variable.
 func (i *Interpreter) executeParallel(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
 	var wg sync.WaitGroup
 	results := make([]any, len(step.Parallel))
-	errors := make([]error, len(step.Parallel))
+	errs := make([]error, len(step.Parallel))
+
+	execCtx.mu.Lock()
+	vars := make([]map[string]any, len(step.Parallel))
+	for idx := range step.Parallel {
+		vars[idx] = copyMap(execCtx.Variables)
+	}
+	execCtx.mu.Unlock()
 
 	for idx, s := range step.Parallel {
 		wg.Add(1)
 		go func(idx int, s Step) {
 			defer wg.Done()
 
-			// Create a copy of execCtx for this goroutine
+			// Inputs are read-only, so branches can share them
 			localCtx := &ExecutionContext{
-				Inputs:    execCtx.Inputs,
-				Variables: copyMap(execCtx.Variables),
+				Workflow:    execCtx.Workflow,
+				RunID:       execCtx.RunID,
+				Inputs:      execCtx.Inputs,
+				Variables:   vars[idx],
+				CurrentStep: execCtx.CurrentStep,
+				Depth:       execCtx.Depth,
 			}
 
 			result, err := i.executeStep(ctx, &s, localCtx)
 			results[idx] = result
-			errors[idx] = err
+			errs[idx] = err
 
 			// Save result to shared context
-			if s.Save != "" && result != nil {
-				i.mu.Lock()
+			if err == nil && s.Save != "" && result != nil {
+				execCtx.mu.Lock()
 				execCtx.Variables[s.Save] = result
-				i.mu.Unlock()
+				execCtx.mu.Unlock()
 			}
 		}(idx, s)
 	}
 
 	wg.Wait()
 
-	// Check for errors
-	for _, err := range errors {
-		if err != nil {
-			return nil, err
+	if step.OnError != "partial" {
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
 		}
+		return results, nil
 	}
 
+	var failures []any
+	for idx, err := range errs {
+		if err == nil {
+			continue
+		}
+		branch := step.Parallel[idx]
+		failure := map[string]any{"index": idx, "error": err.Error()}
+		if branch.Agent != "" {
+			failure["agent"] = branch.Agent
+		}
+		if branch.Save != "" {
+			failure["save"] = branch.Save
+		}
+		failures = append(failures, failure)
+	}
+	if failures != nil {
+		execCtx.Variables["errors"] = failures
+	} else {
+		delete(execCtx.Variables, "errors")
+	}
 	return results, nil
 }
 
@@ -795,9 +1152,7 @@ func (i *Interpreter) executeRepeat(ctx context.Context, step *Step, execCtx *Ex
 			if err != nil {
 				return nil, err
 			}
-			if s.Save != "" && lastResult != nil {
-				execCtx.Variables[s.Save] = lastResult
-			}
+			storeStepResult(&s, lastResult, execCtx)
 		}
 
 		// Check until condition
@@ -816,12 +1171,75 @@ func (i *Interpreter) executeRepeat(ctx context.Context, step *Step, execCtx *Ex
 	return lastResult, nil
 }
 
-// executeForEach handles for-each loops.
+// executeForEach handles for-each loops. Arrays iterate their elements,
+// maps iterate their entries in key order (exposing loop.key/loop.value),
+// and strings iterate their non-blank lines. The result is the list of
+// items iterated.
 func (i *Interpreter) executeForEach(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
+	var results []any
+	err := i.iterate(ctx, step.ForEach, nil, execCtx, func(item, _ any) error {
+		results = append(results, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// executeMap transforms each item of a collection through the step body and
+// collects the last body step's value per item.
+func (i *Interpreter) executeMap(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
+	if len(step.Steps) == 0 {
+		return nil, fmt.Errorf("map requires steps")
+	}
+	results := []any{}
+	err := i.iterate(ctx, step.Map, step.Steps, execCtx, func(_, bodyResult any) error {
+		results = append(results, bodyResult)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// executeFilter keeps the items of a collection for which the where
+// condition holds, or, without a where, for which the last body step's value
+// is truthy (see isTruthy).
+func (i *Interpreter) executeFilter(ctx context.Context, step *Step, execCtx *ExecutionContext) (any, error) {
+	if step.Where == "" && len(step.Steps) == 0 {
+		return nil, fmt.Errorf("filter requires where or steps")
+	}
+	results := []any{}
+	err := i.iterate(ctx, step.Filter, step.Steps, execCtx, func(item, bodyResult any) error {
+		keep := isTruthy(bodyResult)
+		if step.Where != "" {
+			var err error
+			keep, err = i.evaluateCondition(step.Where, execCtx)
+			if err != nil {
+				return err
+			}
+		}
+		if keep {
+			results = append(results, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// iterate runs body once per item of the collection named by an
+// "item in items" expression, binding the item variable and loop state,
+// and calls visit with the item and the last body step's value.
+func (i *Interpreter) iterate(ctx context.Context, loopExpr string, body []Step, execCtx *ExecutionContext, visit func(item, bodyResult any) error) error {
 	// Parse "item in items"
-	parts := strings.SplitN(step.ForEach, " in ", 2)
+	parts := strings.SplitN(loopExpr, " in ", 2)
 	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid for syntax: %s", step.ForEach)
+		return fmt.Errorf("invalid for syntax: %s", loopExpr)
 	}
 
 	itemVar := strings.TrimSpace(parts[0])
@@ -830,32 +1248,98 @@ func (i *Interpreter) executeForEach(ctx context.Context, step *Step, execCtx *E
 	// Get collection
 	collection, err := i.evaluateExpression(collectionExpr, execCtx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	items, ok := collection.([]any)
-	if !ok {
-		return nil, fmt.Errorf("for-each requires array, got %T", collection)
+	keys, items, err := loopEntries(collection)
+	if err != nil {
+		return err
 	}
 
-	var results []any
+	defer func() { execCtx.LoopState = nil }()
 	for idx, item := range items {
 		execCtx.LoopState = &LoopState{
 			Index: idx,
 			Count: idx + 1,
+			Key:   keys[idx],
 			Item:  item,
 			First: idx == 0,
 			Last:  idx == len(items)-1,
 		}
 		execCtx.Variables[itemVar] = item
 
-		// Execute nested steps (from Raw)
-		// TODO: Parse nested steps from Raw
-		results = append(results, item)
+		var lastResult any
+		for _, s := range body {
+			lastResult, err = i.executeStep(ctx, &s, execCtx)
+			if err != nil {
+				return err
+			}
+			storeStepResult(&s, lastResult, execCtx)
+		}
+		if err := visit(item, lastResult); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	execCtx.LoopState = nil
-	return results, nil
+// isTruthy interprets a step result as a boolean. Strings are truthy unless
+// blank or one of "false", "no", "0" (case-insensitive, ignoring trailing
+// punctuation) so agents can act as yes/no deciders.
+func isTruthy(val any) bool {
+	switch v := val.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		switch strings.ToLower(strings.TrimRight(strings.TrimSpace(v), ".!")) {
+		case "", "false", "no", "0":
+			return false
+		}
+		return true
+	default:
+		return true
+	}
+}
+
+// loopEntries flattens a for-each collection into parallel key and item
+// slices. See executeForEach for the supported collection types.
+func loopEntries(collection any) ([]any, []any, error) {
+	var keys, items []any
+	switch c := collection.(type) {
+	case []any:
+		for idx, item := range c {
+			keys = append(keys, idx)
+			items = append(items, item)
+		}
+	case map[string]any:
+		names := make([]string, 0, len(c))
+		for k := range c {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			keys = append(keys, k)
+			items = append(items, c[k])
+		}
+	case string:
+		for _, line := range strings.Split(c, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			keys = append(keys, len(items))
+			items = append(items, line)
+		}
+	default:
+		return nil, nil, fmt.Errorf("for-each requires array, map, or string, got %T", collection)
+	}
+	return keys, items, nil
 }
 
 // executeSubWorkflow calls another workflow.
@@ -906,9 +1390,7 @@ func (i *Interpreter) executeTryCatch(ctx context.Context, step *Step, execCtx *
 			tryErr = err
 			break
 		}
-		if s.Save != "" && lastResult != nil {
-			execCtx.Variables[s.Save] = lastResult
-		}
+		storeStepResult(&s, lastResult, execCtx)
 	}
 
 	// If error, execute catch
@@ -920,9 +1402,7 @@ func (i *Interpreter) executeTryCatch(ctx context.Context, step *Step, execCtx *
 			if err != nil {
 				return nil, err // Error in catch
 			}
-			if s.Save != "" && lastResult != nil {
-				execCtx.Variables[s.Save] = lastResult
-			}
+			storeStepResult(&s, lastResult, execCtx)
 		}
 	}
 
@@ -977,6 +1457,13 @@ func (i *Interpreter) evaluateExpression(expr string, execCtx *ExecutionContext)
 		return val, nil
 	}
 
+	// Without an explicit save, "result" refers to the previous step's value
+	if expr == "result" {
+		if val, ok := execCtx.Variables[lastResultVar]; ok {
+			return val, nil
+		}
+	}
+
 	// Handle loop state
 	if execCtx.LoopState != nil {
 		switch expr {
@@ -988,6 +1475,10 @@ func (i *Interpreter) evaluateExpression(expr string, execCtx *ExecutionContext)
 			return execCtx.LoopState.First, nil
 		case "loop.last":
 			return execCtx.LoopState.Last, nil
+		case "loop.key":
+			return execCtx.LoopState.Key, nil
+		case "loop.value":
+			return execCtx.LoopState.Item, nil
 		case "item":
 			return execCtx.LoopState.Item, nil
 		}
@@ -1084,6 +1575,13 @@ func (i *Interpreter) evaluateCondition(expr string, execCtx *ExecutionContext)
 	}
 }
 
+// knownFilters are the filters applyFilter implements; Document.Validate
+// reports any other.
+var knownFilters = map[string]bool{
+	"upper": true, "lower": true, "trim": true, "default": true,
+	"lines": true, "words": true, "truncate": true, "join": true,
+}
+
 // applyFilter applies a filter function to a value.
 func (i *Interpreter) applyFilter(val any, filter string, execCtx *ExecutionContext) (any, error) {
 	// Parse filter name and args
@@ -1176,6 +1674,18 @@ func (i *Interpreter) Shutdown() {
 	i.orch.Shutdown(ctx)
 }
 
+// Drain waits for in-flight agent calls to finish, refusing new ones, then
+// stops all agents and disconnects MCP servers. See Orchestrator.Drain.
+func (i *Interpreter) Drain(ctx context.Context) error {
+	// Let in-flight calls finish before their tools go away.
+	err := i.orch.Drain(ctx)
+
+	if i.tools != nil {
+		i.tools.DisconnectMCP()
+	}
+	return err
+}
+
 // Execute runs a workflow by name (alias for RunWorkflow).
 func (i *Interpreter) Execute(ctx context.Context, name string, inputs map[string]any) (any, error) {
 	return i.RunWorkflow(ctx, name, inputs)
@@ -1201,6 +1711,21 @@ func (i *Interpreter) SkillsLoader() *skills.Loader {
 	return i.skillsLoader
 }
 
+// AgentDef returns the definition of agent name with the agents it extends
+// merged in, and false if no such agent is defined.
+func (i *Interpreter) AgentDef(name string) (*Agent, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	def, ok := i.doc.Agents[name]
+	if !ok {
+		return nil, false
+	}
+	if resolved, err := resolveAgent(i.doc.Agents, name, def); err == nil {
+		def = resolved
+	}
+	return def, true
+}
+
 // Agents returns a copy of the active agent processes map.
 func (i *Interpreter) Agents() map[string]*vega.Process {
 	i.mu.RLock()
@@ -1256,19 +1781,20 @@ func (i *Interpreter) RemoveAgent(name string) error {
 }
 
 // ResetAgent kills the agent process and removes it from the active map,
-// but preserves the agent definition so it respawns fresh on next use.
-func (i *Interpreter) ResetAgent(name string) error {
+// but preserves the agent definition so it respawns fresh on next use. It
+// reports whether there was a process to reset.
+func (i *Interpreter) ResetAgent(name string) (bool, error) {
 	i.mu.Lock()
 	proc, ok := i.agents[name]
 	if !ok {
 		i.mu.Unlock()
 		// Agent not spawned yet — nothing to reset.
-		return nil
+		return false, nil
 	}
 	delete(i.agents, name)
 	i.mu.Unlock()
 
-	return i.orch.Kill(proc.ID)
+	return true, i.orch.Kill(proc.ID)
 }
 
 // RemoveComposedAgents kills and removes all agents that were NOT defined in
@@ -1304,12 +1830,27 @@ func (i *Interpreter) EnsureAgent(name string) (*vega.Process, error) {
 	return i.ensureAgent(name)
 }
 
-// SendToAgent sends a message to a specific agent and returns the response.
+// callerSpawnOpts marks an agent spawned on behalf of the process in ctx,
+// such as through the delegate tool, as delegated work. Spawns with no
+// calling process keep the default reason.
+func callerSpawnOpts(ctx context.Context) []vega.SpawnOption {
+	caller := vega.ProcessFromContext(ctx)
+	if caller == nil || caller.Agent == nil {
+		return nil
+	}
+	return []vega.SpawnOption{
+		vega.WithSpawnKind(vega.SpawnDelegate),
+		vega.WithSpawnDetail("from " + caller.Agent.Name),
+	}
+}
+
+// SendToAgent sends a message to a specific agent and returns the response,
+// after any response post-processors have run.
 // If the calling context carries an event sink (from a streaming parent),
 // SendToAgent uses streaming and forwards nested tool_start/tool_end events
 // to the parent sink so the UI can display sub-agent activity in real time.
 func (i *Interpreter) SendToAgent(ctx context.Context, agentName string, message string) (string, error) {
-	proc, err := i.ensureAgent(agentName)
+	proc, err := i.ensureAgent(agentName, callerSpawnOpts(ctx)...)
 	if err != nil {
 		return "", err
 	}
@@ -1337,7 +1878,8 @@ func (i *Interpreter) SendToAgent(ctx context.Context, agentName string, message
 		for event := range stream.Events() {
 			// Only forward tool lifecycle events — skip text_delta and done
 			// to avoid corrupting the parent's response text.
-			if event.Type == vega.ChatEventToolStart || event.Type == vega.ChatEventToolEnd {
+			switch event.Type {
+			case vega.ChatEventToolCall, vega.ChatEventToolStart, vega.ChatEventToolResult, vega.ChatEventToolEnd:
 				// Build the nested agent chain.
 				if event.NestedAgent == "" {
 					event.NestedAgent = agentName
@@ -1351,7 +1893,10 @@ func (i *Interpreter) SendToAgent(ctx context.Context, agentName string, message
 		if err := stream.Err(); err != nil {
 			return "", err
 		}
-		resp := stream.Response()
+		resp, err := i.PostProcessResponse(ctx, agentName, stream.Response())
+		if err != nil {
+			return "", err
+		}
 
 		if i.delegationObserver != nil {
 			callerName := ""
@@ -1362,6 +1907,7 @@ func (i *Interpreter) SendToAgent(ctx context.Context, agentName string, message
 				go i.delegationObserver(context.Background(), callerName, agentName, message, resp)
 			}
 		}
+		i.emitTurnComplete(agentName, message, resp)
 
 		return resp, nil
 	}
@@ -1370,6 +1916,9 @@ func (i *Interpreter) SendToAgent(ctx context.Context, agentName string, message
 	if err != nil {
 		return "", err
 	}
+	if response, err = i.PostProcessResponse(ctx, agentName, response); err != nil {
+		return "", err
+	}
 
 	if i.delegationObserver != nil {
 		callerName := ""
@@ -1380,6 +1929,7 @@ func (i *Interpreter) SendToAgent(ctx context.Context, agentName string, message
 			go i.delegationObserver(context.Background(), callerName, agentName, message, response)
 		}
 	}
+	i.emitTurnComplete(agentName, message, response)
 
 	return response, nil
 }
@@ -1417,7 +1967,7 @@ func (i *Interpreter) SetChannelBackend(b ChannelBackend, onPost func(channelNam
 // Returns immediately with a confirmation message.
 func (i *Interpreter) DispatchToAgent(ctx context.Context, agentName string, message string) (string, error) {
 	// Validate agent exists synchronously so callers get immediate errors.
-	if _, err := i.ensureAgent(agentName); err != nil {
+	if _, err := i.ensureAgent(agentName, callerSpawnOpts(ctx)...); err != nil {
 		return "", err
 	}
 
@@ -1504,59 +2054,73 @@ func truncateStr(s string, max int) string {
 
 // StreamToAgent sends a message to a specific agent and returns a ChatStream
 // with structured events for real-time streaming and tool call visibility.
+// Response post-processors are not applied; run PostProcessResponse on the
+// final response. OnTurnComplete callbacks likewise get the response as the
+// model produced it.
 func (i *Interpreter) StreamToAgent(ctx context.Context, agentName string, message string) (*vega.ChatStream, error) {
-	proc, err := i.ensureAgent(agentName)
+	proc, err := i.ensureAgent(agentName, callerSpawnOpts(ctx)...)
 	if err != nil {
 		return nil, err
 	}
-	return proc.SendStreamRich(ctx, message)
+	stream, err := proc.SendStreamRich(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+	i.mu.RLock()
+	observed := len(i.turnObservers) > 0
+	i.mu.RUnlock()
+	if observed {
+		go func() {
+			// Err and Response wait for the stream to finish.
+			if stream.Err() == nil {
+				i.emitTurnComplete(agentName, message, stream.Response())
+			}
+		}()
+	}
+	return stream, nil
 }
 
-// resolveKnowledge fetches all knowledge URIs and returns a formatted section.
-func (i *Interpreter) resolveKnowledge(ctx context.Context, uris []string) string {
-	var builder strings.Builder
-	builder.WriteString("# Knowledge\n")
-	any := false
-
-	for _, uri := range uris {
-		content, err := i.fetchKnowledgeItem(ctx, uri)
-		if err != nil {
-			continue
-		}
-		any = true
-		builder.WriteString("\n## ")
-		builder.WriteString(uri)
-		builder.WriteString("\n```\n")
-		builder.WriteString(content)
-		builder.WriteString("\n```\n")
+// accountLLM returns the backend for a settings.accounts entry, creating
+// it on first use so agents sharing an account share a client.
+func (i *Interpreter) accountLLM(name string) (llm.LLM, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if backend, ok := i.accountLLMs[name]; ok {
+		return backend, nil
 	}
 
-	if !any {
-		return ""
+	var acct *AccountDef
+	if i.doc.Settings != nil {
+		acct = i.doc.Settings.Accounts[name]
+	}
+	if acct == nil {
+		return nil, fmt.Errorf("unknown account '%s'", name)
 	}
-	return builder.String()
-}
 
-// fetchKnowledgeItem fetches a single knowledge resource.
-// Routes file:// URIs to os.ReadFile. Other schemes are treated as MCP resource
-// URIs where the scheme identifies the MCP server name.
-func (i *Interpreter) fetchKnowledgeItem(ctx context.Context, uri string) (string, error) {
-	if strings.HasPrefix(uri, "file://") {
-		path := strings.TrimPrefix(uri, "file://")
-		data, err := os.ReadFile(path)
-		if err != nil {
-			return "", fmt.Errorf("read knowledge file %s: %w", path, err)
+	key := os.ExpandEnv(acct.APIKey)
+	var backend llm.LLM
+	switch acct.Provider {
+	case "", "anthropic":
+		opts := []llm.AnthropicOption{llm.WithAPIKey(key)}
+		if acct.BaseURL != "" {
+			opts = append(opts, llm.WithBaseURL(acct.BaseURL))
+		}
+		backend = llm.NewAnthropic(opts...)
+	case "openai":
+		opts := []llm.OpenAIOption{llm.WithOpenAIAPIKey(key)}
+		if acct.BaseURL != "" {
+			opts = append(opts, llm.WithOpenAIBaseURL(acct.BaseURL))
 		}
-		return string(data), nil
+		backend = llm.NewOpenAI(opts...)
+	default:
+		return nil, fmt.Errorf("account '%s': unknown provider '%s'", name, acct.Provider)
 	}
 
-	// Parse scheme as MCP server name: "postgres://public/users" -> server=postgres, uri=public/users
-	if idx := strings.Index(uri, "://"); idx > 0 {
-		serverName := uri[:idx]
-		return i.tools.ReadMCPResource(ctx, serverName, uri)
+	if i.accountLLMs == nil {
+		i.accountLLMs = make(map[string]llm.LLM)
 	}
-
-	return "", fmt.Errorf("unsupported knowledge URI scheme: %s", uri)
+	i.accountLLMs[name] = backend
+	return backend, nil
 }
 
 // expandEnvVars expands $VAR and ${VAR} references in environment variable values.
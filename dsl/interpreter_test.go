@@ -2,8 +2,18 @@ package dsl
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/llm"
+	"github.com/everydev1618/govega/tools"
 )
 
 func TestExecutionContext(t *testing.T) {
@@ -323,3 +333,1370 @@ func TestWorkflowOutput(t *testing.T) {
 		})
 	}
 }
+
+// echoLLM replies with "echo: <last user message>" and records every request.
+type echoLLM struct {
+	mu       sync.Mutex
+	requests [][]llm.Message
+	models   []string // per-request model override, "" for the default
+}
+
+func (e *echoLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	e.mu.Lock()
+	e.requests = append(e.requests, append([]llm.Message(nil), messages...))
+	e.models = append(e.models, llm.ModelFromContext(ctx))
+	e.mu.Unlock()
+
+	var last string
+	for idx := len(messages) - 1; idx >= 0; idx-- {
+		if messages[idx].Role == llm.RoleUser {
+			last = messages[idx].Content
+			break
+		}
+	}
+	return &llm.LLMResponse{Content: "echo: " + last}, nil
+}
+
+func (e *echoLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	resp, _ := e.Generate(ctx, messages, tools)
+	ch := make(chan llm.StreamEvent, 1)
+	ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: resp.Content}
+	close(ch)
+	return ch, nil
+}
+
+func (e *echoLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+// lastRequest returns the messages of the most recent request.
+func (e *echoLLM) lastRequest() []llm.Message {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.requests) == 0 {
+		return nil
+	}
+	return e.requests[len(e.requests)-1]
+}
+
+func TestSpawnReasons(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  lead:
+    model: test-model
+    system: You lead.
+  worker:
+    model: test-model
+    system: You work.
+  analyst:
+    model: test-model
+    system: You analyze.
+workflows:
+  review:
+    steps:
+      - analyst:
+          send: "look"
+`)
+	interp, err := NewInterpreter(doc, WithLLM(&echoLLM{}), WithLazySpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	lead, err := interp.EnsureAgent("lead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lead.SpawnReason != vega.SpawnUser {
+		t.Errorf("lead reason = %q, want %q", lead.SpawnReason, vega.SpawnUser)
+	}
+
+	ctx := vega.ContextWithProcess(context.Background(), lead)
+	if _, err := interp.SendToAgent(ctx, "worker", "help"); err != nil {
+		t.Fatal(err)
+	}
+	worker, _ := interp.EnsureAgent("worker")
+	if worker.SpawnReason != vega.SpawnDelegate || worker.SpawnDetail != "from lead" {
+		t.Errorf("worker reason = %q (%q), want %q from lead", worker.SpawnReason, worker.SpawnDetail, vega.SpawnDelegate)
+	}
+
+	if _, err := interp.RunWorkflow(context.Background(), "review", map[string]any{}); err != nil {
+		t.Fatal(err)
+	}
+	analyst, _ := interp.EnsureAgent("analyst")
+	if analyst.SpawnReason != vega.SpawnWorkflow || analyst.SpawnDetail != "review" {
+		t.Errorf("analyst reason = %q (%q), want %q for review", analyst.SpawnReason, analyst.SpawnDetail, vega.SpawnWorkflow)
+	}
+}
+
+func TestAgentToolAllowlist(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  reader:
+    model: test-model
+    system: You read.
+    tools: [read_file]
+  anything:
+    model: test-model
+    system: You do anything.
+`)
+	interp, err := NewInterpreter(doc, WithLLM(&echoLLM{}), WithLazySpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	reader, err := interp.EnsureAgent("reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, schema := range reader.Agent.Tools.Schema() {
+		if schema.Name == "exec" {
+			t.Error("reader's schema offers exec")
+		}
+	}
+	if _, err := reader.Agent.Tools.Execute(context.Background(), "exec", map[string]any{"command": "echo hi"}); !errors.Is(err, tools.ErrToolNotPermitted) {
+		t.Errorf("reader exec error = %v, want ErrToolNotPermitted", err)
+	}
+
+	// No tools list means every tool.
+	anything, err := interp.EnsureAgent("anything")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(anything.Agent.Tools.Schema(), func(s llm.ToolSchema) bool { return s.Name == "exec" }) {
+		t.Error("unrestricted agent's schema is missing exec")
+	}
+}
+
+func TestRunWorkflowImplicitPreviousResult(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  chain:
+    steps:
+      - writer:
+          send: "draft"
+      - writer:
+          send: "refine {{result}}"
+      - writer:
+          send: "polish {{_last}}"
+          save: final
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	out, err := interp.RunWorkflow(context.Background(), "chain", map[string]any{})
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	want := "echo: polish echo: refine echo: draft"
+	if out != want {
+		t.Errorf("RunWorkflow() = %q, want %q", out, want)
+	}
+}
+
+func TestRunWorkflowExplicitResultSaveWins(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  chain:
+    steps:
+      - writer:
+          send: "first"
+          save: result
+      - writer:
+          send: "second"
+          save: other
+      - writer:
+          send: "saw {{result}}"
+          save: final
+    output: "{{final}}"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	out, err := interp.RunWorkflow(context.Background(), "chain", map[string]any{})
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+	if !strings.HasSuffix(out.(string), "saw echo: first") {
+		t.Errorf("explicit save should take precedence, got %q", out)
+	}
+}
+
+func TestSystemPromptTemplateRendering(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  assistant:
+    model: test-model
+    system: "You support {{tenant | upper}} on {{date}}. Keep {{unknown}} as-is."
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{}, WithPromptVars(map[string]any{"tenant": "acme"}))
+	defer interp.Shutdown()
+
+	proc := interp.Agents()["assistant"]
+	if proc == nil {
+		t.Fatal("assistant should be spawned")
+	}
+
+	prompt := proc.Agent.System.Prompt()
+	want := "You support ACME on " + time.Now().Format("2006-01-02") + "."
+	if !strings.Contains(prompt, want) {
+		t.Errorf("prompt should contain %q, got %q", want, prompt)
+	}
+	if !strings.Contains(prompt, "Keep {{unknown}} as-is.") {
+		t.Errorf("unresolved placeholders should be preserved, got %q", prompt)
+	}
+}
+
+func TestSystemPromptTemplateRendersPerRun(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  assistant:
+    model: test-model
+    system: "You support {{tenant}} ({{tier}})."
+workflows:
+  support:
+    inputs:
+      tenant:
+        type: string
+    steps:
+      - set:
+          tier: gold
+      - assistant: "hello"
+`)
+	backend := &echoLLM{}
+	interp := newTestInterpreterWithLLM(t, doc, backend, WithPromptVars(map[string]any{"tenant": "nobody", "tier": "free"}))
+	defer interp.Shutdown()
+
+	for _, tenant := range []string{"Acme", "Globex"} {
+		if _, err := interp.RunWorkflow(context.Background(), "support", map[string]any{"tenant": tenant}); err != nil {
+			t.Fatalf("RunWorkflow: %v", err)
+		}
+	}
+	interp.SetPromptVar("tier", "silver")
+	if _, err := interp.Agents()["assistant"].Send(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	want := []string{"You support Acme (gold).", "You support Globex (gold).", "You support nobody (silver)."}
+	if len(backend.requests) != len(want) {
+		t.Fatalf("got %d LLM requests, want %d", len(backend.requests), len(want))
+	}
+	for idx, w := range want {
+		if got := backend.requests[idx][0].Content; !strings.HasPrefix(got, w) {
+			t.Errorf("request %d system prompt = %q, want prefix %q", idx, got, w)
+		}
+	}
+}
+
+func TestAgentStepContextInjection(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  brief:
+    inputs:
+      tenant:
+        type: string
+    steps:
+      - set:
+          tags: [a, b]
+      - writer:
+          send: "write the intro"
+          context: [tenant, tags, missing]
+      - writer:
+          send: "write the outro"
+      - writer:
+          send: "write the footer"
+          context: [missing]
+`)
+	backend := &echoLLM{}
+	interp := newTestInterpreterWithLLM(t, doc, backend)
+	defer interp.Shutdown()
+
+	proc := interp.Agents()["writer"]
+	proc.SetExtraSystem("memory layer")
+
+	if _, err := interp.RunWorkflow(context.Background(), "brief", map[string]any{"tenant": "Acme"}); err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	backend.mu.Lock()
+	requests := backend.requests
+	backend.mu.Unlock()
+	if len(requests) != 3 {
+		t.Fatalf("expected 3 LLM requests, got %d", len(requests))
+	}
+
+	first := requests[0][0]
+	if first.Role != llm.RoleSystem {
+		t.Fatalf("first message should be system, got %s", first.Role)
+	}
+	for _, want := range []string{"memory layer", "## Workflow context", "- tenant: Acme", `- tags: ["a","b"]`} {
+		if !strings.Contains(first.Content, want) {
+			t.Errorf("step system prompt should contain %q, got %q", want, first.Content)
+		}
+	}
+	if strings.Contains(first.Content, "missing") {
+		t.Error("unresolved context names should be skipped")
+	}
+
+	second := requests[1][0]
+	if strings.Contains(second.Content, "Workflow context") {
+		t.Error("injected context should be removed after the step")
+	}
+	if proc.ExtraSystem() != "memory layer" {
+		t.Errorf("ExtraSystem() = %q, step context should not touch the process", proc.ExtraSystem())
+	}
+
+	third := requests[2][0]
+	if strings.Contains(third.Content, "Workflow context") {
+		t.Errorf("no header expected when no context names resolve, got %q", third.Content)
+	}
+}
+
+// delegatingLLM has the lead delegate its task to the helper and records
+// the system prompt of every call.
+type delegatingLLM struct {
+	mu      sync.Mutex
+	systems []string
+}
+
+func (l *delegatingLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	l.mu.Lock()
+	l.systems = append(l.systems, messages[0].Content)
+	l.mu.Unlock()
+	last := messages[len(messages)-1].Content
+	switch {
+	case strings.HasPrefix(last, "<tool_result"):
+		return &llm.LLMResponse{Content: "delegated"}, nil
+	case strings.HasPrefix(messages[0].Content, "You lead."):
+		return &llm.LLMResponse{ToolCalls: []llm.ToolCall{{ID: "t1", Name: "delegate", Arguments: map[string]any{
+			"agent": "helper", "message": "do the work",
+		}}}}, nil
+	default:
+		return &llm.LLMResponse{Content: "done"}, nil
+	}
+}
+
+func (l *delegatingLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	resp, _ := l.Generate(ctx, messages, tools)
+	ch := make(chan llm.StreamEvent, 1)
+	ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: resp.Content}
+	close(ch)
+	return ch, nil
+}
+
+func (l *delegatingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestAgentStepContextNotDelegated(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  lead:
+    model: test-model
+    system: You lead.
+    team: [helper]
+  helper:
+    model: test-model
+    system: You help.
+workflows:
+  brief:
+    inputs:
+      tenant:
+        type: string
+    steps:
+      - lead:
+          send: "get it done"
+          context: [tenant]
+`)
+	backend := &delegatingLLM{}
+	interp := newTestInterpreterWithLLM(t, doc, backend)
+	defer interp.Shutdown()
+
+	if _, err := interp.RunWorkflow(context.Background(), "brief", map[string]any{"tenant": "Acme"}); err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	var sawHelper bool
+	for _, system := range backend.systems {
+		isLead := strings.HasPrefix(system, "You lead.")
+		sawHelper = sawHelper || !isLead
+		if has := strings.Contains(system, "- tenant: Acme"); has != isLead {
+			t.Errorf("system prompt %q: workflow context present = %v, want it only for the lead", system, has)
+		}
+	}
+	if !sawHelper {
+		t.Fatalf("helper was never called; systems = %q", backend.systems)
+	}
+}
+
+func TestSettingsBudgetIsAgentDefault(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+settings:
+  budget: "$2.00"
+agents:
+  writer:
+    model: test-model
+    system: You write.
+  editor:
+    model: test-model
+    system: You edit.
+    budget: "$0.50"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	for name, want := range map[string]float64{"writer": 2, "editor": 0.5} {
+		budget := interp.Agents()[name].Agent.Budget
+		if budget == nil || budget.Limit != want {
+			t.Errorf("%s budget = %+v, want limit %v", name, budget, want)
+		}
+	}
+}
+
+func TestForEachOverMap(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  loop:
+    steps:
+      - set:
+          prices:
+            banana: 2
+            apple: 1
+      - for: fruit in prices
+        save: out
+      - map: fruit in prices
+        steps:
+          - writer: "{{loop.key}}={{loop.value}} ({{loop.index}})"
+        save: lines
+    output: "{{out | join:;}} / {{lines | join:;}}"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	out, err := interp.RunWorkflow(context.Background(), "loop", map[string]any{})
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	want := "1;2 / echo: apple=1 (0);echo: banana=2 (1)"
+	if out != want {
+		t.Errorf("RunWorkflow() = %q, want %q", out, want)
+	}
+}
+
+func TestForEachOverMultilineString(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  loop:
+    inputs:
+      todo:
+        type: string
+    steps:
+      - for: line in todo
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	out, err := interp.RunWorkflow(context.Background(), "loop", map[string]any{
+		"todo": "first\n\n  second\r\nthird\n",
+	})
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	got, ok := out.([]any)
+	if !ok {
+		t.Fatalf("RunWorkflow() returned %T, want []any", out)
+	}
+	want := []string{"first", "  second", "third"}
+	if len(got) != len(want) {
+		t.Fatalf("got %d results, want %d: %v", len(got), len(want), got)
+	}
+	for idx := range want {
+		if got[idx] != want[idx] {
+			t.Errorf("result[%d] = %q, want %q", idx, got[idx], want[idx])
+		}
+	}
+}
+
+func TestForEachRejectsScalar(t *testing.T) {
+	interp := &Interpreter{}
+	execCtx := &ExecutionContext{Variables: map[string]any{"n": 3}, Inputs: map[string]any{}}
+	_, err := interp.executeForEach(context.Background(), &Step{ForEach: "x in n"}, execCtx)
+	if err == nil {
+		t.Error("for-each over an int should fail")
+	}
+}
+
+func TestOnStepReportsProgress(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  pipeline:
+    steps:
+      - writer:
+          send: "draft"
+          save: draft
+      - if: "draft"
+        then:
+          - writer: "polish {{draft}}"
+      - writer:
+          send: "never"
+          if: "enabled"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	var mu sync.Mutex
+	var events []StepEvent
+	interp.OnStep(func(ev StepEvent) {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+	})
+
+	ctx := ContextWithRunID(context.Background(), "run-1")
+	if _, err := interp.RunWorkflow(ctx, "pipeline", map[string]any{"enabled": false}); err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	type summary struct {
+		index, depth int
+		typ          string
+		status       StepStatus
+	}
+	want := []summary{
+		{0, 0, "agent", StepStarted},
+		{0, 0, "agent", StepCompleted},
+		{1, 0, "if", StepStarted},
+		{1, 1, "agent", StepStarted},
+		{1, 1, "agent", StepCompleted},
+		{1, 0, "if", StepCompleted},
+		{2, 0, "agent", StepSkipped},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for idx, w := range want {
+		ev := events[idx]
+		got := summary{ev.Index, ev.Depth, ev.Type, ev.Status}
+		if got != w {
+			t.Errorf("event[%d] = %+v, want %+v", idx, got, w)
+		}
+		if ev.RunID != "run-1" || ev.Workflow != "pipeline" {
+			t.Errorf("event[%d] run/workflow = %q/%q", idx, ev.RunID, ev.Workflow)
+		}
+	}
+	if events[0].Agent != "writer" || events[0].Save != "draft" {
+		t.Errorf("first event agent/save = %q/%q", events[0].Agent, events[0].Save)
+	}
+	if events[0].Result != nil {
+		t.Errorf("started event carries result %v", events[0].Result)
+	}
+	if events[1].Result != "echo: draft" {
+		t.Errorf("completed event result = %v, want %q", events[1].Result, "echo: draft")
+	}
+}
+
+func TestOnStepOutputStreamsAgentSteps(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+  editor:
+    model: test-model
+    system: You edit.
+workflows:
+  pipeline:
+    steps:
+      - writer:
+          send: "draft"
+          save: draft
+      - editor:
+          send: "polish {{draft}}"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	var outputs []StepOutput
+	interp.OnStepOutput(func(out StepOutput) { outputs = append(outputs, out) })
+
+	result, err := interp.RunWorkflow(ContextWithRunID(context.Background(), "run-1"), "pipeline", nil)
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+	if result != "echo: polish echo: draft" {
+		t.Errorf("result = %v, want the streamed responses to flow between steps", result)
+	}
+
+	streamed := make(map[string]string)
+	for _, out := range outputs {
+		if out.RunID != "run-1" || out.Workflow != "pipeline" {
+			t.Errorf("output run/workflow = %q/%q", out.RunID, out.Workflow)
+		}
+		streamed[fmt.Sprintf("%d %s", out.Index, out.Agent)] += out.Delta
+	}
+	want := map[string]string{"0 writer": "echo: draft", "1 editor": "echo: polish echo: draft"}
+	if !reflect.DeepEqual(streamed, want) {
+		t.Errorf("streamed output = %v, want %v", streamed, want)
+	}
+}
+
+func TestMapStepTransformsItems(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  shout:
+    steps:
+      - set:
+          words: [alpha, beta]
+      - map: word in words
+        steps:
+          - writer: "{{word | upper}}"
+        save: shouted
+    output: "{{shouted | join:,}}"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	out, err := interp.RunWorkflow(context.Background(), "shout", map[string]any{})
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+	if want := "echo: ALPHA,echo: BETA"; out != want {
+		t.Errorf("RunWorkflow() = %q, want %q", out, want)
+	}
+}
+
+func TestParallelBranchesSaveConcurrently(t *testing.T) {
+	const branches = 50
+	var yaml strings.Builder
+	yaml.WriteString(`
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  fan:
+    steps:
+      - set:
+          topic: go
+      - parallel:
+`)
+	for n := range branches {
+		fmt.Fprintf(&yaml, "          - writer:\n              send: \"{{topic}} %d\"\n              save: out_%d\n", n, n)
+	}
+	doc := mustParse(t, yaml.String())
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	execCtx := &ExecutionContext{Workflow: "fan", Inputs: map[string]any{}, Variables: map[string]any{"topic": "go"}}
+	step := &doc.Workflows["fan"].Steps[1]
+	results, err := interp.executeParallel(context.Background(), step, execCtx)
+	if err != nil {
+		t.Fatalf("executeParallel: %v", err)
+	}
+	if n := len(results.([]any)); n != branches {
+		t.Errorf("got %d results, want %d", n, branches)
+	}
+	for n := range branches {
+		if got, want := execCtx.Variables[fmt.Sprintf("out_%d", n)], fmt.Sprintf("echo: go %d", n); got != want {
+			t.Errorf("out_%d = %v, want %q", n, got, want)
+		}
+	}
+}
+
+func TestParallelPartialResults(t *testing.T) {
+	const workflow = `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  fan:
+    steps:
+      - parallel:
+          - writer:
+              send: draft
+              save: draft
+          - map: item in count
+            steps:
+              - writer: "{{item}}"
+            save: items
+%s
+`
+	run := func(t *testing.T, policy string) (*ExecutionContext, any, error) {
+		t.Helper()
+		doc := mustParse(t, fmt.Sprintf(workflow, policy))
+		interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+		t.Cleanup(interp.Shutdown)
+
+		execCtx := &ExecutionContext{Workflow: "fan", Inputs: map[string]any{}, Variables: map[string]any{"count": 3}}
+		result, err := interp.executeParallel(context.Background(), &doc.Workflows["fan"].Steps[0], execCtx)
+		return execCtx, result, err
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		if _, _, err := run(t, ""); err == nil {
+			t.Error("executeParallel succeeded, want the failed branch's error")
+		}
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		execCtx, result, err := run(t, "        on_error: partial")
+		if err != nil {
+			t.Fatalf("executeParallel: %v", err)
+		}
+		if results := result.([]any); len(results) != 2 || results[0] != "echo: draft" || results[1] != nil {
+			t.Errorf("results = %v, want the draft and nil for the failed branch", results)
+		}
+		if execCtx.Variables["draft"] != "echo: draft" {
+			t.Errorf("draft = %v, want the successful branch's save", execCtx.Variables["draft"])
+		}
+		if _, ok := execCtx.Variables["items"]; ok {
+			t.Error("failed branch saved a result")
+		}
+		errs, _ := execCtx.Variables["errors"].([]any)
+		if len(errs) != 1 {
+			t.Fatalf("errors = %v, want one failure", execCtx.Variables["errors"])
+		}
+		failure := errs[0].(map[string]any)
+		if failure["index"] != 1 || failure["save"] != "items" || failure["error"] == "" {
+			t.Errorf("failure = %v, want branch 1 saving items", failure)
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		_, err := NewParser().Parse([]byte(fmt.Sprintf(workflow, "        on_error: ignore")))
+		if err == nil || !strings.Contains(err.Error(), "unknown on_error policy 'ignore'") {
+			t.Errorf("Parse() error = %v, want unknown on_error policy", err)
+		}
+	})
+}
+
+func TestFilterStepKeepsMatchingItems(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  judge:
+    model: test-model
+    system: You judge.
+workflows:
+  pick:
+    steps:
+      - set:
+          names: [keep-a, drop-b, keep-c]
+      - filter: name in names
+        where: "'keep' in name"
+        save: kept
+      - filter: name in kept
+        steps:
+          - set:
+              verdict: "{{loop.first}}"
+          - return: verdict
+        save: firsts
+    output:
+      kept: "{{kept | join:,}}"
+      firsts: "{{firsts | join:,}}"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+	defer interp.Shutdown()
+
+	out, err := interp.RunWorkflow(context.Background(), "pick", map[string]any{})
+	if err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+	got := out.(map[string]any)
+	if got["kept"] != "keep-a,keep-c" {
+		t.Errorf("kept = %q, want %q", got["kept"], "keep-a,keep-c")
+	}
+	if got["firsts"] != "keep-a" {
+		t.Errorf("firsts = %q, want %q", got["firsts"], "keep-a")
+	}
+}
+
+func TestIsTruthy(t *testing.T) {
+	tests := []struct {
+		val  any
+		want bool
+	}{
+		{nil, false},
+		{true, true},
+		{0, false},
+		{"", false},
+		{"No.", false},
+		{" false ", false},
+		{"yes", true},
+		{[]any{}, true},
+	}
+	for _, tt := range tests {
+		if got := isTruthy(tt.val); got != tt.want {
+			t.Errorf("isTruthy(%#v) = %v, want %v", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestAgentStepModelOverride(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  flow:
+    steps:
+      - writer:
+          send: "route this"
+          model: cheap-model
+          save: route
+      - writer:
+          send: "synthesize"
+          save: final
+`)
+	backend := &echoLLM{}
+	interp := newTestInterpreterWithLLM(t, doc, backend)
+
+	if _, err := interp.RunWorkflow(context.Background(), "flow", nil); err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	if len(backend.models) != 2 {
+		t.Fatalf("got %d LLM calls, want 2", len(backend.models))
+	}
+	if backend.models[0] != "cheap-model" {
+		t.Errorf("override step model = %q, want %q", backend.models[0], "cheap-model")
+	}
+	if backend.models[1] != "" {
+		t.Errorf("default step model = %q, want agent default (no override)", backend.models[1])
+	}
+
+	// The override step is stateless: it must not appear in the history
+	// sent with the next step.
+	for _, msg := range backend.lastRequest() {
+		if strings.Contains(msg.Content, "route this") {
+			t.Errorf("override step leaked into agent history: %q", msg.Content)
+		}
+	}
+}
+
+func TestPlanWorkflow(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  router:
+    model: test-model
+    system: You route.
+  writer:
+    model: test-model
+    system: You write.
+  reviewer:
+    model: test-model
+    system: You review.
+workflows:
+  flow:
+    inputs:
+      topic:
+        type: string
+      sections:
+        type: array
+    steps:
+      - router:
+          send: "Classify {{topic}}"
+          save: kind
+      - if: "kind"
+        then:
+          - writer:
+              send: "Write about {{topic}}"
+              save: draft
+        else:
+          - reviewer: "Nothing to write"
+      - map: section in sections
+        steps:
+          - writer: "Expand {{section}}"
+      - repeat:
+          max: 3
+          until: "done"
+          steps:
+            - reviewer: "Review again"
+`)
+	backend := &echoLLM{}
+	interp := newTestInterpreterWithLLM(t, doc, backend)
+
+	plan, err := interp.Plan("flow", map[string]any{
+		"topic":    "otters",
+		"sections": []any{"habitat", "diet"},
+	})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(backend.requests) != 0 {
+		t.Fatalf("Plan made %d LLM calls, want 0", len(backend.requests))
+	}
+
+	if got, want := strings.Join(plan.Agents, ","), "reviewer,router,writer"; got != want {
+		t.Errorf("agents = %s, want %s", got, want)
+	}
+	if len(plan.Steps) != 4 {
+		t.Fatalf("got %d top-level steps, want 4", len(plan.Steps))
+	}
+
+	route := plan.Steps[0]
+	if route.Type != "agent" || route.Agent != "router" || route.Message != "Classify otters" {
+		t.Errorf("step 0 = %+v, want router agent step with resolved message", route)
+	}
+
+	branch := plan.Steps[1]
+	if branch.Type != "if" || !branch.Conditional {
+		t.Errorf("step 1 = %+v, want unresolved conditional", branch)
+	}
+	if len(branch.Steps) != 1 || branch.Steps[0].Agent != "writer" {
+		t.Errorf("then branch = %+v, want writer step", branch.Steps)
+	}
+	if len(branch.Else) != 1 || branch.Else[0].Agent != "reviewer" {
+		t.Errorf("else branch = %+v, want reviewer step", branch.Else)
+	}
+
+	loop := plan.Steps[2]
+	if loop.Type != "map" || loop.MaxIterations != 2 {
+		t.Errorf("step 2 = %+v, want map loop bounded at 2", loop)
+	}
+	if len(loop.Steps) != 1 || loop.Steps[0].Message != "Expand {{section}}" {
+		t.Errorf("loop body = %+v, want unresolved item placeholder", loop.Steps)
+	}
+
+	if repeat := plan.Steps[3]; repeat.Type != "repeat" || repeat.MaxIterations != 3 {
+		t.Errorf("step 3 = %+v, want repeat bounded at 3", repeat)
+	}
+}
+
+func TestPlanResolvesStaticCondition(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+  reviewer:
+    model: test-model
+    system: You review.
+workflows:
+  flow:
+    inputs:
+      review:
+        type: boolean
+    steps:
+      - if: "review"
+        then:
+          - reviewer: "Review it"
+        else:
+          - writer: "Just write it"
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &echoLLM{})
+
+	plan, err := interp.Plan("flow", map[string]any{"review": false})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	step := plan.Steps[0]
+	if step.Conditional || step.Branch != "else" {
+		t.Errorf("step = %+v, want statically resolved else branch", step)
+	}
+	if got := strings.Join(plan.Agents, ","); got != "writer" {
+		t.Errorf("agents = %s, want only writer", got)
+	}
+}
+
+// scriptedLLM returns its replies in order, repeating the last one, and
+// records the last user message of every request.
+type scriptedLLM struct {
+	mu      sync.Mutex
+	replies []string
+	asked   []string
+}
+
+func (s *scriptedLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.asked = append(s.asked, messages[len(messages)-1].Content)
+	reply := s.replies[min(len(s.asked), len(s.replies))-1]
+	return &llm.LLMResponse{Content: reply}, nil
+}
+
+func (s *scriptedLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	resp, _ := s.Generate(ctx, messages, tools)
+	ch := make(chan llm.StreamEvent, 1)
+	ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: resp.Content}
+	close(ch)
+	return ch, nil
+}
+
+func (s *scriptedLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestDecideStep(t *testing.T) {
+	const workflow = `
+name: Test
+agents:
+  reviewer:
+    model: test-model
+    system: You review expenses.
+workflows:
+  approve:
+    steps:
+      - decide: reviewer
+        send: "Approve a {{amount}} dollar lunch?"
+        outcomes: [approve, reject, escalate]
+        save: decision
+      - if: "'approve' in decision"
+        then:
+          - set:
+              route: paid
+        else:
+          - set:
+              route: held
+    output:
+      decision: "{{decision}}"
+      route: "{{route}}"
+`
+	run := func(t *testing.T, replies ...string) (*scriptedLLM, any, error) {
+		t.Helper()
+		doc := mustParse(t, workflow)
+		backend := &scriptedLLM{replies: replies}
+		interp := newTestInterpreterWithLLM(t, doc, backend)
+		t.Cleanup(interp.Shutdown)
+		result, err := interp.Execute(context.Background(), "approve", map[string]any{"amount": 40})
+		return backend, result, err
+	}
+
+	t.Run("on-list answer", func(t *testing.T) {
+		backend, result, err := run(t, " **Approve.** ")
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		if out := result.(map[string]any); out["decision"] != "approve" || out["route"] != "paid" {
+			t.Errorf("result = %v, want the approve branch", result)
+		}
+		if len(backend.asked) != 1 || !strings.Contains(backend.asked[0], "approve, reject, escalate") {
+			t.Errorf("asked = %q, want one prompt listing the outcomes", backend.asked)
+		}
+	})
+
+	t.Run("retries off-list answer", func(t *testing.T) {
+		backend, result, err := run(t, "Probably fine, I guess?", "Escalate")
+		if err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		if out := result.(map[string]any); out["decision"] != "escalate" || out["route"] != "held" {
+			t.Errorf("result = %v, want the outcome as declared", result)
+		}
+		if len(backend.asked) != 2 || !strings.Contains(backend.asked[1], "not one of the allowed outcomes") {
+			t.Errorf("asked = %q, want a retry naming the off-list answer", backend.asked)
+		}
+	})
+
+	t.Run("gives up after retries", func(t *testing.T) {
+		backend, _, err := run(t, "maybe")
+		if err == nil || !strings.Contains(err.Error(), `answered "maybe"`) {
+			t.Errorf("Execute error = %v, want the off-list answer", err)
+		}
+		if len(backend.asked) != 1+defaultDecideRetries {
+			t.Errorf("asked %d times, want %d", len(backend.asked), 1+defaultDecideRetries)
+		}
+	})
+
+	t.Run("no outcomes", func(t *testing.T) {
+		_, err := NewParser().Parse([]byte(strings.Replace(workflow, "outcomes: [approve, reject, escalate]", "outcomes: []", 1)))
+		if err == nil || !strings.Contains(err.Error(), "decide step has no outcomes") {
+			t.Errorf("Parse() error = %v, want missing outcomes", err)
+		}
+	})
+}
+
+// stateProbeLLM answers writers with a draft and has inspectors call the
+// workflow_state tool, replying with what it returned.
+type stateProbeLLM struct{}
+
+func (stateProbeLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	last := messages[len(messages)-1].Content
+	switch {
+	case strings.HasPrefix(last, "<tool_result"):
+		return &llm.LLMResponse{Content: last}, nil
+	case strings.Contains(last, "inspect"):
+		return &llm.LLMResponse{ToolCalls: []llm.ToolCall{{ID: "t1", Name: "workflow_state", Arguments: map[string]any{}}}}, nil
+	default:
+		return &llm.LLMResponse{Content: "a draft about otters"}, nil
+	}
+}
+
+func (l stateProbeLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	resp, _ := l.Generate(ctx, messages, tools)
+	ch := make(chan llm.StreamEvent, 1)
+	ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: resp.Content}
+	close(ch)
+	return ch, nil
+}
+
+func (stateProbeLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestWorkflowStateTool(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+  inspector:
+    model: test-model
+    system: You check the workflow.
+    tools: [workflow_state]
+workflows:
+  pipeline:
+    inputs:
+      topic: string
+      customer:
+        type: string
+        sensitive: true
+      api_token: string
+    steps:
+      - writer:
+          send: "write about {{topic}}"
+          save: draft
+      - set:
+          meta:
+            status: drafted
+            password: hunter2
+      - inspector:
+          send: "inspect"
+          save: report
+    output: "{{report}}"
+`)
+	interp, err := NewInterpreter(doc, WithLLM(stateProbeLLM{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(interp.Shutdown)
+
+	result, err := interp.Execute(context.Background(), "pipeline", map[string]any{
+		"topic": "otters", "customer": "Ada Lovelace", "api_token": "sk-123",
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	report := fmt.Sprint(result)
+
+	for _, want := range []string{`"workflow": "pipeline"`, `"step": 2`, `"draft": "a draft about otters"`, `"status": "drafted"`, `"topic": "otters"`} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %s:\n%s", want, report)
+		}
+	}
+	for _, secret := range []string{"Ada Lovelace", "sk-123", "hunter2"} {
+		if strings.Contains(report, secret) {
+			t.Errorf("report leaks %q:\n%s", secret, report)
+		}
+	}
+
+	// Outside a workflow there is no state to show.
+	if _, err := interp.Tools().Execute(context.Background(), "workflow_state", map[string]any{}); err == nil {
+		t.Error("workflow_state outside a workflow succeeded")
+	}
+}
+
+func TestIsSensitiveName(t *testing.T) {
+	tests := map[string]bool{
+		"password":       true,
+		"db_password":    true,
+		"api_token":      true,
+		"accessToken":    true,
+		"refresh-tokens": true,
+		"apiKey":         true,
+		"APIKey":         true,
+		"OPENAI_API_KEY": true,
+		"apikey":         true,
+		"private_key":    true,
+		"auth":           true,
+		"authToken":      true,
+		"Authorization":  true,
+		"clientSecrets":  true,
+		"author":         false,
+		"authors":        false,
+		"authenticated":  false,
+		"tokenizer":      false,
+		"keyboard":       false,
+		"api_version":    false,
+		"topic":          false,
+	}
+	for name, want := range tests {
+		if got := isSensitiveName(name); got != want {
+			t.Errorf("isSensitiveName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestOnTurnComplete(t *testing.T) {
+	doc := &Document{Agents: map[string]*Agent{
+		"helper": {Name: "helper", Model: "test-model", System: "You help."},
+	}}
+	interp, err := NewInterpreter(doc, WithLLM(&scriptedLLM{replies: []string{"first reply", "streamed reply"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	type turn struct{ agent, user, assistant string }
+	turns := make(chan turn, 4)
+	release := make(chan struct{})
+	interp.OnTurnComplete(func(agent, userMsg, assistantMsg string) {
+		<-release // a slow callback must not hold up the response
+		turns <- turn{agent, userMsg, assistantMsg}
+	})
+
+	ctx := context.Background()
+	if resp, err := interp.SendToAgent(ctx, "helper", "hello"); err != nil || resp != "first reply" {
+		t.Fatalf("SendToAgent = %q, %v", resp, err)
+	}
+	stream, err := interp.StreamToAgent(ctx, "helper", "stream please")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range stream.Events() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+
+	var got []turn
+	for len(got) < 2 {
+		select {
+		case tr := <-turns:
+			got = append(got, tr)
+		case <-time.After(time.Second):
+			t.Fatalf("got %d turns, want 2: %+v", len(got), got)
+		}
+	}
+	select {
+	case tr := <-turns:
+		t.Errorf("extra turn reported: %+v", tr)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	want := map[turn]bool{
+		{"helper", "hello", "first reply"}:            true,
+		{"helper", "stream please", "streamed reply"}: true,
+	}
+	for _, tr := range got {
+		if !want[tr] {
+			t.Errorf("unexpected turn %+v", tr)
+		}
+		delete(want, tr)
+	}
+}
+
+func TestOnTurnCompleteStepModel(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  helper:
+    model: test-model
+    system: You help.
+workflows:
+  quick:
+    steps:
+      - helper:
+          send: "summarise"
+          model: other-model
+`)
+	interp := newTestInterpreterWithLLM(t, doc, &scriptedLLM{replies: []string{"summary"}})
+	defer interp.Shutdown()
+
+	turns := make(chan [3]string, 1)
+	interp.OnTurnComplete(func(agent, userMsg, assistantMsg string) {
+		turns <- [3]string{agent, userMsg, assistantMsg}
+	})
+	if _, err := interp.RunWorkflow(context.Background(), "quick", nil); err != nil {
+		t.Fatalf("RunWorkflow: %v", err)
+	}
+
+	select {
+	case tr := <-turns:
+		if tr != [3]string{"helper", "summarise", "summary"} {
+			t.Errorf("turn = %q", tr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("step with a model override reported no turn")
+	}
+}
+
+func TestInterpreterDrain(t *testing.T) {
+	doc := &Document{Agents: map[string]*Agent{
+		"helper": {Name: "helper", Model: "test-model", System: "You help."},
+	}}
+	interp, err := NewInterpreter(doc, WithLLM(&scriptedLLM{replies: []string{"ok"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := interp.SendToAgent(ctx, "helper", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.Drain(ctx); err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if _, err := interp.SendToAgent(ctx, "helper", "again"); !errors.Is(err, vega.ErrShuttingDown) {
+		t.Errorf("SendToAgent after Drain = %v, want ErrShuttingDown", err)
+	}
+}
@@ -0,0 +1,203 @@
+package dsl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/everydev1618/govega/tools"
+)
+
+const (
+	// maxKnowledgeBytes caps the knowledge injected into one agent's
+	// system prompt. Sources past the cap are skipped.
+	maxKnowledgeBytes = 64 * 1024
+
+	// maxKnowledgeItemBytes caps a single knowledge source; longer content
+	// is truncated.
+	maxKnowledgeItemBytes = 32 * 1024
+
+	// knowledgeCacheTTL is how long fetched http(s) and MCP knowledge is
+	// reused. file:// knowledge is reused until the file changes.
+	knowledgeCacheTTL = 5 * time.Minute
+)
+
+// knowledgeHTTPClient fetches http(s) knowledge sources. It refuses
+// private and local addresses; knowledgePrivateHTTPClient, used with
+// WithKnowledgePrivateHosts, doesn't.
+var (
+	knowledgeHTTPClient        = tools.NewGuardedHTTPClient(15*time.Second, false)
+	knowledgePrivateHTTPClient = tools.NewGuardedHTTPClient(15*time.Second, true)
+)
+
+// knowledgeEntry is a cached knowledge source.
+type knowledgeEntry struct {
+	content   string
+	fetchedAt time.Time
+	modTime   time.Time // file:// sources only
+	size      int64     // file:// sources only
+}
+
+// resolveKnowledge fetches all knowledge URIs and returns a formatted
+// section. A source that fails to load is skipped with a warning, and
+// sources past maxKnowledgeBytes are left out.
+func (i *Interpreter) resolveKnowledge(ctx context.Context, uris []string) string {
+	var builder strings.Builder
+	builder.WriteString("# Knowledge\n")
+	any := false
+	remaining := maxKnowledgeBytes
+
+	for _, uri := range uris {
+		if remaining <= 0 {
+			slog.Warn("knowledge: size cap reached, skipping source", "uri", uri, "cap_bytes", maxKnowledgeBytes)
+			continue
+		}
+		content, err := i.loadKnowledge(ctx, uri)
+		if err != nil {
+			slog.Warn("knowledge: skipping source", "uri", uri, "error", err)
+			continue
+		}
+		if len(content) > remaining {
+			slog.Warn("knowledge: size cap reached, truncating source", "uri", uri, "cap_bytes", maxKnowledgeBytes)
+			content = truncateKnowledge(content, remaining)
+			if content == "" {
+				remaining = 0
+				continue
+			}
+		}
+		remaining -= len(content)
+
+		any = true
+		builder.WriteString("\n## ")
+		builder.WriteString(uri)
+		builder.WriteString("\n```\n")
+		builder.WriteString(content)
+		builder.WriteString("\n```\n")
+	}
+
+	if !any {
+		return ""
+	}
+	return builder.String()
+}
+
+// loadKnowledge returns the content of a knowledge source, from the cache
+// when it is still fresh.
+func (i *Interpreter) loadKnowledge(ctx context.Context, uri string) (string, error) {
+	var info os.FileInfo
+	path, isFile := strings.CutPrefix(uri, "file://")
+	if isFile {
+		var err error
+		if info, err = os.Stat(path); err != nil {
+			return "", fmt.Errorf("read knowledge file %s: %w", path, err)
+		}
+	}
+
+	i.knowledgeMu.Lock()
+	entry, ok := i.knowledgeCache[uri]
+	i.knowledgeMu.Unlock()
+	if ok {
+		if isFile && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+			return entry.content, nil
+		}
+		if !isFile && time.Since(entry.fetchedAt) < knowledgeCacheTTL {
+			return entry.content, nil
+		}
+	}
+
+	content, err := i.fetchKnowledgeItem(ctx, uri)
+	if err != nil {
+		return "", err
+	}
+	content = truncateKnowledge(content, maxKnowledgeItemBytes)
+
+	entry = knowledgeEntry{content: content, fetchedAt: time.Now()}
+	if isFile {
+		entry.modTime, entry.size = info.ModTime(), info.Size()
+	}
+	i.knowledgeMu.Lock()
+	if i.knowledgeCache == nil {
+		i.knowledgeCache = make(map[string]knowledgeEntry)
+	}
+	i.knowledgeCache[uri] = entry
+	i.knowledgeMu.Unlock()
+	return content, nil
+}
+
+// fetchKnowledgeItem fetches a single knowledge resource.
+// Routes file:// URIs to os.ReadFile and http(s):// URIs to an HTTP GET.
+// Other schemes are treated as MCP resource URIs where the scheme
+// identifies the MCP server name.
+func (i *Interpreter) fetchKnowledgeItem(ctx context.Context, uri string) (string, error) {
+	if strings.HasPrefix(uri, "file://") {
+		path := strings.TrimPrefix(uri, "file://")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read knowledge file %s: %w", path, err)
+		}
+		return string(data), nil
+	}
+
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		client := knowledgeHTTPClient
+		if i.knowledgePrivateHosts {
+			client = knowledgePrivateHTTPClient
+		}
+		return fetchKnowledgeURL(ctx, client, uri)
+	}
+
+	// Parse scheme as MCP server name: "postgres://public/users" -> server=postgres, uri=public/users
+	if idx := strings.Index(uri, "://"); idx > 0 {
+		serverName := uri[:idx]
+		return i.tools.ReadMCPResource(ctx, serverName, uri)
+	}
+
+	return "", fmt.Errorf("unsupported knowledge URI scheme: %s", uri)
+}
+
+// fetchKnowledgeURL GETs an http(s) knowledge source with client, reading
+// no more than maxKnowledgeItemBytes of the body.
+func fetchKnowledgeURL(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch knowledge %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("fetch knowledge %s: %s", url, resp.Status)
+	}
+
+	// Read one byte past the cap so truncateKnowledge marks the cut.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxKnowledgeItemBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("fetch knowledge %s: %w", url, err)
+	}
+	return string(data), nil
+}
+
+// truncateKnowledge shortens content to at most limit bytes, marking the
+// cut.
+func truncateKnowledge(content string, limit int) string {
+	const marker = "\n[truncated]"
+	if len(content) <= limit {
+		return content
+	}
+	if limit <= len(marker) {
+		return ""
+	}
+	cut := limit - len(marker)
+	// Back up to a rune boundary.
+	for cut > 0 && content[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return content[:cut] + marker
+}
@@ -0,0 +1,110 @@
+package dsl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/everydev1618/govega/tools"
+)
+
+func TestKnowledgeLoading(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/api.md":
+			w.Write([]byte("The API rate limit is 100 requests per minute."))
+		case "/big.md":
+			w.Write([]byte(strings.Repeat("x", 2*maxKnowledgeItemBytes)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "standards.md")
+	if err := os.WriteFile(path, []byte("Use tabs."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := &Document{Agents: map[string]*Agent{
+		"helper": {Name: "helper", Model: "test-model", System: "You help.", Knowledge: []string{
+			"file://" + path,
+			srv.URL + "/api.md",
+			srv.URL + "/missing.md",
+			"file:///does/not/exist.md",
+		}},
+	}}
+	// The test server is on loopback.
+	interp, err := NewInterpreter(doc, WithLLM(&scriptedLLM{}), WithKnowledgePrivateHosts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	proc, err := interp.EnsureAgent("helper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt := proc.Agent.System.Prompt()
+	for _, want := range []string{"# Knowledge", "Use tabs.", "100 requests per minute", "You help."} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("system prompt missing %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "missing.md") || strings.Contains(prompt, "exist.md") {
+		t.Errorf("failed sources should be skipped:\n%s", prompt)
+	}
+
+	// Fetched content is cached; a changed file is read again.
+	ctx := context.Background()
+	before := hits.Load()
+	if err := os.WriteFile(path, []byte("Use spaces now."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	section := interp.resolveKnowledge(ctx, []string{srv.URL + "/api.md", "file://" + path})
+	if hits.Load() != before {
+		t.Errorf("http source fetched again within the cache TTL")
+	}
+	if !strings.Contains(section, "Use spaces now.") {
+		t.Errorf("changed file not reloaded:\n%s", section)
+	}
+
+	// Each source and the whole section are capped.
+	section = interp.resolveKnowledge(ctx, []string{srv.URL + "/big.md", srv.URL + "/big.md?again", srv.URL + "/big.md?third", srv.URL + "/api.md"})
+	if n := strings.Count(section, "x"); n > maxKnowledgeBytes || n < maxKnowledgeBytes-1024 {
+		t.Errorf("section holds %d bytes of knowledge, want close to the %d cap", n, maxKnowledgeBytes)
+	}
+	if strings.Contains(section, "100 requests per minute") {
+		t.Error("sources past the cap should be skipped")
+	}
+}
+
+func TestKnowledgeRefusesPrivateHosts(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("internal secrets"))
+	}))
+	defer srv.Close()
+
+	interp, err := NewInterpreter(&Document{}, WithLLM(&scriptedLLM{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	if _, err := interp.loadKnowledge(context.Background(), srv.URL+"/wiki.md"); !errors.Is(err, tools.ErrHostNotAllowed) {
+		t.Errorf("loadKnowledge error = %v, want ErrHostNotAllowed", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("private host was contacted %d times", hits.Load())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -152,6 +153,9 @@ func (p *Parser) parseAgent(name string, raw any) (*Agent, error) {
 	if v, ok := m["fallback_model"].(string); ok {
 		agent.FallbackModel = v
 	}
+	if v, ok := m["account"].(string); ok {
+		agent.Account = v
+	}
 	if v, ok := m["system"].(string); ok {
 		agent.System = v
 	}
@@ -161,6 +165,15 @@ func (p *Parser) parseAgent(name string, raw any) (*Agent, error) {
 	if v, ok := m["budget"].(string); ok {
 		agent.Budget = v
 	}
+	if v, ok := m["max_history"].(int); ok {
+		agent.MaxHistory = v
+	}
+	if v, ok := m["persist_chat"].(bool); ok {
+		agent.PersistChat = &v
+	}
+	if v, ok := m["memory"].(bool); ok {
+		agent.Memory = &v
+	}
 
 	// Parse tools list
 	if tools, ok := m["tools"].([]any); ok {
@@ -355,6 +368,9 @@ func (p *Parser) parseInput(name string, raw any) (*Input, error) {
 		if r, ok := v["required"].(bool); ok {
 			input.Required = r
 		}
+		if sens, ok := v["sensitive"].(bool); ok {
+			input.Sensitive = sens
+		}
 		if def := v["default"]; def != nil {
 			input.Default = def
 			input.Required = false
@@ -420,6 +436,9 @@ func (p *Parser) parseStep(raw any) (*Step, error) {
 			}
 			step.Parallel = append(step.Parallel, *parsed)
 		}
+		if onErr, ok := m["on_error"].(string); ok {
+			step.OnError = onErr
+		}
 		return step, nil
 	}
 
@@ -444,6 +463,79 @@ func (p *Parser) parseStep(raw any) (*Step, error) {
 		return step, nil
 	}
 
+	// Check for for-each
+	if forExpr, ok := m["for"].(string); ok {
+		step.ForEach = forExpr
+		if save, ok := m["save"].(string); ok {
+			step.Save = save
+		}
+		return step, nil
+	}
+	if mapExpr, ok := m["map"].(string); ok {
+		step.Map = mapExpr
+		body, err := p.parseSteps(m["steps"])
+		if err != nil {
+			return nil, err
+		}
+		step.Steps = body
+		if save, ok := m["save"].(string); ok {
+			step.Save = save
+		}
+		return step, nil
+	}
+	if filterExpr, ok := m["filter"].(string); ok {
+		step.Filter = filterExpr
+		body, err := p.parseSteps(m["steps"])
+		if err != nil {
+			return nil, err
+		}
+		step.Steps = body
+		if where, ok := m["where"].(string); ok {
+			step.Where = where
+		}
+		if save, ok := m["save"].(string); ok {
+			step.Save = save
+		}
+		return step, nil
+	}
+
+	// Check for decide
+	if agent, ok := m["decide"].(string); ok {
+		step.Decide = agent
+		step.Agent = agent
+		if send, ok := m["send"].(string); ok {
+			step.Send = send
+		}
+		if outcomes, ok := m["outcomes"].([]any); ok {
+			for _, o := range outcomes {
+				step.Outcomes = append(step.Outcomes, fmt.Sprint(o))
+			}
+		}
+		if save, ok := m["save"].(string); ok {
+			step.Save = save
+		}
+		if retry, ok := m["retry"].(int); ok {
+			step.Retry = retry
+		}
+		if timeout, ok := m["timeout"].(string); ok {
+			step.Timeout = timeout
+		}
+		if model, ok := m["model"].(string); ok {
+			step.Model = model
+		}
+		switch c := m["context"].(type) {
+		case string:
+			step.Context = []string{c}
+		case []any:
+			for _, name := range c {
+				if s, ok := name.(string); ok {
+					step.Context = append(step.Context, s)
+				}
+			}
+		}
+		return step, nil
+	}
+
 	// Check for workflow call
 	if wf, ok := m["workflow"].(string); ok {
 		step.Workflow = wf
@@ -531,6 +623,19 @@ func (p *Parser) parseStep(raw any) (*Step, error) {
 			if format, ok := v["format"].(string); ok {
 				step.Format = format
 			}
+			if model, ok := v["model"].(string); ok {
+				step.Model = model
+			}
+			switch c := v["context"].(type) {
+			case string:
+				step.Context = []string{c}
+			case []any:
+				for _, name := range c {
+					if s, ok := name.(string); ok {
+						step.Context = append(step.Context, s)
+					}
+				}
+			}
 		}
 		break
 	}
@@ -538,6 +643,23 @@ func (p *Parser) parseStep(raw any) (*Step, error) {
 	return step, nil
 }
 
+// parseSteps parses a list of nested steps. Non-list values yield no steps.
+func (p *Parser) parseSteps(raw any) ([]Step, error) {
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, nil
+	}
+	var steps []Step
+	for _, s := range list {
+		parsed, err := p.parseStep(s)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, *parsed)
+	}
+	return steps, nil
+}
+
 // parseCompany parses the company identity block.
 func (p *Parser) parseCompany(m map[string]any) *Company {
 	c := &Company{}
@@ -598,6 +720,12 @@ func (p *Parser) parseSettings(m map[string]any) *Settings {
 	if v, ok := m["budget"].(string); ok {
 		s.Budget = v
 	}
+	if v, ok := m["max_tool_result_bytes"].(int); ok {
+		s.MaxToolResultBytes = v
+	}
+	if v, ok := m["max_tool_arg_bytes"].(int); ok {
+		s.MaxToolArgBytes = v
+	}
 
 	// Parse supervision
 	if sup, ok := m["supervision"].(map[string]any); ok {
@@ -723,9 +851,38 @@ func (p *Parser) parseSettings(m map[string]any) *Settings {
 		}
 	}
 
+	// Parse provider accounts
+	if accounts, ok := m["accounts"].(map[string]any); ok {
+		s.Accounts = make(map[string]*AccountDef)
+		for name, raw := range accounts {
+			acct := &AccountDef{}
+			if v, ok := raw.(map[string]any); ok {
+				if provider, ok := v["provider"].(string); ok {
+					acct.Provider = provider
+				}
+				if key, ok := v["api_key"].(string); ok {
+					acct.APIKey = key
+				}
+				if url, ok := v["base_url"].(string); ok {
+					acct.BaseURL = url
+				}
+			}
+			s.Accounts[name] = acct
+		}
+	}
+
 	return s
 }
 
+// parseBudget parses a dollar amount such as "$0.50" or "2".
+func parseBudget(s string) (float64, error) {
+	usd, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(s), "$"), 64)
+	if err != nil || usd <= 0 {
+		return 0, fmt.Errorf("invalid budget %q", s)
+	}
+	return usd, nil
+}
+
 // validate validates the parsed document.
 func (p *Parser) validate(doc *Document) error {
 	// Check for at least one agent
@@ -736,38 +893,66 @@ func (p *Parser) validate(doc *Document) error {
 		}
 	}
 
+	if doc.Settings != nil && doc.Settings.Budget != "" {
+		if _, err := parseBudget(doc.Settings.Budget); err != nil {
+			return &ValidationError{
+				Field:   "settings.budget",
+				Message: err.Error(),
+				Hint:    "Use a dollar amount like '$10.00'",
+			}
+		}
+	}
+
 	// Validate agents
 	for name, agent := range doc.Agents {
-		if agent.Model == "" && doc.Settings != nil && doc.Settings.DefaultModel != "" {
+		// Check extends reference
+		if agent.Extends != "" {
+			if _, ok := doc.Agents[agent.Extends]; !ok {
+				return &ValidationError{
+					Field:   fmt.Sprintf("agents.%s.extends", name),
+					Message: fmt.Sprintf("agent '%s' not found", agent.Extends),
+					Hint:    fmt.Sprintf("Did you mean one of: %s?", strings.Join(agentNames(doc), ", ")),
+				}
+			}
+		}
+		resolved, err := resolveAgent(doc.Agents, name, agent)
+		if err != nil {
+			return &ValidationError{
+				Field:   fmt.Sprintf("agents.%s.extends", name),
+				Message: err.Error(),
+			}
+		}
+
+		// Required fields may be inherited from the agents this one extends.
+		if resolved.Model == "" && doc.Settings != nil && doc.Settings.DefaultModel != "" {
 			agent.Model = doc.Settings.DefaultModel
+			resolved.Model = agent.Model
 		}
-		if agent.Model == "" {
+		if resolved.Model == "" {
 			return &ValidationError{
 				Field:   fmt.Sprintf("agents.%s.model", name),
 				Message: "model is required",
 				Hint:    "Add 'model: claude-sonnet-4-20250514' or set default_model in settings",
 			}
 		}
-		if agent.System == "" {
+		if resolved.System == "" {
 			return &ValidationError{
 				Field:   fmt.Sprintf("agents.%s.system", name),
 				Message: "system prompt is required",
 			}
 		}
-
-		// Check extends reference
-		if agent.Extends != "" {
-			if _, ok := doc.Agents[agent.Extends]; !ok {
+		if agent.Budget != "" {
+			if _, err := parseBudget(agent.Budget); err != nil {
 				return &ValidationError{
-					Field:   fmt.Sprintf("agents.%s.extends", name),
-					Message: fmt.Sprintf("agent '%s' not found", agent.Extends),
-					Hint:    fmt.Sprintf("Did you mean one of: %s?", strings.Join(agentNames(doc), ", ")),
+					Field:   fmt.Sprintf("agents.%s.budget", name),
+					Message: err.Error(),
+					Hint:    "Use a dollar amount like '$0.50'",
 				}
 			}
 		}
 
-		// Check team references
-		for _, member := range agent.Team {
+		// Check team references, including an inherited team
+		for _, member := range resolved.Team {
 			if member == name {
 				return &ValidationError{
 					Field:   fmt.Sprintf("agents.%s.team", name),
@@ -784,66 +969,8 @@ func (p *Parser) validate(doc *Document) error {
 		}
 	}
 
-	// Validate workflows
-	for name, wf := range doc.Workflows {
-		for i, step := range wf.Steps {
-			if err := p.validateStep(doc, name, i, &step); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
-}
-
-// validateStep validates a workflow step.
-func (p *Parser) validateStep(doc *Document, wfName string, stepIndex int, step *Step) error {
-	// Validate agent reference
-	if step.Agent != "" {
-		if _, ok := doc.Agents[step.Agent]; !ok {
-			return &ValidationError{
-				Field:   fmt.Sprintf("workflows.%s.steps[%d]", wfName, stepIndex),
-				Message: fmt.Sprintf("unknown agent '%s'", step.Agent),
-				Hint:    fmt.Sprintf("Did you mean '%s'?", findSimilar(step.Agent, agentNames(doc))),
-			}
-		}
-	}
-
-	// Validate sub-workflow reference
-	if step.Workflow != "" {
-		if _, ok := doc.Workflows[step.Workflow]; !ok {
-			return &ValidationError{
-				Field:   fmt.Sprintf("workflows.%s.steps[%d].workflow", wfName, stepIndex),
-				Message: fmt.Sprintf("unknown workflow '%s'", step.Workflow),
-			}
-		}
-	}
-
-	// Recursively validate nested steps
-	for i, s := range step.Then {
-		if err := p.validateStep(doc, wfName, i, &s); err != nil {
-			return err
-		}
-	}
-	for i, s := range step.Else {
-		if err := p.validateStep(doc, wfName, i, &s); err != nil {
-			return err
-		}
-	}
-	for i, s := range step.Parallel {
-		if err := p.validateStep(doc, wfName, i, &s); err != nil {
-			return err
-		}
-	}
-	if step.Repeat != nil {
-		for i, s := range step.Repeat.Steps {
-			if err := p.validateStep(doc, wfName, i, &s); err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	// Validate workflows, reporting every problem at once
+	return doc.Validate()
 }
 
 // Helper functions
@@ -857,6 +984,8 @@ func isKnownKey(key string) bool {
 		"try": true, "catch": true,
 		"save": true, "timeout": true, "budget": true,
 		"retry": true, "continue_on_error": true, "format": true,
+		"context": true, "map": true, "filter": true, "where": true,
+		"steps": true,
 	}
 	return known[key]
 }
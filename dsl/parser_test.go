@@ -663,3 +663,23 @@ agents:
 		t.Errorf("Agent.Budget = %q, want %q", agent.Budget, "$5.00")
 	}
 }
+
+func TestParseAgentWithMaxHistory(t *testing.T) {
+	yaml := `
+name: Test
+agents:
+  triage:
+    model: claude-haiku-4-5
+    system: You sort tickets.
+    max_history: 3
+`
+	p := NewParser()
+	doc, err := p.Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+
+	if got := doc.Agents["triage"].MaxHistory; got != 3 {
+		t.Errorf("Agent.MaxHistory = %d, want 3", got)
+	}
+}
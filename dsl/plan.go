@@ -0,0 +1,336 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/everydev1618/govega"
+)
+
+// Plan is a static description of what a workflow would do, produced
+// without running any agents.
+type Plan struct {
+	Workflow string     `json:"workflow"`
+	Steps    []PlanStep `json:"steps"`
+	Agents   []string   `json:"agents"` // agents the run may call, sorted
+}
+
+// PlanStep describes one workflow step in a Plan. Nested steps (if
+// branches, loop bodies, parallel branches, try blocks and sub-workflows)
+// appear under Steps; else branches and catch blocks under Else.
+type PlanStep struct {
+	Type    string `json:"type"`
+	Agent   string `json:"agent,omitempty"`
+	Message string `json:"message,omitempty"` // placeholders resolved where known
+	Model   string `json:"model,omitempty"`
+	Save    string `json:"save,omitempty"`
+
+	// Condition is the step's if-expression, if any. Conditional is set
+	// when it depends on values only known at run time; otherwise Branch
+	// records the statically chosen outcome ("then" or "else").
+	Condition   string `json:"condition,omitempty"`
+	Conditional bool   `json:"conditional,omitempty"`
+	Branch      string `json:"branch,omitempty"`
+
+	// Loop is the loop expression ("item in items" or a repeat's until).
+	// MaxIterations is the static bound, or 0 when it depends on run-time
+	// data.
+	Loop          string `json:"loop,omitempty"`
+	MaxIterations int    `json:"max_iterations,omitempty"`
+
+	Workflow  string `json:"workflow,omitempty"`
+	Recursive bool   `json:"recursive,omitempty"` // sub-workflow already on the call stack
+
+	Steps []PlanStep `json:"steps,omitempty"`
+	Else  []PlanStep `json:"else,omitempty"`
+}
+
+// planner carries the state of a Plan walk. execCtx.Variables holds only
+// values that are known statically — inputs and set-steps with resolvable values.
+// Anything produced by an agent is left out, so expressions that refer to
+// it are reported as unresolved.
+type planner struct {
+	interp  *Interpreter
+	execCtx *ExecutionContext
+	agents  map[string]bool
+	stack   []string // workflows being planned, for recursion detection
+}
+
+// Plan walks a workflow and returns the steps it would run without making
+// any LLM calls. Conditions and loop collections are evaluated against the
+// inputs where possible; branches that depend on agent output are marked
+// Conditional.
+func (i *Interpreter) Plan(name string, inputs map[string]any) (*Plan, error) {
+	if _, ok := i.doc.Workflows[name]; !ok {
+		return nil, vega.ErrWorkflowNotFound
+	}
+
+	p := &planner{interp: i, agents: make(map[string]bool)}
+	steps, err := p.planWorkflow(name, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Workflow: name, Steps: steps, Agents: []string{}}
+	for agent := range p.agents {
+		plan.Agents = append(plan.Agents, agent)
+	}
+	sort.Strings(plan.Agents)
+	return plan, nil
+}
+
+// planWorkflow plans a workflow's steps with a fresh variable scope.
+func (p *planner) planWorkflow(name string, inputs map[string]any) ([]PlanStep, error) {
+	wf, ok := p.interp.doc.Workflows[name]
+	if !ok {
+		return nil, fmt.Errorf("workflow %q not found", name)
+	}
+
+	scope := make(map[string]any)
+	for k, v := range inputs {
+		scope[k] = v
+	}
+	for inputName, def := range wf.Inputs {
+		if _, ok := scope[inputName]; !ok && def.Default != nil {
+			scope[inputName] = def.Default
+		}
+	}
+
+	saved := p.execCtx
+	p.execCtx = &ExecutionContext{Workflow: name, Inputs: scope, Variables: copyMap(scope)}
+	p.stack = append(p.stack, name)
+	defer func() {
+		p.execCtx = saved
+		p.stack = p.stack[:len(p.stack)-1]
+	}()
+
+	return p.planSteps(wf.Steps, true)
+}
+
+// planSteps plans a sequence of steps. reachable is false inside branches
+// that may not run, which keeps their set-steps from being treated as
+// known values afterwards.
+func (p *planner) planSteps(steps []Step, reachable bool) ([]PlanStep, error) {
+	out := make([]PlanStep, 0, len(steps))
+	for idx := range steps {
+		ps, err := p.planStep(&steps[idx], reachable)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ps)
+	}
+	return out, nil
+}
+
+// planStep plans a single step and everything nested in it.
+func (p *planner) planStep(step *Step, reachable bool) (PlanStep, error) {
+	ps := PlanStep{
+		Type:     stepType(step),
+		Agent:    step.Agent,
+		Model:    step.Model,
+		Save:     step.Save,
+		Workflow: step.Workflow,
+	}
+
+	if step.If != "" {
+		ps.Condition = step.If
+		if ok, known := p.resolveCondition(step.If); !known {
+			ps.Conditional = true
+			reachable = false
+		} else if ok {
+			ps.Branch = "then"
+		} else {
+			// Statically skipped; nothing below runs.
+			ps.Branch = "else"
+			return ps, nil
+		}
+	}
+
+	var err error
+	switch {
+	case step.Condition != "":
+		ps.Condition = step.Condition
+		taken, known := p.resolveCondition(step.Condition)
+		if !known {
+			ps.Conditional = true
+		} else if taken {
+			ps.Branch = "then"
+		} else {
+			ps.Branch = "else"
+		}
+		if !known || taken {
+			if ps.Steps, err = p.planSteps(step.Then, reachable && known); err != nil {
+				return ps, err
+			}
+		}
+		if !known || !taken {
+			if ps.Else, err = p.planSteps(step.Else, reachable && known); err != nil {
+				return ps, err
+			}
+		}
+
+	case len(step.Parallel) > 0:
+		ps.Steps, err = p.planSteps(step.Parallel, reachable)
+
+	case step.Repeat != nil:
+		ps.Loop = step.Repeat.Until
+		ps.MaxIterations = step.Repeat.Max
+		if ps.MaxIterations == 0 {
+			ps.MaxIterations = 100 // matches executeRepeat's safety limit
+		}
+		ps.Steps, err = p.planSteps(step.Repeat.Steps, reachable)
+
+	case step.ForEach != "" || step.Map != "" || step.Filter != "":
+		ps.Loop = step.ForEach + step.Map + step.Filter
+		itemVar, n := p.resolveLoop(ps.Loop)
+		ps.MaxIterations = n
+		// The item variable changes every iteration, so it isn't known.
+		delete(p.execCtx.Variables, itemVar)
+		// A loop over an unknown or empty collection may not run its body.
+		ps.Steps, err = p.planSteps(step.Steps, reachable && n > 0)
+
+	case step.Workflow != "":
+		for _, name := range p.stack {
+			if name == step.Workflow {
+				ps.Recursive = true
+			}
+		}
+		if !ps.Recursive {
+			inputs := make(map[string]any)
+			for k, v := range step.With {
+				if s, ok := v.(string); ok && ContainsExpression(s) {
+					if !p.known(s) {
+						continue
+					}
+					v, _ = p.interp.interpolate(s, p.execCtx)
+				}
+				inputs[k] = v
+			}
+			ps.Steps, err = p.planWorkflow(step.Workflow, inputs)
+		}
+
+	case step.Set != nil:
+		for k, v := range step.Set {
+			s, isStr := v.(string)
+			switch {
+			case !reachable:
+				delete(p.execCtx.Variables, k)
+			case isStr && ContainsExpression(s):
+				if p.known(s) {
+					p.execCtx.Variables[k], _ = p.interp.interpolate(s, p.execCtx)
+				} else {
+					delete(p.execCtx.Variables, k)
+				}
+			default:
+				p.execCtx.Variables[k] = v
+			}
+		}
+
+	case len(step.Try) > 0:
+		if ps.Steps, err = p.planSteps(step.Try, reachable); err != nil {
+			return ps, err
+		}
+		ps.Else, err = p.planSteps(step.Catch, false)
+
+	case step.Agent != "":
+		ps.Message = p.interpolateKnown(step.Send)
+		p.agents[step.Agent] = true
+	}
+
+	// Step results come from agents, so they are never statically known.
+	if step.Save != "" {
+		delete(p.execCtx.Variables, step.Save)
+	}
+	return ps, err
+}
+
+// resolveCondition evaluates cond if every name it refers to is known.
+func (p *planner) resolveCondition(cond string) (result, known bool) {
+	if !p.known(cond) {
+		return false, false
+	}
+	ok, err := p.interp.evaluateCondition(cond, p.execCtx)
+	if err != nil {
+		return false, false
+	}
+	return ok, true
+}
+
+// resolveLoop parses "item in items" and returns the item variable and the
+// collection size, or 0 when the collection isn't statically known.
+func (p *planner) resolveLoop(loopExpr string) (itemVar string, n int) {
+	parts := strings.SplitN(loopExpr, " in ", 2)
+	if len(parts) != 2 {
+		return "", 0
+	}
+	itemVar = strings.TrimSpace(parts[0])
+	collectionExpr := strings.Trim(strings.TrimSpace(parts[1]), "{} ")
+	if !p.known(collectionExpr) {
+		return itemVar, 0
+	}
+	collection, err := p.interp.evaluateExpression(collectionExpr, p.execCtx)
+	if err != nil {
+		return itemVar, 0
+	}
+	_, items, err := loopEntries(collection)
+	if err != nil {
+		return itemVar, 0
+	}
+	return itemVar, len(items)
+}
+
+// interpolateKnown resolves the {{...}} placeholders in template that only
+// refer to known values and leaves the rest untouched.
+func (p *planner) interpolateKnown(template string) string {
+	return exprPattern.ReplaceAllStringFunc(template, func(match string) string {
+		expr := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(match, "{{"), "}}"))
+		if !p.known(expr) {
+			return match
+		}
+		val, err := p.interp.evaluateExpression(expr, p.execCtx)
+		if err != nil {
+			return match
+		}
+		return fmt.Sprint(val)
+	})
+}
+
+// planIdentPattern matches variable references, including dotted paths.
+var planIdentPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z0-9_]+)*`)
+
+// planQuotedPattern matches quoted string literals in expressions.
+var planQuotedPattern = regexp.MustCompile(`'[^']*'|"[^"]*"`)
+
+// planKeywords are words in expressions that aren't variable references.
+var planKeywords = map[string]bool{
+	"in": true, "not": true, "and": true, "or": true,
+	"true": true, "false": true, "date": true, "time": true,
+}
+
+// known reports whether every variable expr refers to has a statically
+// known value. Filter names after a pipe don't count as references.
+func (p *planner) known(expr string) bool {
+	expr = planQuotedPattern.ReplaceAllString(expr, "")
+	for _, m := range exprPattern.FindAllStringSubmatch(expr, -1) {
+		if !p.known(m[1]) {
+			return false
+		}
+	}
+	expr = exprPattern.ReplaceAllString(expr, "")
+	if base, _, ok := strings.Cut(expr, "|"); ok {
+		expr = base
+	}
+
+	for _, ident := range planIdentPattern.FindAllString(expr, -1) {
+		root, _, _ := strings.Cut(ident, ".")
+		if planKeywords[root] {
+			continue
+		}
+		if _, ok := p.execCtx.Variables[root]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package dsl
+
+import (
+	"context"
+	"fmt"
+)
+
+// ResponsePostProcessor rewrites an agent's response before it is returned
+// or persisted, e.g. to strip internal markers, enforce formatting or
+// redact. Returning an error fails the response.
+type ResponsePostProcessor func(ctx context.Context, agentName, response string) (string, error)
+
+// AddResponsePostProcessor registers p to run on every agent response.
+// Processors run in the order they were added, each seeing the previous
+// one's output.
+func (i *Interpreter) AddResponsePostProcessor(p ResponsePostProcessor) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.postProcessors = append(i.postProcessors, p)
+}
+
+// PostProcessResponse runs the registered post-processors over an agent's
+// response. SendToAgent applies them itself; callers streaming a response
+// apply them once the stream completes.
+func (i *Interpreter) PostProcessResponse(ctx context.Context, agentName, response string) (string, error) {
+	i.mu.RLock()
+	processors := make([]ResponsePostProcessor, len(i.postProcessors))
+	copy(processors, i.postProcessors)
+	i.mu.RUnlock()
+
+	for n, p := range processors {
+		var err error
+		if response, err = p(ctx, agentName, response); err != nil {
+			return "", fmt.Errorf("response post-processor %d: %w", n+1, err)
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,89 @@
+package dsl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// templatePrompt is an agent system prompt whose YAML text holds {{...}}
+// placeholders. It is rendered on every call rather than once at spawn, so
+// a workflow step sees its own run's inputs and variables. prefix and
+// suffix are the knowledge, team and environment sections built around the
+// template; they are never rendered.
+type templatePrompt struct {
+	interp   *Interpreter
+	agent    string
+	prefix   string
+	template string
+	suffix   string
+}
+
+// Prompt renders the template against the interpreter's prompt variables
+// alone.
+func (t *templatePrompt) Prompt() string {
+	return t.prefix + t.interp.renderSystemPrompt(t.agent, t.template, nil) + t.suffix
+}
+
+// PromptContext renders the template for a call made with ctx, resolving
+// placeholders against the workflow run ctx carries before falling back to
+// the interpreter's prompt variables.
+func (t *templatePrompt) PromptContext(ctx context.Context) string {
+	return t.prefix + t.interp.renderSystemPrompt(t.agent, t.template, ExecutionFromContext(ctx)) + t.suffix
+}
+
+// SetPromptVar sets a variable for agent system prompt templates. It takes
+// effect on each agent's next call.
+func (i *Interpreter) SetPromptVar(key string, value any) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.promptVars == nil {
+		i.promptVars = make(map[string]any)
+	}
+	i.promptVars[key] = value
+}
+
+// renderSystemPrompt resolves {{...}} placeholders in an agent's system
+// prompt. A name is looked up in run's variables, then its inputs, then the
+// interpreter's prompt variables; date, time and agent (the agent's name)
+// are built in. run may be nil outside a workflow. Placeholders that cannot
+// be resolved are left untouched so literal braces in prompts survive.
+func (i *Interpreter) renderSystemPrompt(name, system string, run *ExecutionContext) string {
+	if !ContainsExpression(system) {
+		return system
+	}
+
+	i.mu.RLock()
+	vars := copyMap(i.promptVars)
+	i.mu.RUnlock()
+	if _, ok := vars["agent"]; !ok {
+		vars["agent"] = name
+	}
+	if run != nil {
+		for k, v := range run.Inputs {
+			vars[k] = v
+		}
+		for k, v := range run.Variables {
+			vars[k] = v
+		}
+	}
+	execCtx := &ExecutionContext{
+		Inputs:    map[string]any{},
+		Variables: vars,
+	}
+
+	return exprPattern.ReplaceAllStringFunc(system, func(match string) string {
+		expr := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(match, "{{"), "}}"))
+		base := strings.TrimSpace(strings.SplitN(expr, "|", 2)[0])
+		root, _, _ := strings.Cut(base, ".")
+		_, known := vars[root]
+		if !known && base != "date" && base != "time" {
+			return match
+		}
+		val, err := i.evaluateExpression(expr, execCtx)
+		if err != nil {
+			return match
+		}
+		return fmt.Sprint(val)
+	})
+}
@@ -3,13 +3,21 @@ package dsl
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/everydev1618/govega/llm"
 )
 
+// maxREPLHistory caps how many lines of input history the REPL keeps.
+const maxREPLHistory = 1000
+
 // REPL provides an interactive terminal chat for a Vega interpreter.
 type REPL struct {
 	interp       *Interpreter
@@ -17,6 +25,16 @@ type REPL struct {
 	out          io.Writer
 	prompt       string
 	sendTimeout  time.Duration
+	lineReader   LineReader
+	historyPath  string
+	history      []string
+}
+
+// LineReader reads one line of REPL input after showing prompt. history
+// holds earlier lines, oldest first, for readers that offer recall. It
+// returns io.EOF when the input ends.
+type LineReader interface {
+	ReadLine(prompt string, history []string) (string, error)
 }
 
 // REPLOption configures a REPL.
@@ -42,6 +60,18 @@ func WithREPLTimeout(d time.Duration) REPLOption {
 	return func(repl *REPL) { repl.sendTimeout = d }
 }
 
+// WithREPLLineReader sets how input lines are read, such as a terminal
+// line editor (default: lines scanned from the input reader).
+func WithREPLLineReader(lr LineReader) REPLOption {
+	return func(repl *REPL) { repl.lineReader = lr }
+}
+
+// WithREPLHistory loads input history from path and appends each line
+// entered to it, so history carries over between sessions.
+func WithREPLHistory(path string) REPLOption {
+	return func(repl *REPL) { repl.historyPath = path }
+}
+
 // NewREPL creates a new REPL for the given interpreter.
 func NewREPL(interp *Interpreter, opts ...REPLOption) *REPL {
 	repl := &REPL{
@@ -60,7 +90,10 @@ func NewREPL(interp *Interpreter, opts ...REPLOption) *REPL {
 // Run starts the interactive REPL loop.
 func (r *REPL) Run() {
 	doc := r.interp.Document()
-	scanner := bufio.NewScanner(r.in)
+	if r.lineReader == nil {
+		r.lineReader = &scanLineReader{scanner: bufio.NewScanner(r.in), out: r.out}
+	}
+	r.loadHistory()
 	var currentAgent string
 
 	// Auto-select if there's only one agent.
@@ -74,20 +107,24 @@ func (r *REPL) Run() {
 	}
 
 	for {
+		prompt := r.prompt + "> "
 		if currentAgent != "" {
-			fmt.Fprintf(r.out, "[%s]> ", currentAgent)
-		} else {
-			fmt.Fprintf(r.out, "%s> ", r.prompt)
+			prompt = "[" + currentAgent + "]> "
 		}
 
-		if !scanner.Scan() {
-			break
+		line, err := r.lineReader.ReadLine(prompt, r.history)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				fmt.Fprintf(r.out, "Error reading input: %v\n", err)
+			}
+			return
 		}
 
-		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
+		r.addHistory(line)
 
 		if strings.HasPrefix(line, "/") {
 			if r.handleCommand(line, &currentAgent) {
@@ -102,10 +139,64 @@ func (r *REPL) Run() {
 			fmt.Fprintln(r.out, "No agent selected. Use /ask <agent> to start a conversation.")
 		}
 	}
+}
+
+// scanLineReader is the default LineReader: it prints the prompt and
+// scans a line, without editing or recall.
+type scanLineReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
 
-	if err := scanner.Err(); err != nil {
-		fmt.Fprintf(r.out, "Error reading input: %v\n", err)
+func (s *scanLineReader) ReadLine(prompt string, history []string) (string, error) {
+	fmt.Fprint(s.out, prompt)
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
 	}
+	return s.scanner.Text(), nil
+}
+
+// loadHistory reads the last maxREPLHistory lines of the history file,
+// creating its directory so new lines can be saved.
+func (r *REPL) loadHistory() {
+	if r.historyPath == "" {
+		return
+	}
+	os.MkdirAll(filepath.Dir(r.historyPath), 0755)
+	data, err := os.ReadFile(r.historyPath)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+	r.history = r.history[max(0, len(r.history)-maxREPLHistory):]
+}
+
+// addHistory records line, skipping an immediate repeat, and appends it to
+// the history file.
+func (r *REPL) addHistory(line string) {
+	if n := len(r.history); n > 0 && r.history[n-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+	if len(r.history) > maxREPLHistory {
+		r.history = r.history[1:]
+	}
+	if r.historyPath == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
 }
 
 // handleCommand processes a slash command. Returns true if the REPL should exit.
@@ -169,12 +260,14 @@ func (r *REPL) handleCommand(line string, currentAgent *string) bool {
 
 	case "/run":
 		if len(parts) < 2 {
-			fmt.Fprintln(r.out, "Usage: /run <workflow> [task]")
+			fmt.Fprintln(r.out, "Usage: /run <workflow> [key=value ...] [task]")
 			return false
 		}
-		inputs := make(map[string]any)
-		if len(parts) > 2 {
-			inputs["task"] = strings.Join(parts[2:], " ")
+		args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line[len(cmd):]), parts[1]))
+		inputs, err := parseRunInputs(args)
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return false
 		}
 		ctx, cancel := context.WithTimeout(context.Background(), r.sendTimeout)
 		result, err := r.interp.Execute(ctx, parts[1], inputs)
@@ -185,6 +278,31 @@ func (r *REPL) handleCommand(line string, currentAgent *string) bool {
 			fmt.Fprintf(r.out, "Result:\n%v\n", result)
 		}
 
+	case "/save":
+		if len(parts) < 2 || *currentAgent == "" {
+			fmt.Fprintln(r.out, "Usage: /save <file> (in a conversation started with /ask)")
+			return false
+		}
+		n, err := r.saveSession(*currentAgent, parts[1])
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return false
+		}
+		fmt.Fprintf(r.out, "Saved %d messages with %s to %s.\n", n, *currentAgent, parts[1])
+
+	case "/load":
+		if len(parts) < 2 {
+			fmt.Fprintln(r.out, "Usage: /load <file>")
+			return false
+		}
+		agent, n, err := r.loadSession(parts[1])
+		if err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+			return false
+		}
+		*currentAgent = agent
+		fmt.Fprintf(r.out, "Restored %d messages with %s. Type /end to stop.\n", n, agent)
+
 	default:
 		fmt.Fprintf(r.out, "Unknown command: %s. Type /help for available commands.\n", cmd)
 	}
@@ -192,6 +310,123 @@ func (r *REPL) handleCommand(line string, currentAgent *string) bool {
 	return false
 }
 
+// replSession is the file format of /save and /load.
+type replSession struct {
+	Agent    string        `json:"agent"`
+	Messages []llm.Message `json:"messages"`
+}
+
+// saveSession writes agent's conversation to path and returns how many
+// messages it saved.
+func (r *REPL) saveSession(agent, path string) (int, error) {
+	var msgs []llm.Message
+	if proc, ok := r.interp.Agents()[agent]; ok {
+		msgs = proc.Messages()
+	}
+	data, err := json.MarshalIndent(replSession{Agent: agent, Messages: msgs}, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return 0, err
+	}
+	return len(msgs), nil
+}
+
+// loadSession replaces the saved agent's conversation with the one in the
+// file at path, returning the agent and how many messages it restored.
+func (r *REPL) loadSession(path string) (string, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", 0, err
+	}
+	var session replSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return "", 0, fmt.Errorf("%s is not a saved session: %w", path, err)
+	}
+	if doc := r.interp.Document(); doc == nil || doc.Agents[session.Agent] == nil {
+		return "", 0, fmt.Errorf("agent '%s' not found", session.Agent)
+	}
+
+	if _, err := r.interp.ResetAgent(session.Agent); err != nil {
+		return "", 0, err
+	}
+	proc, err := r.interp.EnsureAgent(session.Agent)
+	if err != nil {
+		return "", 0, err
+	}
+	proc.HydrateMessages(session.Messages)
+	return session.Agent, len(session.Messages), nil
+}
+
+// parseRunInputs parses the arguments of /run into workflow inputs.
+// key=value arguments set inputs, with values that parse as JSON (numbers,
+// booleans, lists) taking that type; any other words form the task.
+// Values with spaces can be quoted.
+func parseRunInputs(args string) (map[string]any, error) {
+	words, err := splitArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make(map[string]any)
+	var task []string
+	for _, word := range words {
+		key, value, ok := strings.Cut(word, "=")
+		if !ok || key == "" {
+			task = append(task, word)
+			continue
+		}
+		var typed any
+		if err := json.Unmarshal([]byte(value), &typed); err == nil {
+			inputs[key] = typed
+		} else {
+			inputs[key] = value
+		}
+	}
+	if len(task) > 0 {
+		inputs["task"] = strings.Join(task, " ")
+	}
+	return inputs, nil
+}
+
+// splitArgs splits s into words on spaces, keeping single- or
+// double-quoted text together and dropping the quotes.
+func splitArgs(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
 func (r *REPL) sendMessage(agent, message string) {
 	ctx, cancel := context.WithTimeout(context.Background(), r.sendTimeout)
 	defer cancel()
@@ -210,11 +445,16 @@ func (r *REPL) printHelp() {
   /workflows       List available workflows
   /ask <agent>     Start a conversation with an agent
   /end             End current conversation
-  /run <wf> [task] Run a workflow
+  /run <wf> [key=value ...] [task]
+                   Run a workflow, e.g. /run review topic="AI agents" words=500
+  /save <file>     Save the current conversation
+  /load <file>     Restore a saved conversation and continue it
   /help            Show this help
   /quit            Exit
 
 When in a conversation (after /ask):
   Type your message and press Enter to send it to the agent.
-  Use /end to stop the conversation.`)
+  Use /end to stop the conversation.
+
+Use the up and down arrows to recall earlier input.`)
 }
@@ -2,6 +2,9 @@ package dsl
 
 import (
 	"bytes"
+	"io"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -100,3 +103,146 @@ func TestREPLSingleAgentAutoSelect(t *testing.T) {
 		t.Errorf("expected auto-selection of single agent, got: %s", out.String())
 	}
 }
+
+func TestParseRunInputs(t *testing.T) {
+	inputs, err := parseRunInputs(`topic="AI agents" words=500 draft=true tags='["a", "b"]' write it up`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"topic": "AI agents",
+		"words": float64(500),
+		"draft": true,
+		"tags":  []any{"a", "b"},
+		"task":  "write it up",
+	}
+	if !reflect.DeepEqual(inputs, want) {
+		t.Errorf("inputs = %#v, want %#v", inputs, want)
+	}
+
+	if _, err := parseRunInputs(`topic="unclosed`); err == nil {
+		t.Error("expected an error for an unterminated quote")
+	}
+}
+
+func TestREPLRunWithInputs(t *testing.T) {
+	doc := mustParse(t, `
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  review:
+    inputs:
+      topic:
+        type: string
+      words:
+        type: number
+    steps:
+      - set:
+          done: true
+    output: "{{topic}} in {{words}} words"
+`)
+	interp, err := NewInterpreter(doc, WithLLM(&echoLLM{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	in := strings.NewReader("/end\n/run review topic=\"AI agents\" words=500\n/quit\n")
+	out := &bytes.Buffer{}
+	NewREPL(interp, WithREPLInput(in), WithREPLOutput(out)).Run()
+
+	if !strings.Contains(out.String(), "AI agents in 500 words") {
+		t.Errorf("expected the inputs in the result, got: %s", out.String())
+	}
+}
+
+func TestREPLSaveLoad(t *testing.T) {
+	doc := &Document{
+		Name:   "test",
+		Agents: map[string]*Agent{"alice": {Model: "test", System: "You help."}},
+	}
+	backend := &echoLLM{}
+	interp, err := NewInterpreter(doc, WithLLM(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	session := filepath.Join(t.TempDir(), "session.json")
+	in := strings.NewReader("remember the code word: falcon\n/save " + session + "\n/quit\n")
+	out := &bytes.Buffer{}
+	NewREPL(interp, WithREPLInput(in), WithREPLOutput(out)).Run()
+	if !strings.Contains(out.String(), "Saved 2 messages") {
+		t.Fatalf("expected the save to report 2 messages, got: %s", out.String())
+	}
+
+	// Start over, then restore the saved conversation and continue it.
+	if _, err := interp.ResetAgent("alice"); err != nil {
+		t.Fatal(err)
+	}
+	in = strings.NewReader("/end\n/load " + session + "\nwhat was the code word?\n/quit\n")
+	out.Reset()
+	NewREPL(interp, WithREPLInput(in), WithREPLOutput(out)).Run()
+	if !strings.Contains(out.String(), "Restored 2 messages with alice") {
+		t.Fatalf("expected the load to report 2 messages, got: %s", out.String())
+	}
+
+	backend.mu.Lock()
+	last := backend.requests[len(backend.requests)-1]
+	backend.mu.Unlock()
+	var sawEarlier bool
+	for _, m := range last {
+		if strings.Contains(m.Content, "falcon") {
+			sawEarlier = true
+		}
+	}
+	if !sawEarlier {
+		t.Errorf("continued conversation lacks the restored messages: %+v", last)
+	}
+}
+
+func TestREPLHistory(t *testing.T) {
+	doc := &Document{
+		Name:   "test",
+		Agents: map[string]*Agent{"alice": {Model: "test"}, "bob": {Model: "test"}},
+	}
+	interp, err := NewInterpreter(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "history")
+	in := strings.NewReader("/agents\n/agents\n/workflows\n/quit\n")
+	NewREPL(interp, WithREPLInput(in), WithREPLOutput(io.Discard), WithREPLHistory(path)).Run()
+
+	// A new session starts with the earlier lines, repeats collapsed.
+	reader := &recordingReader{lines: []string{"/quit"}}
+	NewREPL(interp, WithREPLLineReader(reader), WithREPLOutput(io.Discard), WithREPLHistory(path)).Run()
+	want := []string{"/agents", "/workflows", "/quit"}
+	if !reflect.DeepEqual(reader.history, want) {
+		t.Errorf("history = %q, want %q", reader.history, want)
+	}
+}
+
+// recordingReader is a LineReader that serves lines and records the
+// history it was offered on the first read.
+type recordingReader struct {
+	lines   []string
+	history []string
+}
+
+func (r *recordingReader) ReadLine(prompt string, history []string) (string, error) {
+	if r.history == nil {
+		r.history = append([]string{}, history...)
+	}
+	if len(r.lines) == 0 {
+		return "", io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return line, nil
+}
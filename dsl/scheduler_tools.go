@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/everydev1618/govega/tools"
 )
@@ -16,13 +17,22 @@ type SchedulerBackend interface {
 	ListJobs() []ScheduledJob
 }
 
-// ScheduledJob describes a recurring agent trigger.
+// ScheduledJob describes an agent trigger. A job either recurs on a cron
+// expression or, when RunAt is set, fires once at that instant and is then
+// removed.
 type ScheduledJob struct {
-	Name      string `json:"name"`
-	Cron      string `json:"cron"`      // standard 5-field cron expression
-	AgentName string `json:"agent"`     // agent to message on schedule
-	Message   string `json:"message"`   // message to send
-	Enabled   bool   `json:"enabled"`
+	Name      string    `json:"name"`
+	Cron      string    `json:"cron"`               // standard 5-field cron expression
+	Timezone  string    `json:"timezone,omitempty"` // IANA zone for Cron; empty means server local time
+	RunAt     time.Time `json:"run_at,omitzero"`    // one-shot fire time; mutually exclusive with Cron
+	AgentName string    `json:"agent"`              // agent to message on schedule
+	Message   string    `json:"message"`            // message to send
+	Enabled   bool      `json:"enabled"`
+}
+
+// OneShot reports whether the job fires once at RunAt instead of recurring.
+func (j ScheduledJob) OneShot() bool {
+	return !j.RunAt.IsZero()
 }
 
 // RegisterSchedulerTools registers the four schedule-management tools on
@@ -32,15 +42,27 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 	t := interp.Tools()
 
 	t.Register("create_schedule", tools.ToolDef{
-		Description: "Create a recurring schedule that sends a message to an agent on a cron expression. Use standard 5-field cron syntax (e.g. '0 9 * * *' for 9am daily).",
+		Description: "Create a schedule that sends a message to an agent. Give either 'cron' for a recurring schedule (standard 5-field syntax, e.g. '0 9 * * *' for 9am daily) or 'run_at' to fire once at a specific time.",
 		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
 			name, _ := params["name"].(string)
 			if name == "" {
 				return "", fmt.Errorf("name is required")
 			}
 			cronExpr, _ := params["cron"].(string)
-			if cronExpr == "" {
-				return "", fmt.Errorf("cron is required")
+			runAtStr, _ := params["run_at"].(string)
+			if cronExpr == "" && runAtStr == "" {
+				return "", fmt.Errorf("cron or run_at is required")
+			}
+			if cronExpr != "" && runAtStr != "" {
+				return "", fmt.Errorf("cron and run_at are mutually exclusive")
+			}
+			var runAt time.Time
+			if runAtStr != "" {
+				var err error
+				runAt, err = time.Parse(time.RFC3339, runAtStr)
+				if err != nil {
+					return "", fmt.Errorf("run_at must be an RFC 3339 timestamp: %w", err)
+				}
 			}
 			agent, _ := params["agent"].(string)
 			if agent == "" {
@@ -50,10 +72,13 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 			if message == "" {
 				return "", fmt.Errorf("message is required")
 			}
+			timezone, _ := params["timezone"].(string)
 
 			job := ScheduledJob{
 				Name:      name,
 				Cron:      cronExpr,
+				Timezone:  timezone,
+				RunAt:     runAt,
 				AgentName: agent,
 				Message:   message,
 				Enabled:   true,
@@ -61,6 +86,9 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 			if err := backend.AddJob(job); err != nil {
 				return "", fmt.Errorf("create schedule: %w", err)
 			}
+			if job.OneShot() {
+				return fmt.Sprintf("Schedule %q created: once at %s → agent '%s'", name, runAt.Format(time.RFC3339), agent), nil
+			}
 			return fmt.Sprintf("Schedule %q created: '%s' → agent '%s'", name, cronExpr, agent), nil
 		}),
 		Params: map[string]tools.ParamDef{
@@ -72,7 +100,10 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 			"cron": {
 				Type:        "string",
 				Description: "5-field cron expression (e.g. '0 9 * * *' for 9am daily, '*/30 * * * *' for every 30 minutes)",
-				Required:    true,
+			},
+			"run_at": {
+				Type:        "string",
+				Description: "RFC 3339 timestamp to fire once (e.g. '2026-03-01T15:00:00-05:00'). Use instead of cron for one-off reminders.",
 			},
 			"agent": {
 				Type:        "string",
@@ -84,6 +115,10 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 				Description: "Message to send to the agent on each tick",
 				Required:    true,
 			},
+			"timezone": {
+				Type:        "string",
+				Description: "IANA timezone the cron expression is evaluated in (e.g. 'America/New_York'). Defaults to server local time.",
+			},
 		},
 	})
 
@@ -111,6 +146,15 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 			// Apply updates.
 			if v, ok := params["cron"].(string); ok && v != "" {
 				existing.Cron = v
+				existing.RunAt = time.Time{}
+			}
+			if v, ok := params["run_at"].(string); ok && v != "" {
+				runAt, err := time.Parse(time.RFC3339, v)
+				if err != nil {
+					return "", fmt.Errorf("run_at must be an RFC 3339 timestamp: %w", err)
+				}
+				existing.RunAt = runAt
+				existing.Cron = ""
 			}
 			if v, ok := params["agent"].(string); ok && v != "" {
 				existing.AgentName = v
@@ -118,6 +162,9 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 			if v, ok := params["message"].(string); ok && v != "" {
 				existing.Message = v
 			}
+			if v, ok := params["timezone"].(string); ok && v != "" {
+				existing.Timezone = v
+			}
 			if v, ok := params["enabled"].(bool); ok {
 				existing.Enabled = v
 			}
@@ -141,6 +188,10 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 				Type:        "string",
 				Description: "New cron expression (leave empty to keep current)",
 			},
+			"run_at": {
+				Type:        "string",
+				Description: "New one-shot RFC 3339 fire time; replaces any cron expression",
+			},
 			"agent": {
 				Type:        "string",
 				Description: "New agent name (leave empty to keep current)",
@@ -149,6 +200,10 @@ func RegisterSchedulerTools(interp *Interpreter, backend SchedulerBackend) {
 				Type:        "string",
 				Description: "New message (leave empty to keep current)",
 			},
+			"timezone": {
+				Type:        "string",
+				Description: "New IANA timezone (leave empty to keep current)",
+			},
 			"enabled": {
 				Type:        "boolean",
 				Description: "Enable or disable the schedule",
@@ -0,0 +1,150 @@
+package dsl
+
+import (
+	"context"
+	"time"
+)
+
+// StepStatus is the lifecycle phase reported in a StepEvent.
+type StepStatus string
+
+const (
+	StepStarted   StepStatus = "started"
+	StepCompleted StepStatus = "completed"
+	StepFailed    StepStatus = "failed"
+	StepSkipped   StepStatus = "skipped"
+)
+
+// StepEvent describes the progress of a single workflow step.
+// Nested steps (inside if, loops, try, parallel) report the index of the
+// enclosing top-level step and a Depth greater than zero. Completed events
+// carry the step's result.
+type StepEvent struct {
+	RunID     string     `json:"run_id,omitempty"`
+	Workflow  string     `json:"workflow"`
+	Index     int        `json:"index"`
+	Depth     int        `json:"depth"`
+	Type      string     `json:"type"`
+	Agent     string     `json:"agent,omitempty"`
+	Save      string     `json:"save,omitempty"`
+	Status    StepStatus `json:"status"`
+	Result    any        `json:"result,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// StepOutput is a piece of an agent step's response, delivered as the
+// model generates it. Index and Depth identify the step as in StepEvent.
+type StepOutput struct {
+	RunID    string `json:"run_id,omitempty"`
+	Workflow string `json:"workflow"`
+	Index    int    `json:"index"`
+	Depth    int    `json:"depth"`
+	Agent    string `json:"agent"`
+	Delta    string `json:"delta"`
+}
+
+// runIDContextKey is the context key for the workflow run ID.
+type runIDContextKey struct{}
+
+// ContextWithRunID attaches a workflow run ID to ctx. Step events emitted
+// while running a workflow with this context carry the ID so observers can
+// tell concurrent runs apart.
+func ContextWithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDContextKey{}, runID)
+}
+
+// RunIDFromContext returns the workflow run ID attached to ctx, if any.
+func RunIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDContextKey{}).(string)
+	return id
+}
+
+// OnStep registers a callback invoked as each workflow step starts and
+// finishes. Callbacks run synchronously on the workflow goroutine and
+// should not block.
+func (i *Interpreter) OnStep(fn func(StepEvent)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.stepObservers = append(i.stepObservers, fn)
+}
+
+// OnStepOutput registers a callback that receives the responses of agent
+// steps as they stream in. While any callback is registered, agent steps
+// stream from the model instead of waiting for the whole response; steps
+// with a model override still return in one piece. Callbacks run
+// synchronously on the workflow goroutine and should not block.
+func (i *Interpreter) OnStepOutput(fn func(StepOutput)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.outputObservers = append(i.outputObservers, fn)
+}
+
+// stepOutputObservers returns a snapshot of the OnStepOutput callbacks.
+func (i *Interpreter) stepOutputObservers() []func(StepOutput) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return append([]func(StepOutput){}, i.outputObservers...)
+}
+
+// emitStep notifies step observers about a step transition.
+func (i *Interpreter) emitStep(step *Step, execCtx *ExecutionContext, status StepStatus, result any, err error) {
+	i.mu.RLock()
+	observers := make([]func(StepEvent), len(i.stepObservers))
+	copy(observers, i.stepObservers)
+	i.mu.RUnlock()
+	if len(observers) == 0 {
+		return
+	}
+
+	event := StepEvent{
+		RunID:     execCtx.RunID,
+		Workflow:  execCtx.Workflow,
+		Index:     execCtx.CurrentStep,
+		Depth:     execCtx.Depth,
+		Type:      stepType(step),
+		Agent:     step.Agent,
+		Save:      step.Save,
+		Status:    status,
+		Result:    result,
+		Timestamp: time.Now(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	for _, fn := range observers {
+		fn(event)
+	}
+}
+
+// stepType returns a short name for the kind of step.
+func stepType(step *Step) string {
+	switch {
+	case step.Condition != "":
+		return "if"
+	case len(step.Parallel) > 0:
+		return "parallel"
+	case step.Repeat != nil:
+		return "repeat"
+	case step.ForEach != "":
+		return "for"
+	case step.Map != "":
+		return "map"
+	case step.Filter != "":
+		return "filter"
+	case step.Workflow != "":
+		return "workflow"
+	case step.Set != nil:
+		return "set"
+	case step.Return != "":
+		return "return"
+	case len(step.Try) > 0:
+		return "try"
+	case step.Decide != "":
+		return "decide"
+	case step.Agent != "":
+		return "agent"
+	default:
+		return "noop"
+	}
+}
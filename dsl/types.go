@@ -1,7 +1,10 @@
 // Package dsl provides the Vega DSL parser and interpreter.
 package dsl
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // CompanySibling represents a sibling Vega instance for company switching.
 type CompanySibling struct {
@@ -63,9 +66,13 @@ type Agent struct {
 	Extends       string            `yaml:"extends"`
 	Model         string            `yaml:"model"`
 	FallbackModel string            `yaml:"fallback_model"`
+	Account       string            `yaml:"account"` // settings.accounts entry to bill; empty uses the default backend
 	System        string            `yaml:"system"`
 	Temperature *float64          `yaml:"temperature"`
 	Budget      string            `yaml:"budget"` // e.g., "$0.50"
+	MaxHistory  int               `yaml:"max_history"` // turns of history to keep
+	PersistChat *bool             `yaml:"persist_chat"` // nil means true
+	Memory      *bool             `yaml:"memory"`       // nil means true
 	Tools       []string          `yaml:"tools"`
 	Knowledge   []string          `yaml:"knowledge"`
 	Team        []string          `yaml:"team"`
@@ -77,6 +84,18 @@ type Agent struct {
 	Delegation     *DelegationDef     `yaml:"delegation"`
 }
 
+// PersistsChat reports whether the server should store the agent's chat
+// history and reload it into a fresh process. Off for stateless helpers.
+func (a *Agent) PersistsChat() bool {
+	return a.PersistChat == nil || *a.PersistChat
+}
+
+// UsesMemory reports whether the server should inject per-user memory into
+// the agent's prompt and extract memory from its chats.
+func (a *Agent) UsesMemory() bool {
+	return a.Memory == nil || *a.Memory
+}
+
 // DelegationDef configures context-aware delegation for an agent.
 type DelegationDef struct {
 	ContextWindow int      `yaml:"context_window"` // number of recent messages to forward
@@ -129,6 +148,7 @@ type Input struct {
 	Enum        []string `yaml:"enum"`
 	Min         *float64 `yaml:"min"`
 	Max         *float64 `yaml:"max"`
+	Sensitive   bool     `yaml:"sensitive"` // redacted from the workflow_state tool
 }
 
 // Step is a workflow step (can be various types).
@@ -145,6 +165,13 @@ type Step struct {
 	If              string        `yaml:"if"`
 	ContinueOnError bool          `yaml:"continue_on_error"`
 	Format          string        `yaml:"format"` // json, yaml, etc.
+	Context         []string      `yaml:"context"` // workflow variables exposed to the agent for this step
+	Model           string        `yaml:"model"`   // model for this step only; runs statelessly
+
+	// Decide fields: Decide names the agent, which must answer with
+	// exactly one of Outcomes
+	Decide   string   `yaml:"decide"`
+	Outcomes []string `yaml:"outcomes"`
 
 	// Control flow fields
 	Condition string  `yaml:"-"` // For if steps
@@ -152,11 +179,16 @@ type Step struct {
 	Else      []Step  `yaml:"else"`
 
 	// Loop fields
-	ForEach   string  `yaml:"for"` // "item in items"
+	ForEach   string  `yaml:"for"`    // "item in items"
+	Map       string  `yaml:"map"`    // "item in items", collects each body result
+	Filter    string  `yaml:"filter"` // "item in items", keeps items whose body/where is truthy
+	Where     string  `yaml:"where"`  // filter condition, instead of a body
+	Steps     []Step  `yaml:"steps"`  // body of a map/filter loop
 	Repeat    *Repeat `yaml:"repeat"`
 
 	// Parallel fields
 	Parallel []Step `yaml:"parallel"`
+	OnError  string `yaml:"on_error"` // "fail" (default) or "partial"
 
 	// Sub-workflow fields
 	Workflow    string         `yaml:"workflow"`
@@ -212,16 +244,27 @@ type ToolImpl struct {
 
 // Settings are global configuration.
 type Settings struct {
-	DefaultModel       string            `yaml:"default_model"`
-	DefaultTemperature *float64          `yaml:"default_temperature"`
-	Sandbox            string            `yaml:"sandbox"`
-	Budget             string            `yaml:"budget"`
-	Supervision        *SupervisionDef   `yaml:"supervision"`
-	RateLimit          *RateLimitDef     `yaml:"rate_limit"`
-	Logging            *LoggingDef       `yaml:"logging"`
-	Tracing            *TracingDef       `yaml:"tracing"`
-	MCP                *MCPDef           `yaml:"mcp"`
-	Skills             *GlobalSkillsDef  `yaml:"skills"`
+	DefaultModel       string                 `yaml:"default_model"`
+	DefaultTemperature *float64               `yaml:"default_temperature"`
+	Sandbox            string                 `yaml:"sandbox"`
+	Budget             string                 `yaml:"budget"` // default per-agent budget, e.g. "$10.00"
+	Supervision        *SupervisionDef        `yaml:"supervision"`
+	RateLimit          *RateLimitDef          `yaml:"rate_limit"`
+	Logging            *LoggingDef            `yaml:"logging"`
+	Tracing            *TracingDef            `yaml:"tracing"`
+	MCP                *MCPDef                `yaml:"mcp"`
+	Skills             *GlobalSkillsDef       `yaml:"skills"`
+	MaxToolResultBytes int                    `yaml:"max_tool_result_bytes"` // 0 keeps the tools default, negative disables
+	MaxToolArgBytes    int                    `yaml:"max_tool_arg_bytes"`    // 0 keeps the tools default, negative disables
+	Accounts           map[string]*AccountDef `yaml:"accounts"`
+}
+
+// AccountDef configures an LLM provider account that agents can select
+// with their account field, so each agent bills to its own API key.
+type AccountDef struct {
+	Provider string `yaml:"provider"` // "anthropic" (default) or "openai"
+	APIKey   string `yaml:"api_key"`  // $VAR and ${VAR} are expanded from the environment
+	BaseURL  string `yaml:"base_url"`
 }
 
 // MCPDef configures MCP servers.
@@ -268,6 +311,12 @@ type TracingDef struct {
 
 // ExecutionContext holds state during workflow execution.
 type ExecutionContext struct {
+	// Workflow is the name of the running workflow
+	Workflow string
+
+	// RunID identifies the run, when the caller provided one
+	RunID string
+
 	// Inputs are the workflow input values
 	Inputs map[string]any
 
@@ -277,6 +326,9 @@ type ExecutionContext struct {
 	// CurrentStep is the index of the executing step
 	CurrentStep int
 
+	// Depth is the nesting level of the executing step (0 = top level)
+	Depth int
+
 	// LoopState for loop iterations
 	LoopState *LoopState
 
@@ -285,12 +337,16 @@ type ExecutionContext struct {
 
 	// Timeout for the entire workflow
 	Timeout time.Duration
+
+	// mu guards Variables while parallel branches save their results
+	mu sync.Mutex
 }
 
 // LoopState tracks loop iteration state.
 type LoopState struct {
 	Index int
 	Count int
+	Key   any // map key, or index for arrays and line-split strings
 	Item  any
 	First bool
 	Last  bool
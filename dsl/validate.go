@@ -0,0 +1,169 @@
+package dsl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Validate checks that the document's workflows are coherent: every agent
+// step names a defined agent, every sub-workflow call names a defined
+// workflow and passes its required inputs, and every {{...}} expression
+// uses known filters. It also checks that every agent's account is
+// defined in settings. It reports every problem it finds, joined with
+// errors.Join; each is a *ValidationError. It returns nil if there are
+// none.
+func (d *Document) Validate() error {
+	v := &docValidator{doc: d}
+	v.accounts()
+	for _, name := range slices.Sorted(maps.Keys(d.Workflows)) {
+		wf := d.Workflows[name]
+		path := "workflows." + name
+		v.steps(path+".steps", wf.Steps)
+		v.expressions(path+".output", wf.Output)
+	}
+	return errors.Join(v.errs...)
+}
+
+// docValidator accumulates the problems Document.Validate finds.
+type docValidator struct {
+	doc  *Document
+	errs []error
+}
+
+func (v *docValidator) add(field, msg, hint string) {
+	v.errs = append(v.errs, &ValidationError{Field: field, Message: msg, Hint: hint})
+}
+
+// accounts checks the provider accounts in settings and the agents that
+// select them.
+func (v *docValidator) accounts() {
+	var accounts map[string]*AccountDef
+	if v.doc.Settings != nil {
+		accounts = v.doc.Settings.Accounts
+	}
+	for _, name := range slices.Sorted(maps.Keys(accounts)) {
+		switch provider := accounts[name].Provider; provider {
+		case "", "anthropic", "openai":
+		default:
+			v.add("settings.accounts."+name+".provider", fmt.Sprintf("unknown provider '%s'", provider), "Providers are: anthropic, openai")
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(v.doc.Agents)) {
+		agent := v.doc.Agents[name]
+		if agent == nil || agent.Account == "" {
+			continue
+		}
+		if _, ok := accounts[agent.Account]; !ok {
+			hint := "Define it under settings.accounts"
+			if similar := findSimilar(agent.Account, slices.Collect(maps.Keys(accounts))); similar != "" {
+				hint = fmt.Sprintf("Did you mean '%s'?", similar)
+			}
+			v.add("agents."+name+".account", fmt.Sprintf("unknown account '%s'", agent.Account), hint)
+		}
+	}
+}
+
+// steps validates each step of a list, and the steps nested in them.
+func (v *docValidator) steps(path string, steps []Step) {
+	for i := range steps {
+		v.step(fmt.Sprintf("%s[%d]", path, i), &steps[i])
+	}
+}
+
+func (v *docValidator) step(path string, step *Step) {
+	if step.Agent != "" {
+		if _, ok := v.doc.Agents[step.Agent]; !ok {
+			hint := ""
+			if similar := findSimilar(step.Agent, agentNames(v.doc)); similar != "" {
+				hint = fmt.Sprintf("Did you mean '%s'?", similar)
+			}
+			v.add(path, fmt.Sprintf("unknown agent '%s'", step.Agent), hint)
+		}
+	}
+
+	if step.Workflow != "" {
+		if wf, ok := v.doc.Workflows[step.Workflow]; !ok {
+			v.add(path+".workflow", fmt.Sprintf("unknown workflow '%s'", step.Workflow), "")
+		} else {
+			for _, input := range slices.Sorted(maps.Keys(wf.Inputs)) {
+				def := wf.Inputs[input]
+				if _, ok := step.With[input]; !ok && def.Required && def.Default == nil {
+					v.add(path+".with", fmt.Sprintf("required input '%s' of workflow '%s' is not provided", input, step.Workflow), "")
+				}
+			}
+		}
+	}
+
+	if step.Decide != "" {
+		if len(step.Outcomes) == 0 {
+			v.add(path+".outcomes", "decide step has no outcomes", "List the answers the agent may give, e.g. outcomes: [approve, reject]")
+		}
+		seen := make(map[string]bool)
+		for _, outcome := range step.Outcomes {
+			key := strings.ToLower(strings.TrimSpace(outcome))
+			if key == "" {
+				v.add(path+".outcomes", "empty outcome", "")
+			} else if seen[key] {
+				v.add(path+".outcomes", fmt.Sprintf("duplicate outcome '%s'", outcome), "Outcomes are matched case-insensitively")
+			}
+			seen[key] = true
+		}
+	}
+
+	switch step.OnError {
+	case "", "fail", "partial":
+	default:
+		v.add(path+".on_error", fmt.Sprintf("unknown on_error policy '%s'", step.OnError), "Policies are: fail, partial")
+	}
+
+	v.expressions(path+".send", step.Send)
+	v.expressions(path+".if", step.If)
+	if step.Condition != step.If {
+		v.expressions(path+".if", step.Condition)
+	}
+	v.expressions(path+".where", step.Where)
+	v.expressions(path+".return", step.Return)
+	v.expressions(path+".set", step.Set)
+	v.expressions(path+".with", step.With)
+
+	v.steps(path+".then", step.Then)
+	v.steps(path+".else", step.Else)
+	v.steps(path+".parallel", step.Parallel)
+	v.steps(path+".steps", step.Steps)
+	v.steps(path+".try", step.Try)
+	v.steps(path+".catch", step.Catch)
+	if step.Repeat != nil {
+		v.steps(path+".repeat.steps", step.Repeat.Steps)
+		v.expressions(path+".repeat.until", step.Repeat.Until)
+	}
+}
+
+// expressions checks the filters of every {{...}} expression in value,
+// which may be a string or a list or map holding strings.
+func (v *docValidator) expressions(path string, value any) {
+	switch val := value.(type) {
+	case string:
+		for _, expr := range ExtractExpressions(val) {
+			_, filter, ok := strings.Cut(expr, "|")
+			if !ok {
+				continue
+			}
+			name, _, _ := strings.Cut(filter, ":")
+			if name = strings.TrimSpace(name); !knownFilters[name] {
+				v.add(path, fmt.Sprintf("unknown filter '%s' in {{%s}}", name, expr),
+					"Filters are: "+strings.Join(slices.Sorted(maps.Keys(knownFilters)), ", "))
+			}
+		}
+	case []any:
+		for i, item := range val {
+			v.expressions(fmt.Sprintf("%s[%d]", path, i), item)
+		}
+	case map[string]any:
+		for _, key := range slices.Sorted(maps.Keys(val)) {
+			v.expressions(path+"."+key, val[key])
+		}
+	}
+}
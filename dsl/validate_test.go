@@ -0,0 +1,74 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDocumentValidate(t *testing.T) {
+	_, err := NewParser().Parse([]byte(`
+name: Test
+agents:
+  Writer:
+    model: test-model
+    system: You write.
+workflows:
+  main:
+    steps:
+      - Writr:
+          send: "{{topic | shout}}"
+      - if: "{{draft | upper}}"
+        then:
+          - workflow: polish
+          - workflow: missing
+      - try:
+          - Editor:
+              send: "{{draft | truncate:10}}"
+  polish:
+    inputs:
+      text:
+        type: string
+        required: true
+      tone:
+        type: string
+        required: true
+        default: plain
+    steps:
+      - Writer:
+          send: "{{text | trim}}"
+`))
+	if err == nil {
+		t.Fatal("Parse() accepted an invalid document")
+	}
+
+	var problems []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var ve *ValidationError
+		if !errors.As(e, &ve) {
+			t.Fatalf("problem %v is not a *ValidationError", e)
+		}
+		problems = append(problems, ve.Field+": "+ve.Message)
+	}
+	want := []string{
+		"workflows.main.steps[0]: unknown agent 'Writr'",
+		"workflows.main.steps[0].send: unknown filter 'shout' in {{topic | shout}}",
+		"workflows.main.steps[1].then[0].with: required input 'text' of workflow 'polish' is not provided",
+		"workflows.main.steps[1].then[1].workflow: unknown workflow 'missing'",
+		"workflows.main.steps[2].try[0]: unknown agent 'Editor'",
+	}
+	if got := strings.Join(problems, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("problems:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	// Documents built in code are checked too.
+	doc := &Document{
+		Agents: map[string]*Agent{"Writer": {Name: "Writer", Model: "test-model", System: "You write."}},
+		Workflows: map[string]*Workflow{
+			"main": {Steps: []Step{{Agent: "Ghost", Send: "hi"}}},
+		},
+	}
+	if _, err := NewInterpreter(doc, WithLLM(&echoLLM{}), WithLazySpawn()); err == nil || !strings.Contains(err.Error(), "unknown agent 'Ghost'") {
+		t.Errorf("NewInterpreter() error = %v, want unknown agent", err)
+	}
+}
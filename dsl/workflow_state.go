@@ -0,0 +1,203 @@
+package dsl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/everydev1618/govega/tools"
+)
+
+// executionContextKey is the context key for the workflow execution an
+// agent step runs in.
+type executionContextKey struct{}
+
+// ContextWithExecution returns a context carrying execCtx, so tools called
+// by the step's agent can see the workflow they are part of.
+func ContextWithExecution(ctx context.Context, execCtx *ExecutionContext) context.Context {
+	return context.WithValue(ctx, executionContextKey{}, execCtx)
+}
+
+// ExecutionFromContext returns the workflow execution carried by ctx, or
+// nil outside a workflow step.
+func ExecutionFromContext(ctx context.Context) *ExecutionContext {
+	execCtx, _ := ctx.Value(executionContextKey{}).(*ExecutionContext)
+	return execCtx
+}
+
+// redactedValue replaces the value of a sensitive variable.
+const redactedValue = "[redacted]"
+
+// maxStateValueLen caps each string in a workflow_state report, so one
+// large step output doesn't flood the agent's context.
+const maxStateValueLen = 2000
+
+// sensitiveNameParts mark a variable or map key as sensitive when its
+// words, split on "_", "-", "." and camelCase boundaries, contain one of them
+// as a whole word or run of words. A trailing "s" is allowed, so "tokens"
+// matches "token" but "author" does not match "auth".
+var sensitiveNameParts = [][]string{
+	{"password"}, {"passwd"}, {"secret"}, {"token"}, {"credential"}, {"auth"}, {"authorization"},
+	{"api", "key"}, {"apikey"}, {"private", "key"},
+}
+
+// isSensitiveName reports whether a variable or key name looks like it
+// holds a credential.
+func isSensitiveName(name string) bool {
+	words := nameWords(name)
+	for _, part := range sensitiveNameParts {
+		for start := 0; start+len(part) <= len(words); start++ {
+			if wordsMatch(words[start:start+len(part)], part) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wordsMatch reports whether words spell out part, allowing the last word
+// a plural "s".
+func wordsMatch(words, part []string) bool {
+	for i, want := range part {
+		if words[i] != want && (i != len(part)-1 || words[i] != want+"s") {
+			return false
+		}
+	}
+	return true
+}
+
+// nameWords splits a name into lowercase words on non-alphanumeric
+// characters and camelCase boundaries, keeping acronyms whole: "apiKey",
+// "api_key" and "APIKey" all give ["api" "key"].
+func nameWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := -1
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if start >= 0 {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				words = append(words, strings.ToLower(string(runes[start:i])))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, strings.ToLower(string(runes[start:])))
+	}
+	return words
+}
+
+// workflowState is the report returned by the workflow_state tool.
+type workflowState struct {
+	Workflow  string         `json:"workflow"`
+	RunID     string         `json:"run_id,omitempty"`
+	Step      int            `json:"step"`
+	Depth     int            `json:"depth"`
+	Elapsed   string         `json:"elapsed"`
+	Loop      map[string]any `json:"loop,omitempty"`
+	Variables map[string]any `json:"variables"`
+}
+
+// newWorkflowStateTool creates the read-only workflow_state tool. It reports
+// the variables and step position of the workflow the calling agent is
+// running in. Inputs declared sensitive, and names that look like
+// credentials at any depth, are redacted; long strings are truncated.
+func newWorkflowStateTool(interp *Interpreter) tools.ToolDef {
+	return tools.ToolDef{
+		Description: "Show the state of the workflow you are running in: its variables (inputs and saved step results) and which step is executing. Read-only.",
+		Fn: func(ctx context.Context, params map[string]any) (string, error) {
+			execCtx := ExecutionFromContext(ctx)
+			if execCtx == nil {
+				return "", fmt.Errorf("not running in a workflow")
+			}
+
+			sensitive := map[string]bool{}
+			if wf, ok := interp.Document().Workflows[execCtx.Workflow]; ok {
+				for name, input := range wf.Inputs {
+					if input.Sensitive {
+						sensitive[name] = true
+					}
+				}
+			}
+
+			state := workflowState{
+				Workflow:  execCtx.Workflow,
+				RunID:     execCtx.RunID,
+				Step:      execCtx.CurrentStep,
+				Depth:     execCtx.Depth,
+				Variables: map[string]any{},
+			}
+			if !execCtx.StartTime.IsZero() {
+				state.Elapsed = time.Since(execCtx.StartTime).Round(time.Second).String()
+			}
+			if ls := execCtx.LoopState; ls != nil {
+				state.Loop = map[string]any{
+					"index": ls.Index,
+					"count": ls.Count,
+					"first": ls.First,
+					"last":  ls.Last,
+					"item":  redactState(ls.Item),
+				}
+			}
+
+			execCtx.mu.Lock()
+			for name, v := range execCtx.Variables {
+				if sensitive[name] || isSensitiveName(name) {
+					state.Variables[name] = redactedValue
+				} else {
+					state.Variables[name] = redactState(v)
+				}
+			}
+			execCtx.mu.Unlock()
+
+			b, err := json.MarshalIndent(state, "", "  ")
+			if err != nil {
+				return "", err
+			}
+			return string(b), nil
+		},
+		Params: map[string]tools.ParamDef{},
+	}
+}
+
+// redactState returns a copy of v fit for the workflow_state report: map
+// entries with sensitive keys are redacted and long strings truncated.
+func redactState(v any) any {
+	switch val := v.(type) {
+	case string:
+		return truncateStr(val, maxStateValueLen)
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			if isSensitiveName(k) {
+				out[k] = redactedValue
+			} else {
+				out[k] = redactState(item)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for idx, item := range val {
+			out[idx] = redactState(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
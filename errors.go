@@ -32,6 +32,14 @@ var (
 	// ErrMaxProcessesReached is returned when orchestrator is at capacity
 	ErrMaxProcessesReached = errors.New("maximum number of processes reached")
 
+	// ErrSpawnDepthExceeded is returned when a spawn would nest deeper than
+	// the orchestrator's maximum spawn depth
+	ErrSpawnDepthExceeded = errors.New("maximum spawn depth exceeded")
+
+	// ErrSpawnRateExceeded is returned when a process spawns children faster
+	// than the orchestrator's spawn rate limit
+	ErrSpawnRateExceeded = errors.New("spawn rate limit exceeded")
+
 	// ErrProcessNotFound is returned when process ID is not found
 	ErrProcessNotFound = errors.New("process not found")
 
@@ -55,6 +63,26 @@ var (
 
 	// ErrGroupNotFound is returned when a process group doesn't exist
 	ErrGroupNotFound = errors.New("process group not found")
+
+	// ErrShuttingDown is returned by Spawn and Send once the orchestrator is draining or shut down
+	ErrShuttingDown = errors.New("orchestrator is shutting down")
+
+	// ErrMailboxFull is returned when a process's mailbox has no room for another message
+	ErrMailboxFull = errors.New("mailbox full")
+
+	// ErrContextWindowExceeded is returned when the model stops because the
+	// conversation no longer fits its context window
+	ErrContextWindowExceeded = errors.New("context window exceeded")
+
+	// ErrNoJobs is returned by JobQueue.Claim when no job is waiting
+	ErrNoJobs = errors.New("no pending jobs")
+
+	// ErrJobNotFound is returned when a job ID is not in the queue
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrClaimExpired is returned when a worker finishes a job whose claim
+	// timed out and passed to another worker
+	ErrClaimExpired = errors.New("job claim expired")
 )
 
 // ProcessError wraps errors with process context.
@@ -112,8 +140,23 @@ func ClassifyError(err error) ErrorClass {
 		return ErrClassTemporary
 	}
 
+	// Sentinel errors are checked first, since their messages can carry
+	// IDs or amounts that look like status codes.
+	if errors.Is(err, ErrBudgetExceeded) {
+		return ErrClassBudgetExceeded
+	}
+
 	errStr := strings.ToLower(err.Error())
 
+	// Refusals and context-window overflows usually arrive as 400s, so they
+	// are recognised by message before the status code is considered.
+	if isContextWindowError(errStr) {
+		return ErrClassContextWindow
+	}
+	if isRefusalError(errStr) {
+		return ErrClassRefusal
+	}
+
 	// Check for API errors with status codes
 	var apiErr *APIError
 	if errors.As(err, &apiErr) {
@@ -159,15 +202,30 @@ func ClassifyError(err error) ErrorClass {
 		return ErrClassInvalidRequest
 	}
 
-	// Check for budget exceeded
-	if errors.Is(err, ErrBudgetExceeded) {
-		return ErrClassBudgetExceeded
-	}
-
 	// Default to temporary (potentially retryable)
 	return ErrClassTemporary
 }
 
+// isContextWindowError reports whether a lowercased error message says the
+// request exceeded the model's context window.
+func isContextWindowError(errStr string) bool {
+	return strings.Contains(errStr, "prompt is too long") ||
+		strings.Contains(errStr, "context window") ||
+		strings.Contains(errStr, "context length") ||
+		strings.Contains(errStr, "context_length_exceeded") ||
+		strings.Contains(errStr, "maximum context")
+}
+
+// isRefusalError reports whether a lowercased error message says the model
+// declined to answer.
+func isRefusalError(errStr string) bool {
+	return strings.Contains(errStr, "refusal") ||
+		strings.Contains(errStr, "model refused") ||
+		strings.Contains(errStr, "declined to respond") ||
+		strings.Contains(errStr, "content_filter") ||
+		strings.Contains(errStr, "content filter")
+}
+
 // classifyStatusCode maps HTTP status codes to ErrorClass.
 func classifyStatusCode(code int) ErrorClass {
 	switch code {
@@ -181,6 +239,8 @@ func classifyStatusCode(code int) ErrorClass {
 		return ErrClassAuthentication
 	case http.StatusBadRequest:
 		return ErrClassInvalidRequest
+	case http.StatusRequestEntityTooLarge:
+		return ErrClassContextWindow
 	default:
 		if code >= 500 {
 			return ErrClassTemporary
@@ -194,7 +254,8 @@ func IsRetryable(class ErrorClass) bool {
 	switch class {
 	case ErrClassRateLimit, ErrClassOverloaded, ErrClassTimeout, ErrClassTemporary:
 		return true
-	case ErrClassInvalidRequest, ErrClassAuthentication, ErrClassBudgetExceeded:
+	case ErrClassInvalidRequest, ErrClassAuthentication, ErrClassBudgetExceeded,
+		ErrClassRefusal, ErrClassContextWindow:
 		return false
 	default:
 		return false
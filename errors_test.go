@@ -139,3 +139,30 @@ func TestErrorWrapping(t *testing.T) {
 		t.Errorf("Final unwrapped error = %v, want %v", unwrapped, baseErr)
 	}
 }
+
+func TestClassifyRefusalAndContextWindow(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"prompt too long", errors.New("anthropic: prompt is too long: 210000 tokens > 200000 maximum"), ErrClassContextWindow},
+		{"context length", errors.New("context_length_exceeded"), ErrClassContextWindow},
+		{"api 400 context", &APIError{StatusCode: 400, Message: "invalid_request_error: prompt is too long"}, ErrClassContextWindow},
+		{"api 413", &APIError{StatusCode: 413, Message: "request too large"}, ErrClassContextWindow},
+		{"refusal", errors.New("stop reason: refusal"), ErrClassRefusal},
+		{"api 400 refusal", &APIError{StatusCode: 400, Message: "the model refused to respond"}, ErrClassRefusal},
+		{"plain bad request", &APIError{StatusCode: 400, Message: "invalid_request_error: bad field"}, ErrClassInvalidRequest},
+		{"connection refused", errors.New("dial tcp: connection refused"), ErrClassTemporary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Errorf("ClassifyError(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+			if IsRetryable(tt.want) != (tt.want == ErrClassTemporary) {
+				t.Errorf("IsRetryable(%d) = %v", tt.want, IsRetryable(tt.want))
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 	"time"
 )
@@ -100,6 +101,27 @@ func WithCallbackURL(url string) OrchestratorOption {
 	}
 }
 
+// WithCallbackConfig configures callbacks from an existing CallbackConfig,
+// such as one built with NewCallbackConfig and shared with workers. If the
+// config has a Dir, the orchestrator polls it for events.
+func WithCallbackConfig(config *CallbackConfig) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.callbackConfig = config
+		if config != nil && config.Dir != "" {
+			os.MkdirAll(config.Dir, 0755)
+			o.startEventPoller()
+		}
+	}
+}
+
+// WithEventPoller feeds events from a caller-built poller into the
+// orchestrator. The poller is started here and stopped on Shutdown.
+func WithEventPoller(poller *EventPoller) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.consumeEvents(poller)
+	}
+}
+
 // PublishEvent sends an event to the orchestrator.
 // Used by workers to report their status.
 func PublishEvent(ctx context.Context, event Event, config *CallbackConfig) error {
@@ -169,8 +191,9 @@ type EventPoller struct {
 	stopped  bool
 }
 
-// newEventPoller creates a new event poller.
-func newEventPoller(dir string) *EventPoller {
+// NewEventPoller creates a poller that reads event files from dir. Pass it
+// to WithEventPoller to feed the events into an orchestrator.
+func NewEventPoller(dir string) *EventPoller {
 	return &EventPoller{
 		dir:    dir,
 		events: make(chan Event, 100),
@@ -295,7 +318,15 @@ func (o *Orchestrator) startEventPoller() {
 		return
 	}
 
-	poller := newEventPoller(o.callbackConfig.Dir)
+	o.consumeEvents(NewEventPoller(o.callbackConfig.Dir))
+}
+
+// consumeEvents starts poller and handles its events in the background,
+// replacing any poller the orchestrator already had.
+func (o *Orchestrator) consumeEvents(poller *EventPoller) {
+	if o.eventPoller != nil {
+		o.eventPoller.Stop()
+	}
 	events := poller.Start()
 
 	// Store poller for cleanup
@@ -309,8 +340,40 @@ func (o *Orchestrator) startEventPoller() {
 	}()
 }
 
+type workerEventCallback struct {
+	id uint64
+	fn func(Event)
+}
+
+// OnWorkerEvent registers a callback for every event a worker reports,
+// including events for jobs that have no process in this orchestrator.
+// Job workers report with the job ID as the event's ProcessID.
+func (o *Orchestrator) OnWorkerEvent(fn func(Event)) CallbackHandle {
+	o.callbackMu.Lock()
+	defer o.callbackMu.Unlock()
+	o.nextCallbackID++
+	id := o.nextCallbackID
+	o.onWorkerEvent = append(o.onWorkerEvent, workerEventCallback{id: id, fn: fn})
+
+	return CallbackHandle{remove: func() {
+		o.callbackMu.Lock()
+		defer o.callbackMu.Unlock()
+		o.onWorkerEvent = slices.DeleteFunc(o.onWorkerEvent, func(c workerEventCallback) bool { return c.id == id })
+	}}
+}
+
 // handleEvent processes an incoming event from a worker.
 func (o *Orchestrator) handleEvent(event Event) {
+	o.callbackMu.RLock()
+	callbacks := make([]func(Event), len(o.onWorkerEvent))
+	for i, c := range o.onWorkerEvent {
+		callbacks[i] = c.fn
+	}
+	o.callbackMu.RUnlock()
+	for _, fn := range callbacks {
+		fn(event)
+	}
+
 	// Find the process
 	p := o.Get(event.ProcessID)
 	if p == nil {
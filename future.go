@@ -52,6 +52,47 @@ func (f *Future) Cancel() {
 	}
 }
 
+// StreamFuture is a streaming generation started by SendStreamAsync.
+type StreamFuture struct {
+	stream *ChatStream
+	err    error
+	done   chan struct{}
+
+	cancel   context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Await waits for the generation to start and returns its stream. The
+// stream holds every event from the start of the generation, however late
+// Await is called.
+func (f *StreamFuture) Await(ctx context.Context) (*ChatStream, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-f.done:
+		return f.stream, f.err
+	}
+}
+
+// Done returns true once the stream is available or starting it failed.
+func (f *StreamFuture) Done() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Cancel stops the generation and discards any events not yet read.
+func (f *StreamFuture) Cancel() {
+	f.stopOnce.Do(func() {
+		f.cancel()
+		close(f.stop)
+	})
+}
+
 // Stream represents a streaming response.
 type Stream struct {
 	chunks   chan string
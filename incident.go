@@ -0,0 +1,108 @@
+package vega
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// maxIncidents caps the incidents an orchestrator keeps; the oldest are
+// dropped first.
+const maxIncidents = 100
+
+// Incident groups the deaths of a cascading failure: the process whose
+// failure started it and every linked process that died because of it.
+// Deaths are correlated by following the LinkedProcessError chain back to
+// the process that failed first.
+type Incident struct {
+	ID            string
+	RootProcessID string
+	RootAgent     string
+	RootError     string // empty if the root was killed rather than failed
+	Affected      []AffectedProcess
+	StartedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// AffectedProcess is a process that died in an incident's cascade.
+type AffectedProcess struct {
+	ProcessID string
+	Agent     string
+	LinkedID  string // the linked process whose death killed this one
+	DiedAt    time.Time
+}
+
+// Incidents returns the recorded incidents, newest first. Only cascades
+// produce incidents; a failure that takes no linked process with it does
+// not.
+func (o *Orchestrator) Incidents() []Incident {
+	o.incidentsMu.RLock()
+	defer o.incidentsMu.RUnlock()
+
+	out := make([]Incident, 0, len(o.incidents))
+	for i := len(o.incidents) - 1; i >= 0; i-- {
+		inc := *o.incidents[i]
+		inc.Affected = slices.Clone(inc.Affected)
+		out = append(out, inc)
+	}
+	return out
+}
+
+// recordLinkedDeath adds p, killed by a linked process's death, to the
+// incident of the cascade's root, opening the incident on the first death.
+func (o *Orchestrator) recordLinkedDeath(p *Process, linkErr *LinkedProcessError, at time.Time) {
+	rootID, rootErr := cascadeRoot(linkErr)
+
+	o.incidentsMu.Lock()
+	defer o.incidentsMu.Unlock()
+
+	var inc *Incident
+	for _, existing := range o.incidents {
+		if existing.RootProcessID == rootID {
+			inc = existing
+			break
+		}
+	}
+	if inc == nil {
+		inc = &Incident{
+			ID:            "incident-" + rootID,
+			RootProcessID: rootID,
+			StartedAt:     at,
+		}
+		if root := o.Get(rootID); root != nil && root.Agent != nil {
+			inc.RootAgent = root.Agent.Name
+		}
+		if rootErr != nil {
+			inc.RootError = rootErr.Error()
+		}
+		o.incidents = append(o.incidents, inc)
+		if len(o.incidents) > maxIncidents {
+			o.incidents = slices.Delete(o.incidents, 0, len(o.incidents)-maxIncidents)
+		}
+	}
+
+	agentName := ""
+	if p.Agent != nil {
+		agentName = p.Agent.Name
+	}
+	inc.Affected = append(inc.Affected, AffectedProcess{
+		ProcessID: p.ID,
+		Agent:     agentName,
+		LinkedID:  linkErr.LinkedID,
+		DiedAt:    at,
+	})
+	inc.UpdatedAt = at
+}
+
+// cascadeRoot follows a chain of LinkedProcessErrors to the process that
+// failed first and the error it failed with.
+func cascadeRoot(err *LinkedProcessError) (string, error) {
+	id, cause := err.LinkedID, err.OriginalError
+	for {
+		var next *LinkedProcessError
+		if !errors.As(cause, &next) {
+			return id, cause
+		}
+		id, cause = next.LinkedID, next.OriginalError
+	}
+}
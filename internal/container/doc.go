@@ -16,6 +16,14 @@
 // unavailable, Manager.IsAvailable() returns false and operations gracefully
 // degrade to local execution.
 //
+// # Cancellation
+//
+// Cancelling the context passed to Exec stops the command inside the
+// container, not just the wait for it: the command gets SIGTERM, then
+// SIGKILL once the grace period set by WithExecKillGrace has passed.
+// Tools run under a context that is cancelled when their process stops, so
+// stopping or killing a process also stops its in-flight container commands.
+//
 // # Usage with Tools
 //
 // The container package integrates with govega's Tools system through the
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -25,6 +26,10 @@ const (
 	LabelManagedBy     = "vega.managed-by"
 	DefaultImage       = "node:20-slim"
 	containerPrefix    = "vega-"
+
+	// DefaultExecKillGrace is how long a cancelled Exec command has to exit
+	// after SIGTERM before it is sent SIGKILL.
+	DefaultExecKillGrace = 5 * time.Second
 )
 
 // Manager handles Docker container operations for projects.
@@ -33,6 +38,7 @@ type Manager struct {
 	baseDir     string
 	networkName string
 	defaultImg  string
+	killGrace   time.Duration
 	mu          sync.RWMutex
 	available   bool
 }
@@ -54,6 +60,14 @@ func WithDefaultImage(img string) ManagerOption {
 	}
 }
 
+// WithExecKillGrace sets how long a cancelled Exec command has to exit
+// after SIGTERM before it is killed. Zero kills it immediately.
+func WithExecKillGrace(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.killGrace = d
+	}
+}
+
 // NewManager creates a new container manager.
 // If Docker is unavailable, it returns a Manager with available=false.
 func NewManager(baseDir string, opts ...ManagerOption) (*Manager, error) {
@@ -61,6 +75,7 @@ func NewManager(baseDir string, opts ...ManagerOption) (*Manager, error) {
 		baseDir:     baseDir,
 		networkName: DefaultNetworkName,
 		defaultImg:  DefaultImage,
+		killGrace:   DefaultExecKillGrace,
 		available:   false,
 	}
 
@@ -320,6 +335,9 @@ type ExecResult struct {
 
 // Exec runs a command in a project's container.
 // If the container doesn't exist, it will be auto-created with the default image.
+// Cancelling ctx stops the command inside the container too: it gets
+// SIGTERM, then SIGKILL after the kill grace period, and Exec returns
+// ctx.Err() without waiting for it.
 func (m *Manager) Exec(ctx context.Context, projectName string, command []string, workDir string) (*ExecResult, error) {
 	if !m.available {
 		return nil, fmt.Errorf("docker not available")
@@ -345,8 +363,11 @@ func (m *Manager) Exec(ctx context.Context, projectName string, command []string
 		workDir = "/workspace"
 	}
 
+	// Run the command under a shell that records its PID, so a cancelled
+	// exec can be killed; Docker has no API to stop an exec.
+	pidFile := fmt.Sprintf("/tmp/.vega-exec-%d.pid", time.Now().UnixNano())
 	execCfg := container.ExecOptions{
-		Cmd:          command,
+		Cmd:          append([]string{"sh", "-c", execWrapper, pidFile}, command...),
 		WorkingDir:   workDir,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -364,7 +385,19 @@ func (m *Manager) Exec(ctx context.Context, projectName string, command []string
 	defer attachResp.Close()
 
 	var stdout, stderr strings.Builder
-	_, err = stdcopy.StdCopy(&stdout, &stderr, attachResp.Reader)
+	copied := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&stdout, &stderr, attachResp.Reader)
+		copied <- err
+	}()
+	select {
+	case err = <-copied:
+	case <-ctx.Done():
+		m.killExec(containerID, pidFile)
+		attachResp.Close()
+		<-copied
+		return nil, ctx.Err()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to read output: %w", err)
 	}
@@ -381,6 +414,34 @@ func (m *Manager) Exec(ctx context.Context, projectName string, command []string
 	}, nil
 }
 
+// execWrapper runs "$@" in the background so its PID can be written to the
+// file named by $0, waits for it, then removes the file and exits with its
+// status.
+const execWrapper = `"$@" & pid=$!; echo "$pid" > "$0"; wait "$pid"; status=$?; rm -f "$0"; exit "$status"`
+
+// killScript sends SIGTERM to the PID in the file named by $0, then
+// SIGKILL after $1 seconds if it is still running.
+const killScript = `pid=$(cat "$0" 2>/dev/null) || exit 0; kill -TERM "$pid" 2>/dev/null || exit 0; sleep "$1"; kill -KILL "$pid" 2>/dev/null; true`
+
+// killExec stops the command an Exec started, using a detached exec so the
+// grace period doesn't hold up the caller. It runs on its own context since
+// the Exec's context is already cancelled.
+func (m *Manager) killExec(containerID, pidFile string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	grace := int((m.killGrace + time.Second - 1) / time.Second)
+	resp, err := m.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", killScript, pidFile, fmt.Sprint(grace)},
+	})
+	if err == nil {
+		err = m.client.ContainerExecStart(ctx, resp.ID, container.ExecStartOptions{Detach: true})
+	}
+	if err != nil {
+		slog.Warn("failed to stop cancelled container exec", "container", containerID, "error", err)
+	}
+}
+
 // GetLogs returns logs from a project's container.
 func (m *Manager) GetLogs(ctx context.Context, projectName string, tail int) (string, error) {
 	if !m.available {
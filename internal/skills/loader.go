@@ -15,6 +15,7 @@ type Loader struct {
 	skills      map[string]*Skill
 	include     []string
 	exclude     []string
+	version     uint64 // bumped whenever the skill set or filters change
 	mu          sync.RWMutex
 }
 
@@ -50,6 +51,7 @@ func WithConfig(config LoaderConfig) *Loader {
 func (l *Loader) Load(ctx context.Context) error {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.version++
 
 	for _, dir := range l.directories {
 		if err := l.scanDirectory(ctx, dir); err != nil {
@@ -210,12 +212,38 @@ func (l *Loader) Names() []string {
 	return names
 }
 
-// Match finds skills that match the given message.
+// Match finds skills that match the given message. Skills loaded before
+// the current filters were set are left out if the filters exclude them.
 func (l *Loader) Match(message string) []SkillMatch {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
 
-	return matchSkills(l.skills, message)
+	matches := matchSkills(l.skills, message)
+	kept := matches[:0]
+	for _, m := range matches {
+		if l.shouldInclude(m.Skill.Name) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
+}
+
+// Rank scores every skill by its relevance to message, matching the
+// message's words against each skill's name, description and tags as well
+// as its triggers. Skills with no relevance are left out, as are skills
+// the current filters exclude.
+func (l *Loader) Rank(message string) []SkillMatch {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	matches := rankSkills(l.skills, message)
+	kept := matches[:0]
+	for _, m := range matches {
+		if l.shouldInclude(m.Skill.Name) {
+			kept = append(kept, m)
+		}
+	}
+	return kept
 }
 
 // Count returns the number of loaded skills.
@@ -240,4 +268,21 @@ func (l *Loader) SetFilters(include, exclude []string) {
 	defer l.mu.Unlock()
 	l.include = include
 	l.exclude = exclude
+	l.version++
+}
+
+// Filters returns the current include/exclude filters.
+func (l *Loader) Filters() (include, exclude []string) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.include, l.exclude
+}
+
+// Version returns a number that changes whenever skills are loaded or the
+// filters change, so callers can tell when output derived from the skill
+// set is stale.
+func (l *Loader) Version() uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.version
 }
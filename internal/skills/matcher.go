@@ -1,9 +1,11 @@
 package skills
 
 import (
+	"math"
 	"regexp"
 	"sort"
 	"strings"
+	"unicode"
 )
 
 // matchSkills finds skills that match the given message.
@@ -18,9 +20,12 @@ func matchSkills(skills map[string]*Skill, message string) []SkillMatch {
 		}
 	}
 
-	// Sort by score descending
+	// Sort by score descending, then by name so the order is stable
 	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].Score > matches[j].Score
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Skill.Name < matches[j].Skill.Name
 	})
 
 	return matches
@@ -251,3 +256,103 @@ func hasAllTags(skillTags, requiredTags []string) bool {
 
 	return true
 }
+
+// rankSkills scores every skill against the message by relevance: how many
+// of the message's terms appear in the skill's name, description and tags,
+// as a cosine over the two term sets. A skill's trigger score counts when
+// it is higher, so keyword and always triggers still apply. Skills that
+// score nothing are left out; the rest are sorted like matchSkills.
+func rankSkills(skills map[string]*Skill, message string) []SkillMatch {
+	msgTerms := terms(message)
+	messageLower := strings.ToLower(message)
+
+	var matches []SkillMatch
+	for _, skill := range skills {
+		var score float64
+		var reason string
+		if m := matchSkill(skill, message, messageLower); m != nil {
+			score, reason = m.Score, m.Reason
+		}
+		if s, shared := relevance(skill, msgTerms); s > score {
+			score = s
+			reason = "relevant terms: " + strings.Join(shared, ", ")
+		}
+		if score > 0 {
+			matches = append(matches, SkillMatch{Skill: skill, Score: score, Reason: reason})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Skill.Name < matches[j].Skill.Name
+	})
+
+	return matches
+}
+
+// relevance returns the cosine similarity between the message terms and
+// the terms describing skill, and the terms they share in sorted order.
+func relevance(skill *Skill, msgTerms map[string]bool) (float64, []string) {
+	if len(msgTerms) == 0 {
+		return 0, nil
+	}
+	text := skill.Name + " " + skill.Description + " " + strings.Join(skill.Tags, " ")
+	skillTerms := terms(text)
+	if len(skillTerms) == 0 {
+		return 0, nil
+	}
+
+	var shared []string
+	for t := range msgTerms {
+		if skillTerms[t] {
+			shared = append(shared, t)
+		}
+	}
+	if len(shared) == 0 {
+		return 0, nil
+	}
+	sort.Strings(shared)
+	return float64(len(shared)) / math.Sqrt(float64(len(msgTerms)*len(skillTerms))), shared
+}
+
+// stopWords are common words left out of relevance terms.
+var stopWords = map[string]bool{
+	"and": true, "are": true, "but": true, "can": true, "could": true, "for": true,
+	"from": true, "have": true, "help": true, "how": true, "into": true, "its": true,
+	"not": true, "please": true, "should": true, "that": true, "the": true,
+	"them": true, "then": true, "this": true, "use": true, "what": true, "when": true,
+	"which": true, "will": true, "with": true, "would": true, "you": true, "your": true,
+}
+
+// terms splits text into a set of lowercased, roughly stemmed words of
+// three letters or more, leaving out stop words.
+func terms(text string) map[string]bool {
+	set := make(map[string]bool)
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if len(w) < 3 || stopWords[w] {
+			continue
+		}
+		set[stem(w)] = true
+	}
+	return set
+}
+
+// stem strips common English suffixes so "queries", "query" and "querying"
+// compare equal.
+func stem(word string) string {
+	for _, suffix := range []string{"ies", "ing", "ed", "es", "s"} {
+		if strings.HasSuffix(word, suffix) && len(word)-len(suffix) >= 3 {
+			word = strings.TrimSuffix(word, suffix)
+			if suffix == "ies" {
+				word += "y"
+			}
+			return word
+		}
+	}
+	return word
+}
@@ -111,6 +111,40 @@ type systemBlock struct {
 	CacheControl *cacheControl `json:"cache_control,omitempty"`
 }
 
+// maxSystemCacheBreakpoints is how many cache breakpoints a system prompt
+// may use. The API allows four per request, and one marks the tools.
+const maxSystemCacheBreakpoints = 3
+
+// CachesPrompts reports that Anthropic splits system prompts at their
+// CacheBreakpoints and caches each part.
+func (a *AnthropicLLM) CachesPrompts() bool {
+	return true
+}
+
+// systemBlocks splits a system prompt at its CacheBreakpoints into blocks
+// that are each marked for caching, so the prefix before a changing part
+// stays cached. Surplus leading parts are merged into one block.
+func systemBlocks(content string) []systemBlock {
+	parts := strings.Split(content, CacheBreakpoint)
+	if extra := len(parts) - maxSystemCacheBreakpoints; extra > 0 {
+		merged := strings.Join(parts[:extra+1], "\n\n")
+		parts = append([]string{merged}, parts[extra+1:]...)
+	}
+
+	var blocks []systemBlock
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		blocks = append(blocks, systemBlock{
+			Type:         "text",
+			Text:         part,
+			CacheControl: &cacheControl{Type: "ephemeral"},
+		})
+	}
+	return blocks
+}
+
 // thinkingBlock configures extended thinking for the API request.
 type thinkingBlock struct {
 	Type         string `json:"type"`          // "enabled"
@@ -201,7 +235,7 @@ func (a *AnthropicLLM) Generate(ctx context.Context, messages []Message, tools [
 	start := time.Now()
 
 	// Build request
-	req := a.buildRequest(messages, tools, false)
+	req := a.buildRequest(ModelFromContext(ctx), messages, tools, false)
 
 	// Make request
 	resp, err := a.doRequest(ctx, req)
@@ -216,7 +250,7 @@ func (a *AnthropicLLM) Generate(ctx context.Context, messages []Message, tools [
 // GenerateStream sends a request and returns a channel of streaming events.
 func (a *AnthropicLLM) GenerateStream(ctx context.Context, messages []Message, tools []ToolSchema) (<-chan StreamEvent, error) {
 	// Build request
-	req := a.buildRequest(messages, tools, true)
+	req := a.buildRequest(ModelFromContext(ctx), messages, tools, true)
 
 	// Make streaming request
 	eventCh := make(chan StreamEvent, 100)
@@ -293,25 +327,86 @@ func (a *AnthropicLLM) GenerateStream(ctx context.Context, messages []Message, t
 	return eventCh, nil
 }
 
+// countTokensRequest is the request format of the count_tokens endpoint.
+type countTokensRequest struct {
+	Model    string          `json:"model"`
+	Messages []anthropicMsg  `json:"messages"`
+	System   any             `json:"system,omitempty"`
+	Tools    []anthropicTool `json:"tools,omitempty"`
+	Thinking *thinkingBlock  `json:"thinking,omitempty"`
+}
+
+// CountTokens asks the API how many input tokens a request would use. The
+// count is free and doesn't generate anything.
+func (a *AnthropicLLM) CountTokens(ctx context.Context, messages []Message, tools []ToolSchema) (int, error) {
+	req := a.buildRequest(ModelFromContext(ctx), messages, tools, false)
+	body, err := json.Marshal(countTokensRequest{
+		Model:    req.Model,
+		Messages: req.Messages,
+		System:   req.System,
+		Tools:    req.Tools,
+		Thinking: req.Thinking,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", a.baseURL+"/v1/messages/count_tokens", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", a.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	httpResp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("http request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("read response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("API error %d: %s", httpResp.StatusCode, string(respBody))
+	}
+
+	var resp struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return 0, fmt.Errorf("unmarshal response: %w", err)
+	}
+	return resp.InputTokens, nil
+}
+
 // isThinkingModel returns true if the model supports extended thinking.
 func isThinkingModel(model string) bool {
 	return strings.Contains(model, "opus")
 }
 
-func (a *AnthropicLLM) buildRequest(messages []Message, tools []ToolSchema, stream bool) *anthropicRequest {
+// buildRequest builds an API request. A non-empty model overrides the
+// client's default for this request only.
+func (a *AnthropicLLM) buildRequest(model string, messages []Message, tools []ToolSchema, stream bool) *anthropicRequest {
+	if model == "" {
+		model = a.model
+	}
+
 	maxTokens := 8192
-	if isThinkingModel(a.model) {
+	if isThinkingModel(model) {
 		maxTokens = 16000
 	}
 
 	req := &anthropicRequest{
-		Model:     a.model,
+		Model:     model,
 		MaxTokens: maxTokens,
 		Stream:    stream,
 	}
 
 	// Enable extended thinking for capable models.
-	if isThinkingModel(a.model) {
+	if isThinkingModel(model) {
 		req.Thinking = &thinkingBlock{
 			Type:         "enabled",
 			BudgetTokens: 10000,
@@ -324,11 +419,9 @@ func (a *AnthropicLLM) buildRequest(messages []Message, tools []ToolSchema, stre
 	var anthropicMsgs []anthropicMsg
 	for _, msg := range messages {
 		if msg.Role == RoleSystem {
-			req.System = []systemBlock{{
-				Type:         "text",
-				Text:         msg.Content,
-				CacheControl: &cacheControl{Type: "ephemeral"},
-			}}
+			if blocks := systemBlocks(msg.Content); len(blocks) > 0 {
+				req.System = blocks
+			}
 			continue
 		}
 
@@ -638,6 +731,8 @@ func (a *AnthropicLLM) parseResponse(resp *anthropicResponse, latency time.Durat
 		result.StopReason = StopReasonLength
 	case "stop_sequence":
 		result.StopReason = StopReasonStop
+	case "model_context_window_exceeded":
+		result.StopReason = StopReasonContextExceeded
 	}
 
 	// Parse content blocks
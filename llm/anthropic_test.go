@@ -175,3 +175,30 @@ func TestExtractAttr(t *testing.T) {
 		t.Errorf("missing = %q", got)
 	}
 }
+
+func TestSystemCacheBreakpoints(t *testing.T) {
+	a := NewAnthropic(WithAPIKey("test"))
+	req := a.buildRequest("claude-haiku-4-5", []Message{
+		{Role: RoleSystem, Content: "Base prompt." + CacheBreakpoint + "# Active Skills\n..."},
+		{Role: RoleUser, Content: "hi"},
+	}, nil, false)
+
+	blocks, ok := req.System.([]systemBlock)
+	if !ok || len(blocks) != 2 {
+		t.Fatalf("system = %#v, want two blocks", req.System)
+	}
+	if blocks[0].Text != "Base prompt." || blocks[1].Text != "# Active Skills\n..." {
+		t.Errorf("blocks = %q / %q", blocks[0].Text, blocks[1].Text)
+	}
+	for _, b := range blocks {
+		if b.CacheControl == nil {
+			t.Errorf("block %q is not marked for caching", b.Text)
+		}
+	}
+
+	// Past the breakpoint limit, leading parts are merged.
+	many := strings.Repeat("part"+CacheBreakpoint, 4) + "last"
+	if n := len(systemBlocks(many)); n != maxSystemCacheBreakpoints {
+		t.Errorf("got %d blocks, want %d", n, maxSystemCacheBreakpoints)
+	}
+}
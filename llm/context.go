@@ -0,0 +1,18 @@
+package llm
+
+import "context"
+
+// modelContextKey is the context key for a per-request model override.
+type modelContextKey struct{}
+
+// ContextWithModel returns a context that asks backends to use model for
+// requests made with it instead of their configured default.
+func ContextWithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelContextKey{}, model)
+}
+
+// ModelFromContext returns the model override attached to ctx, if any.
+func ModelFromContext(ctx context.Context) string {
+	model, _ := ctx.Value(modelContextKey{}).(string)
+	return model
+}
@@ -160,7 +160,7 @@ type openaiStreamChunk struct {
 func (o *OpenAILLM) Generate(ctx context.Context, messages []Message, tools []ToolSchema) (*LLMResponse, error) {
 	start := time.Now()
 
-	req := o.buildRequest(messages, tools, false)
+	req := o.buildRequest(ModelFromContext(ctx), messages, tools, false)
 
 	resp, err := o.doRequest(ctx, req)
 	if err != nil {
@@ -170,9 +170,15 @@ func (o *OpenAILLM) Generate(ctx context.Context, messages []Message, tools []To
 	return o.parseResponse(resp, time.Since(start))
 }
 
+// CountTokens estimates the input tokens of a request. OpenAI-compatible
+// servers have no common counting endpoint, so this uses EstimateTokens.
+func (o *OpenAILLM) CountTokens(ctx context.Context, messages []Message, tools []ToolSchema) (int, error) {
+	return EstimateTokens(messages, tools), nil
+}
+
 // GenerateStream sends a request and returns a channel of streaming events.
 func (o *OpenAILLM) GenerateStream(ctx context.Context, messages []Message, tools []ToolSchema) (<-chan StreamEvent, error) {
-	req := o.buildRequest(messages, tools, true)
+	req := o.buildRequest(ModelFromContext(ctx), messages, tools, true)
 
 	eventCh := make(chan StreamEvent, 100)
 
@@ -216,9 +222,15 @@ func (o *OpenAILLM) GenerateStream(ctx context.Context, messages []Message, tool
 	return eventCh, nil
 }
 
-func (o *OpenAILLM) buildRequest(messages []Message, tools []ToolSchema, stream bool) *openaiRequest {
+// buildRequest builds an API request. A non-empty model overrides the
+// client's default for this request only.
+func (o *OpenAILLM) buildRequest(model string, messages []Message, tools []ToolSchema, stream bool) *openaiRequest {
+	if model == "" {
+		model = o.model
+	}
+
 	req := &openaiRequest{
-		Model:     o.model,
+		Model:     model,
 		MaxTokens: 8192,
 		Stream:    stream,
 	}
@@ -235,7 +247,7 @@ func (o *OpenAILLM) buildRequest(messages []Message, tools []ToolSchema, stream
 		if msg.Role == RoleSystem {
 			req.Messages = append(req.Messages, openaiMsg{
 				Role:    "system",
-				Content: msg.Content + toolNudge,
+				Content: StripCacheBreakpoints(msg.Content) + toolNudge,
 			})
 			toolNudge = "" // Only add once.
 			continue
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// messageOverheadTokens approximates the tokens each message adds for its
+// role and framing.
+const messageOverheadTokens = 4
+
+// EstimateTokens estimates the input tokens of a request by approximating
+// how Claude's tokenizer splits text (see EstimateTextTokens), plus a small
+// overhead per message. Tool schemas count as their JSON. It is meant for
+// gating decisions, such as whether a request fits a context window, not
+// billing: English prose lands close to the real count, while dense code
+// or unusual scripts can be further off.
+func EstimateTokens(messages []Message, tools []ToolSchema) int {
+	total := len(messages) * messageOverheadTokens
+	for _, msg := range messages {
+		total += EstimateTextTokens(msg.Content)
+	}
+	if len(tools) > 0 {
+		if data, err := json.Marshal(tools); err == nil {
+			total += EstimateTextTokens(string(data))
+		}
+	}
+	return total
+}
+
+// EstimateTextTokens approximates the tokens in text. Words are whole
+// tokens up to about eight bytes and split beyond that; a space before a
+// word rides along with it. Digits go in groups of three, and other
+// punctuation, symbols, line breaks and CJK characters are a token each.
+func EstimateTextTokens(text string) int {
+	tokens := 0
+	word, digits := 0, 0
+	flush := func() {
+		tokens += (word+7)/8 + (digits+2)/3
+		word, digits = 0, 0
+	}
+	for _, r := range text {
+		switch {
+		case isIdeograph(r):
+			flush()
+			tokens++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			if digits > 0 {
+				flush()
+			}
+			word += len(string(r))
+		case unicode.IsDigit(r):
+			if word > 0 {
+				flush()
+			}
+			digits++
+		case r == ' ':
+			flush()
+		case unicode.IsSpace(r):
+			flush()
+			if r == '\n' {
+				tokens++
+			}
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// isIdeograph reports whether r belongs to a script written without spaces,
+// where tokenizers spend about a token per character.
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
+
+// modelContextWindows lists context window sizes in tokens by model name
+// prefix. More specific prefixes come first.
+var modelContextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude-", 200_000},
+	{"gpt-4.1", 1_047_576},
+	{"gpt-4o", 128_000},
+	{"gpt-4-turbo", 128_000},
+	{"gpt-4", 8_192},
+	{"gpt-3.5-turbo", 16_385},
+	{"o1", 200_000},
+	{"o3", 200_000},
+	{"o4-mini", 200_000},
+}
+
+// ContextWindow returns the context window of model in tokens, or 0 if it
+// is not known.
+func ContextWindow(model string) int {
+	for _, w := range modelContextWindows {
+		if strings.HasPrefix(model, w.prefix) {
+			return w.tokens
+		}
+	}
+	return 0
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "You are a helpful assistant."},
+		{Role: RoleUser, Content: "What is the capital of France? Please answer in one word."},
+		{Role: RoleAssistant, Content: "Paris."},
+	}
+	// About 25 tokens of text plus a few per message for framing.
+	got := EstimateTokens(messages, nil)
+	if got < 25 || got > 45 {
+		t.Errorf("EstimateTokens = %d, want roughly 35", got)
+	}
+
+	tools := []ToolSchema{{
+		Name:        "get_weather",
+		Description: "Get the current weather for a city",
+		InputSchema: map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+	}}
+	if withTools := EstimateTokens(messages, tools); withTools <= got {
+		t.Errorf("tools added no tokens: %d vs %d", withTools, got)
+	}
+
+	long := []Message{{Role: RoleUser, Content: strings.Repeat("word ", 1000)}}
+	if n := EstimateTokens(long, nil); n < 1000 || n > 1500 {
+		t.Errorf("1000 words estimated at %d tokens", n)
+	}
+}
+
+func TestEstimateTextTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"Hello, world!", 4},
+		{"internationalization", 3},
+		{"1234567", 3},
+		{"line one\nline two", 5},
+		{"日本語", 3},
+	}
+	for _, tt := range tests {
+		if got := EstimateTextTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTextTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"claude-sonnet-4-20250514", 200_000},
+		{"gpt-4o-mini", 128_000},
+		{"gpt-4", 8_192},
+		{"llama3", 0},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.model); got != tt.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestAnthropicCountTokens(t *testing.T) {
+	var got countTokensRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages/count_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"input_tokens": 42}`))
+	}))
+	defer srv.Close()
+
+	a := NewAnthropic(WithBaseURL(srv.URL), WithAPIKey("test"), WithModel("claude-haiku-4-5"))
+	n, err := a.CountTokens(context.Background(), []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "hi"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("CountTokens = %d, want 42", n)
+	}
+	if got.Model != "claude-haiku-4-5" || len(got.Messages) != 1 || got.System == nil {
+		t.Errorf("request = %+v, want model, one message and the system prompt", got)
+	}
+
+	srv.Close()
+	if _, err := a.CountTokens(context.Background(), []Message{{Role: RoleUser, Content: "hi"}}, nil); err == nil {
+		t.Error("expected an error when the API is unreachable")
+	}
+}
@@ -1,6 +1,9 @@
 package llm
 
-import "context"
+import (
+	"context"
+	"strings"
+)
 
 // LLM is the interface for language model backends.
 type LLM interface {
@@ -9,6 +12,11 @@ type LLM interface {
 
 	// GenerateStream sends a request and returns a channel of streaming events.
 	GenerateStream(ctx context.Context, messages []Message, tools []ToolSchema) (<-chan StreamEvent, error)
+
+	// CountTokens returns the number of input tokens a request with these
+	// messages and tools would use. Backends without a counting API return
+	// EstimateTokens.
+	CountTokens(ctx context.Context, messages []Message, tools []ToolSchema) (int, error)
 }
 
 // Message represents a conversation message.
@@ -26,6 +34,25 @@ const (
 	RoleSystem    Role = "system"
 )
 
+// CacheBreakpoint splits a system message into parts that are cached
+// separately. Backends with prompt caching cache the prompt up to each
+// breakpoint, so a stable prefix stays cached when a later part changes.
+// Backends without it read the breakpoint as a paragraph break.
+const CacheBreakpoint = "\n<!-- cache_breakpoint -->\n"
+
+// PromptCacher is implemented by backends that read CacheBreakpoint in
+// system messages. Vega strips the breakpoints from system messages before
+// sending them to a backend that doesn't implement it or returns false.
+type PromptCacher interface {
+	CachesPrompts() bool
+}
+
+// StripCacheBreakpoints replaces the cache breakpoints in content with
+// paragraph breaks.
+func StripCacheBreakpoints(content string) string {
+	return strings.ReplaceAll(content, CacheBreakpoint, "\n\n")
+}
+
 // LLMResponse is the response from an LLM call.
 type LLMResponse struct {
 	// Content is the text response
@@ -73,6 +100,9 @@ const (
 	StopReasonLength   StopReason = "max_tokens"
 	StopReasonStop     StopReason = "stop_sequence"
 	StopReasonFiltered StopReason = "content_filter"
+	// StopReasonContextExceeded means the conversation filled the model's
+	// context window before it finished.
+	StopReasonContextExceeded StopReason = "model_context_window_exceeded"
 )
 
 // StreamEvent is an event from streaming generation.
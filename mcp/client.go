@@ -234,6 +234,61 @@ func (c *Client) ReadResource(ctx context.Context, uri string) (string, error) {
 	return "", fmt.Errorf("no text content in resource")
 }
 
+// DiscoverPrompts retrieves the list of prompts from the server.
+func (c *Client) DiscoverPrompts(ctx context.Context) ([]MCPPrompt, error) {
+	c.mu.RLock()
+	if !c.connected {
+		c.mu.RUnlock()
+		return nil, fmt.Errorf("not connected")
+	}
+	c.mu.RUnlock()
+
+	result, err := c.transport.Send(ctx, "prompts/list", nil)
+	if err != nil {
+		return nil, fmt.Errorf("prompts/list: %w", err)
+	}
+
+	var listResult PromptsListResult
+	if err := json.Unmarshal(result, &listResult); err != nil {
+		return nil, fmt.Errorf("parse prompts list: %w", err)
+	}
+
+	c.mu.Lock()
+	c.prompts = listResult.Prompts
+	c.mu.Unlock()
+
+	return listResult.Prompts, nil
+}
+
+// GetPrompt renders a prompt on the server and returns its messages as
+// text, each headed by its role.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (string, error) {
+	c.mu.RLock()
+	if !c.connected {
+		c.mu.RUnlock()
+		return "", fmt.Errorf("not connected")
+	}
+	c.mu.RUnlock()
+
+	result, err := c.transport.Send(ctx, "prompts/get", PromptGetParams{Name: name, Arguments: args})
+	if err != nil {
+		return "", fmt.Errorf("prompts/get: %w", err)
+	}
+
+	var getResult PromptGetResult
+	if err := json.Unmarshal(result, &getResult); err != nil {
+		return "", fmt.Errorf("parse prompt: %w", err)
+	}
+
+	var parts []string
+	for _, msg := range getResult.Messages {
+		if msg.Content.Type == "text" {
+			parts = append(parts, fmt.Sprintf("[%s]\n%s", msg.Role, msg.Content.Text))
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
 // Close closes the connection to the server.
 func (c *Client) Close() error {
 	c.mu.Lock()
@@ -266,6 +321,34 @@ func (c *Client) Tools() []MCPTool {
 	return c.tools
 }
 
+// Resources returns the cached resources list.
+func (c *Client) Resources() []MCPResource {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.resources
+}
+
+// Prompts returns the cached prompts list.
+func (c *Client) Prompts() []MCPPrompt {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.prompts
+}
+
+// HasResources reports whether the server advertised resource support.
+func (c *Client) HasResources() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.serverInfo != nil && c.serverInfo.Capabilities.Resources != nil
+}
+
+// HasPrompts reports whether the server advertised prompt support.
+func (c *Client) HasPrompts() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.serverInfo != nil && c.serverInfo.Capabilities.Prompts != nil
+}
+
 // ServerInfo returns information about the connected server.
 func (c *Client) ServerInfo() *ServerInfo {
 	c.mu.RLock()
@@ -287,5 +370,11 @@ func (c *Client) handleNotification(method string, params json.RawMessage) {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
 		c.DiscoverResources(ctx)
+
+	case "notifications/prompts/list_changed":
+		// Re-discover prompts
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		c.DiscoverPrompts(ctx)
 	}
 }
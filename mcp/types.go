@@ -41,6 +41,7 @@ type Client struct {
 	transport Transport
 	tools     []MCPTool
 	resources []MCPResource
+	prompts   []MCPPrompt
 	connected bool
 	serverInfo *ServerInfo
 	mu        sync.RWMutex
@@ -62,6 +63,20 @@ type MCPResource struct {
 	MimeType    string `json:"mimeType,omitempty"`
 }
 
+// MCPPrompt represents a prompt template provided by an MCP server.
+type MCPPrompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes an argument a prompt template accepts.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
 // ServerConfig configures an MCP server connection.
 type ServerConfig struct {
 	// Name is a human-readable identifier for the server.
@@ -243,6 +258,29 @@ type ResourceContent struct {
 	Blob     string `json:"blob,omitempty"` // Base64
 }
 
+// PromptsListResult is the result of prompts/list.
+type PromptsListResult struct {
+	Prompts []MCPPrompt `json:"prompts"`
+}
+
+// PromptGetParams are the parameters for prompts/get.
+type PromptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+// PromptGetResult is the result of prompts/get.
+type PromptGetResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptMessage is one message of a rendered prompt.
+type PromptMessage struct {
+	Role    string       `json:"role"`
+	Content ContentBlock `json:"content"`
+}
+
 // Error codes
 const (
 	ErrCodeParse          = -32700
@@ -3,6 +3,7 @@ package vega
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -753,6 +754,48 @@ func TestSupervisorRestForOneStrategy(t *testing.T) {
 	}
 }
 
+func TestSupervisorRestartsOnExitSignal(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+
+	sup := o.NewSupervisor(SupervisorSpec{
+		Strategy: OneForOne,
+		Children: []ChildSpec{
+			{Name: "fast", Agent: Agent{Name: "Worker"}, Restart: Transient},
+		},
+	})
+	if err := sup.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sup.Stop()
+
+	first := o.GetByName("fast")
+	first.Fail(errors.New("crash"))
+
+	// The restart is driven by the exit signal, so it should land well
+	// inside the old 100ms polling interval.
+	deadline := time.Now().Add(50 * time.Millisecond)
+	for {
+		if p := o.GetByName("fast"); p != nil && p.ID != first.ID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("child was not restarted within 50ms of failing")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// A normal exit of a transient child is not restarted.
+	second := o.GetByName("fast")
+	second.Complete("done")
+	time.Sleep(50 * time.Millisecond)
+	if total, _, _ := sup.CountChildren(); total != 1 {
+		t.Fatalf("children = %d, want 1", total)
+	}
+	if sup.Children()[0].ID != second.ID {
+		t.Error("transient child was restarted after a normal exit")
+	}
+}
+
 func TestSupervisorPermanentRestart(t *testing.T) {
 	o := NewOrchestrator(WithLLM(&mockLLM{}))
 
@@ -910,6 +953,81 @@ func TestSupervisorMaxRestartsExceeded(t *testing.T) {
 	}
 }
 
+func TestSupervisorStatus(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+
+	sup := o.NewSupervisor(SupervisorSpec{
+		Strategy:    OneForOne,
+		MaxRestarts: 2,
+		Window:      time.Minute,
+		Children: []ChildSpec{
+			{Name: "flaky", Agent: Agent{Name: "Worker"}, Restart: Permanent},
+			{Name: "steady", Agent: Agent{Name: "Worker"}, Restart: Permanent},
+		},
+	})
+	if err := sup.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// crash fails the flaky child and waits for its replacement.
+	crash := func() {
+		t.Helper()
+		old := o.GetByName("flaky")
+		old.Fail(errors.New("crash"))
+		deadline := time.Now().Add(time.Second)
+		for {
+			if p := o.GetByName("flaky"); p != nil && p.ID != old.ID {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("flaky child was not restarted")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	crash()
+	crash()
+
+	if got := o.Supervisors(); len(got) != 1 || got[0] != sup {
+		t.Fatalf("Supervisors() = %v, want the one supervisor", got)
+	}
+	st := sup.Status()
+	if st.Strategy != OneForOne || st.MaxRestarts != 2 || st.Restarts != 2 || st.GaveUp {
+		t.Errorf("status = %+v, want one_for_one with 2 restarts and not given up", st)
+	}
+	want := map[string]int{"flaky": 2, "steady": 0}
+	for _, child := range st.Children {
+		if child.Restarts != want[child.Name] || child.Status != StatusRunning {
+			t.Errorf("child %s: %d restarts, status %s; want %d restarts, running", child.Name, child.Restarts, child.Status, want[child.Name])
+		}
+	}
+
+	// A third crash exceeds the intensity: the supervisor gives up but
+	// stays listed with its children's final state.
+	o.GetByName("flaky").Fail(errors.New("crash"))
+	deadline := time.Now().Add(time.Second)
+	for !sup.Status().GaveUp {
+		if time.Now().After(deadline) {
+			t.Fatal("supervisor did not give up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	st = sup.Status()
+	if len(o.Supervisors()) != 1 || len(st.Children) != 2 {
+		t.Errorf("after giving up: %d supervisors listed, %d children, want 1 and 2", len(o.Supervisors()), len(st.Children))
+	}
+	for _, child := range st.Children {
+		if child.Status == StatusRunning {
+			t.Errorf("child %s still running after the supervisor gave up", child.Name)
+		}
+	}
+
+	sup.Stop()
+	if got := o.Supervisors(); len(got) != 0 {
+		t.Errorf("Supervisors() after Stop = %v, want none", got)
+	}
+}
+
 func TestSupervisorBackoff(t *testing.T) {
 	o := NewOrchestrator(WithLLM(&mockLLM{}))
 
@@ -1292,6 +1410,50 @@ done:
 	}
 }
 
+func TestCascadingFailureRecordsOneIncident(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+
+	supervisor, _ := o.Spawn(Agent{Name: "Supervisor"})
+	supervisor.SetTrapExit(true)
+
+	workers := make([]*Process, 3)
+	for i := range workers {
+		workers[i], _ = o.Spawn(Agent{Name: fmt.Sprintf("Worker%d", i)})
+		supervisor.Link(workers[i])
+	}
+	workers[0].Link(workers[1])
+	workers[1].Link(workers[2])
+
+	// An unrelated failure with no links is not an incident
+	loner, _ := o.Spawn(Agent{Name: "Loner"})
+	loner.Fail(errors.New("alone"))
+
+	workers[0].Fail(errors.New("crash"))
+
+	incidents := o.Incidents()
+	if len(incidents) != 1 {
+		t.Fatalf("got %d incidents, want 1: %+v", len(incidents), incidents)
+	}
+	inc := incidents[0]
+	if inc.RootProcessID != workers[0].ID || inc.RootAgent != "Worker0" || inc.RootError != "crash" {
+		t.Errorf("root = %s/%s/%q, want %s/Worker0/\"crash\"", inc.RootProcessID, inc.RootAgent, inc.RootError, workers[0].ID)
+	}
+
+	affected := map[string]string{}
+	for _, a := range inc.Affected {
+		affected[a.ProcessID] = a.LinkedID
+	}
+	if len(affected) != 2 || len(inc.Affected) != 2 {
+		t.Fatalf("affected = %+v, want workers 1 and 2", inc.Affected)
+	}
+	if affected[workers[1].ID] != workers[0].ID || affected[workers[2].ID] != workers[1].ID {
+		t.Errorf("affected = %+v, want worker1 killed by worker0 and worker2 by worker1", inc.Affected)
+	}
+	if _, ok := affected[supervisor.ID]; ok {
+		t.Error("supervisor traps exits and should not be affected")
+	}
+}
+
 // =============================================================================
 // DYNAMIC CHILD MANAGEMENT TESTS
 // =============================================================================
@@ -4,11 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/everydev1618/govega/internal/container"
 	"github.com/everydev1618/govega/llm"
 	"github.com/everydev1618/govega/tools"
 )
@@ -75,6 +80,10 @@ func (m *toolCallingLLM) GenerateStream(ctx context.Context, messages []llm.Mess
 	return ch, nil
 }
 
+func (m *toolCallingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
 // failingLLM returns errors to test fault tolerance
 type failingLLM struct {
 	failCount    int32 // number of times to fail before succeeding
@@ -108,6 +117,10 @@ func (m *failingLLM) GenerateStream(ctx context.Context, messages []llm.Message,
 	return ch, nil
 }
 
+func (m *failingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
 // =============================================================================
 // SUPERVISION & FAULT TOLERANCE TESTS
 // =============================================================================
@@ -480,6 +493,92 @@ func TestAgenticToolExecution(t *testing.T) {
 		}
 	})
 
+	t.Run("web_search results flow back to the model", func(t *testing.T) {
+		ts := tools.NewTools(tools.WithSearchProvider(tools.SearchProviderFunc(
+			func(ctx context.Context, query string, limit int) ([]tools.SearchResult, error) {
+				return []tools.SearchResult{
+					{Title: "Seattle Weather", URL: "https://weather.example/seattle", Snippet: "Rain all week"},
+				}, nil
+			})))
+		ts.RegisterBuiltins()
+
+		llm := &toolCallingLLM{
+			responses: []*llm.LLMResponse{
+				{
+					ToolCalls: []llm.ToolCall{
+						{ID: "call-1", Name: "web_search", Arguments: map[string]any{"query": "seattle weather"}},
+					},
+				},
+				{Content: "It will rain all week."},
+			},
+		}
+
+		o := NewOrchestrator(WithLLM(llm))
+		proc, err := o.Spawn(Agent{Name: "researcher", Tools: ts.Filter("web_search")})
+		if err != nil {
+			t.Fatalf("Spawn failed: %v", err)
+		}
+		if _, err := proc.Send(context.Background(), "What's the weather in Seattle?"); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+
+		llm.mu.Lock()
+		defer llm.mu.Unlock()
+		if len(llm.calls) < 2 {
+			t.Fatalf("LLM called %d times, want 2", len(llm.calls))
+		}
+		var sawResult bool
+		for _, m := range llm.calls[1] {
+			if strings.Contains(m.Content, "https://weather.example/seattle") && strings.Contains(m.Content, "Rain all week") {
+				sawResult = true
+			}
+		}
+		if !sawResult {
+			t.Errorf("search results not in the model's next prompt: %+v", llm.calls[1])
+		}
+	})
+
+	t.Run("sanitizes tool output that isn't valid UTF-8", func(t *testing.T) {
+		ts := tools.NewTools()
+		ts.Register("cat", func(path string) string {
+			return "caf\xe9 menu"
+		})
+		ts.Register("dump", func(path string) (string, error) {
+			return "", errors.New("command failed: \x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+		})
+
+		llm := &toolCallingLLM{
+			responses: []*llm.LLMResponse{
+				{ToolCalls: []llm.ToolCall{
+					{ID: "call-1", Name: "cat", Arguments: map[string]any{"path": "menu.txt"}},
+					{ID: "call-2", Name: "dump", Arguments: map[string]any{"path": "logo.png"}},
+				}},
+				{Content: "Done."},
+			},
+		}
+		o := NewOrchestrator(WithLLM(llm))
+		proc, err := o.Spawn(Agent{Name: "reader", Tools: ts})
+		if err != nil {
+			t.Fatalf("Spawn failed: %v", err)
+		}
+		if _, err := proc.Send(context.Background(), "Read the files"); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+
+		llm.mu.Lock()
+		defer llm.mu.Unlock()
+		last := llm.calls[1][len(llm.calls[1])-1].Content
+		if !utf8.ValidString(last) {
+			t.Fatalf("tool results sent to the model are not valid UTF-8: %q", last)
+		}
+		if !strings.Contains(last, "caf\uFFFD menu") {
+			t.Errorf("invalid byte not replaced: %q", last)
+		}
+		if !strings.Contains(last, "[binary output:") {
+			t.Errorf("binary error output not encoded: %q", last)
+		}
+	})
+
 	t.Run("handles multiple tool calls in sequence", func(t *testing.T) {
 		ts := tools.NewTools()
 		var callOrder []string
@@ -622,6 +721,121 @@ func TestAgenticToolExecution(t *testing.T) {
 	})
 }
 
+// fakeContainer is a tools.ContainerExecutor whose commands run until
+// cancelled.
+type fakeContainer struct {
+	started   chan []string
+	cancelled chan struct{}
+}
+
+func (f *fakeContainer) IsAvailable() bool { return true }
+
+func (f *fakeContainer) Exec(ctx context.Context, project string, command []string, workDir string) (*container.ExecResult, error) {
+	f.started <- command
+	<-ctx.Done()
+	close(f.cancelled)
+	return nil, ctx.Err()
+}
+
+func TestStopCancelsContainerExec(t *testing.T) {
+	fake := &fakeContainer{started: make(chan []string, 1), cancelled: make(chan struct{})}
+	ts := tools.NewTools(tools.WithContainer(fake), tools.WithContainerRouting("run_command"))
+	ts.Register("run_command", func(command string) string { return "ran locally" })
+	ts.SetProject("site")
+
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{ToolCalls: []llm.ToolCall{
+			{ID: "call-1", Name: "run_command", Arguments: map[string]any{"command": "npm run build"}},
+		}},
+	}}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+	proc, err := o.Spawn(Agent{Name: "builder", Tools: ts})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		proc.Send(context.Background(), "Build the site")
+		close(sent)
+	}()
+
+	select {
+	case cmd := <-fake.started:
+		if strings.Join(cmd, " ") != "npm run build" {
+			t.Errorf("container ran %q", cmd)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("container exec never started")
+	}
+
+	proc.Stop()
+
+	select {
+	case <-fake.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopping the process did not cancel the container exec")
+	}
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not return after the exec was cancelled")
+	}
+}
+
+func TestContainerRegistryFailureFallsBackToLocal(t *testing.T) {
+	// A file where the registry wants a directory makes NewProjectRegistry fail.
+	baseDir := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(baseDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cm := &container.Manager{} // Docker unavailable
+
+	ts := tools.NewTools(tools.WithContainer(cm), tools.WithContainerRouting("run_command"))
+	ranLocally := make(chan string, 1)
+	ts.Register("run_command", func(command string) string {
+		ranLocally <- command
+		return "ran locally"
+	})
+	ts.SetProject("site")
+
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{ToolCalls: []llm.ToolCall{
+			{ID: "call-1", Name: "run_command", Arguments: map[string]any{"command": "npm run build"}},
+		}},
+		{Content: "Built"},
+	}}
+	o := NewOrchestrator(WithLLM(mock), WithContainerManager(cm, baseDir))
+	defer o.Shutdown(context.Background())
+
+	if o.GetProjectRegistry() != nil {
+		t.Error("GetProjectRegistry() is set after registry creation failed")
+	}
+	if o.ProjectRegistryErr() == nil {
+		t.Error("ProjectRegistryErr() = nil, want the registry creation failure")
+	}
+
+	proc, err := o.Spawn(Agent{Name: "builder", Tools: ts}, WithProject("site"))
+	if err != nil {
+		t.Fatalf("Spawn: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := proc.Send(ctx, "Build the site"); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	select {
+	case cmd := <-ranLocally:
+		if cmd != "npm run build" {
+			t.Errorf("ran %q locally, want npm run build", cmd)
+		}
+	default:
+		t.Error("run_command did not fall back to local execution")
+	}
+}
+
 func TestToolMiddleware(t *testing.T) {
 	t.Run("middleware wraps tool execution", func(t *testing.T) {
 		ts := tools.NewTools()
@@ -877,6 +1091,48 @@ func TestProcessCompleteIdempotent(t *testing.T) {
 	}
 }
 
+func TestCallbackHandleRemove(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{response: "test"}))
+
+	var completed, failed, started int32
+	hc := o.OnProcessComplete(func(p *Process, result string) {
+		atomic.AddInt32(&completed, 1)
+	})
+	hf := o.OnProcessFailed(func(p *Process, err error) {
+		atomic.AddInt32(&failed, 1)
+	})
+	hs := o.OnProcessStarted(func(p *Process) {
+		atomic.AddInt32(&started, 1)
+	})
+
+	first, _ := o.Spawn(Agent{Name: "first"})
+	first.Complete("done")
+	second, _ := o.Spawn(Agent{Name: "second"})
+	second.Fail(errors.New("boom"))
+	time.Sleep(50 * time.Millisecond)
+
+	if atomic.LoadInt32(&completed) != 1 || atomic.LoadInt32(&failed) != 1 || atomic.LoadInt32(&started) != 2 {
+		t.Fatalf("before Remove: completed=%d failed=%d started=%d, want 1, 1, 2",
+			completed, failed, started)
+	}
+
+	hc.Remove()
+	hf.Remove()
+	hs.Remove()
+	hc.Remove() // second Remove is a no-op
+
+	third, _ := o.Spawn(Agent{Name: "third"})
+	third.Complete("done")
+	fourth, _ := o.Spawn(Agent{Name: "fourth"})
+	fourth.Fail(errors.New("boom"))
+	time.Sleep(50 * time.Millisecond)
+
+	if atomic.LoadInt32(&completed) != 1 || atomic.LoadInt32(&failed) != 1 || atomic.LoadInt32(&started) != 2 {
+		t.Errorf("after Remove: completed=%d failed=%d started=%d, want 1, 1, 2",
+			completed, failed, started)
+	}
+}
+
 func TestProcessFailIdempotent(t *testing.T) {
 	llm := &mockLLM{response: "test"}
 	o := NewOrchestrator(WithLLM(llm))
@@ -1573,6 +1829,10 @@ func (m *contextAwareLLM) GenerateStream(ctx context.Context, messages []llm.Mes
 	return ch, nil
 }
 
+func (m *contextAwareLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
 func TestAsyncWorkflow(t *testing.T) {
 	llm := &toolCallingLLM{
 		generateDelay: 50 * time.Millisecond,
@@ -1641,6 +1901,111 @@ func TestStreamingWorkflow(t *testing.T) {
 	}
 }
 
+func TestSendStreamAsync(t *testing.T) {
+	t.Run("completes without a reader or caller", func(t *testing.T) {
+		// More chunks than the stream buffer holds, so an unread stream
+		// would stall the generation.
+		chunks := make([]string, DefaultStreamBufferSize*2)
+		for i := range chunks {
+			chunks[i] = "x"
+		}
+		o := NewOrchestrator(WithLLM(&streamingLLM{chunks: chunks}))
+		proc, _ := o.Spawn(Agent{Name: "worker"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		future := proc.SendStreamAsync(ctx, "stream this")
+		cancel() // the caller goes away
+
+		waitCtx, waitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer waitCancel()
+		stream, err := future.Await(waitCtx)
+		if err != nil {
+			t.Fatalf("Await failed: %v", err)
+		}
+		if !future.Done() {
+			t.Error("future should be done after Await")
+		}
+
+		want := strings.Repeat("x", len(chunks))
+		if got := stream.Response(); got != want || stream.Err() != nil {
+			t.Fatalf("Response = %d chars, err = %v; want %d chars", len(got), stream.Err(), len(want))
+		}
+		msgs := proc.Messages()
+		if last := msgs[len(msgs)-1]; last.Role != llm.RoleAssistant || last.Content != want {
+			t.Errorf("response not recorded in history: %+v", last)
+		}
+
+		// Events read after the fact are all still there.
+		var deltas int
+		for ev := range stream.Events() {
+			if ev.Type == ChatEventTextDelta {
+				deltas++
+			}
+		}
+		if deltas != len(chunks) {
+			t.Errorf("got %d deltas, want %d", deltas, len(chunks))
+		}
+	})
+
+	t.Run("cancel stops the generation", func(t *testing.T) {
+		o := NewOrchestrator(WithLLM(hangingStreamLLM{}))
+		proc, _ := o.Spawn(Agent{Name: "worker"})
+
+		future := proc.SendStreamAsync(context.Background(), "never ends")
+		stream, err := future.Await(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		future.Cancel()
+
+		select {
+		case <-stream.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("generation did not stop after Cancel")
+		}
+		if !errors.Is(stream.Err(), context.Canceled) {
+			t.Errorf("Err = %v, want context.Canceled", stream.Err())
+		}
+		for range stream.Events() {
+			// Closes once the relay notices the cancel.
+		}
+	})
+
+	t.Run("start errors surface from Await", func(t *testing.T) {
+		o := NewOrchestrator(WithLLM(&streamingLLM{}))
+		proc, _ := o.Spawn(Agent{Name: "worker"})
+		proc.Complete("done")
+
+		_, err := proc.SendStreamAsync(context.Background(), "hi").Await(context.Background())
+		if !errors.Is(err, ErrProcessNotRunning) {
+			t.Errorf("Await error = %v, want ErrProcessNotRunning", err)
+		}
+	})
+}
+
+// hangingStreamLLM streams one chunk and then blocks until its context ends.
+type hangingStreamLLM struct{}
+
+func (hangingStreamLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (hangingStreamLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	ch := make(chan llm.StreamEvent, 2)
+	go func() {
+		defer close(ch)
+		ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: "partial"}
+		<-ctx.Done()
+		ch <- llm.StreamEvent{Error: ctx.Err()}
+	}()
+	return ch, nil
+}
+
+func (hangingStreamLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
 // streamingLLM is an LLM that properly implements streaming
 type streamingLLM struct {
 	chunks []string
@@ -1665,6 +2030,100 @@ func (m *streamingLLM) GenerateStream(ctx context.Context, messages []llm.Messag
 	return ch, nil
 }
 
+func (m *streamingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+// toolStreamingLLM streams a tool call on its first turn and text afterwards.
+type toolStreamingLLM struct {
+	mu    sync.Mutex
+	turns int
+}
+
+func (m *toolStreamingLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	return &llm.LLMResponse{Content: "done"}, nil
+}
+
+func (m *toolStreamingLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	m.mu.Lock()
+	m.turns++
+	turn := m.turns
+	m.mu.Unlock()
+
+	ch := make(chan llm.StreamEvent, 8)
+	go func() {
+		defer close(ch)
+		if turn == 1 {
+			ch <- llm.StreamEvent{Type: llm.StreamEventToolStart, ToolCall: &llm.ToolCall{ID: "call-1", Name: "search"}}
+			ch <- llm.StreamEvent{Type: llm.StreamEventToolDelta, Delta: `{"query":"go"}`}
+			ch <- llm.StreamEvent{Type: llm.StreamEventContentEnd}
+			ch <- llm.StreamEvent{Type: llm.StreamEventToolStart, ToolCall: &llm.ToolCall{ID: "call-2", Name: "fail"}}
+			ch <- llm.StreamEvent{Type: llm.StreamEventContentEnd}
+			return
+		}
+		ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: "found it"}
+	}()
+	return ch, nil
+}
+
+func (m *toolStreamingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestSendStreamRichToolProgressEvents(t *testing.T) {
+	ts := tools.NewTools()
+	ts.Register("search", func(query string) string { return "result for " + query })
+	ts.Register("fail", func(ctx context.Context, params map[string]any) (string, error) {
+		return "", errors.New("boom")
+	})
+
+	o := NewOrchestrator(WithLLM(&toolStreamingLLM{}))
+	proc, err := o.Spawn(Agent{Name: "searcher", Tools: ts})
+	if err != nil {
+		t.Fatalf("Spawn failed: %v", err)
+	}
+
+	stream, err := proc.SendStreamRich(context.Background(), "find go")
+	if err != nil {
+		t.Fatalf("SendStreamRich failed: %v", err)
+	}
+
+	var calls []string
+	results := make(map[string]ChatEvent)
+	var sawEndBeforeResult bool
+	for event := range stream.Events() {
+		switch event.Type {
+		case ChatEventToolCall:
+			if event.Status != ToolStatusPending {
+				t.Errorf("tool_call status = %q, want %q", event.Status, ToolStatusPending)
+			}
+			calls = append(calls, event.ToolName)
+		case ChatEventToolResult:
+			results[event.ToolName] = event
+		case ChatEventToolEnd:
+			if _, ok := results[event.ToolName]; !ok {
+				sawEndBeforeResult = true
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "search" || calls[1] != "fail" {
+		t.Errorf("tool_call events = %v, want [search fail]", calls)
+	}
+	if r := results["search"]; r.Status != ToolStatusOK || r.ToolCallID != "call-1" {
+		t.Errorf("search tool_result = %+v, want status ok", r)
+	}
+	if r := results["fail"]; r.Status != ToolStatusError || !strings.Contains(r.Error, "boom") {
+		t.Errorf("fail tool_result = %+v, want status error with message", r)
+	}
+	if sawEndBeforeResult {
+		t.Error("tool_end emitted before the matching tool_result")
+	}
+}
+
 func TestDynamicSystemPrompt(t *testing.T) {
 	callCount := 0
 	dynamicPrompt := DynamicPrompt(func() string {
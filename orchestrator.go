@@ -3,7 +3,10 @@ package vega
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
@@ -29,8 +32,21 @@ type Orchestrator struct {
 	groups   map[string]*ProcessGroup
 	groupsMu sync.RWMutex
 
+	// Supervisors created with NewSupervisor and not yet stopped
+	supervisors   []*Supervisor
+	supervisorsMu sync.RWMutex
+
+	// Cascading failures, oldest first
+	incidents   []*Incident
+	incidentsMu sync.RWMutex
+
+	// newID generates candidate process IDs; uniqueProcessID checks them.
+	newID func() string
+
 	// Configuration
 	maxProcesses  int
+	maxSpawnDepth int // 0 = unlimited
+	spawnRate     int // spawns per minute per parent; 0 = unlimited
 	defaultLLM    llm.LLM
 	persistence   Persistence
 	healthMonitor *HealthMonitor
@@ -42,12 +58,16 @@ type Orchestrator struct {
 	// Container management
 	containerManager  *container.Manager
 	containerRegistry *container.ProjectRegistry
+	registryErr       error
 
 	// Lifecycle callbacks
-	onComplete []func(*Process, string)
-	onFailed   []func(*Process, error)
-	onStarted  []func(*Process)
-	callbackMu sync.RWMutex
+	onComplete     []completeCallback
+	onFailed       []failedCallback
+	onStarted      []startedCallback
+	onCompacted    []compactedCallback
+	onWorkerEvent  []workerEventCallback
+	nextCallbackID uint64
+	callbackMu     sync.RWMutex
 
 	// Event callbacks (for distributed workers)
 	callbackConfig *CallbackConfig
@@ -56,6 +76,13 @@ type Orchestrator struct {
 	// Shutdown coordination
 	ctx    context.Context
 	cancel context.CancelFunc
+
+	// Draining state: once draining, Spawn and Send are refused and idle is
+	// closed when the last in-flight call finishes.
+	drainMu  sync.Mutex
+	draining bool
+	inflight int
+	idle     chan struct{}
 }
 
 // ProcessEvent represents a process lifecycle event.
@@ -90,6 +117,7 @@ func NewOrchestrator(opts ...OrchestratorOption) *Orchestrator {
 		groups:       make(map[string]*ProcessGroup),
 		maxProcesses: 100,
 		rateLimits:   make(map[string]*rateLimiter),
+		newID:        newProcessID,
 		ctx:          ctx,
 		cancel:       cancel,
 	}
@@ -118,6 +146,24 @@ func WithMaxProcesses(n int) OrchestratorOption {
 	}
 }
 
+// WithMaxSpawnDepth limits how deep the spawn tree may grow. A process
+// spawned WithParent has its parent's depth plus one; spawns deeper than n
+// fail with ErrSpawnDepthExceeded. Zero, the default, means no limit.
+func WithMaxSpawnDepth(n int) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.maxSpawnDepth = n
+	}
+}
+
+// WithSpawnRateLimit limits each process to perMinute child spawns per
+// minute, with bursts up to the same number. Spawns over the limit fail
+// with ErrSpawnRateExceeded. Zero, the default, means no limit.
+func WithSpawnRateLimit(perMinute int) OrchestratorOption {
+	return func(o *Orchestrator) {
+		o.spawnRate = perMinute
+	}
+}
+
 // WithLLM sets the default LLM backend.
 func WithLLM(l llm.LLM) OrchestratorOption {
 	return func(o *Orchestrator) {
@@ -132,13 +178,25 @@ func WithPersistence(p Persistence) OrchestratorOption {
 	}
 }
 
-// WithRecovery enables process recovery on startup.
+// WithRecovery enables process recovery on startup. Only processes whose
+// agent was registered through WithAgents can be respawned at that point;
+// call Recover after RegisterAgent for the rest.
 func WithRecovery(enabled bool) OrchestratorOption {
 	return func(o *Orchestrator) {
 		o.recovery = enabled
 	}
 }
 
+// WithAgents registers agent definitions at construction, before recovery
+// runs. It is equivalent to calling RegisterAgent for each agent.
+func WithAgents(agents ...Agent) OrchestratorOption {
+	return func(o *Orchestrator) {
+		for _, agent := range agents {
+			o.agents[agent.Name] = agent
+		}
+	}
+}
+
 // WithHealthCheck enables health monitoring.
 func WithHealthCheck(config HealthConfig) OrchestratorOption {
 	return func(o *Orchestrator) {
@@ -156,15 +214,26 @@ func WithRateLimits(limits map[string]RateLimitConfig) OrchestratorOption {
 }
 
 // WithContainerManager enables container-based project isolation.
-// If baseDir is provided, a ProjectRegistry will also be created.
+// If baseDir is provided, a ProjectRegistry will also be created. If the
+// registry can't be created, a warning is logged, GetProjectRegistry
+// returns nil and ProjectRegistryErr reports why; processes keep running
+// tools locally.
 func WithContainerManager(cm *container.Manager, baseDir string) OrchestratorOption {
 	return func(o *Orchestrator) {
 		o.containerManager = cm
+		o.containerRegistry = nil
+		o.registryErr = nil
 		if baseDir != "" && cm != nil {
 			registry, err := container.NewProjectRegistry(baseDir, cm)
-			if err == nil {
-				o.containerRegistry = registry
+			if err != nil {
+				o.registryErr = fmt.Errorf("creating project registry in %s: %w", baseDir, err)
+				slog.Warn("container projects disabled, falling back to local execution",
+					"base_dir", baseDir,
+					"error", err,
+				)
+				return
 			}
+			o.containerRegistry = registry
 		}
 	}
 }
@@ -195,6 +264,20 @@ func WithTask(task string) SpawnOption {
 	}
 }
 
+// WithLabels adds labels to the process, replacing any already set under
+// the same keys.
+func WithLabels(labels map[string]string) SpawnOption {
+	return func(p *Process) {
+		if len(labels) == 0 {
+			return
+		}
+		if p.Labels == nil {
+			p.Labels = make(map[string]string, len(labels))
+		}
+		maps.Copy(p.Labels, labels)
+	}
+}
+
 // WithWorkDir sets the working directory.
 func WithWorkDir(dir string) SpawnOption {
 	return func(p *Process) {
@@ -224,6 +307,15 @@ func WithTimeout(d time.Duration) SpawnOption {
 	}
 }
 
+// WithBudget caps what the process may spend on LLM calls, in USD. Once its
+// CostUSD reaches usd, the next LLM call fails the process with
+// ErrBudgetExceeded. It replaces any Budget set on the agent.
+func WithBudget(usd float64) SpawnOption {
+	return func(p *Process) {
+		p.Agent.Budget = &Budget{Limit: usd, OnExceed: BudgetBlock}
+	}
+}
+
 // WithMaxIterations sets the maximum iteration count.
 func WithMaxIterations(n int) SpawnOption {
 	return func(p *Process) {
@@ -277,11 +369,28 @@ func WithParent(parent *Process) SpawnOption {
 	}
 }
 
-// WithSpawnReason sets the reason/task for spawning this process.
-// This provides context for why the process was created.
+// WithSpawnKind records why this process was spawned as one of the
+// SpawnReason values. Without it, a process is SpawnDelegate if it has a
+// parent and SpawnUser otherwise.
+func WithSpawnKind(kind SpawnReason) SpawnOption {
+	return func(p *Process) {
+		p.SpawnReason = kind
+	}
+}
+
+// WithSpawnReason records why this process was spawned as a plain string,
+// stored unchanged in Process.SpawnReason. It predates the SpawnReason
+// values; new code should use WithSpawnKind for the reason and
+// WithSpawnDetail for free-form context.
 func WithSpawnReason(reason string) SpawnOption {
+	return WithSpawnKind(SpawnReason(reason))
+}
+
+// WithSpawnDetail sets free-form context for the spawn, such as the task
+// that was handed off.
+func WithSpawnDetail(detail string) SpawnOption {
 	return func(p *Process) {
-		p.SpawnReason = reason
+		p.SpawnDetail = detail
 	}
 }
 
@@ -292,6 +401,10 @@ func (o *Orchestrator) Spawn(agent Agent, opts ...SpawnOption) (*Process, error)
 		return nil, &ProcessError{Err: errors.New("agent name is required")}
 	}
 
+	if o.isDraining() {
+		return nil, ErrShuttingDown
+	}
+
 	o.mu.Lock()
 
 	// Check capacity
@@ -303,7 +416,7 @@ func (o *Orchestrator) Spawn(agent Agent, opts ...SpawnOption) (*Process, error)
 	// Create process
 	ctx, cancel := context.WithCancel(o.ctx)
 	p := &Process{
-		ID:           uuid.New().String()[:8],
+		ID:           o.uniqueProcessID(),
 		Agent:        &agent,
 		status:       StatusPending,
 		StartedAt:    time.Now(),
@@ -324,6 +437,22 @@ func (o *Orchestrator) Spawn(agent Agent, opts ...SpawnOption) (*Process, error)
 	for _, opt := range opts {
 		opt(p)
 	}
+	if p.SpawnReason == "" {
+		p.SpawnReason = SpawnUser
+		if p.ParentID != "" {
+			p.SpawnReason = SpawnDelegate
+		}
+	}
+
+	// Stop runaway recursive spawning before the process exists
+	if err := o.checkSpawnLimits(p); err != nil {
+		parent := o.processes[p.ParentID]
+		o.mu.Unlock()
+		if parent != nil {
+			parent.removeChildID(p.ID)
+		}
+		return nil, err
+	}
 
 	// Default WorkDir to shared workspace if not set by options.
 	if p.WorkDir == "" {
@@ -364,6 +493,93 @@ func (o *Orchestrator) Spawn(agent Agent, opts ...SpawnOption) (*Process, error)
 	return p, nil
 }
 
+// Fork spawns a new process that continues src's conversation: it has the
+// same agent and a copy of src's messages, task, labels, working directory
+// and per-process system prompt, but from then on the two are independent
+// and sending to one does not affect the other. The fork does not share
+// src's Agent.Context, which would mix the two histories; it keeps its
+// history in the process instead. opts are applied after the copied
+// settings.
+func (o *Orchestrator) Fork(src *Process, opts ...SpawnOption) (*Process, error) {
+	if src == nil || src.Agent == nil {
+		return nil, &ProcessError{Err: errors.New("fork: source process is required")}
+	}
+
+	agent := *src.Agent
+	agent.Context = nil
+	forkOpts := []SpawnOption{
+		WithMessages(src.Messages()),
+		WithTask(src.Task),
+		WithLabels(src.Labels),
+		WithWorkDir(src.WorkDir),
+		WithProject(src.Project),
+		WithSpawnKind(SpawnFork),
+		WithSpawnDetail("forked from " + src.ID),
+	}
+
+	fork, err := o.Spawn(agent, append(forkOpts, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	if extra := src.ExtraSystem(); extra != "" {
+		fork.SetExtraSystem(extra)
+	}
+	return fork, nil
+}
+
+// maxProcessIDAttempts is how many short IDs uniqueProcessID tries before
+// falling back to a full UUID.
+const maxProcessIDAttempts = 8
+
+// newProcessID returns a short random process ID.
+func newProcessID() string {
+	return uuid.New().String()[:8]
+}
+
+// uniqueProcessID returns an ID that no registered process has. The short
+// IDs can collide over a long-lived orchestrator, so a clash is retried,
+// and after maxProcessIDAttempts clashes a full UUID is used. Must hold
+// o.mu, so the ID stays free until the process is registered.
+func (o *Orchestrator) uniqueProcessID() string {
+	for range maxProcessIDAttempts {
+		if id := o.newID(); o.processes[id] == nil {
+			return id
+		}
+	}
+	for {
+		if id := uuid.New().String(); o.processes[id] == nil {
+			return id
+		}
+	}
+}
+
+// checkSpawnLimits enforces the spawn depth and per-parent spawn rate
+// limits for p. The errors name the limits so an agent that hits one can
+// tell from its tool result why delegation failed. Must hold o.mu.
+func (o *Orchestrator) checkSpawnLimits(p *Process) error {
+	if o.maxSpawnDepth > 0 && p.SpawnDepth > o.maxSpawnDepth {
+		return fmt.Errorf("%w: %s would run at depth %d, limit is %d; handle the task without spawning further",
+			ErrSpawnDepthExceeded, p.Agent.Name, p.SpawnDepth, o.maxSpawnDepth)
+	}
+
+	parent := o.processes[p.ParentID]
+	if o.spawnRate <= 0 || parent == nil {
+		return nil
+	}
+	parent.childMu.Lock()
+	if parent.spawnLimiter == nil {
+		parent.spawnLimiter = newRateLimiter(RateLimitConfig{RequestsPerMinute: o.spawnRate})
+	}
+	limiter := parent.spawnLimiter
+	parent.childMu.Unlock()
+
+	if !limiter.allow() {
+		return fmt.Errorf("%w: process %s may spawn %d children per minute",
+			ErrSpawnRateExceeded, parent.ID, o.spawnRate)
+	}
+	return nil
+}
+
 // Get returns a process by ID.
 func (o *Orchestrator) Get(id string) *Process {
 	o.mu.RLock()
@@ -405,6 +621,8 @@ func (o *Orchestrator) Kill(id string) error {
 
 // Shutdown gracefully shuts down all processes.
 func (o *Orchestrator) Shutdown(ctx context.Context) error {
+	o.beginDrain()
+
 	// Stop health monitor
 	if o.healthMonitor != nil {
 		o.healthMonitor.Stop()
@@ -442,16 +660,110 @@ func (o *Orchestrator) Shutdown(ctx context.Context) error {
 	}
 }
 
+// drainStopTimeout bounds how long Drain waits for processes to stop once
+// its own deadline has passed.
+const drainStopTimeout = 5 * time.Second
+
+// Drain shuts the orchestrator down without cutting off work in progress.
+// New Spawn and Send calls fail with ErrShuttingDown straight away, except
+// Sends made by the tools of a call already in flight. Drain then waits for
+// in-flight Send, SendStream and Query calls to finish before stopping
+// every process as Shutdown does. If ctx ends first, the remaining calls
+// are cut off, processes get a few more seconds to stop, and ctx's error is
+// returned.
+func (o *Orchestrator) Drain(ctx context.Context) error {
+	idle := o.beginDrain()
+
+	var err error
+	stopCtx := ctx
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+		o.drainMu.Lock()
+		n := o.inflight
+		o.drainMu.Unlock()
+		slog.Warn("drain deadline reached, stopping busy processes", "in_flight", n)
+
+		var cancel context.CancelFunc
+		stopCtx, cancel = context.WithTimeout(context.WithoutCancel(ctx), drainStopTimeout)
+		defer cancel()
+	}
+
+	if shutdownErr := o.Shutdown(stopCtx); err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// beginDrain stops new work from starting and returns a channel that is
+// closed once no calls are in flight.
+func (o *Orchestrator) beginDrain() <-chan struct{} {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+	if !o.draining {
+		o.draining = true
+		o.idle = make(chan struct{})
+		if o.inflight == 0 {
+			close(o.idle)
+		}
+	}
+	return o.idle
+}
+
+// isDraining reports whether Drain or Shutdown has been called.
+func (o *Orchestrator) isDraining() bool {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+	return o.draining
+}
+
+// beginWork registers an in-flight call, failing once draining has begun
+// unless the call is nested in one already in flight. The returned
+// function must be called when the call finishes.
+func (o *Orchestrator) beginWork(nested bool) (func(), error) {
+	o.drainMu.Lock()
+	defer o.drainMu.Unlock()
+	if o.draining && !nested {
+		return nil, ErrShuttingDown
+	}
+	o.inflight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			o.drainMu.Lock()
+			defer o.drainMu.Unlock()
+			o.inflight--
+			if o.draining && o.inflight == 0 {
+				select {
+				case <-o.idle:
+				default:
+					close(o.idle)
+				}
+			}
+		})
+	}, nil
+}
+
 // GetContainerManager returns the container manager, if configured.
 func (o *Orchestrator) GetContainerManager() *container.Manager {
 	return o.containerManager
 }
 
-// GetProjectRegistry returns the project registry, if configured.
+// GetProjectRegistry returns the project registry, if configured. It is
+// nil when WithContainerManager failed to create one; see
+// ProjectRegistryErr.
 func (o *Orchestrator) GetProjectRegistry() *container.ProjectRegistry {
 	return o.containerRegistry
 }
 
+// ProjectRegistryErr returns why WithContainerManager couldn't create the
+// project registry, or nil if it didn't fail.
+func (o *Orchestrator) ProjectRegistryErr() error {
+	return o.registryErr
+}
+
 // OnHealthAlert registers a callback for health alerts.
 func (o *Orchestrator) OnHealthAlert(fn func(Alert)) {
 	if o.healthMonitor == nil {
@@ -465,27 +777,102 @@ func (o *Orchestrator) OnHealthAlert(fn func(Alert)) {
 	}()
 }
 
+// CallbackHandle identifies a registered lifecycle callback. Call Remove
+// to stop the callback firing; callers that never deregister can ignore it.
+type CallbackHandle struct {
+	remove func()
+}
+
+// Remove deregisters the callback. It is safe to call more than once and
+// on the zero value. Invocations already in flight are not interrupted.
+func (h CallbackHandle) Remove() {
+	if h.remove != nil {
+		h.remove()
+	}
+}
+
+type completeCallback struct {
+	id uint64
+	fn func(*Process, string)
+}
+
+type failedCallback struct {
+	id uint64
+	fn func(*Process, error)
+}
+
+type startedCallback struct {
+	id uint64
+	fn func(*Process)
+}
+
+type compactedCallback struct {
+	id uint64
+	fn func(*Process, Compaction)
+}
+
 // OnProcessComplete registers a callback for when a process completes successfully.
 // The callback receives the process and its final result.
-func (o *Orchestrator) OnProcessComplete(fn func(*Process, string)) {
+func (o *Orchestrator) OnProcessComplete(fn func(*Process, string)) CallbackHandle {
 	o.callbackMu.Lock()
 	defer o.callbackMu.Unlock()
-	o.onComplete = append(o.onComplete, fn)
+	o.nextCallbackID++
+	id := o.nextCallbackID
+	o.onComplete = append(o.onComplete, completeCallback{id: id, fn: fn})
+
+	return CallbackHandle{remove: func() {
+		o.callbackMu.Lock()
+		defer o.callbackMu.Unlock()
+		o.onComplete = slices.DeleteFunc(o.onComplete, func(c completeCallback) bool { return c.id == id })
+	}}
 }
 
 // OnProcessFailed registers a callback for when a process fails.
 // The callback receives the process and the error.
-func (o *Orchestrator) OnProcessFailed(fn func(*Process, error)) {
+func (o *Orchestrator) OnProcessFailed(fn func(*Process, error)) CallbackHandle {
 	o.callbackMu.Lock()
 	defer o.callbackMu.Unlock()
-	o.onFailed = append(o.onFailed, fn)
+	o.nextCallbackID++
+	id := o.nextCallbackID
+	o.onFailed = append(o.onFailed, failedCallback{id: id, fn: fn})
+
+	return CallbackHandle{remove: func() {
+		o.callbackMu.Lock()
+		defer o.callbackMu.Unlock()
+		o.onFailed = slices.DeleteFunc(o.onFailed, func(c failedCallback) bool { return c.id == id })
+	}}
 }
 
 // OnProcessStarted registers a callback for when a process starts.
-func (o *Orchestrator) OnProcessStarted(fn func(*Process)) {
+func (o *Orchestrator) OnProcessStarted(fn func(*Process)) CallbackHandle {
 	o.callbackMu.Lock()
 	defer o.callbackMu.Unlock()
-	o.onStarted = append(o.onStarted, fn)
+	o.nextCallbackID++
+	id := o.nextCallbackID
+	o.onStarted = append(o.onStarted, startedCallback{id: id, fn: fn})
+
+	return CallbackHandle{remove: func() {
+		o.callbackMu.Lock()
+		defer o.callbackMu.Unlock()
+		o.onStarted = slices.DeleteFunc(o.onStarted, func(c startedCallback) bool { return c.id == id })
+	}}
+}
+
+// OnHistoryCompacted registers a callback for when a process replaces part of
+// its conversation history with a summary. Persist the summary here to
+// restore the compacted history later with SummaryMessage.
+func (o *Orchestrator) OnHistoryCompacted(fn func(*Process, Compaction)) CallbackHandle {
+	o.callbackMu.Lock()
+	defer o.callbackMu.Unlock()
+	o.nextCallbackID++
+	id := o.nextCallbackID
+	o.onCompacted = append(o.onCompacted, compactedCallback{id: id, fn: fn})
+
+	return CallbackHandle{remove: func() {
+		o.callbackMu.Lock()
+		defer o.callbackMu.Unlock()
+		o.onCompacted = slices.DeleteFunc(o.onCompacted, func(c compactedCallback) bool { return c.id == id })
+	}}
 }
 
 // emitComplete notifies all complete callbacks.
@@ -503,7 +890,9 @@ func (o *Orchestrator) emitComplete(p *Process, result string) {
 
 	o.callbackMu.RLock()
 	callbacks := make([]func(*Process, string), len(o.onComplete))
-	copy(callbacks, o.onComplete)
+	for i, c := range o.onComplete {
+		callbacks[i] = c.fn
+	}
 	o.callbackMu.RUnlock()
 
 	// Run callbacks synchronously first so they can access the process by name
@@ -541,7 +930,9 @@ func (o *Orchestrator) emitFailed(p *Process, err error) {
 
 	o.callbackMu.RLock()
 	callbacks := make([]func(*Process, error), len(o.onFailed))
-	copy(callbacks, o.onFailed)
+	for i, c := range o.onFailed {
+		callbacks[i] = c.fn
+	}
 	o.callbackMu.RUnlock()
 
 	// Run callbacks synchronously first so they can access the process by name
@@ -563,7 +954,11 @@ func (o *Orchestrator) emitFailed(p *Process, err error) {
 	// Leave all groups
 	o.LeaveAllGroups(p)
 
-	// Handle automatic restart if configured
+	// Hand the failure to an ancestor, or restart automatically if configured
+	if p.Supervision != nil && p.Supervision.Strategy == Escalate {
+		o.escalate(p, err)
+		return
+	}
 	go o.handleAutoRestart(p, err)
 }
 
@@ -571,7 +966,9 @@ func (o *Orchestrator) emitFailed(p *Process, err error) {
 func (o *Orchestrator) emitStarted(p *Process) {
 	o.callbackMu.RLock()
 	callbacks := make([]func(*Process), len(o.onStarted))
-	copy(callbacks, o.onStarted)
+	for i, c := range o.onStarted {
+		callbacks[i] = c.fn
+	}
 	o.callbackMu.RUnlock()
 
 	for _, fn := range callbacks {
@@ -579,6 +976,21 @@ func (o *Orchestrator) emitStarted(p *Process) {
 	}
 }
 
+// emitCompacted notifies all compacted callbacks. They run synchronously so
+// a persisted summary is stored before the next turn is.
+func (o *Orchestrator) emitCompacted(p *Process, c Compaction) {
+	o.callbackMu.RLock()
+	callbacks := make([]func(*Process, Compaction), len(o.onCompacted))
+	for i, cb := range o.onCompacted {
+		callbacks[i] = cb.fn
+	}
+	o.callbackMu.RUnlock()
+
+	for _, fn := range callbacks {
+		fn(p, c)
+	}
+}
+
 // rateLimiter implements token bucket rate limiting.
 type rateLimiter struct {
 	config   RateLimitConfig
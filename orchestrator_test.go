@@ -2,6 +2,10 @@ package vega
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,6 +39,10 @@ func (m *mockLLM) GenerateStream(ctx context.Context, messages []llm.Message, to
 	return ch, nil
 }
 
+func (m *mockLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
 func TestNewOrchestrator(t *testing.T) {
 	o := NewOrchestrator()
 	if o == nil {
@@ -97,6 +105,101 @@ func TestSpawn(t *testing.T) {
 	}
 }
 
+func TestSpawnIDCollision(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{response: "test"}))
+	ids := []string{"aaaa1111", "aaaa1111", "bbbb2222"}
+	o.newID = func() string {
+		id := ids[0]
+		if len(ids) > 1 {
+			ids = ids[1:]
+		}
+		return id
+	}
+
+	first, err := o.Spawn(Agent{Name: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := o.Spawn(Agent{Name: "second", Model: "test-model"}, WithParent(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != "aaaa1111" || second.ID != "bbbb2222" {
+		t.Errorf("IDs = %q, %q; want the clash regenerated", first.ID, second.ID)
+	}
+	if got := first.ChildIDs; len(got) != 1 || got[0] != second.ID {
+		t.Errorf("parent's children = %v, want the child's final ID", got)
+	}
+
+	// A generator that only ever clashes falls back to a full UUID.
+	third, err := o.Spawn(Agent{Name: "third"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.ID == second.ID || len(third.ID) != 36 {
+		t.Errorf("third ID = %q, want a full UUID", third.ID)
+	}
+
+	for _, p := range []*Process{first, second, third} {
+		if got := o.Get(p.ID); got != p {
+			t.Errorf("Get(%q) = %v, want the %s process", p.ID, got, p.Agent.Name)
+		}
+	}
+	if n := len(o.List()); n != 3 {
+		t.Errorf("orchestrator has %d processes, want 3", n)
+	}
+}
+
+func TestFork(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{response: "noted"}))
+	defer o.Shutdown(context.Background())
+	ctx := context.Background()
+
+	src, err := o.Spawn(Agent{Name: "planner", Model: "test-model"}, WithTask("plan a trip"), WithLabels(map[string]string{"tenant": "acme"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.Send(ctx, "we are going to Lisbon"); err != nil {
+		t.Fatal(err)
+	}
+	forkPoint := src.Messages()
+
+	fork, err := o.Fork(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ID == src.ID || o.Get(fork.ID) != fork {
+		t.Fatalf("fork %q is not registered as its own process", fork.ID)
+	}
+	if fork.Agent == src.Agent || fork.Agent.Name != "planner" || fork.Agent.Model != "test-model" {
+		t.Errorf("fork agent = %+v, want a copy of the source's", fork.Agent)
+	}
+	if fork.Task != src.Task || fork.Labels["tenant"] != "acme" || fork.SpawnReason != SpawnFork {
+		t.Errorf("fork task %q, labels %v, reason %q", fork.Task, fork.Labels, fork.SpawnReason)
+	}
+	if got := fork.Messages(); len(got) != len(forkPoint) || got[0].Content != forkPoint[0].Content {
+		t.Fatalf("fork history = %v, want the source's %v", got, forkPoint)
+	}
+
+	// From here the two conversations diverge.
+	if _, err := src.Send(ctx, "by train"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fork.Send(ctx, "by plane"); err != nil {
+		t.Fatal(err)
+	}
+	last := func(p *Process) string {
+		msgs := p.Messages()
+		return msgs[len(msgs)-2].Content
+	}
+	if last(src) != "by train" || last(fork) != "by plane" {
+		t.Errorf("last user messages = %q, %q; want each process's own", last(src), last(fork))
+	}
+	if n, m := len(src.Messages()), len(fork.Messages()); n != len(forkPoint)+2 || m != n {
+		t.Errorf("history lengths = %d, %d; want %d each", n, m, len(forkPoint)+2)
+	}
+}
+
 func TestSpawnWithTask(t *testing.T) {
 	llm := &mockLLM{response: "test"}
 	o := NewOrchestrator(WithLLM(llm))
@@ -151,6 +254,127 @@ func TestSpawnMaxProcesses(t *testing.T) {
 	}
 }
 
+func TestWithLabels(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+	defer o.Shutdown(context.Background())
+
+	labels := map[string]string{"tenant": "acme"}
+	p, _ := o.Spawn(Agent{Name: "worker"},
+		WithLabels(labels),
+		WithLabels(map[string]string{"experiment": "b"}),
+	)
+	labels["tenant"] = "changed"
+
+	if p.Labels["tenant"] != "acme" || p.Labels["experiment"] != "b" {
+		t.Errorf("Labels = %v, want both options applied and copied", p.Labels)
+	}
+	if !p.HasLabels(map[string]string{"tenant": "acme"}) || !p.HasLabels(nil) {
+		t.Error("HasLabels should match a subset of the labels")
+	}
+	if p.HasLabels(map[string]string{"tenant": "globex"}) || p.HasLabels(map[string]string{"team": ""}) {
+		t.Error("HasLabels matched a label the process doesn't carry")
+	}
+}
+
+func TestSpawnReason(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+	defer o.Shutdown(context.Background())
+
+	root, _ := o.Spawn(Agent{Name: "root"})
+	if root.SpawnReason != SpawnUser {
+		t.Errorf("root reason = %q, want %q", root.SpawnReason, SpawnUser)
+	}
+	child, _ := o.Spawn(Agent{Name: "child"}, WithParent(root), WithSpawnDetail("summarize"))
+	if child.SpawnReason != SpawnDelegate || child.SpawnDetail != "summarize" {
+		t.Errorf("child reason = %q (%q), want %q", child.SpawnReason, child.SpawnDetail, SpawnDelegate)
+	}
+	if p, _ := o.Spawn(Agent{Name: "forked"}, WithSpawnKind(SpawnFork)); p.SpawnReason != SpawnFork {
+		t.Errorf("WithSpawnKind reason = %q, want %q", p.SpawnReason, SpawnFork)
+	}
+	if p, _ := o.Spawn(Agent{Name: "legacy"}, WithSpawnReason("nightly import")); p.SpawnReason != "nightly import" {
+		t.Errorf("WithSpawnReason(string) reason = %q, want it stored unchanged", p.SpawnReason)
+	}
+
+	sup := o.NewSupervisor(SupervisorSpec{
+		Strategy:    OneForOne,
+		MaxRestarts: 3,
+		Children:    []ChildSpec{{Name: "worker", Agent: Agent{Name: "worker"}, Restart: Permanent}},
+	})
+	sup.Start()
+	defer sup.Stop()
+
+	first := o.GetByName("worker")
+	if first.SpawnReason != SpawnUser {
+		t.Errorf("initial child reason = %q, want %q", first.SpawnReason, SpawnUser)
+	}
+	first.Fail(errors.New("crash"))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if p := o.GetByName("worker"); p != nil && p.ID != first.ID {
+			if p.SpawnReason != SpawnSupervisorRestart {
+				t.Errorf("restarted child reason = %q, want %q", p.SpawnReason, SpawnSupervisorRestart)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("worker was not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSpawnMaxDepth(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}), WithMaxSpawnDepth(2))
+
+	root, _ := o.Spawn(Agent{Name: "root"})
+	child, err := o.Spawn(Agent{Name: "child"}, WithParent(root))
+	if err != nil {
+		t.Fatalf("depth 1 spawn: %v", err)
+	}
+	grandchild, err := o.Spawn(Agent{Name: "grandchild"}, WithParent(child))
+	if err != nil {
+		t.Fatalf("depth 2 spawn: %v", err)
+	}
+
+	_, err = o.Spawn(Agent{Name: "too-deep"}, WithParent(grandchild))
+	if !errors.Is(err, ErrSpawnDepthExceeded) {
+		t.Fatalf("depth 3 spawn error = %v, want ErrSpawnDepthExceeded", err)
+	}
+	if !strings.Contains(err.Error(), "limit is 2") {
+		t.Errorf("error %q should name the depth limit", err)
+	}
+	if len(grandchild.ChildIDs) != 0 {
+		t.Errorf("refused spawn left ChildIDs = %v", grandchild.ChildIDs)
+	}
+	if got := len(o.List()); got != 3 {
+		t.Errorf("processes = %d, want 3", got)
+	}
+}
+
+func TestSpawnRateLimit(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}), WithSpawnRateLimit(3))
+
+	parent, _ := o.Spawn(Agent{Name: "parent"})
+	for i := range 3 {
+		if _, err := o.Spawn(Agent{Name: "worker"}, WithParent(parent)); err != nil {
+			t.Fatalf("spawn %d: %v", i+1, err)
+		}
+	}
+	if _, err := o.Spawn(Agent{Name: "worker"}, WithParent(parent)); !errors.Is(err, ErrSpawnRateExceeded) {
+		t.Errorf("4th spawn error = %v, want ErrSpawnRateExceeded", err)
+	}
+
+	// The limit is per parent; other parents and root spawns are unaffected.
+	other, err := o.Spawn(Agent{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := o.Spawn(Agent{Name: "worker"}, WithParent(other)); err != nil {
+		t.Errorf("spawn under another parent: %v", err)
+	}
+}
+
 func TestSpawnWithoutLLM(t *testing.T) {
 	o := NewOrchestrator() // No LLM configured
 
@@ -561,6 +785,47 @@ func TestSupervisorStart(t *testing.T) {
 	}
 }
 
+func TestSupervisorReconcilesDroppedSignals(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}), WithMaxProcesses(1000))
+	n := supervisorSignalBuffer + 16
+	spec := SupervisorSpec{Strategy: OneForOne}
+	for i := range n {
+		spec.Children = append(spec.Children, ChildSpec{Agent: Agent{Name: fmt.Sprintf("worker%d", i)}, Restart: Permanent})
+	}
+
+	sup := o.NewSupervisor(spec)
+	sup.reconcileInterval = 20 * time.Millisecond
+	// Hold the watcher back so the burst overflows its signal channel.
+	sup.watchOnce.Do(func() {})
+	if err := sup.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer sup.Stop()
+
+	first := sup.Children()
+	for _, p := range first {
+		p.Fail(errors.New("crash"))
+	}
+	go sup.watch()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		restarted := 0
+		for i, p := range sup.Children() {
+			if p.ID != first[i].ID && p.Status() == StatusRunning {
+				restarted++
+			}
+		}
+		if restarted == n {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d children restarted after a burst of exits", restarted, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func TestSupervisorStop(t *testing.T) {
 	o := NewOrchestrator(WithLLM(&mockLLM{}))
 	spec := SupervisorSpec{
@@ -815,3 +1080,132 @@ func TestWithMessagesCopiesSlice(t *testing.T) {
 	}
 	proc.mu.RUnlock()
 }
+
+func TestRecoverProcesses(t *testing.T) {
+	newPersistence := func() *JSONPersistence {
+		persist := NewJSONPersistence(filepath.Join(t.TempDir(), "processes.json"))
+		err := persist.Save([]ProcessState{
+			{ID: "old-1", AgentName: "writer", Task: "draft the post", WorkDir: "/tmp/writer", Status: StatusRunning},
+			{ID: "old-2", AgentName: "ghost", Task: "haunt", Status: StatusRunning},
+			{ID: "old-3", AgentName: "writer", Task: "already done", Status: StatusCompleted},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return persist
+	}
+
+	o := NewOrchestrator(
+		WithLLM(&mockLLM{}),
+		WithPersistence(newPersistence()),
+		WithAgents(Agent{Name: "writer"}),
+		WithRecovery(true),
+	)
+
+	procs := o.List()
+	if len(procs) != 1 {
+		t.Fatalf("recovered %d processes, want 1", len(procs))
+	}
+	p := procs[0]
+	if p.Agent.Name != "writer" || p.Task != "draft the post" || p.WorkDir != "/tmp/writer" {
+		t.Errorf("recovered process = agent %q task %q workdir %q", p.Agent.Name, p.Task, p.WorkDir)
+	}
+
+	// Agents registered after construction are recovered by calling Recover.
+	o2 := NewOrchestrator(WithLLM(&mockLLM{}), WithPersistence(newPersistence()))
+	o2.RegisterAgent(Agent{Name: "ghost"})
+	recovered, err := o2.Recover()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recovered) != 1 || recovered[0].Task != "haunt" {
+		t.Errorf("Recover after RegisterAgent = %d processes, want the ghost", len(recovered))
+	}
+}
+
+// blockingLLM blocks every Generate call until release is closed.
+type blockingLLM struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	b.started <- struct{}{}
+	<-b.release
+	return &llm.LLMResponse{Content: "finished", StopReason: llm.StopReasonEnd}, nil
+}
+
+func (b *blockingLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	return nil, errors.New("not supported")
+}
+
+func (b *blockingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestDrainWaitsForInFlightCalls(t *testing.T) {
+	backend := &blockingLLM{started: make(chan struct{}, 1), release: make(chan struct{})}
+	o := NewOrchestrator(WithLLM(backend))
+	busy, _ := o.Spawn(Agent{Name: "busy"})
+	idle, _ := o.Spawn(Agent{Name: "idle"})
+
+	type result struct {
+		resp string
+		err  error
+	}
+	sent := make(chan result, 1)
+	go func() {
+		resp, err := busy.Send(context.Background(), "work")
+		sent <- result{resp, err}
+	}()
+	<-backend.started
+
+	drained := make(chan error, 1)
+	go func() { drained <- o.Drain(context.Background()) }()
+
+	// Wait until draining has begun.
+	for !o.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := o.Spawn(Agent{Name: "late"}); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Spawn while draining error = %v, want ErrShuttingDown", err)
+	}
+	if _, err := idle.Send(context.Background(), "more"); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("Send while draining error = %v, want ErrShuttingDown", err)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned %v with a call in flight", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(backend.release)
+	if r := <-sent; r.err != nil || r.resp != "finished" {
+		t.Errorf("in-flight Send = %q, %v; want it to complete", r.resp, r.err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("Drain error = %v", err)
+	}
+	if busy.Status() != StatusCompleted || idle.Status() != StatusCompleted {
+		t.Errorf("statuses after Drain = %s, %s; want both stopped", busy.Status(), idle.Status())
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	backend := &blockingLLM{started: make(chan struct{}, 1), release: make(chan struct{})}
+	defer close(backend.release)
+	o := NewOrchestrator(WithLLM(backend))
+	p, _ := o.Spawn(Agent{Name: "busy"})
+
+	go p.Send(context.Background(), "work")
+	<-backend.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := o.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain error = %v, want DeadlineExceeded", err)
+	}
+	if p.Status() != StatusCompleted {
+		t.Errorf("status after Drain deadline = %s, want the process stopped", p.Status())
+	}
+}
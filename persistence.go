@@ -2,6 +2,7 @@ package vega
 
 import (
 	"encoding/json"
+	"log/slog"
 	"os"
 	"sync"
 	"time"
@@ -15,13 +16,14 @@ type Persistence interface {
 
 // ProcessState is the persisted state of a process.
 type ProcessState struct {
-	ID        string         `json:"id"`
-	AgentName string         `json:"agent_name"`
-	Task      string         `json:"task"`
-	WorkDir   string         `json:"work_dir"`
-	Status    Status         `json:"status"`
-	StartedAt time.Time      `json:"started_at"`
-	Metrics   ProcessMetrics `json:"metrics"`
+	ID        string            `json:"id"`
+	AgentName string            `json:"agent_name"`
+	Task      string            `json:"task"`
+	WorkDir   string            `json:"work_dir"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Status    Status            `json:"status"`
+	StartedAt time.Time         `json:"started_at"`
+	Metrics   ProcessMetrics    `json:"metrics"`
 }
 
 // JSONPersistence saves state to a JSON file.
@@ -83,6 +85,7 @@ func (o *Orchestrator) persistState() {
 			AgentName: p.Agent.Name,
 			Task:      p.Task,
 			WorkDir:   p.WorkDir,
+			Labels:    p.Labels,
 			Status:    p.status,
 			StartedAt: p.StartedAt,
 			Metrics:   p.metrics,
@@ -95,19 +98,56 @@ func (o *Orchestrator) persistState() {
 
 // recoverProcesses recovers processes from persistence.
 func (o *Orchestrator) recoverProcesses() {
+	if _, err := o.Recover(); err != nil {
+		slog.Error("process recovery failed", "error", err)
+	}
+}
+
+// Recover respawns the persisted processes that were running or pending,
+// using the agent definitions registered with RegisterAgent or WithAgents.
+// Task and WorkDir carry over; the new processes get fresh IDs. States
+// whose agent isn't registered are logged and skipped, and are not kept:
+// the next save records only live processes. Recover returns the
+// processes it spawned.
+func (o *Orchestrator) Recover() ([]*Process, error) {
 	if o.persistence == nil {
-		return
+		return nil, nil
 	}
 
 	states, err := o.persistence.Load()
 	if err != nil {
-		return
+		return nil, err
 	}
 
+	var recovered []*Process
 	for _, state := range states {
-		if state.Status == StatusRunning || state.Status == StatusPending {
-			// Mark as needing restart
-			// In a real implementation, we'd need agent definitions to respawn
+		if state.Status != StatusRunning && state.Status != StatusPending {
+			continue
+		}
+		agent, ok := o.GetAgent(state.AgentName)
+		if !ok {
+			slog.Warn("skipping recovery of process with unregistered agent",
+				"process_id", state.ID,
+				"agent", state.AgentName,
+			)
+			continue
+		}
+		p, err := o.Spawn(agent,
+			WithTask(state.Task),
+			WithWorkDir(state.WorkDir),
+			WithLabels(state.Labels),
+			WithSpawnKind(SpawnRecovery),
+		)
+		if err != nil {
+			slog.Error("process recovery failed",
+				"process_id", state.ID,
+				"agent", state.AgentName,
+				"error", err,
+			)
+			continue
 		}
+		slog.Info("process recovered", "process_id", state.ID, "new_process_id", p.ID, "agent", state.AgentName)
+		recovered = append(recovered, p)
 	}
+	return recovered, nil
 }
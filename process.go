@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/everydev1618/govega/llm"
+	"github.com/everydev1618/govega/tools"
 )
 
 // contextKey is a type for context keys used by vega.
@@ -43,6 +44,62 @@ func ProcessFromContext(ctx context.Context) *Process {
 	return p
 }
 
+// systemAddendumContextKey is the context key for system prompt content
+// scoped to a single call.
+const systemAddendumContextKey contextKey = "vega.system_addendum"
+
+// systemAddendum is system prompt content meant for one process.
+type systemAddendum struct {
+	processID string
+	content   string
+}
+
+// ContextWithSystemAddendum returns a new context whose LLM calls on the
+// process with the given ID append content to its system prompt. Unlike
+// SetExtraSystem it does not touch the process, so concurrent calls on one
+// process each see only their own addendum. The addendum is bound to that
+// process, so agents it delegates to with the same ctx don't see it.
+// Addenda from nested calls on the same process accumulate.
+func ContextWithSystemAddendum(ctx context.Context, processID, content string) context.Context {
+	if content == "" {
+		return ctx
+	}
+	if prev, ok := ctx.Value(systemAddendumContextKey).(systemAddendum); ok && prev.processID == processID {
+		content = prev.content + "\n\n" + content
+	}
+	return context.WithValue(ctx, systemAddendumContextKey, systemAddendum{processID: processID, content: content})
+}
+
+// systemAddendumFromContext retrieves the system addendum ctx carries for
+// the process with the given ID, if any.
+func systemAddendumFromContext(ctx context.Context, processID string) string {
+	a, ok := ctx.Value(systemAddendumContextKey).(systemAddendum)
+	if !ok || a.processID != processID {
+		return ""
+	}
+	return a.content
+}
+
+// usageContextKey is the context key for a UsageFunc.
+const usageContextKey contextKey = "vega.usage"
+
+// UsageFunc receives the usage of LLM calls made by a process.
+type UsageFunc func(p *Process, m CallMetrics)
+
+// ContextWithUsage returns a new context whose LLM calls are reported to fn
+// as they are recorded. Agents delegated to with this context report to fn
+// too, so a caller sees the full cost of a request. fn runs on the calling
+// goroutine and should not block.
+func ContextWithUsage(ctx context.Context, fn UsageFunc) context.Context {
+	return context.WithValue(ctx, usageContextKey, fn)
+}
+
+// usageFromContext retrieves the UsageFunc from the context, if present.
+func usageFromContext(ctx context.Context) UsageFunc {
+	fn, _ := ctx.Value(usageContextKey).(UsageFunc)
+	return fn
+}
+
 // Process is a running Agent with state and lifecycle.
 type Process struct {
 	// ID is the unique identifier for this process
@@ -60,6 +117,10 @@ type Process struct {
 	// Project is the container project name for isolated execution
 	Project string
 
+	// Labels are operator-defined tags, such as tenant or experiment, for
+	// filtering and grouping processes. They are set at spawn.
+	Labels map[string]string
+
 	// StartedAt is when the process was spawned
 	StartedAt time.Time
 
@@ -105,7 +166,9 @@ type Process struct {
 	trapExit bool
 	// exitSignals receives exit notifications when trapExit is true
 	exitSignals chan ExitSignal
-	// linkMu protects link/monitor maps
+	// mailbox receives messages sent with Cast and Call
+	mailbox chan MailboxMessage
+	// linkMu protects link/monitor maps and the signal channels
 	linkMu sync.RWMutex
 	// nextMonitorID for generating unique monitor references
 	nextMonitorID uint64
@@ -132,8 +195,12 @@ type Process struct {
 	ParentAgent string   // Agent name of parent
 	ChildIDs    []string // Child process IDs
 	childMu     sync.RWMutex
-	SpawnDepth  int    // Depth in tree (0 = root)
-	SpawnReason string // Task/context for spawn
+	SpawnDepth  int         // Depth in tree (0 = root)
+	SpawnReason SpawnReason // Why the process was spawned
+	SpawnDetail string      // Free-form task/context for the spawn
+
+	// spawnLimiter throttles this process's own spawns; guarded by childMu
+	spawnLimiter *rateLimiter
 }
 
 // Status represents the process lifecycle state.
@@ -160,6 +227,12 @@ type ProcessMetrics struct {
 	LastActiveAt             time.Time
 	ToolCalls                int
 	Errors                   int
+
+	// ContextTokens estimates the input tokens of the latest LLM request,
+	// and ContextWindow is the model's window (0 if unknown), so callers
+	// can show how full the context is.
+	ContextTokens int
+	ContextWindow int
 }
 
 // SendResult is the result of a Send operation.
@@ -171,6 +244,7 @@ type SendResult struct {
 
 // CallMetrics tracks a single LLM call.
 type CallMetrics struct {
+	Model                    string
 	InputTokens              int
 	OutputTokens             int
 	CacheCreationInputTokens int
@@ -202,6 +276,16 @@ func (p *Process) Name() string {
 	return p.name
 }
 
+// HasLabels reports whether the process carries every label in want.
+func (p *Process) HasLabels(want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := p.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Groups returns the names of all groups this process belongs to.
 func (p *Process) Groups() []string {
 	p.mu.RLock()
@@ -223,8 +307,21 @@ func (p *Process) SetExtraSystem(content string) {
 	p.extraSystem = content
 }
 
+// ExtraSystem returns the additional system prompt content set via SetExtraSystem.
+func (p *Process) ExtraSystem() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.extraSystem
+}
+
 // Send sends a message and waits for a response.
 func (p *Process) Send(ctx context.Context, message string) (string, error) {
+	done, err := p.beginWork(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
 	p.mu.Lock()
 	if p.status != StatusRunning && p.status != StatusPending {
 		p.mu.Unlock()
@@ -239,7 +336,7 @@ func (p *Process) Send(ctx context.Context, message string) (string, error) {
 	p.addMessage(llm.Message{Role: llm.RoleUser, Content: message})
 
 	// Execute the LLM call loop (may involve tool calls)
-	response, callMetrics, err := p.executeLLMLoop(ctx, message)
+	response, _, err := p.executeLLMLoop(ctx, message)
 	if err != nil {
 		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 			// Context cancelled or timed out — fail the process so ensureAgent
@@ -254,22 +351,67 @@ func (p *Process) Send(ctx context.Context, message string) (string, error) {
 		return "", err
 	}
 
-	// Update metrics
-	p.mu.Lock()
-	p.metrics.InputTokens += callMetrics.InputTokens
-	p.metrics.OutputTokens += callMetrics.OutputTokens
-	p.metrics.CacheCreationInputTokens += callMetrics.CacheCreationInputTokens
-	p.metrics.CacheReadInputTokens += callMetrics.CacheReadInputTokens
-	p.metrics.CostUSD += callMetrics.CostUSD
-	p.metrics.ToolCalls += len(callMetrics.ToolCalls)
-	p.mu.Unlock()
-
 	// Add assistant response to context
 	p.addMessage(llm.Message{Role: llm.RoleAssistant, Content: response})
 
 	return response, nil
 }
 
+// Query sends a one-off message that bypasses the conversation history.
+// The agent's system prompt and tools apply, but earlier messages are not
+// sent and neither the message nor the response is recorded, so concurrent
+// or experimental calls don't pollute the shared process. Token usage still
+// counts toward the process metrics. Use llm.ContextWithModel to run the
+// query on a different model than the backend default.
+func (p *Process) Query(ctx context.Context, message string) (string, error) {
+	done, err := p.beginWork(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer done()
+
+	p.mu.Lock()
+	if p.status != StatusRunning && p.status != StatusPending {
+		p.mu.Unlock()
+		return "", ErrProcessNotRunning
+	}
+	p.metrics.LastActiveAt = time.Now()
+	p.mu.Unlock()
+
+	response, _, err := p.runLLMLoop(ctx, p.buildQueryMessages(ctx, message))
+	if err != nil {
+		p.mu.Lock()
+		p.metrics.Errors++
+		p.mu.Unlock()
+		return "", err
+	}
+	return response, nil
+}
+
+// recordCallMetrics adds the usage of one or more LLM calls to the process
+// metrics and reports it to the UsageFunc in ctx, if any.
+func (p *Process) recordCallMetrics(ctx context.Context, m CallMetrics) {
+	if m.Model == "" {
+		m.Model = llm.ModelFromContext(ctx)
+	}
+	if m.Model == "" {
+		m.Model = p.Agent.Model
+	}
+
+	p.mu.Lock()
+	p.metrics.InputTokens += m.InputTokens
+	p.metrics.OutputTokens += m.OutputTokens
+	p.metrics.CacheCreationInputTokens += m.CacheCreationInputTokens
+	p.metrics.CacheReadInputTokens += m.CacheReadInputTokens
+	p.metrics.CostUSD += m.CostUSD
+	p.metrics.ToolCalls += len(m.ToolCalls)
+	p.mu.Unlock()
+
+	if fn := usageFromContext(ctx); fn != nil {
+		fn(p, m)
+	}
+}
+
 // SendAsync sends a message and returns a Future.
 func (p *Process) SendAsync(message string) *Future {
 	f := &Future{
@@ -303,9 +445,15 @@ func (p *Process) SendAsync(message string) *Future {
 
 // SendStream sends a message and returns a streaming response.
 func (p *Process) SendStream(ctx context.Context, message string) (*Stream, error) {
+	done, err := p.beginWork(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	p.mu.Lock()
 	if p.status != StatusRunning && p.status != StatusPending {
 		p.mu.Unlock()
+		done()
 		return nil, ErrProcessNotRunning
 	}
 	p.status = StatusRunning
@@ -324,6 +472,7 @@ func (p *Process) SendStream(ctx context.Context, message string) (*Stream, erro
 
 	// Execute streaming in goroutine
 	go func() {
+		defer done()
 		defer close(stream.chunks)
 		defer close(stream.done)
 
@@ -345,9 +494,15 @@ func (p *Process) SendStream(ctx context.Context, message string) (*Stream, erro
 // SendStreamRich sends a message and returns a ChatStream with structured events
 // (text deltas, tool start/end) instead of raw text chunks.
 func (p *Process) SendStreamRich(ctx context.Context, message string) (*ChatStream, error) {
+	done, err := p.beginWork(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	p.mu.Lock()
 	if p.status != StatusRunning && p.status != StatusPending {
 		p.mu.Unlock()
+		done()
 		return nil, ErrProcessNotRunning
 	}
 	p.status = StatusRunning
@@ -360,6 +515,7 @@ func (p *Process) SendStreamRich(ctx context.Context, message string) (*ChatStre
 	stream := newChatStream()
 
 	go func() {
+		defer done()
 		defer close(stream.events)
 		defer close(stream.done)
 
@@ -377,6 +533,49 @@ func (p *Process) SendStreamRich(ctx context.Context, message string) (*ChatStre
 	return stream, nil
 }
 
+// SendStreamAsync starts a streaming generation in the background and
+// returns a future for its ChatStream, so a worker can start streaming work
+// now and collect the events later.
+//
+// Like the HTTP chat stream, the generation is detached from ctx: it keeps
+// ctx's values but not its cancellation or deadline, so it runs to
+// completion (bounded by DefaultAsyncStreamTimeout) and the response joins
+// the conversation history whether or not anyone reads the stream. Events
+// are buffered until read; use StreamFuture.Cancel to stop the generation
+// and release them.
+func (p *Process) SendStreamAsync(ctx context.Context, message string) *StreamFuture {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultAsyncStreamTimeout)
+	f := &StreamFuture{
+		done:   make(chan struct{}),
+		cancel: cancel,
+		stop:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(f.done)
+		stream, err := p.SendStreamRich(ctx, message)
+		if err != nil {
+			cancel()
+			f.err = err
+			return
+		}
+		f.stream = bufferChatStream(stream, f.stop, cancel)
+	}()
+
+	return f
+}
+
+// beginWork registers a call with the orchestrator so Drain can wait for
+// it. Calls made from another process's tools (delegation) are part of
+// work already in flight, so they are let through while draining. The
+// returned function must be called when the call finishes.
+func (p *Process) beginWork(ctx context.Context) (func(), error) {
+	if p.orchestrator == nil {
+		return func() {}, nil
+	}
+	return p.orchestrator.beginWork(ProcessFromContext(ctx) != nil)
+}
+
 // Stop terminates the process.
 // This is equivalent to killing the process - linked processes will be notified.
 func (p *Process) Stop() {
@@ -506,14 +705,15 @@ func (p *Process) Result() string {
 
 // HydrateMessages loads historical messages into a process that has no
 // conversation history yet (e.g. after a restart). This is a no-op if
-// the process already has messages.
+// the process already has messages. Only the last MaxHistory turns are
+// kept when the agent sets it.
 func (p *Process) HydrateMessages(msgs []llm.Message) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if len(p.messages) > 0 {
 		return // already has conversation history
 	}
-	p.messages = append(p.messages, msgs...)
+	p.messages = windowHistory(append(p.messages, msgs...), p.Agent.MaxHistory)
 }
 
 // addMessage adds a message to the conversation history.
@@ -524,11 +724,11 @@ func (p *Process) addMessage(msg llm.Message) {
 	if p.Agent.Context != nil {
 		p.Agent.Context.Add(msg)
 	}
-	p.messages = append(p.messages, msg)
+	p.messages = windowHistory(append(p.messages, msg), p.Agent.MaxHistory)
 }
 
 // buildMessages builds the message list for LLM call.
-func (p *Process) buildMessages() []llm.Message {
+func (p *Process) buildMessages(ctx context.Context) []llm.Message {
 	var messages []llm.Message
 
 	// Set skill context if using SkillsPrompt
@@ -547,18 +747,8 @@ func (p *Process) buildMessages() []llm.Message {
 	}
 
 	// Add system prompt
-	if p.Agent.System != nil {
-		systemContent := p.Agent.System.Prompt()
-		p.mu.RLock()
-		extra := p.extraSystem
-		p.mu.RUnlock()
-		if extra != "" {
-			systemContent += "\n\n" + extra
-		}
-		messages = append(messages, llm.Message{
-			Role:    llm.RoleSystem,
-			Content: systemContent,
-		})
+	if sys, ok := p.systemMessage(ctx); ok {
+		messages = append(messages, sys)
 	}
 
 	// Add conversation history
@@ -585,8 +775,52 @@ func (p *Process) buildMessages() []llm.Message {
 	return filtered
 }
 
+// buildQueryMessages builds the message list for a stateless Query: the
+// system prompt followed by message alone.
+func (p *Process) buildQueryMessages(ctx context.Context, message string) []llm.Message {
+	if sp, ok := p.Agent.System.(*SkillsPrompt); ok {
+		sp.SetContext(message)
+	}
+
+	var messages []llm.Message
+	if sys, ok := p.systemMessage(ctx); ok {
+		messages = append(messages, sys)
+	}
+	return append(messages, llm.Message{Role: llm.RoleUser, Content: message})
+}
+
+// systemMessage returns the agent's system prompt plus any extra system
+// content and the addendum carried by ctx. Cache breakpoints are stripped
+// unless the backend is an llm.PromptCacher. ok is false when the agent has
+// no system prompt and ctx carries no addendum.
+func (p *Process) systemMessage(ctx context.Context) (msg llm.Message, ok bool) {
+	addendum := systemAddendumFromContext(ctx, p.ID)
+	if p.Agent.System == nil {
+		if addendum == "" {
+			return llm.Message{}, false
+		}
+		return llm.Message{Role: llm.RoleSystem, Content: addendum}, true
+	}
+	systemContent := promptFor(ctx, p.Agent.System)
+	p.mu.RLock()
+	extra := p.extraSystem
+	p.mu.RUnlock()
+	if extra != "" {
+		systemContent += "\n\n" + extra
+	}
+	if addendum != "" {
+		systemContent += "\n\n" + addendum
+	}
+	if pc, ok := p.llm.(llm.PromptCacher); !ok || !pc.CachesPrompts() {
+		systemContent = llm.StripCacheBreakpoints(systemContent)
+	}
+	return llm.Message{Role: llm.RoleSystem, Content: systemContent}, true
+}
+
 // formatToolResult formats a tool result for the LLM.
 func formatToolResult(id, name, result string) string {
+	// Execute sanitizes results, but error text can carry raw output too.
+	result = tools.SanitizeOutput(result)
 	return "<tool_result tool_use_id=\"" + id + "\" name=\"" + name + "\">\n" + result + "\n</tool_result>"
 }
 
@@ -0,0 +1,334 @@
+package vega
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/everydev1618/govega/llm"
+	"github.com/everydev1618/govega/memory"
+)
+
+// SummaryPrefix starts the message that replaces compacted history.
+const SummaryPrefix = "Summary of the earlier conversation:\n"
+
+// SummaryMessage returns the history message carrying a compaction summary.
+// Use it to restore a persisted summary into a process's history.
+func SummaryMessage(summary string) llm.Message {
+	return llm.Message{Role: llm.RoleUser, Content: SummaryPrefix + summary}
+}
+
+// IsSummaryMessage reports whether msg carries a compaction summary.
+func IsSummaryMessage(msg llm.Message) bool {
+	return msg.Role == llm.RoleUser && strings.HasPrefix(msg.Content, SummaryPrefix)
+}
+
+// Compaction describes a history compaction, for OnHistoryCompacted
+// callbacks.
+type Compaction struct {
+	// Summary is the new summary text, which also covers any earlier summary
+	Summary string
+	// Replaced is how many messages the summary replaced, not counting an
+	// earlier summary
+	Replaced int
+}
+
+// Compact replaces the older half of the conversation history with an
+// LLM-written summary, keeping recent turns verbatim. An earlier summary is
+// folded into the new one. It does nothing if the history is too short to
+// have earlier turns. Agents with a Context manager compact it instead when
+// it is a memory.CompactableContext.
+func (p *Process) Compact(ctx context.Context) error {
+	if p.Agent.Context != nil {
+		if cc, ok := p.Agent.Context.(memory.CompactableContext); ok {
+			return cc.Compact(p.llm)
+		}
+		return nil
+	}
+	_, err := p.compact(ctx)
+	return err
+}
+
+// compact summarizes the older half of the history and reports whether
+// anything was replaced.
+func (p *Process) compact(ctx context.Context) (bool, error) {
+	p.mu.RLock()
+	msgs := make([]llm.Message, len(p.messages))
+	copy(msgs, p.messages)
+	p.mu.RUnlock()
+
+	compacted, c, err := p.summarizeHistory(ctx, msgs)
+	if err != nil || compacted == nil {
+		return false, err
+	}
+	if !p.replaceHistory(msgs, compacted) {
+		return false, nil
+	}
+	if p.orchestrator != nil {
+		p.orchestrator.emitCompacted(p, c)
+	}
+	return true, nil
+}
+
+// replaceHistory swaps the snapshot old, taken earlier from p.messages, for
+// msgs, keeping anything added since the snapshot. If MaxHistory windowed
+// the history in the meantime, it is left alone and false is returned.
+func (p *Process) replaceHistory(old, msgs []llm.Message) bool {
+	p.mu.Lock()
+	if len(p.messages) < len(old) {
+		p.mu.Unlock()
+		return false
+	}
+	p.messages = append(msgs, p.messages[len(old):]...)
+	p.mu.Unlock()
+
+	slog.Info("compacted conversation history",
+		"process_id", p.ID,
+		"agent", p.Agent.Name,
+		"messages_before", len(old),
+		"messages_after", len(msgs),
+	)
+	return true
+}
+
+// compactHistoryIfNeeded compacts the conversation history before a request
+// when it is over the agent's MaxHistoryTokens or MaxHistoryMessages, so the
+// provider never sees a history that outgrew the context window. If
+// summarizing fails, or the result is still over the limits, the oldest
+// messages are dropped instead.
+//
+// Agents with a Context manager keep their own window, so only a
+// memory.CompactableContext past MaxHistoryTokens is compacted.
+func (p *Process) compactHistoryIfNeeded(ctx context.Context) {
+	if p.Agent.MaxHistoryTokens <= 0 && p.Agent.MaxHistoryMessages <= 0 {
+		return
+	}
+
+	if p.Agent.Context != nil {
+		cc, ok := p.Agent.Context.(memory.CompactableContext)
+		if ok && p.Agent.MaxHistoryTokens > 0 && cc.NeedsCompaction(p.Agent.MaxHistoryTokens) {
+			if err := cc.Compact(p.llm); err != nil {
+				slog.Warn("context compaction failed", "process_id", p.ID, "agent", p.Agent.Name, "error", err)
+			}
+		}
+		return
+	}
+
+	p.mu.RLock()
+	msgs := make([]llm.Message, len(p.messages))
+	copy(msgs, p.messages)
+	p.mu.RUnlock()
+
+	if !p.historyOverLimit(msgs, p.historyTokens(ctx, msgs)) {
+		return
+	}
+
+	if ok, err := p.compact(ctx); err != nil {
+		slog.Warn("history compaction failed, trimming instead",
+			"process_id", p.ID,
+			"agent", p.Agent.Name,
+			"error", err,
+		)
+	} else if !ok {
+		slog.Debug("history over limit with no earlier turns to summarize, trimming", "process_id", p.ID)
+	}
+
+	p.mu.RLock()
+	msgs = make([]llm.Message, len(p.messages))
+	copy(msgs, p.messages)
+	p.mu.RUnlock()
+	if trimmed := p.trimHistory(msgs); len(trimmed) < len(msgs) {
+		p.replaceHistory(msgs, trimmed)
+	}
+}
+
+// compactAfterOverflow compacts the history after the model reported a
+// context window overflow, and reports whether it shrank.
+func (p *Process) compactAfterOverflow(ctx context.Context) bool {
+	if p.Agent.Context != nil {
+		cc, ok := p.Agent.Context.(memory.CompactableContext)
+		if !ok {
+			return false
+		}
+		if err := cc.Compact(p.llm); err != nil {
+			slog.Warn("context compaction failed", "process_id", p.ID, "agent", p.Agent.Name, "error", err)
+			return false
+		}
+		return true
+	}
+
+	ok, err := p.compact(ctx)
+	if err != nil {
+		slog.Warn("history compaction failed", "process_id", p.ID, "agent", p.Agent.Name, "error", err)
+	}
+	return ok
+}
+
+// checkContextWindow estimates the tokens of a request and records the
+// estimate in the process metrics. If the request would overflow the
+// model's context window it returns ErrContextWindowExceeded, so no call
+// is wasted on a request the API would reject. Models whose window isn't
+// known are not checked.
+func (p *Process) checkContextWindow(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) error {
+	model := llm.ModelFromContext(ctx)
+	if model == "" {
+		model = p.Agent.Model
+	}
+	window := llm.ContextWindow(model)
+	tokens := llm.EstimateTokens(messages, tools)
+
+	p.mu.Lock()
+	p.metrics.ContextTokens = tokens
+	p.metrics.ContextWindow = window
+	p.mu.Unlock()
+
+	if window > 0 && tokens > window {
+		return fmt.Errorf("%w: request is about %d tokens, over the %d-token window of %s",
+			ErrContextWindowExceeded, tokens, window, model)
+	}
+	return nil
+}
+
+// fitContextWindow checks the messages for the first call of a streamed
+// turn. If they overflow, the history is compacted and the messages rebuilt
+// once, as executeLLMLoop does when the model reports an overflow.
+func (p *Process) fitContextWindow(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) ([]llm.Message, error) {
+	err := p.checkContextWindow(ctx, messages, tools)
+	if err == nil || !p.compactAfterOverflow(ctx) {
+		return messages, err
+	}
+	slog.Info("compacted history to fit the context window", "process_id", p.ID, "agent", p.Agent.Name)
+	messages = p.buildMessages(ctx)
+	return messages, p.checkContextWindow(ctx, messages, tools)
+}
+
+// historyTokens counts the tokens in msgs with the backend, falling back to
+// an estimate if counting fails. It returns 0 when the agent has no token
+// limit, so no count is requested.
+func (p *Process) historyTokens(ctx context.Context, msgs []llm.Message) int {
+	if p.Agent.MaxHistoryTokens <= 0 {
+		return 0
+	}
+	n, err := p.llm.CountTokens(ctx, msgs, nil)
+	if err != nil {
+		slog.Debug("token count failed, estimating", "process_id", p.ID, "error", err)
+		return llm.EstimateTokens(msgs, nil)
+	}
+	return n
+}
+
+// historyOverLimit reports whether msgs, holding tokens tokens, exceed the
+// agent's history limits.
+func (p *Process) historyOverLimit(msgs []llm.Message, tokens int) bool {
+	if n := p.Agent.MaxHistoryMessages; n > 0 && len(msgs) > n {
+		return true
+	}
+	if n := p.Agent.MaxHistoryTokens; n > 0 && tokens > n {
+		return true
+	}
+	return false
+}
+
+// summarizeHistory replaces the older half of msgs with a summary message.
+// The kept half starts at an assistant message, so the summary (a user
+// message) keeps the roles alternating. It returns nil if msgs has no
+// earlier turns to summarize.
+func (p *Process) summarizeHistory(ctx context.Context, msgs []llm.Message) ([]llm.Message, Compaction, error) {
+	split := -1
+	for i := len(msgs) / 2; i < len(msgs); i++ {
+		if msgs[i].Role == llm.RoleAssistant {
+			split = i
+			break
+		}
+	}
+	for i := len(msgs)/2 - 1; split < 0 && i > 0; i-- {
+		if msgs[i].Role == llm.RoleAssistant {
+			split = i
+		}
+	}
+	if split <= 0 {
+		return nil, Compaction{}, nil
+	}
+
+	var content strings.Builder
+	content.WriteString("Please provide a brief summary of this conversation excerpt, focusing on key decisions, facts, and context that would be important for continuing the conversation:\n\n")
+	for _, msg := range msgs[:split] {
+		content.WriteString(string(msg.Role))
+		content.WriteString(": ")
+		content.WriteString(msg.Content)
+		content.WriteString("\n\n")
+	}
+
+	resp, err := p.callLLMWithRetry(ctx, []llm.Message{
+		{Role: llm.RoleUser, Content: content.String()},
+	}, nil)
+	if err != nil {
+		return nil, Compaction{}, err
+	}
+	p.recordCallMetrics(ctx, CallMetrics{
+		InputTokens:              resp.InputTokens,
+		OutputTokens:             resp.OutputTokens,
+		CacheCreationInputTokens: resp.CacheCreationInputTokens,
+		CacheReadInputTokens:     resp.CacheReadInputTokens,
+		CostUSD:                  resp.CostUSD,
+	})
+
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return nil, Compaction{}, errors.New("empty summary")
+	}
+
+	c := Compaction{Summary: summary, Replaced: split}
+	if IsSummaryMessage(msgs[0]) {
+		c.Replaced--
+	}
+	out := make([]llm.Message, 0, len(msgs)-split+1)
+	out = append(out, SummaryMessage(summary))
+	return append(out, msgs[split:]...), c, nil
+}
+
+// windowHistory returns the last n turns of msgs, where each turn starts at
+// a user message. System messages before the window are kept. msgs is
+// returned unchanged if n is not positive or msgs has no more than n turns;
+// otherwise the result is a new slice, so the dropped turns can be freed.
+func windowHistory(msgs []llm.Message, n int) []llm.Message {
+	if n <= 0 {
+		return msgs
+	}
+	start, turns := -1, 0
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == llm.RoleUser {
+			if turns++; turns == n {
+				start = i
+				break
+			}
+		}
+	}
+	if start <= 0 {
+		return msgs
+	}
+
+	var out []llm.Message
+	for _, m := range msgs[:start] {
+		if m.Role == llm.RoleSystem {
+			out = append(out, m)
+		}
+	}
+	return append(out, msgs[start:]...)
+}
+
+// trimHistory drops the oldest messages until msgs fits the agent's history
+// limits, always keeping the last message, then drops leading non-user
+// messages so the history still starts with a user turn. Tokens are
+// estimated here rather than counted, to avoid a backend call per message.
+func (p *Process) trimHistory(msgs []llm.Message) []llm.Message {
+	for len(msgs) > 1 && p.historyOverLimit(msgs, llm.EstimateTokens(msgs, nil)) {
+		msgs = msgs[1:]
+	}
+	for len(msgs) > 1 && msgs[0].Role != llm.RoleUser {
+		msgs = msgs[1:]
+	}
+	return msgs
+}
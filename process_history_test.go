@@ -0,0 +1,284 @@
+package vega
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/everydev1618/govega/llm"
+)
+
+func TestHistoryCompactedBeforeRequest(t *testing.T) {
+	t.Run("summarizes past message limit", func(t *testing.T) {
+		mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+			{Content: "first answer"},
+			{Content: "second answer"},
+			{Content: "user asked one and two"},
+			{Content: "third answer"},
+		}}
+		o := NewOrchestrator(WithLLM(mock))
+		defer o.Shutdown(context.Background())
+
+		proc, err := o.Spawn(Agent{Name: "chat", MaxHistoryMessages: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, msg := range []string{"one", "two", "three"} {
+			if _, err := proc.Send(context.Background(), msg); err != nil {
+				t.Fatalf("Send(%q): %v", msg, err)
+			}
+		}
+
+		if len(mock.calls) != 4 {
+			t.Fatalf("got %d LLM calls, want 4 (two turns, a summary, then the third turn)", len(mock.calls))
+		}
+		prompt := mock.calls[2][0].Content
+		if !strings.Contains(prompt, "summary") || !strings.Contains(prompt, "user: one") || !strings.Contains(prompt, "user: two") {
+			t.Errorf("third call should be the summary request, got %q", prompt)
+		}
+
+		sent := mock.calls[3]
+		if len(sent) != 3 {
+			t.Fatalf("third turn sent %d messages, want 3: %+v", len(sent), sent)
+		}
+		if sent[0].Role != llm.RoleUser || sent[0].Content != SummaryPrefix+"user asked one and two" {
+			t.Errorf("sent[0] = %+v, want the summary", sent[0])
+		}
+		if sent[1].Content != "second answer" || sent[2].Content != "three" {
+			t.Errorf("recent turns not kept verbatim: %+v", sent[1:])
+		}
+
+		if got := len(proc.Messages()); got != 4 {
+			t.Errorf("history has %d messages after the turn, want 4", got)
+		}
+	})
+
+	t.Run("trims when summarizing fails", func(t *testing.T) {
+		mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+			{Content: strings.Repeat("a", 400)},
+			{Content: ""}, // empty summary
+			{Content: "ok"},
+		}}
+		o := NewOrchestrator(WithLLM(mock))
+		defer o.Shutdown(context.Background())
+
+		proc, err := o.Spawn(Agent{Name: "chat", MaxHistoryTokens: 50})
+		if err != nil {
+			t.Fatal(err)
+		}
+		proc.Send(context.Background(), "hi")
+		if _, err := proc.Send(context.Background(), "again"); err != nil {
+			t.Fatal(err)
+		}
+
+		sent := mock.calls[len(mock.calls)-1]
+		if len(sent) != 1 || sent[0].Content != "again" {
+			t.Errorf("sent %+v, want only the latest message", sent)
+		}
+	})
+
+	t.Run("token limit uses the backend count", func(t *testing.T) {
+		mock := &countingLLM{toolCallingLLM: &toolCallingLLM{responses: []*llm.LLMResponse{
+			{Content: "hello"},
+			{Content: "greeting exchanged"},
+			{Content: "ok"},
+		}}, tokens: 5000}
+		o := NewOrchestrator(WithLLM(mock))
+		defer o.Shutdown(context.Background())
+
+		// Short enough to pass an estimate, but the backend says otherwise.
+		proc, _ := o.Spawn(Agent{Name: "chat", MaxHistoryTokens: 1000})
+		proc.Send(context.Background(), "hi")
+		proc.Send(context.Background(), "again")
+
+		if len(mock.calls) != 3 || !strings.HasPrefix(mock.calls[2][0].Content, SummaryPrefix) {
+			t.Errorf("calls = %+v, want a summary before the second turn", mock.calls)
+		}
+	})
+
+	t.Run("under the limits nothing changes", func(t *testing.T) {
+		mock := &toolCallingLLM{}
+		o := NewOrchestrator(WithLLM(mock))
+		defer o.Shutdown(context.Background())
+
+		proc, _ := o.Spawn(Agent{Name: "chat", MaxHistoryMessages: 10, MaxHistoryTokens: 1000})
+		proc.Send(context.Background(), "one")
+		proc.Send(context.Background(), "two")
+
+		if len(mock.calls) != 2 || len(mock.calls[1]) != 3 {
+			t.Errorf("calls = %+v, want two plain turns", mock.calls)
+		}
+	})
+}
+
+// countingLLM reports a fixed token count for every request.
+type countingLLM struct {
+	*toolCallingLLM
+	tokens int
+}
+
+func (m *countingLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return m.tokens, nil
+}
+
+func TestProcessCompact(t *testing.T) {
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{Content: "a1"},
+		{Content: "a2"},
+		{Content: "a3"},
+		{Content: "talked about one and two"},
+	}}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+
+	var got []Compaction
+	o.OnHistoryCompacted(func(p *Process, c Compaction) { got = append(got, c) })
+
+	proc, _ := o.Spawn(Agent{Name: "chat"})
+	for _, msg := range []string{"one", "two", "three"} {
+		if _, err := proc.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := len(proc.Messages())
+
+	if err := proc.Compact(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := proc.Messages()
+	if len(msgs) >= before {
+		t.Fatalf("history has %d messages after compacting, want fewer than %d", len(msgs), before)
+	}
+	if !IsSummaryMessage(msgs[0]) || msgs[0].Content != SummaryPrefix+"talked about one and two" {
+		t.Errorf("msgs[0] = %+v, want the summary", msgs[0])
+	}
+	if last := msgs[len(msgs)-1]; last.Content != "a3" {
+		t.Errorf("last message = %+v, want the latest turn kept verbatim", last)
+	}
+	if len(got) != 1 || got[0].Summary != "talked about one and two" || got[0].Replaced != before-len(msgs)+1 {
+		t.Errorf("callback got %+v", got)
+	}
+}
+
+func TestCompactOnContextWindowOverflow(t *testing.T) {
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{Content: "a1"},
+		{Content: "a2"},
+		{StopReason: llm.StopReasonContextExceeded},
+		{Content: "summary of one"},
+		{Content: "a3"},
+	}}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+
+	proc, _ := o.Spawn(Agent{Name: "chat"})
+	proc.Send(context.Background(), "one")
+	proc.Send(context.Background(), "two")
+	resp, err := proc.Send(context.Background(), "three")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != "a3" {
+		t.Errorf("resp = %q, want the retried answer", resp)
+	}
+
+	retried := mock.calls[len(mock.calls)-1]
+	if !IsSummaryMessage(retried[0]) || retried[len(retried)-1].Content != "three" {
+		t.Errorf("retry sent %+v, want the summary then the recent turns", retried)
+	}
+}
+
+func TestContextWindowCheckedBeforeRequest(t *testing.T) {
+	long := strings.Repeat("word ", 3000)
+
+	t.Run("compacts instead of sending an overflow", func(t *testing.T) {
+		mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+			{Content: "summary of the early turns"},
+			{Content: "answer"},
+		}}
+		o := NewOrchestrator(WithLLM(mock))
+		defer o.Shutdown(context.Background())
+
+		proc, _ := o.Spawn(Agent{Name: "chat", Model: "gpt-4"})
+		proc.HydrateMessages([]llm.Message{
+			{Role: llm.RoleUser, Content: long},
+			{Role: llm.RoleAssistant, Content: long},
+			{Role: llm.RoleUser, Content: long},
+			{Role: llm.RoleAssistant, Content: long},
+		})
+		resp, err := proc.Send(context.Background(), "next")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp != "answer" || len(mock.calls) != 2 {
+			t.Fatalf("resp = %q after %d calls, want the answer after a summary call", resp, len(mock.calls))
+		}
+		if !IsSummaryMessage(mock.calls[1][0]) {
+			t.Errorf("answer call sent %+v, want the summary first", mock.calls[1][0])
+		}
+
+		m := proc.Metrics()
+		if m.ContextWindow != 8_192 || m.ContextTokens == 0 || m.ContextTokens > m.ContextWindow {
+			t.Errorf("context usage = %d/%d, want the fitted request within gpt-4's window", m.ContextTokens, m.ContextWindow)
+		}
+	})
+
+	t.Run("fails fast when compaction can't help", func(t *testing.T) {
+		mock := &toolCallingLLM{}
+		o := NewOrchestrator(WithLLM(mock))
+		defer o.Shutdown(context.Background())
+
+		proc, _ := o.Spawn(Agent{Name: "chat", Model: "gpt-4"})
+		_, err := proc.Send(context.Background(), strings.Repeat(long, 3))
+		if !errors.Is(err, ErrContextWindowExceeded) {
+			t.Fatalf("err = %v, want ErrContextWindowExceeded", err)
+		}
+		if len(mock.calls) != 0 {
+			t.Errorf("made %d LLM calls, want none", len(mock.calls))
+		}
+	})
+}
+
+func TestMaxHistoryWindow(t *testing.T) {
+	const k = 2
+	mock := &toolCallingLLM{}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+
+	proc, _ := o.Spawn(Agent{Name: "triage", System: StaticPrompt("Sort tickets."), MaxHistory: k})
+	for i := range k + 2 {
+		if _, err := proc.Send(context.Background(), fmt.Sprintf("ticket %d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sent := mock.calls[len(mock.calls)-1]
+	if len(sent) != 1+2*k-1 {
+		t.Fatalf("last request sent %d messages, want the system prompt and %d turns: %+v", len(sent), k, sent)
+	}
+	if sent[0].Role != llm.RoleSystem {
+		t.Errorf("sent[0] = %+v, want the system prompt", sent[0])
+	}
+	if sent[1].Content != "ticket 2" || sent[len(sent)-1].Content != "ticket 3" {
+		t.Errorf("window = %+v, want tickets 2 and 3", sent[1:])
+	}
+
+	t.Run("hydration respects the cap", func(t *testing.T) {
+		fresh, _ := o.Spawn(Agent{Name: "triage", MaxHistory: k})
+		fresh.HydrateMessages([]llm.Message{
+			{Role: llm.RoleUser, Content: "one"},
+			{Role: llm.RoleAssistant, Content: "1"},
+			{Role: llm.RoleUser, Content: "two"},
+			{Role: llm.RoleAssistant, Content: "2"},
+			{Role: llm.RoleUser, Content: "three"},
+			{Role: llm.RoleAssistant, Content: "3"},
+		})
+		msgs := fresh.Messages()
+		if len(msgs) != 2*k || msgs[0].Content != "two" {
+			t.Errorf("hydrated %+v, want the last %d turns", msgs, k)
+		}
+	})
+}
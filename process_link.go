@@ -1,6 +1,9 @@
 package vega
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
 
 // ExitReason describes why a process exited.
 type ExitReason string
@@ -14,6 +17,9 @@ const (
 	ExitKilled ExitReason = "killed"
 	// ExitLinked means the process died because a linked process died
 	ExitLinked ExitReason = "linked"
+	// ExitEscalated means a descendant with the Escalate strategy failed and
+	// passed the failure up the spawn tree
+	ExitEscalated ExitReason = "escalated"
 )
 
 // ExitSignal is sent to linked/monitoring processes when a process exits.
@@ -282,13 +288,17 @@ func (p *Process) handleLinkedExit(dead *Process, signal ExitSignal) {
 	p.mu.Unlock()
 
 	// Propagate with ExitLinked reason
+	linkErr := &LinkedProcessError{LinkedID: dead.ID, OriginalError: signal.Error}
 	cascadeSignal := ExitSignal{
 		ProcessID: p.ID,
 		AgentName: p.Agent.Name,
 		Reason:    ExitLinked,
-		Error:     &LinkedProcessError{LinkedID: dead.ID, OriginalError: signal.Error},
+		Error:     linkErr,
 		Timestamp: time.Now(),
 	}
+	if p.orchestrator != nil {
+		p.orchestrator.recordLinkedDeath(p, linkErr, cascadeSignal.Timestamp)
+	}
 	p.propagateExit(cascadeSignal)
 
 	// Notify orchestrator
@@ -310,7 +320,10 @@ func (p *Process) handleMonitoredExit(dead *Process, signal ExitSignal) {
 		select {
 		case exitCh <- signal:
 		default:
-			// Channel full, signal dropped
+			// Channel full. Supervisors reconcile with their children's
+			// status, so they catch up; other monitors miss this exit.
+			slog.Warn("exit signal dropped, monitor's signal channel is full",
+				"monitor", p.ID, "process_id", dead.ID, "reason", signal.Reason)
 		}
 	}
 }
@@ -3,6 +3,7 @@ package vega
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"math/rand"
 	"strings"
@@ -12,12 +13,56 @@ import (
 	"github.com/everydev1618/govega/llm"
 )
 
-// executeLLMLoop runs the LLM call loop, handling tool calls.
+// executeLLMLoop runs the LLM call loop, handling tool calls. If the model
+// reports that the conversation overflowed its context window before any
+// tool ran, the history is compacted and the turn retried once.
 func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, CallMetrics, error) {
-	metrics := CallMetrics{}
+	p.compactHistoryIfNeeded(ctx)
+	response, metrics, err := p.runLLMLoop(ctx, p.buildMessages(ctx))
+	if err == nil || len(metrics.ToolCalls) > 0 || ClassifyError(err) != ErrClassContextWindow {
+		return response, metrics, err
+	}
+	if !p.compactAfterOverflow(ctx) {
+		return response, metrics, err
+	}
+
+	slog.Info("retrying after context window overflow", "process_id", p.ID, "agent", p.Agent.Name)
+	response, retry, err := p.runLLMLoop(ctx, p.buildMessages(ctx))
+	retry.InputTokens += metrics.InputTokens
+	retry.OutputTokens += metrics.OutputTokens
+	retry.CacheCreationInputTokens += metrics.CacheCreationInputTokens
+	retry.CacheReadInputTokens += metrics.CacheReadInputTokens
+	retry.CostUSD += metrics.CostUSD
+	retry.LatencyMs += metrics.LatencyMs
+	return response, retry, err
+}
+
+// toolContext returns the context tools run under: ctx carrying the process,
+// also cancelled when the process stops, so Stop, Kill or a process timeout
+// reaches in-flight tools such as container commands. Call stop once the
+// tools finish.
+func (p *Process) toolContext(ctx context.Context) (toolCtx context.Context, stop func()) {
+	p.mu.RLock()
+	procCtx := p.ctx
+	p.mu.RUnlock()
+
+	toolCtx, cancel := context.WithCancel(ContextWithProcess(ctx, p))
+	if procCtx == nil {
+		return toolCtx, cancel
+	}
+	unlink := context.AfterFunc(procCtx, cancel)
+	return toolCtx, func() {
+		unlink()
+		cancel()
+	}
+}
 
-	// Build messages for LLM
-	messages := p.buildMessages()
+// runLLMLoop calls the LLM with messages until it returns a response
+// without tool calls, executing requested tools in between. Usage is added
+// to the process metrics after every call, so cost is visible while a long
+// tool loop is still running; the returned CallMetrics is the total.
+func (p *Process) runLLMLoop(ctx context.Context, messages []llm.Message) (string, CallMetrics, error) {
+	metrics := CallMetrics{}
 
 	// Get tools schema if agent has tools
 	var toolSchemas []llm.ToolSchema
@@ -37,6 +82,10 @@ func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, C
 		default:
 		}
 
+		if err := p.checkContextWindow(ctx, messages, toolSchemas); err != nil {
+			return "", metrics, err
+		}
+
 		// Call LLM with retry support
 		resp, err := p.callLLMWithRetry(ctx, messages, toolSchemas)
 		if err != nil {
@@ -44,12 +93,30 @@ func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, C
 		}
 
 		// Update metrics
-		metrics.InputTokens += resp.InputTokens
-		metrics.OutputTokens += resp.OutputTokens
-		metrics.CacheCreationInputTokens += resp.CacheCreationInputTokens
-		metrics.CacheReadInputTokens += resp.CacheReadInputTokens
-		metrics.CostUSD += resp.CostUSD
-		metrics.LatencyMs += resp.LatencyMs
+		call := CallMetrics{
+			InputTokens:              resp.InputTokens,
+			OutputTokens:             resp.OutputTokens,
+			CacheCreationInputTokens: resp.CacheCreationInputTokens,
+			CacheReadInputTokens:     resp.CacheReadInputTokens,
+			CostUSD:                  resp.CostUSD,
+			LatencyMs:                resp.LatencyMs,
+		}
+		for _, tc := range resp.ToolCalls {
+			call.ToolCalls = append(call.ToolCalls, tc.Name)
+		}
+		p.recordCallMetrics(ctx, call)
+
+		metrics.InputTokens += call.InputTokens
+		metrics.OutputTokens += call.OutputTokens
+		metrics.CacheCreationInputTokens += call.CacheCreationInputTokens
+		metrics.CacheReadInputTokens += call.CacheReadInputTokens
+		metrics.CostUSD += call.CostUSD
+		metrics.LatencyMs += call.LatencyMs
+		metrics.ToolCalls = append(metrics.ToolCalls, call.ToolCalls...)
+
+		if resp.StopReason == llm.StopReasonContextExceeded {
+			return "", metrics, ErrContextWindowExceeded
+		}
 
 		// If no tool calls, we're done
 		if len(resp.ToolCalls) == 0 {
@@ -67,7 +134,7 @@ func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, C
 		}
 
 		// Create context with process for tool execution
-		toolCtx := ContextWithProcess(ctx, p)
+		toolCtx, stopTools := p.toolContext(ctx)
 
 		// Execute all tool calls in parallel and collect results.
 		type toolResult struct {
@@ -76,7 +143,6 @@ func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, C
 		results := make([]toolResult, len(resp.ToolCalls))
 		var wg sync.WaitGroup
 		for i, tc := range resp.ToolCalls {
-			metrics.ToolCalls = append(metrics.ToolCalls, tc.Name)
 			wg.Add(1)
 			go func(idx int, tc llm.ToolCall) {
 				defer wg.Done()
@@ -88,6 +154,7 @@ func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, C
 			}(i, tc)
 		}
 		wg.Wait()
+		stopTools()
 
 		var toolResults strings.Builder
 		for _, tr := range results {
@@ -107,12 +174,16 @@ func (p *Process) executeLLMLoop(ctx context.Context, message string) (string, C
 
 // executeLLMStream runs streaming LLM call with tool execution loop.
 func (p *Process) executeLLMStream(ctx context.Context, message string, chunks chan<- string) (string, error) {
-	messages := p.buildMessages()
+	p.compactHistoryIfNeeded(ctx)
 
 	var toolSchemas []llm.ToolSchema
 	if p.Agent.Tools != nil {
 		toolSchemas = p.Agent.Tools.Schema()
 	}
+	messages, err := p.fitContextWindow(ctx, p.buildMessages(ctx), toolSchemas)
+	if err != nil {
+		return "", err
+	}
 
 	var fullResponse string
 	maxIterations := DefaultMaxIterations
@@ -127,6 +198,14 @@ func (p *Process) executeLLMStream(ctx context.Context, message string, chunks c
 		default:
 		}
 
+		if err := p.checkBudget(); err != nil {
+			return fullResponse, err
+		}
+		if i > 0 {
+			if err := p.checkContextWindow(ctx, messages, toolSchemas); err != nil {
+				return fullResponse, err
+			}
+		}
 		eventCh, err := p.llm.GenerateStream(ctx, messages, toolSchemas)
 		if err != nil {
 			return fullResponse, err
@@ -137,13 +216,21 @@ func (p *Process) executeLLMStream(ctx context.Context, message string, chunks c
 		var toolCalls []llm.ToolCall
 		var currentToolCall *llm.ToolCall
 		var currentToolJSON string
+		var usage CallMetrics
 
 		for event := range eventCh {
 			if event.Error != nil {
+				p.recordStreamUsage(ctx, usage)
 				return fullResponse, event.Error
 			}
 
 			switch event.Type {
+			case llm.StreamEventMessageStart:
+				usage.InputTokens += event.InputTokens
+				usage.CacheCreationInputTokens += event.CacheCreationInputTokens
+				usage.CacheReadInputTokens += event.CacheReadInputTokens
+			case llm.StreamEventMessageEnd:
+				usage.OutputTokens += event.OutputTokens
 			case llm.StreamEventContentDelta:
 				if event.Delta != "" {
 					chunks <- event.Delta
@@ -175,6 +262,7 @@ func (p *Process) executeLLMStream(ctx context.Context, message string, chunks c
 				}
 			}
 		}
+		p.recordStreamUsage(ctx, usage)
 
 		// If no tool calls, we're done
 		if len(toolCalls) == 0 {
@@ -191,7 +279,7 @@ func (p *Process) executeLLMStream(ctx context.Context, message string, chunks c
 		}
 
 		// Create context with process for tool execution
-		toolCtx := ContextWithProcess(ctx, p)
+		toolCtx, stopTools := p.toolContext(ctx)
 
 		// Execute all tool calls in parallel and collect results.
 		type streamToolResult struct {
@@ -214,6 +302,7 @@ func (p *Process) executeLLMStream(ctx context.Context, message string, chunks c
 			}(i, tc)
 		}
 		wg.Wait()
+		stopTools()
 
 		var toolResults strings.Builder
 		for _, tr := range streamResults {
@@ -234,29 +323,18 @@ func (p *Process) executeLLMStream(ctx context.Context, message string, chunks c
 // executeLLMStreamRich runs a streaming LLM call loop, emitting structured
 // ChatEvent values (text deltas + tool lifecycle) instead of raw string chunks.
 func (p *Process) executeLLMStreamRich(ctx context.Context, message string, events chan<- ChatEvent) (string, error) {
-	messages := p.buildMessages()
+	p.compactHistoryIfNeeded(ctx)
 
 	var toolSchemas []llm.ToolSchema
 	if p.Agent.Tools != nil {
 		toolSchemas = p.Agent.Tools.Schema()
 	}
+	messages, err := p.fitContextWindow(ctx, p.buildMessages(ctx), toolSchemas)
+	if err != nil {
+		return "", err
+	}
 
 	var fullResponse string
-	var totalInputTokens, totalOutputTokens int
-	var totalCacheCreationTokens, totalCacheReadTokens int
-
-	// Update process metrics when the function returns.
-	defer func() {
-		costUSD := llm.CalculateCost(p.Agent.Model, totalInputTokens, totalOutputTokens,
-			totalCacheCreationTokens, totalCacheReadTokens)
-		p.mu.Lock()
-		p.metrics.InputTokens += totalInputTokens
-		p.metrics.OutputTokens += totalOutputTokens
-		p.metrics.CacheCreationInputTokens += totalCacheCreationTokens
-		p.metrics.CacheReadInputTokens += totalCacheReadTokens
-		p.metrics.CostUSD += costUSD
-		p.mu.Unlock()
-	}()
 
 	maxIterations := DefaultMaxIterations
 	if p.Agent.MaxIterations > 0 {
@@ -270,6 +348,14 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 		default:
 		}
 
+		if err := p.checkBudget(); err != nil {
+			return fullResponse, err
+		}
+		if i > 0 {
+			if err := p.checkContextWindow(ctx, messages, toolSchemas); err != nil {
+				return fullResponse, err
+			}
+		}
 		eventCh, err := p.llm.GenerateStream(ctx, messages, toolSchemas)
 		if err != nil {
 			return fullResponse, err
@@ -279,19 +365,21 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 		var toolCalls []llm.ToolCall
 		var currentToolCall *llm.ToolCall
 		var currentToolJSON string
+		var usage CallMetrics
 
 		for ev := range eventCh {
 			if ev.Error != nil {
+				p.recordStreamUsage(ctx, usage)
 				return fullResponse, ev.Error
 			}
 
 			switch ev.Type {
 			case llm.StreamEventMessageStart:
-				totalInputTokens += ev.InputTokens
-				totalCacheCreationTokens += ev.CacheCreationInputTokens
-				totalCacheReadTokens += ev.CacheReadInputTokens
+				usage.InputTokens += ev.InputTokens
+				usage.CacheCreationInputTokens += ev.CacheCreationInputTokens
+				usage.CacheReadInputTokens += ev.CacheReadInputTokens
 			case llm.StreamEventMessageEnd:
-				totalOutputTokens += ev.OutputTokens
+				usage.OutputTokens += ev.OutputTokens
 			case llm.StreamEventContentDelta:
 				if ev.Delta != "" {
 					events <- ChatEvent{Type: ChatEventTextDelta, Delta: ev.Delta}
@@ -306,6 +394,12 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 						Arguments: make(map[string]any),
 					}
 					currentToolJSON = ""
+					events <- ChatEvent{
+						Type:       ChatEventToolCall,
+						ToolCallID: ev.ToolCall.ID,
+						ToolName:   ev.ToolCall.Name,
+						Status:     ToolStatusPending,
+					}
 				}
 			case llm.StreamEventToolDelta:
 				if currentToolCall != nil {
@@ -330,6 +424,10 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 			}
 		}
 
+		// Record each round-trip as it completes, as callLLMWithRetry's
+		// callers do, so checkBudget sees it before the next one.
+		p.recordStreamUsage(ctx, usage)
+
 		if len(toolCalls) == 0 {
 			return fullResponse, nil
 		}
@@ -343,7 +441,7 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 			messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: assistantContent})
 		}
 
-		toolCtx := ContextWithProcess(ctx, p)
+		toolCtx, stopTools := p.toolContext(ctx)
 		toolCtx = ContextWithEventSink(toolCtx, events)
 
 		// Execute all tool calls in parallel and collect results.
@@ -363,13 +461,24 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 				start := time.Now()
 				result, execErr := p.Agent.Tools.Execute(toolCtx, tc.Name, tc.Arguments)
 				elapsed := toolDuration(start)
+				progress := ChatEvent{
+					Type:       ChatEventToolResult,
+					ToolCallID: tc.ID,
+					ToolName:   tc.Name,
+					Status:     ToolStatusOK,
+					DurationMs: elapsed,
+				}
 				if execErr != nil {
 					result = "Error: " + execErr.Error()
+					progress.Status = ToolStatusError
+					progress.Error = execErr.Error()
 				}
+				events <- progress
 				richResults[idx] = richToolResult{tc.ID, tc.Name, result, elapsed}
 			}(i, tc)
 		}
 		wg.Wait()
+		stopTools()
 
 		// Emit tool end events and build result message in order.
 		var toolResults strings.Builder
@@ -399,9 +508,63 @@ func (p *Process) executeLLMStreamRich(ctx context.Context, message string, even
 	return fullResponse, ErrMaxIterationsExceeded
 }
 
+// recordStreamUsage records the usage of one streamed LLM round-trip,
+// pricing it from the token counts the stream reported.
+func (p *Process) recordStreamUsage(ctx context.Context, usage CallMetrics) {
+	model := llm.ModelFromContext(ctx)
+	if model == "" {
+		model = p.Agent.Model
+	}
+	usage.Model = model
+	usage.CostUSD = llm.CalculateCost(model, usage.InputTokens, usage.OutputTokens,
+		usage.CacheCreationInputTokens, usage.CacheReadInputTokens)
+	p.recordCallMetrics(ctx, usage)
+}
+
+// checkBudget enforces the agent's Budget before an LLM call. Once the
+// process has spent its limit, a BudgetBlock budget fails the process with
+// ErrBudgetExceeded and a BudgetWarn budget logs a warning.
+func (p *Process) checkBudget() error {
+	budget := p.Agent.Budget
+	if budget == nil || budget.Limit <= 0 {
+		return nil
+	}
+	p.mu.RLock()
+	spent := p.metrics.CostUSD
+	p.mu.RUnlock()
+	if spent < budget.Limit {
+		return nil
+	}
+
+	switch budget.OnExceed {
+	case BudgetAllow:
+		return nil
+	case BudgetWarn:
+		slog.Warn("process over budget",
+			"process_id", p.ID,
+			"agent", p.Agent.Name,
+			"spent_usd", spent,
+			"budget_usd", budget.Limit,
+		)
+		return nil
+	}
+
+	err := &ProcessError{
+		ProcessID: p.ID,
+		AgentName: p.Agent.Name,
+		Err:       fmt.Errorf("%w: spent $%.4f of $%.2f", ErrBudgetExceeded, spent, budget.Limit),
+	}
+	p.Fail(err)
+	return err
+}
+
 // callLLMWithRetry calls the LLM with retry logic based on agent's RetryPolicy.
 // It also enforces per-agent rate limits and circuit breaker state.
 func (p *Process) callLLMWithRetry(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	if err := p.checkBudget(); err != nil {
+		return nil, err
+	}
+
 	// Circuit breaker check
 	if p.circuitBreaker != nil && !p.circuitBreaker.Allow() {
 		return nil, &ProcessError{
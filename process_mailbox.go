@@ -0,0 +1,141 @@
+package vega
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// mailboxSize is the number of undelivered messages a mailbox holds.
+const mailboxSize = 64
+
+// callRefs generates correlation references for Call requests.
+var callRefs atomic.Uint64
+
+// MailboxMessage is a data message delivered to a process's mailbox by Cast or
+// Call. Messages sent with Call carry a non-zero Ref and must be answered
+// with Reply.
+type MailboxMessage struct {
+	// From is the ID of the sending process
+	From string
+	// Ref correlates a Call with its reply; zero for Cast
+	Ref uint64
+	// Payload is the data sent
+	Payload any
+	// Timestamp is when the message was sent
+	Timestamp time.Time
+
+	reply chan any
+}
+
+// IsCall reports whether the sender is waiting for a Reply.
+func (m MailboxMessage) IsCall() bool {
+	return m.reply != nil
+}
+
+// Reply answers a message sent with Call. If v is an error, Call returns
+// it as its error. Replies to Cast messages, second replies, and replies
+// arriving after the caller gave up are discarded; Reply reports whether
+// the value was delivered.
+func (m MailboxMessage) Reply(v any) bool {
+	if m.reply == nil {
+		return false
+	}
+	select {
+	case m.reply <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+// Mailbox returns the channel on which this process receives messages
+// sent with Cast and Call. The mailbox is created on first use, so a
+// receiver can start reading before any sender exists.
+func (p *Process) Mailbox() <-chan MailboxMessage {
+	return p.mailboxChan()
+}
+
+// Cast sends msg to another process's mailbox without waiting for it to be
+// handled. It fails with ErrProcessNotRunning if the target has exited and
+// ErrMailboxFull if its mailbox has no room.
+func (p *Process) Cast(to *Process, msg any) error {
+	return p.deliver(to, MailboxMessage{
+		From:      p.ID,
+		Payload:   msg,
+		Timestamp: time.Now(),
+	})
+}
+
+// Call sends msg to another process's mailbox and waits for the receiver
+// to Reply. It gives up when ctx ends, returning ErrTimeout if its deadline
+// passed, or when the target exits before replying.
+func (p *Process) Call(ctx context.Context, to *Process, msg any) (any, error) {
+	reply := make(chan any, 1)
+	err := p.deliver(to, MailboxMessage{
+		From:      p.ID,
+		Ref:       callRefs.Add(1),
+		Payload:   msg,
+		Timestamp: time.Now(),
+		reply:     reply,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case v := <-reply:
+		if err, ok := v.(error); ok {
+			return nil, err
+		}
+		return v, nil
+	case <-to.done():
+		return nil, fmt.Errorf("%w: %s exited before replying", ErrProcessNotRunning, to.ID)
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: call to %s", ErrTimeout, to.ID)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// deliver puts m in to's mailbox without blocking.
+func (p *Process) deliver(to *Process, m MailboxMessage) error {
+	if to == nil {
+		return ErrProcessNotFound
+	}
+	switch to.Status() {
+	case StatusCompleted, StatusFailed, StatusTimeout:
+		return fmt.Errorf("%w: %s", ErrProcessNotRunning, to.ID)
+	}
+
+	select {
+	case to.mailboxChan() <- m:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrMailboxFull, to.ID)
+	}
+}
+
+// mailboxChan returns the mailbox channel, creating it if needed.
+func (p *Process) mailboxChan() chan MailboxMessage {
+	p.linkMu.Lock()
+	defer p.linkMu.Unlock()
+	if p.mailbox == nil {
+		p.mailbox = make(chan MailboxMessage, mailboxSize)
+	}
+	return p.mailbox
+}
+
+// done returns a channel closed when the process stops, or nil if the
+// process has no context.
+func (p *Process) done() <-chan struct{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.ctx == nil {
+		return nil
+	}
+	return p.ctx.Done()
+}
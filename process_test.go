@@ -2,8 +2,13 @@ package vega
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/everydev1618/govega/llm"
+	"github.com/everydev1618/govega/tools"
 )
 
 func TestStatus(t *testing.T) {
@@ -83,6 +88,192 @@ func TestProcessStop(t *testing.T) {
 	}
 }
 
+func TestContextWithUsage(t *testing.T) {
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{Content: "hi", InputTokens: 10, OutputTokens: 5, CostUSD: 0.02},
+	}}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+
+	proc, _ := o.Spawn(Agent{Name: "billed", Model: "test-model"})
+
+	var got []CallMetrics
+	ctx := ContextWithUsage(context.Background(), func(p *Process, m CallMetrics) {
+		if p != proc {
+			t.Errorf("usage reported for process %s, want %s", p.ID, proc.ID)
+		}
+		got = append(got, m)
+	})
+	if _, err := proc.Send(ctx, "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d usage reports, want 1", len(got))
+	}
+	if got[0].Model != "test-model" || got[0].InputTokens != 10 || got[0].CostUSD != 0.02 {
+		t.Errorf("usage = %+v", got[0])
+	}
+	if proc.Metrics().CostUSD != 0.02 {
+		t.Errorf("process cost = %v, want it recorded as well", proc.Metrics().CostUSD)
+	}
+}
+
+func TestContextWithSystemAddendum(t *testing.T) {
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{Content: "one"},
+		{Content: "two"},
+		{Content: "three"},
+	}}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+
+	proc, _ := o.Spawn(Agent{Name: "writer", Model: "test-model", System: StaticPrompt("You write.")})
+	proc.SetExtraSystem("memory layer")
+
+	ctx := ContextWithSystemAddendum(context.Background(), proc.ID, "step context")
+	if _, err := proc.Send(ctx, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proc.Send(context.Background(), "second"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another process called with the same ctx doesn't see it.
+	other, _ := o.Spawn(Agent{Name: "helper", Model: "test-model", System: StaticPrompt("You help.")})
+	if _, err := other.Send(ctx, "third"); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if got := mock.calls[0][0].Content; got != "You write.\n\nmemory layer\n\nstep context" {
+		t.Errorf("first system prompt = %q", got)
+	}
+	if got := mock.calls[1][0].Content; got != "You write.\n\nmemory layer" {
+		t.Errorf("second system prompt = %q, want addendum scoped to the first call", got)
+	}
+	if got := mock.calls[2][0].Content; got != "You help." {
+		t.Errorf("other process's system prompt = %q, want addendum scoped to the writer", got)
+	}
+	if proc.ExtraSystem() != "memory layer" {
+		t.Errorf("ExtraSystem() = %q, want it untouched", proc.ExtraSystem())
+	}
+}
+
+func TestProcessBudget(t *testing.T) {
+	mock := &toolCallingLLM{responses: []*llm.LLMResponse{
+		{Content: "one", CostUSD: 0.03},
+		{Content: "two", CostUSD: 0.03},
+		{Content: "three", CostUSD: 0.03},
+	}}
+	o := NewOrchestrator(WithLLM(mock))
+	defer o.Shutdown(context.Background())
+
+	failed := make(chan error, 1)
+	o.OnProcessFailed(func(p *Process, err error) { failed <- err })
+
+	proc, err := o.Spawn(Agent{Name: "spender"}, WithBudget(0.05))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{"a", "b"} {
+		if _, err := proc.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send(%q) under budget: %v", msg, err)
+		}
+	}
+
+	_, err = proc.Send(context.Background(), "c")
+	if !errors.Is(err, ErrBudgetExceeded) || ClassifyError(err) != ErrClassBudgetExceeded {
+		t.Fatalf("err = %v, want ErrBudgetExceeded", err)
+	}
+	if len(mock.calls) != 2 {
+		t.Errorf("LLM called %d times, want 2 (no call once over budget)", len(mock.calls))
+	}
+	if proc.Status() != StatusFailed {
+		t.Errorf("status = %q, want failed", proc.Status())
+	}
+	select {
+	case err := <-failed:
+		if !errors.Is(err, ErrBudgetExceeded) {
+			t.Errorf("OnProcessFailed got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("OnProcessFailed not called")
+	}
+}
+
+// loopingStreamLLM streams a tool call on every turn, reporting heavy
+// token usage each time.
+type loopingStreamLLM struct {
+	mu    sync.Mutex
+	turns int
+}
+
+func (m *loopingStreamLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	return &llm.LLMResponse{Content: "done"}, nil
+}
+
+func (m *loopingStreamLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	m.mu.Lock()
+	m.turns++
+	m.mu.Unlock()
+
+	ch := make(chan llm.StreamEvent, 4)
+	ch <- llm.StreamEvent{Type: llm.StreamEventMessageStart, InputTokens: 1_000_000}
+	ch <- llm.StreamEvent{Type: llm.StreamEventToolStart, ToolCall: &llm.ToolCall{ID: "call", Name: "noop"}}
+	ch <- llm.StreamEvent{Type: llm.StreamEventContentEnd}
+	ch <- llm.StreamEvent{Type: llm.StreamEventMessageEnd, OutputTokens: 10}
+	close(ch)
+	return ch, nil
+}
+
+func (m *loopingStreamLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestProcessBudgetStreaming(t *testing.T) {
+	ts := tools.NewTools()
+	ts.Register("noop", func() string { return "ok" })
+
+	for _, rich := range []bool{false, true} {
+		mock := &loopingStreamLLM{}
+		o := NewOrchestrator(WithLLM(mock))
+		proc, err := o.Spawn(Agent{Name: "spender", Model: "test-model", Tools: ts}, WithBudget(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rich {
+			stream, serr := proc.SendStreamRich(context.Background(), "go")
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			for range stream.Events() {
+			}
+			err = stream.Err()
+		} else {
+			stream, serr := proc.SendStream(context.Background(), "go")
+			if serr != nil {
+				t.Fatal(serr)
+			}
+			for range stream.Chunks() {
+			}
+			err = stream.Err()
+		}
+		if !errors.Is(err, ErrBudgetExceeded) {
+			t.Errorf("rich=%v: err = %v, want ErrBudgetExceeded", rich, err)
+		}
+		if mock.turns != 1 {
+			t.Errorf("rich=%v: LLM streamed %d times, want 1 (budget checked before the next round-trip)", rich, mock.turns)
+		}
+		if cost := proc.Metrics().CostUSD; cost < 1 {
+			t.Errorf("rich=%v: cost = %v, want the round-trip recorded", rich, cost)
+		}
+		o.Shutdown(context.Background())
+	}
+}
+
 func TestFutureDone(t *testing.T) {
 	f := &Future{
 		done:      make(chan struct{}),
@@ -528,6 +719,52 @@ func TestTrapExitReceivesSignal(t *testing.T) {
 	}
 }
 
+func TestEscalateReachesTrappingAncestor(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+	crash := errors.New("crash")
+
+	grandparent, _ := o.Spawn(Agent{Name: "lead"})
+	parent, _ := o.Spawn(Agent{Name: "manager"}, WithParent(grandparent))
+	escalating := WithSupervision(Supervision{Strategy: Escalate})
+
+	// The parent traps exits, so it receives the escalation.
+	parent.SetTrapExit(true)
+	child, _ := o.Spawn(Agent{Name: "worker"}, WithParent(parent), escalating)
+	child.Fail(crash)
+
+	select {
+	case signal := <-parent.ExitSignals():
+		if signal.ProcessID != child.ID || signal.Reason != ExitEscalated || !errors.Is(signal.Error, crash) {
+			t.Errorf("signal = %+v, want escalation of %s with %v", signal, child.ID, crash)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("parent did not receive the escalation")
+	}
+	if parent.Status() != StatusRunning {
+		t.Errorf("parent status = %q, want running", parent.Status())
+	}
+
+	// Once the parent stops trapping, the next failure bubbles past it.
+	parent.SetTrapExit(false)
+	grandparent.SetTrapExit(true)
+	second, _ := o.Spawn(Agent{Name: "worker"}, WithParent(parent), escalating)
+	second.Fail(crash)
+
+	select {
+	case signal := <-grandparent.ExitSignals():
+		if signal.ProcessID != second.ID || signal.Reason != ExitEscalated {
+			t.Errorf("signal = %+v, want escalation of %s", signal, second.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("escalation did not bubble up to the grandparent")
+	}
+	select {
+	case signal := <-parent.ExitSignals():
+		t.Errorf("non-trapping parent received %+v", signal)
+	default:
+	}
+}
+
 func TestMonitorReceivesSignal(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -675,3 +912,97 @@ func TestExitReasonStrings(t *testing.T) {
 		}
 	}
 }
+
+func TestMailboxConcurrentCast(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+	receiver, err := o.Spawn(Agent{Name: "receiver"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const senders, perSender = 8, 5
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		sender, err := o.Spawn(Agent{Name: "sender"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				if err := sender.Cast(receiver, j); err != nil {
+					t.Errorf("Cast: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	from := make(map[string]int)
+	for i := 0; i < senders*perSender; i++ {
+		select {
+		case msg := <-receiver.Mailbox():
+			if msg.IsCall() {
+				t.Error("Cast message reports IsCall")
+			}
+			from[msg.From]++
+		case <-time.After(time.Second):
+			t.Fatalf("received %d messages, want %d", i, senders*perSender)
+		}
+	}
+	if len(from) != senders {
+		t.Errorf("messages from %d senders, want %d", len(from), senders)
+	}
+}
+
+func TestMailboxCall(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+	server, _ := o.Spawn(Agent{Name: "server"})
+	client, _ := o.Spawn(Agent{Name: "client"})
+
+	go func() {
+		for msg := range server.Mailbox() {
+			switch msg.Payload {
+			case "ping":
+				msg.Reply("pong")
+			case "fail":
+				msg.Reply(errors.New("no"))
+			}
+			// Anything else is left unanswered.
+		}
+	}()
+
+	got, err := client.Call(context.Background(), server, "ping")
+	if err != nil || got != "pong" {
+		t.Fatalf("Call(ping) = %v, %v; want pong", got, err)
+	}
+	if _, err := client.Call(context.Background(), server, "fail"); err == nil || err.Error() != "no" {
+		t.Errorf("Call(fail) error = %v, want the replied error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Call(ctx, server, "ignored"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("unanswered Call error = %v, want ErrTimeout", err)
+	}
+
+	server.Stop()
+	if err := client.Cast(server, "late"); !errors.Is(err, ErrProcessNotRunning) {
+		t.Errorf("Cast to stopped process error = %v, want ErrProcessNotRunning", err)
+	}
+}
+
+func TestMailboxCallTargetExits(t *testing.T) {
+	o := NewOrchestrator(WithLLM(&mockLLM{}))
+	server, _ := o.Spawn(Agent{Name: "server"})
+	client, _ := o.Spawn(Agent{Name: "client"})
+
+	go func() {
+		<-server.Mailbox()
+		server.Stop()
+	}()
+	if _, err := client.Call(context.Background(), server, "hello"); !errors.Is(err, ErrProcessNotRunning) {
+		t.Errorf("Call error = %v, want ErrProcessNotRunning", err)
+	}
+}
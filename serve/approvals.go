@@ -0,0 +1,190 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/google/uuid"
+)
+
+// ApprovalToolsSetting is a comma-separated list of tool names that need a
+// human's approval before they run, e.g. "exec,write_file,send_email".
+// Empty disables approvals.
+const ApprovalToolsSetting = "VEGA_APPROVAL_TOOLS"
+
+// approvalTimeout is how long a call waits for a decision before it is
+// rejected.
+const approvalTimeout = 10 * time.Minute
+
+// PendingApproval is a tool call waiting for a human decision.
+type PendingApproval struct {
+	ID        string         `json:"id"`
+	Tool      string         `json:"tool"`
+	Agent     string         `json:"agent,omitempty"`
+	ProcessID string         `json:"process_id,omitempty"`
+	Params    map[string]any `json:"params"`
+	CreatedAt time.Time      `json:"created_at"`
+
+	decision chan bool
+}
+
+// approvals tracks tool calls that are waiting for a decision.
+type approvals struct {
+	mu      sync.Mutex
+	pending map[string]*PendingApproval
+}
+
+func (a *approvals) add(p *PendingApproval) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending == nil {
+		a.pending = make(map[string]*PendingApproval)
+	}
+	a.pending[p.ID] = p
+}
+
+// take removes and returns a pending approval, so each one is decided at
+// most once.
+func (a *approvals) take(id string) *PendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := a.pending[id]
+	delete(a.pending, id)
+	return p
+}
+
+// list returns the pending approvals, oldest first.
+func (a *approvals) list() []*PendingApproval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	list := make([]*PendingApproval, 0, len(a.pending))
+	for _, p := range a.pending {
+		list = append(list, p)
+	}
+	slices.SortFunc(list, func(x, y *PendingApproval) int {
+		return x.CreatedAt.Compare(y.CreatedAt)
+	})
+	return list
+}
+
+// needsApproval reports whether toolName is listed in ApprovalToolsSetting.
+func (s *Server) needsApproval(toolName string) bool {
+	st, err := s.store.GetSetting(ApprovalToolsSetting)
+	if err != nil || st == nil {
+		return false
+	}
+	for _, name := range strings.Split(st.Value, ",") {
+		if strings.TrimSpace(name) == toolName {
+			return true
+		}
+	}
+	return false
+}
+
+// approveToolCall is the tools.ApprovalFunc used in serve mode. Calls to
+// tools listed in ApprovalToolsSetting publish an "approval.requested"
+// event and block until POST /api/approvals/{id} decides them, the call's
+// context ends, or approvalTimeout passes. A timeout rejects the call.
+func (s *Server) approveToolCall(ctx context.Context, toolName string, params map[string]any) (bool, error) {
+	if !s.needsApproval(toolName) {
+		return true, nil
+	}
+
+	p := &PendingApproval{
+		ID:        uuid.New().String()[:8],
+		Tool:      toolName,
+		Params:    params,
+		CreatedAt: time.Now(),
+		decision:  make(chan bool, 1),
+	}
+	if proc := vega.ProcessFromContext(ctx); proc != nil {
+		p.ProcessID = proc.ID
+		if proc.Agent != nil {
+			p.Agent = proc.Agent.Name
+		}
+	}
+	s.approvals.add(p)
+
+	s.broker.Publish(BrokerEvent{
+		Type:      "approval.requested",
+		ProcessID: p.ProcessID,
+		Agent:     p.Agent,
+		Data:      p,
+		Timestamp: time.Now(),
+	})
+
+	timer := time.NewTimer(approvalTimeout)
+	defer timer.Stop()
+
+	select {
+	case approved := <-p.decision:
+		return approved, nil
+	case <-timer.C:
+		if s.approvals.take(p.ID) != nil {
+			slog.Warn("tool approval timed out", "tool", toolName, "agent", p.Agent, "id", p.ID)
+			s.publishApprovalResolved(p, false)
+			return false, nil
+		}
+		// Decided just as the timer fired.
+		return <-p.decision, nil
+	case <-ctx.Done():
+		if s.approvals.take(p.ID) != nil {
+			s.publishApprovalResolved(p, false)
+		}
+		return false, ctx.Err()
+	}
+}
+
+func (s *Server) publishApprovalResolved(p *PendingApproval, approved bool) {
+	s.broker.Publish(BrokerEvent{
+		Type:      "approval.resolved",
+		ProcessID: p.ProcessID,
+		Agent:     p.Agent,
+		Data: map[string]any{
+			"id":       p.ID,
+			"tool":     p.Tool,
+			"approved": approved,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+// ApprovalDecision is the body of POST /api/approvals/{id}.
+type ApprovalDecision struct {
+	Approved bool `json:"approved"`
+}
+
+// handleListApprovals returns the tool calls waiting for a decision.
+func (s *Server) handleListApprovals(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.approvals.list())
+}
+
+// handleDecideApproval approves or denies a pending tool call.
+func (s *Server) handleDecideApproval(w http.ResponseWriter, r *http.Request) {
+	var req ApprovalDecision
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON body"})
+		return
+	}
+
+	p := s.approvals.take(r.PathValue("id"))
+	if p == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: "approval not found or already decided"})
+		return
+	}
+	p.decision <- req.Approved
+	s.publishApprovalResolved(p, req.Approved)
+
+	status := "denied"
+	if req.Approved {
+		status = "approved"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status})
+}
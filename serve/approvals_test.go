@@ -0,0 +1,131 @@
+package serve
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/everydev1618/govega/tools"
+)
+
+func TestToolApproval(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.UpsertSetting(Setting{Key: ApprovalToolsSetting, Value: "rm, send_email"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{store: store, broker: NewEventBroker()}
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+
+	events := s.broker.Subscribe()
+	defer s.broker.Unsubscribe(events)
+
+	ran := make(chan string, 4)
+	tl := tools.NewTools()
+	tl.Use(tools.WithApproval(s.approveToolCall))
+	for _, name := range []string{"ls", "rm"} {
+		tl.Register(name, func(ctx context.Context, params map[string]any) (string, error) {
+			ran <- name
+			return "ran " + name, nil
+		})
+	}
+
+	// Tools that aren't listed run without asking.
+	if got, err := tl.Execute(context.Background(), "ls", nil); err != nil || got != "ran ls" {
+		t.Fatalf("ls = %q, %v", got, err)
+	}
+
+	// call runs rm in the background, decides it over HTTP, and returns
+	// the tool's result.
+	call := func(body string) (string, int) {
+		t.Helper()
+		type result struct {
+			out string
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			out, err := tl.Execute(context.Background(), "rm", map[string]any{"path": "/tmp/x"})
+			done <- result{out, err}
+		}()
+
+		var p *PendingApproval
+		for p == nil {
+			select {
+			case ev := <-events:
+				if ev.Type == "approval.requested" {
+					p = ev.Data.(*PendingApproval)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("no approval.requested event")
+			}
+		}
+		if p.Tool != "rm" || p.Params["path"] != "/tmp/x" {
+			t.Errorf("pending approval = %+v", p)
+		}
+		if list := s.approvals.list(); len(list) != 1 || list[0].ID != p.ID {
+			t.Errorf("pending list = %+v", list)
+		}
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/approvals/"+p.ID, strings.NewReader(body)))
+		code := w.Code
+
+		// A second decision on the same call is refused.
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/approvals/"+p.ID, strings.NewReader(body)))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("second decision: status = %d, want 404", w.Code)
+		}
+
+		select {
+		case r := <-done:
+			if r.err != nil {
+				t.Fatalf("rm: %v", r.err)
+			}
+			return r.out, code
+		case <-time.After(2 * time.Second):
+			t.Fatal("tool call still blocked after decision")
+		}
+		return "", 0
+	}
+
+	<-ran // ls
+	if out, code := call(`{"approved": false}`); code != http.StatusOK || out != tools.RejectedResult {
+		t.Errorf("denied: status %d, result %q", code, out)
+	}
+	select {
+	case name := <-ran:
+		t.Errorf("%s ran after being denied", name)
+	default:
+	}
+
+	if out, code := call(`{"approved": true}`); code != http.StatusOK || out != "ran rm" {
+		t.Errorf("approved: status %d, result %q", code, out)
+	}
+	if len(s.approvals.list()) != 0 {
+		t.Error("decided approvals still pending")
+	}
+}
+
+func TestToolApprovalContextCancel(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.UpsertSetting(Setting{Key: ApprovalToolsSetting, Value: "rm"}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{store: store, broker: NewEventBroker()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ok, err := s.approveToolCall(ctx, "rm", nil)
+	if ok || err == nil {
+		t.Errorf("approveToolCall = %v, %v; want false with context error", ok, err)
+	}
+	if len(s.approvals.list()) != 0 {
+		t.Error("cancelled approval still pending")
+	}
+}
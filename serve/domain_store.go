@@ -102,7 +102,7 @@ func (s *SQLiteStore) InitDomainTables() error {
 	);
 	CREATE INDEX IF NOT EXISTS idx_production_rates_type ON production_rates(job_type);
 	`
-	_, err := s.db.Exec(schema)
+	_, err := s.exec(schema)
 	return err
 }
 
@@ -110,7 +110,7 @@ func (s *SQLiteStore) InitDomainTables() error {
 
 // InsertJob creates a new job record.
 func (s *SQLiteStore) InsertJob(j Job) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO jobs (external_id, customer_name, property_address, job_type, stage, owner_agent, notes, estimate_total, actual_total)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		j.ExternalID, j.CustomerName, j.PropertyAddress, j.JobType, j.Stage, j.OwnerAgent, j.Notes, j.EstimateTotal, j.ActualTotal,
@@ -123,7 +123,7 @@ func (s *SQLiteStore) InsertJob(j Job) (int64, error) {
 
 // UpdateJobStage advances a job to a new lifecycle stage.
 func (s *SQLiteStore) UpdateJobStage(id int64, stage, ownerAgent, notes string) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE jobs SET stage = ?, owner_agent = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`, stage, ownerAgent, notes, id)
 	return err
@@ -131,7 +131,7 @@ func (s *SQLiteStore) UpdateJobStage(id int64, stage, ownerAgent, notes string)
 
 // UpdateJobTotals sets estimate and actual totals for job costing.
 func (s *SQLiteStore) UpdateJobTotals(id int64, estimateTotal, actualTotal float64) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE jobs SET estimate_total = ?, actual_total = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`, estimateTotal, actualTotal, id)
 	return err
@@ -207,7 +207,7 @@ func scanJobs(rows interface{ Scan(dest ...any) error; Next() bool }) ([]Job, er
 
 // InsertFollowUp creates a new follow-up action.
 func (s *SQLiteStore) InsertFollowUp(f FollowUp) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO follow_ups (agent, target_type, target_name, action, due_date, status, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		f.Agent, f.TargetType, f.TargetName, f.Action, f.DueDate, f.Status, f.Notes,
@@ -220,7 +220,7 @@ func (s *SQLiteStore) InsertFollowUp(f FollowUp) (int64, error) {
 
 // CompleteFollowUp marks a follow-up as done or skipped.
 func (s *SQLiteStore) CompleteFollowUp(id int64, status string) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE follow_ups SET status = ?, completed_at = CURRENT_TIMESTAMP
 		WHERE id = ?`, status, id)
 	return err
@@ -277,7 +277,7 @@ func scanFollowUps(rows interface{ Scan(dest ...any) error; Next() bool }) ([]Fo
 
 // InsertProductionRate records an estimate-vs-actual data point.
 func (s *SQLiteStore) InsertProductionRate(p ProductionRate) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO production_rates (job_type, unit, estimated_hours_per_unit, actual_hours_per_unit, job_name, notes)
 		VALUES (?, ?, ?, ?, ?, ?)`,
 		p.JobType, p.Unit, p.EstimatedHoursPerUnit, p.ActualHoursPerUnit, p.JobName, p.Notes,
@@ -763,7 +763,7 @@ func (s *SQLiteStore) InitDomainTablesV2() error {
 	);
 	CREATE INDEX IF NOT EXISTS idx_budget_lines_budget ON budget_lines(budget_id);
 	`
-	_, err := s.db.Exec(schema)
+	_, err := s.exec(schema)
 	return err
 }
 
@@ -771,7 +771,7 @@ func (s *SQLiteStore) InitDomainTablesV2() error {
 
 // InsertCustomer creates a new customer record.
 func (s *SQLiteStore) InsertCustomer(c Customer) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO customers (name, contact_name, email, phone, address, city, state, zip, source, status, tags, notes, payment_method)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		c.Name, c.ContactName, c.Email, c.Phone, c.Address, c.City, c.State, c.Zip, c.Source, c.Status, c.Tags, c.Notes, c.PaymentMethod,
@@ -795,7 +795,7 @@ func (s *SQLiteStore) GetCustomer(id int64) (*Customer, error) {
 
 // UpdateCustomer updates all fields of a customer by ID.
 func (s *SQLiteStore) UpdateCustomer(c Customer) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE customers SET name = ?, contact_name = ?, email = ?, phone = ?, address = ?, city = ?, state = ?, zip = ?, source = ?, status = ?, tags = ?, notes = ?, payment_method = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		c.Name, c.ContactName, c.Email, c.Phone, c.Address, c.City, c.State, c.Zip, c.Source, c.Status, c.Tags, c.Notes, c.PaymentMethod, c.ID,
@@ -805,7 +805,7 @@ func (s *SQLiteStore) UpdateCustomer(c Customer) error {
 
 // DeleteCustomer removes a customer by ID.
 func (s *SQLiteStore) DeleteCustomer(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM customers WHERE id = ?`, id)
+	_, err := s.exec(`DELETE FROM customers WHERE id = ?`, id)
 	return err
 }
 
@@ -851,7 +851,7 @@ func scanCustomers(rows interface{ Scan(dest ...any) error; Next() bool }) ([]Cu
 
 // InsertProperty creates a new property record.
 func (s *SQLiteStore) InsertProperty(p Property) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO properties (customer_id, address, city, state, zip, lot_size_sqft, lawn_sqft, bed_sqft, hardscape_sqft, tags, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		p.CustomerID, p.Address, p.City, p.State, p.Zip, p.LotSizeSqft, p.LawnSqft, p.BedSqft, p.HardscapeSqft, p.Tags, p.Notes,
@@ -875,7 +875,7 @@ func (s *SQLiteStore) GetProperty(id int64) (*Property, error) {
 
 // UpdateProperty updates all fields of a property by ID.
 func (s *SQLiteStore) UpdateProperty(p Property) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE properties SET customer_id = ?, address = ?, city = ?, state = ?, zip = ?, lot_size_sqft = ?, lawn_sqft = ?, bed_sqft = ?, hardscape_sqft = ?, tags = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		p.CustomerID, p.Address, p.City, p.State, p.Zip, p.LotSizeSqft, p.LawnSqft, p.BedSqft, p.HardscapeSqft, p.Tags, p.Notes, p.ID,
@@ -885,7 +885,7 @@ func (s *SQLiteStore) UpdateProperty(p Property) error {
 
 // DeleteProperty removes a property by ID.
 func (s *SQLiteStore) DeleteProperty(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM properties WHERE id = ?`, id)
+	_, err := s.exec(`DELETE FROM properties WHERE id = ?`, id)
 	return err
 }
 
@@ -921,7 +921,7 @@ func (s *SQLiteStore) InsertCrewMember(m CrewMember) (int64, error) {
 	if m.Active {
 		active = 1
 	}
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO crew_members (name, role, phone, email, hourly_rate, skills, active, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
 		m.Name, m.Role, m.Phone, m.Email, m.HourlyRate, m.Skills, active, m.Notes,
@@ -951,7 +951,7 @@ func (s *SQLiteStore) UpdateCrewMember(m CrewMember) error {
 	if m.Active {
 		active = 1
 	}
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE crew_members SET name = ?, role = ?, phone = ?, email = ?, hourly_rate = ?, skills = ?, active = ?, notes = ?
 		WHERE id = ?`,
 		m.Name, m.Role, m.Phone, m.Email, m.HourlyRate, m.Skills, active, m.Notes, m.ID,
@@ -1002,7 +1002,7 @@ func (s *SQLiteStore) InsertCrew(c Crew) (int64, error) {
 	if c.Active {
 		active = 1
 	}
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO crews (name, foreman_id, member_ids, truck, specialties, active)
 		VALUES (?, ?, ?, ?, ?, ?)`,
 		c.Name, c.ForemanID, c.MemberIDs, c.Truck, c.Specialties, active,
@@ -1032,7 +1032,7 @@ func (s *SQLiteStore) UpdateCrew(c Crew) error {
 	if c.Active {
 		active = 1
 	}
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE crews SET name = ?, foreman_id = ?, member_ids = ?, truck = ?, specialties = ?, active = ?
 		WHERE id = ?`,
 		c.Name, c.ForemanID, c.MemberIDs, c.Truck, c.Specialties, active, c.ID,
@@ -1083,7 +1083,7 @@ func (s *SQLiteStore) InsertItem(i Item) (int64, error) {
 	if i.Active {
 		active = 1
 	}
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO items (name, category, unit, cost, price, supplier, sku, taxable, active, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		i.Name, i.Category, i.Unit, i.Cost, i.Price, i.Supplier, i.SKU, taxable, active, i.Notes,
@@ -1118,7 +1118,7 @@ func (s *SQLiteStore) UpdateItem(i Item) error {
 	if i.Active {
 		active = 1
 	}
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE items SET name = ?, category = ?, unit = ?, cost = ?, price = ?, supplier = ?, sku = ?, taxable = ?, active = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		i.Name, i.Category, i.Unit, i.Cost, i.Price, i.Supplier, i.SKU, taxable, active, i.Notes, i.ID,
@@ -1180,7 +1180,7 @@ func scanItems(rows interface{ Scan(dest ...any) error; Next() bool }) ([]Item,
 
 // InsertEstimate creates a new estimate record.
 func (s *SQLiteStore) InsertEstimate(e Estimate) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO estimates (customer_id, property_id, job_id, title, status, line_items, subtotal, tax, total, margin_pct, deposit_pct, valid_until, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		e.CustomerID, e.PropertyID, e.JobID, e.Title, e.Status, e.LineItems, e.Subtotal, e.Tax, e.Total, e.MarginPct, e.DepositPct, e.ValidUntil, e.Notes,
@@ -1204,7 +1204,7 @@ func (s *SQLiteStore) GetEstimate(id int64) (*Estimate, error) {
 
 // UpdateEstimate updates all fields of an estimate by ID.
 func (s *SQLiteStore) UpdateEstimate(e Estimate) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE estimates SET customer_id = ?, property_id = ?, job_id = ?, title = ?, status = ?, line_items = ?, subtotal = ?, tax = ?, total = ?, margin_pct = ?, deposit_pct = ?, valid_until = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		e.CustomerID, e.PropertyID, e.JobID, e.Title, e.Status, e.LineItems, e.Subtotal, e.Tax, e.Total, e.MarginPct, e.DepositPct, e.ValidUntil, e.Notes, e.ID,
@@ -1214,7 +1214,7 @@ func (s *SQLiteStore) UpdateEstimate(e Estimate) error {
 
 // UpdateEstimateStatus changes just the status of an estimate.
 func (s *SQLiteStore) UpdateEstimateStatus(id int64, status string) error {
-	_, err := s.db.Exec(`UPDATE estimates SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, status, id)
+	_, err := s.exec(`UPDATE estimates SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, status, id)
 	return err
 }
 
@@ -1264,7 +1264,7 @@ func scanEstimates(rows interface{ Scan(dest ...any) error; Next() bool }) ([]Es
 
 // InsertCalendarEvent creates a new calendar event.
 func (s *SQLiteStore) InsertCalendarEvent(e CalendarEvent) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO calendar_events (title, event_type, date, start_time, end_time, crew_id, job_id, customer_id, property_id, status, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		e.Title, e.EventType, e.Date, e.StartTime, e.EndTime, e.CrewID, e.JobID, e.CustomerID, e.PropertyID, e.Status, e.Notes,
@@ -1288,7 +1288,7 @@ func (s *SQLiteStore) GetCalendarEvent(id int64) (*CalendarEvent, error) {
 
 // UpdateCalendarEvent updates all fields of a calendar event by ID.
 func (s *SQLiteStore) UpdateCalendarEvent(e CalendarEvent) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE calendar_events SET title = ?, event_type = ?, date = ?, start_time = ?, end_time = ?, crew_id = ?, job_id = ?, customer_id = ?, property_id = ?, status = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		e.Title, e.EventType, e.Date, e.StartTime, e.EndTime, e.CrewID, e.JobID, e.CustomerID, e.PropertyID, e.Status, e.Notes, e.ID,
@@ -1298,7 +1298,7 @@ func (s *SQLiteStore) UpdateCalendarEvent(e CalendarEvent) error {
 
 // DeleteCalendarEvent removes a calendar event by ID.
 func (s *SQLiteStore) DeleteCalendarEvent(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM calendar_events WHERE id = ?`, id)
+	_, err := s.exec(`DELETE FROM calendar_events WHERE id = ?`, id)
 	return err
 }
 
@@ -1354,7 +1354,7 @@ func scanCalendarEvents(rows interface{ Scan(dest ...any) error; Next() bool })
 
 // InsertInvoice creates a new invoice record.
 func (s *SQLiteStore) InsertInvoice(i Invoice) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO invoices (invoice_number, customer_id, job_id, estimate_id, status, line_items, subtotal, tax, total, deposit_applied, amount_due, issued_date, due_date, paid_date, payment_method, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		i.InvoiceNumber, i.CustomerID, i.JobID, i.EstimateID, i.Status, i.LineItems, i.Subtotal, i.Tax, i.Total, i.DepositApplied, i.AmountDue, i.IssuedDate, i.DueDate, i.PaidDate, i.PaymentMethod, i.Notes,
@@ -1378,7 +1378,7 @@ func (s *SQLiteStore) GetInvoice(id int64) (*Invoice, error) {
 
 // UpdateInvoice updates all fields of an invoice by ID.
 func (s *SQLiteStore) UpdateInvoice(i Invoice) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE invoices SET invoice_number = ?, customer_id = ?, job_id = ?, estimate_id = ?, status = ?, line_items = ?, subtotal = ?, tax = ?, total = ?, deposit_applied = ?, amount_due = ?, issued_date = ?, due_date = ?, paid_date = ?, payment_method = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		i.InvoiceNumber, i.CustomerID, i.JobID, i.EstimateID, i.Status, i.LineItems, i.Subtotal, i.Tax, i.Total, i.DepositApplied, i.AmountDue, i.IssuedDate, i.DueDate, i.PaidDate, i.PaymentMethod, i.Notes, i.ID,
@@ -1388,7 +1388,7 @@ func (s *SQLiteStore) UpdateInvoice(i Invoice) error {
 
 // UpdateInvoiceStatus updates the status and payment details of an invoice.
 func (s *SQLiteStore) UpdateInvoiceStatus(id int64, status, paidDate, paymentMethod string) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE invoices SET status = ?, paid_date = ?, payment_method = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`, status, paidDate, paymentMethod, id)
 	return err
@@ -1444,7 +1444,7 @@ func (s *SQLiteStore) InsertVendor(v Vendor) (int64, error) {
 	if v.Active {
 		active = 1
 	}
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO vendors (name, contact_name, phone, email, address, specialty, payment_terms, account_number, active, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		v.Name, v.ContactName, v.Phone, v.Email, v.Address, v.Specialty, v.PaymentTerms, v.AccountNumber, active, v.Notes,
@@ -1474,7 +1474,7 @@ func (s *SQLiteStore) UpdateVendor(v Vendor) error {
 	if v.Active {
 		active = 1
 	}
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE vendors SET name = ?, contact_name = ?, phone = ?, email = ?, address = ?, specialty = ?, payment_terms = ?, account_number = ?, active = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		v.Name, v.ContactName, v.Phone, v.Email, v.Address, v.Specialty, v.PaymentTerms, v.AccountNumber, active, v.Notes, v.ID,
@@ -1531,7 +1531,7 @@ func scanVendors(rows interface{ Scan(dest ...any) error; Next() bool }) ([]Vend
 
 // InsertSalesLead creates a new sales lead record.
 func (s *SQLiteStore) InsertSalesLead(l SalesLead) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO sales_leads (customer_id, name, phone, email, source, status, estimated_value, job_type, property_address, assigned_to, lost_reason, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		l.CustomerID, l.Name, l.Phone, l.Email, l.Source, l.Status, l.EstimatedValue, l.JobType, l.PropertyAddress, l.AssignedTo, l.LostReason, l.Notes,
@@ -1555,7 +1555,7 @@ func (s *SQLiteStore) GetSalesLead(id int64) (*SalesLead, error) {
 
 // UpdateSalesLead updates all fields of a sales lead by ID.
 func (s *SQLiteStore) UpdateSalesLead(l SalesLead) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE sales_leads SET customer_id = ?, name = ?, phone = ?, email = ?, source = ?, status = ?, estimated_value = ?, job_type = ?, property_address = ?, assigned_to = ?, lost_reason = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		l.CustomerID, l.Name, l.Phone, l.Email, l.Source, l.Status, l.EstimatedValue, l.JobType, l.PropertyAddress, l.AssignedTo, l.LostReason, l.Notes, l.ID,
@@ -1613,7 +1613,7 @@ func (s *SQLiteStore) InsertCostCode(c CostCode) (int64, error) {
 	if c.Active {
 		active = 1
 	}
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO cost_codes (code, name, division, division_group, active)
 		VALUES (?, ?, ?, ?, ?)`,
 		c.Code, c.Name, c.Division, c.DivisionGroup, active,
@@ -1674,7 +1674,7 @@ func (s *SQLiteStore) ListDivisions() ([]string, error) {
 
 // InsertBudget creates a new budget record.
 func (s *SQLiteStore) InsertBudget(b Budget) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO budgets (year, name, revenue_target, total_overhead, billable_hours, hourly_rate, owner_salary, target_margin_pct, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		b.Year, b.Name, b.RevenueTarget, b.TotalOverhead, b.BillableHours, b.HourlyRate, b.OwnerSalary, b.TargetMarginPct, b.Notes,
@@ -1698,7 +1698,7 @@ func (s *SQLiteStore) GetBudget(id int64) (*Budget, error) {
 
 // UpdateBudget updates all fields of a budget by ID.
 func (s *SQLiteStore) UpdateBudget(b Budget) error {
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		UPDATE budgets SET year = ?, name = ?, revenue_target = ?, total_overhead = ?, billable_hours = ?, hourly_rate = ?, owner_salary = ?, target_margin_pct = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?`,
 		b.Year, b.Name, b.RevenueTarget, b.TotalOverhead, b.BillableHours, b.HourlyRate, b.OwnerSalary, b.TargetMarginPct, b.Notes, b.ID,
@@ -1739,7 +1739,7 @@ func (s *SQLiteStore) ListBudgetYears() ([]int, error) {
 
 // InsertBudgetLine creates a new budget line item.
 func (s *SQLiteStore) InsertBudgetLine(l BudgetLine) (int64, error) {
-	res, err := s.db.Exec(`
+	res, err := s.exec(`
 		INSERT INTO budget_lines (budget_id, cost_code, description, category, annual_amount, monthly_amount, notes)
 		VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		l.BudgetID, l.CostCode, l.Description, l.Category, l.AnnualAmount, l.MonthlyAmount, l.Notes,
@@ -1772,6 +1772,6 @@ func (s *SQLiteStore) ListBudgetLines(budgetID int64) ([]BudgetLine, error) {
 
 // DeleteBudgetLine removes a budget line item by ID.
 func (s *SQLiteStore) DeleteBudgetLine(id int64) error {
-	_, err := s.db.Exec(`DELETE FROM budget_lines WHERE id = ?`, id)
+	_, err := s.exec(`DELETE FROM budget_lines WHERE id = ?`, id)
 	return err
 }
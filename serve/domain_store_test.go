@@ -1342,3 +1342,50 @@ func TestBudgetLineLifecycle(t *testing.T) {
 		t.Errorf("got %d budget lines after delete, want 2", len(lines))
 	}
 }
+
+func TestSQLiteStoreBackup(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.InsertChatMessage("etienne", "user", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpsertSetting(Setting{Key: "theme", Value: "dark"}); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "backups", "snapshot.db")
+	if err := store.Backup(dest); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := store.Backup(dest); err == nil {
+		t.Error("Backup over an existing file should fail")
+	}
+
+	backup, err := NewSQLiteStore(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+	if err := backup.Init(); err != nil {
+		t.Fatalf("Init on backup: %v", err)
+	}
+
+	msgs, err := backup.ListChatMessages("etienne")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Content != "hello" {
+		t.Errorf("backup chat messages = %+v, want one \"hello\"", msgs)
+	}
+	st, err := backup.GetSetting("theme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st == nil || st.Value != "dark" {
+		t.Errorf("backup setting = %+v, want theme=dark", st)
+	}
+
+	want, _ := store.SchemaVersion()
+	if got, _ := backup.SchemaVersion(); got != want {
+		t.Errorf("backup schema version = %d, want %d", got, want)
+	}
+}
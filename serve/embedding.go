@@ -0,0 +1,69 @@
+package serve
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+)
+
+// Embedder turns text into a vector for semantic memory search. Vectors
+// from the same embedder must have the same length.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// EmbedderFunc adapts a function to the Embedder interface.
+type EmbedderFunc func(ctx context.Context, text string) ([]float32, error)
+
+// Embed implements Embedder.
+func (f EmbedderFunc) Embed(ctx context.Context, text string) ([]float32, error) {
+	return f(ctx, text)
+}
+
+// memoryItemText is the text embedded for a memory item.
+func memoryItemText(item MemoryItem) string {
+	text := item.Content
+	if item.Topic != "" {
+		text = item.Topic + ": " + text
+	}
+	if item.Tags != "" {
+		text += "\n" + item.Tags
+	}
+	return text
+}
+
+// encodeEmbedding packs a vector as little-endian float32s for storage.
+func encodeEmbedding(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+// decodeEmbedding unpacks a vector stored by encodeEmbedding.
+func decodeEmbedding(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// when the vectors differ in length or either is all zeros.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,103 @@
+package serve
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// conceptEmbedder maps text onto a few hand-picked concept axes so that
+// synonyms land close together without a real model.
+func conceptEmbedder() Embedder {
+	concepts := [][]string{
+		{"car", "vehicle", "sedan", "truck"},
+		{"dog", "puppy", "pet"},
+		{"invoice", "billing", "payment"},
+	}
+	return EmbedderFunc(func(ctx context.Context, text string) ([]float32, error) {
+		text = strings.ToLower(text)
+		v := make([]float32, len(concepts))
+		for i, words := range concepts {
+			for _, w := range words {
+				if strings.Contains(text, w) {
+					v[i]++
+				}
+			}
+		}
+		return v, nil
+	})
+}
+
+func TestSearchMemoryItemsSemantic(t *testing.T) {
+	store := newTestStore(t)
+
+	// Saved before an embedder is configured: must be backfilled.
+	if _, err := store.InsertMemoryItem(MemoryItem{UserID: "u", Agent: "a", Content: "Walk the puppy at 6pm"}); err != nil {
+		t.Fatal(err)
+	}
+
+	store.SetEmbedder(conceptEmbedder())
+	for _, content := range []string{
+		"Send the invoice to Acme by Friday",
+		"Their vehicle is a blue sedan",
+	} {
+		if _, err := store.InsertMemoryItem(MemoryItem{UserID: "u", Agent: "a", Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items, err := store.SearchMemoryItemsSemantic("u", "a", "what car do they drive", 1)
+	if err != nil {
+		t.Fatalf("SearchMemoryItemsSemantic: %v", err)
+	}
+	if len(items) != 1 || !strings.Contains(items[0].Content, "vehicle") {
+		t.Fatalf("car query = %+v, want the vehicle memory", items)
+	}
+
+	items, err = store.SearchMemoryItemsSemantic("u", "a", "pet care", 1)
+	if err != nil {
+		t.Fatalf("SearchMemoryItemsSemantic: %v", err)
+	}
+	if len(items) != 1 || !strings.Contains(items[0].Content, "puppy") {
+		t.Fatalf("pet query = %+v, want the backfilled puppy memory", items)
+	}
+}
+
+func TestSearchMemoryItemsSemanticFallsBackToKeyword(t *testing.T) {
+	store := newTestStore(t)
+	if _, err := store.InsertMemoryItem(MemoryItem{UserID: "u", Agent: "a", Content: "Their vehicle is a blue sedan"}); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := store.SearchMemoryItemsSemantic("u", "a", "sedan", 10)
+	if err != nil {
+		t.Fatalf("SearchMemoryItemsSemantic: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("keyword fallback returned %d items, want 1", len(items))
+	}
+
+	items, err = store.SearchMemoryItemsSemantic("u", "a", "car", 10)
+	if err != nil {
+		t.Fatalf("SearchMemoryItemsSemantic: %v", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("keyword fallback matched %d items for a paraphrase, want 0", len(items))
+	}
+}
+
+func TestEmbeddingRoundTrip(t *testing.T) {
+	v := []float32{0.5, -1.25, 3}
+	got := decodeEmbedding(encodeEmbedding(v))
+	if len(got) != len(v) {
+		t.Fatalf("len = %d, want %d", len(got), len(v))
+	}
+	for i := range v {
+		if got[i] != v[i] {
+			t.Errorf("got[%d] = %v, want %v", i, got[i], v[i])
+		}
+	}
+	if s := cosineSimilarity(v, v); s < 0.999 {
+		t.Errorf("self similarity = %v, want 1", s)
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -53,15 +56,36 @@ func (s *Server) handleGetCompany(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleListProcesses(w http.ResponseWriter, r *http.Request) {
 	procs := s.interp.Orchestrator().List()
+	labels := labelFilter(r.URL.Query())
 
 	resp := make([]ProcessResponse, 0, len(procs))
 	for _, p := range procs {
+		if !p.HasLabels(labels) {
+			continue
+		}
 		resp = append(resp, processToResponse(p))
 	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
 
+// labelFilter collects label.<key>=<value> query parameters into the labels
+// a process must carry.
+func labelFilter(q url.Values) map[string]string {
+	var labels map[string]string
+	for key, values := range q {
+		name, ok := strings.CutPrefix(key, "label.")
+		if !ok || name == "" || len(values) == 0 {
+			continue
+		}
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[name] = values[0]
+	}
+	return labels
+}
+
 func (s *Server) handleGetProcess(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	p := s.interp.Orchestrator().Get(id)
@@ -104,9 +128,11 @@ func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
 
 	// Build maps of composed agent metadata for source tagging and team info.
 	composedMap := make(map[string]ComposedAgent)
-	if composed, err := s.store.ListComposedAgents(); err == nil {
-		for _, a := range composed {
-			composedMap[a.Name] = a
+	if s.store != nil {
+		if composed, err := s.store.ListComposedAgents(); err == nil {
+			for _, a := range composed {
+				composedMap[a.Name] = a
+			}
 		}
 	}
 
@@ -170,20 +196,72 @@ func (s *Server) handleListAgents(w http.ResponseWriter, r *http.Request) {
 
 // --- Chat Handlers ---
 
+// agentPersistsChat reports whether the chat history of agent name is
+// stored and reloaded; agents missing from the document default to yes.
+func agentPersistsChat(interp *dsl.Interpreter, name string) bool {
+	if interp == nil {
+		return true
+	}
+	def, ok := interp.AgentDef(name)
+	return !ok || def.PersistsChat()
+}
+
+// agentUsesMemory reports whether agent name gets per-user memory injected
+// and extracted; agents missing from the document default to yes.
+func agentUsesMemory(interp *dsl.Interpreter, name string) bool {
+	if interp == nil {
+		return true
+	}
+	def, ok := interp.AgentDef(name)
+	return !ok || def.UsesMemory()
+}
+
+// userMemoryText returns userID's memory of agent formatted for the system
+// prompt, or "" if there is none or the agent doesn't use memory.
+func userMemoryText(store Store, interp *dsl.Interpreter, userID, agent string) string {
+	if !agentUsesMemory(interp, agent) {
+		return ""
+	}
+	memories, err := store.GetUserMemory(userID, agent)
+	if err != nil || len(memories) == 0 {
+		return ""
+	}
+	return formatMemoryForInjection(memories)
+}
+
+// saveChatMessage persists a chat message unless the agent opts out of
+// chat persistence.
+func (s *Server) saveChatMessage(agent, role, content string) error {
+	if !agentPersistsChat(s.interp, agent) {
+		return nil
+	}
+	return s.store.InsertChatMessage(agent, role, content)
+}
+
 // hydrateAgent loads persisted chat history into a process that has no
 // conversation history (e.g. freshly spawned after restart). This gives
-// agents continuity across server restarts.
+// agents continuity across server restarts. If the chat was compacted, the
+// latest summary replaces the messages it covers.
 func (s *Server) hydrateAgent(proc *vega.Process, agentName string) {
-	if len(proc.Messages()) > 0 {
-		return // already has history
+	if len(proc.Messages()) > 0 || !agentPersistsChat(s.interp, agentName) {
+		return // already has history, or keeps none
 	}
 
-	history, err := s.store.ListChatMessages(agentName)
-	if err != nil || len(history) == 0 {
-		return
+	summary, err := s.store.LatestChatSummary(agentName)
+	if err != nil {
+		slog.Warn("failed to load chat summary", "agent", agentName, "error", err)
+	}
+	var msgs []llm.Message
+	var after int64
+	if summary != nil {
+		msgs = append(msgs, vega.SummaryMessage(summary.Summary))
+		after = summary.ThroughID
 	}
 
-	msgs := make([]llm.Message, 0, len(history))
+	history, err := s.store.ListChatMessagesAfter(agentName, after)
+	if err != nil || len(msgs)+len(history) == 0 {
+		return
+	}
 	for _, m := range history {
 		role := llm.RoleUser
 		if m.Role == "assistant" {
@@ -198,9 +276,8 @@ func (s *Server) hydrateAgent(proc *vega.Process, agentName string) {
 
 
 func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
-	baseAgent := r.PathValue("name")
-	name := baseAgent
-	userID := "default"
+	name := r.PathValue("name")
+	userID, baseAgent := memoryOwner(r, name)
 
 	var req struct {
 		Message string `json:"message"`
@@ -209,6 +286,10 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "message is required"})
 		return
 	}
+	if refusal := s.screenInput(r.Context(), name, req.Message); refusal != "" {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: refusal})
+		return
+	}
 
 	// Ensure the agent process is spawned so we can inject memory.
 	proc, err := s.interp.EnsureAgent(name)
@@ -222,10 +303,7 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	s.hydrateAgent(proc, name)
 
 	// Load and inject memory + project context into the process before sending.
-	var memText string
-	if memories, err := s.store.GetUserMemory(userID, baseAgent); err == nil && len(memories) > 0 {
-		memText = formatMemoryForInjection(memories)
-	}
+	memText := userMemoryText(s.store, s.interp, userID, baseAgent)
 	projectCtx := buildProjectContext(s.interp.Tools().ActiveProject())
 	companyCtx := buildCompanyContext(s.company)
 	if extra := buildExtraSystem(memText, projectCtx, companyCtx); extra != "" {
@@ -233,7 +311,7 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Persist user message.
-	if err := s.store.InsertChatMessage(name, "user", req.Message); err != nil {
+	if err := s.saveChatMessage(name, "user", req.Message); err != nil {
 		slog.Error("failed to persist user chat message", "agent", name, "error", err)
 	}
 
@@ -248,6 +326,7 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 	ctx = ContextWithMemory(ctx, s.store, userID, baseAgent)
 	ctx = ContextWithDomainStore(ctx, s.sqliteStore)
+	ctx = vega.ContextWithUsage(ctx, s.usageRecorder(userID, baseAgent))
 
 	response, err := s.interp.SendToAgent(ctx, name, req.Message)
 	if err != nil {
@@ -257,20 +336,20 @@ func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Persist assistant response.
-	if err := s.store.InsertChatMessage(name, "assistant", response); err != nil {
+	if err := s.saveChatMessage(name, "assistant", response); err != nil {
 		slog.Error("failed to persist assistant chat message", "agent", name, "error", err)
 	}
 
-	// Fire async memory extraction.
-	go s.extractMemory(userID, baseAgent, req.Message, response)
+	// Extract memory from the turn, in the background unless configured
+	// to finish first.
+	s.scheduleMemoryExtraction(userID, baseAgent, req.Message, response)
 
 	writeJSON(w, http.StatusOK, map[string]string{"response": response})
 }
 
 func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
-	baseAgent := r.PathValue("name")
-	name := baseAgent
-	userID := "default"
+	name := r.PathValue("name")
+	userID, baseAgent := memoryOwner(r, name)
 
 	var req struct {
 		Message string `json:"message"`
@@ -279,34 +358,49 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "message is required"})
 		return
 	}
+	if refusal := s.screenInput(r.Context(), name, req.Message); refusal != "" {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: refusal})
+		return
+	}
 
-	proc, err := s.interp.EnsureAgent(name)
+	as, err := s.startChatStream(name, userID, baseAgent, req.Message)
 	if err != nil {
 		status, msg := classifyHTTPError(err)
 		writeJSON(w, status, ErrorResponse{Error: msg})
 		return
 	}
 
+	// --- SSE relay: subscribe and forward events to the connected client ---
+	s.relayStreamSSE(w, r, as)
+}
+
+// startChatStream sends message to the agent called name and returns the
+// server-side stream of the response. The stream runs detached from any
+// client connection, persists the response when it completes, and stays
+// registered under name briefly afterwards so late reconnects can see it.
+func (s *Server) startChatStream(name, userID, baseAgent, message string) (*activeStream, error) {
+	proc, err := s.interp.EnsureAgent(name)
+	if err != nil {
+		return nil, err
+	}
+
 	s.hydrateAgent(proc, name)
 
 	// Load and inject memory + project context into the process before sending.
-	var memTextStream string
-	if memories, err := s.store.GetUserMemory(userID, baseAgent); err == nil && len(memories) > 0 {
-		memTextStream = formatMemoryForInjection(memories)
-	}
+	memTextStream := userMemoryText(s.store, s.interp, userID, baseAgent)
 	projectCtxStream := buildProjectContext(s.interp.Tools().ActiveProject())
 	companyCtxStream := buildCompanyContext(s.company)
 	if extra := buildExtraSystem(memTextStream, projectCtxStream, companyCtxStream); extra != "" {
 		proc.SetExtraSystem(extra)
 	}
 
-	if err := s.store.InsertChatMessage(name, "user", req.Message); err != nil {
+	if err := s.saveChatMessage(name, "user", message); err != nil {
 		slog.Error("failed to persist user chat message", "agent", name, "error", err)
 	}
 
 	// Record original prompt to iris in prompt history (survives reset).
 	if baseAgent == "iris" {
-		if _, err := s.store.InsertPromptHistory(req.Message); err != nil {
+		if _, err := s.store.InsertPromptHistory(message); err != nil {
 			slog.Error("failed to persist prompt history", "error", err)
 		}
 	}
@@ -316,23 +410,23 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
 	ctx = ContextWithMemory(ctx, s.store, userID, baseAgent)
 	ctx = ContextWithDomainStore(ctx, s.sqliteStore)
+	ctx = vega.ContextWithUsage(ctx, s.usageRecorder(userID, baseAgent))
 
 	// Snapshot baseline metrics before the stream so we can compute per-response delta.
 	baseMetrics := proc.Metrics()
 	streamStart := time.Now()
 
-	stream, err := s.interp.StreamToAgent(ctx, name, req.Message)
+	stream, err := s.interp.StreamToAgent(ctx, name, message)
 	if err != nil {
 		cancel()
-		status, msg := classifyHTTPError(err)
-		writeJSON(w, status, ErrorResponse{Error: msg})
-		return
+		return nil, err
 	}
 
 	// Create a server-side active stream keyed by agent name.
 	as := &activeStream{
 		agentName: name,
 		done:      make(chan struct{}),
+		cancel:    cancel,
 	}
 
 	s.streamsMu.Lock()
@@ -345,13 +439,28 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 	go func() {
 		defer cancel()
 
-		for event := range stream.Events() {
-			as.publish(event)
-		}
+		policy := s.relayChatEvents(ctx, name, stream.Events(), as, cancel)
 
 		response := stream.Response()
 		streamErr := stream.Err()
 
+		// A blocked response is replaced by the policy message; what was
+		// generated before the abort is discarded.
+		if policy != "" {
+			response, streamErr = policy, nil
+		}
+
+		// Post-process before anything is stored or handed to reconnecting
+		// clients. If that fails, drop the response rather than keep it raw.
+		if response != "" && policy == "" {
+			processed, err := s.interp.PostProcessResponse(context.WithoutCancel(ctx), name, response)
+			if err != nil {
+				slog.Error("response post-processing failed", "agent", name, "error", err)
+				processed, streamErr = "", err
+			}
+			response = processed
+		}
+
 		// Compute per-response metrics delta.
 		finalMetrics := proc.Metrics()
 		delta := &vega.ChatEventMetrics{
@@ -361,37 +470,97 @@ func (s *Server) handleChatStream(w http.ResponseWriter, r *http.Request) {
 			DurationMs:   time.Since(streamStart).Milliseconds(),
 		}
 
+		// Closing done under the lock settles any race with stop: either
+		// the stop landed first and the response is marked interrupted, or
+		// the stream completed and stop reports it already finished.
 		as.mu.Lock()
+		interrupted := as.stopped
+		if interrupted {
+			response = markInterrupted(response)
+			streamErr = nil
+		}
 		as.response = response
 		as.err = streamErr
 		as.metrics = delta
-		as.mu.Unlock()
 		close(as.done)
+		as.mu.Unlock()
 		as.finish() // close all subscriber channels
 
 		// Persist assistant response even if no client is listening.
-		if streamErr != nil {
+		if interrupted {
+			if err := s.saveChatMessage(name, "assistant", response); err != nil {
+				slog.Error("failed to persist interrupted chat message", "agent", name, "error", err)
+			}
+		} else if policy != "" {
+			if err := s.saveChatMessage(name, "assistant", policy); err != nil {
+				slog.Error("failed to persist blocked chat message", "agent", name, "error", err)
+			}
+		} else if streamErr != nil {
 			slog.Error("stream completed with error, assistant response not saved",
 				"agent", name, "error", streamErr, "response_len", len(response))
 		} else if response == "" {
 			slog.Warn("stream completed with empty response, nothing to save", "agent", name)
 		} else {
-			if err := s.store.InsertChatMessage(name, "assistant", response); err != nil {
+			if err := s.saveChatMessage(name, "assistant", response); err != nil {
 				slog.Error("failed to persist assistant chat message", "agent", name, "error", err)
 			}
-			go s.extractMemory(userID, baseAgent, req.Message, response)
+			s.scheduleMemoryExtraction(userID, baseAgent, message, response)
 		}
 
-		// Keep the stream in the map briefly so late reconnects can see
-		// the final state, then remove it.
-		time.Sleep(30 * time.Second)
+		// Keep the stream in the map briefly so late reconnects can
+		// replay it and see the final state, then remove it.
+		time.Sleep(s.streamRetention())
 		s.streamsMu.Lock()
-		delete(s.streams, name)
+		if s.streams[name] == as {
+			delete(s.streams, name)
+		}
 		s.streamsMu.Unlock()
 	}()
 
-	// --- SSE relay: subscribe and forward events to the connected client ---
-	s.relayStreamSSE(w, r, as)
+	return as, nil
+}
+
+// defaultStreamRetention is how long a finished chat stream stays
+// replayable when Config.StreamRetention is unset.
+const defaultStreamRetention = 30 * time.Second
+
+func (s *Server) streamRetention() time.Duration {
+	if s.cfg.StreamRetention > 0 {
+		return s.cfg.StreamRetention
+	}
+	return defaultStreamRetention
+}
+
+// interruptedNote is appended to the persisted response of a stopped stream.
+const interruptedNote = "_[interrupted]_"
+
+// markInterrupted notes on a partial response that it was cut short.
+func markInterrupted(response string) string {
+	if strings.TrimSpace(response) == "" {
+		return interruptedNote
+	}
+	return strings.TrimRight(response, "\n") + "\n\n" + interruptedNote
+}
+
+// handleChatStop cancels an agent's in-progress chat stream. The partial
+// response is persisted with a note that it was interrupted.
+func (s *Server) handleChatStop(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	s.streamsMu.Lock()
+	as := s.streams[name]
+	s.streamsMu.Unlock()
+
+	if as == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("no active stream for agent '%s'", name)})
+		return
+	}
+	if !as.stop() {
+		// Finished between the lookup and the stop.
+		writeJSON(w, http.StatusOK, map[string]string{"status": "finished"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "stopped"})
 }
 
 // handleChatStatus returns whether an agent has an active (in-progress) stream.
@@ -465,18 +634,10 @@ func (s *Server) relayStreamSSE(w http.ResponseWriter, r *http.Request, as *acti
 	// If stream already finished, send final events and return.
 	select {
 	case <-as.done:
-		as.mu.Lock()
-		streamErr := as.err
-		doneMetrics := as.metrics
-		as.mu.Unlock()
-
-		if streamErr != nil {
-			_, friendlyMsg := classifyHTTPError(streamErr)
-			errData, _ := json.Marshal(vega.ChatEvent{Type: vega.ChatEventError, Error: friendlyMsg})
-			fmt.Fprintf(w, "event: error\ndata: %s\n\n", errData)
+		for _, event := range as.closingEvents() {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
 		}
-		doneData, _ := json.Marshal(vega.ChatEvent{Type: vega.ChatEventDone, Metrics: doneMetrics})
-		fmt.Fprintf(w, "event: done\ndata: %s\n\n", doneData)
 		flusher.Flush()
 		return
 	default:
@@ -488,20 +649,10 @@ func (s *Server) relayStreamSSE(w http.ResponseWriter, r *http.Request, as *acti
 		case event, ok := <-ch:
 			if !ok {
 				// Stream finished — send final events.
-				as.mu.Lock()
-				streamErr := as.err
-				doneMetrics := as.metrics
-				as.mu.Unlock()
-
-				if streamErr != nil {
-					_, friendlyMsg := classifyHTTPError(streamErr)
-					errData, _ := json.Marshal(vega.ChatEvent{Type: vega.ChatEventError, Error: friendlyMsg})
-					fmt.Fprintf(w, "event: error\ndata: %s\n\n", errData)
-					flusher.Flush()
+				for _, event := range as.closingEvents() {
+					data, _ := json.Marshal(event)
+					fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
 				}
-
-				doneData, _ := json.Marshal(vega.ChatEvent{Type: vega.ChatEventDone, Metrics: doneMetrics})
-				fmt.Fprintf(w, "event: done\ndata: %s\n\n", doneData)
 				flusher.Flush()
 				return
 			}
@@ -519,15 +670,187 @@ func (s *Server) relayStreamSSE(w http.ResponseWriter, r *http.Request, as *acti
 	}
 }
 
-func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
-	baseAgent := r.PathValue("name")
-	userID := r.URL.Query().Get("user")
+// Keepalive for chat WebSockets: the server pings every wsPingInterval and
+// drops a client it hasn't heard from, pongs included, for wsIdleTimeout.
+const (
+	wsPingInterval = 30 * time.Second
+	wsIdleTimeout  = 2 * wsPingInterval
+)
+
+// wsChatFrame is a client message on the chat WebSocket.
+type wsChatFrame struct {
+	// Type is "message" (the default), "stop" or "interrupt"
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+}
+
+// handleChatWS is a WebSocket transport for chat streaming, for clients
+// behind proxies that buffer SSE. The client sends {"type":"message",
+// "message":...} and receives the same ChatEvents as the SSE stream, ending
+// with done. {"type":"stop"} or {"type":"interrupt"} stops the response in
+// progress, like POST .../chat/stop. A connection carries any number of
+// messages in turn; if it drops, the response keeps running as with SSE.
+func (s *Server) handleChatWS(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	userID, baseAgent := memoryOwner(r, name)
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		slog.Debug("chat websocket upgrade failed", "agent", name, "error", err)
+		return
+	}
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	defer close(closed)
+	frames := make(chan wsChatFrame)
+	go func() {
+		defer close(frames)
+		for {
+			_, data, err := conn.ReadMessage(wsIdleTimeout)
+			if err != nil {
+				return
+			}
+			var f wsChatFrame
+			if err := json.Unmarshal(data, &f); err != nil {
+				conn.WriteJSON(vega.ChatEvent{Type: vega.ChatEventError, Error: "invalid frame: " + err.Error()})
+				continue
+			}
+			select {
+			case frames <- f:
+			case <-closed:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	var as *activeStream
+	var events chan vega.ChatEvent
+	defer func() {
+		if as != nil {
+			as.unsubscribe(events)
+		}
+	}()
+
+	// finish sends what is left of the current stream and its closing
+	// events, then detaches from it.
+	finish := func() error {
+		for drained := false; !drained; {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					drained = true
+				} else if err := conn.WriteJSON(event); err != nil {
+					return err
+				}
+			default:
+				drained = true
+			}
+		}
+		for _, event := range as.closingEvents() {
+			if err := conn.WriteJSON(event); err != nil {
+				return err
+			}
+		}
+		as.unsubscribe(events)
+		as, events = nil, nil
+		return nil
+	}
+
+	for {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				return
+			}
+			switch f.Type {
+			case "", "message":
+				if as != nil {
+					conn.WriteJSON(vega.ChatEvent{Type: vega.ChatEventError, Error: "a response is already streaming; stop it first"})
+					continue
+				}
+				if f.Message == "" {
+					conn.WriteJSON(vega.ChatEvent{Type: vega.ChatEventError, Error: "message is required"})
+					continue
+				}
+				if refusal := s.screenInput(r.Context(), name, f.Message); refusal != "" {
+					conn.WriteJSON(vega.ChatEvent{Type: vega.ChatEventError, Error: refusal})
+					continue
+				}
+				started, err := s.startChatStream(name, userID, baseAgent, f.Message)
+				if err != nil {
+					_, msg := classifyHTTPError(err)
+					conn.WriteJSON(vega.ChatEvent{Type: vega.ChatEventError, Error: msg})
+					continue
+				}
+				as = started
+				var history []vega.ChatEvent
+				history, events = as.subscribe()
+				for _, event := range history {
+					if err := conn.WriteJSON(event); err != nil {
+						return
+					}
+				}
+				// A stream that finished before we subscribed never
+				// closes our channel.
+				select {
+				case <-as.done:
+					if err := finish(); err != nil {
+						return
+					}
+				default:
+				}
+			case "stop", "interrupt":
+				if as != nil {
+					as.stop()
+				}
+			default:
+				conn.WriteJSON(vega.ChatEvent{Type: vega.ChatEventError, Error: fmt.Sprintf("unknown frame type %q", f.Type)})
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				if err := finish(); err != nil {
+					return
+				}
+				continue
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+
+		case <-ping.C:
+			if err := conn.Ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// memoryOwner returns the (userID, baseAgent) pair that keys user memory
+// for a request against the agent called name. Per-user agent processes are
+// named "<agent>:<user>" (see TelegramBot), so their memory belongs to that
+// user under the base agent. Otherwise the user comes from the ?user= query
+// parameter, then the X-Auth-User header, falling back to "default".
+func memoryOwner(r *http.Request, name string) (userID, baseAgent string) {
+	if agent, user, ok := strings.Cut(name, ":"); ok && agent != "" && user != "" {
+		return user, agent
+	}
+	userID = r.URL.Query().Get("user")
 	if userID == "" {
 		userID = r.Header.Get("X-Auth-User")
 	}
 	if userID == "" {
 		userID = "default"
 	}
+	return userID, name
+}
+
+func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
+	userID, baseAgent := memoryOwner(r, r.PathValue("name"))
 
 	memories, err := s.store.GetUserMemory(userID, baseAgent)
 	if err != nil {
@@ -546,14 +869,7 @@ func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleDeleteMemory(w http.ResponseWriter, r *http.Request) {
-	baseAgent := r.PathValue("name")
-	userID := r.URL.Query().Get("user")
-	if userID == "" {
-		userID = r.Header.Get("X-Auth-User")
-	}
-	if userID == "" {
-		userID = "default"
-	}
+	userID, baseAgent := memoryOwner(r, r.PathValue("name"))
 
 	if err := s.store.DeleteUserMemory(userID, baseAgent); err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
@@ -576,6 +892,56 @@ func (s *Server) handleChatHistory(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, msgs)
 }
 
+// Chat search defaults and caps for the limit and context parameters.
+const (
+	defaultChatSearchLimit   = 20
+	maxChatSearchLimit       = 100
+	defaultChatSearchContext = 2
+	maxChatSearchContext     = 10
+)
+
+// handleSearchChat searches an agent's chat history. Per-user agent
+// processes are named "<agent>:<user>", and only that user may search them.
+func (s *Server) handleSearchChat(w http.ResponseWriter, r *http.Request) {
+	// Only the caller's own chat is searched: the agent's shared chat for
+	// an anonymous caller, "agent:user" for a signed-in one.
+	userID, agent := memoryOwner(r, r.PathValue("name"))
+	authUser := r.Header.Get("X-Auth-User")
+	if userID != authUser && !(authUser == "" && userID == "default") {
+		writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "chat belongs to another user"})
+		return
+	}
+	name := agent
+	if authUser != "" {
+		name = agent + ":" + authUser
+	}
+
+	q := r.URL.Query()
+	query := strings.TrimSpace(q.Get("q"))
+	if query == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "q is required"})
+		return
+	}
+	limit := defaultChatSearchLimit
+	if n, err := strconv.Atoi(q.Get("limit")); err == nil && n > 0 {
+		limit = min(n, maxChatSearchLimit)
+	}
+	around := defaultChatSearchContext
+	if n, err := strconv.Atoi(q.Get("context")); err == nil && n >= 0 {
+		around = min(n, maxChatSearchContext)
+	}
+
+	hits, err := s.store.SearchChatMessages(name, query, limit, around)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if hits == nil {
+		hits = []ChatSearchHit{}
+	}
+	writeJSON(w, http.StatusOK, hits)
+}
+
 func (s *Server) handleClearChat(w http.ResponseWriter, r *http.Request) {
 	name := r.PathValue("name")
 
@@ -586,14 +952,47 @@ func (s *Server) handleClearChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Reset in-memory agent process so it starts fresh.
-	if err := s.interp.ResetAgent(name); err != nil {
+	if _, err := s.interp.ResetAgent(name); err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
 		return
 	}
 
+	// Chat history is keyed by agent name, memory by (user, base agent).
+	// Only clear the requesting user's memory, and only when asked to.
+	if r.URL.Query().Get("memory") == "true" {
+		userID, baseAgent := memoryOwner(r, name)
+		if err := s.store.DeleteUserMemory(userID, baseAgent); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+			return
+		}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]string{"status": "cleared"})
 }
 
+// handleResetAgent starts an agent over for a user: it clears the chat
+// history, the user's memory and scratchpad, and the agent's process, and
+// reports what was cleared. The store is cleared in one transaction before
+// the process is touched, so a failure leaves the process as it was.
+func (s *Server) handleResetAgent(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	userID, baseAgent := memoryOwner(r, name)
+
+	reset, err := s.store.ResetAgentState(name, userID, baseAgent)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	reset.ProcessReset, err = s.interp.ResetAgent(name)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reset)
+}
+
 // --- Workflow Handlers ---
 
 func (s *Server) handleListWorkflows(w http.ResponseWriter, r *http.Request) {
@@ -641,6 +1040,29 @@ func (s *Server) handleRunWorkflow(w http.ResponseWriter, r *http.Request) {
 
 	runID := uuid.New().String()[:8]
 
+	// A retried request with the same key gets the original run back.
+	key := r.Header.Get("Idempotency-Key")
+	if key == "" {
+		key = req.IdempotencyKey
+	}
+	if key != "" {
+		run, err := s.store.ClaimIdempotencyKey(name, key, runID, s.idempotencyTTL())
+		if err != nil {
+			slog.Error("claim idempotency key failed", "workflow", name, "error", err)
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to check idempotency key"})
+			return
+		}
+		if run.RunID != runID {
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeJSON(w, http.StatusOK, WorkflowRunResponse{
+				RunID:  run.RunID,
+				Status: run.Status,
+				Result: run.Result,
+			})
+			return
+		}
+	}
+
 	// Persist the run.
 	inputsJSON, _ := json.Marshal(req.Inputs)
 	s.store.InsertWorkflowRun(WorkflowRun{
@@ -651,10 +1073,21 @@ func (s *Server) handleRunWorkflow(w http.ResponseWriter, r *http.Request) {
 		StartedAt: time.Now(),
 	})
 
+	// Track step progress so clients can follow the run over SSE.
+	ws := &workflowRunStream{
+		runID:    runID,
+		workflow: name,
+		done:     make(chan struct{}),
+	}
+	s.runsMu.Lock()
+	s.runs[runID] = ws
+	s.runsMu.Unlock()
+
 	// Execute async.
 	go func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
 		defer cancel()
+		ctx = dsl.ContextWithRunID(ctx, runID)
 
 		result, err := s.interp.Execute(ctx, name, req.Inputs)
 
@@ -666,6 +1099,14 @@ func (s *Server) handleRunWorkflow(w http.ResponseWriter, r *http.Request) {
 		}
 
 		s.store.UpdateWorkflowRun(runID, status, resultStr)
+		ws.finish(status, resultStr)
+
+		// Keep the finished run briefly so late subscribers get the outcome.
+		time.AfterFunc(5*time.Minute, func() {
+			s.runsMu.Lock()
+			delete(s.runs, runID)
+			s.runsMu.Unlock()
+		})
 
 		s.broker.Publish(BrokerEvent{
 			Type:      "workflow." + status,
@@ -684,6 +1125,17 @@ func (s *Server) handleRunWorkflow(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// defaultIdempotencyTTL is how long an Idempotency-Key maps to its run
+// when Config.IdempotencyTTL is unset.
+const defaultIdempotencyTTL = 24 * time.Hour
+
+func (s *Server) idempotencyTTL() time.Duration {
+	if s.cfg.IdempotencyTTL > 0 {
+		return s.cfg.IdempotencyTTL
+	}
+	return defaultIdempotencyTTL
+}
+
 // --- MCP Handlers ---
 
 func (s *Server) handleMCPServers(w http.ResponseWriter, r *http.Request) {
@@ -726,8 +1178,8 @@ func (s *Server) handleMCPServers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Include disabled servers from persistence (not connected, but should be visible).
-	if sqlStore, ok := s.store.(*SQLiteStore); ok {
-		if servers, err := sqlStore.ListMCPServers(); err == nil {
+	if mcpStore, ok := s.store.(mcpServerStore); ok {
+		if servers, err := mcpStore.ListMCPServers(); err == nil {
 			for _, sc := range servers {
 				if sc.Disabled && !listed[sc.Name] {
 					resp = append(resp, MCPServerResponse{
@@ -749,9 +1201,11 @@ func (s *Server) handleMCPRegistry(w http.ResponseWriter, r *http.Request) {
 
 	// Load existing settings for pre-filling env fields.
 	settingsMap := make(map[string]string)
-	if settings, err := s.store.ListSettings(); err == nil {
-		for _, st := range settings {
-			settingsMap[st.Key] = st.Key // just indicate existence, don't leak values
+	if s.store != nil {
+		if settings, err := s.store.ListSettings(); err == nil {
+			for _, st := range settings {
+				settingsMap[st.Key] = st.Key // just indicate existence, don't leak values
+			}
 		}
 	}
 
@@ -935,8 +1389,8 @@ func (s *Server) handleDisconnectMCPServer(w http.ResponseWriter, r *http.Reques
 	}
 
 	// Remove from persistence so it won't auto-reconnect on restart.
-	if sqlStore, ok := s.store.(*SQLiteStore); ok {
-		sqlStore.DeleteMCPServer(name)
+	if mcpStore, ok := s.store.(mcpServerStore); ok {
+		mcpStore.DeleteMCPServer(name)
 	}
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "disconnected"})
@@ -952,13 +1406,13 @@ func (s *Server) handleRefreshMCPServer(w http.ResponseWriter, r *http.Request)
 	t := s.interp.Tools()
 
 	// Load persisted config so we can reconnect after disconnect.
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "persistence not available"})
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: storeUnavailableMsg})
 		return
 	}
 
-	servers, err := sqlStore.ListMCPServers()
+	servers, err := mcpStore.ListMCPServers()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load server configs"})
 		return
@@ -1096,13 +1550,13 @@ func (s *Server) handleGetMCPServerConfig(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "persistence not available"})
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: storeUnavailableMsg})
 		return
 	}
 
-	servers, err := sqlStore.ListMCPServers()
+	servers, err := mcpStore.ListMCPServers()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load server configs"})
 		return
@@ -1243,8 +1697,8 @@ func (s *Server) handleUpdateMCPServer(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 		}
-		if sqlStore, ok := s.store.(*SQLiteStore); ok {
-			if err := sqlStore.DeleteMCPServer(name); err != nil {
+		if mcpStore, ok := s.store.(mcpServerStore); ok {
+			if err := mcpStore.DeleteMCPServer(name); err != nil {
 				slog.Error("update: delete old server config failed", "server", name, "error", err)
 			}
 		}
@@ -1252,8 +1706,8 @@ func (s *Server) handleUpdateMCPServer(w http.ResponseWriter, r *http.Request) {
 
 	// Load persisted config to get all known env keys (the request only has changed values).
 	envKeySet := make(map[string]bool)
-	if sqlStore, ok := s.store.(*SQLiteStore); ok {
-		if servers, err := sqlStore.ListMCPServers(); err == nil {
+	if mcpStore, ok := s.store.(mcpServerStore); ok {
+		if servers, err := mcpStore.ListMCPServers(); err == nil {
 			for _, sc := range servers {
 				if sc.Name == name {
 					var persisted ConnectMCPRequest
@@ -1433,13 +1887,13 @@ func (s *Server) handleDuplicateMCPServer(w http.ResponseWriter, r *http.Request
 	}
 
 	// Load persisted config of the source server.
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "persistence not available"})
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: storeUnavailableMsg})
 		return
 	}
 
-	servers, err := sqlStore.ListMCPServers()
+	servers, err := mcpStore.ListMCPServers()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load server configs"})
 		return
@@ -1562,13 +2016,13 @@ func (s *Server) handleToggleMCPServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
-		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "persistence not available"})
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: storeUnavailableMsg})
 		return
 	}
 
-	if err := sqlStore.SetMCPServerDisabled(name, body.Disabled); err != nil {
+	if err := mcpStore.SetMCPServerDisabled(name, body.Disabled); err != nil {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -1588,7 +2042,7 @@ func (s *Server) handleToggleMCPServer(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Re-enable: reconnect the server.
-	servers, err := sqlStore.ListMCPServers()
+	servers, err := mcpStore.ListMCPServers()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "failed to load server configs"})
 		return
@@ -1742,7 +2196,7 @@ func (s *Server) buildMCPEnvMap(serverName string, reqEnv map[string]string) map
 
 // persistMCPServer saves the MCP server connect request so it auto-reconnects on restart.
 func (s *Server) persistMCPServer(req ConnectMCPRequest) {
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
 		return
 	}
@@ -1758,44 +2212,71 @@ func (s *Server) persistMCPServer(req ConnectMCPRequest) {
 		slog.Error("failed to marshal MCP server config", "name", req.Name, "error", err)
 		return
 	}
-	if err := sqlStore.UpsertMCPServer(req.Name, string(configJSON)); err != nil {
+	if err := mcpStore.UpsertMCPServer(req.Name, string(configJSON)); err != nil {
 		slog.Error("failed to persist MCP server", "name", req.Name, "error", err)
 	}
 }
 
 // --- Stats Handler ---
 
+// handleStats totals the live processes. ?group_by=label.<key> also breaks
+// the totals down by the value of that label.
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
-	procs := s.interp.Orchestrator().List()
+	groupBy := r.URL.Query().Get("group_by")
+	labelKey, ok := strings.CutPrefix(groupBy, "label.")
+	if groupBy != "" && (!ok || labelKey == "") {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "group_by must be label.<key>"})
+		return
+	}
 
+	procs := s.interp.Orchestrator().List()
 	stats := StatsResponse{
-		TotalProcesses: len(procs),
-		Uptime:         time.Since(s.startedAt).Truncate(time.Second).String(),
+		Uptime:  time.Since(s.startedAt).Truncate(time.Second).String(),
+		GroupBy: groupBy,
 	}
 
+	groups := make(map[string]*ProcessTotals)
 	for _, p := range procs {
-		switch p.Status() {
-		case vega.StatusRunning, vega.StatusPending:
-			stats.RunningProcesses++
-		case vega.StatusCompleted:
-			stats.CompletedProcesses++
-		case vega.StatusFailed, vega.StatusTimeout:
-			stats.FailedProcesses++
+		stats.add(p)
+		if labelKey == "" {
+			continue
 		}
+		value := p.Labels[labelKey]
+		if groups[value] == nil {
+			groups[value] = &ProcessTotals{}
+		}
+		groups[value].add(p)
+	}
 
-		m := p.Metrics()
-		stats.TotalInputTokens += m.InputTokens
-		stats.TotalOutputTokens += m.OutputTokens
-		stats.TotalCacheCreationTokens += m.CacheCreationInputTokens
-		stats.TotalCacheReadTokens += m.CacheReadInputTokens
-		stats.TotalCostUSD += m.CostUSD
-		stats.TotalToolCalls += m.ToolCalls
-		stats.TotalErrors += m.Errors
+	for _, value := range slices.Sorted(maps.Keys(groups)) {
+		stats.Groups = append(stats.Groups, StatsGroup{Value: value, ProcessTotals: *groups[value]})
 	}
 
 	writeJSON(w, http.StatusOK, stats)
 }
 
+// add counts p and its usage into the totals.
+func (t *ProcessTotals) add(p *vega.Process) {
+	t.TotalProcesses++
+	switch p.Status() {
+	case vega.StatusRunning, vega.StatusPending:
+		t.RunningProcesses++
+	case vega.StatusCompleted:
+		t.CompletedProcesses++
+	case vega.StatusFailed, vega.StatusTimeout:
+		t.FailedProcesses++
+	}
+
+	m := p.Metrics()
+	t.TotalInputTokens += m.InputTokens
+	t.TotalOutputTokens += m.OutputTokens
+	t.TotalCacheCreationTokens += m.CacheCreationInputTokens
+	t.TotalCacheReadTokens += m.CacheReadInputTokens
+	t.TotalCostUSD += m.CostUSD
+	t.TotalToolCalls += m.ToolCalls
+	t.TotalErrors += m.Errors
+}
+
 // --- Spawn Tree Handler ---
 
 func (s *Server) handleSpawnTree(w http.ResponseWriter, r *http.Request) {
@@ -1809,6 +2290,28 @@ func (s *Server) handleSpawnTree(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+func (s *Server) handleListSupervisors(w http.ResponseWriter, r *http.Request) {
+	supervisors := s.interp.Orchestrator().Supervisors()
+
+	resp := make([]SupervisorResponse, 0, len(supervisors))
+	for _, sup := range supervisors {
+		resp = append(resp, supervisorToResponse(sup.Status()))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleListIncidents(w http.ResponseWriter, r *http.Request) {
+	incidents := s.interp.Orchestrator().Incidents()
+
+	resp := make([]IncidentResponse, 0, len(incidents))
+	for _, inc := range incidents {
+		resp = append(resp, incidentToResponse(inc))
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
 // --- Settings Handlers ---
 
 func (s *Server) handleListSettings(w http.ResponseWriter, r *http.Request) {
@@ -1928,18 +2431,87 @@ func (s *Server) handleToggleSchedule(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+func (s *Server) handleListScheduleRuns(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if name == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "schedule name is required"})
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	runs, err := s.store.ListScheduleRuns(name, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if runs == nil {
+		runs = []ScheduleRun{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
 // --- Agent Template Handlers ---
 
 func (s *Server) handleExportTemplate(w http.ResponseWriter, r *http.Request) {
-	name := r.PathValue("name")
-	doc := s.interp.Document()
+	tmpl, ok := s.agentTemplate(r.PathValue("name"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("agent %q not found", r.PathValue("name"))})
+		return
+	}
+	writeJSON(w, http.StatusOK, tmpl)
+}
 
-	agentDef, ok := doc.Agents[name]
+// handleExportAgent returns the agent's template together with its
+// composed-agent settings, chat history and the requesting user's memory.
+// The result can be posted back to /api/agents/import on any instance.
+func (s *Server) handleExportAgent(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	tmpl, ok := s.agentTemplate(name)
 	if !ok {
 		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("agent %q not found", name)})
 		return
 	}
 
+	composed, err := s.store.ListComposedAgents()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	for _, a := range composed {
+		if a.Name == name {
+			tmpl.Avatar = a.Avatar
+			tmpl.Persona = a.Persona
+			tmpl.Skills = a.Skills
+			tmpl.Temperature = a.Temperature
+			break
+		}
+	}
+
+	if tmpl.Chat, err = s.store.ListChatMessages(name); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	userID, baseAgent := memoryOwner(r, name)
+	if tmpl.Memory, err = s.store.GetUserMemory(userID, baseAgent); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, tmpl)
+}
+
+// agentTemplate builds the portable template for a loaded agent.
+func (s *Server) agentTemplate(name string) (AgentTemplateResponse, bool) {
+	agentDef, ok := s.interp.Document().Agents[name]
+	if !ok {
+		return AgentTemplateResponse{}, false
+	}
+
 	// Filter out MCP-specific tools (contain "__" separator).
 	var portableTools []string
 	for _, t := range agentDef.Tools {
@@ -1953,28 +2525,35 @@ func (s *Server) handleExportTemplate(w http.ResponseWriter, r *http.Request) {
 		companyName = s.company.Name
 	}
 
-	tmpl := AgentTemplateResponse{
+	return AgentTemplateResponse{
 		Version:     "1",
 		Name:        name,
 		DisplayName: agentDef.DisplayName,
 		Title:       agentDef.Title,
+		Avatar:      agentDef.Avatar,
 		Model:       agentDef.Model,
 		System:      agentDef.System,
 		Tools:       portableTools,
 		Team:        agentDef.Team,
+		Temperature: agentDef.Temperature,
 		ExportedBy:  companyName,
 		ExportedAt:  time.Now().UTC().Format(time.RFC3339),
-	}
-
-	writeJSON(w, http.StatusOK, tmpl)
+	}, true
 }
 
+// handleImportTemplate creates an agent from a template or a full export.
+// Chat history and memory layers in the body are restored under the new
+// agent. ?rename=<name> imports under a different name, e.g. to avoid a
+// collision with an existing agent.
 func (s *Server) handleImportTemplate(w http.ResponseWriter, r *http.Request) {
 	var tmpl AgentTemplateResponse
 	if err := json.NewDecoder(r.Body).Decode(&tmpl); err != nil {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "invalid JSON body"})
 		return
 	}
+	if rename := r.URL.Query().Get("rename"); rename != "" {
+		tmpl.Name = rename
+	}
 
 	if tmpl.Name == "" || tmpl.Model == "" || tmpl.System == "" {
 		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "name, model, and system are required"})
@@ -1984,7 +2563,7 @@ func (s *Server) handleImportTemplate(w http.ResponseWriter, r *http.Request) {
 	// Check if agent already exists.
 	doc := s.interp.Document()
 	if _, exists := doc.Agents[tmpl.Name]; exists {
-		writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("agent %q already exists", tmpl.Name)})
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: fmt.Sprintf("agent %q already exists; pass ?rename=<name> to import under another name", tmpl.Name)})
 		return
 	}
 
@@ -1993,28 +2572,52 @@ func (s *Server) handleImportTemplate(w http.ResponseWriter, r *http.Request) {
 		Name:        tmpl.Name,
 		DisplayName: tmpl.DisplayName,
 		Title:       tmpl.Title,
+		Avatar:      tmpl.Avatar,
 		Model:       tmpl.Model,
 		System:      tmpl.System,
 		Tools:       tmpl.Tools,
 		Team:        tmpl.Team,
+		Temperature: tmpl.Temperature,
 	}
 
-	s.interp.AddAgent(tmpl.Name, agentDef)
+	if err := s.interp.AddAgent(tmpl.Name, agentDef); err != nil {
+		writeJSON(w, http.StatusConflict, ErrorResponse{Error: err.Error()})
+		return
+	}
 
 	// Persist as composed agent.
 	if err := s.store.InsertComposedAgent(ComposedAgent{
 		Name:        agentDef.Name,
 		DisplayName: agentDef.DisplayName,
 		Title:       agentDef.Title,
+		Avatar:      agentDef.Avatar,
 		Model:       agentDef.Model,
+		Persona:     tmpl.Persona,
+		Skills:      tmpl.Skills,
 		System:      agentDef.System,
 		Tools:       agentDef.Tools,
 		Team:        agentDef.Team,
+		Temperature: agentDef.Temperature,
 		CreatedAt:   time.Now(),
 	}); err != nil {
 		slog.Error("failed to persist imported agent", "agent", agentDef.Name, "error", err)
 	}
 
+	if err := s.store.InsertChatMessages(agentDef.Name, tmpl.Chat); err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "agent imported but failed to restore chat: " + err.Error()})
+		return
+	}
+	for _, m := range tmpl.Memory {
+		userID := m.UserID
+		if userID == "" {
+			userID = "default"
+		}
+		if err := s.store.UpsertUserMemory(userID, agentDef.Name, m.Layer, m.Content); err != nil {
+			writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "agent imported but failed to restore memory: " + err.Error()})
+			return
+		}
+	}
+
 	s.broker.Publish(BrokerEvent{
 		Type:      "agent.created",
 		Agent:     agentDef.Name,
@@ -2077,7 +2680,9 @@ func processToResponse(p *vega.Process) ProcessResponse {
 		StartedAt:   p.StartedAt,
 		ParentID:    p.ParentID,
 		SpawnDepth:  p.SpawnDepth,
-		SpawnReason: p.SpawnReason,
+		SpawnReason: string(p.SpawnReason),
+		SpawnDetail: p.SpawnDetail,
+		Labels:      p.Labels,
 		Metrics: MetricsResponse{
 			Iterations:   m.Iterations,
 			InputTokens:  m.InputTokens,
@@ -2086,6 +2691,9 @@ func processToResponse(p *vega.Process) ProcessResponse {
 			ToolCalls:    m.ToolCalls,
 			Errors:       m.Errors,
 			LastActiveAt: m.LastActiveAt,
+
+			ContextTokens: m.ContextTokens,
+			ContextWindow: m.ContextWindow,
 		},
 	}
 	if !m.CompletedAt.IsZero() {
@@ -2105,12 +2713,60 @@ func treeNodeToResponse(node *vega.SpawnTreeNode) SpawnTreeNodeResponse {
 		Task:        node.Task,
 		Status:      string(node.Status),
 		SpawnDepth:  node.SpawnDepth,
-		SpawnReason: node.SpawnReason,
+		SpawnReason: string(node.SpawnReason),
+		SpawnDetail: node.SpawnDetail,
 		StartedAt:   node.StartedAt,
 		Children:    children,
 	}
 }
 
+func supervisorToResponse(st vega.SupervisorStatus) SupervisorResponse {
+	children := make([]SupervisedChildResponse, 0, len(st.Children))
+	for _, child := range st.Children {
+		children = append(children, SupervisedChildResponse{
+			Name:      child.Name,
+			ProcessID: child.ID,
+			Agent:     child.Agent,
+			Status:    string(child.Status),
+			Restart:   child.Restart.String(),
+			Restarts:  child.Restarts,
+		})
+	}
+	resp := SupervisorResponse{
+		ID:          st.ID,
+		Strategy:    st.Strategy.String(),
+		MaxRestarts: st.MaxRestarts,
+		Restarts:    st.Restarts,
+		GaveUp:      st.GaveUp,
+		Children:    children,
+	}
+	if st.Window > 0 {
+		resp.Window = st.Window.String()
+	}
+	return resp
+}
+
+func incidentToResponse(inc vega.Incident) IncidentResponse {
+	affected := make([]AffectedProcessResponse, 0, len(inc.Affected))
+	for _, a := range inc.Affected {
+		affected = append(affected, AffectedProcessResponse{
+			ProcessID: a.ProcessID,
+			Agent:     a.Agent,
+			LinkedID:  a.LinkedID,
+			DiedAt:    a.DiedAt,
+		})
+	}
+	return IncidentResponse{
+		ID:            inc.ID,
+		RootProcessID: inc.RootProcessID,
+		RootAgent:     inc.RootAgent,
+		RootError:     inc.RootError,
+		Affected:      affected,
+		StartedAt:     inc.StartedAt,
+		UpdatedAt:     inc.UpdatedAt,
+	}
+}
+
 // classifyHTTPError maps an error to an HTTP status code and user-friendly message
 // using vega.ClassifyError.
 func classifyHTTPError(err error) (int, string) {
@@ -2128,6 +2784,12 @@ func classifyHTTPError(err error) (int, string) {
 	case vega.ErrClassBudgetExceeded:
 		return http.StatusPaymentRequired,
 			"Budget exceeded for this agent."
+	case vega.ErrClassRefusal:
+		return http.StatusUnprocessableEntity,
+			"The model declined this request."
+	case vega.ErrClassContextWindow:
+		return http.StatusRequestEntityTooLarge,
+			"Conversation too long, try clearing history."
 	default:
 		return http.StatusInternalServerError, err.Error()
 	}
@@ -2139,6 +2801,36 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// --- Admin Handlers ---
+
+// handleBackup snapshots the database into a timestamped file in the
+// backups directory next to it.
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	if s.store == nil {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: storeUnavailableMsg})
+		return
+	}
+	if s.sqliteStore == nil {
+		writeJSON(w, http.StatusNotImplemented, ErrorResponse{Error: "backups are only supported for SQLite; back up Postgres with pg_dump"})
+		return
+	}
+
+	name := "vega-" + time.Now().UTC().Format("20060102-150405") + ".db"
+	dest := filepath.Join(filepath.Dir(s.cfg.DBPath), "backups", name)
+	if err := s.sqliteStore.Backup(dest); err != nil {
+		slog.Error("backup failed", "path", dest, "error", err)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	resp := map[string]any{"status": "ok", "path": dest}
+	if info, err := os.Stat(dest); err == nil {
+		resp["size_bytes"] = info.Size()
+	}
+	slog.Info("backup written", "path", dest)
+	writeJSON(w, http.StatusOK, resp)
+}
+
 // --- Reset Handler ---
 
 func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
@@ -2147,7 +2839,7 @@ func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
 	// 1. Kill all agent processes and remove composed (non-YAML) agents.
 	s.interp.RemoveComposedAgents()
 	for name := range s.interp.Agents() {
-		if err := s.interp.ResetAgent(name); err != nil {
+		if _, err := s.interp.ResetAgent(name); err != nil {
 			slog.Warn("reset: failed to reset agent", "agent", name, "error", err)
 		}
 	}
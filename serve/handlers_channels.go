@@ -385,11 +385,7 @@ func (s *Server) runChannelAgentStreamed(ch *Channel, cs *channelStream, agentNa
 	}
 	s.hydrateAgent(proc, agentName)
 
-	userID := "default"
-	var memText string
-	if memories, err := s.store.GetUserMemory(userID, agentName); err == nil && len(memories) > 0 {
-		memText = formatMemoryForInjection(memories)
-	}
+	memText := userMemoryText(s.store, s.interp, "default", agentName)
 	companyCtx := buildCompanyContext(s.company)
 	if extra := buildExtraSystem(memText, "", companyCtx); extra != "" {
 		proc.SetExtraSystem(extra)
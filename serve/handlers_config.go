@@ -63,9 +63,11 @@ func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Build agent list.
 	composedMap := make(map[string]bool)
-	if composed, err := s.store.ListComposedAgents(); err == nil {
-		for _, a := range composed {
-			composedMap[a.Name] = true
+	if s.store != nil {
+		if composed, err := s.store.ListComposedAgents(); err == nil {
+			for _, a := range composed {
+				composedMap[a.Name] = true
+			}
 		}
 	}
 
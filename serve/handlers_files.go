@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	vega "github.com/everydev1618/govega"
@@ -203,6 +204,28 @@ func (s *Server) handleListFileMetadata(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// handleListToolCalls returns recent tool calls, newest first, optionally
+// filtered by agent.
+func (s *Server) handleListToolCalls(w http.ResponseWriter, r *http.Request) {
+	agent := r.URL.Query().Get("agent")
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	calls, err := s.store.ListToolCalls(agent, limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if calls == nil {
+		calls = []ToolCall{}
+	}
+	writeJSON(w, http.StatusOK, calls)
+}
+
 // handleWorkspaceStatic serves raw files from the workspace directory.
 // This allows agents to produce deliverables (HTML sites, images, etc.) that
 // are accessible via direct URLs like /workspace/project/index.html.
@@ -0,0 +1,29 @@
+package serve
+
+import (
+	"context"
+	"log/slog"
+)
+
+// InputGuard screens a user's chat message before it reaches an agent, for
+// prompt-injection or policy checks. Returning allow false refuses the
+// message without calling the LLM; reason is shown to the user.
+type InputGuard func(ctx context.Context, agentName, message string) (allow bool, reason string)
+
+// screenInput runs the configured InputGuard, if any, and returns the
+// refusal to show the user, or "" if the message may go through.
+func (s *Server) screenInput(ctx context.Context, agentName, message string) string {
+	if s.cfg.InputGuard == nil {
+		return ""
+	}
+	allow, reason := s.cfg.InputGuard(ctx, agentName, message)
+	if allow {
+		return ""
+	}
+
+	slog.Warn("chat message refused by input guard", "agent", agentName, "reason", reason)
+	if reason == "" {
+		return "message refused"
+	}
+	return "message refused: " + reason
+}
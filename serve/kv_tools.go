@@ -0,0 +1,141 @@
+package serve
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/everydev1618/govega/dsl"
+	"github.com/everydev1618/govega/tools"
+)
+
+// maxKVValueSize caps a single scratchpad value.
+const maxKVValueSize = 64 * 1024
+
+// RegisterKVTools registers kv_set, kv_get, and kv_delete on the
+// interpreter's global tool collection. Unlike remember/recall, values are
+// stored verbatim under an exact key, scoped to the user and agent carried
+// by ContextWithMemory.
+func RegisterKVTools(interp *dsl.Interpreter) {
+	t := interp.Tools()
+
+	t.Register("kv_set", tools.ToolDef{
+		Description: "Store a value in your scratchpad under an exact key, replacing any previous value. Use it for state you need to read back exactly on later turns: counters, IDs, intermediate results. Values are stored verbatim; use JSON for structured data.",
+		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			store, userID, agent, err := memoryFromContext(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			key, _ := params["key"].(string)
+			if key == "" {
+				return "", fmt.Errorf("key is required")
+			}
+			value, err := kvValue(params["value"])
+			if err != nil {
+				return "", err
+			}
+			if len(value) > maxKVValueSize {
+				return "", fmt.Errorf("value is %d bytes; the limit is %d", len(value), maxKVValueSize)
+			}
+
+			if err := store.UpsertKV(userID, agent, key, value); err != nil {
+				return "", fmt.Errorf("save value: %w", err)
+			}
+			return fmt.Sprintf("Stored %q (%d bytes).", key, len(value)), nil
+		}),
+		Params: map[string]tools.ParamDef{
+			"key": {
+				Type:        "string",
+				Description: "Key to store the value under (e.g. 'draft_count', 'last_invoice_id')",
+				Required:    true,
+			},
+			"value": {
+				Type:        "string",
+				Description: "Value to store; JSON for structured data",
+				Required:    true,
+			},
+		},
+	})
+
+	t.Register("kv_get", tools.ToolDef{
+		Description: "Read a value from your scratchpad by its exact key. Returns the value exactly as stored.",
+		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			store, userID, agent, err := memoryFromContext(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			key, _ := params["key"].(string)
+			if key == "" {
+				return "", fmt.Errorf("key is required")
+			}
+
+			value, ok, err := store.GetKV(userID, agent, key)
+			if err != nil {
+				return "", fmt.Errorf("read value: %w", err)
+			}
+			if !ok {
+				return fmt.Sprintf("No value stored for %q.", key), nil
+			}
+			return value, nil
+		}),
+		Params: map[string]tools.ParamDef{
+			"key": {
+				Type:        "string",
+				Description: "Key to read",
+				Required:    true,
+			},
+		},
+	})
+
+	t.Register("kv_delete", tools.ToolDef{
+		Description: "Delete a value from your scratchpad by its exact key.",
+		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			store, userID, agent, err := memoryFromContext(ctx)
+			if err != nil {
+				return "", err
+			}
+
+			key, _ := params["key"].(string)
+			if key == "" {
+				return "", fmt.Errorf("key is required")
+			}
+
+			if err := store.DeleteKV(userID, agent, key); err != nil {
+				if errors.Is(err, sql.ErrNoRows) {
+					return fmt.Sprintf("No value stored for %q.", key), nil
+				}
+				return "", fmt.Errorf("delete value: %w", err)
+			}
+			return fmt.Sprintf("Deleted %q.", key), nil
+		}),
+		Params: map[string]tools.ParamDef{
+			"key": {
+				Type:        "string",
+				Description: "Key to delete",
+				Required:    true,
+			},
+		},
+	})
+}
+
+// kvValue converts a kv_set value to its stored form. Strings are kept as
+// they are; models sometimes pass numbers or objects directly, and those
+// are stored as JSON.
+func kvValue(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", fmt.Errorf("value is required")
+	case string:
+		return v, nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("encode value: %w", err)
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,57 @@
+package serve
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+func TestKVToolsRoundTripAndScope(t *testing.T) {
+	store := newTestStore(t)
+	interp, err := dsl.NewInterpreter(&dsl.Document{Agents: map[string]*dsl.Agent{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterKVTools(interp)
+	tl := interp.Tools()
+
+	alice := ContextWithMemory(context.Background(), store, "default", "alice")
+	bob := ContextWithMemory(context.Background(), store, "default", "bob")
+
+	value := `{"drafts":3,"last":"inv-42"}`
+	if _, err := tl.Execute(alice, "kv_set", map[string]any{"key": "progress", "value": value}); err != nil {
+		t.Fatalf("kv_set: %v", err)
+	}
+	got, err := tl.Execute(alice, "kv_get", map[string]any{"key": "progress"})
+	if err != nil {
+		t.Fatalf("kv_get: %v", err)
+	}
+	if got != value {
+		t.Errorf("kv_get = %q, want %q", got, value)
+	}
+
+	// Another agent sees its own scratchpad, not alice's.
+	if got, _ := tl.Execute(bob, "kv_get", map[string]any{"key": "progress"}); !strings.HasPrefix(got, "No value") {
+		t.Errorf("bob's kv_get = %q, want no value", got)
+	}
+
+	// Non-string values are stored as JSON.
+	if _, err := tl.Execute(bob, "kv_set", map[string]any{"key": "count", "value": float64(7)}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tl.Execute(bob, "kv_get", map[string]any{"key": "count"}); got != "7" {
+		t.Errorf("kv_get count = %q, want 7", got)
+	}
+
+	if _, err := tl.Execute(alice, "kv_delete", map[string]any{"key": "progress"}); err != nil {
+		t.Fatalf("kv_delete: %v", err)
+	}
+	if got, _ := tl.Execute(alice, "kv_get", map[string]any{"key": "progress"}); !strings.HasPrefix(got, "No value") {
+		t.Errorf("after delete kv_get = %q, want no value", got)
+	}
+	if _, ok, _ := store.GetKV("default", "bob", "count"); !ok {
+		t.Error("deleting alice's key removed bob's")
+	}
+}
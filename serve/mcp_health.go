@@ -0,0 +1,40 @@
+package serve
+
+import (
+	"context"
+	"time"
+)
+
+// mcpHealthInterval is how often connected MCP servers are checked.
+const mcpHealthInterval = time.Minute
+
+// runMCPHealth checks MCP servers on a fixed interval until ctx is done,
+// reconnecting dead ones. Each transition is published as an
+// "mcp.disconnected" or "mcp.connected" event so the UI can follow it.
+func (s *Server) runMCPHealth(ctx context.Context) {
+	ticker := time.NewTicker(mcpHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.interp.Tools().CheckMCPHealth(ctx, s.publishMCPStatus)
+	}
+}
+
+// publishMCPStatus publishes an MCP server state transition.
+func (s *Server) publishMCPStatus(server string, connected bool, err error) {
+	event := BrokerEvent{
+		Type:      "mcp.connected",
+		Data:      map[string]string{"server": server},
+		Timestamp: time.Now(),
+	}
+	if !connected {
+		event.Type = "mcp.disconnected"
+		event.Data = map[string]string{"server": server, "error": err.Error()}
+	}
+	s.broker.Publish(event)
+}
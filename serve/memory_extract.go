@@ -26,17 +26,78 @@ type topicUpdate struct {
 	Tags    []string `json:"tags"`
 }
 
-// extractMemory runs an async LLM call to extract memory from the latest exchange.
-func (s *Server) extractMemory(userID, agent, userMsg, response string) {
-	// Only one extraction at a time; skip if another is in progress.
-	select {
-	case s.extractSem <- struct{}{}:
-		defer func() { <-s.extractSem }()
-	default:
-		slog.Debug("memory extraction skipped: another extraction in progress")
+// defaultExtractQueue is how many chat turns wait for memory extraction
+// when Config.MemoryExtractQueue is unset.
+const defaultExtractQueue = 16
+
+// extractJob is a chat turn waiting for memory extraction.
+type extractJob struct {
+	userID, agent, userMsg, response string
+}
+
+// scheduleMemoryExtraction extracts memory from a chat turn. With
+// Config.SyncMemoryExtract it runs the extraction before returning.
+// Otherwise it queues the turn without blocking: at most
+// Config.MemoryExtractWorkers extractions run at once, and when the queue
+// is full the oldest waiting turn is dropped to make room.
+func (s *Server) scheduleMemoryExtraction(userID, agent, userMsg, response string) {
+	if !agentUsesMemory(s.interp, agent) {
+		return
+	}
+	if s.cfg.SyncMemoryExtract {
+		s.extractMemory(userID, agent, userMsg, response)
 		return
 	}
+	s.extractWorkers.Do(s.startExtractWorkers)
+
+	job := extractJob{userID: userID, agent: agent, userMsg: userMsg, response: response}
+	s.extractPending.Add(1)
+	for {
+		select {
+		case s.extractQueue <- job:
+			return
+		default:
+		}
+		select {
+		case dropped := <-s.extractQueue:
+			slog.Warn("memory extraction dropped: queue full", "user", dropped.userID, "agent", dropped.agent)
+			s.extractPending.Add(-1)
+		default:
+		}
+	}
+}
 
+// startExtractWorkers starts the goroutines that run queued extractions.
+func (s *Server) startExtractWorkers() {
+	workers := max(s.cfg.MemoryExtractWorkers, 1)
+	for range workers {
+		go func() {
+			for job := range s.extractQueue {
+				s.extractMemory(job.userID, job.agent, job.userMsg, job.response)
+				s.extractPending.Add(-1)
+			}
+		}()
+	}
+}
+
+// WaitMemoryExtraction blocks until every queued memory extraction has
+// finished or been dropped, or ctx is done. Tests use it to read memory
+// right after a chat without Config.SyncMemoryExtract.
+func (s *Server) WaitMemoryExtraction(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for s.extractPending.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// extractMemory runs an LLM call to extract memory from the latest exchange.
+func (s *Server) extractMemory(userID, agent, userMsg, response string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -0,0 +1,161 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/everydev1618/govega/dsl"
+	"github.com/everydev1618/govega/llm"
+)
+
+// gatedLLM blocks every Generate call until release is closed, tracking
+// how many calls are in flight at once.
+type gatedLLM struct {
+	stepLLM
+	release chan struct{}
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	calls    int
+}
+
+func (l *gatedLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	l.mu.Lock()
+	l.inFlight++
+	l.calls++
+	l.peak = max(l.peak, l.inFlight)
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.inFlight--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case <-l.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &llm.LLMResponse{Content: "{}"}, nil
+}
+
+func (l *gatedLLM) stats() (inFlight, peak, calls int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight, l.peak, l.calls
+}
+
+func TestMemoryExtractionConcurrency(t *testing.T) {
+	gate := &gatedLLM{release: make(chan struct{})}
+	s := New(nil, Config{MemoryExtractWorkers: 2, MemoryExtractQueue: 3})
+	s.store = newTestStore(t)
+	s.extractLLMMu.Do(func() { s.extractLLM = gate })
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// Occupy both workers, then burst more turns than the queue holds.
+	for i := range 2 {
+		s.scheduleMemoryExtraction("u1", "support", fmt.Sprintf("turn %d", i), "ok")
+	}
+	waitFor("both workers to start", func() bool { n, _, _ := gate.stats(); return n == 2 })
+	for i := range 10 {
+		s.scheduleMemoryExtraction("u1", "support", fmt.Sprintf("burst %d", i), "ok")
+	}
+	if n := len(s.extractQueue); n != 3 {
+		t.Errorf("queue holds %d turns, want 3 with the rest dropped", n)
+	}
+
+	close(gate.release)
+	waitFor("the queue to drain", func() bool {
+		n, _, calls := gate.stats()
+		return n == 0 && calls == 5 && len(s.extractQueue) == 0
+	})
+	if _, peak, calls := gate.stats(); peak != 2 || calls != 5 {
+		t.Errorf("peak concurrency %d over %d extractions, want 2 over 5", peak, calls)
+	}
+}
+
+// profileLLM is a slow extraction model that always learns the user's name.
+type profileLLM struct{ stepLLM }
+
+func (profileLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	time.Sleep(50 * time.Millisecond)
+	return &llm.LLMResponse{Content: `{"profile_updates": {"name": "Ada"}}`}, nil
+}
+
+func TestSyncMemoryExtraction(t *testing.T) {
+	doc := &dsl.Document{Agents: map[string]*dsl.Agent{
+		"support": {Name: "support", Model: "test-model", System: "You help."},
+	}}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(stepLLM{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	chat := func(t *testing.T, cfg Config, user string) *Server {
+		t.Helper()
+		s := New(interp, cfg)
+		s.store = newTestStore(t)
+		s.sqliteStore = s.store.(*SQLiteStore)
+		s.extractLLMMu.Do(func() { s.extractLLM = profileLLM{} })
+		mux := http.NewServeMux()
+		s.registerRoutes(mux)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/agents/support/chat", strings.NewReader(`{"message":"Hi, I'm Ada"}`))
+		req.Header.Set("X-Auth-User", user)
+		mux.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("chat: status %d: %s", rec.Code, rec.Body)
+		}
+		return s
+	}
+	hasName := func(s *Server, user string) bool {
+		layers, err := s.store.GetUserMemory(user, "support")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, l := range layers {
+			if l.Layer == "profile" && strings.Contains(l.Content, "Ada") {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("sync", func(t *testing.T) {
+		s := chat(t, Config{SyncMemoryExtract: true}, "ada")
+		if !hasName(s, "ada") {
+			t.Error("memory not stored when the chat returned")
+		}
+	})
+
+	t.Run("wait for async", func(t *testing.T) {
+		s := chat(t, Config{}, "ada")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := s.WaitMemoryExtraction(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !hasName(s, "ada") {
+			t.Error("memory not stored after WaitMemoryExtraction")
+		}
+	})
+}
@@ -90,7 +90,7 @@ func RegisterMemoryTools(interp *dsl.Interpreter) {
 	})
 
 	t.Register("recall", tools.ToolDef{
-		Description: "Search long-term memory by keyword. Returns matching memories across all topics. Use this to look up past conversations, project details, or decisions.",
+		Description: "Search long-term memory. Returns the most relevant memories across all topics. Use this to look up past conversations, project details, or decisions.",
 		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
 			store, userID, agent, err := memoryFromContext(ctx)
 			if err != nil {
@@ -107,7 +107,7 @@ func RegisterMemoryTools(interp *dsl.Interpreter) {
 				limit = int(l)
 			}
 
-			items, err := store.SearchMemoryItems(userID, agent, query, limit)
+			items, err := store.SearchMemoryItemsSemantic(userID, agent, query, limit)
 			if err != nil {
 				return "", fmt.Errorf("search memory: %w", err)
 			}
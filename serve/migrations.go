@@ -0,0 +1,259 @@
+package serve
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migration is one step in the evolution of the SQLite schema. Migrations
+// run in order, each in its own transaction, and the highest applied
+// version is recorded in the schema_version table.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change made after the base schema in Init.
+// Append new migrations with the next version number; never edit or
+// reorder ones that have shipped.
+//
+// The early column additions used to run as ad-hoc ALTER TABLE statements,
+// so databases created before versioning may already have them. addColumn
+// skips columns that exist, which lets those databases catch up cleanly.
+var migrations = []migration{
+	{1, "composed_agents.tools", func(tx *sql.Tx) error {
+		return addColumn(tx, "composed_agents", "tools", `TEXT NOT NULL DEFAULT '[]'`)
+	}},
+	{2, "mcp_servers.disabled", func(tx *sql.Tx) error {
+		return addColumn(tx, "mcp_servers", "disabled", `INTEGER NOT NULL DEFAULT 0`)
+	}},
+	{3, "composed_agents.display_name and title", func(tx *sql.Tx) error {
+		if err := addColumn(tx, "composed_agents", "display_name", `TEXT NOT NULL DEFAULT ''`); err != nil {
+			return err
+		}
+		return addColumn(tx, "composed_agents", "title", `TEXT NOT NULL DEFAULT ''`)
+	}},
+	{4, "composed_agents.avatar", func(tx *sql.Tx) error {
+		return addColumn(tx, "composed_agents", "avatar", `TEXT NOT NULL DEFAULT ''`)
+	}},
+	{5, "channels.mode", func(tx *sql.Tx) error {
+		return addColumn(tx, "channels", "mode", `TEXT NOT NULL DEFAULT ''`)
+	}},
+	{6, "channel_messages.sender", func(tx *sql.Tx) error {
+		return addColumn(tx, "channel_messages", "sender", `TEXT DEFAULT ''`)
+	}},
+	{7, "scheduled_jobs.timezone", func(tx *sql.Tx) error {
+		return addColumn(tx, "scheduled_jobs", "timezone", `TEXT NOT NULL DEFAULT ''`)
+	}},
+	{8, "scheduled_jobs.run_at", func(tx *sql.Tx) error {
+		return addColumn(tx, "scheduled_jobs", "run_at", `DATETIME`)
+	}},
+	{9, "memory_items.embedding", func(tx *sql.Tx) error {
+		return addColumn(tx, "memory_items", "embedding", `BLOB`)
+	}},
+	{10, "agent_kv", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS agent_kv (
+			user_id    TEXT NOT NULL,
+			agent      TEXT NOT NULL,
+			key        TEXT NOT NULL,
+			value      TEXT NOT NULL,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, agent, key)
+		)`)
+		return err
+	}},
+	{11, "tool_calls", func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS tool_calls (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			tool        TEXT NOT NULL,
+			agent       TEXT NOT NULL DEFAULT '',
+			process_id  TEXT NOT NULL DEFAULT '',
+			args        TEXT NOT NULL DEFAULT '',
+			result      TEXT NOT NULL DEFAULT '',
+			error       TEXT NOT NULL DEFAULT '',
+			duration_ms INTEGER NOT NULL DEFAULT 0,
+			created_at  DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_tool_calls_agent ON tool_calls(agent)`)
+		return err
+	}},
+	{12, "workflow_steps", func(tx *sql.Tx) error {
+		_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS workflow_steps (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id     TEXT NOT NULL,
+			step_index INTEGER NOT NULL,
+			type       TEXT NOT NULL DEFAULT '',
+			agent      TEXT NOT NULL DEFAULT '',
+			save       TEXT NOT NULL DEFAULT '',
+			status     TEXT NOT NULL,
+			result     TEXT NOT NULL DEFAULT '',
+			error      TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (run_id, step_index)
+		)`)
+		return err
+	}},
+	{13, "chat_summaries", func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS chat_summaries (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			agent      TEXT NOT NULL,
+			summary    TEXT NOT NULL,
+			through_id INTEGER NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_chat_summaries_agent ON chat_summaries(agent)`)
+		return err
+	}},
+	{14, "cost_ledger", func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS cost_ledger (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id       TEXT NOT NULL,
+			agent         TEXT NOT NULL,
+			model         TEXT NOT NULL DEFAULT '',
+			input_tokens  INTEGER NOT NULL DEFAULT 0,
+			output_tokens INTEGER NOT NULL DEFAULT 0,
+			cost_usd      REAL NOT NULL DEFAULT 0,
+			created_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_cost_ledger_user_agent ON cost_ledger(user_id, agent, created_at)`)
+		return err
+	}},
+	{15, "idempotency_keys", func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			workflow   TEXT NOT NULL,
+			key        TEXT NOT NULL,
+			run_id     TEXT NOT NULL,
+			expires_at INTEGER NOT NULL,
+			PRIMARY KEY (workflow, key)
+		)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at)`)
+		return err
+	}},
+	// chat_messages_fts indexes chat content for SearchChatMessages. It is
+	// an external-content table kept in step by triggers, so every path that
+	// deletes chat messages (clear, reset, retention) maintains it.
+	{16, "chat_messages_fts", func(tx *sql.Tx) error {
+		for _, stmt := range []string{
+			`CREATE VIRTUAL TABLE IF NOT EXISTS chat_messages_fts USING fts5(
+				content, content='chat_messages', content_rowid='id'
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS chat_messages_fts_insert AFTER INSERT ON chat_messages BEGIN
+				INSERT INTO chat_messages_fts(rowid, content) VALUES (new.id, new.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS chat_messages_fts_delete AFTER DELETE ON chat_messages BEGIN
+				INSERT INTO chat_messages_fts(chat_messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS chat_messages_fts_update AFTER UPDATE OF content ON chat_messages BEGIN
+				INSERT INTO chat_messages_fts(chat_messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+				INSERT INTO chat_messages_fts(rowid, content) VALUES (new.id, new.content);
+			END`,
+			// Index the history that predates the table.
+			`INSERT INTO chat_messages_fts(chat_messages_fts) VALUES ('rebuild')`,
+		} {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+}
+
+// SchemaVersion returns the highest migration version applied to the
+// database, or 0 if none has been.
+func (s *SQLiteStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	return version, err
+}
+
+// migrate applies the migrations newer than the recorded schema version.
+// It stops at the first failure, leaving the database at the last version
+// that applied cleanly.
+func (s *SQLiteStore) migrate(migrations []migration) error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL DEFAULT '',
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("create schema_version: %w", err)
+	}
+
+	current, err := s.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := s.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+		}
+		current = m.version
+	}
+	return nil
+}
+
+// applyMigration runs one migration and records its version atomically.
+func (s *SQLiteStore) applyMigration(m migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, name) VALUES (?, ?)`, m.version, m.name); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// addColumn adds a column to table unless it already exists.
+func addColumn(tx *sql.Tx, table, column, def string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, def))
+	return err
+}
+
+// hasColumn reports whether table has a column with the given name.
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
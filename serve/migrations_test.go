@@ -0,0 +1,135 @@
+package serve
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	store := newTestStore(t)
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+
+	// Running Init again is a no-op.
+	if err := store.Init(); err != nil {
+		t.Fatalf("second Init: %v", err)
+	}
+	var applied int
+	store.db.QueryRow(`SELECT COUNT(*) FROM schema_version`).Scan(&applied)
+	if applied != len(migrations) {
+		t.Errorf("schema_version has %d rows, want %d", applied, len(migrations))
+	}
+}
+
+func TestMigrateOlderDatabase(t *testing.T) {
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "old.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	// A database written by a release at schema version 3: composed_agents
+	// predates the avatar column and memory_items predates embeddings.
+	_, err = store.db.Exec(`
+	CREATE TABLE composed_agents (
+		name         TEXT PRIMARY KEY,
+		display_name TEXT NOT NULL DEFAULT '',
+		title        TEXT NOT NULL DEFAULT '',
+		model        TEXT NOT NULL DEFAULT '',
+		persona      TEXT NOT NULL DEFAULT '',
+		skills       TEXT NOT NULL DEFAULT '[]',
+		tools        TEXT NOT NULL DEFAULT '[]',
+		team         TEXT NOT NULL DEFAULT '[]',
+		system       TEXT NOT NULL DEFAULT '',
+		temperature  REAL,
+		created_at   DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO composed_agents (name, model) VALUES ('etienne', 'claude-sonnet-4-20250514');
+	CREATE TABLE memory_items (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id    TEXT NOT NULL,
+		agent      TEXT NOT NULL,
+		topic      TEXT NOT NULL DEFAULT '',
+		content    TEXT NOT NULL,
+		tags       TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE schema_version (
+		version    INTEGER PRIMARY KEY,
+		name       TEXT NOT NULL DEFAULT '',
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO schema_version (version) VALUES (1), (2), (3);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Init(); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	version, _ := store.SchemaVersion()
+	if want := migrations[len(migrations)-1].version; version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+	tx, err := store.db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	for _, col := range []struct{ table, column string }{
+		{"composed_agents", "avatar"},
+		{"memory_items", "embedding"},
+		{"scheduled_jobs", "run_at"},
+	} {
+		if ok, err := hasColumn(tx, col.table, col.column); err != nil || !ok {
+			t.Errorf("%s.%s missing after migration (err %v)", col.table, col.column, err)
+		}
+	}
+
+	agents, err := store.ListComposedAgents()
+	if err != nil || len(agents) != 1 || agents[0].Name != "etienne" {
+		t.Errorf("existing rows after migration = %+v, %v", agents, err)
+	}
+}
+
+func TestMigrateFailureIsTransactional(t *testing.T) {
+	store := newTestStore(t)
+	before, _ := store.SchemaVersion()
+
+	boom := errors.New("boom")
+	err := store.migrate([]migration{
+		{before + 1, "add table", func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE migrated (id INTEGER)`)
+			return err
+		}},
+		{before + 2, "broken", func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE half_done (id INTEGER)`); err != nil {
+				return err
+			}
+			return boom
+		}},
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("migrate error = %v, want boom", err)
+	}
+
+	if version, _ := store.SchemaVersion(); version != before+1 {
+		t.Errorf("schema version = %d, want %d", version, before+1)
+	}
+	var n int
+	store.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n)
+	if n != 0 {
+		t.Error("failed migration was not rolled back")
+	}
+}
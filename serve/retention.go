@@ -0,0 +1,151 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Settings that control how long history is kept. Both are optional; an
+// unset or unparsable value falls back to the default.
+const (
+	// RetentionDaysSetting is the age in days after which events, process
+	// snapshots, tool calls and finished workflow runs and their steps are
+	// deleted. 0 disables age-based pruning.
+	RetentionDaysSetting = "VEGA_RETENTION_DAYS"
+	// RetentionMaxRowsSetting caps the number of rows kept in each of those
+	// tables, newest first. 0 disables the cap.
+	RetentionMaxRowsSetting = "VEGA_RETENTION_MAX_ROWS"
+)
+
+const (
+	defaultRetentionDays = 30
+	retentionInterval    = time.Hour
+	vacuumInterval       = 24 * time.Hour
+)
+
+// RetentionPolicy bounds how much history the store keeps.
+type RetentionPolicy struct {
+	// MaxAge deletes rows older than this; zero keeps rows of any age.
+	MaxAge time.Duration
+	// MaxRows keeps at most this many rows per table; zero means no cap.
+	MaxRows int
+}
+
+// retentionTable describes a history table and the column that dates it.
+type retentionTable struct {
+	name   string
+	column string
+	// keep is an extra condition for rows that must survive pruning.
+	keep string
+}
+
+var retentionTables = []retentionTable{
+	{name: "events", column: "timestamp"},
+	{name: "process_snapshots", column: "snapshot_at"},
+	{name: "workflow_runs", column: "started_at", keep: "status = 'running'"},
+	{name: "tool_calls", column: "created_at"},
+	{name: "workflow_steps", column: "updated_at", keep: "run_id IN (SELECT run_id FROM workflow_runs WHERE status = 'running')"},
+}
+
+// RetentionPolicy reads the policy from settings.
+func (s *SQLiteStore) RetentionPolicy() RetentionPolicy {
+	return retentionPolicyFromSettings(s)
+}
+
+// retentionPolicyFromSettings reads the retention settings from store,
+// falling back to the defaults.
+func retentionPolicyFromSettings(s Store) RetentionPolicy {
+	p := RetentionPolicy{MaxAge: defaultRetentionDays * 24 * time.Hour}
+	if st, err := s.GetSetting(RetentionDaysSetting); err == nil && st != nil {
+		if days, err := strconv.Atoi(st.Value); err == nil && days >= 0 {
+			p.MaxAge = time.Duration(days) * 24 * time.Hour
+		}
+	}
+	if st, err := s.GetSetting(RetentionMaxRowsSetting); err == nil && st != nil {
+		if n, err := strconv.Atoi(st.Value); err == nil && n >= 0 {
+			p.MaxRows = n
+		}
+	}
+	return p
+}
+
+// Prune deletes events, process snapshots, tool calls, workflow runs and
+// workflow steps that fall outside the policy and returns how many rows
+// were removed. Workflow runs that are still running, and their steps, are
+// never deleted.
+func (s *SQLiteStore) Prune(p RetentionPolicy) (int64, error) {
+	var total int64
+	for _, t := range retentionTables {
+		keep := ""
+		if t.keep != "" {
+			keep = " AND NOT (" + t.keep + ")"
+		}
+
+		if p.MaxAge > 0 {
+			// Rows hold either CURRENT_TIMESTAMP text or a Go time.String()
+			// with a zone and monotonic suffix that SQLite can't parse. Both
+			// start with "YYYY-MM-DD HH:MM:SS", so compare just that; a zone
+			// offset skews the cutoff by hours, which is fine at day scale.
+			cutoff := time.Now().Add(-p.MaxAge).UTC().Format("2006-01-02 15:04:05")
+			res, err := s.exec(fmt.Sprintf(
+				`DELETE FROM %s WHERE julianday(substr(%s, 1, 19)) < julianday(?)%s`, t.name, t.column, keep), cutoff)
+			if err != nil {
+				return total, fmt.Errorf("prune %s by age: %w", t.name, err)
+			}
+			n, _ := res.RowsAffected()
+			total += n
+		}
+
+		if p.MaxRows > 0 {
+			res, err := s.exec(fmt.Sprintf(
+				`DELETE FROM %[1]s WHERE id <= (SELECT id FROM %[1]s ORDER BY id DESC LIMIT 1 OFFSET ?)%[2]s`,
+				t.name, keep), p.MaxRows)
+			if err != nil {
+				return total, fmt.Errorf("prune %s by count: %w", t.name, err)
+			}
+			n, _ := res.RowsAffected()
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// runRetention prunes history on a fixed interval until ctx is done,
+// re-reading the policy each time so settings changes apply without a
+// restart. The database is vacuumed at most once per vacuumInterval, and
+// only after something was deleted.
+func (s *Server) runRetention(ctx context.Context) {
+	rs, ok := s.store.(retentionStore)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	var lastVacuum time.Time
+	var pending int64
+	for {
+		n, err := rs.Prune(rs.RetentionPolicy())
+		if err != nil {
+			slog.Warn("retention: prune failed", "error", err)
+		} else if n > 0 {
+			slog.Info("retention: pruned old history", "rows", n)
+		}
+		pending += n
+
+		if pending > 0 && time.Since(lastVacuum) >= vacuumInterval {
+			rs.Vacuum()
+			lastVacuum = time.Now()
+			pending = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package serve
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPruneRemovesOnlyOldRows(t *testing.T) {
+	store := newTestStore(t)
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	for _, ts := range []time.Time{old, recent} {
+		if err := store.InsertEvent(StoreEvent{Type: "process.started", Timestamp: ts}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.InsertProcessSnapshot(ProcessSnapshot{ProcessID: "p", SnapshotAt: ts}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Rows written with CURRENT_TIMESTAMP use a different text format.
+	if _, err := store.db.Exec(`INSERT INTO events (type) VALUES ('process.completed')`); err != nil {
+		t.Fatal(err)
+	}
+	runs := []WorkflowRun{
+		{RunID: "old-done", Workflow: "w", Status: "completed", StartedAt: old},
+		{RunID: "old-running", Workflow: "w", Status: "running", StartedAt: old},
+		{RunID: "new-done", Workflow: "w", Status: "completed", StartedAt: recent},
+	}
+	for _, r := range runs {
+		if err := store.InsertWorkflowRun(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := store.Prune(RetentionPolicy{MaxAge: 30 * 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("pruned %d rows, want 3", n)
+	}
+
+	counts := map[string]int{"events": 2, "process_snapshots": 1, "workflow_runs": 2}
+	for table, want := range counts {
+		if got, _ := store.CountTable(table); got != want {
+			t.Errorf("%s has %d rows, want %d", table, got, want)
+		}
+	}
+	var survivor int
+	store.db.QueryRow(`SELECT COUNT(*) FROM workflow_runs WHERE run_id = 'old-running'`).Scan(&survivor)
+	if survivor != 1 {
+		t.Error("running workflow run was pruned")
+	}
+
+	// Cap at one row: the newest event survives.
+	if _, err := store.Prune(RetentionPolicy{MaxRows: 1}); err != nil {
+		t.Fatal(err)
+	}
+	var typ string
+	store.db.QueryRow(`SELECT type FROM events`).Scan(&typ)
+	if got, _ := store.CountTable("events"); got != 1 || typ != "process.completed" {
+		t.Errorf("after MaxRows=1: %d events, newest %q; want 1, process.completed", got, typ)
+	}
+}
+
+func TestRetentionPolicyFromSettings(t *testing.T) {
+	store := newTestStore(t)
+	if p := store.RetentionPolicy(); p.MaxAge != defaultRetentionDays*24*time.Hour || p.MaxRows != 0 {
+		t.Errorf("default policy = %+v", p)
+	}
+
+	store.UpsertSetting(Setting{Key: RetentionDaysSetting, Value: "7"})
+	store.UpsertSetting(Setting{Key: RetentionMaxRowsSetting, Value: "5000"})
+	if p := store.RetentionPolicy(); p.MaxAge != 7*24*time.Hour || p.MaxRows != 5000 {
+		t.Errorf("policy = %+v, want 7 days and 5000 rows", p)
+	}
+
+	store.UpsertSetting(Setting{Key: RetentionDaysSetting, Value: "0"})
+	if p := store.RetentionPolicy(); p.MaxAge != 0 {
+		t.Errorf("VEGA_RETENTION_DAYS=0 should disable age pruning, got %v", p.MaxAge)
+	}
+}
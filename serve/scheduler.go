@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/everydev1618/govega/dsl"
 	"github.com/robfig/cron/v3"
@@ -15,12 +16,21 @@ type inboxChecker interface {
 	PendingInboxCount() (int, error)
 }
 
+// scheduleRunRecorder is a minimal interface for recording job firings.
+type scheduleRunRecorder interface {
+	InsertScheduleRun(run ScheduleRun) error
+}
+
+// maxScheduleRunResponse caps the agent response stored per run.
+const maxScheduleRunResponse = 2048
+
 // Scheduler runs cron jobs that send messages to agents.
 // It implements dsl.SchedulerBackend.
 type Scheduler struct {
 	c       *cron.Cron
 	interp  *dsl.Interpreter
-	inbox   inboxChecker // optional — used to skip no-op heartbeats
+	inbox   inboxChecker        // optional — used to skip no-op heartbeats
+	runs    scheduleRunRecorder // optional — records each firing
 	persist func(job dsl.ScheduledJob) error
 	remove  func(name string) error
 	store   *SQLiteStore // domain store for tool context
@@ -59,6 +69,11 @@ func (s *Scheduler) Start(ctx context.Context) {
 // AddJob adds a job to the cron runner and persists it.
 // If a job with the same name already exists it is replaced.
 func (s *Scheduler) AddJob(job dsl.ScheduledJob) error {
+	sched, err := jobSchedule(job)
+	if err != nil {
+		return err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -80,10 +95,7 @@ func (s *Scheduler) AddJob(job dsl.ScheduledJob) error {
 		return nil
 	}
 
-	entryID, err := s.c.AddFunc(job.Cron, s.makeFunc(job))
-	if err != nil {
-		return fmt.Errorf("invalid cron expression %q: %w", job.Cron, err)
-	}
+	entryID := s.c.Schedule(sched, cron.FuncJob(s.makeFunc(job)))
 
 	s.entries[job.Name] = entryID
 	s.jobs = append(s.jobs, job)
@@ -94,10 +106,68 @@ func (s *Scheduler) AddJob(job dsl.ScheduledJob) error {
 		}
 	}
 
-	slog.Info("scheduler: job added", "name", job.Name, "cron", job.Cron, "agent", job.AgentName)
+	if job.OneShot() {
+		slog.Info("scheduler: one-shot job added", "name", job.Name, "run_at", job.RunAt, "agent", job.AgentName)
+	} else {
+		slog.Info("scheduler: job added", "name", job.Name, "cron", job.Cron, "timezone", job.Timezone, "agent", job.AgentName)
+	}
 	return nil
 }
 
+// jobSchedule parses a job's cron expression and pins it to the job's
+// timezone, if one is set. One-shot jobs get a schedule that fires once.
+func jobSchedule(job dsl.ScheduledJob) (cron.Schedule, error) {
+	if job.OneShot() {
+		if job.Cron != "" {
+			return nil, fmt.Errorf("schedule %q: cron and run_at are mutually exclusive", job.Name)
+		}
+		return &onceSchedule{at: job.RunAt}, nil
+	}
+	if job.Cron == "" {
+		return nil, fmt.Errorf("schedule %q: cron or run_at is required", job.Name)
+	}
+
+	var loc *time.Location
+	if job.Timezone != "" {
+		var err error
+		loc, err = time.LoadLocation(job.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", job.Timezone, err)
+		}
+	}
+
+	sched, err := cron.ParseStandard(job.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", job.Cron, err)
+	}
+	if spec, ok := sched.(*cron.SpecSchedule); ok && loc != nil {
+		spec.Location = loc
+	}
+	return sched, nil
+}
+
+// onceSchedule is a cron.Schedule that fires a single time. The first call
+// to Next returns the fire time even when it is already past, so a one-shot
+// whose moment passed while the server was down runs as soon as the cron
+// runner picks it up instead of being dropped. Later calls return the zero
+// time, which cron treats as "never".
+type onceSchedule struct {
+	mu    sync.Mutex
+	at    time.Time
+	fired bool
+}
+
+// Next implements cron.Schedule.
+func (o *onceSchedule) Next(time.Time) time.Time {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.fired {
+		return time.Time{}
+	}
+	o.fired = true
+	return o.at
+}
+
 // RemoveJob removes a job from the cron runner and calls the remove callback.
 func (s *Scheduler) RemoveJob(name string) error {
 	s.mu.Lock()
@@ -163,9 +233,56 @@ func (s *Scheduler) makeFunc(job dsl.ScheduledJob) func() {
 		// Use SendToAgent (synchronous, no inbox item) instead of
 		// DispatchToAgent to avoid spamming the inbox with no-op
 		// heartbeat results like "inbox empty."
-		if _, err := s.interp.SendToAgent(ctx, job.AgentName, job.Message); err != nil {
+		start := time.Now()
+		response, err := s.interp.SendToAgent(ctx, job.AgentName, job.Message)
+		if err != nil {
 			slog.Warn("scheduler: agent send failed", "name", job.Name, "agent", job.AgentName, "error", err)
 		}
+		s.recordRun(job, start, response, err)
+
+		if job.OneShot() {
+			s.retireOneShot(job)
+		}
+	}
+}
+
+// recordRun stores the outcome of one job firing, if a recorder is set.
+func (s *Scheduler) recordRun(job dsl.ScheduledJob, start time.Time, response string, sendErr error) {
+	if s.runs == nil {
+		return
+	}
+	run := ScheduleRun{
+		Name:       job.Name,
+		Agent:      job.AgentName,
+		Response:   truncate(response, maxScheduleRunResponse),
+		DurationMs: time.Since(start).Milliseconds(),
+		FiredAt:    start,
+	}
+	if sendErr != nil {
+		run.Error = sendErr.Error()
+	}
+	if err := s.runs.InsertScheduleRun(run); err != nil {
+		slog.Warn("scheduler: record run failed", "name", job.Name, "error", err)
+	}
+}
+
+// retireOneShot removes a one-shot job after it has fired, unless it was
+// replaced by a different job of the same name in the meantime.
+func (s *Scheduler) retireOneShot(job dsl.ScheduledJob) {
+	s.mu.Lock()
+	current := false
+	for _, j := range s.jobs {
+		if j.Name == job.Name && j.RunAt.Equal(job.RunAt) {
+			current = true
+			break
+		}
+	}
+	s.mu.Unlock()
+	if !current {
+		return
+	}
+	if err := s.RemoveJob(job.Name); err != nil {
+		slog.Warn("scheduler: retire one-shot job failed", "name", job.Name, "error", err)
 	}
 }
 
@@ -0,0 +1,132 @@
+package serve
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/everydev1618/govega/dsl"
+	"github.com/robfig/cron/v3"
+)
+
+func TestJobScheduleTimezone(t *testing.T) {
+	sched, err := jobSchedule(dsl.ScheduledJob{
+		Name:     "tokyo-morning",
+		Cron:     "0 9 * * *",
+		Timezone: "Asia/Tokyo",
+	})
+	if err != nil {
+		t.Fatalf("jobSchedule: %v", err)
+	}
+
+	from := time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)
+	got := sched.Next(from).UTC()
+	want := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC) // 09:00 JST
+	if !got.Equal(want) {
+		t.Errorf("Next = %v, want %v", got, want)
+	}
+}
+
+func TestAddJobInvalidTimezone(t *testing.T) {
+	s := NewScheduler(nil, nil, nil)
+	err := s.AddJob(dsl.ScheduledJob{
+		Name:     "bad-tz",
+		Cron:     "0 9 * * *",
+		Timezone: "Mars/Olympus_Mons",
+		Enabled:  true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid timezone") {
+		t.Fatalf("AddJob err = %v, want invalid timezone error", err)
+	}
+	if len(s.ListJobs()) != 0 {
+		t.Error("job with invalid timezone should not be registered")
+	}
+}
+
+func TestOnceScheduleFiresOnce(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	sched := &onceSchedule{at: past}
+
+	// A fire time that passed while the server was down is still returned,
+	// so cron runs the job immediately instead of dropping it.
+	if got := sched.Next(time.Now()); !got.Equal(past) {
+		t.Fatalf("first Next = %v, want %v", got, past)
+	}
+	if got := sched.Next(time.Now()); !got.IsZero() {
+		t.Fatalf("second Next = %v, want zero time", got)
+	}
+}
+
+func TestOnceSchedulePastRunAtFiresOnStart(t *testing.T) {
+	c := cron.New()
+	fired := make(chan struct{}, 2)
+	c.Schedule(&onceSchedule{at: time.Now().Add(-time.Hour)}, cron.FuncJob(func() {
+		fired <- struct{}{}
+	}))
+	c.Start()
+	defer c.Stop()
+
+	select {
+	case <-fired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("past one-shot job did not fire")
+	}
+	select {
+	case <-fired:
+		t.Fatal("one-shot job fired twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestJobScheduleRequiresCronOrRunAt(t *testing.T) {
+	if _, err := jobSchedule(dsl.ScheduledJob{Name: "empty"}); err == nil {
+		t.Error("expected error for job with neither cron nor run_at")
+	}
+	_, err := jobSchedule(dsl.ScheduledJob{
+		Name:  "both",
+		Cron:  "0 9 * * *",
+		RunAt: time.Now().Add(time.Hour),
+	})
+	if err == nil {
+		t.Error("expected error for job with both cron and run_at")
+	}
+}
+
+func TestScheduleRunHistory(t *testing.T) {
+	store := newTestStore(t)
+	s := NewScheduler(nil, nil, nil)
+	s.runs = store
+
+	job := dsl.ScheduledJob{Name: "daily-summary", AgentName: "writer"}
+	start := time.Now().Add(-time.Minute)
+	s.recordRun(job, start, strings.Repeat("x", maxScheduleRunResponse+100), nil)
+	s.recordRun(job, time.Now(), "", errors.New("agent not found"))
+	s.recordRun(dsl.ScheduledJob{Name: "other", AgentName: "writer"}, time.Now(), "ok", nil)
+
+	runs, err := store.ListScheduleRuns("daily-summary", 10)
+	if err != nil {
+		t.Fatalf("ListScheduleRuns: %v", err)
+	}
+	if len(runs) != 2 {
+		t.Fatalf("got %d runs, want 2", len(runs))
+	}
+	// Newest first.
+	if runs[0].Error != "agent not found" {
+		t.Errorf("latest run error = %q, want %q", runs[0].Error, "agent not found")
+	}
+	if runs[1].Error != "" || len(runs[1].Response) != maxScheduleRunResponse {
+		t.Errorf("first run = error %q, response len %d; want no error, truncated response", runs[1].Error, len(runs[1].Response))
+	}
+	if runs[1].Agent != "writer" {
+		t.Errorf("agent = %q, want writer", runs[1].Agent)
+	}
+
+	limited, err := store.ListScheduleRuns("daily-summary", 1)
+	if err != nil {
+		t.Fatalf("ListScheduleRuns: %v", err)
+	}
+	if len(limited) != 1 {
+		t.Errorf("limit 1 returned %d runs", len(limited))
+	}
+}
@@ -2,7 +2,9 @@ package serve
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -10,12 +12,14 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	vega "github.com/everydev1618/govega"
 	"github.com/everydev1618/govega/dsl"
 	"github.com/everydev1618/govega/llm"
 	"github.com/everydev1618/govega/mcp"
+	"github.com/everydev1618/govega/tools"
 	"github.com/everydev1618/vega-population/population"
 )
 
@@ -30,14 +34,16 @@ type streamSubscriber struct {
 // clients can replay them. Multiple subscribers can listen concurrently.
 type activeStream struct {
 	agentName string
-	done      chan struct{} // closed when stream completes
+	done      chan struct{}      // closed when stream completes
+	cancel    context.CancelFunc // cancels the detached LLM context
 
 	mu          sync.Mutex
-	history     []vega.ChatEvent    // all events received, for replay
-	subscribers []*streamSubscriber // active SSE subscribers
-	response    string              // set after done
-	err         error               // set after done
+	history     []vega.ChatEvent       // all events received, for replay
+	subscribers []*streamSubscriber    // active SSE subscribers
+	response    string                 // set after done
+	err         error                  // set after done
 	metrics     *vega.ChatEventMetrics // set after done
+	stopped     bool                   // stop was requested before completion
 }
 
 // publish sends an event to all active subscribers and appends it to history.
@@ -80,6 +86,39 @@ func (as *activeStream) unsubscribe(ch chan vega.ChatEvent) {
 	}
 }
 
+// stop cancels the stream if it is still running. It reports false when
+// the stream had already completed, in which case nothing is cancelled.
+// done is closed under mu, so a stream can't complete between the check
+// and the cancel.
+func (as *activeStream) stop() bool {
+	as.mu.Lock()
+	defer as.mu.Unlock()
+	select {
+	case <-as.done:
+		return false
+	default:
+	}
+	as.stopped = true
+	as.cancel()
+	return true
+}
+
+// closingEvents returns the events that end a finished stream for a
+// client: an error event if the stream failed, then done with its metrics.
+func (as *activeStream) closingEvents() []vega.ChatEvent {
+	as.mu.Lock()
+	streamErr := as.err
+	doneMetrics := as.metrics
+	as.mu.Unlock()
+
+	var events []vega.ChatEvent
+	if streamErr != nil {
+		_, friendlyMsg := classifyHTTPError(streamErr)
+		events = append(events, vega.ChatEvent{Type: vega.ChatEventError, Error: friendlyMsg})
+	}
+	return append(events, vega.ChatEvent{Type: vega.ChatEventDone, Metrics: doneMetrics})
+}
+
 // finish closes all subscriber channels. Called when the stream completes.
 func (as *activeStream) finish() {
 	as.mu.Lock()
@@ -95,10 +134,22 @@ func (as *activeStream) finish() {
 // Config holds server configuration.
 type Config struct {
 	Addr          string
-	DBPath        string
+	DBPath        string       // SQLite file path, or a postgres:// URL
 	TelegramToken string       // TELEGRAM_BOT_TOKEN; leave empty to disable
 	TelegramAgent string       // TELEGRAM_AGENT; defaults to first agent if empty
 	Company       *dsl.Company // optional company identity (env var overrides)
+	Embedder      Embedder     // optional; enables semantic memory recall
+	AdminToken    string       // VEGA_ADMIN_TOKEN; leave empty to disable /admin endpoints
+	InputGuard    InputGuard   // optional; screens chat messages before they reach an agent
+	StreamFilter  StreamFilter // optional; can abort a chat response while it streams
+	WarmAgents    []string     // agents to spawn and hydrate at startup instead of on first use
+
+	IdempotencyTTL  time.Duration // how long an Idempotency-Key maps to its workflow run; 0 means 24h
+	StreamRetention time.Duration // how long a finished chat stream stays replayable for reconnects; 0 means 30s
+
+	MemoryExtractWorkers int  // concurrent memory extractions; 0 means 1
+	MemoryExtractQueue   int  // extractions waiting for a worker; 0 means 16, oldest dropped when full
+	SyncMemoryExtract    bool // extract memory before answering a chat, so the next turn sees it
 }
 
 // Server is the HTTP server for the Vega dashboard and REST API.
@@ -117,9 +168,11 @@ type Server struct {
 	extractLLM   llm.LLM
 	extractLLMMu sync.Once
 
-	// extractSem limits memory extraction to one at a time; extra
-	// requests are dropped rather than queued.
-	extractSem chan struct{}
+	// extractQueue holds chat turns waiting for memory extraction; the
+	// workers draining it start on first use.
+	extractQueue   chan extractJob
+	extractWorkers sync.Once
+	extractPending atomic.Int64 // queued and running extractions
 
 	// company is the resolved company identity for this instance.
 	company *dsl.Company
@@ -128,16 +181,28 @@ type Server struct {
 	// from any particular SSE client connection.
 	streamsMu sync.Mutex
 	streams   map[string]*activeStream
+
+	// runs tracks step progress of in-flight workflow runs keyed by run ID.
+	runsMu sync.Mutex
+	runs   map[string]*workflowRunStream
+
+	// approvals holds tool calls waiting for a human decision.
+	approvals approvals
 }
 
 // New creates a new Server.
 func New(interp *dsl.Interpreter, cfg Config) *Server {
+	queueSize := cfg.MemoryExtractQueue
+	if queueSize <= 0 {
+		queueSize = defaultExtractQueue
+	}
 	return &Server{
-		interp:     interp,
-		broker:     NewEventBroker(),
-		cfg:        cfg,
-		streams:    make(map[string]*activeStream),
-		extractSem: make(chan struct{}, 1),
+		interp:       interp,
+		broker:       NewEventBroker(),
+		cfg:          cfg,
+		streams:      make(map[string]*activeStream),
+		runs:         make(map[string]*workflowRunStream),
+		extractQueue: make(chan extractJob, queueSize),
 	}
 }
 
@@ -160,6 +225,26 @@ func (s *Server) getExtractLLM() llm.LLM {
 	return s.extractLLM
 }
 
+// SummaryModelSetting names the model summarize_file uses. When unset,
+// summaries use defaultSummaryModel on Anthropic and the backend's default
+// model on OpenAI-compatible servers.
+const SummaryModelSetting = "VEGA_SUMMARY_MODEL"
+
+const defaultSummaryModel = "claude-haiku-4-5-20251001"
+
+// summarize is the tools.Summarizer for summarize_file. It shares the
+// memory-extraction client and reads the model setting on each call.
+func (s *Server) summarize(ctx context.Context, text, instructions string) (string, error) {
+	model := ""
+	if st, err := s.store.GetSetting(SummaryModelSetting); err == nil && st != nil {
+		model = strings.TrimSpace(st.Value)
+	}
+	if model == "" && os.Getenv("OPENAI_BASE_URL") == "" {
+		model = defaultSummaryModel
+	}
+	return tools.LLMSummarizer(s.getExtractLLM(), model).Summarize(ctx, text, instructions)
+}
+
 // resolveAddr binds a TCP listener on addr (or ":0" if addr is empty to
 // let the OS pick a free port). It returns the listener and the resolved
 // address with the actual port filled in.
@@ -179,21 +264,36 @@ func resolveAddr(addr string) (net.Listener, string, error) {
 func (s *Server) Start(ctx context.Context) error {
 	s.startedAt = time.Now()
 
-	// Initialize SQLite store.
-	store, err := NewSQLiteStore(s.cfg.DBPath)
+	// Initialize the store: SQLite, or Postgres for a postgres:// DBPath.
+	store, err := OpenStore(s.cfg.DBPath)
 	if err != nil {
 		return fmt.Errorf("open database: %w", err)
 	}
 	s.store = store
-	s.sqliteStore = store
+	if es, ok := store.(interface{ SetEmbedder(Embedder) }); ok && s.cfg.Embedder != nil {
+		es.SetEmbedder(s.cfg.Embedder)
+	}
 	if err := store.Init(); err != nil {
 		return fmt.Errorf("init database: %w", err)
 	}
-	if err := store.InitDomainTables(); err != nil {
-		return fmt.Errorf("init domain tables: %w", err)
-	}
-	if err := store.InitDomainTablesV2(); err != nil {
-		return fmt.Errorf("init domain tables v2: %w", err)
+	// Domain tables, and the tools and backups that use them, are SQLite-only.
+	if sqlStore, ok := store.(*SQLiteStore); ok {
+		s.sqliteStore = sqlStore
+		if err := sqlStore.InitDomainTables(); err != nil {
+			return fmt.Errorf("init domain tables: %w", err)
+		}
+		if err := sqlStore.InitDomainTablesV2(); err != nil {
+			return fmt.Errorf("init domain tables v2: %w", err)
+		}
+	} else {
+		for _, feature := range []string{
+			"domain tools in chat and channels",
+			"domain tools in scheduled jobs",
+			"domain tools in telegram chats",
+			"POST /admin/backup",
+		} {
+			slog.Warn("store: feature needs SQLite, disabled", "feature", feature, "store", fmt.Sprintf("%T", store))
+		}
 	}
 
 	// Resolve company identity.
@@ -205,25 +305,16 @@ func (s *Server) Start(ctx context.Context) error {
 	s.refreshToolSettings()
 
 	// Wire file-write tracking callback.
-	s.interp.Tools().OnFileWrite = func(ctx context.Context, path, operation, description string) {
-		agentName := ""
-		processID := ""
-		if proc := vega.ProcessFromContext(ctx); proc != nil {
-			processID = proc.ID
-			if proc.Agent != nil {
-				agentName = proc.Agent.Name
-			}
-		}
-		if err := store.InsertWorkspaceFile(WorkspaceFile{
-			Path:        path,
-			Agent:       agentName,
-			ProcessID:   processID,
-			Operation:   operation,
-			Description: description,
-		}); err != nil {
-			slog.Error("failed to record workspace file", "path", path, "error", err)
-		}
-	}
+	s.interp.Tools().OnFileWrite = fileWriteRecorder(store)
+
+	// Record every tool call for the audit trail.
+	s.interp.Tools().Use(toolAuditMiddleware(store))
+
+	// Hold calls to tools listed in VEGA_APPROVAL_TOOLS for human approval.
+	s.interp.Tools().Use(tools.WithApproval(s.approveToolCall))
+
+	// Back summarize_file with a cheap model.
+	s.interp.Tools().SetSummarizer(tools.SummarizerFunc(s.summarize))
 
 	// Initialize population client.
 	popClient, err := population.NewClient()
@@ -248,6 +339,8 @@ func (s *Server) Start(ctx context.Context) error {
 
 	// Register memory tools before injecting meta-agents so they can use them.
 	RegisterMemoryTools(s.interp)
+	RegisterKVTools(s.interp)
+	RegisterWorkspaceTools(s.interp, s.store)
 
 	// Register domain tools (job tracking, follow-ups, production rates).
 	RegisterDomainTools(s.interp)
@@ -262,20 +355,29 @@ func (s *Server) Start(ctx context.Context) error {
 	s.scheduler = NewScheduler(
 		s.interp,
 		func(job dsl.ScheduledJob) error {
-			return s.store.UpsertScheduledJob(ScheduledJob{
+			sj := ScheduledJob{
 				Name:      job.Name,
 				Cron:      job.Cron,
+				Timezone:  job.Timezone,
 				AgentName: job.AgentName,
 				Message:   job.Message,
 				Enabled:   job.Enabled,
-			})
+			}
+			if job.OneShot() {
+				runAt := job.RunAt
+				sj.RunAt = &runAt
+			}
+			return s.store.UpsertScheduledJob(sj)
 		},
 		func(name string) error {
 			return s.store.DeleteScheduledJob(name)
 		},
 	)
-	s.scheduler.inbox = store
-	s.scheduler.store = store
+	if ic, ok := store.(inboxChecker); ok {
+		s.scheduler.inbox = ic
+	}
+	s.scheduler.runs = store
+	s.scheduler.store = s.sqliteStore
 	if storedJobs, err := s.store.ListScheduledJobs(); err != nil {
 		slog.Warn("scheduler: failed to load persisted jobs", "error", err)
 	} else {
@@ -283,10 +385,14 @@ func (s *Server) Start(ctx context.Context) error {
 			job := dsl.ScheduledJob{
 				Name:      sj.Name,
 				Cron:      sj.Cron,
+				Timezone:  sj.Timezone,
 				AgentName: sj.AgentName,
 				Message:   sj.Message,
 				Enabled:   sj.Enabled,
 			}
+			if sj.RunAt != nil {
+				job.RunAt = *sj.RunAt
+			}
 			if err := s.scheduler.AddJob(job); err != nil {
 				slog.Warn("scheduler: failed to restore job", "name", sj.Name, "error", err)
 			}
@@ -302,12 +408,14 @@ func (s *Server) Start(ctx context.Context) error {
 	// Wire inbox backend so DispatchToAgent can post completion notifications.
 	s.interp.SetInboxBackend(inboxBack)
 
+	// Route workflow step progress to per-run SSE streams, and persist it
+	// so interrupted runs keep their completed steps.
+	s.interp.OnStep(s.publishStepEvent)
+	s.interp.OnStep(s.recordWorkflowStep)
+
 	// Wire memory injector so agents get their memories + project context during delegated tasks.
 	s.interp.SetMemoryInjector(func(proc *vega.Process, agentName string) {
-		var memText string
-		if memories, err := s.store.GetUserMemory("default", agentName); err == nil && len(memories) > 0 {
-			memText = formatMemoryForInjection(memories)
-		}
+		memText := userMemoryText(s.store, s.interp, "default", agentName)
 		projectCtx := buildProjectContext(s.interp.Tools().ActiveProject())
 		companyCtx := buildCompanyContext(s.company)
 		if extra := buildExtraSystem(memText, projectCtx, companyCtx); extra != "" {
@@ -446,7 +554,7 @@ func (s *Server) Start(ctx context.Context) error {
 			// so the user sees it in their chat.
 			for name := range s.interp.Agents() {
 				if name == "iris" || strings.HasPrefix(name, "iris:") {
-					_ = s.store.InsertChatMessage(name, "assistant", resp)
+					_ = s.saveChatMessage(name, "assistant", resp)
 				}
 			}
 
@@ -513,6 +621,15 @@ func (s *Server) Start(ctx context.Context) error {
 
 	go s.scheduler.Start(ctx)
 
+	// Prune old events, snapshots and workflow runs per the retention settings.
+	go s.runRetention(ctx)
+
+	// Ping MCP servers and reconnect any that died.
+	go s.runMCPHealth(ctx)
+
+	// Keep the store's cached health current for requireStore.
+	go s.runStoreHealth(ctx)
+
 	// Start Telegram bot if configured (after meta-agents are injected).
 	if s.cfg.TelegramToken != "" {
 		agentName := s.cfg.TelegramAgent
@@ -520,7 +637,7 @@ func (s *Server) Start(ctx context.Context) error {
 			agentName = dsl.IrisAgentName // default to Iris
 		}
 		tb, err := NewTelegramBot(s.cfg.TelegramToken, agentName, s.interp, s.store, s.company, func(userID, agent, userMsg, response string) {
-			s.extractMemory(userID, agent, userMsg, response)
+			s.scheduleMemoryExtraction(userID, agent, userMsg, response)
 		})
 		if err != nil {
 			slog.Warn("telegram bot init failed", "error", err)
@@ -534,6 +651,9 @@ func (s *Server) Start(ctx context.Context) error {
 	// Wire orchestrator callbacks to broker + store.
 	s.wireCallbacks()
 
+	// Pre-spawn the agents that should be ready for their first chat.
+	s.warmAgents()
+
 	// Build router.
 	mux := http.NewServeMux()
 	s.registerRoutes(mux)
@@ -598,18 +718,22 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("DELETE /api/processes/{id}", s.handleKillProcess)
 	mux.HandleFunc("GET /api/agents", s.handleListAgents)
 	mux.HandleFunc("GET /api/workflows", s.handleListWorkflows)
-	mux.HandleFunc("POST /api/workflows/{name}/run", s.handleRunWorkflow)
+	mux.HandleFunc("POST /api/workflows/{name}/run", s.requireStore(s.handleRunWorkflow))
+	mux.HandleFunc("GET /api/workflows/{name}/runs/{run_id}/stream", s.handleWorkflowRunStream)
+	mux.HandleFunc("GET /api/workflows/runs/{run_id}/steps", s.requireStore(s.handleListWorkflowSteps))
 	mux.HandleFunc("GET /api/mcp/servers", s.handleMCPServers)
 	mux.HandleFunc("GET /api/mcp/registry", s.handleMCPRegistry)
-	mux.HandleFunc("POST /api/mcp/servers", s.handleConnectMCPServer)
-	mux.HandleFunc("GET /api/mcp/servers/{name}/config", s.handleGetMCPServerConfig)
-	mux.HandleFunc("PUT /api/mcp/servers/{name}", s.handleUpdateMCPServer)
-	mux.HandleFunc("POST /api/mcp/servers/{name}/refresh", s.handleRefreshMCPServer)
-	mux.HandleFunc("POST /api/mcp/servers/{name}/duplicate", s.handleDuplicateMCPServer)
-	mux.HandleFunc("PUT /api/mcp/servers/{name}/disable", s.handleToggleMCPServer)
-	mux.HandleFunc("DELETE /api/mcp/servers/{name}", s.handleDisconnectMCPServer)
+	mux.HandleFunc("POST /api/mcp/servers", s.requireStore(s.handleConnectMCPServer))
+	mux.HandleFunc("GET /api/mcp/servers/{name}/config", s.requireStore(s.handleGetMCPServerConfig))
+	mux.HandleFunc("PUT /api/mcp/servers/{name}", s.requireStore(s.handleUpdateMCPServer))
+	mux.HandleFunc("POST /api/mcp/servers/{name}/refresh", s.requireStore(s.handleRefreshMCPServer))
+	mux.HandleFunc("POST /api/mcp/servers/{name}/duplicate", s.requireStore(s.handleDuplicateMCPServer))
+	mux.HandleFunc("PUT /api/mcp/servers/{name}/disable", s.requireStore(s.handleToggleMCPServer))
+	mux.HandleFunc("DELETE /api/mcp/servers/{name}", s.requireStore(s.handleDisconnectMCPServer))
 	mux.HandleFunc("GET /api/stats", s.handleStats)
 	mux.HandleFunc("GET /api/spawn-tree", s.handleSpawnTree)
+	mux.HandleFunc("GET /api/supervisors", s.handleListSupervisors)
+	mux.HandleFunc("GET /api/incidents", s.handleListIncidents)
 
 	// Population
 	mux.HandleFunc("GET /api/population/search", s.handlePopulationSearch)
@@ -618,70 +742,83 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/population/installed", s.handlePopulationInstalled)
 
 	// Agent composition
-	mux.HandleFunc("POST /api/agents", s.handleCreateAgent)
-	mux.HandleFunc("PUT /api/agents/{name}", s.handleUpdateAgent)
-	mux.HandleFunc("DELETE /api/agents/{name}", s.handleDeleteAgent)
+	mux.HandleFunc("POST /api/agents", s.requireStore(s.handleCreateAgent))
+	mux.HandleFunc("PUT /api/agents/{name}", s.requireStore(s.handleUpdateAgent))
+	mux.HandleFunc("DELETE /api/agents/{name}", s.requireStore(s.handleDeleteAgent))
 	mux.HandleFunc("GET /api/agents/{name}/template", s.handleExportTemplate)
-	mux.HandleFunc("POST /api/agents/import", s.handleImportTemplate)
+	mux.HandleFunc("GET /api/agents/{name}/export", s.requireStore(s.handleExportAgent))
+	mux.HandleFunc("POST /api/agents/import", s.requireStore(s.handleImportTemplate))
 
 	// Chat
-	mux.HandleFunc("GET /api/agents/{name}/chat", s.handleChatHistory)
-	mux.HandleFunc("POST /api/agents/{name}/chat", s.handleChat)
-	mux.HandleFunc("POST /api/agents/{name}/chat/stream", s.handleChatStream)
+	mux.HandleFunc("GET /api/agents/{name}/chat", s.requireStore(s.handleChatHistory))
+	mux.HandleFunc("GET /api/agents/{name}/chat/search", s.requireStore(s.handleSearchChat))
+	mux.HandleFunc("POST /api/agents/{name}/chat", s.requireStore(s.handleChat))
+	mux.HandleFunc("POST /api/agents/{name}/chat/stream", s.requireStore(s.handleChatStream))
 	mux.HandleFunc("GET /api/agents/{name}/chat/stream", s.handleChatStreamReconnect)
+	mux.HandleFunc("GET /api/agents/{name}/chat/ws", s.requireStore(s.handleChatWS))
 	mux.HandleFunc("GET /api/agents/{name}/chat/status", s.handleChatStatus)
-	mux.HandleFunc("DELETE /api/agents/{name}/chat", s.handleClearChat)
-	mux.HandleFunc("POST /api/agents/{name}/chat/read", s.handleMarkChatRead)
-	mux.HandleFunc("GET /api/chat/unread", s.handleChatUnreadCounts)
+	mux.HandleFunc("POST /api/agents/{name}/chat/stop", s.handleChatStop)
+	mux.HandleFunc("DELETE /api/agents/{name}/chat", s.requireStore(s.handleClearChat))
+	mux.HandleFunc("DELETE /api/agents/{name}/reset", s.requireStore(s.handleResetAgent))
+	mux.HandleFunc("POST /api/agents/{name}/chat/read", s.requireStore(s.handleMarkChatRead))
+	mux.HandleFunc("GET /api/chat/unread", s.requireStore(s.handleChatUnreadCounts))
 
 	// Memory
-	mux.HandleFunc("GET /api/agents/{name}/memory", s.handleGetMemory)
-	mux.HandleFunc("DELETE /api/agents/{name}/memory", s.handleDeleteMemory)
+	mux.HandleFunc("GET /api/agents/{name}/memory", s.requireStore(s.handleGetMemory))
+	mux.HandleFunc("DELETE /api/agents/{name}/memory", s.requireStore(s.handleDeleteMemory))
 
 	// Files
 	mux.HandleFunc("GET /api/files", s.handleListFiles)
 	mux.HandleFunc("GET /api/files/read", s.handleReadFile)
 	mux.HandleFunc("DELETE /api/files", s.handleDeleteFile)
-	mux.HandleFunc("GET /api/files/metadata", s.handleListFileMetadata)
+	mux.HandleFunc("GET /api/files/metadata", s.requireStore(s.handleListFileMetadata))
+	mux.HandleFunc("GET /api/tool-calls", s.requireStore(s.handleListToolCalls))
+	mux.HandleFunc("GET /api/usage", s.requireStore(s.handleUsage))
+	mux.HandleFunc("GET /api/approvals", s.handleListApprovals)
+	mux.HandleFunc("POST /api/approvals/{id}", s.handleDecideApproval)
 
 	// Schedules
 	mux.HandleFunc("GET /api/schedules", s.handleListSchedules)
 	mux.HandleFunc("DELETE /api/schedules/{name}", s.handleDeleteSchedule)
 	mux.HandleFunc("PUT /api/schedules/{name}", s.handleToggleSchedule)
+	mux.HandleFunc("GET /api/schedules/{name}/runs", s.requireStore(s.handleListScheduleRuns))
 
 	// Inbox
-	mux.HandleFunc("GET /api/inbox", s.handleListInbox)
-	mux.HandleFunc("DELETE /api/inbox/resolved", s.handleClearResolvedInbox)
+	mux.HandleFunc("GET /api/inbox", s.requireStore(s.handleListInbox))
+	mux.HandleFunc("DELETE /api/inbox/resolved", s.requireStore(s.handleClearResolvedInbox))
 
 	// Settings
-	mux.HandleFunc("GET /api/settings", s.handleListSettings)
-	mux.HandleFunc("PUT /api/settings", s.handleUpsertSetting)
-	mux.HandleFunc("DELETE /api/settings/{key}", s.handleDeleteSetting)
+	mux.HandleFunc("GET /api/settings", s.requireStore(s.handleListSettings))
+	mux.HandleFunc("PUT /api/settings", s.requireStore(s.handleUpsertSetting))
+	mux.HandleFunc("DELETE /api/settings/{key}", s.requireStore(s.handleDeleteSetting))
 
 	// Channels
-	mux.HandleFunc("GET /api/channels", s.handleListChannels)
-	mux.HandleFunc("POST /api/channels", s.handleCreateChannel)
-	mux.HandleFunc("GET /api/channels/{name}", s.handleGetChannel)
-	mux.HandleFunc("DELETE /api/channels/{name}", s.handleDeleteChannel)
-	mux.HandleFunc("PUT /api/channels/{name}/team", s.handleUpdateChannelTeam)
-	mux.HandleFunc("GET /api/channels/{name}/messages", s.handleListChannelMessages)
-	mux.HandleFunc("GET /api/channels/{name}/messages/{id}/thread", s.handleListThreadMessages)
-	mux.HandleFunc("POST /api/channels/{name}/messages", s.handleChannelPost)
-	mux.HandleFunc("POST /api/channels/{name}/stream", s.handleChannelStream)
-	mux.HandleFunc("GET /api/channels/{name}/stream", s.handleChannelStreamReconnect)
-	mux.HandleFunc("POST /api/channels/{name}/read", s.handleMarkChannelRead)
+	mux.HandleFunc("GET /api/channels", s.requireStore(s.handleListChannels))
+	mux.HandleFunc("POST /api/channels", s.requireStore(s.handleCreateChannel))
+	mux.HandleFunc("GET /api/channels/{name}", s.requireStore(s.handleGetChannel))
+	mux.HandleFunc("DELETE /api/channels/{name}", s.requireStore(s.handleDeleteChannel))
+	mux.HandleFunc("PUT /api/channels/{name}/team", s.requireStore(s.handleUpdateChannelTeam))
+	mux.HandleFunc("GET /api/channels/{name}/messages", s.requireStore(s.handleListChannelMessages))
+	mux.HandleFunc("GET /api/channels/{name}/messages/{id}/thread", s.requireStore(s.handleListThreadMessages))
+	mux.HandleFunc("POST /api/channels/{name}/messages", s.requireStore(s.handleChannelPost))
+	mux.HandleFunc("POST /api/channels/{name}/stream", s.requireStore(s.handleChannelStream))
+	mux.HandleFunc("GET /api/channels/{name}/stream", s.requireStore(s.handleChannelStreamReconnect))
+	mux.HandleFunc("POST /api/channels/{name}/read", s.requireStore(s.handleMarkChannelRead))
 
 	// Prompt History (survives reset)
-	mux.HandleFunc("GET /api/prompt-history", s.handleListPromptHistory)
-	mux.HandleFunc("GET /api/prompt-history/search", s.handleSearchPromptHistory)
-	mux.HandleFunc("DELETE /api/prompt-history/{id}", s.handleDeletePromptHistory)
+	mux.HandleFunc("GET /api/prompt-history", s.requireStore(s.handleListPromptHistory))
+	mux.HandleFunc("GET /api/prompt-history/search", s.requireStore(s.handleSearchPromptHistory))
+	mux.HandleFunc("DELETE /api/prompt-history/{id}", s.requireStore(s.handleDeletePromptHistory))
 
 	// Config
 	mux.HandleFunc("GET /api/config", s.handleGetConfig)
-	mux.HandleFunc("POST /api/config/upload", s.handleConfigUpload)
+	mux.HandleFunc("POST /api/config/upload", s.requireStore(s.handleConfigUpload))
 
 	// Reset
-	mux.HandleFunc("POST /api/reset", s.handleReset)
+	mux.HandleFunc("POST /api/reset", s.requireStore(s.handleReset))
+
+	// Admin
+	mux.HandleFunc("POST /admin/backup", s.requireAdmin(s.requireStore(s.handleBackup)))
 
 	// SSE
 	mux.HandleFunc("GET /api/events", s.handleSSE)
@@ -743,7 +880,7 @@ func (s *Server) wireCallbacks() {
 		})
 
 		// Snapshot final state.
-		s.store.(*SQLiteStore).snapshotProcess(processToResponse(p))
+		snapshotProcess(s.store, processToResponse(p))
 	})
 
 	orch.OnProcessFailed(func(p *vega.Process, err error) {
@@ -774,8 +911,84 @@ func (s *Server) wireCallbacks() {
 		})
 
 		// Snapshot final state.
-		s.store.(*SQLiteStore).snapshotProcess(processToResponse(p))
+		snapshotProcess(s.store, processToResponse(p))
 	})
+
+	orch.OnHistoryCompacted(s.recordChatSummary)
+}
+
+// recordChatSummary persists a compaction of a chat agent's history, so
+// hydrateAgent restores the summary instead of the messages it replaced.
+// Other processes, such as workflow steps, have no stored chat to compact.
+func (s *Server) recordChatSummary(p *vega.Process, c vega.Compaction) {
+	for name, proc := range s.interp.Agents() {
+		if proc != p {
+			continue
+		}
+		if err := s.store.InsertChatSummary(name, c.Summary, c.Replaced); err != nil {
+			slog.Error("failed to persist chat summary", "agent", name, "error", err)
+		}
+		return
+	}
+}
+
+// storeUnavailableMsg is the error returned when a request needs the store
+// and it is missing, closed or read-only.
+const storeUnavailableMsg = "persistence not available"
+
+// storeStatus reports whether the store can serve r. Every request needs a
+// reachable database; anything but a GET also needs it writable. It reads
+// the state runStoreHealth and failed writes leave behind rather than
+// probing the database itself.
+func (s *Server) storeStatus(r *http.Request) error {
+	if s.store == nil {
+		return errors.New("no store configured")
+	}
+	hc, ok := s.store.(healthChecker)
+	if !ok {
+		return nil
+	}
+	readErr, writeErr := hc.Health()
+	if readErr != nil {
+		return readErr
+	}
+	if r.Method != http.MethodGet {
+		return writeErr
+	}
+	return nil
+}
+
+// requireStore wraps a handler that depends on the store. When the store
+// can't serve the request the client gets a 503 up front instead of the
+// handler failing halfway through. Handlers that only decorate their
+// response with stored data skip it and check s.store themselves.
+func (s *Server) requireStore(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := s.storeStatus(r); err != nil {
+			slog.Warn("store unavailable", "method", r.Method, "path", r.URL.Path, "error", err)
+			writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: storeUnavailableMsg})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// requireAdmin wraps an operator-only handler. Requests must carry
+// "Authorization: Bearer <AdminToken>"; with no token configured the
+// endpoint is disabled outright rather than left open.
+func (s *Server) requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cfg.AdminToken == "" {
+			writeJSON(w, http.StatusForbidden, ErrorResponse{Error: "admin endpoints are disabled; set VEGA_ADMIN_TOKEN"})
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.AdminToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, ErrorResponse{Error: "invalid admin token"})
+			return
+		}
+		h(w, r)
+	}
 }
 
 // corsMiddleware adds permissive CORS headers for development.
@@ -825,11 +1038,11 @@ func (s *Server) autoConnectBuiltinServers(ctx context.Context) {
 // autoConnectPersistedServers reconnects MCP servers that were previously
 // connected and persisted in the mcp_servers table.
 func (s *Server) autoConnectPersistedServers(ctx context.Context) {
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
 		return
 	}
-	servers, err := sqlStore.ListMCPServers()
+	servers, err := mcpStore.ListMCPServers()
 	if err != nil {
 		slog.Warn("failed to load persisted MCP servers", "error", err)
 		return
@@ -950,7 +1163,7 @@ func (s *Server) autoConnectPersistedServers(ctx context.Context) {
 // persistYAMLMCPServers ensures YAML-configured MCP servers are persisted to
 // SQLite so the Connections page can display and edit them.
 func (s *Server) persistYAMLMCPServers() {
-	sqlStore, ok := s.store.(*SQLiteStore)
+	mcpStore, ok := s.store.(mcpServerStore)
 	if !ok {
 		return
 	}
@@ -977,7 +1190,7 @@ func (s *Server) persistYAMLMCPServers() {
 			slog.Warn("failed to marshal YAML MCP server config", "server", serverDef.Name, "error", err)
 			continue
 		}
-		if err := sqlStore.UpsertMCPServer(serverDef.Name, string(configJSON)); err != nil {
+		if err := mcpStore.UpsertMCPServer(serverDef.Name, string(configJSON)); err != nil {
 			slog.Warn("failed to persist YAML MCP server config", "server", serverDef.Name, "error", err)
 			continue
 		}
@@ -1,6 +1,7 @@
 package serve
 
 import (
+	"context"
 	"time"
 
 	"github.com/everydev1618/govega/dsl"
@@ -35,6 +36,18 @@ type Store interface {
 	// ListWorkflowRuns returns recent workflow runs.
 	ListWorkflowRuns(limit int) ([]WorkflowRun, error)
 
+	// ClaimIdempotencyKey maps key to runID for workflow for ttl, unless
+	// the key already maps to an unexpired run, and returns the run the key
+	// maps to. A run that hasn't been recorded yet reports status running.
+	ClaimIdempotencyKey(workflow, key, runID string, ttl time.Duration) (*WorkflowRun, error)
+
+	// UpsertWorkflowStep records the latest state of a top-level workflow
+	// step, replacing any earlier state for the same run and index.
+	UpsertWorkflowStep(st WorkflowStep) error
+
+	// ListWorkflowSteps returns the recorded steps of a run in step order.
+	ListWorkflowSteps(runID string) ([]WorkflowStep, error)
+
 	// InsertComposedAgent persists a composed agent definition.
 	InsertComposedAgent(a ComposedAgent) error
 
@@ -47,12 +60,33 @@ type Store interface {
 	// InsertChatMessage persists a chat message.
 	InsertChatMessage(agent, role, content string) error
 
+	// InsertChatMessages persists several chat messages atomically.
+	InsertChatMessages(agent string, msgs []ChatMessage) error
+
 	// ListChatMessages returns chat history for an agent.
 	ListChatMessages(agent string) ([]ChatMessage, error)
 
-	// DeleteChatMessages removes all chat messages for an agent.
+	// ListChatMessagesAfter returns an agent's chat messages stored after
+	// the message with the given ID.
+	ListChatMessagesAfter(agent string, afterID int64) ([]ChatMessage, error)
+
+	// DeleteChatMessages removes all chat messages for an agent, along with
+	// their summaries.
 	DeleteChatMessages(agent string) error
 
+	// InsertChatSummary records a summary that replaces the next replaced
+	// chat messages after the agent's previous summary.
+	InsertChatSummary(agent, summary string, replaced int) error
+
+	// SearchChatMessages returns up to limit of an agent's chat messages
+	// that contain every word of query, best match first, each with up to
+	// around messages either side of it.
+	SearchChatMessages(agent, query string, limit, around int) ([]ChatSearchHit, error)
+
+	// LatestChatSummary returns the agent's most recent chat summary, or nil
+	// if the chat has never been compacted.
+	LatestChatSummary(agent string) (*ChatSummary, error)
+
 	// UpsertUserMemory creates or updates a memory layer for a user+agent.
 	UpsertUserMemory(userID, agent, layer, content string) error
 
@@ -68,12 +102,29 @@ type Store interface {
 	// SearchMemoryItems searches memory items by keyword across topic, content, and tags.
 	SearchMemoryItems(userID, agent, query string, limit int) ([]MemoryItem, error)
 
+	// SearchMemoryItemsSemantic ranks memory items by embedding similarity to
+	// query, falling back to keyword search when no embedder is configured.
+	SearchMemoryItemsSemantic(userID, agent, query string, limit int) ([]MemoryItem, error)
+
 	// DeleteMemoryItem removes a memory item by ID.
 	DeleteMemoryItem(id int64) error
 
 	// ListMemoryItemsByTopic returns memory items for a given user+agent+topic.
 	ListMemoryItemsByTopic(userID, agent, topic string) ([]MemoryItem, error)
 
+	// UpsertKV sets a scratchpad value for a user+agent.
+	UpsertKV(userID, agent, key, value string) error
+
+	// GetKV returns a scratchpad value; ok is false if the key is not set.
+	GetKV(userID, agent, key string) (value string, ok bool, err error)
+
+	// DeleteKV removes a scratchpad value, returning sql.ErrNoRows if unset.
+	DeleteKV(userID, agent, key string) error
+
+	// ResetAgentState deletes an agent's chat history, and userID's memory
+	// and scratchpad for baseAgent, all in one transaction.
+	ResetAgentState(agent, userID, baseAgent string) (*AgentReset, error)
+
 	// UpsertScheduledJob creates or replaces a scheduled job.
 	UpsertScheduledJob(job ScheduledJob) error
 
@@ -83,6 +134,12 @@ type Store interface {
 	// ListScheduledJobs returns all scheduled jobs.
 	ListScheduledJobs() ([]ScheduledJob, error)
 
+	// InsertScheduleRun records one firing of a scheduled job.
+	InsertScheduleRun(run ScheduleRun) error
+
+	// ListScheduleRuns returns recent runs of a scheduled job, newest first.
+	ListScheduleRuns(name string, limit int) ([]ScheduleRun, error)
+
 	// InsertWorkspaceFile records a file write by an agent.
 	InsertWorkspaceFile(f WorkspaceFile) error
 
@@ -92,6 +149,20 @@ type Store interface {
 	// ListWorkspaceFileAgents returns distinct agent names that have written files.
 	ListWorkspaceFileAgents() ([]string, error)
 
+	// InsertToolCall records one tool execution.
+	InsertToolCall(c ToolCall) error
+
+	// ListToolCalls returns recent tool calls, newest first, optionally
+	// filtered by agent.
+	ListToolCalls(agent string, limit int) ([]ToolCall, error)
+
+	// InsertCostEntry records the cost of an LLM call in the cost ledger.
+	InsertCostEntry(e CostEntry) error
+
+	// Usage totals the cost ledger since the given time, optionally
+	// filtered by user and agent.
+	Usage(userID, agent string, since time.Time) (*Usage, error)
+
 	// UpsertSetting creates or updates a setting.
 	UpsertSetting(s Setting) error
 
@@ -184,6 +255,55 @@ type Store interface {
 	DeletePromptHistory(id int64) error
 }
 
+// OpenStore opens the store named by dsn: a postgres:// or postgresql://
+// URL selects PostgresStore, anything else is a SQLite database path.
+func OpenStore(dsn string) (Store, error) {
+	if isPostgresDSN(dsn) {
+		return NewPostgresStore(dsn)
+	}
+	return NewSQLiteStore(dsn)
+}
+
+// mcpServerStore is implemented by stores that persist MCP server
+// connections for auto-reconnect.
+type mcpServerStore interface {
+	UpsertMCPServer(name, configJSON string) error
+	DeleteMCPServer(name string) error
+	ListMCPServers() ([]MCPServerConfig, error)
+	SetMCPServerDisabled(name string, disabled bool) error
+}
+
+// healthChecker is implemented by stores that can report whether the
+// database is reachable and writable. Health returns a cached state that
+// CheckHealth refreshes, so it is cheap enough to consult on every request.
+type healthChecker interface {
+	Health() (readErr, writeErr error)
+	CheckHealth(ctx context.Context)
+}
+
+// retentionStore is implemented by stores that prune old history.
+type retentionStore interface {
+	RetentionPolicy() RetentionPolicy
+	Prune(p RetentionPolicy) (int64, error)
+	Vacuum()
+}
+
+// snapshotProcess creates a snapshot from a live process and persists it.
+func snapshotProcess(store Store, proc ProcessResponse) error {
+	return store.InsertProcessSnapshot(ProcessSnapshot{
+		ProcessID:    proc.ID,
+		AgentName:    proc.Agent,
+		Status:       proc.Status,
+		ParentID:     proc.ParentID,
+		InputTokens:  proc.Metrics.InputTokens,
+		OutputTokens: proc.Metrics.OutputTokens,
+		CostUSD:      proc.Metrics.CostUSD,
+		StartedAt:    proc.StartedAt,
+		CompletedAt:  proc.CompletedAt,
+		SnapshotAt:   time.Now(),
+	})
+}
+
 // UserMemory is a persisted memory layer for a user+agent pair.
 type UserMemory struct {
 	UserID    string    `json:"user_id"`
@@ -200,6 +320,34 @@ type ChatMessage struct {
 	Content string `json:"content"`
 }
 
+// ChatSearchHit is a chat message that matched a search, with the messages
+// around it. Highlights are the byte ranges of the matched words in Content.
+type ChatSearchHit struct {
+	ID         int64         `json:"id"`
+	Role       string        `json:"role"`
+	Content    string        `json:"content"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Highlights []TextRange   `json:"highlights"`
+	Before     []ChatMessage `json:"before"`
+	After      []ChatMessage `json:"after"`
+}
+
+// TextRange is the byte range [Start, End) of a string.
+type TextRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// ChatSummary is a compaction summary of an agent's chat. It stands in for
+// every chat message up to and including ThroughID when the history is
+// loaded back into a process.
+type ChatSummary struct {
+	Agent     string    `json:"agent"`
+	Summary   string    `json:"summary"`
+	ThroughID int64     `json:"through_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // StoreEvent is a persisted orchestration event.
 type StoreEvent struct {
 	ID        int64     `json:"id"`
@@ -255,14 +403,28 @@ type MemoryItem struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// ScheduledJob is a persisted recurring agent trigger.
+// ScheduledJob is a persisted agent trigger. Recurring jobs have a Cron
+// expression; one-shot jobs have RunAt instead.
 type ScheduledJob struct {
-	Name      string    `json:"name"`
-	Cron      string    `json:"cron"`
-	AgentName string    `json:"agent"`
-	Message   string    `json:"message"`
-	Enabled   bool      `json:"enabled"`
-	CreatedAt time.Time `json:"created_at"`
+	Name      string     `json:"name"`
+	Cron      string     `json:"cron"`
+	Timezone  string     `json:"timezone,omitempty"`
+	RunAt     *time.Time `json:"run_at,omitempty"`
+	AgentName string     `json:"agent"`
+	Message   string     `json:"message"`
+	Enabled   bool       `json:"enabled"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// ScheduleRun records one firing of a scheduled job.
+type ScheduleRun struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Agent      string    `json:"agent"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	FiredAt    time.Time `json:"fired_at"`
 }
 
 // WorkspaceFile tracks a file written by an agent.
@@ -276,6 +438,56 @@ type WorkspaceFile struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// ToolCall is an audit record of one tool execution. Args and Result are
+// truncated before they are stored.
+type ToolCall struct {
+	ID         int64     `json:"id"`
+	Tool       string    `json:"tool"`
+	Agent      string    `json:"agent"`
+	ProcessID  string    `json:"process_id"`
+	Args       string    `json:"args"`
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CostEntry is a cost ledger record of one LLM call made while serving a
+// user's chat with an agent. The ledger is billing data, so it survives
+// resets and retention pruning.
+type CostEntry struct {
+	ID           int64     `json:"id"`
+	UserID       string    `json:"user_id"`
+	Agent        string    `json:"agent"`
+	Model        string    `json:"model"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Usage is the cost ledger totalled for a user, an agent, or both.
+type Usage struct {
+	UserID       string    `json:"user_id,omitempty"`
+	Agent        string    `json:"agent,omitempty"`
+	Since        time.Time `json:"since,omitzero"`
+	Calls        int       `json:"calls"`
+	InputTokens  int       `json:"input_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+}
+
+// AgentReset reports what resetting an agent cleared.
+type AgentReset struct {
+	Agent        string `json:"agent"`
+	UserID       string `json:"user_id"`
+	ChatMessages int64  `json:"chat_messages"`
+	Memories     int64  `json:"memories"`
+	MemoryItems  int64  `json:"memory_items"`
+	KVKeys       int64  `json:"kv_keys"`
+	ProcessReset bool   `json:"process_reset"`
+}
+
 // Setting is a persisted key-value configuration entry.
 type Setting struct {
 	Key       string    `json:"key"`
@@ -302,3 +514,18 @@ type WorkflowRun struct {
 	Result    string    `json:"result,omitempty"`
 	StartedAt time.Time `json:"started_at"`
 }
+
+// WorkflowStep is the persisted state of one top-level step of a workflow
+// run. Steps are recorded as they start and finish, so a run that crashes
+// or is cancelled keeps the results of the steps it completed.
+type WorkflowStep struct {
+	RunID     string    `json:"run_id"`
+	Index     int       `json:"index"`
+	Type      string    `json:"type"`
+	Agent     string    `json:"agent,omitempty"`
+	Save      string    `json:"save,omitempty"`
+	Status    string    `json:"status"`
+	Result    string    `json:"result,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
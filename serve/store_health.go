@@ -0,0 +1,32 @@
+package serve
+
+import (
+	"context"
+	"time"
+)
+
+// storeHealthInterval is how often the store is probed for reachability
+// and writability.
+const storeHealthInterval = 15 * time.Second
+
+// runStoreHealth probes the store on a fixed interval until ctx is done,
+// refreshing the cached state requireStore consults. A store that starts
+// rejecting writes is also marked as soon as a write fails; this loop is
+// what notices it recover.
+func (s *Server) runStoreHealth(ctx context.Context) {
+	hc, ok := s.store.(healthChecker)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(storeHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		hc.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,2037 @@
+package serve
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/everydev1618/govega/dsl"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresStore implements Store on PostgreSQL, so several serve instances
+// behind a load balancer can share state. It keeps the SQLite schema, with
+// Postgres types: BIGSERIAL ids, TIMESTAMPTZ times, BOOLEAN flags and BYTEA
+// embeddings.
+type PostgresStore struct {
+	db       *sql.DB
+	embedder Embedder // optional; enables semantic memory search
+
+	// healthMu guards the cached health state reported by Health.
+	healthMu sync.RWMutex
+	readErr  error
+	writeErr error
+}
+
+// NewPostgresStore connects to the database at dsn, a postgres:// URL or
+// key=value connection string.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// isPostgresDSN reports whether dsn names a Postgres database rather than
+// a SQLite file.
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// Init creates the schema tables. Every statement is idempotent, so Init
+// brings a database created by an older version up to date.
+func (s *PostgresStore) Init() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS events (
+		id          BIGSERIAL PRIMARY KEY,
+		type        TEXT NOT NULL,
+		process_id  TEXT NOT NULL DEFAULT '',
+		agent_name  TEXT NOT NULL DEFAULT '',
+		timestamp   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		data        TEXT NOT NULL DEFAULT '',
+		result      TEXT NOT NULL DEFAULT '',
+		error       TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE IF NOT EXISTS process_snapshots (
+		id            BIGSERIAL PRIMARY KEY,
+		process_id    TEXT NOT NULL,
+		agent_name    TEXT NOT NULL DEFAULT '',
+		status        TEXT NOT NULL DEFAULT '',
+		parent_id     TEXT NOT NULL DEFAULT '',
+		input_tokens  BIGINT NOT NULL DEFAULT 0,
+		output_tokens BIGINT NOT NULL DEFAULT 0,
+		cost_usd      DOUBLE PRECISION NOT NULL DEFAULT 0,
+		started_at    TIMESTAMPTZ,
+		completed_at  TIMESTAMPTZ,
+		snapshot_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS workflow_runs (
+		id         BIGSERIAL PRIMARY KEY,
+		run_id     TEXT NOT NULL UNIQUE,
+		workflow   TEXT NOT NULL,
+		inputs     TEXT NOT NULL DEFAULT '{}',
+		status     TEXT NOT NULL DEFAULT 'running',
+		result     TEXT NOT NULL DEFAULT '',
+		started_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS workflow_steps (
+		id         BIGSERIAL PRIMARY KEY,
+		run_id     TEXT NOT NULL,
+		step_index INTEGER NOT NULL,
+		type       TEXT NOT NULL DEFAULT '',
+		agent      TEXT NOT NULL DEFAULT '',
+		save       TEXT NOT NULL DEFAULT '',
+		status     TEXT NOT NULL,
+		result     TEXT NOT NULL DEFAULT '',
+		error      TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (run_id, step_index)
+	);
+
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		workflow   TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		run_id     TEXT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		PRIMARY KEY (workflow, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS composed_agents (
+		name         TEXT PRIMARY KEY,
+		display_name TEXT NOT NULL DEFAULT '',
+		title        TEXT NOT NULL DEFAULT '',
+		avatar       TEXT NOT NULL DEFAULT '',
+		model        TEXT NOT NULL DEFAULT '',
+		persona      TEXT NOT NULL DEFAULT '',
+		skills       TEXT NOT NULL DEFAULT '[]',
+		tools        TEXT NOT NULL DEFAULT '[]',
+		team         TEXT NOT NULL DEFAULT '[]',
+		system       TEXT NOT NULL DEFAULT '',
+		temperature  DOUBLE PRECISION,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS chat_messages (
+		id         BIGSERIAL PRIMARY KEY,
+		agent      TEXT NOT NULL,
+		role       TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS chat_summaries (
+		id         BIGSERIAL PRIMARY KEY,
+		agent      TEXT NOT NULL,
+		summary    TEXT NOT NULL,
+		through_id BIGINT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS user_memory (
+		id         BIGSERIAL PRIMARY KEY,
+		user_id    TEXT NOT NULL,
+		agent      TEXT NOT NULL,
+		layer      TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, agent, layer)
+	);
+
+	CREATE TABLE IF NOT EXISTS agent_kv (
+		user_id    TEXT NOT NULL,
+		agent      TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		value      TEXT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, agent, key)
+	);
+
+	CREATE TABLE IF NOT EXISTS scheduled_jobs (
+		name       TEXT PRIMARY KEY,
+		cron       TEXT NOT NULL,
+		timezone   TEXT NOT NULL DEFAULT '',
+		run_at     TIMESTAMPTZ,
+		agent_name TEXT NOT NULL,
+		message    TEXT NOT NULL,
+		enabled    BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS schedule_runs (
+		id          BIGSERIAL PRIMARY KEY,
+		name        TEXT NOT NULL,
+		agent_name  TEXT NOT NULL,
+		response    TEXT NOT NULL DEFAULT '',
+		error       TEXT NOT NULL DEFAULT '',
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		fired_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS memory_items (
+		id         BIGSERIAL PRIMARY KEY,
+		user_id    TEXT NOT NULL,
+		agent      TEXT NOT NULL,
+		topic      TEXT NOT NULL DEFAULT '',
+		content    TEXT NOT NULL,
+		tags       TEXT NOT NULL DEFAULT '',
+		embedding  BYTEA,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS workspace_files (
+		id          BIGSERIAL PRIMARY KEY,
+		path        TEXT NOT NULL,
+		agent       TEXT NOT NULL DEFAULT '',
+		process_id  TEXT NOT NULL DEFAULT '',
+		operation   TEXT NOT NULL DEFAULT 'write',
+		description TEXT NOT NULL DEFAULT '',
+		created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS tool_calls (
+		id          BIGSERIAL PRIMARY KEY,
+		tool        TEXT NOT NULL,
+		agent       TEXT NOT NULL DEFAULT '',
+		process_id  TEXT NOT NULL DEFAULT '',
+		args        TEXT NOT NULL DEFAULT '',
+		result      TEXT NOT NULL DEFAULT '',
+		error       TEXT NOT NULL DEFAULT '',
+		duration_ms BIGINT NOT NULL DEFAULT 0,
+		created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS cost_ledger (
+		id            BIGSERIAL PRIMARY KEY,
+		user_id       TEXT NOT NULL,
+		agent         TEXT NOT NULL,
+		model         TEXT NOT NULL DEFAULT '',
+		input_tokens  BIGINT NOT NULL DEFAULT 0,
+		output_tokens BIGINT NOT NULL DEFAULT 0,
+		cost_usd      DOUBLE PRECISION NOT NULL DEFAULT 0,
+		created_at    TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key        TEXT PRIMARY KEY,
+		value      TEXT NOT NULL,
+		sensitive  BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS mcp_servers (
+		name       TEXT PRIMARY KEY,
+		config     TEXT NOT NULL DEFAULT '{}',
+		disabled   BOOLEAN NOT NULL DEFAULT FALSE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS channels (
+		id          TEXT PRIMARY KEY,
+		name        TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		team        TEXT NOT NULL DEFAULT '[]',
+		mode        TEXT NOT NULL DEFAULT '',
+		created_by  TEXT NOT NULL,
+		created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS channel_messages (
+		id          BIGSERIAL PRIMARY KEY,
+		channel_id  TEXT NOT NULL REFERENCES channels(id) ON DELETE CASCADE,
+		thread_id   BIGINT,
+		agent       TEXT NOT NULL DEFAULT '',
+		sender      TEXT NOT NULL DEFAULT '',
+		role        TEXT NOT NULL,
+		content     TEXT NOT NULL,
+		metadata    TEXT NOT NULL DEFAULT '{}',
+		created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS agent_inbox (
+		id          BIGSERIAL PRIMARY KEY,
+		from_agent  TEXT NOT NULL,
+		subject     TEXT NOT NULL,
+		body        TEXT NOT NULL DEFAULT '',
+		priority    TEXT NOT NULL DEFAULT 'normal',
+		status      TEXT NOT NULL DEFAULT 'pending',
+		resolution  TEXT DEFAULT '',
+		created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		resolved_at TIMESTAMPTZ
+	);
+
+	CREATE TABLE IF NOT EXISTS inbox_replies (
+		id         BIGSERIAL PRIMARY KEY,
+		inbox_id   BIGINT NOT NULL REFERENCES agent_inbox(id) ON DELETE CASCADE,
+		role       TEXT NOT NULL,
+		agent      TEXT NOT NULL DEFAULT '',
+		content    TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS prompt_history (
+		id         BIGSERIAL PRIMARY KEY,
+		prompt     TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS channel_read_cursors (
+		channel_id   TEXT NOT NULL,
+		user_id      TEXT NOT NULL DEFAULT 'default',
+		last_read_id BIGINT NOT NULL DEFAULT 0,
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (channel_id, user_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS chat_read_cursors (
+		agent        TEXT NOT NULL,
+		user_id      TEXT NOT NULL DEFAULT 'default',
+		last_read_id BIGINT NOT NULL DEFAULT 0,
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (agent, user_id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_events_process ON events(process_id);
+	CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
+	CREATE INDEX IF NOT EXISTS idx_snapshots_process ON process_snapshots(process_id);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+	CREATE INDEX IF NOT EXISTS idx_chat_agent ON chat_messages(agent);
+	CREATE INDEX IF NOT EXISTS idx_chat_content_fts ON chat_messages USING GIN (to_tsvector('simple', content));
+	CREATE INDEX IF NOT EXISTS idx_chat_summaries_agent ON chat_summaries(agent);
+	CREATE INDEX IF NOT EXISTS idx_schedule_runs_name ON schedule_runs(name, id);
+	CREATE INDEX IF NOT EXISTS idx_memory_items_user_agent ON memory_items(user_id, agent);
+	CREATE INDEX IF NOT EXISTS idx_memory_items_topic ON memory_items(user_id, agent, topic);
+	CREATE INDEX IF NOT EXISTS idx_workspace_files_agent ON workspace_files(agent);
+	CREATE INDEX IF NOT EXISTS idx_tool_calls_agent ON tool_calls(agent);
+	CREATE INDEX IF NOT EXISTS idx_cost_ledger_user_agent ON cost_ledger(user_id, agent, created_at);
+	CREATE INDEX IF NOT EXISTS idx_channel_messages_channel ON channel_messages(channel_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_channel_messages_thread ON channel_messages(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_agent_inbox_status ON agent_inbox(status, created_at);
+	CREATE INDEX IF NOT EXISTS idx_inbox_replies_inbox ON inbox_replies(inbox_id, created_at);
+	`
+	// Serialise schema creation across instances starting together;
+	// concurrent CREATE ... IF NOT EXISTS can still collide in Postgres.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('vega.schema'))`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database.
+func (s *PostgresStore) Close() error {
+	closed := errors.New("store closed")
+	s.setHealth(closed, closed)
+	return s.db.Close()
+}
+
+// Ping reports whether the database can be reached.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Health returns the store's health as of the last CheckHealth, without
+// touching the database.
+func (s *PostgresStore) Health() (readErr, writeErr error) {
+	s.healthMu.RLock()
+	defer s.healthMu.RUnlock()
+	return s.readErr, s.writeErr
+}
+
+// CheckHealth probes the database and updates the state Health reports.
+func (s *PostgresStore) CheckHealth(ctx context.Context) {
+	readErr := s.Ping(ctx)
+	writeErr := readErr
+	if readErr == nil {
+		writeErr = s.CheckWritable(ctx)
+	}
+	s.setHealth(readErr, writeErr)
+}
+
+func (s *PostgresStore) setHealth(readErr, writeErr error) {
+	s.healthMu.Lock()
+	s.readErr, s.writeErr = readErr, writeErr
+	s.healthMu.Unlock()
+}
+
+// CheckWritable reports whether the database accepts writes, such as when
+// it is a read-only replica, by running a write that matches no rows and
+// rolling it back.
+func (s *PostgresStore) CheckWritable(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx, "DELETE FROM events WHERE false")
+	return err
+}
+
+// InsertEvent records an orchestration event.
+func (s *PostgresStore) InsertEvent(e StoreEvent) error {
+	_, err := s.db.Exec(
+		`INSERT INTO events (type, process_id, agent_name, timestamp, data, result, error)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		e.Type, e.ProcessID, e.AgentName, e.Timestamp, e.Data, e.Result, e.Error,
+	)
+	return err
+}
+
+// InsertProcessSnapshot records a process state snapshot.
+func (s *PostgresStore) InsertProcessSnapshot(snap ProcessSnapshot) error {
+	_, err := s.db.Exec(
+		`INSERT INTO process_snapshots
+		 (process_id, agent_name, status, parent_id, input_tokens, output_tokens, cost_usd, started_at, completed_at, snapshot_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		snap.ProcessID, snap.AgentName, snap.Status, snap.ParentID,
+		snap.InputTokens, snap.OutputTokens, snap.CostUSD,
+		snap.StartedAt, snap.CompletedAt, snap.SnapshotAt,
+	)
+	return err
+}
+
+// InsertWorkflowRun records a workflow execution.
+func (s *PostgresStore) InsertWorkflowRun(r WorkflowRun) error {
+	_, err := s.db.Exec(
+		`INSERT INTO workflow_runs (run_id, workflow, inputs, status, started_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		r.RunID, r.Workflow, r.Inputs, r.Status, r.StartedAt,
+	)
+	return err
+}
+
+// UpdateWorkflowRun updates a workflow run status and result.
+func (s *PostgresStore) UpdateWorkflowRun(runID string, status string, result string) error {
+	_, err := s.db.Exec(
+		`UPDATE workflow_runs SET status = $1, result = $2 WHERE run_id = $3`,
+		status, result, runID,
+	)
+	return err
+}
+
+// ClaimIdempotencyKey maps key to runID for workflow for ttl, unless the
+// key already maps to an unexpired run, and returns the run the key maps
+// to. Expired keys are deleted on the way.
+func (s *PostgresStore) ClaimIdempotencyKey(workflow, key, runID string, ttl time.Duration) (*WorkflowRun, error) {
+	now := time.Now()
+	if _, err := s.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= $1`, now.Unix()); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(
+		`INSERT INTO idempotency_keys (workflow, key, run_id, expires_at) VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (workflow, key) DO NOTHING`,
+		workflow, key, runID, now.Add(ttl).Unix(),
+	); err != nil {
+		return nil, err
+	}
+
+	run := &WorkflowRun{Workflow: workflow}
+	err := s.db.QueryRow(
+		`SELECT k.run_id, COALESCE(r.status, 'running'), COALESCE(r.result, '')
+		 FROM idempotency_keys k LEFT JOIN workflow_runs r ON r.run_id = k.run_id
+		 WHERE k.workflow = $1 AND k.key = $2`, workflow, key,
+	).Scan(&run.RunID, &run.Status, &run.Result)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// UpsertWorkflowStep records the latest state of a top-level workflow
+// step, replacing any earlier state for the same run and index.
+func (s *PostgresStore) UpsertWorkflowStep(st WorkflowStep) error {
+	_, err := s.db.Exec(
+		`INSERT INTO workflow_steps (run_id, step_index, type, agent, save, status, result, error, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+		 ON CONFLICT (run_id, step_index) DO UPDATE SET
+		   type = excluded.type, agent = excluded.agent, save = excluded.save,
+		   status = excluded.status, result = excluded.result, error = excluded.error,
+		   updated_at = CURRENT_TIMESTAMP`,
+		st.RunID, st.Index, st.Type, st.Agent, st.Save, st.Status, st.Result, st.Error,
+	)
+	return err
+}
+
+// ListWorkflowSteps returns the recorded steps of a run in step order.
+func (s *PostgresStore) ListWorkflowSteps(runID string) ([]WorkflowStep, error) {
+	rows, err := s.db.Query(
+		`SELECT run_id, step_index, type, agent, save, status, result, error, updated_at
+		 FROM workflow_steps WHERE run_id = $1 ORDER BY step_index`, runID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []WorkflowStep
+	for rows.Next() {
+		var st WorkflowStep
+		if err := rows.Scan(&st.RunID, &st.Index, &st.Type, &st.Agent, &st.Save, &st.Status, &st.Result, &st.Error, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		steps = append(steps, st)
+	}
+	return steps, rows.Err()
+}
+
+// ListEvents returns recent events, newest first.
+func (s *PostgresStore) ListEvents(limit int) ([]StoreEvent, error) {
+	rows, err := s.db.Query(
+		`SELECT id, type, process_id, agent_name, timestamp, data, result, error
+		 FROM events ORDER BY id DESC LIMIT $1`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []StoreEvent
+	for rows.Next() {
+		var e StoreEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.ProcessID, &e.AgentName, &e.Timestamp, &e.Data, &e.Result, &e.Error); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// ListProcessSnapshots returns the latest snapshot per process.
+func (s *PostgresStore) ListProcessSnapshots() ([]ProcessSnapshot, error) {
+	rows, err := s.db.Query(
+		`SELECT ps.id, ps.process_id, ps.agent_name, ps.status, ps.parent_id,
+		        ps.input_tokens, ps.output_tokens, ps.cost_usd,
+		        ps.started_at, ps.completed_at, ps.snapshot_at
+		 FROM process_snapshots ps
+		 INNER JOIN (
+		   SELECT process_id, MAX(id) AS max_id FROM process_snapshots GROUP BY process_id
+		 ) latest ON ps.id = latest.max_id
+		 ORDER BY ps.started_at DESC NULLS LAST`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []ProcessSnapshot
+	for rows.Next() {
+		var snap ProcessSnapshot
+		var startedAt, completedAt sql.NullTime
+		if err := rows.Scan(
+			&snap.ID, &snap.ProcessID, &snap.AgentName, &snap.Status, &snap.ParentID,
+			&snap.InputTokens, &snap.OutputTokens, &snap.CostUSD,
+			&startedAt, &completedAt, &snap.SnapshotAt,
+		); err != nil {
+			return nil, err
+		}
+		snap.StartedAt = startedAt.Time
+		if completedAt.Valid {
+			snap.CompletedAt = &completedAt.Time
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, rows.Err()
+}
+
+// ListWorkflowRuns returns recent workflow runs.
+func (s *PostgresStore) ListWorkflowRuns(limit int) ([]WorkflowRun, error) {
+	rows, err := s.db.Query(
+		`SELECT id, run_id, workflow, inputs, status, result, started_at
+		 FROM workflow_runs ORDER BY id DESC LIMIT $1`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []WorkflowRun
+	for rows.Next() {
+		var r WorkflowRun
+		if err := rows.Scan(&r.ID, &r.RunID, &r.Workflow, &r.Inputs, &r.Status, &r.Result, &r.StartedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// InsertComposedAgent persists a composed agent definition, replacing any
+// agent with the same name.
+func (s *PostgresStore) InsertComposedAgent(a ComposedAgent) error {
+	skillsJSON, _ := json.Marshal(a.Skills)
+	toolsJSON, _ := json.Marshal(a.Tools)
+	teamJSON, _ := json.Marshal(a.Team)
+	_, err := s.db.Exec(
+		`INSERT INTO composed_agents (name, display_name, title, avatar, model, persona, skills, tools, team, system, temperature, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		 ON CONFLICT (name) DO UPDATE SET
+		   display_name = excluded.display_name, title = excluded.title, avatar = excluded.avatar,
+		   model = excluded.model, persona = excluded.persona, skills = excluded.skills,
+		   tools = excluded.tools, team = excluded.team, system = excluded.system,
+		   temperature = excluded.temperature, created_at = excluded.created_at`,
+		a.Name, a.DisplayName, a.Title, a.Avatar, a.Model, a.Persona, string(skillsJSON), string(toolsJSON), string(teamJSON), a.System, a.Temperature, a.CreatedAt,
+	)
+	return err
+}
+
+// ListComposedAgents returns all composed agents.
+func (s *PostgresStore) ListComposedAgents() ([]ComposedAgent, error) {
+	rows, err := s.db.Query(
+		`SELECT name, display_name, title, avatar, model, persona, skills, tools, team, system, temperature, created_at
+		 FROM composed_agents ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []ComposedAgent
+	for rows.Next() {
+		var a ComposedAgent
+		var skillsJSON, toolsJSON, teamJSON string
+		var temp sql.NullFloat64
+		if err := rows.Scan(&a.Name, &a.DisplayName, &a.Title, &a.Avatar, &a.Model, &a.Persona, &skillsJSON, &toolsJSON, &teamJSON, &a.System, &temp, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(skillsJSON), &a.Skills)
+		json.Unmarshal([]byte(toolsJSON), &a.Tools)
+		json.Unmarshal([]byte(teamJSON), &a.Team)
+		if temp.Valid {
+			a.Temperature = &temp.Float64
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+// DeleteComposedAgent removes a composed agent by name.
+func (s *PostgresStore) DeleteComposedAgent(name string) error {
+	return expectRows(s.db.Exec(`DELETE FROM composed_agents WHERE name = $1`, name))
+}
+
+// InsertChatMessage persists a chat message for an agent.
+func (s *PostgresStore) InsertChatMessage(agent, role, content string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO chat_messages (agent, role, content) VALUES ($1, $2, $3)`,
+		agent, role, content,
+	)
+	return err
+}
+
+// InsertChatMessages persists msgs in order in one transaction, so either
+// all of them are saved or none are.
+func (s *PostgresStore) InsertChatMessages(agent string, msgs []ChatMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO chat_messages (agent, role, content) VALUES ($1, $2, $3)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range msgs {
+		if _, err := stmt.Exec(agent, m.Role, m.Content); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListChatMessages returns all chat messages for an agent, oldest first.
+func (s *PostgresStore) ListChatMessages(agent string) ([]ChatMessage, error) {
+	return s.ListChatMessagesAfter(agent, 0)
+}
+
+// ListChatMessagesAfter returns an agent's chat messages with IDs above
+// afterID, oldest first.
+func (s *PostgresStore) ListChatMessagesAfter(agent string, afterID int64) ([]ChatMessage, error) {
+	rows, err := s.db.Query(
+		`SELECT role, content FROM chat_messages WHERE agent = $1 AND id > $2 ORDER BY id ASC`, agent, afterID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []ChatMessage
+	for rows.Next() {
+		var m ChatMessage
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// DeleteChatMessages removes all chat messages and summaries for an agent.
+func (s *PostgresStore) DeleteChatMessages(agent string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM chat_summaries WHERE agent = $1`, agent); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_messages WHERE agent = $1`, agent); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InsertChatSummary records summary as replacing the next replaced chat
+// messages after the agent's previous summary. If fewer messages are stored,
+// it covers all of them.
+func (s *PostgresStore) InsertChatSummary(agent, summary string, replaced int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var after int64
+	err = tx.QueryRow(
+		`SELECT through_id FROM chat_summaries WHERE agent = $1 ORDER BY id DESC LIMIT 1`, agent,
+	).Scan(&after)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	var through int64
+	err = tx.QueryRow(
+		`SELECT id FROM chat_messages WHERE agent = $1 AND id > $2 ORDER BY id ASC LIMIT 1 OFFSET $3`,
+		agent, after, max(replaced-1, 0),
+	).Scan(&through)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(
+			`SELECT COALESCE(MAX(id), $1) FROM chat_messages WHERE agent = $2`, after, agent,
+		).Scan(&through)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO chat_summaries (agent, summary, through_id) VALUES ($1, $2, $3)`,
+		agent, summary, through,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LatestChatSummary returns the agent's most recent chat summary, or nil if
+// there is none.
+func (s *PostgresStore) LatestChatSummary(agent string) (*ChatSummary, error) {
+	var cs ChatSummary
+	err := s.db.QueryRow(
+		`SELECT agent, summary, through_id, created_at FROM chat_summaries
+		 WHERE agent = $1 ORDER BY id DESC LIMIT 1`, agent,
+	).Scan(&cs.Agent, &cs.Summary, &cs.ThroughID, &cs.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+// SearchChatMessages returns up to limit of the agent's chat messages that
+// contain every word of query, ranked by ts_rank, each with up to around
+// messages either side of it.
+func (s *PostgresStore) SearchChatMessages(agent, query string, limit, around int) ([]ChatSearchHit, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	rows, err := s.db.Query(
+		`SELECT id, role, content, created_at FROM chat_messages
+		 WHERE agent = $1 AND to_tsvector('simple', content) @@ plainto_tsquery('simple', $2)
+		 ORDER BY ts_rank(to_tsvector('simple', content), plainto_tsquery('simple', $2)) DESC, id DESC
+		 LIMIT $3`, agent, query, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var hits []ChatSearchHit
+	for rows.Next() {
+		var h ChatSearchHit
+		if err := rows.Scan(&h.ID, &h.Role, &h.Content, &h.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.Highlights = wordRanges(h.Content, query)
+		hits = append(hits, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		h := &hits[i]
+		if h.Before, err = s.queryChatMessages(
+			`SELECT role, content FROM (
+				SELECT id, role, content FROM chat_messages WHERE agent = $1 AND id < $2 ORDER BY id DESC LIMIT $3
+			 ) AS earlier ORDER BY id ASC`, agent, h.ID, around,
+		); err != nil {
+			return nil, err
+		}
+		if h.After, err = s.queryChatMessages(
+			`SELECT role, content FROM chat_messages WHERE agent = $1 AND id > $2 ORDER BY id ASC LIMIT $3`,
+			agent, h.ID, around,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// queryChatMessages runs a query selecting (role, content) rows. It never
+// returns a nil slice, so empty context encodes as [].
+func (s *PostgresStore) queryChatMessages(query string, args ...any) ([]ChatMessage, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs := []ChatMessage{}
+	for rows.Next() {
+		var m ChatMessage
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// wordRanges returns the ranges of the words in text that equal a word of
+// query, ignoring case, the way the 'simple' text search config matches.
+func wordRanges(text, query string) []TextRange {
+	terms := map[string]bool{}
+	for _, r := range splitWords(query) {
+		terms[strings.ToLower(query[r.Start:r.End])] = true
+	}
+	ranges := []TextRange{}
+	for _, r := range splitWords(text) {
+		if terms[strings.ToLower(text[r.Start:r.End])] {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// splitWords returns the ranges of the runs of letters and digits in s.
+func splitWords(s string) []TextRange {
+	var words []TextRange
+	start := -1
+	for i, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			words = append(words, TextRange{Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, TextRange{Start: start, End: len(s)})
+	}
+	return words
+}
+
+// UpsertUserMemory creates or replaces a memory layer for a user+agent.
+func (s *PostgresStore) UpsertUserMemory(userID, agent, layer, content string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO user_memory (user_id, agent, layer, content, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		 ON CONFLICT (user_id, agent, layer)
+		 DO UPDATE SET content = excluded.content, updated_at = CURRENT_TIMESTAMP`,
+		userID, agent, layer, content,
+	)
+	return err
+}
+
+// GetUserMemory returns all memory layers for a user+agent.
+func (s *PostgresStore) GetUserMemory(userID, agent string) ([]UserMemory, error) {
+	rows, err := s.db.Query(
+		`SELECT user_id, agent, layer, content, created_at, updated_at
+		 FROM user_memory WHERE user_id = $1 AND agent = $2 ORDER BY layer ASC`,
+		userID, agent,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memories []UserMemory
+	for rows.Next() {
+		var m UserMemory
+		if err := rows.Scan(&m.UserID, &m.Agent, &m.Layer, &m.Content, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		memories = append(memories, m)
+	}
+	return memories, rows.Err()
+}
+
+// DeleteUserMemory removes all memory for a user+agent.
+func (s *PostgresStore) DeleteUserMemory(userID, agent string) error {
+	_, err := s.db.Exec(`DELETE FROM user_memory WHERE user_id = $1 AND agent = $2`, userID, agent)
+	return err
+}
+
+// ResetAgentState deletes an agent's chat history and summaries, and
+// userID's memory, memory items and scratchpad for baseAgent, in one
+// transaction, reporting how many rows of each were deleted.
+func (s *PostgresStore) ResetAgentState(agent, userID, baseAgent string) (*AgentReset, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reset := &AgentReset{Agent: agent, UserID: userID}
+	deletes := []struct {
+		query string
+		args  []any
+		n     *int64
+	}{
+		{`DELETE FROM chat_summaries WHERE agent = $1`, []any{agent}, nil},
+		{`DELETE FROM chat_messages WHERE agent = $1`, []any{agent}, &reset.ChatMessages},
+		{`DELETE FROM user_memory WHERE user_id = $1 AND agent = $2`, []any{userID, baseAgent}, &reset.Memories},
+		{`DELETE FROM memory_items WHERE user_id = $1 AND agent = $2`, []any{userID, baseAgent}, &reset.MemoryItems},
+		{`DELETE FROM agent_kv WHERE user_id = $1 AND agent = $2`, []any{userID, baseAgent}, &reset.KVKeys},
+	}
+	for _, d := range deletes {
+		result, err := tx.Exec(d.query, d.args...)
+		if err != nil {
+			return nil, err
+		}
+		if d.n != nil {
+			*d.n, _ = result.RowsAffected()
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reset, nil
+}
+
+// UpsertScheduledJob creates or replaces a scheduled job, keeping the
+// original creation time.
+func (s *PostgresStore) UpsertScheduledJob(job ScheduledJob) error {
+	_, err := s.db.Exec(
+		`INSERT INTO scheduled_jobs (name, cron, timezone, run_at, agent_name, message, enabled)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (name) DO UPDATE SET
+		   cron = excluded.cron, timezone = excluded.timezone, run_at = excluded.run_at,
+		   agent_name = excluded.agent_name, message = excluded.message, enabled = excluded.enabled`,
+		job.Name, job.Cron, job.Timezone, job.RunAt, job.AgentName, job.Message, job.Enabled,
+	)
+	return err
+}
+
+// DeleteScheduledJob removes a scheduled job by name.
+func (s *PostgresStore) DeleteScheduledJob(name string) error {
+	_, err := s.db.Exec(`DELETE FROM scheduled_jobs WHERE name = $1`, name)
+	return err
+}
+
+// ListScheduledJobs returns all scheduled jobs.
+func (s *PostgresStore) ListScheduledJobs() ([]ScheduledJob, error) {
+	rows, err := s.db.Query(
+		`SELECT name, cron, timezone, run_at, agent_name, message, enabled, created_at
+		 FROM scheduled_jobs ORDER BY created_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []ScheduledJob
+	for rows.Next() {
+		var j ScheduledJob
+		var runAt sql.NullTime
+		if err := rows.Scan(&j.Name, &j.Cron, &j.Timezone, &runAt, &j.AgentName, &j.Message, &j.Enabled, &j.CreatedAt); err != nil {
+			return nil, err
+		}
+		if runAt.Valid {
+			j.RunAt = &runAt.Time
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// InsertScheduleRun records one firing of a scheduled job.
+func (s *PostgresStore) InsertScheduleRun(run ScheduleRun) error {
+	if run.FiredAt.IsZero() {
+		run.FiredAt = time.Now()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO schedule_runs (name, agent_name, response, error, duration_ms, fired_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		run.Name, run.Agent, run.Response, run.Error, run.DurationMs, run.FiredAt,
+	)
+	return err
+}
+
+// ListScheduleRuns returns recent runs of a scheduled job, newest first.
+func (s *PostgresStore) ListScheduleRuns(name string, limit int) ([]ScheduleRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.Query(
+		`SELECT id, name, agent_name, response, error, duration_ms, fired_at
+		 FROM schedule_runs WHERE name = $1 ORDER BY id DESC LIMIT $2`,
+		name, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var r ScheduleRun
+		if err := rows.Scan(&r.ID, &r.Name, &r.Agent, &r.Response, &r.Error, &r.DurationMs, &r.FiredAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// SetEmbedder enables semantic memory search. New memory items are
+// embedded on insert; existing ones are embedded the first time a semantic
+// search needs them.
+func (s *PostgresStore) SetEmbedder(e Embedder) {
+	s.embedder = e
+}
+
+// InsertMemoryItem saves a memory item and returns its ID.
+func (s *PostgresStore) InsertMemoryItem(item MemoryItem) (int64, error) {
+	var embedding []byte
+	if s.embedder != nil {
+		if v, err := s.embed(memoryItemText(item)); err != nil {
+			slog.Warn("memory: embed item failed, saving without embedding", "error", err)
+		} else {
+			embedding = encodeEmbedding(v)
+		}
+	}
+
+	var id int64
+	err := s.db.QueryRow(
+		`INSERT INTO memory_items (user_id, agent, topic, content, tags, embedding)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		item.UserID, item.Agent, item.Topic, item.Content, item.Tags, embedding,
+	).Scan(&id)
+	return id, err
+}
+
+// embed runs the configured embedder with a bounded timeout.
+func (s *PostgresStore) embed(text string) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.embedder.Embed(ctx, text)
+}
+
+// SearchMemoryItems searches memory items by keyword via ILIKE across
+// topic, content, and tags.
+func (s *PostgresStore) SearchMemoryItems(userID, agent, query string, limit int) ([]MemoryItem, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	pattern := "%" + query + "%"
+	rows, err := s.db.Query(
+		`SELECT id, user_id, agent, topic, content, tags, created_at, updated_at
+		 FROM memory_items
+		 WHERE user_id = $1 AND agent = $2
+		   AND (topic ILIKE $3 OR content ILIKE $3 OR tags ILIKE $3)
+		 ORDER BY updated_at DESC LIMIT $4`,
+		userID, agent, pattern, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMemoryItems(rows)
+}
+
+// SearchMemoryItemsSemantic ranks a user+agent's memory items by cosine
+// similarity between their embeddings and the query's. Items saved before
+// an embedder was configured are embedded and backfilled on the way. With
+// no embedder it falls back to SearchMemoryItems.
+func (s *PostgresStore) SearchMemoryItemsSemantic(userID, agent, query string, limit int) ([]MemoryItem, error) {
+	if s.embedder == nil || strings.TrimSpace(query) == "" {
+		return s.SearchMemoryItems(userID, agent, query, limit)
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	queryVec, err := s.embed(query)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, user_id, agent, topic, content, tags, created_at, updated_at, embedding
+		 FROM memory_items
+		 WHERE user_id = $1 AND agent = $2`,
+		userID, agent,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		item  MemoryItem
+		vec   []float32
+		score float64
+	}
+	var candidates []scored
+	for rows.Next() {
+		var c scored
+		var blob []byte
+		m := &c.item
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Agent, &m.Topic, &m.Content, &m.Tags, &m.CreatedAt, &m.UpdatedAt, &blob); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(blob) > 0 {
+			c.vec = decodeEmbedding(blob)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for idx := range candidates {
+		c := &candidates[idx]
+		if c.vec == nil {
+			v, err := s.embed(memoryItemText(c.item))
+			if err != nil {
+				slog.Warn("memory: embed item failed", "id", c.item.ID, "error", err)
+				continue
+			}
+			c.vec = v
+			s.db.Exec(`UPDATE memory_items SET embedding = $1 WHERE id = $2`, encodeEmbedding(v), c.item.ID)
+		}
+		c.score = cosineSimilarity(queryVec, c.vec)
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	items := make([]MemoryItem, len(candidates))
+	for idx, c := range candidates {
+		items[idx] = c.item
+	}
+	return items, nil
+}
+
+// DeleteMemoryItem removes a memory item by ID.
+func (s *PostgresStore) DeleteMemoryItem(id int64) error {
+	return expectRows(s.db.Exec(`DELETE FROM memory_items WHERE id = $1`, id))
+}
+
+// ListMemoryItemsByTopic returns memory items for a given user+agent+topic.
+func (s *PostgresStore) ListMemoryItemsByTopic(userID, agent, topic string) ([]MemoryItem, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, agent, topic, content, tags, created_at, updated_at
+		 FROM memory_items
+		 WHERE user_id = $1 AND agent = $2 AND topic = $3
+		 ORDER BY created_at ASC, id ASC`,
+		userID, agent, topic,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanMemoryItems(rows)
+}
+
+// scanMemoryItems reads memory item rows selected without their embedding.
+func scanMemoryItems(rows *sql.Rows) ([]MemoryItem, error) {
+	var items []MemoryItem
+	for rows.Next() {
+		var m MemoryItem
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Agent, &m.Topic, &m.Content, &m.Tags, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, m)
+	}
+	return items, rows.Err()
+}
+
+// UpsertKV sets a scratchpad value for a user+agent, replacing any
+// previous value for the key.
+func (s *PostgresStore) UpsertKV(userID, agent, key, value string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO agent_kv (user_id, agent, key, value, updated_at)
+		 VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		 ON CONFLICT (user_id, agent, key)
+		 DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+		userID, agent, key, value,
+	)
+	return err
+}
+
+// GetKV returns a scratchpad value for a user+agent.
+func (s *PostgresStore) GetKV(userID, agent, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow(
+		`SELECT value FROM agent_kv WHERE user_id = $1 AND agent = $2 AND key = $3`,
+		userID, agent, key,
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// DeleteKV removes a scratchpad value for a user+agent.
+func (s *PostgresStore) DeleteKV(userID, agent, key string) error {
+	return expectRows(s.db.Exec(
+		`DELETE FROM agent_kv WHERE user_id = $1 AND agent = $2 AND key = $3`,
+		userID, agent, key,
+	))
+}
+
+// InsertWorkspaceFile records a file write by an agent.
+func (s *PostgresStore) InsertWorkspaceFile(f WorkspaceFile) error {
+	_, err := s.db.Exec(
+		`INSERT INTO workspace_files (path, agent, process_id, operation, description)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		f.Path, f.Agent, f.ProcessID, f.Operation, f.Description,
+	)
+	return err
+}
+
+// ListWorkspaceFiles returns workspace file records, optionally filtered by agent.
+func (s *PostgresStore) ListWorkspaceFiles(agent string) ([]WorkspaceFile, error) {
+	rows, err := s.db.Query(
+		`SELECT id, path, agent, process_id, operation, description, created_at
+		 FROM workspace_files WHERE $1 = '' OR agent = $1 ORDER BY created_at DESC, id DESC`, agent,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []WorkspaceFile
+	for rows.Next() {
+		var f WorkspaceFile
+		if err := rows.Scan(&f.ID, &f.Path, &f.Agent, &f.ProcessID, &f.Operation, &f.Description, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// ListWorkspaceFileAgents returns distinct agent names that have written files.
+func (s *PostgresStore) ListWorkspaceFileAgents() ([]string, error) {
+	rows, err := s.db.Query(
+		`SELECT DISTINCT agent FROM workspace_files WHERE agent != '' ORDER BY agent ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+// InsertToolCall records one tool execution.
+func (s *PostgresStore) InsertToolCall(c ToolCall) error {
+	_, err := s.db.Exec(
+		`INSERT INTO tool_calls (tool, agent, process_id, args, result, error, duration_ms)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.Tool, c.Agent, c.ProcessID, c.Args, c.Result, c.Error, c.DurationMs,
+	)
+	return err
+}
+
+// ListToolCalls returns recent tool calls, newest first, optionally
+// filtered by agent.
+func (s *PostgresStore) ListToolCalls(agent string, limit int) ([]ToolCall, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.Query(
+		`SELECT id, tool, agent, process_id, args, result, error, duration_ms, created_at
+		 FROM tool_calls WHERE $1 = '' OR agent = $1 ORDER BY id DESC LIMIT $2`, agent, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []ToolCall
+	for rows.Next() {
+		var c ToolCall
+		if err := rows.Scan(&c.ID, &c.Tool, &c.Agent, &c.ProcessID, &c.Args, &c.Result, &c.Error, &c.DurationMs, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// InsertCostEntry records the cost of an LLM call in the cost ledger.
+func (s *PostgresStore) InsertCostEntry(e CostEntry) error {
+	_, err := s.db.Exec(
+		`INSERT INTO cost_ledger (user_id, agent, model, input_tokens, output_tokens, cost_usd)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		e.UserID, e.Agent, e.Model, e.InputTokens, e.OutputTokens, e.CostUSD,
+	)
+	return err
+}
+
+// Usage totals the cost ledger since the given time, optionally filtered by
+// user and agent. A zero since totals the whole ledger.
+func (s *PostgresStore) Usage(userID, agent string, since time.Time) (*Usage, error) {
+	var sinceArg any
+	if !since.IsZero() {
+		sinceArg = since
+	}
+	u := &Usage{UserID: userID, Agent: agent, Since: since}
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(input_tokens), 0)::BIGINT, COALESCE(SUM(output_tokens), 0)::BIGINT, COALESCE(SUM(cost_usd), 0)
+		 FROM cost_ledger
+		 WHERE ($1 = '' OR user_id = $1) AND ($2 = '' OR agent = $2)
+		   AND ($3::TIMESTAMPTZ IS NULL OR created_at >= $3)`,
+		userID, agent, sinceArg,
+	).Scan(&u.Calls, &u.InputTokens, &u.OutputTokens, &u.CostUSD)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CountTable returns the number of rows in the given table.
+func (s *PostgresStore) CountTable(table string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count)
+	return count, err
+}
+
+// ResetData clears all transient data but preserves settings.
+func (s *PostgresStore) ResetData() error {
+	tables := []string{
+		"composed_agents",
+		"chat_messages",
+		"chat_summaries",
+		"user_memory",
+		"memory_items",
+		"agent_kv",
+		"events",
+		"process_snapshots",
+		"workflow_runs",
+		"workflow_steps",
+		"idempotency_keys",
+		"scheduled_jobs",
+		"schedule_runs",
+		"channel_messages",
+		"channels",
+		"inbox_replies",
+		"agent_inbox",
+		"workspace_files",
+		"tool_calls",
+		"channel_read_cursors",
+		"chat_read_cursors",
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range tables {
+		if _, err := tx.Exec("DELETE FROM " + t); err != nil {
+			return fmt.Errorf("clear %s: %w", t, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// UpsertSetting creates or updates a setting.
+func (s *PostgresStore) UpsertSetting(st Setting) error {
+	_, err := s.db.Exec(
+		`INSERT INTO settings (key, value, sensitive, created_at, updated_at)
+		 VALUES ($1, $2, $3, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		 ON CONFLICT (key)
+		 DO UPDATE SET value = excluded.value, sensitive = excluded.sensitive, updated_at = CURRENT_TIMESTAMP`,
+		st.Key, st.Value, st.Sensitive,
+	)
+	return err
+}
+
+// GetSetting returns a setting by key.
+func (s *PostgresStore) GetSetting(key string) (*Setting, error) {
+	var st Setting
+	err := s.db.QueryRow(
+		`SELECT key, value, sensitive, created_at, updated_at FROM settings WHERE key = $1`, key,
+	).Scan(&st.Key, &st.Value, &st.Sensitive, &st.CreatedAt, &st.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// ListSettings returns all settings.
+func (s *PostgresStore) ListSettings() ([]Setting, error) {
+	rows, err := s.db.Query(
+		`SELECT key, value, sensitive, created_at, updated_at FROM settings ORDER BY key ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var settings []Setting
+	for rows.Next() {
+		var st Setting
+		if err := rows.Scan(&st.Key, &st.Value, &st.Sensitive, &st.CreatedAt, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		settings = append(settings, st)
+	}
+	return settings, rows.Err()
+}
+
+// DeleteSetting removes a setting by key.
+func (s *PostgresStore) DeleteSetting(key string) error {
+	return expectRows(s.db.Exec(`DELETE FROM settings WHERE key = $1`, key))
+}
+
+// UpsertMCPServer persists an MCP server connection config.
+func (s *PostgresStore) UpsertMCPServer(name, configJSON string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO mcp_servers (name, config, created_at)
+		 VALUES ($1, $2, CURRENT_TIMESTAMP)
+		 ON CONFLICT (name)
+		 DO UPDATE SET config = excluded.config`,
+		name, configJSON,
+	)
+	return err
+}
+
+// DeleteMCPServer removes a persisted MCP server connection.
+func (s *PostgresStore) DeleteMCPServer(name string) error {
+	_, err := s.db.Exec(`DELETE FROM mcp_servers WHERE name = $1`, name)
+	return err
+}
+
+// ListMCPServers returns all persisted MCP server configs.
+func (s *PostgresStore) ListMCPServers() ([]MCPServerConfig, error) {
+	rows, err := s.db.Query(
+		`SELECT name, config, disabled FROM mcp_servers ORDER BY created_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var servers []MCPServerConfig
+	for rows.Next() {
+		var sc MCPServerConfig
+		if err := rows.Scan(&sc.Name, &sc.ConfigJSON, &sc.Disabled); err != nil {
+			return nil, err
+		}
+		servers = append(servers, sc)
+	}
+	return servers, rows.Err()
+}
+
+// SetMCPServerDisabled enables or disables a persisted MCP server.
+func (s *PostgresStore) SetMCPServerDisabled(name string, disabled bool) error {
+	res, err := s.db.Exec(`UPDATE mcp_servers SET disabled = $1 WHERE name = $2`, disabled, name)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("server %q not found", name)
+	}
+	return nil
+}
+
+// RetentionPolicy reads the policy from settings.
+func (s *PostgresStore) RetentionPolicy() RetentionPolicy {
+	return retentionPolicyFromSettings(s)
+}
+
+// Prune deletes events, process snapshots, tool calls, workflow runs and
+// workflow steps that fall outside the policy and returns how many rows
+// were removed. Workflow runs that are still running, and their steps, are
+// never deleted.
+func (s *PostgresStore) Prune(p RetentionPolicy) (int64, error) {
+	var total int64
+	for _, t := range retentionTables {
+		keep := ""
+		if t.keep != "" {
+			keep = " AND NOT (" + t.keep + ")"
+		}
+
+		if p.MaxAge > 0 {
+			res, err := s.db.Exec(fmt.Sprintf(
+				`DELETE FROM %s WHERE %s < $1%s`, t.name, t.column, keep), time.Now().Add(-p.MaxAge))
+			if err != nil {
+				return total, fmt.Errorf("prune %s by age: %w", t.name, err)
+			}
+			n, _ := res.RowsAffected()
+			total += n
+		}
+
+		if p.MaxRows > 0 {
+			res, err := s.db.Exec(fmt.Sprintf(
+				`DELETE FROM %[1]s WHERE id <= (SELECT id FROM %[1]s ORDER BY id DESC LIMIT 1 OFFSET $1)%[2]s`,
+				t.name, keep), p.MaxRows)
+			if err != nil {
+				return total, fmt.Errorf("prune %s by count: %w", t.name, err)
+			}
+			n, _ := res.RowsAffected()
+			total += n
+		}
+	}
+	return total, nil
+}
+
+// Vacuum reclaims space in the pruned tables for reuse. Postgres also
+// autovacuums, so this only makes the space available sooner.
+func (s *PostgresStore) Vacuum() {
+	for _, t := range retentionTables {
+		s.db.Exec("VACUUM " + t.name)
+	}
+}
+
+// --- Channel Methods ---
+
+// CreateChannel creates a new channel.
+func (s *PostgresStore) CreateChannel(id, name, description, createdBy string, team []string, mode string) error {
+	teamJSON, _ := json.Marshal(team)
+	_, err := s.db.Exec(
+		`INSERT INTO channels (id, name, description, team, mode, created_by) VALUES ($1, $2, $3, $4, $5, $6)`,
+		id, name, description, string(teamJSON), mode, createdBy,
+	)
+	return err
+}
+
+// GetChannel returns a channel by name.
+func (s *PostgresStore) GetChannel(name string) (*Channel, error) {
+	var ch Channel
+	var teamJSON string
+	err := s.db.QueryRow(
+		`SELECT id, name, description, team, mode, created_by, created_at FROM channels WHERE name = $1`, name,
+	).Scan(&ch.ID, &ch.Name, &ch.Description, &teamJSON, &ch.Mode, &ch.CreatedBy, &ch.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal([]byte(teamJSON), &ch.Team)
+	return &ch, nil
+}
+
+// GetChannelByName returns minimal channel info for the dsl.ChannelBackend interface.
+func (s *PostgresStore) GetChannelByName(name string) (*dsl.ChannelInfo, error) {
+	ch, err := s.GetChannel(name)
+	if err != nil || ch == nil {
+		return nil, err
+	}
+	return &dsl.ChannelInfo{ID: ch.ID, Name: ch.Name, Team: ch.Team}, nil
+}
+
+// ListAllChannels returns all channels as ChannelInfo (for the dsl.ChannelBackend interface).
+func (s *PostgresStore) ListAllChannels() ([]dsl.ChannelInfo, error) {
+	channels, err := s.ListChannels("default")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]dsl.ChannelInfo, len(channels))
+	for i, ch := range channels {
+		result[i] = dsl.ChannelInfo{ID: ch.ID, Name: ch.Name, Team: ch.Team}
+	}
+	return result, nil
+}
+
+// ListChannelsForAgent returns channels where the agent is a team member.
+func (s *PostgresStore) ListChannelsForAgent(agent string) ([]dsl.ChannelInfo, error) {
+	channels, err := s.ListChannels("default")
+	if err != nil {
+		return nil, err
+	}
+	var result []dsl.ChannelInfo
+	for _, ch := range channels {
+		for _, member := range ch.Team {
+			if member == agent {
+				result = append(result, dsl.ChannelInfo{ID: ch.ID, Name: ch.Name, Team: ch.Team})
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// ListChannels returns all channels with unread counts for the given user.
+func (s *PostgresStore) ListChannels(userID string) ([]Channel, error) {
+	if userID == "" {
+		userID = "default"
+	}
+	rows, err := s.db.Query(`
+		SELECT c.id, c.name, c.description, c.team, c.mode, c.created_by, c.created_at,
+		       (SELECT COUNT(*) FROM channel_messages WHERE channel_id = c.id AND thread_id IS NULL),
+		       (SELECT COUNT(*) FROM channel_messages WHERE channel_id = c.id AND thread_id IS NULL
+		        AND id > COALESCE((SELECT last_read_id FROM channel_read_cursors WHERE channel_id = c.id AND user_id = $1), 0))
+		FROM channels c
+		ORDER BY c.created_at ASC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var channels []Channel
+	for rows.Next() {
+		var ch Channel
+		var teamJSON string
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.Description, &teamJSON, &ch.Mode, &ch.CreatedBy, &ch.CreatedAt, &ch.MessageCount, &ch.UnreadCount); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(teamJSON), &ch.Team)
+		channels = append(channels, ch)
+	}
+	return channels, rows.Err()
+}
+
+// DeleteChannel removes a channel by name; its messages go with it through
+// the foreign key cascade.
+func (s *PostgresStore) DeleteChannel(name string) error {
+	return expectRows(s.db.Exec(`DELETE FROM channels WHERE name = $1`, name))
+}
+
+// UpdateChannelTeam updates the team members of a channel.
+func (s *PostgresStore) UpdateChannelTeam(name string, team []string) error {
+	teamJSON, _ := json.Marshal(team)
+	return expectRows(s.db.Exec(`UPDATE channels SET team = $1 WHERE name = $2`, string(teamJSON), name))
+}
+
+// FindChannelForAgents returns the first channel where both agents are team members.
+func (s *PostgresStore) FindChannelForAgents(agent1, agent2 string) (string, string, error) {
+	rows, err := s.db.Query(`SELECT id, name, team FROM channels ORDER BY created_at ASC`)
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, name, teamJSON string
+		if err := rows.Scan(&id, &name, &teamJSON); err != nil {
+			return "", "", err
+		}
+		var team []string
+		json.Unmarshal([]byte(teamJSON), &team)
+		has1, has2 := false, false
+		for _, m := range team {
+			if m == agent1 {
+				has1 = true
+			}
+			if m == agent2 {
+				has2 = true
+			}
+		}
+		if has1 && has2 {
+			return id, name, nil
+		}
+	}
+	return "", "", rows.Err()
+}
+
+// InsertChannelMessage inserts a message into a channel and returns its ID.
+func (s *PostgresStore) InsertChannelMessage(channelID, agent, role, content string, threadID *int64, metadata, sender string) (int64, error) {
+	if metadata == "" {
+		metadata = "{}"
+	}
+	var id int64
+	err := s.db.QueryRow(
+		`INSERT INTO channel_messages (channel_id, thread_id, agent, role, content, metadata, sender)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		channelID, threadID, agent, role, content, metadata, sender,
+	).Scan(&id)
+	return id, err
+}
+
+// ListChannelMessages returns top-level messages for a channel with reply counts.
+func (s *PostgresStore) ListChannelMessages(channelID string, limit int) ([]ChannelMessage, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.Query(
+		`SELECT m.id, m.channel_id, m.thread_id, m.agent, m.sender, m.role, m.content, m.metadata, m.created_at,
+		        (SELECT COUNT(*) FROM channel_messages r WHERE r.thread_id = m.id) AS reply_count
+		 FROM channel_messages m
+		 WHERE m.channel_id = $1 AND m.thread_id IS NULL
+		 ORDER BY m.created_at ASC, m.id ASC LIMIT $2`,
+		channelID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []ChannelMessage
+	for rows.Next() {
+		var m ChannelMessage
+		var threadID sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.ChannelID, &threadID, &m.Agent, &m.Sender, &m.Role, &m.Content, &m.Metadata, &m.CreatedAt, &m.ReplyCount); err != nil {
+			return nil, err
+		}
+		if threadID.Valid {
+			m.ThreadID = &threadID.Int64
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// RecentChannelMessages returns the last N messages in a channel (lightweight, for status checks).
+func (s *PostgresStore) RecentChannelMessages(channelID string, limit int) ([]dsl.ChannelMessage, error) {
+	if limit <= 0 {
+		limit = 5
+	}
+	rows, err := s.db.Query(
+		`SELECT agent, sender, content FROM channel_messages
+		 WHERE channel_id = $1 AND thread_id IS NULL
+		 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		channelID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []dsl.ChannelMessage
+	for rows.Next() {
+		var m dsl.ChannelMessage
+		if err := rows.Scan(&m.Agent, &m.Sender, &m.Content); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	// Reverse to chronological order.
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, rows.Err()
+}
+
+// ListThreadMessages returns the original message and all replies in a thread.
+func (s *PostgresStore) ListThreadMessages(channelID string, threadID int64) ([]ChannelMessage, error) {
+	rows, err := s.db.Query(
+		`SELECT id, channel_id, thread_id, agent, sender, role, content, metadata, created_at
+		 FROM channel_messages
+		 WHERE channel_id = $1 AND (id = $2 OR thread_id = $2)
+		 ORDER BY created_at ASC, id ASC`,
+		channelID, threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []ChannelMessage
+	for rows.Next() {
+		var m ChannelMessage
+		var tid sql.NullInt64
+		if err := rows.Scan(&m.ID, &m.ChannelID, &tid, &m.Agent, &m.Sender, &m.Role, &m.Content, &m.Metadata, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if tid.Valid {
+			m.ThreadID = &tid.Int64
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// --- Inbox Methods ---
+
+// InsertInboxItem creates a new inbox item and returns its ID.
+func (s *PostgresStore) InsertInboxItem(fromAgent, subject, body, priority string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(
+		`INSERT INTO agent_inbox (from_agent, subject, body, priority) VALUES ($1, $2, $3, $4) RETURNING id`,
+		fromAgent, subject, body, priority,
+	).Scan(&id)
+	return id, err
+}
+
+// PendingInboxCount returns the number of pending inbox items (cheap query, no LLM needed).
+func (s *PostgresStore) PendingInboxCount() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM agent_inbox WHERE status = 'pending'`).Scan(&count)
+	return count, err
+}
+
+// ListInboxItems returns inbox items filtered by status; "all" or an empty
+// status returns every item.
+func (s *PostgresStore) ListInboxItems(status string, limit int) ([]InboxItem, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	if status == "all" {
+		status = ""
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, from_agent, subject, body, priority, status, resolution, created_at, resolved_at
+		 FROM agent_inbox WHERE $1 = '' OR status = $1 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []InboxItem
+	for rows.Next() {
+		item, err := scanInboxItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// GetInboxItem returns a single inbox item by ID.
+func (s *PostgresStore) GetInboxItem(id int64) (*InboxItem, error) {
+	return scanInboxItem(s.db.QueryRow(
+		`SELECT id, from_agent, subject, body, priority, status, resolution, created_at, resolved_at
+		 FROM agent_inbox WHERE id = $1`, id))
+}
+
+// scanInboxItem reads one agent_inbox row from a *sql.Row or *sql.Rows.
+func scanInboxItem(row interface{ Scan(...any) error }) (*InboxItem, error) {
+	var item InboxItem
+	var resolution sql.NullString
+	var resolvedAt sql.NullTime
+	if err := row.Scan(&item.ID, &item.FromAgent, &item.Subject, &item.Body,
+		&item.Priority, &item.Status, &resolution, &item.CreatedAt, &resolvedAt); err != nil {
+		return nil, err
+	}
+	item.Resolution = resolution.String
+	if resolvedAt.Valid {
+		item.ResolvedAt = &resolvedAt.Time
+	}
+	return &item, nil
+}
+
+// ResolveInboxItem marks an inbox item as resolved.
+func (s *PostgresStore) ResolveInboxItem(id int64, resolution string) error {
+	return expectRows(s.db.Exec(
+		`UPDATE agent_inbox SET status = 'resolved', resolution = $1, resolved_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		resolution, id,
+	))
+}
+
+// DeleteResolvedInboxItems removes all resolved inbox items; their replies
+// go with them through the foreign key cascade.
+func (s *PostgresStore) DeleteResolvedInboxItems() (int64, error) {
+	result, err := s.db.Exec(`DELETE FROM agent_inbox WHERE status = 'resolved'`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// --- Prompt History Methods ---
+
+// InsertPromptHistory records an original user prompt to iris.
+func (s *PostgresStore) InsertPromptHistory(prompt string) (int64, error) {
+	var id int64
+	err := s.db.QueryRow(`INSERT INTO prompt_history (prompt) VALUES ($1) RETURNING id`, prompt).Scan(&id)
+	return id, err
+}
+
+// ListPromptHistory returns prompt history entries, newest first.
+func (s *PostgresStore) ListPromptHistory(limit int) ([]PromptHistoryItem, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	rows, err := s.db.Query(
+		`SELECT id, prompt, created_at FROM prompt_history ORDER BY id DESC LIMIT $1`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPromptHistory(rows)
+}
+
+// SearchPromptHistory searches prompt history by keyword via ILIKE.
+func (s *PostgresStore) SearchPromptHistory(query string, limit int) ([]PromptHistoryItem, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(
+		`SELECT id, prompt, created_at FROM prompt_history
+		 WHERE prompt ILIKE $1
+		 ORDER BY id DESC LIMIT $2`,
+		"%"+query+"%", limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPromptHistory(rows)
+}
+
+func scanPromptHistory(rows *sql.Rows) ([]PromptHistoryItem, error) {
+	var items []PromptHistoryItem
+	for rows.Next() {
+		var item PromptHistoryItem
+		if err := rows.Scan(&item.ID, &item.Prompt, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// DeletePromptHistory removes a prompt history entry by ID.
+func (s *PostgresStore) DeletePromptHistory(id int64) error {
+	return expectRows(s.db.Exec(`DELETE FROM prompt_history WHERE id = $1`, id))
+}
+
+// MarkChannelRead updates the read cursor for a channel so unread count resets.
+func (s *PostgresStore) MarkChannelRead(channelID, userID string) error {
+	if userID == "" {
+		userID = "default"
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO channel_read_cursors (channel_id, user_id, last_read_id, updated_at)
+		VALUES ($1, $2, COALESCE((SELECT MAX(id) FROM channel_messages WHERE channel_id = $1 AND thread_id IS NULL), 0), CURRENT_TIMESTAMP)
+		ON CONFLICT (channel_id, user_id) DO UPDATE SET
+			last_read_id = excluded.last_read_id,
+			updated_at = CURRENT_TIMESTAMP`,
+		channelID, userID,
+	)
+	return err
+}
+
+// MarkChatRead updates the read cursor for a DM conversation so unread count resets.
+func (s *PostgresStore) MarkChatRead(agent, userID string) error {
+	if userID == "" {
+		userID = "default"
+	}
+	_, err := s.db.Exec(`
+		INSERT INTO chat_read_cursors (agent, user_id, last_read_id, updated_at)
+		VALUES ($1, $2, COALESCE((SELECT MAX(id) FROM chat_messages WHERE agent = $1), 0), CURRENT_TIMESTAMP)
+		ON CONFLICT (agent, user_id) DO UPDATE SET
+			last_read_id = excluded.last_read_id,
+			updated_at = CURRENT_TIMESTAMP`,
+		agent, userID,
+	)
+	return err
+}
+
+// ChatUnreadCounts returns a map of agent name → unread message count for DMs.
+func (s *PostgresStore) ChatUnreadCounts(userID string) (map[string]int, error) {
+	if userID == "" {
+		userID = "default"
+	}
+	rows, err := s.db.Query(`
+		SELECT cm.agent, COUNT(*) AS unread
+		FROM chat_messages cm
+		LEFT JOIN chat_read_cursors crc ON cm.agent = crc.agent AND crc.user_id = $1
+		WHERE cm.role = 'assistant'
+		  AND cm.id > COALESCE(crc.last_read_id, 0)
+		GROUP BY cm.agent`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var agent string
+		var count int
+		if err := rows.Scan(&agent, &count); err != nil {
+			return nil, err
+		}
+		counts[agent] = count
+	}
+	return counts, rows.Err()
+}
+
+// expectRows turns the result of a statement that must touch a row into
+// sql.ErrNoRows when it touched none.
+func expectRows(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
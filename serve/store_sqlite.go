@@ -1,9 +1,17 @@
 package serve
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/everydev1618/govega/dsl"
@@ -12,29 +20,93 @@ import (
 
 // SQLiteStore implements Store using modernc.org/sqlite (pure Go).
 type SQLiteStore struct {
-	db *sql.DB
+	db       *sql.DB
+	embedder Embedder // optional; enables semantic memory search
+
+	// healthMu guards the cached health state reported by Health.
+	healthMu sync.RWMutex
+	readErr  error
+	writeErr error
 }
 
 // NewSQLiteStore opens or creates a SQLite database at the given path.
 func NewSQLiteStore(path string) (*SQLiteStore, error) {
-	db, err := sql.Open("sqlite", path)
+	db, err := sql.Open("sqlite", sqliteDSN(path))
 	if err != nil {
 		return nil, err
 	}
-	// Enable WAL mode for concurrent reads and set busy timeout
-	// so concurrent writers wait instead of returning SQLITE_BUSY.
-	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
-		db.Close()
-		return nil, err
-	}
-	if _, err := db.Exec("PRAGMA busy_timeout=30000"); err != nil {
+	if err := db.Ping(); err != nil {
 		db.Close()
 		return nil, err
 	}
 	return &SQLiteStore{db: db}, nil
 }
 
-// Init creates the schema tables.
+// sqliteDSN adds the connection settings every pooled connection needs.
+// PRAGMAs run through db.Exec reach only one connection, so they go in the
+// DSN instead. WAL allows reads alongside a writer; busy_timeout makes
+// writers wait for the lock rather than fail with SQLITE_BUSY; and
+// immediate transactions take the write lock up front, since a deferred
+// transaction that upgrades from read to write gets SQLITE_BUSY without
+// waiting.
+func sqliteDSN(path string) string {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=journal_mode(WAL)&_pragma=busy_timeout(30000)&_txlock=immediate"
+}
+
+// writeRetries is how many times a write is retried when SQLite still
+// reports lock contention after busy_timeout.
+const writeRetries = 3
+
+// exec runs a write statement, retrying transient busy errors with a short
+// linear backoff.
+func (s *SQLiteStore) exec(query string, args ...any) (sql.Result, error) {
+	for attempt := 1; ; attempt++ {
+		res, err := s.db.Exec(query, args...)
+		if !isBusy(err) || attempt > writeRetries {
+			if isUnwritable(err) {
+				s.markUnwritable(err)
+			}
+			return res, err
+		}
+		time.Sleep(time.Duration(attempt) * 50 * time.Millisecond)
+	}
+}
+
+// isBusy reports whether err is lock contention that may clear on retry.
+func isBusy(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "SQLITE_BUSY") || strings.Contains(msg, "SQLITE_LOCKED")
+}
+
+// isUnwritable reports whether err means the database rejects writes
+// outright, rather than one statement failing.
+func isUnwritable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, s := range []string{
+		"readonly database",
+		"disk I/O error",
+		"database or disk is full",
+		"unable to open database file",
+		"database is closed",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Init creates the schema tables and applies pending migrations.
 func (s *SQLiteStore) Init() error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS events (
@@ -116,6 +188,17 @@ func (s *SQLiteStore) Init() error {
 		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 	);
 
+	CREATE TABLE IF NOT EXISTS schedule_runs (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		name        TEXT NOT NULL,
+		agent_name  TEXT NOT NULL,
+		response    TEXT NOT NULL DEFAULT '',
+		error       TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER NOT NULL DEFAULT 0,
+		fired_at    DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_schedule_runs_name ON schedule_runs(name, id);
+
 	CREATE TABLE IF NOT EXISTS memory_items (
 		id         INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id    TEXT NOT NULL,
@@ -232,40 +315,81 @@ func (s *SQLiteStore) Init() error {
 	CREATE INDEX IF NOT EXISTS idx_workflow_runs_id ON workflow_runs(run_id);
 	CREATE INDEX IF NOT EXISTS idx_chat_agent ON chat_messages(agent);
 	`
-	if _, err := s.db.Exec(schema); err != nil {
+	if _, err := s.exec(schema); err != nil {
 		return err
 	}
 
-	// Migrate: add tools column to composed_agents if missing (added after initial release).
-	s.db.Exec(`ALTER TABLE composed_agents ADD COLUMN tools TEXT NOT NULL DEFAULT '[]'`)
+	return s.migrate(migrations)
+}
 
-	// Migrate: add disabled column to mcp_servers if missing.
-	s.db.Exec(`ALTER TABLE mcp_servers ADD COLUMN disabled INTEGER NOT NULL DEFAULT 0`)
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	closed := errors.New("store closed")
+	s.setHealth(closed, closed)
+	return s.db.Close()
+}
 
-	// Migrate: add display_name and title columns to composed_agents if missing.
-	s.db.Exec(`ALTER TABLE composed_agents ADD COLUMN display_name TEXT NOT NULL DEFAULT ''`)
-	s.db.Exec(`ALTER TABLE composed_agents ADD COLUMN title TEXT NOT NULL DEFAULT ''`)
+// Ping reports whether the database can be reached.
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
 
-	// Migrate: add avatar column to composed_agents if missing.
-	s.db.Exec(`ALTER TABLE composed_agents ADD COLUMN avatar TEXT NOT NULL DEFAULT ''`)
+// Health returns the store's health as of the last CheckHealth or failed
+// write, without touching the database. readErr is set when the database
+// can't be reached and writeErr when it rejects writes.
+func (s *SQLiteStore) Health() (readErr, writeErr error) {
+	s.healthMu.RLock()
+	defer s.healthMu.RUnlock()
+	return s.readErr, s.writeErr
+}
 
-	// Migrate: add mode column to channels if missing.
-	s.db.Exec(`ALTER TABLE channels ADD COLUMN mode TEXT NOT NULL DEFAULT ''`)
+// CheckHealth probes the database and updates the state Health reports.
+func (s *SQLiteStore) CheckHealth(ctx context.Context) {
+	readErr := s.Ping(ctx)
+	writeErr := readErr
+	if readErr == nil {
+		writeErr = s.CheckWritable(ctx)
+	}
+	s.setHealth(readErr, writeErr)
+}
 
-	// Migrate: add sender column to channel_messages for multi-user identity.
-	s.db.Exec(`ALTER TABLE channel_messages ADD COLUMN sender TEXT DEFAULT ''`)
+func (s *SQLiteStore) setHealth(readErr, writeErr error) {
+	s.healthMu.Lock()
+	s.readErr, s.writeErr = readErr, writeErr
+	s.healthMu.Unlock()
+}
 
-	return nil
+// markUnwritable records a write the database rejected, so requests that
+// need to write fail fast until the next CheckHealth.
+func (s *SQLiteStore) markUnwritable(err error) {
+	s.healthMu.Lock()
+	s.writeErr = err
+	s.healthMu.Unlock()
 }
 
-// Close closes the database.
-func (s *SQLiteStore) Close() error {
-	return s.db.Close()
+// CheckWritable reports whether the database accepts writes by running a
+// write that matches no rows and rolling it back. A lock held by another
+// writer doesn't count as a failure.
+func (s *SQLiteStore) CheckWritable(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		if isBusy(err) {
+			return nil
+		}
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+	_, err = conn.ExecContext(ctx, "DELETE FROM events WHERE 0")
+	return err
 }
 
 // InsertEvent records an orchestration event.
 func (s *SQLiteStore) InsertEvent(e StoreEvent) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO events (type, process_id, agent_name, timestamp, data, result, error)
 		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		e.Type, e.ProcessID, e.AgentName, e.Timestamp, e.Data, e.Result, e.Error,
@@ -275,7 +399,7 @@ func (s *SQLiteStore) InsertEvent(e StoreEvent) error {
 
 // InsertProcessSnapshot records a process state snapshot.
 func (s *SQLiteStore) InsertProcessSnapshot(snap ProcessSnapshot) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO process_snapshots
 		 (process_id, agent_name, status, parent_id, input_tokens, output_tokens, cost_usd, started_at, completed_at, snapshot_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
@@ -288,7 +412,7 @@ func (s *SQLiteStore) InsertProcessSnapshot(snap ProcessSnapshot) error {
 
 // InsertWorkflowRun records a workflow execution.
 func (s *SQLiteStore) InsertWorkflowRun(r WorkflowRun) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO workflow_runs (run_id, workflow, inputs, status, started_at)
 		 VALUES (?, ?, ?, ?, ?)`,
 		r.RunID, r.Workflow, r.Inputs, r.Status, r.StartedAt,
@@ -298,13 +422,78 @@ func (s *SQLiteStore) InsertWorkflowRun(r WorkflowRun) error {
 
 // UpdateWorkflowRun updates a workflow run status and result.
 func (s *SQLiteStore) UpdateWorkflowRun(runID string, status string, result string) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`UPDATE workflow_runs SET status = ?, result = ? WHERE run_id = ?`,
 		status, result, runID,
 	)
 	return err
 }
 
+// ClaimIdempotencyKey maps key to runID for workflow for ttl, unless the
+// key already maps to an unexpired run, and returns the run the key maps
+// to. Expired keys are deleted on the way.
+func (s *SQLiteStore) ClaimIdempotencyKey(workflow, key, runID string, ttl time.Duration) (*WorkflowRun, error) {
+	now := time.Now()
+	if _, err := s.exec(`DELETE FROM idempotency_keys WHERE expires_at <= ?`, now.Unix()); err != nil {
+		return nil, err
+	}
+	if _, err := s.exec(
+		`INSERT INTO idempotency_keys (workflow, key, run_id, expires_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (workflow, key) DO NOTHING`,
+		workflow, key, runID, now.Add(ttl).Unix(),
+	); err != nil {
+		return nil, err
+	}
+
+	run := &WorkflowRun{Workflow: workflow}
+	err := s.db.QueryRow(
+		`SELECT k.run_id, COALESCE(r.status, 'running'), COALESCE(r.result, '')
+		 FROM idempotency_keys k LEFT JOIN workflow_runs r ON r.run_id = k.run_id
+		 WHERE k.workflow = ? AND k.key = ?`, workflow, key,
+	).Scan(&run.RunID, &run.Status, &run.Result)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// UpsertWorkflowStep records the latest state of a top-level workflow
+// step, replacing any earlier state for the same run and index.
+func (s *SQLiteStore) UpsertWorkflowStep(st WorkflowStep) error {
+	_, err := s.exec(
+		`INSERT INTO workflow_steps (run_id, step_index, type, agent, save, status, result, error, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(run_id, step_index) DO UPDATE SET
+		   type = excluded.type, agent = excluded.agent, save = excluded.save,
+		   status = excluded.status, result = excluded.result, error = excluded.error,
+		   updated_at = CURRENT_TIMESTAMP`,
+		st.RunID, st.Index, st.Type, st.Agent, st.Save, st.Status, st.Result, st.Error,
+	)
+	return err
+}
+
+// ListWorkflowSteps returns the recorded steps of a run in step order.
+func (s *SQLiteStore) ListWorkflowSteps(runID string) ([]WorkflowStep, error) {
+	rows, err := s.db.Query(
+		`SELECT run_id, step_index, type, agent, save, status, result, error, updated_at
+		 FROM workflow_steps WHERE run_id = ? ORDER BY step_index`, runID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []WorkflowStep
+	for rows.Next() {
+		var st WorkflowStep
+		if err := rows.Scan(&st.RunID, &st.Index, &st.Type, &st.Agent, &st.Save, &st.Status, &st.Result, &st.Error, &st.UpdatedAt); err != nil {
+			return nil, err
+		}
+		steps = append(steps, st)
+	}
+	return steps, rows.Err()
+}
+
 // ListEvents returns recent events, newest first.
 func (s *SQLiteStore) ListEvents(limit int) ([]StoreEvent, error) {
 	rows, err := s.db.Query(
@@ -390,7 +579,7 @@ func (s *SQLiteStore) InsertComposedAgent(a ComposedAgent) error {
 	skillsJSON, _ := json.Marshal(a.Skills)
 	toolsJSON, _ := json.Marshal(a.Tools)
 	teamJSON, _ := json.Marshal(a.Team)
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT OR REPLACE INTO composed_agents (name, display_name, title, avatar, model, persona, skills, tools, team, system, temperature, created_at)
 		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		a.Name, a.DisplayName, a.Title, a.Avatar, a.Model, a.Persona, string(skillsJSON), string(toolsJSON), string(teamJSON), a.System, a.Temperature, a.CreatedAt,
@@ -430,7 +619,7 @@ func (s *SQLiteStore) ListComposedAgents() ([]ComposedAgent, error) {
 
 // DeleteComposedAgent removes a composed agent by name.
 func (s *SQLiteStore) DeleteComposedAgent(name string) error {
-	result, err := s.db.Exec(`DELETE FROM composed_agents WHERE name = ?`, name)
+	result, err := s.exec(`DELETE FROM composed_agents WHERE name = ?`, name)
 	if err != nil {
 		return err
 	}
@@ -443,17 +632,49 @@ func (s *SQLiteStore) DeleteComposedAgent(name string) error {
 
 // InsertChatMessage persists a chat message for an agent.
 func (s *SQLiteStore) InsertChatMessage(agent, role, content string) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO chat_messages (agent, role, content) VALUES (?, ?, ?)`,
 		agent, role, content,
 	)
 	return err
 }
 
+// InsertChatMessages persists msgs in order in one transaction, so either
+// all of them are saved or none are.
+func (s *SQLiteStore) InsertChatMessages(agent string, msgs []ChatMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO chat_messages (agent, role, content) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range msgs {
+		if _, err := stmt.Exec(agent, m.Role, m.Content); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 // ListChatMessages returns all chat messages for an agent, oldest first.
 func (s *SQLiteStore) ListChatMessages(agent string) ([]ChatMessage, error) {
+	return s.ListChatMessagesAfter(agent, 0)
+}
+
+// ListChatMessagesAfter returns an agent's chat messages with IDs above
+// afterID, oldest first.
+func (s *SQLiteStore) ListChatMessagesAfter(agent string, afterID int64) ([]ChatMessage, error) {
 	rows, err := s.db.Query(
-		`SELECT role, content FROM chat_messages WHERE agent = ? ORDER BY id ASC`, agent,
+		`SELECT role, content FROM chat_messages WHERE agent = ? AND id > ? ORDER BY id ASC`, agent, afterID,
 	)
 	if err != nil {
 		return nil, err
@@ -471,15 +692,178 @@ func (s *SQLiteStore) ListChatMessages(agent string) ([]ChatMessage, error) {
 	return msgs, rows.Err()
 }
 
-// DeleteChatMessages removes all chat messages for an agent.
+// DeleteChatMessages removes all chat messages and summaries for an agent.
 func (s *SQLiteStore) DeleteChatMessages(agent string) error {
-	_, err := s.db.Exec(`DELETE FROM chat_messages WHERE agent = ?`, agent)
+	if _, err := s.exec(`DELETE FROM chat_summaries WHERE agent = ?`, agent); err != nil {
+		return err
+	}
+	_, err := s.exec(`DELETE FROM chat_messages WHERE agent = ?`, agent)
 	return err
 }
 
+// InsertChatSummary records summary as replacing the next replaced chat
+// messages after the agent's previous summary. If fewer messages are stored,
+// it covers all of them.
+func (s *SQLiteStore) InsertChatSummary(agent, summary string, replaced int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var after int64
+	err = tx.QueryRow(
+		`SELECT through_id FROM chat_summaries WHERE agent = ? ORDER BY id DESC LIMIT 1`, agent,
+	).Scan(&after)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	var through int64
+	err = tx.QueryRow(
+		`SELECT id FROM chat_messages WHERE agent = ? AND id > ? ORDER BY id ASC LIMIT 1 OFFSET ?`,
+		agent, after, max(replaced-1, 0),
+	).Scan(&through)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRow(
+			`SELECT COALESCE(MAX(id), ?) FROM chat_messages WHERE agent = ?`, after, agent,
+		).Scan(&through)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO chat_summaries (agent, summary, through_id) VALUES (?, ?, ?)`,
+		agent, summary, through,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LatestChatSummary returns the agent's most recent chat summary, or nil if
+// there is none.
+func (s *SQLiteStore) LatestChatSummary(agent string) (*ChatSummary, error) {
+	var cs ChatSummary
+	err := s.db.QueryRow(
+		`SELECT agent, summary, through_id, created_at FROM chat_summaries
+		 WHERE agent = ? ORDER BY id DESC LIMIT 1`, agent,
+	).Scan(&cs.Agent, &cs.Summary, &cs.ThroughID, &cs.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cs, nil
+}
+
+// SearchChatMessages returns up to limit of the agent's chat messages that
+// contain every word of query, ranked by the FTS5 index, each with up to
+// around messages either side of it.
+func (s *SQLiteStore) SearchChatMessages(agent, query string, limit, around int) ([]ChatSearchHit, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := s.db.Query(
+		`SELECT m.id, m.role, highlight(chat_messages_fts, 0, char(1), char(2)), m.created_at
+		 FROM chat_messages_fts JOIN chat_messages m ON m.id = chat_messages_fts.rowid
+		 WHERE chat_messages_fts MATCH ? AND m.agent = ?
+		 ORDER BY chat_messages_fts.rank, m.id DESC LIMIT ?`, match, agent, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var hits []ChatSearchHit
+	for rows.Next() {
+		var h ChatSearchHit
+		var marked string
+		if err := rows.Scan(&h.ID, &h.Role, &marked, &h.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		h.Content, h.Highlights = splitHighlights(marked)
+		hits = append(hits, h)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range hits {
+		h := &hits[i]
+		if h.Before, err = s.queryChatMessages(
+			`SELECT role, content FROM (
+				SELECT id, role, content FROM chat_messages WHERE agent = ? AND id < ? ORDER BY id DESC LIMIT ?
+			 ) ORDER BY id ASC`, agent, h.ID, around,
+		); err != nil {
+			return nil, err
+		}
+		if h.After, err = s.queryChatMessages(
+			`SELECT role, content FROM chat_messages WHERE agent = ? AND id > ? ORDER BY id ASC LIMIT ?`,
+			agent, h.ID, around,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return hits, nil
+}
+
+// queryChatMessages runs a query selecting (role, content) rows. It never
+// returns a nil slice, so empty context encodes as [].
+func (s *SQLiteStore) queryChatMessages(query string, args ...any) ([]ChatMessage, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs := []ChatMessage{}
+	for rows.Next() {
+		var m ChatMessage
+		if err := rows.Scan(&m.Role, &m.Content); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query for messages containing every
+// word, quoting each word so punctuation is not parsed as query syntax.
+func ftsQuery(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = `"` + strings.ReplaceAll(w, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// splitHighlights strips the \x01 and \x02 markers that highlight() puts
+// around matched words, returning the plain text and the marked ranges.
+func splitHighlights(marked string) (string, []TextRange) {
+	var b strings.Builder
+	ranges := []TextRange{}
+	for i := 0; i < len(marked); i++ {
+		switch marked[i] {
+		case '\x01':
+			ranges = append(ranges, TextRange{Start: b.Len()})
+		case '\x02':
+			if n := len(ranges); n > 0 {
+				ranges[n-1].End = b.Len()
+			}
+		default:
+			b.WriteByte(marked[i])
+		}
+	}
+	return b.String(), ranges
+}
+
 // UpsertUserMemory creates or replaces a memory layer for a user+agent.
 func (s *SQLiteStore) UpsertUserMemory(userID, agent, layer, content string) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO user_memory (user_id, agent, layer, content, created_at, updated_at)
 		 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		 ON CONFLICT(user_id, agent, layer)
@@ -514,33 +898,70 @@ func (s *SQLiteStore) GetUserMemory(userID, agent string) ([]UserMemory, error)
 
 // DeleteUserMemory removes all memory for a user+agent.
 func (s *SQLiteStore) DeleteUserMemory(userID, agent string) error {
-	_, err := s.db.Exec(`DELETE FROM user_memory WHERE user_id = ? AND agent = ?`, userID, agent)
+	_, err := s.exec(`DELETE FROM user_memory WHERE user_id = ? AND agent = ?`, userID, agent)
 	return err
 }
 
+// ResetAgentState deletes an agent's chat history and summaries, and
+// userID's memory, memory items and scratchpad for baseAgent, in one
+// transaction, reporting how many rows of each were deleted.
+func (s *SQLiteStore) ResetAgentState(agent, userID, baseAgent string) (*AgentReset, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	reset := &AgentReset{Agent: agent, UserID: userID}
+	deletes := []struct {
+		query string
+		args  []any
+		n     *int64
+	}{
+		{`DELETE FROM chat_summaries WHERE agent = ?`, []any{agent}, nil},
+		{`DELETE FROM chat_messages WHERE agent = ?`, []any{agent}, &reset.ChatMessages},
+		{`DELETE FROM user_memory WHERE user_id = ? AND agent = ?`, []any{userID, baseAgent}, &reset.Memories},
+		{`DELETE FROM memory_items WHERE user_id = ? AND agent = ?`, []any{userID, baseAgent}, &reset.MemoryItems},
+		{`DELETE FROM agent_kv WHERE user_id = ? AND agent = ?`, []any{userID, baseAgent}, &reset.KVKeys},
+	}
+	for _, d := range deletes {
+		result, err := tx.Exec(d.query, d.args...)
+		if err != nil {
+			return nil, err
+		}
+		if d.n != nil {
+			*d.n, _ = result.RowsAffected()
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reset, nil
+}
+
 // UpsertScheduledJob creates or replaces a scheduled job.
 func (s *SQLiteStore) UpsertScheduledJob(job ScheduledJob) error {
-	_, err := s.db.Exec(
-		`INSERT OR REPLACE INTO scheduled_jobs (name, cron, agent_name, message, enabled, created_at)
-		 VALUES (?, ?, ?, ?, ?, COALESCE(
+	_, err := s.exec(
+		`INSERT OR REPLACE INTO scheduled_jobs (name, cron, timezone, run_at, agent_name, message, enabled, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, COALESCE(
 		   (SELECT created_at FROM scheduled_jobs WHERE name = ?),
 		   CURRENT_TIMESTAMP
 		 ))`,
-		job.Name, job.Cron, job.AgentName, job.Message, job.Enabled, job.Name,
+		job.Name, job.Cron, job.Timezone, job.RunAt, job.AgentName, job.Message, job.Enabled, job.Name,
 	)
 	return err
 }
 
 // DeleteScheduledJob removes a scheduled job by name.
 func (s *SQLiteStore) DeleteScheduledJob(name string) error {
-	_, err := s.db.Exec(`DELETE FROM scheduled_jobs WHERE name = ?`, name)
+	_, err := s.exec(`DELETE FROM scheduled_jobs WHERE name = ?`, name)
 	return err
 }
 
 // ListScheduledJobs returns all scheduled jobs.
 func (s *SQLiteStore) ListScheduledJobs() ([]ScheduledJob, error) {
 	rows, err := s.db.Query(
-		`SELECT name, cron, agent_name, message, enabled, created_at
+		`SELECT name, cron, timezone, run_at, agent_name, message, enabled, created_at
 		 FROM scheduled_jobs ORDER BY created_at ASC`,
 	)
 	if err != nil {
@@ -551,20 +972,79 @@ func (s *SQLiteStore) ListScheduledJobs() ([]ScheduledJob, error) {
 	var jobs []ScheduledJob
 	for rows.Next() {
 		var j ScheduledJob
-		if err := rows.Scan(&j.Name, &j.Cron, &j.AgentName, &j.Message, &j.Enabled, &j.CreatedAt); err != nil {
+		var runAt sql.NullTime
+		if err := rows.Scan(&j.Name, &j.Cron, &j.Timezone, &runAt, &j.AgentName, &j.Message, &j.Enabled, &j.CreatedAt); err != nil {
 			return nil, err
 		}
+		if runAt.Valid {
+			j.RunAt = &runAt.Time
+		}
 		jobs = append(jobs, j)
 	}
 	return jobs, rows.Err()
 }
 
+// InsertScheduleRun records one firing of a scheduled job.
+func (s *SQLiteStore) InsertScheduleRun(run ScheduleRun) error {
+	if run.FiredAt.IsZero() {
+		run.FiredAt = time.Now()
+	}
+	_, err := s.exec(
+		`INSERT INTO schedule_runs (name, agent_name, response, error, duration_ms, fired_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		run.Name, run.Agent, run.Response, run.Error, run.DurationMs, run.FiredAt.UTC(),
+	)
+	return err
+}
+
+// ListScheduleRuns returns recent runs of a scheduled job, newest first.
+func (s *SQLiteStore) ListScheduleRuns(name string, limit int) ([]ScheduleRun, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.Query(
+		`SELECT id, name, agent_name, response, error, duration_ms, fired_at
+		 FROM schedule_runs WHERE name = ? ORDER BY id DESC LIMIT ?`,
+		name, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var r ScheduleRun
+		if err := rows.Scan(&r.ID, &r.Name, &r.Agent, &r.Response, &r.Error, &r.DurationMs, &r.FiredAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// SetEmbedder enables semantic memory search. New memory items are
+// embedded on insert; existing ones are embedded the first time a semantic
+// search needs them.
+func (s *SQLiteStore) SetEmbedder(e Embedder) {
+	s.embedder = e
+}
+
 // InsertMemoryItem saves a memory item and returns its ID.
 func (s *SQLiteStore) InsertMemoryItem(item MemoryItem) (int64, error) {
-	result, err := s.db.Exec(
-		`INSERT INTO memory_items (user_id, agent, topic, content, tags)
-		 VALUES (?, ?, ?, ?, ?)`,
-		item.UserID, item.Agent, item.Topic, item.Content, item.Tags,
+	var embedding []byte
+	if s.embedder != nil {
+		if v, err := s.embed(memoryItemText(item)); err != nil {
+			slog.Warn("memory: embed item failed, saving without embedding", "error", err)
+		} else {
+			embedding = encodeEmbedding(v)
+		}
+	}
+
+	result, err := s.exec(
+		`INSERT INTO memory_items (user_id, agent, topic, content, tags, embedding)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		item.UserID, item.Agent, item.Topic, item.Content, item.Tags, embedding,
 	)
 	if err != nil {
 		return 0, err
@@ -572,6 +1052,13 @@ func (s *SQLiteStore) InsertMemoryItem(item MemoryItem) (int64, error) {
 	return result.LastInsertId()
 }
 
+// embed runs the configured embedder with a bounded timeout.
+func (s *SQLiteStore) embed(text string) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return s.embedder.Embed(ctx, text)
+}
+
 // SearchMemoryItems searches memory items by keyword via LIKE across topic, content, and tags.
 func (s *SQLiteStore) SearchMemoryItems(userID, agent, query string, limit int) ([]MemoryItem, error) {
 	if limit <= 0 {
@@ -602,9 +1089,87 @@ func (s *SQLiteStore) SearchMemoryItems(userID, agent, query string, limit int)
 	return items, rows.Err()
 }
 
+// SearchMemoryItemsSemantic ranks a user+agent's memory items by cosine
+// similarity between their embeddings and the query's. Items saved before
+// an embedder was configured are embedded and backfilled on the way. With
+// no embedder it falls back to SearchMemoryItems.
+func (s *SQLiteStore) SearchMemoryItemsSemantic(userID, agent, query string, limit int) ([]MemoryItem, error) {
+	if s.embedder == nil || strings.TrimSpace(query) == "" {
+		return s.SearchMemoryItems(userID, agent, query, limit)
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	queryVec, err := s.embed(query)
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+
+	rows, err := s.db.Query(
+		`SELECT id, user_id, agent, topic, content, tags, created_at, updated_at, embedding
+		 FROM memory_items
+		 WHERE user_id = ? AND agent = ?`,
+		userID, agent,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		item  MemoryItem
+		vec   []float32
+		score float64
+	}
+	var candidates []scored
+	for rows.Next() {
+		var c scored
+		var blob []byte
+		m := &c.item
+		if err := rows.Scan(&m.ID, &m.UserID, &m.Agent, &m.Topic, &m.Content, &m.Tags, &m.CreatedAt, &m.UpdatedAt, &blob); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(blob) > 0 {
+			c.vec = decodeEmbedding(blob)
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for idx := range candidates {
+		c := &candidates[idx]
+		if c.vec == nil {
+			v, err := s.embed(memoryItemText(c.item))
+			if err != nil {
+				slog.Warn("memory: embed item failed", "id", c.item.ID, "error", err)
+				continue
+			}
+			c.vec = v
+			s.exec(`UPDATE memory_items SET embedding = ? WHERE id = ?`, encodeEmbedding(v), c.item.ID)
+		}
+		c.score = cosineSimilarity(queryVec, c.vec)
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].score > candidates[b].score
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	items := make([]MemoryItem, len(candidates))
+	for idx, c := range candidates {
+		items[idx] = c.item
+	}
+	return items, nil
+}
+
 // DeleteMemoryItem removes a memory item by ID.
 func (s *SQLiteStore) DeleteMemoryItem(id int64) error {
-	result, err := s.db.Exec(`DELETE FROM memory_items WHERE id = ?`, id)
+	result, err := s.exec(`DELETE FROM memory_items WHERE id = ?`, id)
 	if err != nil {
 		return err
 	}
@@ -640,9 +1205,54 @@ func (s *SQLiteStore) ListMemoryItemsByTopic(userID, agent, topic string) ([]Mem
 	return items, rows.Err()
 }
 
+// UpsertKV sets a scratchpad value for a user+agent, replacing any
+// previous value for the key.
+func (s *SQLiteStore) UpsertKV(userID, agent, key, value string) error {
+	_, err := s.exec(
+		`INSERT INTO agent_kv (user_id, agent, key, value, updated_at)
+		 VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(user_id, agent, key)
+		 DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+		userID, agent, key, value,
+	)
+	return err
+}
+
+// GetKV returns a scratchpad value for a user+agent.
+func (s *SQLiteStore) GetKV(userID, agent, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow(
+		`SELECT value FROM agent_kv WHERE user_id = ? AND agent = ? AND key = ?`,
+		userID, agent, key,
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// DeleteKV removes a scratchpad value for a user+agent.
+func (s *SQLiteStore) DeleteKV(userID, agent, key string) error {
+	result, err := s.exec(
+		`DELETE FROM agent_kv WHERE user_id = ? AND agent = ? AND key = ?`,
+		userID, agent, key,
+	)
+	if err != nil {
+		return err
+	}
+	n, _ := result.RowsAffected()
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
 // InsertWorkspaceFile records a file write by an agent.
 func (s *SQLiteStore) InsertWorkspaceFile(f WorkspaceFile) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO workspace_files (path, agent, process_id, operation, description)
 		 VALUES (?, ?, ?, ?, ?)`,
 		f.Path, f.Agent, f.ProcessID, f.Operation, f.Description,
@@ -657,12 +1267,12 @@ func (s *SQLiteStore) ListWorkspaceFiles(agent string) ([]WorkspaceFile, error)
 	if agent != "" {
 		rows, err = s.db.Query(
 			`SELECT id, path, agent, process_id, operation, description, created_at
-			 FROM workspace_files WHERE agent = ? ORDER BY created_at DESC`, agent,
+			 FROM workspace_files WHERE agent = ? ORDER BY created_at DESC, id DESC`, agent,
 		)
 	} else {
 		rows, err = s.db.Query(
 			`SELECT id, path, agent, process_id, operation, description, created_at
-			 FROM workspace_files ORDER BY created_at DESC`,
+			 FROM workspace_files ORDER BY created_at DESC, id DESC`,
 		)
 	}
 	if err != nil {
@@ -702,6 +1312,89 @@ func (s *SQLiteStore) ListWorkspaceFileAgents() ([]string, error) {
 	return agents, rows.Err()
 }
 
+// InsertToolCall records one tool execution.
+func (s *SQLiteStore) InsertToolCall(c ToolCall) error {
+	_, err := s.exec(
+		`INSERT INTO tool_calls (tool, agent, process_id, args, result, error, duration_ms)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		c.Tool, c.Agent, c.ProcessID, c.Args, c.Result, c.Error, c.DurationMs,
+	)
+	return err
+}
+
+// ListToolCalls returns recent tool calls, newest first, optionally
+// filtered by agent.
+func (s *SQLiteStore) ListToolCalls(agent string, limit int) ([]ToolCall, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	var rows *sql.Rows
+	var err error
+	if agent != "" {
+		rows, err = s.db.Query(
+			`SELECT id, tool, agent, process_id, args, result, error, duration_ms, created_at
+			 FROM tool_calls WHERE agent = ? ORDER BY id DESC LIMIT ?`, agent, limit,
+		)
+	} else {
+		rows, err = s.db.Query(
+			`SELECT id, tool, agent, process_id, args, result, error, duration_ms, created_at
+			 FROM tool_calls ORDER BY id DESC LIMIT ?`, limit,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []ToolCall
+	for rows.Next() {
+		var c ToolCall
+		if err := rows.Scan(&c.ID, &c.Tool, &c.Agent, &c.ProcessID, &c.Args, &c.Result, &c.Error, &c.DurationMs, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// InsertCostEntry records the cost of an LLM call in the cost ledger.
+func (s *SQLiteStore) InsertCostEntry(e CostEntry) error {
+	_, err := s.exec(
+		`INSERT INTO cost_ledger (user_id, agent, model, input_tokens, output_tokens, cost_usd)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		e.UserID, e.Agent, e.Model, e.InputTokens, e.OutputTokens, e.CostUSD,
+	)
+	return err
+}
+
+// Usage totals the cost ledger since the given time, optionally filtered by
+// user and agent. A zero since totals the whole ledger.
+func (s *SQLiteStore) Usage(userID, agent string, since time.Time) (*Usage, error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0), COALESCE(SUM(cost_usd), 0)
+		FROM cost_ledger WHERE 1 = 1`
+	var args []any
+	if userID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+	if agent != "" {
+		query += ` AND agent = ?`
+		args = append(args, agent)
+	}
+	if !since.IsZero() {
+		// created_at holds CURRENT_TIMESTAMP text, which is UTC.
+		query += ` AND created_at >= ?`
+		args = append(args, since.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	u := &Usage{UserID: userID, Agent: agent, Since: since}
+	err := s.db.QueryRow(query, args...).Scan(&u.Calls, &u.InputTokens, &u.OutputTokens, &u.CostUSD)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 // CountTable returns the number of rows in the given table.
 func (s *SQLiteStore) CountTable(table string) (int, error) {
 	var count int
@@ -711,13 +1404,32 @@ func (s *SQLiteStore) CountTable(table string) (int, error) {
 
 // DeleteAllFromTable removes all rows from the given table.
 func (s *SQLiteStore) DeleteAllFromTable(table string) error {
-	_, err := s.db.Exec("DELETE FROM " + table)
+	_, err := s.exec("DELETE FROM " + table)
 	return err
 }
 
 // Vacuum reclaims unused space in the database.
 func (s *SQLiteStore) Vacuum() {
-	s.db.Exec("VACUUM")
+	s.exec("VACUUM")
+}
+
+// Backup writes a consistent snapshot of the database to destPath while
+// the store stays online. It uses VACUUM INTO, which reads inside a single
+// transaction, so concurrent writers are neither blocked for long nor
+// captured halfway. destPath must not already exist. To restore, stop the
+// server and start it with the backup as its database.
+func (s *SQLiteStore) Backup(destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fmt.Errorf("create backup directory: %w", err)
+	}
+	if _, err := s.exec(`VACUUM INTO ?`, destPath); err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("backup to %s: %w", destPath, err)
+	}
+	return nil
 }
 
 // ResetData clears all transient data but preserves settings.
@@ -725,17 +1437,23 @@ func (s *SQLiteStore) ResetData() error {
 	tables := []string{
 		"composed_agents",
 		"chat_messages",
+		"chat_summaries",
 		"user_memory",
 		"memory_items",
+		"agent_kv",
 		"events",
 		"process_snapshots",
 		"workflow_runs",
+		"workflow_steps",
+		"idempotency_keys",
 		"scheduled_jobs",
+		"schedule_runs",
 		"channel_messages",
 		"channels",
 		"inbox_replies",
 		"agent_inbox",
 		"workspace_files",
+		"tool_calls",
 		"channel_read_cursors",
 		"chat_read_cursors",
 	}
@@ -750,7 +1468,7 @@ func (s *SQLiteStore) ResetData() error {
 
 // UpsertSetting creates or updates a setting.
 func (s *SQLiteStore) UpsertSetting(st Setting) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO settings (key, value, sensitive, created_at, updated_at)
 		 VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		 ON CONFLICT(key)
@@ -798,7 +1516,7 @@ func (s *SQLiteStore) ListSettings() ([]Setting, error) {
 
 // DeleteSetting removes a setting by key.
 func (s *SQLiteStore) DeleteSetting(key string) error {
-	result, err := s.db.Exec(`DELETE FROM settings WHERE key = ?`, key)
+	result, err := s.exec(`DELETE FROM settings WHERE key = ?`, key)
 	if err != nil {
 		return err
 	}
@@ -811,7 +1529,7 @@ func (s *SQLiteStore) DeleteSetting(key string) error {
 
 // UpsertMCPServer persists an MCP server connection config.
 func (s *SQLiteStore) UpsertMCPServer(name, configJSON string) error {
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO mcp_servers (name, config, created_at)
 		 VALUES (?, ?, CURRENT_TIMESTAMP)
 		 ON CONFLICT(name)
@@ -823,7 +1541,7 @@ func (s *SQLiteStore) UpsertMCPServer(name, configJSON string) error {
 
 // DeleteMCPServer removes a persisted MCP server connection.
 func (s *SQLiteStore) DeleteMCPServer(name string) error {
-	_, err := s.db.Exec(`DELETE FROM mcp_servers WHERE name = ?`, name)
+	_, err := s.exec(`DELETE FROM mcp_servers WHERE name = ?`, name)
 	return err
 }
 
@@ -854,7 +1572,7 @@ func (s *SQLiteStore) SetMCPServerDisabled(name string, disabled bool) error {
 	if disabled {
 		val = 1
 	}
-	res, err := s.db.Exec(`UPDATE mcp_servers SET disabled = ? WHERE name = ?`, val, name)
+	res, err := s.exec(`UPDATE mcp_servers SET disabled = ? WHERE name = ?`, val, name)
 	if err != nil {
 		return err
 	}
@@ -870,7 +1588,7 @@ func (s *SQLiteStore) SetMCPServerDisabled(name string, disabled bool) error {
 // CreateChannel creates a new channel.
 func (s *SQLiteStore) CreateChannel(id, name, description, createdBy string, team []string, mode string) error {
 	teamJSON, _ := json.Marshal(team)
-	_, err := s.db.Exec(
+	_, err := s.exec(
 		`INSERT INTO channels (id, name, description, team, mode, created_by) VALUES (?, ?, ?, ?, ?, ?)`,
 		id, name, description, string(teamJSON), mode, createdBy,
 	)
@@ -977,8 +1695,8 @@ func (s *SQLiteStore) DeleteChannel(name string) error {
 		return err
 	}
 	// Delete messages first (SQLite foreign key cascade may not be enabled).
-	s.db.Exec(`DELETE FROM channel_messages WHERE channel_id = ?`, id)
-	result, err := s.db.Exec(`DELETE FROM channels WHERE name = ?`, name)
+	s.exec(`DELETE FROM channel_messages WHERE channel_id = ?`, id)
+	result, err := s.exec(`DELETE FROM channels WHERE name = ?`, name)
 	if err != nil {
 		return err
 	}
@@ -992,7 +1710,7 @@ func (s *SQLiteStore) DeleteChannel(name string) error {
 // UpdateChannelTeam updates the team members of a channel.
 func (s *SQLiteStore) UpdateChannelTeam(name string, team []string) error {
 	teamJSON, _ := json.Marshal(team)
-	result, err := s.db.Exec(`UPDATE channels SET team = ? WHERE name = ?`, string(teamJSON), name)
+	result, err := s.exec(`UPDATE channels SET team = ? WHERE name = ?`, string(teamJSON), name)
 	if err != nil {
 		return err
 	}
@@ -1039,7 +1757,7 @@ func (s *SQLiteStore) InsertChannelMessage(channelID, agent, role, content strin
 	if metadata == "" {
 		metadata = "{}"
 	}
-	result, err := s.db.Exec(
+	result, err := s.exec(
 		`INSERT INTO channel_messages (channel_id, thread_id, agent, role, content, metadata, sender) VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		channelID, threadID, agent, role, content, metadata, sender,
 	)
@@ -1146,7 +1864,7 @@ func (s *SQLiteStore) ListThreadMessages(channelID string, threadID int64) ([]Ch
 
 // InsertInboxItem creates a new inbox item and returns its ID.
 func (s *SQLiteStore) InsertInboxItem(fromAgent, subject, body, priority string) (int64, error) {
-	result, err := s.db.Exec(
+	result, err := s.exec(
 		`INSERT INTO agent_inbox (from_agent, subject, body, priority) VALUES (?, ?, ?, ?)`,
 		fromAgent, subject, body, priority,
 	)
@@ -1231,7 +1949,7 @@ func (s *SQLiteStore) GetInboxItem(id int64) (*InboxItem, error) {
 
 // ResolveInboxItem marks an inbox item as resolved.
 func (s *SQLiteStore) ResolveInboxItem(id int64, resolution string) error {
-	result, err := s.db.Exec(
+	result, err := s.exec(
 		`UPDATE agent_inbox SET status = 'resolved', resolution = ?, resolved_at = CURRENT_TIMESTAMP WHERE id = ?`,
 		resolution, id,
 	)
@@ -1248,8 +1966,8 @@ func (s *SQLiteStore) ResolveInboxItem(id int64, resolution string) error {
 // DeleteResolvedInboxItems removes all resolved inbox items and their replies.
 func (s *SQLiteStore) DeleteResolvedInboxItems() (int64, error) {
 	// Delete replies for resolved items first.
-	s.db.Exec(`DELETE FROM inbox_replies WHERE inbox_id IN (SELECT id FROM agent_inbox WHERE status = 'resolved')`)
-	result, err := s.db.Exec(`DELETE FROM agent_inbox WHERE status = 'resolved'`)
+	s.exec(`DELETE FROM inbox_replies WHERE inbox_id IN (SELECT id FROM agent_inbox WHERE status = 'resolved')`)
+	result, err := s.exec(`DELETE FROM agent_inbox WHERE status = 'resolved'`)
 	if err != nil {
 		return 0, err
 	}
@@ -1260,7 +1978,7 @@ func (s *SQLiteStore) DeleteResolvedInboxItems() (int64, error) {
 
 // InsertPromptHistory records an original user prompt to iris.
 func (s *SQLiteStore) InsertPromptHistory(prompt string) (int64, error) {
-	result, err := s.db.Exec(
+	result, err := s.exec(
 		`INSERT INTO prompt_history (prompt) VALUES (?)`, prompt,
 	)
 	if err != nil {
@@ -1323,7 +2041,7 @@ func (s *SQLiteStore) SearchPromptHistory(query string, limit int) ([]PromptHist
 
 // DeletePromptHistory removes a prompt history entry by ID.
 func (s *SQLiteStore) DeletePromptHistory(id int64) error {
-	result, err := s.db.Exec(`DELETE FROM prompt_history WHERE id = ?`, id)
+	result, err := s.exec(`DELETE FROM prompt_history WHERE id = ?`, id)
 	if err != nil {
 		return err
 	}
@@ -1339,7 +2057,7 @@ func (s *SQLiteStore) MarkChannelRead(channelID, userID string) error {
 	if userID == "" {
 		userID = "default"
 	}
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		INSERT INTO channel_read_cursors (channel_id, user_id, last_read_id, updated_at)
 		VALUES (?, ?, COALESCE((SELECT MAX(id) FROM channel_messages WHERE channel_id = ? AND thread_id IS NULL), 0), CURRENT_TIMESTAMP)
 		ON CONFLICT(channel_id, user_id) DO UPDATE SET
@@ -1355,7 +2073,7 @@ func (s *SQLiteStore) MarkChatRead(agent, userID string) error {
 	if userID == "" {
 		userID = "default"
 	}
-	_, err := s.db.Exec(`
+	_, err := s.exec(`
 		INSERT INTO chat_read_cursors (agent, user_id, last_read_id, updated_at)
 		VALUES (?, ?, COALESCE((SELECT MAX(id) FROM chat_messages WHERE agent = ?), 0), CURRENT_TIMESTAMP)
 		ON CONFLICT(agent, user_id) DO UPDATE SET
@@ -1394,20 +2112,3 @@ func (s *SQLiteStore) ChatUnreadCounts(userID string) (map[string]int, error) {
 	}
 	return counts, rows.Err()
 }
-
-// snapshotProcess creates a snapshot from a live process and persists it.
-func (s *SQLiteStore) snapshotProcess(proc ProcessResponse) error {
-	snap := ProcessSnapshot{
-		ProcessID:    proc.ID,
-		AgentName:    proc.Agent,
-		Status:       proc.Status,
-		ParentID:     proc.ParentID,
-		InputTokens:  proc.Metrics.InputTokens,
-		OutputTokens: proc.Metrics.OutputTokens,
-		CostUSD:      proc.Metrics.CostUSD,
-		StartedAt:    proc.StartedAt,
-		CompletedAt:  proc.CompletedAt,
-		SnapshotAt:   time.Now(),
-	}
-	return s.InsertProcessSnapshot(snap)
-}
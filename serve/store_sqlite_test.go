@@ -0,0 +1,21 @@
+package serve
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tmp/vega.db", "/tmp/vega.db?_pragma="},
+		{"file:/tmp/vega.db?mode=ro", "file:/tmp/vega.db?mode=ro&_pragma="},
+	}
+	for _, tt := range tests {
+		if got := sqliteDSN(tt.path); !strings.HasPrefix(got, tt.want) || !strings.Contains(got, "busy_timeout") {
+			t.Errorf("sqliteDSN(%q) = %q", tt.path, got)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+package serve
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// postgresTestURLEnv names a Postgres database to run the store tests
+// against as well as SQLite. Each test gets its own schema, dropped after.
+const postgresTestURLEnv = "VEGA_TEST_POSTGRES_URL"
+
+var postgresSchemaSeq atomic.Int64
+
+// forEachStore runs fn against a fresh SQLite store and, when
+// VEGA_TEST_POSTGRES_URL is set, a fresh Postgres store.
+func forEachStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	t.Run("sqlite", func(t *testing.T) {
+		fn(t, newTestStore(t))
+	})
+	t.Run("postgres", func(t *testing.T) {
+		fn(t, newTestPostgresStore(t))
+	})
+}
+
+// newTestPostgresStore returns an initialised PostgresStore in a schema of
+// its own, or skips the test when no test database is configured.
+func newTestPostgresStore(t *testing.T) *PostgresStore {
+	t.Helper()
+	base := os.Getenv(postgresTestURLEnv)
+	if base == "" {
+		t.Skip(postgresTestURLEnv + " not set")
+	}
+
+	admin, err := sql.Open("pgx", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("vega_test_%d_%d", os.Getpid(), postgresSchemaSeq.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	store, err := NewPostgresStore(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// countRows returns the number of rows in table.
+func countRows(t *testing.T, store Store, table string) int {
+	t.Helper()
+	counter, ok := store.(interface{ CountTable(string) (int, error) })
+	if !ok {
+		t.Fatalf("%T cannot count rows", store)
+	}
+	n, err := counter.CountTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// rejectChatContent makes inserting a chat message with the given content
+// fail, to exercise rollback.
+func rejectChatContent(t *testing.T, store Store, content string) {
+	t.Helper()
+	var stmts []string
+	var db *sql.DB
+	switch st := store.(type) {
+	case *SQLiteStore:
+		db = st.db
+		stmts = []string{fmt.Sprintf(`CREATE TRIGGER reject_content BEFORE INSERT ON chat_messages
+			WHEN NEW.content = '%s' BEGIN SELECT RAISE(ABORT, 'rejected'); END`, content)}
+	case *PostgresStore:
+		db = st.db
+		stmts = []string{
+			fmt.Sprintf(`CREATE FUNCTION reject_content() RETURNS trigger AS $$
+			BEGIN
+				IF NEW.content = '%s' THEN RAISE EXCEPTION 'rejected'; END IF;
+				RETURN NEW;
+			END $$ LANGUAGE plpgsql`, content),
+			`CREATE TRIGGER reject_content BEFORE INSERT ON chat_messages
+			FOR EACH ROW EXECUTE FUNCTION reject_content()`,
+		}
+	default:
+		t.Fatalf("no trigger support for %T", store)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		const writers, perWriter = 32, 25
+		var wg sync.WaitGroup
+		errs := make(chan error, writers*perWriter*2)
+		for w := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				agent := fmt.Sprintf("agent-%d", w%4)
+				for i := range perWriter {
+					if err := store.InsertChatMessage(agent, "user", fmt.Sprintf("msg %d-%d", w, i)); err != nil {
+						errs <- err
+					}
+					if err := store.InsertEvent(StoreEvent{Type: "process.started", AgentName: agent, Timestamp: time.Now()}); err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Errorf("write failed: %v", err)
+		}
+		for _, table := range []string{"chat_messages", "events"} {
+			if got := countRows(t, store, table); got != writers*perWriter {
+				t.Errorf("%s has %d rows, want %d", table, got, writers*perWriter)
+			}
+		}
+	})
+}
+
+func TestInsertChatMessagesIsAtomic(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		turn := []ChatMessage{
+			{Role: "user", Content: "What's on today?"},
+			{Role: "assistant", Content: "Two site visits."},
+		}
+		if err := store.InsertChatMessages("etienne", turn); err != nil {
+			t.Fatal(err)
+		}
+		msgs, _ := store.ListChatMessages("etienne")
+		if len(msgs) != 2 || msgs[0] != turn[0] || msgs[1] != turn[1] {
+			t.Fatalf("messages = %+v, want %+v", msgs, turn)
+		}
+
+		// Make the middle insert of the next batch fail.
+		rejectChatContent(t, store, "boom")
+		err := store.InsertChatMessages("etienne", []ChatMessage{
+			{Role: "user", Content: "first"},
+			{Role: "assistant", Content: "boom"},
+			{Role: "user", Content: "third"},
+		})
+		if err == nil {
+			t.Fatal("expected the batch to fail")
+		}
+		if msgs, _ := store.ListChatMessages("etienne"); len(msgs) != 2 {
+			t.Errorf("after failed batch: %d messages, want the original 2", len(msgs))
+		}
+	})
+}
+
+func TestStoreRoundTrips(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		started := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+		// events, process_snapshots, workflow_runs
+		if err := store.InsertEvent(StoreEvent{Type: "process.started", ProcessID: "p1", AgentName: "ada", Timestamp: started}); err != nil {
+			t.Fatal(err)
+		}
+		if events, err := store.ListEvents(10); err != nil || len(events) != 1 || events[0].ProcessID != "p1" {
+			t.Errorf("ListEvents = %+v, %v", events, err)
+		}
+		for _, status := range []string{"running", "completed"} {
+			if err := store.InsertProcessSnapshot(ProcessSnapshot{ProcessID: "p1", AgentName: "ada", Status: status, InputTokens: 7, StartedAt: started, SnapshotAt: time.Now()}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if snaps, err := store.ListProcessSnapshots(); err != nil || len(snaps) != 1 || snaps[0].Status != "completed" || snaps[0].InputTokens != 7 {
+			t.Errorf("ListProcessSnapshots = %+v, %v; want the latest snapshot", snaps, err)
+		}
+		if err := store.InsertWorkflowRun(WorkflowRun{RunID: "r1", Workflow: "daily", Inputs: "{}", Status: "running", StartedAt: started}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.UpdateWorkflowRun("r1", "completed", "done"); err != nil {
+			t.Fatal(err)
+		}
+		if runs, err := store.ListWorkflowRuns(10); err != nil || len(runs) != 1 || runs[0].Status != "completed" || runs[0].Result != "done" {
+			t.Errorf("ListWorkflowRuns = %+v, %v", runs, err)
+		}
+
+		// idempotency_keys: the first claim wins until it expires.
+		if run, err := store.ClaimIdempotencyKey("daily", "k", "r2", time.Hour); err != nil || run.RunID != "r2" || run.Status != "running" {
+			t.Errorf("first claim = %+v, %v; want the new run", run, err)
+		}
+		if run, err := store.ClaimIdempotencyKey("daily", "k", "r3", time.Hour); err != nil || run.RunID != "r2" {
+			t.Errorf("second claim = %+v, %v; want r2", run, err)
+		}
+		if run, err := store.ClaimIdempotencyKey("daily", "done", "r1", -time.Second); err != nil || run.RunID != "r1" || run.Status != "completed" {
+			t.Errorf("claim for recorded run = %+v, %v; want r1 completed", run, err)
+		}
+		if run, err := store.ClaimIdempotencyKey("daily", "done", "r4", time.Hour); err != nil || run.RunID != "r4" {
+			t.Errorf("claim after expiry = %+v, %v; want r4", run, err)
+		}
+
+		// composed_agents: re-inserting replaces.
+		temp := 0.3
+		for _, model := range []string{"m1", "m2"} {
+			if err := store.InsertComposedAgent(ComposedAgent{Name: "ada", Model: model, Skills: []string{"s"}, Temperature: &temp, CreatedAt: started}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		agents, err := store.ListComposedAgents()
+		if err != nil || len(agents) != 1 || agents[0].Model != "m2" || agents[0].Temperature == nil || *agents[0].Temperature != temp {
+			t.Errorf("ListComposedAgents = %+v, %v", agents, err)
+		}
+		if err := store.DeleteComposedAgent("nobody"); err != sql.ErrNoRows {
+			t.Errorf("DeleteComposedAgent(missing) = %v, want sql.ErrNoRows", err)
+		}
+
+		// user_memory: upsert replaces the layer.
+		store.UpsertUserMemory("u", "ada", "profile", "likes tea")
+		store.UpsertUserMemory("u", "ada", "profile", "likes coffee")
+		if mem, err := store.GetUserMemory("u", "ada"); err != nil || len(mem) != 1 || mem[0].Content != "likes coffee" {
+			t.Errorf("GetUserMemory = %+v, %v", mem, err)
+		}
+
+		// scheduled_jobs: upsert replaces, keeping the job enabled flag.
+		runAt := started.Add(time.Hour)
+		store.UpsertScheduledJob(ScheduledJob{Name: "j", Cron: "* * * * *", AgentName: "ada", Message: "old", Enabled: true})
+		store.UpsertScheduledJob(ScheduledJob{Name: "j", Cron: "* * * * *", AgentName: "ada", Message: "new", Enabled: true, RunAt: &runAt})
+		jobs, err := store.ListScheduledJobs()
+		if err != nil || len(jobs) != 1 || jobs[0].Message != "new" || !jobs[0].Enabled || jobs[0].RunAt == nil || !jobs[0].RunAt.Equal(runAt) {
+			t.Errorf("ListScheduledJobs = %+v, %v", jobs, err)
+		}
+
+		// memory_items
+		id, err := store.InsertMemoryItem(MemoryItem{UserID: "u", Agent: "ada", Topic: "Pets", Content: "Has a CAT named Tom"})
+		if err != nil || id == 0 {
+			t.Fatalf("InsertMemoryItem = %d, %v", id, err)
+		}
+		if items, err := store.SearchMemoryItems("u", "ada", "cat", 10); err != nil || len(items) != 1 || items[0].ID != id {
+			t.Errorf("SearchMemoryItems = %+v, %v; want case-insensitive match", items, err)
+		}
+		if err := store.DeleteMemoryItem(id); err != nil {
+			t.Errorf("DeleteMemoryItem: %v", err)
+		}
+
+		// settings
+		store.UpsertSetting(Setting{Key: "K", Value: "v1", Sensitive: true})
+		store.UpsertSetting(Setting{Key: "K", Value: "v2", Sensitive: true})
+		if st, err := store.GetSetting("K"); err != nil || st == nil || st.Value != "v2" || !st.Sensitive {
+			t.Errorf("GetSetting = %+v, %v", st, err)
+		}
+		if st, err := store.GetSetting("missing"); err != nil || st != nil {
+			t.Errorf("GetSetting(missing) = %+v, %v; want nil, nil", st, err)
+		}
+
+		// mcp_servers
+		mcp, ok := store.(mcpServerStore)
+		if !ok {
+			t.Fatalf("%T does not persist MCP servers", store)
+		}
+		mcp.UpsertMCPServer("gh", `{"a":1}`)
+		mcp.UpsertMCPServer("gh", `{"a":2}`)
+		if err := mcp.SetMCPServerDisabled("gh", true); err != nil {
+			t.Fatal(err)
+		}
+		if servers, err := mcp.ListMCPServers(); err != nil || len(servers) != 1 || servers[0].ConfigJSON != `{"a":2}` || !servers[0].Disabled {
+			t.Errorf("ListMCPServers = %+v, %v", servers, err)
+		}
+		if err := mcp.SetMCPServerDisabled("missing", true); err == nil {
+			t.Error("SetMCPServerDisabled(missing) succeeded")
+		}
+	})
+}
+
+func TestSearchChatMessages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		store.InsertChatMessages("ada", []ChatMessage{
+			{Role: "user", Content: "hi"},
+			{Role: "user", Content: "What did we decide about the API?"},
+			{Role: "assistant", Content: "We picked REST; the api stays versioned."},
+			{Role: "user", Content: "thanks"},
+		})
+		store.InsertChatMessage("bob", "user", "the API is down")
+
+		hits, err := store.SearchChatMessages("ada", "api versioned", 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hits) != 1 {
+			t.Fatalf("hits = %+v, want the one message with both words", hits)
+		}
+		h := hits[0]
+		if h.Role != "assistant" || h.Content != "We picked REST; the api stays versioned." {
+			t.Errorf("hit = %+v", h)
+		}
+		var marked []string
+		for _, r := range h.Highlights {
+			marked = append(marked, h.Content[r.Start:r.End])
+		}
+		if strings.Join(marked, ",") != "api,versioned" {
+			t.Errorf("highlighted %q, want api and versioned", marked)
+		}
+		if len(h.Before) != 1 || h.Before[0].Content != "What did we decide about the API?" ||
+			len(h.After) != 1 || h.After[0].Content != "thanks" {
+			t.Errorf("context = %+v / %+v, want one message either side", h.Before, h.After)
+		}
+
+		// Punctuation is matched literally, not parsed as query syntax.
+		if hits, err := store.SearchChatMessages("ada", `"API?" -`, 10, 0); err != nil || len(hits) != 2 {
+			t.Errorf("punctuated query = %+v, %v; want both API messages", hits, err)
+		}
+
+		// Clearing the chat drops it from the index.
+		store.DeleteChatMessages("ada")
+		if hits, err := store.SearchChatMessages("ada", "api", 10, 0); err != nil || len(hits) != 0 {
+			t.Errorf("after clearing = %+v, %v; want no hits", hits, err)
+		}
+		if hits, err := store.SearchChatMessages("bob", "api", 10, 0); err != nil || len(hits) != 1 {
+			t.Errorf("bob's chat = %+v, %v; want one hit", hits, err)
+		}
+	})
+}
+
+func TestStoreHealth(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store Store) {
+		hc, ok := store.(healthChecker)
+		if !ok {
+			t.Fatalf("%T does not report health", store)
+		}
+		hc.CheckHealth(context.Background())
+		if readErr, writeErr := hc.Health(); readErr != nil || writeErr != nil {
+			t.Errorf("Health() = %v, %v; want a healthy store", readErr, writeErr)
+		}
+		store.Close()
+		if readErr, writeErr := hc.Health(); readErr == nil || writeErr == nil {
+			t.Error("Health() after Close reported a healthy store")
+		}
+	})
+}
+
+func TestOpenStoreSelectsBackend(t *testing.T) {
+	store, err := OpenStore(t.TempDir() + "/vega.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, ok := store.(*SQLiteStore); !ok {
+		t.Errorf("OpenStore(path) = %T, want *SQLiteStore", store)
+	}
+	for _, dsn := range []string{"postgres://h/db", "postgresql://h/db"} {
+		if !isPostgresDSN(dsn) {
+			t.Errorf("isPostgresDSN(%q) = false", dsn)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package serve
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	vega "github.com/everydev1618/govega"
+)
+
+// defaultPolicyMessage replaces a blocked response when the StreamFilter
+// gives no message of its own.
+const defaultPolicyMessage = "This response was stopped by the content policy."
+
+// StreamFilter inspects a chat response while it streams. text is all the
+// agent has written so far. Returning allow false aborts the response and
+// shows policy in its place.
+type StreamFilter func(ctx context.Context, agentName, text string) (allow bool, policy string)
+
+// relayChatEvents publishes the agent's stream events to as, running the
+// configured StreamFilter, if any, over the accumulated text. When the
+// filter blocks, the offending delta is withheld, a policy event is
+// published, the generation is cancelled and the rest of its events are
+// dropped. It returns the policy message, or "" if nothing was blocked.
+func (s *Server) relayChatEvents(ctx context.Context, name string, events <-chan vega.ChatEvent, as *activeStream, cancel context.CancelFunc) string {
+	var text strings.Builder
+	var policy string
+	for event := range events {
+		if policy != "" {
+			continue
+		}
+		if s.cfg.StreamFilter != nil && event.Type == vega.ChatEventTextDelta {
+			text.WriteString(event.Delta)
+			if allow, msg := s.cfg.StreamFilter(ctx, name, text.String()); !allow {
+				policy = msg
+				if policy == "" {
+					policy = defaultPolicyMessage
+				}
+				slog.Warn("chat response blocked by stream filter", "agent", name, "policy", policy)
+				as.publish(vega.ChatEvent{Type: vega.ChatEventPolicy, Error: policy})
+				cancel()
+				continue
+			}
+		}
+		as.publish(event)
+	}
+	return policy
+}
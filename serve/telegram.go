@@ -88,19 +88,19 @@ func (t *TelegramBot) handle(ctx context.Context, update tgbotapi.Update) {
 	// Load and inject memory into the process before sending.
 	proc, err := t.interp.EnsureAgent(name)
 	if err == nil && proc != nil {
-		var memText string
-		if memories, err := t.store.GetUserMemory(userID, t.agentName); err == nil && len(memories) > 0 {
-			memText = formatMemoryForInjection(memories)
-		}
+		memText := userMemoryText(t.store, t.interp, userID, t.agentName)
 		companyCtx := buildCompanyContext(t.company)
 		if extra := buildExtraSystem(memText, "", companyCtx); extra != "" {
 			proc.SetExtraSystem(extra)
 		}
 	}
 
-	// Persist user message.
-	if err := t.store.InsertChatMessage(name, "user", text); err != nil {
-		slog.Warn("telegram: failed to insert user message", "error", err)
+	// Persist user message, unless the agent keeps no history.
+	persist := agentPersistsChat(t.interp, name)
+	if persist {
+		if err := t.store.InsertChatMessage(name, "user", text); err != nil {
+			slog.Warn("telegram: failed to insert user message", "error", err)
+		}
 	}
 
 	// Add memory context so tools can access the store.
@@ -117,8 +117,10 @@ func (t *TelegramBot) handle(ctx context.Context, update tgbotapi.Update) {
 	}
 
 	// Persist assistant response.
-	if err := t.store.InsertChatMessage(name, "assistant", response); err != nil {
-		slog.Warn("telegram: failed to insert assistant message", "error", err)
+	if persist {
+		if err := t.store.InsertChatMessage(name, "assistant", response); err != nil {
+			slog.Warn("telegram: failed to insert assistant message", "error", err)
+		}
 	}
 
 	if _, err := t.bot.Send(tgbotapi.NewMessage(chatID, response)); err != nil {
@@ -0,0 +1,49 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/tools"
+)
+
+// maxAuditFieldSize caps the args and result stored for each tool call.
+const maxAuditFieldSize = 2048
+
+// toolAuditMiddleware records every tool execution in the tool_calls table
+// with the calling agent and process taken from the context. Recording
+// failures are logged and never affect the tool's result.
+func toolAuditMiddleware(store Store) tools.ToolMiddleware {
+	return func(next tools.ToolFunc) tools.ToolFunc {
+		return func(ctx context.Context, params map[string]any) (string, error) {
+			start := time.Now()
+			result, err := next(ctx, params)
+
+			call := ToolCall{
+				Tool:       tools.ToolNameFromContext(ctx),
+				Result:     truncate(result, maxAuditFieldSize),
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if args, merr := json.Marshal(params); merr == nil {
+				call.Args = truncate(string(args), maxAuditFieldSize)
+			}
+			if err != nil {
+				call.Error = err.Error()
+			}
+			if proc := vega.ProcessFromContext(ctx); proc != nil {
+				call.ProcessID = proc.ID
+				if proc.Agent != nil {
+					call.Agent = proc.Agent.Name
+				}
+			}
+			if ierr := store.InsertToolCall(call); ierr != nil {
+				slog.Error("failed to record tool call", "tool", call.Tool, "error", ierr)
+			}
+
+			return result, err
+		}
+	}
+}
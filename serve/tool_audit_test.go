@@ -0,0 +1,110 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/tools"
+)
+
+func TestToolAuditMiddleware(t *testing.T) {
+	store := newTestStore(t)
+
+	tl := tools.NewTools()
+	tl.Use(toolAuditMiddleware(store))
+	tl.Register("echo", func(ctx context.Context, params map[string]any) (string, error) {
+		return strings.Repeat("x", 3*maxAuditFieldSize), nil
+	})
+	tl.Register("fail", func(ctx context.Context, params map[string]any) (string, error) {
+		return "", errors.New("boom")
+	})
+
+	proc := &vega.Process{ID: "proc-1", Agent: &vega.Agent{Name: "coder"}}
+	ctx := vega.ContextWithProcess(context.Background(), proc)
+
+	if _, err := tl.Execute(ctx, "echo", map[string]any{"msg": "hi"}); err != nil {
+		t.Fatalf("echo: %v", err)
+	}
+	if _, err := tl.Execute(context.Background(), "fail", nil); err == nil {
+		t.Fatal("fail: expected error")
+	}
+
+	calls, err := store.ListToolCalls("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("got %d calls, want 2", len(calls))
+	}
+
+	// Newest first.
+	failed, echo := calls[0], calls[1]
+	if failed.Tool != "fail" || !strings.Contains(failed.Error, "boom") || failed.Agent != "" {
+		t.Errorf("fail call = %+v", failed)
+	}
+	if echo.Tool != "echo" || echo.Agent != "coder" || echo.ProcessID != "proc-1" {
+		t.Errorf("echo call = %+v", echo)
+	}
+	if echo.Args != `{"msg":"hi"}` {
+		t.Errorf("args = %q", echo.Args)
+	}
+	if len(echo.Result) != maxAuditFieldSize {
+		t.Errorf("result length = %d, want %d", len(echo.Result), maxAuditFieldSize)
+	}
+
+	filtered, err := store.ListToolCalls("coder", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 1 || filtered[0].Tool != "echo" {
+		t.Errorf("filtered calls = %+v", filtered)
+	}
+}
+
+func TestHandleListToolCalls(t *testing.T) {
+	store := newTestStore(t)
+	for _, c := range []ToolCall{
+		{Tool: "exec", Agent: "coder"},
+		{Tool: "http_get", Agent: "researcher"},
+		{Tool: "write_file", Agent: "coder"},
+	} {
+		if err := store.InsertToolCall(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Server{store: store}
+	get := func(query string) []ToolCall {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.handleListToolCalls(w, httptest.NewRequest("GET", "/api/tool-calls"+query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d; body: %s", w.Code, w.Body.String())
+		}
+		var calls []ToolCall
+		if err := json.Unmarshal(w.Body.Bytes(), &calls); err != nil {
+			t.Fatal(err)
+		}
+		return calls
+	}
+
+	if calls := get(""); len(calls) != 3 {
+		t.Errorf("all: got %d calls, want 3", len(calls))
+	}
+	calls := get("?agent=coder")
+	if len(calls) != 2 || calls[0].Tool != "write_file" || calls[1].Tool != "exec" {
+		t.Errorf("agent=coder: got %+v", calls)
+	}
+	if calls := get("?agent=coder&limit=1"); len(calls) != 1 {
+		t.Errorf("limit=1: got %d calls, want 1", len(calls))
+	}
+	if calls := get("?agent=nobody"); calls == nil || len(calls) != 0 {
+		t.Errorf("agent=nobody: got %+v, want empty list", calls)
+	}
+}
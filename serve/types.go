@@ -6,16 +6,18 @@ import "time"
 
 // ProcessResponse is the API representation of a process.
 type ProcessResponse struct {
-	ID          string          `json:"id"`
-	Agent       string          `json:"agent"`
-	Task        string          `json:"task,omitempty"`
-	Status      string          `json:"status"`
-	StartedAt   time.Time       `json:"started_at"`
-	CompletedAt *time.Time      `json:"completed_at,omitempty"`
-	ParentID    string          `json:"parent_id,omitempty"`
-	SpawnDepth  int             `json:"spawn_depth"`
-	SpawnReason string          `json:"spawn_reason,omitempty"`
-	Metrics     MetricsResponse `json:"metrics"`
+	ID          string            `json:"id"`
+	Agent       string            `json:"agent"`
+	Task        string            `json:"task,omitempty"`
+	Status      string            `json:"status"`
+	StartedAt   time.Time         `json:"started_at"`
+	CompletedAt *time.Time        `json:"completed_at,omitempty"`
+	ParentID    string            `json:"parent_id,omitempty"`
+	SpawnDepth  int               `json:"spawn_depth"`
+	SpawnReason string            `json:"spawn_reason,omitempty"`
+	SpawnDetail string            `json:"spawn_detail,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Metrics     MetricsResponse   `json:"metrics"`
 }
 
 // ProcessDetailResponse includes conversation history.
@@ -39,6 +41,11 @@ type MetricsResponse struct {
 	ToolCalls    int       `json:"tool_calls"`
 	Errors       int       `json:"errors"`
 	LastActiveAt time.Time `json:"last_active_at,omitempty"`
+
+	// ContextTokens estimates the latest request; with ContextWindow it
+	// drives a context-usage gauge. ContextWindow is 0 for unknown models.
+	ContextTokens int `json:"context_tokens"`
+	ContextWindow int `json:"context_window"`
 }
 
 // AgentResponse is the API representation of an agent definition.
@@ -76,18 +83,34 @@ type InputResponse struct {
 
 // StatsResponse contains aggregate metrics.
 type StatsResponse struct {
-	TotalProcesses         int     `json:"total_processes"`
-	RunningProcesses       int     `json:"running_processes"`
-	CompletedProcesses     int     `json:"completed_processes"`
-	FailedProcesses        int     `json:"failed_processes"`
-	TotalInputTokens       int     `json:"total_input_tokens"`
-	TotalOutputTokens      int     `json:"total_output_tokens"`
-	TotalCacheCreationTokens int   `json:"total_cache_creation_tokens"`
-	TotalCacheReadTokens   int     `json:"total_cache_read_tokens"`
-	TotalCostUSD           float64 `json:"total_cost_usd"`
-	TotalToolCalls         int     `json:"total_tool_calls"`
-	TotalErrors            int     `json:"total_errors"`
-	Uptime                 string  `json:"uptime"`
+	ProcessTotals
+	Uptime string `json:"uptime"`
+
+	// GroupBy and Groups break the totals down when ?group_by= is given.
+	GroupBy string       `json:"group_by,omitempty"`
+	Groups  []StatsGroup `json:"groups,omitempty"`
+}
+
+// ProcessTotals sums the status counts and usage of a set of processes.
+type ProcessTotals struct {
+	TotalProcesses           int     `json:"total_processes"`
+	RunningProcesses         int     `json:"running_processes"`
+	CompletedProcesses       int     `json:"completed_processes"`
+	FailedProcesses          int     `json:"failed_processes"`
+	TotalInputTokens         int     `json:"total_input_tokens"`
+	TotalOutputTokens        int     `json:"total_output_tokens"`
+	TotalCacheCreationTokens int     `json:"total_cache_creation_tokens"`
+	TotalCacheReadTokens     int     `json:"total_cache_read_tokens"`
+	TotalCostUSD             float64 `json:"total_cost_usd"`
+	TotalToolCalls           int     `json:"total_tool_calls"`
+	TotalErrors              int     `json:"total_errors"`
+}
+
+// StatsGroup is the totals for processes sharing a label value. Value is
+// empty for processes without the label.
+type StatsGroup struct {
+	Value string `json:"value"`
+	ProcessTotals
 }
 
 // SpawnTreeNodeResponse is the API representation of a spawn tree node.
@@ -98,10 +121,51 @@ type SpawnTreeNodeResponse struct {
 	Status      string                   `json:"status"`
 	SpawnDepth  int                      `json:"spawn_depth"`
 	SpawnReason string                   `json:"spawn_reason,omitempty"`
+	SpawnDetail string                   `json:"spawn_detail,omitempty"`
 	StartedAt   time.Time                `json:"started_at"`
 	Children    []SpawnTreeNodeResponse  `json:"children,omitempty"`
 }
 
+// SupervisorResponse is the API representation of a supervisor's health.
+type SupervisorResponse struct {
+	ID          string                    `json:"id"`
+	Strategy    string                    `json:"strategy"`
+	MaxRestarts int                       `json:"max_restarts"`
+	Window      string                    `json:"window,omitempty"`
+	Restarts    int                       `json:"restarts"`
+	GaveUp      bool                      `json:"gave_up"`
+	Children    []SupervisedChildResponse `json:"children"`
+}
+
+// IncidentResponse is the API representation of a cascading failure.
+type IncidentResponse struct {
+	ID            string                    `json:"id"`
+	RootProcessID string                    `json:"root_process_id"`
+	RootAgent     string                    `json:"root_agent"`
+	RootError     string                    `json:"root_error,omitempty"`
+	Affected      []AffectedProcessResponse `json:"affected"`
+	StartedAt     time.Time                 `json:"started_at"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+}
+
+// AffectedProcessResponse is a process that died in an incident's cascade.
+type AffectedProcessResponse struct {
+	ProcessID string    `json:"process_id"`
+	Agent     string    `json:"agent"`
+	LinkedID  string    `json:"linked_id"`
+	DiedAt    time.Time `json:"died_at"`
+}
+
+// SupervisedChildResponse is the API representation of a supervised child.
+type SupervisedChildResponse struct {
+	Name      string `json:"name,omitempty"`
+	ProcessID string `json:"process_id"`
+	Agent     string `json:"agent"`
+	Status    string `json:"status"`
+	Restart   string `json:"restart"`
+	Restarts  int    `json:"restarts"`
+}
+
 // MCPServerResponse is the API representation of an MCP server.
 type MCPServerResponse struct {
 	Name      string   `json:"name"`
@@ -116,12 +180,17 @@ type MCPServerResponse struct {
 // WorkflowRunRequest is the request to launch a workflow.
 type WorkflowRunRequest struct {
 	Inputs map[string]any `json:"inputs"`
+	// IdempotencyKey, like the Idempotency-Key header, makes retries of
+	// the same request return the original run instead of starting another.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
-// WorkflowRunResponse is returned when a workflow is launched.
+// WorkflowRunResponse is returned when a workflow is launched. Result is
+// only set once the run has finished.
 type WorkflowRunResponse struct {
 	RunID  string `json:"run_id"`
 	Status string `json:"status"`
+	Result string `json:"result,omitempty"`
 }
 
 // BrokerEvent is an event sent via SSE.
@@ -162,17 +231,26 @@ type CompanySiblingResponse struct {
 }
 
 // AgentTemplateResponse is the API representation of a portable agent template.
+// Full exports (GET /api/agents/{name}/export) also carry the composed-agent
+// settings, chat history and memory layers so an agent can be moved between
+// instances with its state.
 type AgentTemplateResponse struct {
-	Version     string   `json:"version"`
-	Name        string   `json:"name"`
-	DisplayName string   `json:"display_name,omitempty"`
-	Title       string   `json:"title,omitempty"`
-	Model       string   `json:"model"`
-	System      string   `json:"system"`
-	Tools       []string `json:"tools,omitempty"`
-	Team        []string `json:"team,omitempty"`
-	ExportedBy  string   `json:"exported_by,omitempty"`
-	ExportedAt  string   `json:"exported_at,omitempty"`
+	Version     string        `json:"version"`
+	Name        string        `json:"name"`
+	DisplayName string        `json:"display_name,omitempty"`
+	Title       string        `json:"title,omitempty"`
+	Avatar      string        `json:"avatar,omitempty"`
+	Model       string        `json:"model"`
+	Persona     string        `json:"persona,omitempty"`
+	System      string        `json:"system"`
+	Skills      []string      `json:"skills,omitempty"`
+	Tools       []string      `json:"tools,omitempty"`
+	Team        []string      `json:"team,omitempty"`
+	Temperature *float64      `json:"temperature,omitempty"`
+	Chat        []ChatMessage `json:"chat,omitempty"`
+	Memory      []UserMemory  `json:"memory,omitempty"`
+	ExportedBy  string        `json:"exported_by,omitempty"`
+	ExportedAt  string        `json:"exported_at,omitempty"`
 }
 
 // --- Channel Types ---
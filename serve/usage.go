@@ -0,0 +1,60 @@
+package serve
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	vega "github.com/everydev1618/govega"
+)
+
+// usageRecorder returns a vega.UsageFunc that writes each LLM call made
+// while serving userID's chat with agent to the cost ledger. Calls by
+// agents the chat delegates to are billed to the same user and agent.
+// Writes happen in the background so they never slow the chat.
+func (s *Server) usageRecorder(userID, agent string) vega.UsageFunc {
+	return func(p *vega.Process, m vega.CallMetrics) {
+		if m.CostUSD == 0 && m.InputTokens == 0 && m.OutputTokens == 0 {
+			return
+		}
+		e := CostEntry{
+			UserID:       userID,
+			Agent:        agent,
+			Model:        m.Model,
+			InputTokens:  m.InputTokens,
+			OutputTokens: m.OutputTokens,
+			CostUSD:      m.CostUSD,
+		}
+		go func() {
+			if err := s.store.InsertCostEntry(e); err != nil {
+				slog.Error("failed to record LLM cost", "user", userID, "agent", agent, "error", err)
+			}
+		}()
+	}
+}
+
+// handleUsage totals the cost ledger, optionally filtered by ?user= and
+// ?agent=. ?since= takes an RFC 3339 time or a YYYY-MM-DD date.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var since time.Time
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			t, err = time.Parse(time.DateOnly, v)
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "since must be an RFC 3339 time or a YYYY-MM-DD date"})
+			return
+		}
+		since = t
+	}
+
+	usage, err := s.store.Usage(q.Get("user"), q.Get("agent"), since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
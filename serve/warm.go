@@ -0,0 +1,18 @@
+package serve
+
+import "log/slog"
+
+// warmAgents spawns and hydrates the agents listed in Config.WarmAgents so
+// their first chat doesn't pay for either. An agent that fails to spawn is
+// logged and skipped; it doesn't stop the server from starting.
+func (s *Server) warmAgents() {
+	for _, name := range s.cfg.WarmAgents {
+		proc, err := s.interp.EnsureAgent(name)
+		if err != nil {
+			slog.Warn("failed to warm agent", "agent", name, "error", err)
+			continue
+		}
+		s.hydrateAgent(proc, name)
+		slog.Info("warmed agent", "agent", name, "messages", len(proc.Messages()))
+	}
+}
@@ -0,0 +1,41 @@
+package serve
+
+import (
+	"testing"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+func TestWarmAgents(t *testing.T) {
+	doc := &dsl.Document{Agents: map[string]*dsl.Agent{
+		"support": {Name: "support", Model: "test-model", System: "You help."},
+		"billing": {Name: "billing", Model: "test-model", System: "You bill."},
+		"archive": {Name: "archive", Model: "test-model", System: "You file."},
+	}}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(stepLLM{}), dsl.WithLazySpawn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	s := New(interp, Config{WarmAgents: []string{"support", "nobody", "billing"}})
+	s.store = newTestStore(t)
+	s.sqliteStore = s.store.(*SQLiteStore)
+	s.store.InsertChatMessage("support", "user", "my order is late")
+	s.store.InsertChatMessage("support", "assistant", "sorry, checking")
+
+	s.warmAgents()
+
+	agents := interp.Agents()
+	for _, name := range []string{"support", "billing"} {
+		if agents[name] == nil {
+			t.Errorf("%s was not spawned at startup", name)
+		}
+	}
+	if agents["archive"] != nil {
+		t.Error("archive was spawned but is not listed; it should stay lazy")
+	}
+	if msgs := agents["support"].Messages(); len(msgs) != 2 || msgs[0].Content != "my order is late" {
+		t.Errorf("support history = %+v, want it hydrated from the store", msgs)
+	}
+}
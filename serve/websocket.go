@@ -0,0 +1,243 @@
+package serve
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of the WebSocket protocol (RFC 6455), as much as chat
+// streaming needs: text messages, fragmentation, ping/pong and close. No
+// extensions or subprotocols are negotiated.
+
+// wsAcceptGUID is appended to the client's key to compute the handshake
+// accept value.
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes.
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	// wsMaxMessageSize caps a client message, across all its fragments.
+	wsMaxMessageSize = 1 << 20
+	// wsWriteTimeout bounds a single frame write.
+	wsWriteTimeout = 10 * time.Second
+)
+
+// errWSMessageTooLarge is returned when a client message exceeds
+// wsMaxMessageSize.
+var errWSMessageTooLarge = errors.New("websocket message too large")
+
+// wsConn is a server-side WebSocket connection. Writes may come from
+// several goroutines; reads must come from one.
+type wsConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	writeMu sync.Mutex
+}
+
+// upgradeWebSocket completes the WebSocket handshake for r and takes over
+// the connection. On failure it has already written an HTTP error.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerHasToken(r.Header, "Connection", "upgrade") ||
+		!headerHasToken(r.Header, "Upgrade", "websocket") ||
+		key == "" {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: "websocket upgrade required"})
+		return nil, errors.New("not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeJSON(w, http.StatusUpgradeRequired, ErrorResponse{Error: "unsupported websocket version"})
+		return nil, errors.New("unsupported websocket version")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "websocket not supported"})
+		return nil, errors.New("response writer cannot be hijacked")
+	}
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsAcceptGUID))
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, br: brw.Reader}, nil
+}
+
+// headerHasToken reports whether the comma-separated header contains
+// token, ignoring case.
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped along the way. Every frame, pongs included, extends the
+// read deadline by idle, so a client answering pings is never timed out.
+// A close frame is echoed and reported as io.EOF.
+func (c *wsConn) ReadMessage(idle time.Duration) (opcode byte, data []byte, err error) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(idle))
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			c.writeFrame(wsOpClose, payload)
+			return 0, nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if opcode != 0 {
+				return 0, nil, errors.New("websocket: new message inside a fragmented one")
+			}
+			opcode = op
+		case wsOpContinuation:
+			if opcode == 0 {
+				return 0, nil, errors.New("websocket: continuation without a message")
+			}
+		default:
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %#x", op)
+		}
+
+		if len(data)+len(payload) > wsMaxMessageSize {
+			return 0, nil, errWSMessageTooLarge
+		}
+		data = append(data, payload...)
+		if fin {
+			return opcode, data, nil
+		}
+	}
+}
+
+// readFrame reads one frame. Client frames must be masked.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin = head[0]&0x80 != 0
+	opcode = head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("websocket: unmasked client frame")
+	}
+
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxMessageSize {
+		return false, 0, nil, errWSMessageTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unfragmented, unmasked frame.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	head := make([]byte, 0, 10)
+	head = append(head, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		head = append(head, byte(n))
+	case n <= 0xFFFF:
+		head = append(head, 126)
+		head = binary.BigEndian.AppendUint16(head, uint16(n))
+	default:
+		head = append(head, 127)
+		head = binary.BigEndian.AppendUint64(head, uint64(n))
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.conn.Write(head); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// WriteJSON sends v as a text message.
+func (c *wsConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// Ping sends a ping; the client's pong extends the read deadline.
+func (c *wsConn) Ping() error {
+	return c.writeFrame(wsOpPing, nil)
+}
+
+// Close sends a normal-closure frame and closes the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(wsOpClose, []byte{0x03, 0xE8}) // 1000: normal closure
+	return c.conn.Close()
+}
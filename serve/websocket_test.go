@@ -0,0 +1,204 @@
+package serve
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/dsl"
+	"github.com/everydev1618/govega/llm"
+)
+
+// wsTestClient is the client end of a WebSocket, for tests.
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+func dialWS(t *testing.T, srv *httptest.Server, path string) *wsTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", path, base64.StdEncoding.EncodeToString(key))
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status %d, want 101", resp.StatusCode)
+	}
+	return &wsTestClient{t: t, conn: conn, br: br}
+}
+
+// write sends one masked frame.
+func (c *wsTestClient) write(opcode byte, payload []byte) {
+	c.t.Helper()
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsTestClient) send(frame string) {
+	c.write(wsOpText, []byte(frame))
+}
+
+// read returns the next frame from the server.
+func (c *wsTestClient) read() (byte, []byte) {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		c.t.Fatal(err)
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+// events reads chat events up to and including done.
+func (c *wsTestClient) events() []vega.ChatEvent {
+	c.t.Helper()
+	var events []vega.ChatEvent
+	for {
+		op, data := c.read()
+		if op != wsOpText {
+			continue
+		}
+		var event vega.ChatEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			c.t.Fatal(err)
+		}
+		events = append(events, event)
+		if event.Type == vega.ChatEventDone {
+			return events
+		}
+	}
+}
+
+func newWSTestServer(t *testing.T, backend llm.LLM) (*Server, *httptest.Server) {
+	t.Helper()
+	doc := &dsl.Document{Agents: map[string]*dsl.Agent{
+		"writer": {Name: "writer", Model: "test-model", System: "You write."},
+	}}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(backend))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(interp.Shutdown)
+	s := New(interp, Config{})
+	s.store = newTestStore(t)
+	s.sqliteStore = s.store.(*SQLiteStore)
+
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+func TestChatWebSocket(t *testing.T) {
+	_, srv := newWSTestServer(t, stepLLM{})
+	c := dialWS(t, srv, "/api/agents/writer/chat/ws")
+
+	for _, msg := range []string{"first", "second"} {
+		c.send(`{"type":"message","message":"` + msg + `"}`)
+		events := c.events()
+		var text strings.Builder
+		for _, e := range events {
+			text.WriteString(e.Delta)
+		}
+		if got := text.String(); got != "done: "+msg {
+			t.Errorf("streamed %q, want %q", got, "done: "+msg)
+		}
+	}
+
+	t.Run("answers pings", func(t *testing.T) {
+		c.write(wsOpPing, []byte("hi"))
+		if op, data := c.read(); op != wsOpPong || string(data) != "hi" {
+			t.Errorf("got opcode %#x %q, want pong", op, data)
+		}
+	})
+
+	t.Run("rejects plain requests", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/api/agents/writer/chat/ws")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("status %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestChatWebSocketStop(t *testing.T) {
+	s, srv := newWSTestServer(t, hangingLLM{})
+	c := dialWS(t, srv, "/api/agents/writer/chat/ws")
+
+	c.send(`{"message":"write"}`)
+	op, data := c.read()
+	if op != wsOpText || !strings.Contains(string(data), "partial answer") {
+		t.Fatalf("first frame = %#x %s, want the partial answer", op, data)
+	}
+
+	c.send(`{"type":"interrupt"}`)
+	events := c.events()
+	if last := events[len(events)-1]; last.Type != vega.ChatEventDone {
+		t.Errorf("last event = %+v, want done", last)
+	}
+
+	var msgs []ChatMessage
+	for deadline := time.Now().Add(5 * time.Second); len(msgs) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("persisted %d messages, want user + assistant", len(msgs))
+		}
+		time.Sleep(5 * time.Millisecond)
+		var err error
+		if msgs, err = s.store.ListChatMessages("writer"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := msgs[1].Content; got != "partial answer\n\n"+interruptedNote {
+		t.Errorf("assistant message = %q, want partial response marked interrupted", got)
+	}
+}
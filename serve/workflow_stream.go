@@ -0,0 +1,225 @@
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/everydev1618/govega/dsl"
+)
+
+// workflowRunStream tracks the step events of a running workflow so SSE
+// clients can follow its progress. Events are buffered in history so a
+// client that connects after launch still sees every step.
+type workflowRunStream struct {
+	runID    string
+	workflow string
+	done     chan struct{} // closed when the run completes
+
+	mu          sync.Mutex
+	history     []dsl.StepEvent
+	subscribers []chan dsl.StepEvent
+	status      string // set after done
+	result      string // set after done
+}
+
+// publish sends a step event to all subscribers and appends it to history.
+func (ws *workflowRunStream) publish(event dsl.StepEvent) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.history = append(ws.history, event)
+	for _, ch := range ws.subscribers {
+		select {
+		case ch <- event:
+		default: // subscriber too slow, skip
+		}
+	}
+}
+
+// subscribe returns a snapshot of past events plus a channel for future
+// events. The channel is closed when the run finishes.
+func (ws *workflowRunStream) subscribe() ([]dsl.StepEvent, chan dsl.StepEvent) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	snapshot := make([]dsl.StepEvent, len(ws.history))
+	copy(snapshot, ws.history)
+	ch := make(chan dsl.StepEvent, 256)
+	select {
+	case <-ws.done:
+		close(ch)
+	default:
+		ws.subscribers = append(ws.subscribers, ch)
+	}
+	return snapshot, ch
+}
+
+// unsubscribe removes a subscriber channel.
+func (ws *workflowRunStream) unsubscribe(ch chan dsl.StepEvent) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	for idx, sub := range ws.subscribers {
+		if sub == ch {
+			ws.subscribers = append(ws.subscribers[:idx], ws.subscribers[idx+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// finish records the outcome and closes all subscriber channels.
+func (ws *workflowRunStream) finish(status, result string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.status = status
+	ws.result = result
+	close(ws.done)
+	for _, ch := range ws.subscribers {
+		close(ch)
+	}
+	ws.subscribers = nil
+}
+
+// publishStepEvent routes an interpreter step event to its run's stream.
+func (s *Server) publishStepEvent(event dsl.StepEvent) {
+	if event.RunID == "" {
+		return
+	}
+	s.runsMu.Lock()
+	ws := s.runs[event.RunID]
+	s.runsMu.Unlock()
+	if ws != nil {
+		ws.publish(event)
+	}
+}
+
+// recordWorkflowStep persists the top-level steps of server-launched runs
+// as they start and finish, so a run that crashes or is cancelled keeps the
+// results of the steps it completed. Sub-workflows share their parent's run
+// ID but not its workflow name; their steps are not recorded.
+func (s *Server) recordWorkflowStep(event dsl.StepEvent) {
+	if event.RunID == "" || event.Depth != 0 {
+		return
+	}
+	s.runsMu.Lock()
+	ws := s.runs[event.RunID]
+	s.runsMu.Unlock()
+	if ws == nil || ws.workflow != event.Workflow {
+		return
+	}
+
+	if err := s.store.UpsertWorkflowStep(WorkflowStep{
+		RunID:  event.RunID,
+		Index:  event.Index,
+		Type:   event.Type,
+		Agent:  event.Agent,
+		Save:   event.Save,
+		Status: string(event.Status),
+		Result: stepResultString(event.Result),
+		Error:  event.Error,
+	}); err != nil {
+		slog.Warn("failed to record workflow step", "run_id", event.RunID, "step", event.Index, "error", err)
+	}
+}
+
+// stepResultString renders a step result for storage: strings as they are,
+// anything else as JSON.
+func stepResultString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+}
+
+// handleListWorkflowSteps returns the recorded steps of a workflow run,
+// including runs that crashed or were cancelled part-way.
+func (s *Server) handleListWorkflowSteps(w http.ResponseWriter, r *http.Request) {
+	steps, err := s.store.ListWorkflowSteps(r.PathValue("run_id"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: err.Error()})
+		return
+	}
+	if steps == nil {
+		steps = []WorkflowStep{}
+	}
+	writeJSON(w, http.StatusOK, steps)
+}
+
+// handleWorkflowRunStream streams per-step progress of a workflow run as
+// SSE. Past steps are replayed first; a final "done" event carries the
+// run's status and result.
+func (s *Server) handleWorkflowRunStream(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	runID := r.PathValue("run_id")
+
+	s.runsMu.Lock()
+	ws := s.runs[runID]
+	s.runsMu.Unlock()
+	if ws == nil || ws.workflow != name {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: fmt.Sprintf("run '%s' not found", runID)})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "streaming not supported"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	history, ch := ws.subscribe()
+	defer ws.unsubscribe(ch)
+
+	for _, event := range history {
+		writeStepSSE(w, event)
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				ws.mu.Lock()
+				done := WorkflowRunResponse{RunID: runID, Status: ws.status, Result: ws.result}
+				ws.mu.Unlock()
+				data, _ := json.Marshal(done)
+				fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+				flusher.Flush()
+				return
+			}
+			writeStepSSE(w, event)
+			flusher.Flush()
+		case <-ticker.C:
+			fmt.Fprintf(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeStepSSE writes a single step event in SSE framing.
+func writeStepSSE(w http.ResponseWriter, event dsl.StepEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: step\ndata: %s\n\n", data)
+}
@@ -0,0 +1,264 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/everydev1618/govega/dsl"
+	"github.com/everydev1618/govega/llm"
+)
+
+// stepLLM answers with its last prompt and fails on prompts containing
+// "crash".
+type stepLLM struct{}
+
+func (stepLLM) Generate(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (*llm.LLMResponse, error) {
+	last := messages[len(messages)-1].Content
+	if strings.Contains(last, "crash") {
+		return nil, errors.New("model crashed")
+	}
+	return &llm.LLMResponse{Content: "done: " + last}, nil
+}
+
+func (l stepLLM) GenerateStream(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (<-chan llm.StreamEvent, error) {
+	ch := make(chan llm.StreamEvent, 1)
+	resp, err := l.Generate(ctx, messages, tools)
+	if err != nil {
+		ch <- llm.StreamEvent{Error: err}
+	} else {
+		ch <- llm.StreamEvent{Type: llm.StreamEventContentDelta, Delta: resp.Content}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func (l stepLLM) CountTokens(ctx context.Context, messages []llm.Message, tools []llm.ToolSchema) (int, error) {
+	return llm.EstimateTokens(messages, tools), nil
+}
+
+func TestUpsertWorkflowStep(t *testing.T) {
+	store := newTestStore(t)
+
+	for _, st := range []WorkflowStep{
+		{RunID: "r1", Index: 1, Type: "agent", Status: "started"},
+		{RunID: "r1", Index: 0, Type: "agent", Status: "completed", Result: "first"},
+		{RunID: "r2", Index: 0, Type: "agent", Status: "started"},
+		{RunID: "r1", Index: 1, Type: "agent", Status: "failed", Error: "boom"},
+	} {
+		if err := store.UpsertWorkflowStep(st); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps, err := store.ListWorkflowSteps("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 {
+		t.Fatalf("got %d steps, want 2: %+v", len(steps), steps)
+	}
+	if steps[0].Index != 0 || steps[0].Result != "first" {
+		t.Errorf("steps[0] = %+v", steps[0])
+	}
+	if steps[1].Status != "failed" || steps[1].Error != "boom" {
+		t.Errorf("steps[1] = %+v, want the later failed state", steps[1])
+	}
+}
+
+func TestWorkflowStepsPersistedAsRunProgresses(t *testing.T) {
+	doc, err := dsl.NewParser().Parse([]byte(`
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  pipeline:
+    steps:
+      - writer:
+          send: "outline"
+          save: outline
+      - set:
+          count: 3
+      - writer:
+          send: "draft from {{outline}}"
+          save: draft
+      - writer: "crash now"
+      - writer: "never reached"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(stepLLM{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	s := New(interp, Config{})
+	s.store = newTestStore(t)
+	s.sqliteStore = s.store.(*SQLiteStore)
+	s.interp.OnStep(s.recordWorkflowStep)
+
+	// When each step starts, the steps before it must already be stored.
+	var mu sync.Mutex
+	var persistedAtStart []int
+	s.interp.OnStep(func(ev dsl.StepEvent) {
+		if ev.Depth != 0 || ev.Status != dsl.StepStarted {
+			return
+		}
+		steps, _ := s.store.ListWorkflowSteps(ev.RunID)
+		done := 0
+		for _, st := range steps {
+			if st.Status == string(dsl.StepCompleted) {
+				done++
+			}
+		}
+		mu.Lock()
+		persistedAtStart = append(persistedAtStart, done)
+		mu.Unlock()
+	})
+
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/workflows/pipeline/run", strings.NewReader(`{"inputs":{}}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("run: status %d: %s", w.Code, w.Body.String())
+	}
+	var run WorkflowRunResponse
+	json.Unmarshal(w.Body.Bytes(), &run)
+
+	s.runsMu.Lock()
+	ws := s.runs[run.RunID]
+	s.runsMu.Unlock()
+	select {
+	case <-ws.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workflow did not finish")
+	}
+	if ws.status != "failed" {
+		t.Fatalf("run status = %q, want failed", ws.status)
+	}
+
+	mu.Lock()
+	if got := persistedAtStart; len(got) != 4 || got[0] != 0 || got[1] != 1 || got[2] != 2 || got[3] != 3 {
+		t.Errorf("completed steps stored when each step started = %v, want [0 1 2 3]", got)
+	}
+	mu.Unlock()
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/workflows/runs/"+run.RunID+"/steps", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("steps: status %d: %s", w.Code, w.Body.String())
+	}
+	var steps []WorkflowStep
+	if err := json.Unmarshal(w.Body.Bytes(), &steps); err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 4 {
+		t.Fatalf("got %d steps, want 4 (the step after the crash never ran): %+v", len(steps), steps)
+	}
+	if steps[0].Status != "completed" || steps[0].Save != "outline" || steps[0].Result != "done: outline" {
+		t.Errorf("steps[0] = %+v", steps[0])
+	}
+	if steps[1].Type != "set" || steps[1].Status != "completed" {
+		t.Errorf("steps[1] = %+v", steps[1])
+	}
+	if steps[2].Result != "done: draft from done: outline" {
+		t.Errorf("steps[2].Result = %q", steps[2].Result)
+	}
+	if steps[3].Status != "failed" || !strings.Contains(steps[3].Error, "model crashed") {
+		t.Errorf("steps[3] = %+v, want failed with the model error", steps[3])
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/workflows/runs/nope/steps", nil))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("unknown run: status %d body %q, want empty list", w.Code, w.Body.String())
+	}
+}
+
+func TestRunWorkflowIdempotencyKey(t *testing.T) {
+	doc, err := dsl.NewParser().Parse([]byte(`
+name: Test
+agents:
+  writer:
+    model: test-model
+    system: You write.
+workflows:
+  pipeline:
+    steps:
+      - writer: "outline"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := dsl.NewInterpreter(doc, dsl.WithLLM(stepLLM{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+
+	s := New(interp, Config{})
+	store := newTestStore(t)
+	s.store = store
+	s.sqliteStore = store
+
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+	post := func(key, body string) (*httptest.ResponseRecorder, WorkflowRunResponse) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/workflows/pipeline/run", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var run WorkflowRunResponse
+		json.Unmarshal(w.Body.Bytes(), &run)
+		return w, run
+	}
+
+	w1, first := post("retry-me", `{"inputs":{}}`)
+	if w1.Code != http.StatusAccepted {
+		t.Fatalf("first run: status %d: %s", w1.Code, w1.Body.String())
+	}
+	w2, second := post("retry-me", `{"inputs":{}}`)
+	if w2.Code != http.StatusOK || w2.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: status %d, replayed %q; want 200 replayed", w2.Code, w2.Header().Get("Idempotent-Replayed"))
+	}
+	if second.RunID != first.RunID {
+		t.Errorf("retry started run %s, want the original %s", second.RunID, first.RunID)
+	}
+
+	s.runsMu.Lock()
+	ws := s.runs[first.RunID]
+	s.runsMu.Unlock()
+	select {
+	case <-ws.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workflow did not finish")
+	}
+
+	// The key also works as a body field, and reports the finished run.
+	_, third := post("", `{"inputs":{},"idempotency_key":"retry-me"}`)
+	if third.RunID != first.RunID || third.Status != "completed" {
+		t.Errorf("body-key retry = %+v, want completed run %s", third, first.RunID)
+	}
+	if n, _ := store.CountTable("workflow_runs"); n != 1 {
+		t.Errorf("workflow_runs has %d rows, want 1", n)
+	}
+
+	if _, other := post("another", `{"inputs":{}}`); other.RunID == first.RunID {
+		t.Error("a different key reused the run")
+	}
+}
@@ -0,0 +1,137 @@
+package serve
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/dsl"
+	"github.com/everydev1618/govega/tools"
+)
+
+// RegisterWorkspaceTools registers list_workspace_files and
+// read_workspace_file on the interpreter's global tool collection. Both
+// are scoped to the calling agent: it only sees the files it recorded
+// writing with write_file or append_file.
+func RegisterWorkspaceTools(interp *dsl.Interpreter, store Store) {
+	t := interp.Tools()
+
+	t.Register("list_workspace_files", tools.ToolDef{
+		Description: "List the files you have written in the workspace, newest first, with the description you gave each write. Use it to pick up work on files you created earlier.",
+		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			agent, err := workspaceAgent(ctx)
+			if err != nil {
+				return "", err
+			}
+			files, err := agentWorkspaceFiles(store, agent)
+			if err != nil {
+				return "", err
+			}
+			if len(files) == 0 {
+				return "You have not written any files yet.", nil
+			}
+
+			var b strings.Builder
+			for _, f := range files {
+				fmt.Fprintf(&b, "- %s (%s %s)", f.Path, f.Operation, f.CreatedAt.Format("2006-01-02 15:04"))
+				if f.Description != "" {
+					fmt.Fprintf(&b, ": %s", f.Description)
+				}
+				b.WriteString("\n")
+			}
+			return b.String(), nil
+		}),
+	})
+
+	t.Register("read_workspace_file", tools.ToolDef{
+		Description: "Read back a file you wrote earlier, by the path list_workspace_files shows.",
+		Fn: tools.ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			agent, err := workspaceAgent(ctx)
+			if err != nil {
+				return "", err
+			}
+			path, _ := params["path"].(string)
+			if path == "" {
+				return "", fmt.Errorf("path is required")
+			}
+
+			files, err := agentWorkspaceFiles(store, agent)
+			if err != nil {
+				return "", err
+			}
+			for _, f := range files {
+				if f.Path == path {
+					data, err := os.ReadFile(path)
+					if err != nil {
+						return "", fmt.Errorf("read %s: %w", path, err)
+					}
+					return string(data), nil
+				}
+			}
+			return "", fmt.Errorf("%s is not a file you wrote; call list_workspace_files to see yours", path)
+		}),
+		Params: map[string]tools.ParamDef{
+			"path": {
+				Type:        "string",
+				Description: "Path of the file, as listed by list_workspace_files",
+				Required:    true,
+			},
+		},
+		Access: tools.AccessRead,
+	})
+}
+
+// fileWriteRecorder returns a tools OnFileWrite callback that records each
+// write in the store under the writing agent and process.
+func fileWriteRecorder(store Store) func(ctx context.Context, path, operation, description string) {
+	return func(ctx context.Context, path, operation, description string) {
+		agentName := ""
+		processID := ""
+		if proc := vega.ProcessFromContext(ctx); proc != nil {
+			processID = proc.ID
+			if proc.Agent != nil {
+				agentName = proc.Agent.Name
+			}
+		}
+		if err := store.InsertWorkspaceFile(WorkspaceFile{
+			Path:        path,
+			Agent:       agentName,
+			ProcessID:   processID,
+			Operation:   operation,
+			Description: description,
+		}); err != nil {
+			slog.Error("failed to record workspace file", "path", path, "error", err)
+		}
+	}
+}
+
+// workspaceAgent returns the name of the agent making a tool call, which
+// is what file writes are recorded under.
+func workspaceAgent(ctx context.Context) (string, error) {
+	proc := vega.ProcessFromContext(ctx)
+	if proc == nil || proc.Agent == nil || proc.Agent.Name == "" {
+		return "", fmt.Errorf("workspace files are only available to agents")
+	}
+	return proc.Agent.Name, nil
+}
+
+// agentWorkspaceFiles returns the latest record of each file agent wrote,
+// newest first.
+func agentWorkspaceFiles(store Store, agent string) ([]WorkspaceFile, error) {
+	records, err := store.ListWorkspaceFiles(agent)
+	if err != nil {
+		return nil, fmt.Errorf("list workspace files: %w", err)
+	}
+	seen := make(map[string]bool, len(records))
+	var files []WorkspaceFile
+	for _, f := range records {
+		if !seen[f.Path] {
+			seen[f.Path] = true
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
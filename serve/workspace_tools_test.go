@@ -0,0 +1,67 @@
+package serve
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	vega "github.com/everydev1618/govega"
+	"github.com/everydev1618/govega/dsl"
+)
+
+func TestWorkspaceTools(t *testing.T) {
+	store := newTestStore(t)
+	sandbox := t.TempDir()
+	interp, err := dsl.NewInterpreter(&dsl.Document{
+		Agents:   map[string]*dsl.Agent{},
+		Settings: &dsl.Settings{Sandbox: sandbox},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer interp.Shutdown()
+	RegisterWorkspaceTools(interp, store)
+	tl := interp.Tools()
+	tl.OnFileWrite = fileWriteRecorder(store)
+
+	asAgent := func(name string) context.Context {
+		p, err := interp.Orchestrator().Spawn(vega.Agent{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vega.ContextWithProcess(context.Background(), p)
+	}
+	writer, editor := asAgent("writer"), asAgent("editor")
+
+	path := filepath.Join(sandbox, "draft.md")
+	if _, err := tl.Execute(writer, "write_file", map[string]any{"path": path, "content": "# Draft\n", "description": "first draft"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tl.Execute(writer, "append_file", map[string]any{"path": path, "content": "More.\n"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tl.Execute(writer, "list_workspace_files", map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(list, path) != 1 {
+		t.Errorf("list = %q, want %s listed once", list, path)
+	}
+	got, err := tl.Execute(writer, "read_workspace_file", map[string]any{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "# Draft\nMore.\n" {
+		t.Errorf("read back %q", got)
+	}
+
+	// Another agent sees neither the listing nor the file.
+	if list, _ := tl.Execute(editor, "list_workspace_files", map[string]any{}); strings.Contains(list, path) {
+		t.Errorf("editor's list = %q, want only its own files", list)
+	}
+	if _, err := tl.Execute(editor, "read_workspace_file", map[string]any{"path": path}); err == nil {
+		t.Error("editor read the writer's file")
+	}
+}
@@ -1,10 +1,13 @@
 package vega
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/everydev1618/govega/internal/skills"
+	"github.com/everydev1618/govega/llm"
 )
 
 // SkillMatch is a type alias for skills.SkillMatch, keeping it in the public API.
@@ -15,10 +18,23 @@ type SkillsPrompt struct {
 	base      SystemPrompt
 	loader    *skills.Loader
 	maxActive int
+	relevant  bool   // rank all skills by relevance instead of triggers alone
 	context   string // Last message for matching
 	mu        sync.RWMutex
+
+	// cache keeps rendered skills blocks by the skills they contain, for
+	// the skill-set version and filters in cacheKey. When enabled, the
+	// block also follows a cache breakpoint, so the base prompt stays
+	// cached by the provider when the active skills change.
+	cache       bool
+	cacheKey    string
+	cacheBlocks map[string]string
 }
 
+// maxCachedSkillBlocks bounds the rendered skills blocks a SkillsPrompt
+// keeps; past it the cache starts over.
+const maxCachedSkillBlocks = 64
+
 // SkillsPromptOption configures a SkillsPrompt.
 type SkillsPromptOption func(*SkillsPrompt)
 
@@ -28,6 +44,7 @@ func NewSkillsPrompt(base SystemPrompt, loader *skills.Loader, opts ...SkillsPro
 		base:      base,
 		loader:    loader,
 		maxActive: 3, // Default max skills
+		cache:     true,
 	}
 
 	for _, opt := range opts {
@@ -44,39 +61,93 @@ func WithMaxActiveSkills(n int) SkillsPromptOption {
 	}
 }
 
+// WithRelevantSkills selects skills by relevance to the latest user
+// message instead of by triggers alone. Every skill is scored on how well
+// its name, description and tags match the message's words, with trigger
+// matches still counting, and the best maxActive are injected. It keeps
+// the prompt focused when an agent has many skills that lack triggers.
+func WithRelevantSkills(enabled bool) SkillsPromptOption {
+	return func(sp *SkillsPrompt) {
+		sp.relevant = enabled
+	}
+}
+
+// WithSkillsPromptCache turns caching of the rendered skills block on or
+// off. It is on by default. Turning it off also drops the cache breakpoint
+// before the block; a process strips the breakpoint anyway when its
+// backend is not an llm.PromptCacher.
+func WithSkillsPromptCache(enabled bool) SkillsPromptOption {
+	return func(sp *SkillsPrompt) {
+		sp.cache = enabled
+	}
+}
+
 // Prompt generates the system prompt with injected skills.
 func (s *SkillsPrompt) Prompt() string {
-	// Get base prompt
-	prompt := s.base.Prompt()
+	return s.withSkills(s.base.Prompt())
+}
 
-	// Get context for matching
-	s.mu.RLock()
-	context := s.context
-	s.mu.RUnlock()
+// PromptContext is Prompt with the base prompt built for the call made
+// with ctx, when the base is a ContextPrompt.
+func (s *SkillsPrompt) PromptContext(ctx context.Context) string {
+	return s.withSkills(promptFor(ctx, s.base))
+}
 
-	if context == "" || s.loader == nil {
+// withSkills appends the matched skills to prompt.
+func (s *SkillsPrompt) withSkills(prompt string) string {
+	matches := s.GetMatchedSkills()
+	if len(matches) == 0 {
 		return prompt
 	}
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = match.Skill.Name
+	}
 
-	// Find matching skills
-	matches := s.loader.Match(context)
-
-	if len(matches) == 0 {
-		return prompt
+	if !s.cache {
+		return prompt + "\n\n" + s.renderSkills(names)
 	}
+	return prompt + llm.CacheBreakpoint + s.cachedSkills(names)
+}
 
-	// Build skills section
-	var builder strings.Builder
-	builder.WriteString(prompt)
-	builder.WriteString("\n\n# Active Skills\n")
+// cachedSkills returns the rendered skills block for names, rendering it
+// only if the skill set, the loader's filters or the names have changed.
+func (s *SkillsPrompt) cachedSkills(names []string) string {
+	include, exclude := s.loader.Filters()
+	key := fmt.Sprintf("%d|%q|%q", s.loader.Version(), include, exclude)
+	set := strings.Join(names, "\x00")
 
-	for i, match := range matches {
-		if i >= s.maxActive {
-			break
+	s.mu.Lock()
+	if s.cacheKey != key {
+		s.cacheKey = key
+		s.cacheBlocks = make(map[string]string)
+	}
+	block, ok := s.cacheBlocks[set]
+	s.mu.Unlock()
+	if ok {
+		return block
+	}
+
+	block = s.renderSkills(names)
+	s.mu.Lock()
+	if s.cacheKey == key {
+		if len(s.cacheBlocks) >= maxCachedSkillBlocks {
+			s.cacheBlocks = make(map[string]string)
 		}
+		s.cacheBlocks[set] = block
+	}
+	s.mu.Unlock()
+	return block
+}
 
+// renderSkills renders the Active Skills section for the named skills.
+func (s *SkillsPrompt) renderSkills(names []string) string {
+	var builder strings.Builder
+	builder.WriteString("# Active Skills\n")
+
+	for _, name := range names {
 		// Load full skill content
-		skill, err := s.loader.Get(match.Skill.Name)
+		skill, err := s.loader.Get(name)
 		if err != nil {
 			continue
 		}
@@ -109,7 +180,12 @@ func (s *SkillsPrompt) GetMatchedSkills() []skills.SkillMatch {
 		return nil
 	}
 
-	matches := s.loader.Match(context)
+	var matches []skills.SkillMatch
+	if s.relevant {
+		matches = s.loader.Rank(context)
+	} else {
+		matches = s.loader.Match(context)
+	}
 
 	if len(matches) > s.maxActive {
 		matches = matches[:s.maxActive]
@@ -144,6 +220,10 @@ type SkillsConfig struct {
 
 	// MaxActive is the maximum number of skills to inject.
 	MaxActive int
+
+	// SelectByRelevance picks the skills most relevant to each message,
+	// as WithRelevantSkills does.
+	SelectByRelevance bool
 }
 
 // SkillsPromptFromConfig creates a SkillsPrompt from configuration.
@@ -155,6 +235,9 @@ func SkillsPromptFromConfig(base SystemPrompt, config SkillsConfig) (*SkillsProm
 	if config.MaxActive > 0 {
 		opts = append(opts, WithMaxActiveSkills(config.MaxActive))
 	}
+	if config.SelectByRelevance {
+		opts = append(opts, WithRelevantSkills(true))
+	}
 
 	return NewSkillsPrompt(base, loader, opts...), nil
 }
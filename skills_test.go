@@ -0,0 +1,142 @@
+package vega
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/everydev1618/govega/internal/skills"
+	"github.com/everydev1618/govega/llm"
+)
+
+func TestSkillsPromptCache(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"pdf", "sql"} {
+		content := "---\nname: " + name + "\ndescription: " + name + " help\ntriggers:\n  - type: always\n---\nUse " + name + " well.\n"
+		if err := os.WriteFile(filepath.Join(dir, name+".skill.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader := skills.NewLoader(dir)
+	if err := loader.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sp := NewSkillsPrompt(StaticPrompt("Base."), loader)
+	sp.SetContext("hello")
+	first := sp.Prompt()
+	if !strings.HasPrefix(first, "Base."+llm.CacheBreakpoint+"# Active Skills") ||
+		!strings.Contains(first, "## pdf") || !strings.Contains(first, "## sql") {
+		t.Fatalf("prompt = %q, want the base, a breakpoint, then both skills", first)
+	}
+
+	// The rendered block is reused while the skill set is unchanged.
+	pdf, err := loader.Get("pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf.Instructions = "Edited."
+	if got := sp.Prompt(); got != first {
+		t.Errorf("prompt re-rendered without a skill-set change:\n%s", got)
+	}
+
+	// Changing the filters invalidates it.
+	loader.SetFilters(nil, []string{"sql"})
+	got := sp.Prompt()
+	if strings.Contains(got, "## sql") || !strings.Contains(got, "Edited.") {
+		t.Errorf("prompt after excluding sql = %q, want a fresh render of pdf alone", got)
+	}
+
+	uncached := NewSkillsPrompt(StaticPrompt("Base."), loader, WithSkillsPromptCache(false))
+	uncached.SetContext("hello")
+	if got := uncached.Prompt(); !strings.HasPrefix(got, "Base.\n\n# Active Skills") {
+		t.Errorf("uncached prompt = %q, want no breakpoint", got)
+	}
+}
+
+// cachingLLM is a mockLLM that reads cache breakpoints.
+type cachingLLM struct{ mockLLM }
+
+func (cachingLLM) CachesPrompts() bool { return true }
+
+func TestSkillsPromptBreakpointOnlyForPromptCachers(t *testing.T) {
+	dir := t.TempDir()
+	content := "---\nname: pdf\ndescription: pdf help\ntriggers:\n  - type: always\n---\nUse pdf well.\n"
+	if err := os.WriteFile(filepath.Join(dir, "pdf.skill.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loader := skills.NewLoader(dir)
+	if err := loader.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	agent := &Agent{Name: "helper", System: NewSkillsPrompt(StaticPrompt("Base."), loader)}
+
+	custom := &Process{ID: "p1", Agent: agent, llm: &mockLLM{}}
+	custom.addMessage(llm.Message{Role: llm.RoleUser, Content: "hello"})
+	if sys := custom.buildMessages(context.Background())[0].Content; strings.Contains(sys, llm.CacheBreakpoint) || !strings.HasPrefix(sys, "Base.\n\n# Active Skills") {
+		t.Errorf("system message for a custom backend = %q, want the breakpoint stripped", sys)
+	}
+
+	caching := &Process{ID: "p2", Agent: agent, llm: &cachingLLM{}}
+	caching.addMessage(llm.Message{Role: llm.RoleUser, Content: "hello"})
+	if sys := caching.buildMessages(context.Background())[0].Content; !strings.HasPrefix(sys, "Base."+llm.CacheBreakpoint) {
+		t.Errorf("system message for a prompt cacher = %q, want the breakpoint kept", sys)
+	}
+}
+
+func TestRelevantSkillsSelectedPerTurn(t *testing.T) {
+	dir := t.TempDir()
+	for name, description := range map[string]string{
+		"sql-tuning":     "Optimize slow SQL queries and database indexes",
+		"pdf-forms":      "Extract and fill fields in PDF forms",
+		"email-drafting": "Draft polite replies to customer emails",
+	} {
+		content := "---\nname: " + name + "\ndescription: " + description + "\n---\nDo " + name + ".\n"
+		if err := os.WriteFile(filepath.Join(dir, name+".skill.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loader := skills.NewLoader(dir)
+	if err := loader.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sp := NewSkillsPrompt(StaticPrompt("Base."), loader, WithMaxActiveSkills(1), WithRelevantSkills(true))
+	p := &Process{ID: "p1", Agent: &Agent{Name: "helper", System: sp}}
+
+	activeSkills := func() []string {
+		msgs := p.buildMessages(context.Background())
+		var names []string
+		for _, name := range []string{"sql-tuning", "pdf-forms", "email-drafting"} {
+			if strings.Contains(msgs[0].Content, "## "+name+"\n") {
+				names = append(names, name)
+			}
+		}
+		return names
+	}
+
+	p.addMessage(llm.Message{Role: llm.RoleUser, Content: "Why is this database query so slow?"})
+	if got := activeSkills(); len(got) != 1 || got[0] != "sql-tuning" {
+		t.Errorf("skills for a database question = %v, want [sql-tuning]", got)
+	}
+
+	p.addMessage(llm.Message{Role: llm.RoleAssistant, Content: "Add an index."})
+	p.addMessage(llm.Message{Role: llm.RoleUser, Content: "Now extract the fields from this PDF form."})
+	if got := activeSkills(); len(got) != 1 || got[0] != "pdf-forms" {
+		t.Errorf("skills for a PDF question = %v, want [pdf-forms]", got)
+	}
+
+	p.addMessage(llm.Message{Role: llm.RoleUser, Content: "What's the weather like?"})
+	if got := activeSkills(); len(got) != 0 {
+		t.Errorf("skills for an unrelated message = %v, want none", got)
+	}
+
+	// Without relevance selection, skills lacking triggers never activate.
+	triggered := NewSkillsPrompt(StaticPrompt("Base."), loader)
+	triggered.SetContext("Why is this database query so slow?")
+	if got := triggered.GetMatchedSkills(); len(got) != 0 {
+		t.Errorf("trigger matching selected %d skills, want 0", len(got))
+	}
+}
@@ -1,6 +1,27 @@
 package vega
 
-import "time"
+import (
+	"slices"
+	"time"
+)
+
+// SpawnReason classifies why a process was spawned.
+type SpawnReason string
+
+const (
+	// SpawnUser is a process started directly by the application or a user.
+	SpawnUser SpawnReason = "user"
+	// SpawnDelegate is a process started by another process handing off work.
+	SpawnDelegate SpawnReason = "delegate"
+	// SpawnSupervisorRestart replaces a process that failed under supervision.
+	SpawnSupervisorRestart SpawnReason = "supervisor_restart"
+	// SpawnWorkflow is a process started to run a workflow step.
+	SpawnWorkflow SpawnReason = "workflow"
+	// SpawnRecovery is a process recreated from persisted state on startup.
+	SpawnRecovery SpawnReason = "recovery"
+	// SpawnFork is a copy of another process's conversation, made by Fork.
+	SpawnFork SpawnReason = "fork"
+)
 
 // SpawnTreeNode represents a node in the process spawn tree.
 type SpawnTreeNode struct {
@@ -9,7 +30,8 @@ type SpawnTreeNode struct {
 	Task        string           `json:"task"`
 	Status      Status           `json:"status"`
 	SpawnDepth  int              `json:"spawn_depth"`
-	SpawnReason string           `json:"spawn_reason,omitempty"`
+	SpawnReason SpawnReason      `json:"spawn_reason,omitempty"`
+	SpawnDetail string           `json:"spawn_detail,omitempty"`
 	StartedAt   time.Time        `json:"started_at"`
 	Children    []*SpawnTreeNode `json:"children,omitempty"`
 }
@@ -34,6 +56,7 @@ func (o *Orchestrator) GetSpawnTree() []*SpawnTreeNode {
 			Status:      p.Status(),
 			SpawnDepth:  p.SpawnDepth,
 			SpawnReason: p.SpawnReason,
+			SpawnDetail: p.SpawnDetail,
 			StartedAt:   p.StartedAt,
 			Children:    make([]*SpawnTreeNode, 0),
 		}
@@ -57,3 +80,11 @@ func (o *Orchestrator) GetSpawnTree() []*SpawnTreeNode {
 
 	return roots
 }
+
+// removeChildID drops id from the process's ChildIDs, undoing WithParent
+// for a child whose spawn was refused.
+func (p *Process) removeChildID(id string) {
+	p.childMu.Lock()
+	defer p.childMu.Unlock()
+	p.ChildIDs = slices.DeleteFunc(p.ChildIDs, func(c string) bool { return c == id })
+}
@@ -14,6 +14,25 @@ const (
 	ChatEventToolEnd   ChatEventType = "tool_end"
 	ChatEventError     ChatEventType = "error"
 	ChatEventDone      ChatEventType = "done"
+
+	// ChatEventToolCall is emitted as soon as the model begins a tool call,
+	// before its arguments have finished streaming.
+	ChatEventToolCall ChatEventType = "tool_call"
+
+	// ChatEventToolResult is emitted the moment a tool finishes, without
+	// waiting for the other tools called in the same turn.
+	ChatEventToolResult ChatEventType = "tool_result"
+
+	// ChatEventPolicy is emitted when a content filter aborts a response.
+	// Error carries the policy message shown in place of the response.
+	ChatEventPolicy ChatEventType = "policy"
+)
+
+// Tool progress statuses carried in ChatEvent.Status.
+const (
+	ToolStatusPending = "pending" // tool_call: arguments still streaming
+	ToolStatusOK      = "ok"      // tool_result: tool succeeded
+	ToolStatusError   = "error"   // tool_result: tool returned an error
 )
 
 // ChatEventMetrics holds token/cost/duration stats for a completed response.
@@ -35,6 +54,7 @@ type ChatEvent struct {
 	Arguments   map[string]any    `json:"arguments,omitempty"`
 	Result      string            `json:"result,omitempty"`
 	DurationMs  int64             `json:"duration_ms,omitempty"`
+	Status      string            `json:"status,omitempty"`
 	Error       string            `json:"error,omitempty"`
 	NestedAgent string            `json:"nested_agent,omitempty"`
 	Metrics     *ChatEventMetrics `json:"metrics,omitempty"`
@@ -78,6 +98,80 @@ func newChatStream() *ChatStream {
 	}
 }
 
+// bufferChatStream relays in to a new ChatStream through an unbounded
+// queue, so in's producer never waits for a reader and the generation
+// finishes even if nobody consumes the events. The returned stream's
+// Response and Err are available as soon as in finishes; its Events
+// channel closes once every event has been read, or when stop closes.
+// release is called when in finishes.
+func bufferChatStream(in *ChatStream, stop <-chan struct{}, release func()) *ChatStream {
+	out := newChatStream()
+
+	var (
+		mu       sync.Mutex
+		queue    []ChatEvent
+		finished bool
+		wake     = make(chan struct{}, 1)
+	)
+	notify := func() {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+
+	go func() {
+		for ev := range in.events {
+			mu.Lock()
+			queue = append(queue, ev)
+			mu.Unlock()
+			notify()
+		}
+		// in.done closes before in.events, so the result is final here.
+		out.mu.Lock()
+		out.response = in.Response()
+		out.err = in.Err()
+		out.mu.Unlock()
+		close(out.done)
+		release()
+
+		mu.Lock()
+		finished = true
+		mu.Unlock()
+		notify()
+	}()
+
+	go func() {
+		defer close(out.events)
+		for {
+			mu.Lock()
+			batch, last := queue, finished
+			queue = nil
+			mu.Unlock()
+
+			for _, ev := range batch {
+				select {
+				case out.events <- ev:
+				case <-stop:
+					return
+				}
+			}
+			if last && len(batch) == 0 {
+				return
+			}
+			if len(batch) == 0 {
+				select {
+				case <-wake:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
 // toolDuration returns milliseconds elapsed since start.
 func toolDuration(start time.Time) int64 {
 	return time.Since(start).Milliseconds()
@@ -47,7 +47,8 @@ const (
 	// Stop lets the process stay dead
 	Stop
 
-	// Escalate propagates failure to parent
+	// Escalate passes the failure up the spawn tree to the nearest ancestor
+	// that traps exits, as an ExitEscalated signal, instead of restarting
 	Escalate
 
 	// RestartAll restarts all sibling processes (for interdependent processes)
@@ -95,8 +96,8 @@ func (s *Supervision) recordFailure(p *Process, err error) bool {
 		s.OnFailure(p, err)
 	}
 
-	// Check if we should restart
-	if s.Strategy == Stop {
+	// Check if we should restart; escalated failures are the ancestor's call
+	if s.Strategy == Stop || s.Strategy == Escalate {
 		return false
 	}
 
@@ -2,10 +2,24 @@ package vega
 
 import (
 	"context"
+	"log/slog"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// supervisorSignalBuffer is the capacity of the channel on which a
+// supervisor receives its children's exit signals.
+const supervisorSignalBuffer = 64
+
+// supervisorReconcileInterval is how often a supervisor checks its
+// children's status directly, to catch exits whose signal was dropped
+// because a burst of them filled the signal channel.
+const supervisorReconcileInterval = 2 * time.Second
+
 // SupervisorStrategy determines how failures affect siblings.
 type SupervisorStrategy int
 
@@ -99,6 +113,19 @@ type Supervisor struct {
 	restarts    int
 	lastBackoff time.Duration
 
+	// gaveUp is set when the restart intensity is exceeded and the
+	// supervisor stops its children for good.
+	gaveUp atomic.Bool
+
+	// watcher monitors every child, so each exit arrives as an ExitSignal
+	// on its channel the moment it happens.
+	watcher   *Process
+	watchOnce sync.Once
+
+	// reconcileInterval is how often watch polls the children as a
+	// backstop for dropped exit signals.
+	reconcileInterval time.Duration
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -108,27 +135,62 @@ type supervisedChild struct {
 	spec    ChildSpec
 	process *Process
 	index   int // Position in children slice (for RestForOne)
+
+	// restarts counts how many times this child's slot has been
+	// restarted; a replacement inherits its predecessor's count plus one.
+	restarts int
+
+	// exited is set by the first exit notification so a child is never
+	// handled twice.
+	exited atomic.Bool
 }
 
 // NewSupervisor creates a new supervisor with the given spec.
 func (o *Orchestrator) NewSupervisor(spec SupervisorSpec) *Supervisor {
 	ctx, cancel := context.WithCancel(o.ctx)
-	return &Supervisor{
+	s := &Supervisor{
 		spec:         spec,
 		orchestrator: o,
 		children:     make([]*supervisedChild, 0, len(spec.Children)),
-		ctx:          ctx,
-		cancel:       cancel,
-	}
+		watcher: &Process{
+			ID:           "supervisor-" + uuid.New().String()[:8],
+			orchestrator: o,
+			exitSignals:  make(chan ExitSignal, supervisorSignalBuffer),
+		},
+		reconcileInterval: supervisorReconcileInterval,
+		ctx:               ctx,
+		cancel:            cancel,
+	}
+
+	o.supervisorsMu.Lock()
+	o.supervisors = append(o.supervisors, s)
+	o.supervisorsMu.Unlock()
+	return s
+}
+
+// Supervisors returns the supervisors created with NewSupervisor that have
+// not been stopped, in creation order. A supervisor that gave up after
+// exceeding its restart intensity stays listed so its state can be seen.
+func (o *Orchestrator) Supervisors() []*Supervisor {
+	o.supervisorsMu.RLock()
+	defer o.supervisorsMu.RUnlock()
+	return slices.Clone(o.supervisors)
+}
+
+// ID returns the supervisor's identifier.
+func (s *Supervisor) ID() string {
+	return s.watcher.ID
 }
 
 // Start spawns all children and begins supervision.
 func (s *Supervisor) Start() error {
+	s.watchOnce.Do(func() { go s.watch() })
+
 	s.childrenMu.Lock()
 	defer s.childrenMu.Unlock()
 
 	for i, childSpec := range s.spec.Children {
-		child, err := s.spawnChild(childSpec, i)
+		child, err := s.spawnChild(childSpec, i, false)
 		if err != nil {
 			// Shutdown already-started children
 			s.stopAllChildrenLocked()
@@ -140,14 +202,18 @@ func (s *Supervisor) Start() error {
 	return nil
 }
 
-// spawnChild spawns a single child and sets up monitoring.
-func (s *Supervisor) spawnChild(spec ChildSpec, index int) (*supervisedChild, error) {
+// spawnChild spawns a single child and sets up monitoring. restart marks
+// the child as replacing one that exited.
+func (s *Supervisor) spawnChild(spec ChildSpec, index int, restart bool) (*supervisedChild, error) {
 	// Build spawn options
-	opts := make([]SpawnOption, 0, len(spec.SpawnOpts)+1)
+	opts := make([]SpawnOption, 0, len(spec.SpawnOpts)+2)
 	opts = append(opts, spec.SpawnOpts...)
 	if spec.Task != "" {
 		opts = append(opts, WithTask(spec.Task))
 	}
+	if restart {
+		opts = append(opts, WithSpawnKind(SpawnSupervisorRestart))
+	}
 
 	// Spawn the process
 	proc, err := s.orchestrator.Spawn(spec.Agent, opts...)
@@ -176,38 +242,93 @@ func (s *Supervisor) spawnChild(spec ChildSpec, index int) (*supervisedChild, er
 	return child, nil
 }
 
-// monitorChild sets up exit monitoring for a child.
+// monitorChild has the supervisor's watcher monitor a child.
 func (s *Supervisor) monitorChild(child *supervisedChild) {
-	// We'll use the orchestrator's OnProcessFailed callback mechanism
-	// plus direct monitoring
-	go func() {
-		proc := child.process
+	proc := child.process
+	s.watcher.Monitor(proc)
+
+	// A child that exited before the monitor was in place has already sent
+	// its signal, so report the exit now. If the signal also arrives,
+	// child.exited keeps it from being handled twice.
+	switch proc.Status() {
+	case StatusCompleted:
+		go s.handleChildExit(child, ExitSignal{ProcessID: proc.ID, Reason: ExitNormal, Timestamp: time.Now()})
+	case StatusFailed:
+		go s.handleChildExit(child, ExitSignal{ProcessID: proc.ID, Reason: ExitError, Timestamp: time.Now()})
+	}
+}
 
-		// Wait for process to complete or fail
-		for {
-			select {
-			case <-s.ctx.Done():
-				return
-			case <-time.After(DefaultSupervisorPollInterval):
-				status := proc.Status()
-				if status == StatusCompleted || status == StatusFailed {
-					s.handleChildExit(child, status)
-					return
-				}
+// watch dispatches the watcher's exit signals until the supervisor stops.
+// Signals from processes that are no longer children, such as ones the
+// supervisor itself stopped while restarting, are ignored. Signals can be
+// dropped when many children exit at once, so watch also polls the
+// children every reconcileInterval and handles any that have exited.
+func (s *Supervisor) watch() {
+	signals := s.watcher.ExitSignals()
+	ticker := time.NewTicker(s.reconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case signal := <-signals:
+			if child := s.childByProcessID(signal.ProcessID); child != nil {
+				go s.handleChildExit(child, signal)
 			}
+		case <-ticker.C:
+			s.reconcile()
 		}
-	}()
+	}
+}
+
+// reconcile handles children that have exited without their exit signal
+// being handled. handleChildExit ignores children already handled.
+func (s *Supervisor) reconcile() {
+	s.childrenMu.RLock()
+	children := slices.Clone(s.children)
+	s.childrenMu.RUnlock()
+
+	for _, child := range children {
+		if child.exited.Load() {
+			continue
+		}
+		proc := child.process
+		switch proc.Status() {
+		case StatusCompleted:
+			go s.handleChildExit(child, ExitSignal{ProcessID: proc.ID, Reason: ExitNormal, Timestamp: time.Now()})
+		case StatusFailed:
+			go s.handleChildExit(child, ExitSignal{ProcessID: proc.ID, Reason: ExitError, Timestamp: time.Now()})
+		}
+	}
+}
+
+// childByProcessID returns the current child running the given process.
+func (s *Supervisor) childByProcessID(id string) *supervisedChild {
+	s.childrenMu.RLock()
+	defer s.childrenMu.RUnlock()
+
+	for _, child := range s.children {
+		if child.process.ID == id {
+			return child
+		}
+	}
+	return nil
 }
 
 // handleChildExit is called when a supervised child exits.
-func (s *Supervisor) handleChildExit(child *supervisedChild, status Status) {
-	// Determine if we should restart
+func (s *Supervisor) handleChildExit(child *supervisedChild, signal ExitSignal) {
+	if !child.exited.CompareAndSwap(false, true) {
+		return
+	}
+
+	// Determine if we should restart. Stop reports ExitKilled but, like
+	// the completed status it sets, that counts as a normal exit here.
 	shouldRestart := false
 	switch child.spec.Restart {
 	case Permanent:
 		shouldRestart = true
 	case Transient:
-		shouldRestart = status == StatusFailed
+		shouldRestart = signal.Reason != ExitNormal && signal.Reason != ExitKilled
 	case Temporary:
 		shouldRestart = false
 	}
@@ -219,7 +340,7 @@ func (s *Supervisor) handleChildExit(child *supervisedChild, status Status) {
 	// Check restart limits
 	if !s.canRestart() {
 		// Exceeded max restarts - supervisor gives up
-		s.Stop()
+		s.giveUp()
 		return
 	}
 
@@ -329,11 +450,12 @@ func (s *Supervisor) restartChild(child *supervisedChild) {
 	}
 
 	// Spawn new process
-	newChild, err := s.spawnChild(child.spec, child.index)
+	newChild, err := s.spawnChild(child.spec, child.index, true)
 	if err != nil {
 		// Failed to restart - will be handled by next failure
 		return
 	}
+	newChild.restarts = child.restarts + 1
 
 	// Update child reference
 	for i, c := range s.children {
@@ -350,8 +472,10 @@ func (s *Supervisor) restartAllChildren() {
 	defer s.childrenMu.Unlock()
 
 	// Stop all children in reverse order
+	restarts := make(map[int]int, len(s.children))
 	for i := len(s.children) - 1; i >= 0; i-- {
 		child := s.children[i]
+		restarts[child.index] = child.restarts
 		if child.process.Status() == StatusRunning {
 			child.process.Stop()
 		}
@@ -365,11 +489,12 @@ func (s *Supervisor) restartAllChildren() {
 
 	// Restart all children in order
 	for i, childSpec := range s.spec.Children {
-		newChild, err := s.spawnChild(childSpec, i)
+		newChild, err := s.spawnChild(childSpec, i, true)
 		if err != nil {
 			// Failed to restart - will be handled by next failure
 			continue
 		}
+		newChild.restarts = restarts[i] + 1
 		s.children = append(s.children, newChild)
 	}
 }
@@ -383,8 +508,10 @@ func (s *Supervisor) restartChildAndFollowing(failed *supervisedChild) {
 	failedIndex := failed.index
 
 	// Stop all children from failedIndex onwards in reverse order
+	restarts := make(map[int]int, len(s.children)-failedIndex)
 	for i := len(s.children) - 1; i >= failedIndex; i-- {
 		child := s.children[i]
+		restarts[child.index] = child.restarts
 		if child.process.Status() == StatusRunning {
 			child.process.Stop()
 		}
@@ -398,22 +525,48 @@ func (s *Supervisor) restartChildAndFollowing(failed *supervisedChild) {
 
 	// Restart from failedIndex onwards
 	for i := failedIndex; i < len(s.spec.Children); i++ {
-		newChild, err := s.spawnChild(s.spec.Children[i], i)
+		newChild, err := s.spawnChild(s.spec.Children[i], i, true)
 		if err != nil {
 			continue
 		}
+		newChild.restarts = restarts[i] + 1
 		s.children = append(s.children, newChild)
 	}
 }
 
-// Stop stops the supervisor and all its children.
+// Stop stops the supervisor and all its children, and removes it from the
+// orchestrator's Supervisors.
 func (s *Supervisor) Stop() {
 	s.cancel()
 
 	s.childrenMu.Lock()
-	defer s.childrenMu.Unlock()
-
 	s.stopAllChildrenLocked()
+	s.childrenMu.Unlock()
+
+	o := s.orchestrator
+	o.supervisorsMu.Lock()
+	o.supervisors = slices.DeleteFunc(o.supervisors, func(other *Supervisor) bool { return other == s })
+	o.supervisorsMu.Unlock()
+}
+
+// giveUp stops the supervisor after its restart intensity is exceeded.
+// Unlike Stop, it keeps the children and the supervisor's listing so
+// Status can show what happened.
+func (s *Supervisor) giveUp() {
+	s.gaveUp.Store(true)
+	s.cancel()
+
+	s.childrenMu.Lock()
+	defer s.childrenMu.Unlock()
+	for i := len(s.children) - 1; i >= 0; i-- {
+		child := s.children[i]
+		if child.process.Status() == StatusRunning {
+			child.process.Stop()
+		}
+		if child.spec.Name != "" {
+			s.orchestrator.Unregister(child.spec.Name)
+		}
+	}
 }
 
 // stopAllChildrenLocked stops all children (must hold childrenMu).
@@ -447,11 +600,12 @@ func (s *Supervisor) Children() []*Process {
 
 // ChildInfo contains information about a supervised child.
 type ChildInfo struct {
-	Name    string
-	ID      string
-	Status  Status
-	Restart ChildRestart
-	Agent   string
+	Name     string
+	ID       string
+	Status   Status
+	Restart  ChildRestart
+	Agent    string
+	Restarts int // times the supervisor has restarted this child
 }
 
 // WhichChildren returns information about all current children.
@@ -462,16 +616,46 @@ func (s *Supervisor) WhichChildren() []ChildInfo {
 	infos := make([]ChildInfo, len(s.children))
 	for i, child := range s.children {
 		infos[i] = ChildInfo{
-			Name:    child.spec.Name,
-			ID:      child.process.ID,
-			Status:  child.process.Status(),
-			Restart: child.spec.Restart,
-			Agent:   child.spec.Agent.Name,
+			Name:     child.spec.Name,
+			ID:       child.process.ID,
+			Status:   child.process.Status(),
+			Restart:  child.spec.Restart,
+			Agent:    child.spec.Agent.Name,
+			Restarts: child.restarts,
 		}
 	}
 	return infos
 }
 
+// SupervisorStatus is a snapshot of a supervisor's health.
+type SupervisorStatus struct {
+	ID          string
+	Strategy    SupervisorStrategy
+	MaxRestarts int
+	Window      time.Duration
+	Restarts    int  // restarts performed across all children
+	GaveUp      bool // restart intensity was exceeded and the children stopped
+	Children    []ChildInfo
+}
+
+// Status returns a snapshot of the supervisor's children and restart
+// state.
+func (s *Supervisor) Status() SupervisorStatus {
+	s.failuresMu.Lock()
+	restarts := s.restarts
+	s.failuresMu.Unlock()
+
+	return SupervisorStatus{
+		ID:          s.ID(),
+		Strategy:    s.spec.Strategy,
+		MaxRestarts: s.spec.MaxRestarts,
+		Window:      s.spec.Window,
+		Restarts:    restarts,
+		GaveUp:      s.gaveUp.Load(),
+		Children:    s.WhichChildren(),
+	}
+}
+
 // StartChild dynamically adds and starts a new child to the supervisor.
 // Returns the new process or an error if the child couldn't be started.
 func (s *Supervisor) StartChild(spec ChildSpec) (*Process, error) {
@@ -489,7 +673,7 @@ func (s *Supervisor) StartChild(spec ChildSpec) (*Process, error) {
 
 	// Spawn the child
 	index := len(s.children)
-	child, err := s.spawnChild(spec, index)
+	child, err := s.spawnChild(spec, index, false)
 	if err != nil {
 		return nil, err
 	}
@@ -553,7 +737,7 @@ func (s *Supervisor) RestartChild(name string) error {
 	s.childrenMu.Unlock()
 
 	// Spawn new process (outside lock to avoid deadlock)
-	newChild, err := s.spawnChild(targetChild.spec, targetIndex)
+	newChild, err := s.spawnChild(targetChild.spec, targetIndex, true)
 	if err != nil {
 		return err
 	}
@@ -713,7 +897,8 @@ func (o *Orchestrator) handleAutoRestart(p *Process, err error) {
 
 	// Spawn replacement
 	go func() {
-		newProc, spawnErr := o.Spawn(agent, spawnOpts...)
+		opts := append(slices.Clip(spawnOpts), WithSpawnKind(SpawnSupervisorRestart))
+		newProc, spawnErr := o.Spawn(agent, opts...)
 		if spawnErr != nil {
 			return
 		}
@@ -731,3 +916,71 @@ func (o *Orchestrator) handleAutoRestart(p *Process, err error) {
 		}
 	}()
 }
+
+// --- Escalation ---
+
+// escalate delivers a failed process's error to the nearest ancestor that
+// traps exits, walking up ParentID links. Ancestors that don't trap exits
+// are skipped, as are ones that have already exited; if no ancestor takes
+// the signal the failure is logged and dropped. The receiving ancestor
+// decides what to do, e.g. respawn the child with a different config or
+// escalate further by failing itself.
+func (o *Orchestrator) escalate(p *Process, err error) {
+	agentName := ""
+	if p.Agent != nil {
+		agentName = p.Agent.Name
+	}
+	signal := ExitSignal{
+		ProcessID: p.ID,
+		AgentName: agentName,
+		Reason:    ExitEscalated,
+		Error:     err,
+		Timestamp: time.Now(),
+	}
+
+	seen := map[string]bool{p.ID: true}
+	for parentID := p.ParentID; parentID != "" && !seen[parentID]; {
+		seen[parentID] = true
+		parent := o.Get(parentID)
+		if parent == nil {
+			break
+		}
+		if parent.deliverEscalation(signal) {
+			slog.Info("failure escalated",
+				"process_id", p.ID,
+				"agent", agentName,
+				"to", parent.ID,
+			)
+			return
+		}
+		parentID = parent.ParentID
+	}
+
+	slog.Warn("escalation unhandled: no ancestor traps exits",
+		"process_id", p.ID,
+		"agent", agentName,
+	)
+}
+
+// deliverEscalation puts signal on the process's exit signal channel if it
+// is alive and trapping exits, reporting whether it was delivered.
+func (p *Process) deliverEscalation(signal ExitSignal) bool {
+	switch p.Status() {
+	case StatusCompleted, StatusFailed, StatusTimeout:
+		return false
+	}
+
+	p.linkMu.RLock()
+	trapExit, exitCh := p.trapExit, p.exitSignals
+	p.linkMu.RUnlock()
+	if !trapExit || exitCh == nil {
+		return false
+	}
+
+	select {
+	case exitCh <- signal:
+		return true
+	default:
+		return false
+	}
+}
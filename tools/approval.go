@@ -0,0 +1,32 @@
+package tools
+
+import "context"
+
+// RejectedResult is returned to the model in place of a tool's output when
+// a call is denied by WithApproval.
+const RejectedResult = "Tool call rejected by user. Do not retry it; ask the user how to proceed."
+
+// ApprovalFunc decides whether a tool call may run. It may block while a
+// human reviews the call. Returning false rejects the call; returning an
+// error fails it.
+type ApprovalFunc func(ctx context.Context, toolName string, params map[string]any) (bool, error)
+
+// WithApproval returns middleware that asks approve before every tool call.
+// A rejected call does not execute; the model receives RejectedResult
+// instead, so it can adjust rather than treat the rejection as a failure.
+// Approve decides which tools need review and should return true at once
+// for the rest.
+func WithApproval(approve ApprovalFunc) ToolMiddleware {
+	return func(next ToolFunc) ToolFunc {
+		return func(ctx context.Context, params map[string]any) (string, error) {
+			ok, err := approve(ctx, ToolNameFromContext(ctx), params)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return RejectedResult, nil
+			}
+			return next(ctx, params)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package tools
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -90,14 +89,27 @@ func sandboxEnv(sandbox string) []string {
 	return append(result, "HOME="+sandbox, "TMPDIR="+sandbox)
 }
 
+// withFileURL adds the URL a written file is served at to msg, when a
+// server base URL is configured and the file is in the sandbox.
+func (t *Tools) withFileURL(msg, path string) string {
+	if t.baseURL == "" || t.sandbox == "" {
+		return msg
+	}
+	relPath, err := filepath.Rel(t.sandbox, path)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		return msg
+	}
+	return msg + fmt.Sprintf("\nAccessible at: %s/workspace/%s", t.baseURL, relPath)
+}
+
 // RegisterBuiltins adds the built-in tools.
 func (t *Tools) RegisterBuiltins() {
-	t.Register("read_file", func(path string) (string, error) {
+	t.registerAs(ToolSourceBuiltin, "read_file", func(path string) (string, error) {
 		data, err := os.ReadFile(path)
 		return string(data), err
 	})
 
-	t.Register("write_file", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "write_file", ToolDef{
 		Description: "Write content to a file",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			path := params["path"].(string)
@@ -109,15 +121,7 @@ func (t *Tools) RegisterBuiltins() {
 			if t.OnFileWrite != nil {
 				t.OnFileWrite(ctx, path, "write", desc)
 			}
-			msg := "File written successfully"
-			// Include accessible URL when a server base URL is configured.
-			if t.baseURL != "" && t.sandbox != "" {
-				relPath, err := filepath.Rel(t.sandbox, path)
-				if err == nil && !strings.HasPrefix(relPath, "..") {
-					msg += fmt.Sprintf("\nAccessible at: %s/workspace/%s", t.baseURL, relPath)
-				}
-			}
-			return msg, nil
+			return t.withFileURL("File written successfully", path), nil
 		},
 		Params: map[string]ParamDef{
 			"path":        {Type: "string", Description: "File path", Required: true},
@@ -126,25 +130,10 @@ func (t *Tools) RegisterBuiltins() {
 		},
 	})
 
-	t.Register("list_files", func(path string) (string, error) {
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			return "", err
-		}
-		var names []string
-		for _, e := range entries {
-			name := e.Name()
-			if e.IsDir() {
-				name += "/"
-			}
-			names = append(names, name)
-		}
-		result, _ := json.Marshal(names)
-		return string(result), nil
-	})
+	t.registerListFiles()
 
-	t.Register("append_file", ToolDef{
-		Description: "Append content to a file",
+	t.registerAs(ToolSourceBuiltin, "append_file", ToolDef{
+		Description: "Append content to the end of a file, creating it if it doesn't exist. Use this to add to logs and notes instead of reading and rewriting the whole file.",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			path := params["path"].(string)
 			content := params["content"].(string)
@@ -160,7 +149,7 @@ func (t *Tools) RegisterBuiltins() {
 			if t.OnFileWrite != nil {
 				t.OnFileWrite(ctx, path, "append", desc)
 			}
-			return "Content appended successfully", nil
+			return t.withFileURL("Content appended successfully", path), nil
 		},
 		Params: map[string]ParamDef{
 			"path":        {Type: "string", Description: "File path", Required: true},
@@ -169,7 +158,7 @@ func (t *Tools) RegisterBuiltins() {
 		},
 	})
 
-	t.Register("exec", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "exec", ToolDef{
 		Description: "Execute a shell command inside the workspace sandbox. The working directory is always the sandbox. Use this to run build tools, start servers, install dependencies, etc.",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			command := params["command"].(string)
@@ -250,7 +239,7 @@ func (t *Tools) RegisterBuiltins() {
 	})
 
 	// Background service management — for long-running processes like dev servers.
-	t.Register("start_service", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "start_service", ToolDef{
 		Description: "Start a long-running background process (e.g. npm dev server, python http.server). The process runs until explicitly stopped. Returns the service name and recent output.",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			name, _ := params["name"].(string)
@@ -348,7 +337,7 @@ func (t *Tools) RegisterBuiltins() {
 		},
 	})
 
-	t.Register("stop_service", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "stop_service", ToolDef{
 		Description: "Stop a running background service by name.",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			name, _ := params["name"].(string)
@@ -376,7 +365,7 @@ func (t *Tools) RegisterBuiltins() {
 		},
 	})
 
-	t.Register("list_services", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "list_services", ToolDef{
 		Description: "List all running background services with their status and recent output.",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			t.servicesMu.Lock()
@@ -397,7 +386,7 @@ func (t *Tools) RegisterBuiltins() {
 		Params: map[string]ParamDef{},
 	})
 
-	t.Register("service_logs", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "service_logs", ToolDef{
 		Description: "Get recent output from a running background service.",
 		Fn: func(ctx context.Context, params map[string]any) (string, error) {
 			name, _ := params["name"].(string)
@@ -418,4 +407,8 @@ func (t *Tools) RegisterBuiltins() {
 			"name": {Type: "string", Description: "Name of the service to get logs from", Required: true},
 		},
 	})
+
+	t.registerWebSearch()
+	t.registerSummarizeFile()
+	t.registerHTTPRequest()
 }
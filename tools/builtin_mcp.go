@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"html"
 	"io"
@@ -57,9 +58,10 @@ func (t *Tools) ConnectBuiltinServer(ctx context.Context, name string) (int, err
 	var count int
 	for toolName, def := range server.tools {
 		prefixed := name + "__" + toolName
-		if err := t.Register(prefixed, def); err != nil {
-			// Skip if already registered.
-			if strings.Contains(err.Error(), "already registered") {
+		if err := t.registerTool(ToolSourceMCP, name, prefixed, def); err != nil {
+			// Skip tools this server registered before; any other
+			// tool holding the name is a collision.
+			if errors.Is(err, ErrToolAlreadyRegistered) && t.serverOwns(name, prefixed) {
 				continue
 			}
 			return count, fmt.Errorf("register %s: %w", prefixed, err)
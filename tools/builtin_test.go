@@ -2,10 +2,14 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestWriteFileReturnsURL(t *testing.T) {
@@ -77,3 +81,207 @@ func TestWriteFileNoURLWithoutBaseURL(t *testing.T) {
 		t.Errorf("expected no URL without base URL, got: %s", result)
 	}
 }
+
+func TestAppendFile(t *testing.T) {
+	dir := t.TempDir()
+	tools := NewTools(WithSandbox(dir), WithBaseURL("http://localhost:3001"))
+	tools.RegisterBuiltins()
+
+	type write struct{ path, operation, description string }
+	var writes []write
+	tools.OnFileWrite = func(ctx context.Context, path, operation, description string) {
+		writes = append(writes, write{path, operation, description})
+	}
+
+	for _, line := range []string{"started\n", "step 1 done\n"} {
+		result, err := tools.Execute(context.Background(), "append_file", map[string]any{
+			"path":        "logs/../run.log",
+			"content":     line,
+			"description": "run log",
+		})
+		if err != nil {
+			t.Fatalf("append_file failed: %v", err)
+		}
+		if !strings.Contains(result, "http://localhost:3001/workspace/run.log") {
+			t.Errorf("expected URL in response, got: %s", result)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "run.log"))
+	if err != nil {
+		t.Fatalf("file not created: %v", err)
+	}
+	if string(data) != "started\nstep 1 done\n" {
+		t.Errorf("appends did not accumulate: %q", data)
+	}
+	want := write{filepath.Join(dir, "run.log"), "append", "run log"}
+	if len(writes) != 2 || writes[0] != want || writes[1] != want {
+		t.Errorf("OnFileWrite calls = %+v, want two of %+v", writes, want)
+	}
+
+	// Paths outside the sandbox are pulled back in, as for write_file.
+	if _, err := tools.Execute(context.Background(), "append_file", map[string]any{"path": "../escape.log", "content": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.log")); err == nil {
+		t.Error("append_file wrote outside the sandbox")
+	}
+}
+
+func TestWebSearch(t *testing.T) {
+	t.Run("returns provider results", func(t *testing.T) {
+		var gotQuery string
+		var gotLimit int
+		provider := SearchProviderFunc(func(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+			gotQuery, gotLimit = query, limit
+			return []SearchResult{
+				{Title: "Go 1.25 Release Notes", URL: "https://go.dev/doc/go1.25", Snippet: "The latest Go release"},
+				{Title: "Go Blog", URL: "https://go.dev/blog"},
+				{Title: "extra", URL: "https://example.com"},
+			}, nil
+		})
+
+		ts := NewTools(WithSearchProvider(provider))
+		ts.RegisterBuiltins()
+
+		out, err := ts.Execute(context.Background(), "web_search", map[string]any{"query": " go release ", "limit": 2})
+		if err != nil {
+			t.Fatalf("web_search: %v", err)
+		}
+		if gotQuery != "go release" || gotLimit != 2 {
+			t.Errorf("provider got query %q limit %d", gotQuery, gotLimit)
+		}
+
+		var results []SearchResult
+		if err := json.Unmarshal([]byte(out), &results); err != nil {
+			t.Fatalf("result is not JSON: %v\n%s", err, out)
+		}
+		if len(results) != 2 {
+			t.Fatalf("got %d results, want 2 (capped to limit)", len(results))
+		}
+		if results[0].URL != "https://go.dev/doc/go1.25" || results[0].Snippet != "The latest Go release" {
+			t.Errorf("results[0] = %+v", results[0])
+		}
+	})
+
+	t.Run("errors without a provider", func(t *testing.T) {
+		ts := NewTools()
+		ts.RegisterBuiltins()
+
+		_, err := ts.Execute(context.Background(), "web_search", map[string]any{"query": "anything"})
+		if !errors.Is(err, ErrNoSearchProvider) {
+			t.Fatalf("err = %v, want ErrNoSearchProvider", err)
+		}
+		if !strings.Contains(err.Error(), "WithSearchProvider") {
+			t.Errorf("error should say how to configure a provider: %v", err)
+		}
+	})
+
+	t.Run("provider set after filtering", func(t *testing.T) {
+		ts := NewTools()
+		ts.RegisterBuiltins()
+		agentTools := ts.Filter("web_search")
+
+		ts.SetSearchProvider(SearchProviderFunc(func(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+			return nil, nil
+		}))
+
+		out, err := agentTools.Execute(context.Background(), "web_search", map[string]any{"query": "q"})
+		if err != nil || out != "[]" {
+			t.Errorf("web_search = %q, %v; want empty JSON array", out, err)
+		}
+	})
+}
+
+func TestSummarizeFile(t *testing.T) {
+	// stub summarizes a chunk as its first line and records what it saw.
+	var calls []string
+	stub := SummarizerFunc(func(ctx context.Context, text, instructions string) (string, error) {
+		calls = append(calls, instructions)
+		if strings.HasPrefix(instructions, "These are summaries") {
+			return "COMBINED: " + strings.ReplaceAll(text, "\n", " | "), nil
+		}
+		first, _, _ := strings.Cut(text, "\n")
+		return first, nil
+	})
+
+	dir := t.TempDir()
+	var b strings.Builder
+	for i := 0; b.Len() < 2*summaryChunkSize+100; i++ {
+		fmt.Fprintf(&b, "line %d of the report\n", i)
+	}
+	os.WriteFile(filepath.Join(dir, "big.txt"), []byte(b.String()), 0644)
+
+	// The stub echoes its input, so lift the result cap to see all of it.
+	ts := NewTools(WithSandbox(dir), WithSummarizer(stub), WithMaxResultBytes(0))
+	ts.RegisterBuiltins()
+
+	t.Run("combines chunk summaries", func(t *testing.T) {
+		calls = nil
+		out, err := ts.Execute(context.Background(), "summarize_file", map[string]any{"path": "big.txt", "focus": "totals"})
+		if err != nil {
+			t.Fatalf("summarize_file: %v", err)
+		}
+		// Three chunks plus one combining call.
+		if len(calls) != 4 {
+			t.Fatalf("summarizer called %d times, want 4", len(calls))
+		}
+		if !strings.HasPrefix(out, "COMBINED: ") || !strings.Contains(out, "Part 1:") || !strings.Contains(out, "Part 3:") {
+			t.Errorf("summary = %q", out)
+		}
+		if !strings.Contains(out, "line 0 of the report") {
+			t.Errorf("summary missing first chunk: %q", out)
+		}
+		if !strings.Contains(calls[3], "Focus on: totals") {
+			t.Errorf("focus not passed to combine step: %q", calls[3])
+		}
+		if strings.Contains(out, "Truncated") {
+			t.Errorf("unexpected truncation note: %q", out)
+		}
+	})
+
+	t.Run("reports truncation", func(t *testing.T) {
+		huge := strings.Repeat("x", maxSummarizeBytes+2048)
+		os.WriteFile(filepath.Join(dir, "huge.txt"), []byte(huge), 0644)
+
+		out, err := ts.Execute(context.Background(), "summarize_file", map[string]any{"path": "huge.txt"})
+		if err != nil {
+			t.Fatalf("summarize_file: %v", err)
+		}
+		if !strings.Contains(out, "[Truncated: only the first 512 KB of 514 KB were summarized.]") {
+			t.Errorf("missing truncation note: %q", out[max(0, len(out)-200):])
+		}
+	})
+
+	t.Run("errors without a summarizer", func(t *testing.T) {
+		ts := NewTools(WithSandbox(dir))
+		ts.RegisterBuiltins()
+		_, err := ts.Execute(context.Background(), "summarize_file", map[string]any{"path": "big.txt"})
+		if !errors.Is(err, ErrNoSummarizer) {
+			t.Errorf("err = %v, want ErrNoSummarizer", err)
+		}
+	})
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("héllo wörld\n", 100)
+	chunks := chunkText(text, 50)
+	if strings.Join(chunks, "") != text {
+		t.Fatal("chunks do not reassemble the original text")
+	}
+	for i, c := range chunks {
+		if len(c) > 50 {
+			t.Errorf("chunk %d is %d bytes, want <= 50", i, len(c))
+		}
+		if i < len(chunks)-1 && !strings.HasSuffix(c, "\n") {
+			t.Errorf("chunk %d does not end at a line break: %q", i, c)
+		}
+	}
+
+	// No newlines: cuts must not split multi-byte runes.
+	for _, c := range chunkText(strings.Repeat("ö", 100), 25) {
+		if !utf8.ValidString(c) {
+			t.Fatalf("chunk split a rune: %q", c)
+		}
+	}
+}
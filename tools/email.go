@@ -17,7 +17,7 @@ import (
 //   - SMTP_PASS (required)
 //   - SMTP_FROM (defaults to SMTP_USER)
 func RegisterEmailTool(t *Tools) {
-	t.Register("send_email", ToolDef{
+	t.registerAs(ToolSourceBuiltin, "send_email", ToolDef{
 		Description: "Send an email via SMTP. Requires SMTP_HOST, SMTP_USER, and SMTP_PASS environment variables.",
 		Fn: ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
 			to, _ := params["to"].(string)
@@ -0,0 +1,236 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Settings read by http_request at call time, so operators can change them
+// without a restart. Set them with SetSettings or SetSetting; vega serve
+// syncs them from its settings store.
+const (
+	// HTTPAllowlistSetting is a comma-separated list of domains
+	// http_request may contact. An entry matches the domain and its
+	// subdomains; "*" allows any host. Empty allows nothing.
+	HTTPAllowlistSetting = "VEGA_HTTP_ALLOWLIST"
+	// HTTPAllowPrivateSetting set to "true" lets http_request reach
+	// loopback, private and link-local addresses, which are blocked by
+	// default.
+	HTTPAllowPrivateSetting = "VEGA_HTTP_ALLOW_PRIVATE"
+)
+
+const (
+	httpTimeout      = 30 * time.Second
+	maxHTTPBodyBytes = 64 * 1024
+	maxHTTPRedirects = 5
+)
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// cgnat is the carrier-grade NAT range, which net/netip doesn't count as
+// private but is not publicly routable either.
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// HTTPResponse is the result of http_request.
+type HTTPResponse struct {
+	Status    int               `json:"status"`
+	Headers   map[string]string `json:"headers"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+// setting returns a tool setting.
+func (t *Tools) setting(key string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.settings[key]
+}
+
+// registerHTTPRequest registers the http_request built-in tool.
+func (t *Tools) registerHTTPRequest() {
+	t.registerAs(ToolSourceBuiltin, "http_request", ToolDef{
+		Description: fmt.Sprintf("Make an HTTP request to an allowed domain. Returns JSON with status, headers and body; bodies over %d KB are truncated.", maxHTTPBodyBytes/1024),
+		Fn: ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			rawURL, _ := params["url"].(string)
+			method, _ := params["method"].(string)
+			if method == "" {
+				method = "GET"
+			}
+
+			allowlist := parseAllowlist(t.setting(HTTPAllowlistSetting))
+			allowPrivate := t.setting(HTTPAllowPrivateSetting) == "true"
+
+			u, err := url.Parse(rawURL)
+			if err != nil {
+				return "", fmt.Errorf("invalid url: %w", err)
+			}
+			if err := checkHTTPURL(u, allowlist); err != nil {
+				return "", err
+			}
+
+			var body io.Reader
+			if b, _ := params["body"].(string); b != "" {
+				body = strings.NewReader(b)
+			}
+			req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+			if err != nil {
+				return "", err
+			}
+			req.Header.Set("User-Agent", "Vega/1.0")
+			if headers, ok := params["headers"].(map[string]any); ok {
+				for k, v := range headers {
+					req.Header.Set(k, fmt.Sprint(v))
+				}
+			}
+
+			resp, err := newHTTPClient(allowlist, allowPrivate).Do(req)
+			if err != nil {
+				return "", fmt.Errorf("http request: %w", err)
+			}
+			defer resp.Body.Close()
+
+			data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBodyBytes+1))
+			if err != nil {
+				return "", fmt.Errorf("read response: %w", err)
+			}
+			out := HTTPResponse{
+				Status:  resp.StatusCode,
+				Headers: make(map[string]string, len(resp.Header)),
+			}
+			if len(data) > maxHTTPBodyBytes {
+				data = data[:maxHTTPBodyBytes]
+				out.Truncated = true
+			}
+			out.Body = strings.ToValidUTF8(string(data), "�")
+			for k, v := range resp.Header {
+				out.Headers[k] = strings.Join(v, ", ")
+			}
+
+			result, err := json.Marshal(out)
+			if err != nil {
+				return "", err
+			}
+			return string(result), nil
+		}),
+		Params: map[string]ParamDef{
+			"url":     {Type: "string", Description: "Absolute http or https URL", Required: true},
+			"method":  {Type: "string", Description: "HTTP method (default GET)", Enum: httpMethods},
+			"headers": {Type: "object", Description: "Request headers"},
+			"body":    {Type: "string", Description: "Request body"},
+		},
+	})
+}
+
+// parseAllowlist splits an allowlist setting into lower-case domains.
+func parseAllowlist(s string) []string {
+	var domains []string
+	for _, d := range strings.Split(s, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		d = strings.TrimPrefix(d, "*.")
+		if d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// checkHTTPURL rejects URLs that aren't http(s) or whose host isn't
+// allowed.
+func checkHTTPURL(u *url.URL, allowlist []string) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: only http and https URLs are supported", ErrHostNotAllowed)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: url has no host", ErrHostNotAllowed)
+	}
+	if len(allowlist) == 0 {
+		return fmt.Errorf("%w: %s (no domains are allowed; add them to the %s setting)", ErrHostNotAllowed, host, HTTPAllowlistSetting)
+	}
+	for _, d := range allowlist {
+		if d == "*" || host == d || strings.HasSuffix(host, "."+d) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s is not in the %s setting", ErrHostNotAllowed, host, HTTPAllowlistSetting)
+}
+
+// newHTTPClient returns a client that re-checks the allowlist on every
+// redirect and, unless allowPrivate is set, refuses to connect to
+// non-public addresses.
+func newHTTPClient(allowlist []string, allowPrivate bool) *http.Client {
+	client := NewGuardedHTTPClient(httpTimeout, allowPrivate)
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxHTTPRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+		}
+		return checkHTTPURL(req.URL, allowlist)
+	}
+	return client
+}
+
+// NewGuardedHTTPClient returns a client for fetching URLs taken from
+// configuration or agent output. Unless allowPrivate is set it refuses to
+// connect to loopback, private, link-local and carrier-grade NAT
+// addresses, failing with ErrHostNotAllowed. The check runs on the
+// resolved IP at dial time, so DNS names and redirects that point inside
+// the network are caught too. Redirects must stay on http(s).
+func NewGuardedHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !isPublicAddr(addr) {
+				return fmt.Errorf("%w: %s is a private or local address", ErrHostNotAllowed, addr)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: the dial-time address check must see the real
+			// destination.
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxHTTPRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxHTTPRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: only http and https URLs are supported", ErrHostNotAllowed)
+			}
+			return nil
+		},
+	}
+}
+
+// isPublicAddr reports whether addr is a globally routable unicast
+// address.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!cgnat.Contains(addr)
+}
@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Write([]byte(strings.Repeat("x", maxHTTPBodyBytes+100)))
+		case "/redirect":
+			http.Redirect(w, r, "http://evil.example/", http.StatusFound)
+		default:
+			w.Header().Set("X-Echo-Method", r.Method)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, "%s %s", r.Header.Get("X-Token"), r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	ts := NewTools()
+	ts.RegisterBuiltins()
+	ctx := context.Background()
+	call := func(params map[string]any) (HTTPResponse, error) {
+		var resp HTTPResponse
+		out, err := ts.Execute(ctx, "http_request", params)
+		if err == nil {
+			err = json.Unmarshal([]byte(out), &resp)
+		}
+		return resp, err
+	}
+
+	t.Run("empty allowlist allows nothing", func(t *testing.T) {
+		_, err := call(map[string]any{"url": srv.URL})
+		if !errors.Is(err, ErrHostNotAllowed) || !strings.Contains(err.Error(), HTTPAllowlistSetting) {
+			t.Errorf("err = %v, want ErrHostNotAllowed naming the setting", err)
+		}
+	})
+
+	ts.SetSetting(HTTPAllowlistSetting, "127.0.0.1, example.com")
+
+	t.Run("blocks loopback by default", func(t *testing.T) {
+		_, err := call(map[string]any{"url": srv.URL})
+		if !errors.Is(err, ErrHostNotAllowed) {
+			t.Errorf("err = %v, want ErrHostNotAllowed", err)
+		}
+	})
+
+	t.Run("blocks metadata endpoint", func(t *testing.T) {
+		ts.SetSetting(HTTPAllowlistSetting, "*")
+		defer ts.SetSetting(HTTPAllowlistSetting, "127.0.0.1, example.com")
+		_, err := call(map[string]any{"url": "http://169.254.169.254/latest/meta-data/"})
+		if !errors.Is(err, ErrHostNotAllowed) {
+			t.Errorf("err = %v, want ErrHostNotAllowed", err)
+		}
+	})
+
+	ts.SetSetting(HTTPAllowPrivateSetting, "true")
+
+	t.Run("returns status, headers and body", func(t *testing.T) {
+		resp, err := call(map[string]any{
+			"url":     srv.URL + "/hello",
+			"method":  "POST",
+			"headers": map[string]any{"X-Token": "abc"},
+			"body":    "payload",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != http.StatusCreated || resp.Body != "abc /hello" || resp.Headers["X-Echo-Method"] != "POST" || resp.Truncated {
+			t.Errorf("resp = %+v", resp)
+		}
+	})
+
+	t.Run("truncates large bodies", func(t *testing.T) {
+		resp, err := call(map[string]any{"url": srv.URL + "/big"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.Truncated || len(resp.Body) != maxHTTPBodyBytes {
+			t.Errorf("truncated = %v, body length = %d", resp.Truncated, len(resp.Body))
+		}
+	})
+
+	t.Run("checks redirects against the allowlist", func(t *testing.T) {
+		_, err := call(map[string]any{"url": srv.URL + "/redirect"})
+		if !errors.Is(err, ErrHostNotAllowed) {
+			t.Errorf("err = %v, want ErrHostNotAllowed", err)
+		}
+	})
+
+	rejected := map[string]string{
+		"host not listed":  "http://other.org/",
+		"lookalike suffix": "http://notexample.com/",
+		"file scheme":      "file:///etc/passwd",
+	}
+	for name, u := range rejected {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := call(map[string]any{"url": u}); !errors.Is(err, ErrHostNotAllowed) {
+				t.Errorf("err = %v, want ErrHostNotAllowed", err)
+			}
+		})
+	}
+
+	t.Run("rejects unsupported methods", func(t *testing.T) {
+		if _, err := call(map[string]any{"url": srv.URL, "method": "TRACE"}); err == nil {
+			t.Error("TRACE accepted")
+		}
+	})
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"::1":                false,
+		"fd00::1":            false,
+		"fe80::1":            false,
+		"::ffff:127.0.0.1":   false,
+		"::ffff:169.254.0.1": false,
+	} {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// maxListEntries caps how many entries list_files returns.
+	maxListEntries = 500
+	// maxListDepth caps how deep list_files recurses.
+	maxListDepth = 10
+)
+
+// errListFull stops the walk once list_files has enough entries.
+var errListFull = errors.New("listing full")
+
+// FileEntry is one file or directory in a list_files result.
+type FileEntry struct {
+	Name     string `json:"name"` // path relative to the listed directory
+	Size     int64  `json:"size"`
+	IsDir    bool   `json:"is_dir"`
+	Modified string `json:"modified"` // RFC 3339
+}
+
+// FileListing is the result of list_files.
+type FileListing struct {
+	Entries   []FileEntry `json:"entries"`
+	Truncated bool        `json:"truncated,omitempty"`
+	Note      string      `json:"note,omitempty"`
+}
+
+// registerListFiles registers the list_files built-in tool.
+func (t *Tools) registerListFiles() {
+	t.registerAs(ToolSourceBuiltin, "list_files", ToolDef{
+		Description: fmt.Sprintf("List a directory. Returns JSON entries with name (relative to path), size, is_dir and modified. Use depth to recurse and pattern to filter by file name; at most %d entries are returned.", maxListEntries),
+		Fn: ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			path, _ := params["path"].(string)
+			pattern, _ := params["pattern"].(string)
+			depth := 1
+			if d, ok := toInt(params["depth"]); ok && d >= 1 {
+				depth = min(d, maxListDepth)
+			}
+
+			listing, err := listFiles(ctx, path, depth, pattern)
+			if err != nil {
+				return "", err
+			}
+			result, err := json.Marshal(listing)
+			if err != nil {
+				return "", err
+			}
+			return string(result), nil
+		}),
+		Params: map[string]ParamDef{
+			"path":    {Type: "string", Description: "Directory to list", Required: true},
+			"depth":   {Type: "integer", Description: fmt.Sprintf("How many levels to list: 1 (the default) is the directory's own entries, 2 includes its subdirectories' entries, up to %d", maxListDepth)},
+			"pattern": {Type: "string", Description: "Optional glob matched against file names, e.g. *.go"},
+		},
+		Access: AccessRead,
+	})
+}
+
+// listFiles walks root to the given depth and returns its entries, in
+// lexical order, whose names match pattern (all entries if pattern is
+// empty). Symlinks are listed but not followed.
+func listFiles(ctx context.Context, root string, depth int, pattern string) (*FileListing, error) {
+	if pattern != "" {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	if info, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+
+	listing := &FileListing{Entries: []FileEntry{}}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if path == root {
+			return err
+		}
+		if err != nil {
+			return nil // unreadable subdirectory; list what we can
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		// Don't read directories whose entries would be too deep to list.
+		var skip error
+		if d.IsDir() && strings.Count(rel, "/")+1 >= depth {
+			skip = fs.SkipDir
+		}
+
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, d.Name()); !ok {
+				return skip
+			}
+		}
+		if len(listing.Entries) == maxListEntries {
+			return errListFull
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return skip // removed since it was read
+		}
+		entry := FileEntry{
+			Name:     rel,
+			IsDir:    d.IsDir(),
+			Modified: info.ModTime().UTC().Format(time.RFC3339),
+		}
+		if !d.IsDir() {
+			entry.Size = info.Size()
+		}
+		listing.Entries = append(listing.Entries, entry)
+		return skip
+	})
+	if errors.Is(err, errListFull) {
+		listing.Truncated = true
+		listing.Note = fmt.Sprintf("only the first %d entries are shown; narrow the listing with a lower depth, a pattern or a subdirectory", maxListEntries)
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
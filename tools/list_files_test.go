@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListFiles(t *testing.T) {
+	root := t.TempDir()
+	sandbox := filepath.Join(root, "sandbox")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{filepath.Join(sandbox, "src", "lib"), outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for path, content := range map[string]string{
+		filepath.Join(sandbox, "notes.md"):                 "hello",
+		filepath.Join(sandbox, "src", "main.go"):           "package main",
+		filepath.Join(sandbox, "src", "lib", "util.go"):    "package lib",
+		filepath.Join(sandbox, "src", "lib", "README.txt"): "docs",
+		filepath.Join(outside, "secret.txt"):               "top secret",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(sandbox, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := NewTools(WithSandbox(sandbox))
+	ts.RegisterBuiltins()
+	list := func(t *testing.T, tools *Tools, params map[string]any) (FileListing, error) {
+		t.Helper()
+		out, err := tools.Execute(context.Background(), "list_files", params)
+		if err != nil {
+			return FileListing{}, err
+		}
+		if strings.Contains(out, "secret") {
+			t.Errorf("listing reached outside the sandbox: %s", out)
+		}
+		var listing FileListing
+		if err := json.Unmarshal([]byte(out), &listing); err != nil {
+			t.Fatalf("result is not a listing: %v: %s", err, out)
+		}
+		return listing, nil
+	}
+	names := func(l FileListing) string {
+		var n []string
+		for _, e := range l.Entries {
+			n = append(n, e.Name)
+		}
+		return strings.Join(n, " ")
+	}
+
+	t.Run("one level by default", func(t *testing.T) {
+		l, err := list(t, ts, map[string]any{"path": "."})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(l); got != "escape notes.md src" {
+			t.Errorf("entries = %q", got)
+		}
+		for _, e := range l.Entries {
+			switch e.Name {
+			case "notes.md":
+				if e.Size != 5 || e.IsDir || e.Modified == "" {
+					t.Errorf("notes.md = %+v", e)
+				}
+			case "src":
+				if !e.IsDir {
+					t.Errorf("src = %+v, want a directory", e)
+				}
+			case "escape":
+				if e.IsDir {
+					t.Errorf("symlink reported as a directory: %+v", e)
+				}
+			}
+		}
+	})
+
+	t.Run("depth and pattern", func(t *testing.T) {
+		l, err := list(t, ts, map[string]any{"path": "src", "depth": 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(l); got != "lib lib/README.txt lib/util.go main.go" {
+			t.Errorf("entries = %q", got)
+		}
+		l, err = list(t, ts, map[string]any{"path": ".", "depth": 5, "pattern": "*.go"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := names(l); got != "src/lib/util.go src/main.go" {
+			t.Errorf("*.go entries = %q", got)
+		}
+		if _, err := list(t, ts, map[string]any{"path": ".", "pattern": "["}); err == nil {
+			t.Error("bad pattern accepted")
+		}
+	})
+
+	t.Run("paths stay in the sandbox", func(t *testing.T) {
+		for _, path := range []string{"..", "../outside", outside, "src/../../outside"} {
+			l, err := list(t, ts, map[string]any{"path": path, "depth": 3})
+			if err == nil && strings.Contains(names(l), "secret") {
+				t.Errorf("%s listed the outside directory", path)
+			}
+		}
+	})
+
+	t.Run("sandbox policy", func(t *testing.T) {
+		strict := NewTools(WithSandboxPolicy(SandboxPolicy{Read: []string{sandbox}}))
+		strict.RegisterBuiltins()
+		for _, path := range []string{outside, filepath.Join(sandbox, "escape")} {
+			if _, err := list(t, strict, map[string]any{"path": path}); !errors.Is(err, ErrPathNotAllowed) {
+				t.Errorf("%s: err = %v, want ErrPathNotAllowed", path, err)
+			}
+		}
+	})
+
+	t.Run("caps entries", func(t *testing.T) {
+		big := filepath.Join(sandbox, "big")
+		os.Mkdir(big, 0755)
+		for i := range maxListEntries + 5 {
+			os.WriteFile(filepath.Join(big, fmt.Sprintf("f%04d.txt", i)), nil, 0644)
+		}
+		l, err := list(t, ts, map[string]any{"path": "big"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l.Entries) != maxListEntries || !l.Truncated || l.Note == "" {
+			t.Errorf("got %d entries, truncated %v, note %q; want %d, marked truncated", len(l.Entries), l.Truncated, l.Note, maxListEntries)
+		}
+	})
+}
@@ -2,9 +2,11 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/everydev1618/govega/mcp"
@@ -14,6 +16,10 @@ import (
 type mcpClientEntry struct {
 	client *mcp.Client
 	config mcp.ServerConfig
+
+	// down is set when a health check finds the server dead, so it is
+	// reconnected on later checks.
+	down atomic.Bool
 }
 
 // WithMCPServer adds an MCP server to the tools collection.
@@ -57,9 +63,12 @@ func (t *Tools) ConnectMCP(ctx context.Context) error {
 		}
 
 		// Register each MCP tool as a tool
-		for _, mcpTool := range mcpTools {
-			t.registerMCPTool(entry.client, mcpTool)
+		if err := t.registerMCPTools(entry.client, mcpTools); err != nil {
+			slog.Warn("mcp: skipping server", "server", entry.config.Name, "error", err)
+			entry.client.Close()
+			continue
 		}
+		t.registerMCPExtras(ctx, entry.client)
 		connected++
 		slog.Info("mcp: connected server", "server", entry.config.Name, "tools", len(mcpTools))
 	}
@@ -91,7 +100,7 @@ func (t *Tools) registerMCPReadResourceTool() {
 		return t.ReadMCPResource(ctx, server, uri)
 	}
 
-	t.Register("mcp_read_resource", ToolDef{
+	t.registerAs(ToolSourceMCP, "mcp_read_resource", ToolDef{
 		Description: "Read a resource from a connected MCP server",
 		Fn:          fn,
 		Params: map[string]ParamDef{
@@ -118,6 +127,10 @@ func (t *Tools) ConnectMCPServer(ctx context.Context, config mcp.ServerConfig) (
 		client.Close()
 		return 0, fmt.Errorf("discover tools from %s: %w", config.Name, err)
 	}
+	if err := t.registerMCPTools(client, mcpTools); err != nil {
+		client.Close()
+		return 0, err
+	}
 
 	entry := &mcpClientEntry{client: client, config: config}
 	t.mu.Lock()
@@ -127,9 +140,7 @@ func (t *Tools) ConnectMCPServer(ctx context.Context, config mcp.ServerConfig) (
 	t.mcpClients = append(t.mcpClients, entry)
 	t.mu.Unlock()
 
-	for _, mcpTool := range mcpTools {
-		t.registerMCPTool(client, mcpTool)
-	}
+	t.registerMCPExtras(ctx, client)
 
 	// Ensure the global resource tool exists.
 	t.registerMCPReadResourceTool()
@@ -204,23 +215,92 @@ func (t *Tools) DisconnectMCP() error {
 	return lastErr
 }
 
+// registerMCPTools registers a server's tools. If any prefixed name is
+// already taken by a local tool or another server, nothing is registered
+// and an error wrapping ErrToolAlreadyRegistered names the clashes.
+// Tools the server itself registered earlier are kept.
+func (t *Tools) registerMCPTools(client *mcp.Client, mcpTools []mcp.MCPTool) error {
+	server := client.Name()
+	var clashes []string
+	t.mu.RLock()
+	for _, mcpTool := range mcpTools {
+		name := server + "__" + mcpTool.Name
+		if tl, ok := t.tools[name]; ok && !tl.ownedBy(server) {
+			clashes = append(clashes, name)
+		}
+	}
+	t.mu.RUnlock()
+	if len(clashes) > 0 {
+		return fmt.Errorf("%w: MCP server %s: %s", ErrToolAlreadyRegistered, server, strings.Join(clashes, ", "))
+	}
+
+	for _, mcpTool := range mcpTools {
+		if err := t.registerMCPTool(client, mcpTool); err != nil {
+			slog.Warn("mcp: failed to register tool", "server", server, "tool", mcpTool.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// registerMCP registers a tool owned by an MCP server. Registering a name
+// the same server already owns is a no-op; any other existing tool of that
+// name is an ErrToolAlreadyRegistered error.
+func (t *Tools) registerMCP(server, name string, def ToolDef) error {
+	err := t.registerTool(ToolSourceMCP, server, name, def)
+	if errors.Is(err, ErrToolAlreadyRegistered) && t.serverOwns(server, name) {
+		return nil
+	}
+	return err
+}
+
+// serverOwns reports whether the named tool is registered by the MCP server.
+func (t *Tools) serverOwns(server, name string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tl, ok := t.tools[name]
+	return ok && tl.ownedBy(server)
+}
+
+// ownedBy reports whether the tool belongs to the named MCP server.
+func (tl *tool) ownedBy(server string) bool {
+	return tl.source == ToolSourceMCP && tl.server == server && !tl.removed.Load()
+}
+
+// ResolveToolServer returns the MCP server that owns the named tool. ok is
+// false for unknown tools and tools not provided by an MCP server.
+func (t *Tools) ResolveToolServer(name string) (server string, ok bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	tl, found := t.tools[name]
+	if !found || tl.server == "" || tl.removed.Load() {
+		return "", false
+	}
+	return tl.server, true
+}
+
 // registerMCPTool registers a single MCP tool as a tool.
-func (t *Tools) registerMCPTool(client *mcp.Client, mcpTool mcp.MCPTool) {
+func (t *Tools) registerMCPTool(client *mcp.Client, mcpTool mcp.MCPTool) error {
 	// Create prefixed name: server__toolname
 	name := client.Name() + "__" + mcpTool.Name
 
 	// Build params from input schema
 	params := extractParamsFromSchema(mcpTool.InputSchema)
 
-	// Create executor that calls the MCP tool
+	// Create executor that calls the MCP tool. The client is looked up on
+	// each call so a reconnected server is picked up.
+	server := client.Name()
 	fn := func(ctx context.Context, args map[string]any) (string, error) {
-		if client.Name() == "slack" {
+		if server == "slack" {
 			convertSlackArgs(args)
 		}
-		return client.CallTool(ctx, mcpTool.Name, args)
+		c := t.mcpClient(server)
+		if c == nil {
+			c = client
+		}
+		return c.CallTool(ctx, mcpTool.Name, args)
 	}
 
-	t.Register(name, ToolDef{
+	return t.registerMCP(server, name, ToolDef{
 		Description: mcpTool.Description,
 		Fn:          fn,
 		Params:      params,
@@ -312,6 +392,7 @@ func (t *Tools) FilterMCP(patterns ...string) *Tools {
 		tools:      make(map[string]*tool),
 		middleware: t.middleware,
 		sandbox:    t.sandbox,
+		policy:     t.policy,
 		mcpClients: t.mcpClients,
 	}
 
@@ -358,6 +439,8 @@ type MCPServerStatus struct {
 	URL       string   `json:"url,omitempty"`
 	Command   string   `json:"command,omitempty"`
 	Tools     []string `json:"tools"`
+	Resources []string `json:"resources,omitempty"`
+	Prompts   []string `json:"prompts,omitempty"`
 }
 
 // MCPServerStatuses returns the status of all configured MCP servers.
@@ -381,6 +464,12 @@ func (t *Tools) MCPServerStatuses() []MCPServerStatus {
 		for _, mcpTool := range entry.client.Tools() {
 			s.Tools = append(s.Tools, mcpTool.Name)
 		}
+		for _, r := range entry.client.Resources() {
+			s.Resources = append(s.Resources, r.URI)
+		}
+		for _, p := range entry.client.Prompts() {
+			s.Prompts = append(s.Prompts, p.Name)
+		}
 		existing, seen := byName[s.Name]
 		if !seen {
 			order = append(order, s.Name)
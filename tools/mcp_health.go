@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/everydev1618/govega/mcp"
+)
+
+// mcpHealthTimeout bounds a single server's health check or reconnect.
+const mcpHealthTimeout = 15 * time.Second
+
+// MCPStatusFunc is called when a health check finds an MCP server has gone
+// down (connected false, with the error that showed it) or has been
+// reconnected (connected true).
+type MCPStatusFunc func(server string, connected bool, err error)
+
+// CheckMCPHealth checks every connected MCP server with a tools/list call.
+// A server that fails is closed and marked down, and every server marked
+// down is reconnected from its config. onChange, if not nil, is called for
+// each transition. Servers that never connected are left alone.
+func (t *Tools) CheckMCPHealth(ctx context.Context, onChange MCPStatusFunc) {
+	t.mu.RLock()
+	clients := slices.Clone(t.mcpClients)
+	t.mu.RUnlock()
+
+	for _, entry := range clients {
+		name := entry.config.Name
+		if entry.client.Connected() {
+			checkCtx, cancel := context.WithTimeout(ctx, mcpHealthTimeout)
+			_, err := entry.client.DiscoverTools(checkCtx)
+			cancel()
+			if err == nil {
+				continue
+			}
+			slog.Warn("mcp: health check failed", "server", name, "error", err)
+			entry.client.Close()
+			entry.down.Store(true)
+			if onChange != nil {
+				onChange(name, false, err)
+			}
+		}
+		if !entry.down.Load() || ctx.Err() != nil {
+			continue
+		}
+
+		if err := t.reconnectMCP(ctx, entry); err != nil {
+			slog.Warn("mcp: reconnect failed", "server", name, "error", err)
+			continue
+		}
+		if onChange != nil {
+			onChange(name, true, nil)
+		}
+	}
+}
+
+// reconnectMCP replaces a downed entry with a freshly connected client and
+// registers any tools the server added in the meantime.
+func (t *Tools) reconnectMCP(ctx context.Context, entry *mcpClientEntry) error {
+	ctx, cancel := context.WithTimeout(ctx, mcpHealthTimeout)
+	defer cancel()
+
+	client, err := mcp.NewClient(entry.config)
+	if err != nil {
+		return err
+	}
+	if err := client.Connect(ctx); err != nil {
+		return err
+	}
+	mcpTools, err := client.DiscoverTools(ctx)
+	if err != nil {
+		client.Close()
+		return err
+	}
+
+	// Swap in a new slice so snapshots taken by readers stay consistent.
+	t.mu.Lock()
+	i := slices.Index(t.mcpClients, entry)
+	if i < 0 {
+		t.mu.Unlock()
+		client.Close()
+		return errors.New("server was disconnected while reconnecting")
+	}
+	clients := slices.Clone(t.mcpClients)
+	clients[i] = &mcpClientEntry{client: client, config: entry.config}
+	t.mcpClients = clients
+	t.mu.Unlock()
+
+	if err := t.registerMCPTools(client, mcpTools); err != nil {
+		client.Close()
+		return err
+	}
+	t.registerMCPExtras(ctx, client)
+	slog.Info("mcp: reconnected server", "server", entry.config.Name, "tools", len(mcpTools))
+	return nil
+}
+
+// mcpClient returns the connected client for the named server, or nil.
+func (t *Tools) mcpClient(name string) *mcp.Client {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, entry := range t.mcpClients {
+		if entry.config.Name == name && entry.client.Connected() {
+			return entry.client
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/everydev1618/govega/mcp"
+)
+
+// fakeMCPServer answers JSON-RPC over HTTP with one "echo" tool, plus a
+// resource and a prompt when caps advertises them. It fails every request
+// while down is set.
+func fakeMCPServer(t *testing.T, down *atomic.Bool, caps mcp.Capabilities) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req mcp.JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result any = map[string]any{}
+		switch req.Method {
+		case "initialize":
+			result = mcp.InitializeResult{ProtocolVersion: mcp.ProtocolVersion, Capabilities: caps}
+		case "tools/list":
+			result = mcp.ToolsListResult{Tools: []mcp.MCPTool{{Name: "echo", InputSchema: map[string]any{}}}}
+		case "tools/call":
+			result = mcp.ToolCallResult{Content: []mcp.ContentBlock{{Type: "text", Text: "pong"}}}
+		case "resources/list":
+			result = mcp.ResourcesListResult{Resources: []mcp.MCPResource{{URI: "file:///notes.md", Name: "notes"}}}
+		case "resources/read":
+			result = mcp.ResourceReadResult{Contents: []mcp.ResourceContent{{URI: "file:///notes.md", Text: "remember the milk"}}}
+		case "prompts/list":
+			result = mcp.PromptsListResult{Prompts: []mcp.MCPPrompt{{
+				Name:      "review",
+				Arguments: []mcp.PromptArgument{{Name: "topic", Required: true}},
+			}}}
+		case "prompts/get":
+			var params struct {
+				Arguments map[string]string `json:"arguments"`
+			}
+			raw, _ := json.Marshal(req.Params)
+			json.Unmarshal(raw, &params)
+			result = mcp.PromptGetResult{Messages: []mcp.PromptMessage{{
+				Role:    "user",
+				Content: mcp.ContentBlock{Type: "text", Text: "Review " + params.Arguments["topic"]},
+			}}}
+		}
+		data, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(mcp.JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCheckMCPHealth(t *testing.T) {
+	var down atomic.Bool
+	srv := fakeMCPServer(t, &down, mcp.Capabilities{})
+	ctx := context.Background()
+
+	tl := NewTools()
+	if _, err := tl.ConnectMCPServer(ctx, mcp.ServerConfig{Name: "fake", Transport: mcp.TransportHTTP, URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	type transition struct {
+		server    string
+		connected bool
+	}
+	var got []transition
+	record := func(server string, connected bool, err error) {
+		got = append(got, transition{server, connected})
+	}
+
+	tl.CheckMCPHealth(ctx, record)
+	if len(got) != 0 {
+		t.Fatalf("healthy server reported %v", got)
+	}
+
+	down.Store(true)
+	tl.CheckMCPHealth(ctx, record)
+	if len(got) != 1 || got[0] != (transition{"fake", false}) {
+		t.Fatalf("transitions = %v, want fake down", got)
+	}
+	if tl.MCPServerConnected("fake") {
+		t.Error("server still reported connected after a failed check")
+	}
+
+	down.Store(false)
+	tl.CheckMCPHealth(ctx, record)
+	if len(got) != 2 || got[1] != (transition{"fake", true}) {
+		t.Fatalf("transitions = %v, want fake reconnected", got)
+	}
+	if !tl.MCPServerConnected("fake") {
+		t.Error("server not connected after reconnect")
+	}
+	if out, err := tl.Execute(ctx, "fake__echo", map[string]any{}); err != nil || out != "pong" {
+		t.Errorf("tool after reconnect = %q, %v; want pong", out, err)
+	}
+}
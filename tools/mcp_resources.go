@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/everydev1618/govega/mcp"
+)
+
+// maxListedMCPEntries caps how many resources or prompts are named in a
+// tool description, to keep the schema small.
+const maxListedMCPEntries = 20
+
+// registerMCPExtras registers "server__read_resource" and
+// "server__get_prompt" for a server that advertises resources or prompts.
+// Servers without those capabilities get neither, and a server tool with
+// the same name wins.
+func (t *Tools) registerMCPExtras(ctx context.Context, client *mcp.Client) {
+	server := client.Name()
+
+	if client.HasResources() {
+		resources, err := client.DiscoverResources(ctx)
+		if err != nil {
+			slog.Warn("mcp: failed to discover resources", "server", server, "error", err)
+		} else {
+			t.registerMCPResourceTool(server, resources)
+		}
+	}
+
+	if client.HasPrompts() {
+		prompts, err := client.DiscoverPrompts(ctx)
+		if err != nil {
+			slog.Warn("mcp: failed to discover prompts", "server", server, "error", err)
+		} else {
+			t.registerMCPPromptTool(server, prompts)
+		}
+	}
+}
+
+// registerMCPResourceTool registers server__read_resource, naming the
+// server's resources in its description.
+func (t *Tools) registerMCPResourceTool(server string, resources []mcp.MCPResource) {
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "Read a resource from the %s MCP server by URI.", server)
+	if len(resources) > 0 {
+		desc.WriteString(" Available resources:")
+		for i, r := range resources {
+			if i == maxListedMCPEntries {
+				fmt.Fprintf(&desc, "\n- ... and %d more", len(resources)-i)
+				break
+			}
+			fmt.Fprintf(&desc, "\n- %s (%s)", r.URI, r.Name)
+			if r.Description != "" {
+				desc.WriteString(": " + r.Description)
+			}
+		}
+	}
+
+	name := server + "__read_resource"
+	err := t.registerMCP(server, name, ToolDef{
+		Description: desc.String(),
+		Fn: func(ctx context.Context, params map[string]any) (string, error) {
+			uri, _ := params["uri"].(string)
+			return t.ReadMCPResource(ctx, server, uri)
+		},
+		Params: map[string]ParamDef{
+			"uri": {Type: "string", Description: "Resource URI to read", Required: true},
+		},
+	})
+	if err != nil {
+		slog.Warn("mcp: failed to register resource tool", "server", server, "tool", name, "error", err)
+	}
+}
+
+// registerMCPPromptTool registers server__get_prompt, describing the
+// server's prompts and their arguments.
+func (t *Tools) registerMCPPromptTool(server string, prompts []mcp.MCPPrompt) {
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "Get a prompt template from the %s MCP server, filled in with arguments.", server)
+	if len(prompts) > 0 {
+		desc.WriteString(" Available prompts:")
+		for i, p := range prompts {
+			if i == maxListedMCPEntries {
+				fmt.Fprintf(&desc, "\n- ... and %d more", len(prompts)-i)
+				break
+			}
+			fmt.Fprintf(&desc, "\n- %s", p.Name)
+			var args []string
+			for _, a := range p.Arguments {
+				if a.Required {
+					args = append(args, a.Name+" (required)")
+				} else {
+					args = append(args, a.Name)
+				}
+			}
+			if len(args) > 0 {
+				fmt.Fprintf(&desc, " [%s]", strings.Join(args, ", "))
+			}
+			if p.Description != "" {
+				desc.WriteString(": " + p.Description)
+			}
+		}
+	}
+
+	name := server + "__get_prompt"
+	err := t.registerMCP(server, name, ToolDef{
+		Description: desc.String(),
+		Fn: func(ctx context.Context, params map[string]any) (string, error) {
+			prompt, _ := params["name"].(string)
+			args := make(map[string]string)
+			if raw, ok := params["arguments"].(map[string]any); ok {
+				for k, v := range raw {
+					args[k] = fmt.Sprint(v)
+				}
+			}
+			return t.GetMCPPrompt(ctx, server, prompt, args)
+		},
+		Params: map[string]ParamDef{
+			"name":      {Type: "string", Description: "Prompt name", Required: true},
+			"arguments": {Type: "object", Description: "Prompt arguments, as string values", Required: false},
+		},
+	})
+	if err != nil {
+		slog.Warn("mcp: failed to register prompt tool", "server", server, "tool", name, "error", err)
+	}
+}
+
+// MCPResources returns the resources a connected MCP server offers, as
+// discovered when it connected. It fails if the server does not advertise
+// resources.
+func (t *Tools) MCPResources(server string) ([]mcp.MCPResource, error) {
+	client, err := t.connectedMCPClient(server)
+	if err != nil {
+		return nil, err
+	}
+	if !client.HasResources() {
+		return nil, fmt.Errorf("MCP server %q does not offer resources", server)
+	}
+	return client.Resources(), nil
+}
+
+// MCPPrompts returns the prompts a connected MCP server offers, as
+// discovered when it connected. It fails if the server does not advertise
+// prompts.
+func (t *Tools) MCPPrompts(server string) ([]mcp.MCPPrompt, error) {
+	client, err := t.connectedMCPClient(server)
+	if err != nil {
+		return nil, err
+	}
+	if !client.HasPrompts() {
+		return nil, fmt.Errorf("MCP server %q does not offer prompts", server)
+	}
+	return client.Prompts(), nil
+}
+
+// GetMCPPrompt renders a prompt on a connected MCP server and returns its
+// messages as text.
+func (t *Tools) GetMCPPrompt(ctx context.Context, server, name string, args map[string]string) (string, error) {
+	client, err := t.connectedMCPClient(server)
+	if err != nil {
+		return "", err
+	}
+	if !client.HasPrompts() {
+		return "", fmt.Errorf("MCP server %q does not offer prompts", server)
+	}
+	return client.GetPrompt(ctx, name, args)
+}
+
+// connectedMCPClient returns the named server's client, or an error if the
+// server is unknown or not connected.
+func (t *Tools) connectedMCPClient(server string) (*mcp.Client, error) {
+	if client := t.mcpClient(server); client != nil {
+		return client, nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, entry := range t.mcpClients {
+		if entry.config.Name == server {
+			return nil, fmt.Errorf("MCP server %q not connected", server)
+		}
+	}
+	return nil, fmt.Errorf("MCP server %q not found", server)
+}
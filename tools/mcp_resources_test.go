@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/everydev1618/govega/mcp"
+)
+
+func TestMCPResourcesAndPrompts(t *testing.T) {
+	var down atomic.Bool
+	ctx := context.Background()
+	tl := NewTools()
+
+	full := fakeMCPServer(t, &down, mcp.Capabilities{
+		Resources: &mcp.ResourcesCapability{},
+		Prompts:   &mcp.PromptsCapability{},
+	})
+	if _, err := tl.ConnectMCPServer(ctx, mcp.ServerConfig{Name: "docs", Transport: mcp.TransportHTTP, URL: full.URL}); err != nil {
+		t.Fatal(err)
+	}
+	plain := fakeMCPServer(t, &down, mcp.Capabilities{})
+	if _, err := tl.ConnectMCPServer(ctx, mcp.ServerConfig{Name: "plain", Transport: mcp.TransportHTTP, URL: plain.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	resources, err := tl.MCPResources("docs")
+	if err != nil || len(resources) != 1 || resources[0].URI != "file:///notes.md" {
+		t.Fatalf("MCPResources = %+v, %v", resources, err)
+	}
+	got, err := tl.Execute(ctx, "docs__read_resource", map[string]any{"uri": "file:///notes.md"})
+	if err != nil || got != "remember the milk" {
+		t.Errorf("docs__read_resource = %q, %v", got, err)
+	}
+
+	prompts, err := tl.MCPPrompts("docs")
+	if err != nil || len(prompts) != 1 || prompts[0].Name != "review" {
+		t.Fatalf("MCPPrompts = %+v, %v", prompts, err)
+	}
+	got, err = tl.Execute(ctx, "docs__get_prompt", map[string]any{"name": "review", "arguments": map[string]any{"topic": "the budget"}})
+	if err != nil || !strings.Contains(got, "Review the budget") {
+		t.Errorf("docs__get_prompt = %q, %v", got, err)
+	}
+
+	t.Run("gated on capabilities", func(t *testing.T) {
+		if _, err := tl.MCPResources("plain"); err == nil {
+			t.Error("MCPResources succeeded for a server without resources")
+		}
+		for _, name := range []string{"plain__read_resource", "plain__get_prompt"} {
+			if _, err := tl.Execute(ctx, name, map[string]any{}); err == nil {
+				t.Errorf("%s registered for a server without the capability", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/everydev1618/govega/mcp"
+)
+
+func TestMCPToolCollision(t *testing.T) {
+	var down atomic.Bool
+	srv := fakeMCPServer(t, &down, mcp.Capabilities{})
+	ctx := context.Background()
+
+	tl := NewTools()
+	local := func(ctx context.Context, params map[string]any) (string, error) { return "local", nil }
+	if err := tl.Register("fake__echo", ToolDef{Fn: local}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := tl.ConnectMCPServer(ctx, mcp.ServerConfig{Name: "fake", Transport: mcp.TransportHTTP, URL: srv.URL})
+	if !errors.Is(err, ErrToolAlreadyRegistered) {
+		t.Fatalf("ConnectMCPServer = %v, want ErrToolAlreadyRegistered", err)
+	}
+	if tl.MCPServerConnected("fake") {
+		t.Error("clashing server was left connected")
+	}
+	if got, _ := tl.Execute(ctx, "fake__echo", map[string]any{}); got != "local" {
+		t.Errorf("fake__echo = %q, want the local tool kept", got)
+	}
+	if server, ok := tl.ResolveToolServer("fake__echo"); ok {
+		t.Errorf("local tool resolved to server %q", server)
+	}
+
+	if _, err := tl.ConnectMCPServer(ctx, mcp.ServerConfig{Name: "other", Transport: mcp.TransportHTTP, URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if server, ok := tl.ResolveToolServer("other__echo"); !ok || server != "other" {
+		t.Errorf("ResolveToolServer(other__echo) = %q, %v; want other", server, ok)
+	}
+	if err := tl.Register("other__echo", ToolDef{Fn: local}); !errors.Is(err, ErrToolAlreadyRegistered) {
+		t.Errorf("Register over an MCP tool = %v, want ErrToolAlreadyRegistered", err)
+	}
+	if got, _ := tl.Execute(ctx, "other__echo", map[string]any{}); got != "pong" {
+		t.Errorf("other__echo = %q, want the server's tool kept", got)
+	}
+}
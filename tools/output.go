@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxResultBytes is the tool result cap a new Tools starts with.
+const DefaultMaxResultBytes = 128 * 1024
+
+// DefaultMaxArgBytes is the tool argument cap a new Tools starts with.
+const DefaultMaxArgBytes = 128 * 1024
+
+// maxBinaryOutputBytes caps how much binary output is base64-encoded into a
+// tool result.
+const maxBinaryOutputBytes = 4096
+
+// checkArgSize returns an ErrArgumentsTooLarge error, worded for the
+// model, if params encode to more than limit bytes of JSON. A limit of 0
+// means no cap.
+func checkArgSize(params map[string]any, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(params)
+	if err != nil || len(data) <= limit {
+		return nil
+	}
+	return fmt.Errorf("%w (%d bytes, limit %d): use a file reference instead: write the content to a file and pass its path", ErrArgumentsTooLarge, len(data), limit)
+}
+
+// SanitizeOutput makes tool output safe to send to a model. Valid UTF-8 is
+// returned unchanged. Output that looks binary (it contains NUL bytes, or
+// more than 30% of it is invalid UTF-8) is base64-encoded behind a
+// marker, up to maxBinaryOutputBytes; otherwise invalid bytes are replaced
+// with U+FFFD.
+func SanitizeOutput(s string) string {
+	if utf8.ValidString(s) && !strings.ContainsRune(s, 0) {
+		return s
+	}
+	if !looksBinary(s) {
+		return strings.ToValidUTF8(s, "�")
+	}
+
+	data := s
+	note := ""
+	if len(data) > maxBinaryOutputBytes {
+		data = data[:maxBinaryOutputBytes]
+		note = fmt.Sprintf(", first %d shown", maxBinaryOutputBytes)
+	}
+	return fmt.Sprintf("[binary output: %d bytes%s, base64]\n%s",
+		len(s), note, base64.StdEncoding.EncodeToString([]byte(data)))
+}
+
+// looksBinary reports whether s contains NUL bytes or is more than 30%
+// invalid UTF-8. Text cut mid-character has only a few invalid bytes.
+func looksBinary(s string) bool {
+	if strings.IndexByte(s, 0) >= 0 {
+		return true
+	}
+	invalid := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		i += size
+	}
+	return invalid*10 > len(s)*3
+}
+
+// truncateOutput cuts s to at most limit bytes, backing off to a UTF-8
+// boundary, and appends a marker with how many of its bytes were kept so
+// the model knows the result is partial. limit <= 0 means no cap.
+func truncateOutput(s string, limit int) string {
+	if limit <= 0 || len(s) <= limit {
+		return s
+	}
+	n := limit
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s\n\n[output truncated: %d of %d bytes shown]", s[:n], n, len(s))
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeOutput(t *testing.T) {
+	if got := SanitizeOutput("héllo\n"); got != "héllo\n" {
+		t.Errorf("valid UTF-8 changed: %q", got)
+	}
+
+	// A multi-byte character cut off by truncation.
+	if got := SanitizeOutput("total: 5€"[:len("total: 5€")-1]); got != "total: 5�" {
+		t.Errorf("truncated text = %q", got)
+	}
+
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	got := SanitizeOutput(png)
+	header, encoded, ok := strings.Cut(got, "\n")
+	if !ok || header != "[binary output: 16 bytes, base64]" {
+		t.Fatalf("binary output = %q", got)
+	}
+	if raw, err := base64.StdEncoding.DecodeString(encoded); err != nil || string(raw) != png {
+		t.Errorf("decoded %q, %v; want the original bytes", raw, err)
+	}
+
+	big := SanitizeOutput(strings.Repeat("\x00\xff", maxBinaryOutputBytes))
+	if !strings.HasPrefix(big, "[binary output: 8192 bytes, first 4096 shown, base64]") || !utf8.ValidString(big) {
+		t.Errorf("large binary output = %.80q", big)
+	}
+}
+
+func TestToolResultCap(t *testing.T) {
+	ctx := context.Background()
+	ts := NewTools(WithMaxResultBytes(10))
+	ts.Register("dump", func(text string) string { return text })
+	ts.Register("dump_all", ToolDef{Fn: func(text string) string { return text }, Args: []string{"text"}, MaxResultBytes: -1})
+	ts.Register("dump_more", ToolDef{Fn: func(text string) string { return text }, Args: []string{"text"}, MaxResultBytes: 20})
+
+	out, err := ts.Execute(ctx, "dump", map[string]any{"text": strings.Repeat("a", 25)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Repeat("a", 10) + "\n\n[output truncated: 10 of 25 bytes shown]"; out != want {
+		t.Errorf("capped = %q, want %q", out, want)
+	}
+
+	// The cut backs off to a character boundary: "é" is bytes 9-10.
+	out, _ = ts.Execute(ctx, "dump", map[string]any{"text": "abcdefghiéjk"})
+	if out != "abcdefghi\n\n[output truncated: 9 of 13 bytes shown]" || !utf8.ValidString(out) {
+		t.Errorf("multi-byte cut = %q", out)
+	}
+
+	if out, _ := ts.Execute(ctx, "dump", map[string]any{"text": "short"}); out != "short" {
+		t.Errorf("under the cap = %q", out)
+	}
+	if out, _ := ts.Execute(ctx, "dump_all", map[string]any{"text": strings.Repeat("b", 25)}); out != strings.Repeat("b", 25) {
+		t.Errorf("uncapped tool = %q", out)
+	}
+	if out, _ := ts.Execute(ctx, "dump_more", map[string]any{"text": strings.Repeat("c", 25)}); !strings.HasSuffix(out, "[output truncated: 20 of 25 bytes shown]") {
+		t.Errorf("per-tool cap = %q", out)
+	}
+
+	// Filter copies keep the collection's cap.
+	if out, _ := ts.Filter("dump").Execute(ctx, "dump", map[string]any{"text": strings.Repeat("d", 25)}); !strings.HasSuffix(out, "[output truncated: 10 of 25 bytes shown]") {
+		t.Errorf("filtered = %q", out)
+	}
+}
+
+func TestToolArgumentCap(t *testing.T) {
+	ctx := context.Background()
+	ts := NewTools(WithMaxArgBytes(100))
+	var calls int
+	ts.Register("save", func(content string) string { calls++; return "saved" })
+	ts.Register("save_big", ToolDef{Fn: func(content string) string { calls++; return "saved" }, Args: []string{"content"}, MaxArgBytes: -1})
+
+	_, err := ts.Execute(ctx, "save", map[string]any{"content": strings.Repeat("x", 500)})
+	if !errors.Is(err, ErrArgumentsTooLarge) {
+		t.Fatalf("oversized call: err = %v, want ErrArgumentsTooLarge", err)
+	}
+	if !strings.Contains(err.Error(), "use a file reference") {
+		t.Errorf("error %q does not tell the model what to do instead", err)
+	}
+	if calls != 0 {
+		t.Errorf("tool ran %d times for a rejected call", calls)
+	}
+
+	if out, err := ts.Execute(ctx, "save", map[string]any{"content": "small"}); err != nil || out != "saved" {
+		t.Errorf("small call = %q, %v", out, err)
+	}
+	if out, err := ts.Execute(ctx, "save_big", map[string]any{"content": strings.Repeat("x", 500)}); err != nil || out != "saved" {
+		t.Errorf("exempt tool = %q, %v", out, err)
+	}
+	if _, err := ts.Filter("save").Execute(ctx, "save", map[string]any{"content": strings.Repeat("x", 500)}); !errors.Is(err, ErrArgumentsTooLarge) {
+		t.Errorf("filtered copy: err = %v, want the collection's cap", err)
+	}
+	if calls != 2 {
+		t.Errorf("tool ran %d times, want 2", calls)
+	}
+}
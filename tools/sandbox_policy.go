@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathAccess says whether a tool reads or writes the paths it is given.
+type PathAccess string
+
+const (
+	// AccessRead tools only read their paths; a SandboxPolicy lets them use
+	// any Read or Write directory.
+	AccessRead PathAccess = "read"
+	// AccessWrite tools may modify their paths; a SandboxPolicy confines
+	// them to Write directories.
+	AccessWrite PathAccess = "write"
+)
+
+// readOnlyPrefixes are tool name prefixes treated as AccessRead when a tool
+// does not declare its access.
+var readOnlyPrefixes = []string{"read_", "list_", "get_", "search_", "find_", "summarize_"}
+
+// SandboxPolicy confines the path arguments of tool calls to allowed
+// directories, separately for reads and writes. A path argument is any
+// param named "path" or ending in "_path" or "Path".
+//
+// Unlike WithSandbox, which redirects stray paths into the sandbox, a
+// policy rejects them: paths outside the allowed directories, including
+// ones that escape through ".." or a symlink, fail with ErrPathNotAllowed.
+// Relative paths resolve against the first Write directory, or the first
+// Read directory when there are no Write directories.
+type SandboxPolicy struct {
+	// Read lists directories tools may read from. Write directories are
+	// readable too.
+	Read []string
+	// Write lists directories tools may write to.
+	Write []string
+}
+
+// WithSandboxPolicy confines tool path arguments to the policy's read and
+// write directories. A tool's access comes from ToolDef.Access; tools that
+// don't declare it are treated as readers when their name starts with
+// read_, list_, get_, search_, find_ or summarize_, and as writers
+// otherwise. The policy takes precedence over WithSandbox for path
+// arguments; WithSandbox still sets exec's working directory.
+func WithSandboxPolicy(p SandboxPolicy) ToolsOption {
+	return func(t *Tools) {
+		t.policy = p.resolved()
+	}
+}
+
+// resolved returns a copy of p with every directory made absolute and its
+// symlinks resolved, so containment checks compare real paths.
+func (p SandboxPolicy) resolved() *SandboxPolicy {
+	resolve := func(dirs []string) []string {
+		out := make([]string, 0, len(dirs))
+		for _, d := range dirs {
+			if abs, err := realPath(d); err == nil {
+				out = append(out, abs)
+			}
+		}
+		return out
+	}
+	return &SandboxPolicy{Read: resolve(p.Read), Write: resolve(p.Write)}
+}
+
+// pathAccess returns the declared or inferred access of a tool.
+func (tl *tool) pathAccess() PathAccess {
+	if tl.access != "" {
+		return tl.access
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(tl.name, prefix) {
+			return AccessRead
+		}
+	}
+	return AccessWrite
+}
+
+// apply checks and resolves the path arguments in params for a tool with
+// the given access, returning a copy with absolute, symlink-free paths.
+func (p *SandboxPolicy) apply(access PathAccess, params map[string]any) (map[string]any, error) {
+	allowed := p.Write
+	if access == AccessRead {
+		allowed = append(append([]string{}, p.Write...), p.Read...)
+	}
+	base := ""
+	if len(p.Write) > 0 {
+		base = p.Write[0]
+	} else if len(p.Read) > 0 {
+		base = p.Read[0]
+	}
+
+	result := make(map[string]any, len(params))
+	for k, v := range params {
+		s, ok := v.(string)
+		if !ok || !isPathParam(k) {
+			result[k] = v
+			continue
+		}
+
+		path := s
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+		real, err := realPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrPathNotAllowed, s, err)
+		}
+		if !withinAny(real, allowed) {
+			return nil, fmt.Errorf("%w: %s is outside the directories this tool may %s", ErrPathNotAllowed, s, access)
+		}
+		result[k] = real
+	}
+	return result, nil
+}
+
+// isPathParam reports whether a param name holds a filesystem path.
+func isPathParam(name string) bool {
+	return name == "path" || strings.HasSuffix(name, "_path") || strings.HasSuffix(name, "Path")
+}
+
+// realPath returns the absolute path with symlinks resolved. For a path
+// that doesn't exist yet, the deepest existing ancestor is resolved and
+// the rest appended, so a file about to be created is checked against
+// where it will really land.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var rest []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{real}, rest...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		if _, lerr := os.Lstat(dir); lerr == nil {
+			// A dangling symlink: its target is unknown, so refuse it.
+			return "", fmt.Errorf("dangling symlink %s", dir)
+		}
+		if dir == filepath.Dir(dir) {
+			return abs, nil
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// withinAny reports whether path is one of dirs or inside one of them.
+func withinAny(path string, dirs []string) bool {
+	for _, d := range dirs {
+		rel, err := filepath.Rel(d, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
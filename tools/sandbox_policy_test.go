@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSandboxPolicy(t *testing.T) {
+	root := t.TempDir()
+	knowledge := filepath.Join(root, "knowledge")
+	workspace := filepath.Join(root, "workspace")
+	outside := filepath.Join(root, "outside")
+	for _, d := range []string{knowledge, workspace, outside} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(knowledge, "facts.md"), []byte("the sky is blue"), 0644)
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("hunter2"), 0644)
+
+	// Symlinks inside allowed dirs that point outside them.
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(knowledge, "link.txt")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	os.Symlink(outside, filepath.Join(workspace, "escape"))
+	os.Symlink(filepath.Join(outside, "missing.txt"), filepath.Join(workspace, "dangling.txt"))
+
+	ts := NewTools(WithSandboxPolicy(SandboxPolicy{
+		Read:  []string{knowledge},
+		Write: []string{workspace},
+	}))
+	ts.RegisterBuiltins()
+	ts.Register("publish", ToolDef{
+		Fn:     ToolFunc(func(ctx context.Context, params map[string]any) (string, error) { return params["path"].(string), nil }),
+		Params: map[string]ParamDef{"path": {Type: "string", Required: true}},
+		Access: AccessRead,
+	})
+
+	ctx := context.Background()
+	exec := func(tool string, params map[string]any) (string, error) {
+		return ts.Execute(ctx, tool, params)
+	}
+
+	t.Run("reads from read dirs", func(t *testing.T) {
+		out, err := exec("read_file", map[string]any{"path": filepath.Join(knowledge, "facts.md")})
+		if err != nil || out != "the sky is blue" {
+			t.Errorf("read_file = %q, %v", out, err)
+		}
+	})
+
+	t.Run("writes to write dirs", func(t *testing.T) {
+		if _, err := exec("write_file", map[string]any{"path": "notes/out.md", "content": "hi"}); err != nil {
+			// notes/ doesn't exist; the write itself fails, but not the policy.
+			if errors.Is(err, ErrPathNotAllowed) {
+				t.Fatalf("write to new subdir rejected by policy: %v", err)
+			}
+		}
+		if _, err := exec("write_file", map[string]any{"path": "out.md", "content": "hi"}); err != nil {
+			t.Fatalf("write_file: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(workspace, "out.md")); string(data) != "hi" {
+			t.Errorf("relative write landed elsewhere; workspace/out.md = %q", data)
+		}
+	})
+
+	t.Run("declared access overrides name", func(t *testing.T) {
+		if _, err := exec("publish", map[string]any{"path": filepath.Join(knowledge, "facts.md")}); err != nil {
+			t.Errorf("read-access tool rejected in read dir: %v", err)
+		}
+	})
+
+	rejected := []struct {
+		name   string
+		tool   string
+		params map[string]any
+	}{
+		{"write to read-only dir", "write_file", map[string]any{"path": filepath.Join(knowledge, "facts.md"), "content": "x"}},
+		{"relative traversal", "read_file", map[string]any{"path": "../outside/secret.txt"}},
+		{"traversal inside absolute path", "read_file", map[string]any{"path": filepath.Join(workspace, "..", "outside", "secret.txt")}},
+		{"absolute path outside", "read_file", map[string]any{"path": filepath.Join(outside, "secret.txt")}},
+		{"sibling with shared prefix", "read_file", map[string]any{"path": knowledge + "-evil/x"}},
+		{"symlinked file", "read_file", map[string]any{"path": filepath.Join(knowledge, "link.txt")}},
+		{"symlinked dir", "write_file", map[string]any{"path": "escape/pwned.txt", "content": "x"}},
+		{"dangling symlink", "write_file", map[string]any{"path": "dangling.txt", "content": "x"}},
+	}
+	for _, tc := range rejected {
+		t.Run("rejects "+tc.name, func(t *testing.T) {
+			_, err := exec(tc.tool, tc.params)
+			if !errors.Is(err, ErrPathNotAllowed) {
+				t.Fatalf("err = %v, want ErrPathNotAllowed", err)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(outside, "pwned.txt")); err == nil {
+		t.Error("write escaped through symlinked dir")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "missing.txt")); err == nil {
+		t.Error("write escaped through dangling symlink")
+	}
+	if data, _ := os.ReadFile(filepath.Join(knowledge, "facts.md")); string(data) != "the sky is blue" {
+		t.Error("read-only file was modified")
+	}
+
+	t.Run("error names the path and access", func(t *testing.T) {
+		_, err := exec("write_file", map[string]any{"path": "../outside/x", "content": "x"})
+		if err == nil || !strings.Contains(err.Error(), "../outside/x") || !strings.Contains(err.Error(), "may write") {
+			t.Errorf("err = %v", err)
+		}
+	})
+
+	t.Run("filtered copies keep the policy", func(t *testing.T) {
+		_, err := ts.Filter("read_file").Execute(ctx, "read_file", map[string]any{"path": filepath.Join(outside, "secret.txt")})
+		if !errors.Is(err, ErrPathNotAllowed) {
+			t.Errorf("err = %v, want ErrPathNotAllowed", err)
+		}
+	})
+}
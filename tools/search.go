@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultSearchResults = 5
+	maxSearchResults     = 20
+)
+
+// SearchResult is one hit returned by web_search.
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// SearchProvider runs web searches for the web_search tool. Implement it
+// to plug in a search backend (Brave, Bing, SerpAPI, an internal index...).
+type SearchProvider interface {
+	// Search returns up to limit results for query.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchProviderFunc adapts a function to SearchProvider.
+type SearchProviderFunc func(ctx context.Context, query string, limit int) ([]SearchResult, error)
+
+// Search calls f.
+func (f SearchProviderFunc) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return f(ctx, query, limit)
+}
+
+// noSearchProvider is used until a provider is configured.
+type noSearchProvider struct{}
+
+func (noSearchProvider) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	return nil, fmt.Errorf("%w: configure one with tools.WithSearchProvider or Tools.SetSearchProvider", ErrNoSearchProvider)
+}
+
+// WithSearchProvider sets the backend used by the web_search tool.
+func WithSearchProvider(p SearchProvider) ToolsOption {
+	return func(t *Tools) {
+		t.searchProvider = p
+	}
+}
+
+// SetSearchProvider sets the backend used by the web_search tool after
+// construction. A nil provider restores the default, which fails every
+// search with ErrNoSearchProvider.
+func (t *Tools) SetSearchProvider(p SearchProvider) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.searchProvider = p
+}
+
+func (t *Tools) getSearchProvider() SearchProvider {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.searchProvider == nil {
+		return noSearchProvider{}
+	}
+	return t.searchProvider
+}
+
+// registerWebSearch registers the web_search built-in tool.
+func (t *Tools) registerWebSearch() {
+	t.registerAs(ToolSourceBuiltin, "web_search", ToolDef{
+		Description: "Search the web for current information. Returns a JSON array of results, each with title, url and snippet.",
+		Fn: ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			query, _ := params["query"].(string)
+			query = strings.TrimSpace(query)
+			if query == "" {
+				return "", fmt.Errorf("query is required")
+			}
+			limit := defaultSearchResults
+			if n, ok := toInt(params["limit"]); ok && n > 0 {
+				limit = min(n, maxSearchResults)
+			}
+
+			results, err := t.getSearchProvider().Search(ctx, query, limit)
+			if err != nil {
+				return "", err
+			}
+			if len(results) > limit {
+				results = results[:limit]
+			}
+			if results == nil {
+				results = []SearchResult{}
+			}
+			out, err := json.Marshal(results)
+			if err != nil {
+				return "", err
+			}
+			return string(out), nil
+		}),
+		Params: map[string]ParamDef{
+			"query": {Type: "string", Description: "Search query", Required: true},
+			"limit": {Type: "integer", Description: fmt.Sprintf("Maximum number of results (default %d, max %d)", defaultSearchResults, maxSearchResults)},
+		},
+	})
+}
@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/everydev1618/govega/llm"
+)
+
+const (
+	// maxSummarizeBytes caps how much of a file summarize_file reads.
+	maxSummarizeBytes = 512 * 1024
+	// summaryChunkSize is the target size of each chunk sent to the
+	// summarizer, roughly 4k tokens of English text.
+	summaryChunkSize = 16 * 1024
+)
+
+// Summarizer condenses text for the summarize_file tool.
+type Summarizer interface {
+	// Summarize returns a summary of text following instructions.
+	Summarize(ctx context.Context, text, instructions string) (string, error)
+}
+
+// SummarizerFunc adapts a function to Summarizer.
+type SummarizerFunc func(ctx context.Context, text, instructions string) (string, error)
+
+// Summarize calls f.
+func (f SummarizerFunc) Summarize(ctx context.Context, text, instructions string) (string, error) {
+	return f(ctx, text, instructions)
+}
+
+// LLMSummarizer returns a Summarizer that asks l for summaries. If model is
+// set it overrides l's default for these requests, so a cheap model can do
+// the summarizing while agents use a stronger one.
+func LLMSummarizer(l llm.LLM, model string) Summarizer {
+	return SummarizerFunc(func(ctx context.Context, text, instructions string) (string, error) {
+		if model != "" {
+			ctx = llm.ContextWithModel(ctx, model)
+		}
+		resp, err := l.Generate(ctx, []llm.Message{
+			{Role: llm.RoleUser, Content: instructions + "\n\n<document>\n" + text + "\n</document>"},
+		}, nil)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(resp.Content), nil
+	})
+}
+
+// WithSummarizer sets the backend used by the summarize_file tool.
+func WithSummarizer(s Summarizer) ToolsOption {
+	return func(t *Tools) {
+		t.summarizer = s
+	}
+}
+
+// SetSummarizer sets the backend used by the summarize_file tool after
+// construction. A nil summarizer makes summarize_file fail with
+// ErrNoSummarizer.
+func (t *Tools) SetSummarizer(s Summarizer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.summarizer = s
+}
+
+func (t *Tools) getSummarizer() Summarizer {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.summarizer
+}
+
+// registerSummarizeFile registers the summarize_file built-in tool.
+func (t *Tools) registerSummarizeFile() {
+	t.registerAs(ToolSourceBuiltin, "summarize_file", ToolDef{
+		Description: "Summarize a large file without reading it whole. Returns a summary instead of the raw content; use read_file when you need exact text.",
+		Fn: ToolFunc(func(ctx context.Context, params map[string]any) (string, error) {
+			path, _ := params["path"].(string)
+			if path == "" {
+				return "", fmt.Errorf("path is required")
+			}
+			focus, _ := params["focus"].(string)
+
+			s := t.getSummarizer()
+			if s == nil {
+				return "", fmt.Errorf("%w: configure one with tools.WithSummarizer or Tools.SetSummarizer", ErrNoSummarizer)
+			}
+
+			text, size, err := readHead(path, maxSummarizeBytes)
+			if err != nil {
+				return "", err
+			}
+			if strings.TrimSpace(text) == "" {
+				return "The file is empty.", nil
+			}
+
+			summary, err := summarizeText(ctx, s, text, focus)
+			if err != nil {
+				return "", fmt.Errorf("summarize %s: %w", path, err)
+			}
+			if size > int64(len(text)) {
+				summary += fmt.Sprintf("\n\n[Truncated: only the first %d KB of %d KB were summarized.]", len(text)/1024, size/1024)
+			}
+			return summary, nil
+		}),
+		Params: map[string]ParamDef{
+			"path":  {Type: "string", Description: "File path", Required: true},
+			"focus": {Type: "string", Description: "Optional: what the summary should concentrate on (e.g. 'API changes', 'action items')"},
+		},
+	})
+}
+
+// readHead reads at most limit bytes of the file at path and returns them
+// with the file's full size.
+func readHead(path string, limit int64) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	data, err := io.ReadAll(io.LimitReader(f, limit))
+	if err != nil {
+		return "", 0, err
+	}
+	return strings.ToValidUTF8(string(data), ""), info.Size(), nil
+}
+
+// summarizeText summarizes each chunk of text, then combines the chunk
+// summaries into one. Text that fits in a single chunk is summarized
+// directly.
+func summarizeText(ctx context.Context, s Summarizer, text, focus string) (string, error) {
+	instructions := "Summarize the following document concisely, keeping key facts, names and numbers."
+	if focus != "" {
+		instructions += " Focus on: " + focus + "."
+	}
+
+	chunks := chunkText(text, summaryChunkSize)
+	if len(chunks) == 1 {
+		return s.Summarize(ctx, chunks[0], instructions)
+	}
+
+	partInstructions := "This is one part of a longer document. " + instructions
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		part, err := s.Summarize(ctx, chunk, partInstructions)
+		if err != nil {
+			return "", fmt.Errorf("part %d of %d: %w", i+1, len(chunks), err)
+		}
+		parts[i] = fmt.Sprintf("Part %d:\n%s", i+1, part)
+	}
+
+	combineInstructions := "These are summaries of consecutive parts of one document. Combine them into a single coherent summary of the whole document."
+	if focus != "" {
+		combineInstructions += " Focus on: " + focus + "."
+	}
+	return s.Summarize(ctx, strings.Join(parts, "\n\n"), combineInstructions)
+}
+
+// chunkText splits text into chunks of at most size bytes, breaking at the
+// last newline in each chunk where there is one so lines stay whole.
+func chunkText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := size
+		if i := strings.LastIndexByte(text[:size], '\n'); i > size/2 {
+			cut = i + 1
+		} else {
+			// Don't split a multi-byte rune.
+			for cut > 0 && !isRuneStart(text[cut]) {
+				cut--
+			}
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return chunks
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
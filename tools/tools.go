@@ -2,12 +2,18 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/everydev1618/govega/internal/container"
 	"github.com/everydev1618/govega/internal/skills"
@@ -19,8 +25,39 @@ var (
 	// ErrToolNotFound is returned when a tool is not registered
 	ErrToolNotFound = errors.New("tool not found")
 
+	// ErrToolNotPermitted is returned when an agent calls a tool that is
+	// registered but not on its allowlist.
+	ErrToolNotPermitted = errors.New("tool not permitted for this agent")
+
 	// ErrToolAlreadyRegistered is returned when trying to register a duplicate tool name.
 	ErrToolAlreadyRegistered = errors.New("tool already registered")
+
+	// ErrInvalidParamDef is returned when a ToolDef declares a malformed parameter.
+	ErrInvalidParamDef = errors.New("invalid parameter definition")
+
+	// ErrInvalidArguments is returned when a tool call's arguments don't
+	// match the tool's declared params.
+	ErrInvalidArguments = errors.New("invalid arguments")
+
+	// ErrArgumentsTooLarge is returned, without running the tool, when a
+	// tool call's arguments exceed the argument size cap.
+	ErrArgumentsTooLarge = errors.New("arguments too large")
+
+	// ErrNoSearchProvider is returned by web_search when no SearchProvider
+	// has been configured.
+	ErrNoSearchProvider = errors.New("web_search has no search provider configured")
+
+	// ErrNoSummarizer is returned by summarize_file when no Summarizer has
+	// been configured.
+	ErrNoSummarizer = errors.New("summarize_file has no summarizer configured")
+
+	// ErrPathNotAllowed is returned when a tool call's path argument falls
+	// outside the directories its SandboxPolicy allows.
+	ErrPathNotAllowed = errors.New("path not allowed")
+
+	// ErrHostNotAllowed is returned by http_request for URLs outside the
+	// domain allowlist or addresses on private networks.
+	ErrHostNotAllowed = errors.New("host not allowed")
 )
 
 // ToolError wraps errors with tool context.
@@ -76,9 +113,26 @@ type Tools struct {
 	container  *containerState   // Container routing state
 	project    *projectState     // Active project subdirectory (shared pointer)
 	parent     *Tools            // parent for skill-tool lookups (set by Filter)
+	allowed    map[string]bool   // allowlist of a Filter copy; nil means unrestricted
 	skillsRef  SkillsRef         // skills prompt for dynamic tool augmentation
 	mu         sync.RWMutex
 
+	// searchProvider backs the web_search tool; nil means none configured.
+	searchProvider SearchProvider
+
+	// summarizer backs the summarize_file tool; nil means none configured.
+	summarizer Summarizer
+
+	// policy confines path arguments per read/write access; nil means
+	// only sandbox (if set) applies.
+	policy *SandboxPolicy
+
+	// maxResultBytes caps the size of a tool result; 0 means no cap.
+	maxResultBytes int
+
+	// maxArgBytes caps the JSON size of a call's arguments; 0 means no cap.
+	maxArgBytes int
+
 	// Settings holds key-value pairs from the settings store that are injected
 	// into dynamic tool template interpolation.
 	settings map[string]string
@@ -92,9 +146,19 @@ type Tools struct {
 	OnFileWrite func(ctx context.Context, path, operation, description string)
 }
 
+// ContainerExecutor runs commands in project containers.
+// *container.Manager implements it.
+type ContainerExecutor interface {
+	// IsAvailable reports whether containers can be used.
+	IsAvailable() bool
+	// Exec runs command in the project's container. Cancelling ctx must
+	// stop the command, not just stop waiting for it.
+	Exec(ctx context.Context, projectName string, command []string, workDir string) (*container.ExecResult, error)
+}
+
 // containerState holds container routing configuration.
 type containerState struct {
-	manager     *container.Manager
+	manager     ContainerExecutor
 	project     string
 	routedTools map[string]bool
 }
@@ -106,6 +170,39 @@ type tool struct {
 	fn          any
 	schema      llm.ToolSchema
 	params      map[string]ParamDef
+	args        []string   // param name per Fn argument, "" if not bound by name
+	rawArgs     bool       // skip argument validation against params
+	access      PathAccess // declared path access for SandboxPolicy
+	maxResult   int        // per-tool result cap; 0 uses the collection's
+	maxArgs     int        // per-tool argument cap; 0 uses the collection's
+	source      ToolSource
+	server      string      // owning MCP server, for MCP tools
+	removed     atomic.Bool // set by Unregister; hides the tool from Filter copies
+}
+
+// ToolSource identifies where a registered tool came from.
+type ToolSource string
+
+const (
+	ToolSourceBuiltin ToolSource = "builtin" // RegisterBuiltins and RegisterEmailTool
+	ToolSourceMCP     ToolSource = "mcp"     // MCP servers, remote or built-in
+	ToolSourceCustom  ToolSource = "custom"  // Register and RegisterDynamicTool
+)
+
+// ToolInfo describes one registered tool in an Export snapshot.
+type ToolInfo struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Source      ToolSource          `json:"source"`
+	Server      string              `json:"server,omitempty"` // MCP server, for MCP tools
+	Params      map[string]ParamDef `json:"params,omitempty"`
+	Schema      llm.ToolSchema      `json:"schema"`
+
+	// Sandboxed is set when file paths in the tool's arguments are confined
+	// to the sandbox directory. ContainerRouted is set when calls run in the
+	// project container instead of on the host.
+	Sandboxed       bool `json:"sandboxed"`
+	ContainerRouted bool `json:"container_routed,omitempty"`
 }
 
 // ParamDef defines a tool parameter.
@@ -118,10 +215,32 @@ type ParamDef struct {
 }
 
 // ToolDef allows explicit tool definition with schema.
+//
+// When Params is empty the schema is inferred from Fn. Args then names Fn's
+// positional parameters in order, since Go reflection can't recover them:
+//
+//	ToolDef{Fn: func(city string, days int) string {...}, Args: []string{"city", "days"}}
+//
+// Calls are checked against Params before Fn runs: required params must be
+// present, values are coerced to the declared type where unambiguous (for
+// example "5" to 5 for a number) and enums are enforced. Set RawArgs to
+// skip this and receive the arguments exactly as sent.
+//
+// Access declares whether the tool reads or writes its path arguments,
+// for WithSandboxPolicy; when empty it is inferred from the tool name.
+//
+// MaxResultBytes overrides the collection's result cap (see
+// WithMaxResultBytes) for this tool, and MaxArgBytes its argument cap (see
+// WithMaxArgBytes); a negative value disables the cap.
 type ToolDef struct {
-	Description string
-	Fn          any
-	Params      map[string]ParamDef
+	Description    string
+	Fn             any
+	Params         map[string]ParamDef
+	Args           []string
+	RawArgs        bool
+	Access         PathAccess
+	MaxResultBytes int
+	MaxArgBytes    int
 }
 
 // ToolMiddleware wraps tool execution.
@@ -130,13 +249,25 @@ type ToolMiddleware func(ToolFunc) ToolFunc
 // ToolFunc is the signature for tool execution.
 type ToolFunc func(ctx context.Context, params map[string]any) (string, error)
 
+type toolNameKey struct{}
+
+// ToolNameFromContext returns the name of the tool being executed. It is
+// set by Execute before the middleware chain runs, so middleware can tell
+// which tool it is wrapping.
+func ToolNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(toolNameKey{}).(string)
+	return name
+}
+
 // ToolsOption configures Tools.
 type ToolsOption func(*Tools)
 
 // NewTools creates a new Tools collection.
 func NewTools(opts ...ToolsOption) *Tools {
 	t := &Tools{
-		tools: make(map[string]*tool),
+		tools:          make(map[string]*tool),
+		maxResultBytes: DefaultMaxResultBytes,
+		maxArgBytes:    DefaultMaxArgBytes,
 	}
 
 	for _, opt := range opts {
@@ -213,6 +344,25 @@ func WithSandbox(path string) ToolsOption {
 	}
 }
 
+// WithMaxResultBytes caps tool results at n bytes; longer results are cut
+// and end with a marker saying how much was shown. n <= 0 disables the
+// cap. The default is DefaultMaxResultBytes.
+func WithMaxResultBytes(n int) ToolsOption {
+	return func(t *Tools) {
+		t.maxResultBytes = max(n, 0)
+	}
+}
+
+// WithMaxArgBytes caps the size of a tool call's arguments, measured as
+// JSON, at n bytes. A larger call is rejected with ErrArgumentsTooLarge
+// before the tool runs, and the model is told to pass a file path
+// instead. n <= 0 disables the cap. The default is DefaultMaxArgBytes.
+func WithMaxArgBytes(n int) ToolsOption {
+	return func(t *Tools) {
+		t.maxArgBytes = max(n, 0)
+	}
+}
+
 // WithBaseURL sets the server base URL for constructing deliverable URLs
 // in tool responses (e.g. write_file returns the accessible URL).
 func WithBaseURL(url string) ToolsOption {
@@ -232,7 +382,7 @@ func (t *Tools) SetBaseURL(url string) {
 }
 
 // WithContainer enables container-based tool execution.
-func WithContainer(cm *container.Manager) ToolsOption {
+func WithContainer(cm ContainerExecutor) ToolsOption {
 	return func(t *Tools) {
 		if t.container == nil {
 			t.container = &containerState{
@@ -262,8 +412,30 @@ func WithContainerRouting(toolNames ...string) ToolsOption {
 // - func(params) string
 // - func(params) (string, error)
 // - func(ctx, params) (string, error)
+// - func(ctx, params) error
 // - ToolDef with explicit schema
+//
+// ctx, when present, must be a context.Context and receives the context
+// the tool is executed with.
+//
+// params may be a struct, whose fields become schema properties (named by
+// their json tag, described by desc:"..." and marked required:"true"), a
+// map[string]any receiving the raw arguments, or one or more plain values.
+// Plain values are exposed as "arg0", "arg1", ... unless named through
+// ToolDef.Args; pointer values are optional. A lone unnamed string is
+// matched against common names like "path" or "query" instead.
 func (t *Tools) Register(name string, fn any) error {
+	return t.registerAs(ToolSourceCustom, name, fn)
+}
+
+// registerAs registers a tool like Register, tagging it with source.
+func (t *Tools) registerAs(source ToolSource, name string, fn any) error {
+	return t.registerTool(source, "", name, fn)
+}
+
+// registerTool registers a tool tagged with its source and, for MCP tools,
+// the server that owns it.
+func (t *Tools) registerTool(source ToolSource, server, name string, fn any) error {
 	if name == "" {
 		return errors.New("tool name is required")
 	}
@@ -277,18 +449,40 @@ func (t *Tools) Register(name string, fn any) error {
 	}
 
 	tl := &tool{
-		name: name,
+		name:   name,
+		source: source,
+		server: server,
 	}
 
 	// Handle ToolDef
 	if def, ok := fn.(ToolDef); ok {
+		if err := validateParams(def.Params); err != nil {
+			return fmt.Errorf("%w: tool %s: %v", ErrInvalidParamDef, name, err)
+		}
 		tl.description = def.Description
 		tl.fn = def.Fn
 		tl.params = def.Params
+		tl.rawArgs = def.RawArgs
+		tl.access = def.Access
+		tl.maxResult = def.MaxResultBytes
+		tl.maxArgs = def.MaxArgBytes
 		tl.schema = t.buildSchema(name, def.Description, def.Params)
+		if fnType := reflect.TypeOf(def.Fn); len(def.Params) == 0 && fnType != nil && fnType.Kind() == reflect.Func {
+			tl.args = argNames(fnType, def.Args)
+			tl.schema = t.inferSchema(name, def.Fn, tl.args)
+			if def.Description != "" {
+				tl.schema.Description = def.Description
+			}
+			tl.description = tl.schema.Description
+		}
 	} else {
+		fnType := reflect.TypeOf(fn)
+		if err := validateFunc(fnType); err != nil {
+			return fmt.Errorf("tool %s: %w", name, err)
+		}
 		tl.fn = fn
-		tl.schema = t.inferSchema(name, fn)
+		tl.args = argNames(fnType, nil)
+		tl.schema = t.inferSchema(name, fn, tl.args)
 		tl.description = tl.schema.Description
 	}
 
@@ -296,6 +490,26 @@ func (t *Tools) Register(name string, fn any) error {
 	return nil
 }
 
+// Unregister removes a tool from the collection so the name can be
+// registered again. Calls already executing the tool run to completion;
+// later calls fail with ErrToolNotFound, including through copies made by
+// Filter before the tool was removed.
+func (t *Tools) Unregister(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tl, ok := t.tools[name]
+	if !ok {
+		return &ToolError{ToolName: name, Err: ErrToolNotFound}
+	}
+	tl.removed.Store(true)
+	delete(t.tools, name)
+	if t.container != nil {
+		delete(t.container.routedTools, name)
+	}
+	return nil
+}
+
 // Use adds middleware to the tool chain.
 func (t *Tools) Use(mw ToolMiddleware) {
 	t.mu.Lock()
@@ -328,49 +542,98 @@ func (t *Tools) Execute(ctx context.Context, name string, params map[string]any)
 	tl, ok := t.tools[name]
 	middleware := t.middleware
 	sandbox := t.effectiveSandbox()
+	policy := t.policy
 	cs := t.container
 	parent := t.parent
+	allowed := t.allowed
+	sp := t.skillsRef
+	limit := t.maxResultBytes
+	argLimit := t.maxArgBytes
 	t.mu.RUnlock()
 
-	// Fallback to parent for tools provided by skills.
+	// A Filter copy may still hold a tool unregistered from its parent.
+	if ok && tl.removed.Load() {
+		ok = false
+	}
+
+	// Fallback to parent for tools on the allowlist that were registered
+	// again, and for tools provided by matched skills. Anything else the
+	// parent has is off-limits to this copy.
 	if !ok && parent != nil {
 		parent.mu.RLock()
 		tl, ok = parent.tools[name]
 		parent.mu.RUnlock()
+		if ok && allowed != nil && !allowed[name] && !skillDeclares(sp, name) {
+			return "", &ToolError{ToolName: name, Err: ErrToolNotPermitted}
+		}
 	}
 
 	if !ok {
 		return "", &ToolError{ToolName: name, Err: ErrToolNotFound}
 	}
+	if tl.maxResult != 0 {
+		limit = max(tl.maxResult, 0)
+	}
+	if tl.maxArgs != 0 {
+		argLimit = max(tl.maxArgs, 0)
+	}
+	if err := checkArgSize(params, argLimit); err != nil {
+		return "", &ToolError{ToolName: name, Err: err}
+	}
 
-	// Check if this tool should be routed to container
-	if cs != nil && cs.manager != nil &&
-		cs.manager.IsAvailable() && cs.project != "" &&
-		cs.routedTools[name] {
-		return t.executeInContainer(ctx, name, params, cs)
+	// Validate arguments against the declared params.
+	if len(tl.params) > 0 && !tl.rawArgs {
+		validated, err := validateArgs(tl.params, params)
+		if err != nil {
+			return "", &ToolError{ToolName: name, Err: err}
+		}
+		params = validated
 	}
 
-	// Apply sandbox rewriting if needed
-	if sandbox != "" {
-		params = t.rewritePathsForSandbox(params, sandbox)
+	// Check if this tool should be routed to container
+	routed := cs != nil && cs.manager != nil &&
+		cs.manager.IsAvailable() && cs.project != "" &&
+		cs.routedTools[name]
+
+	// Enforce the sandbox policy, or apply sandbox rewriting if needed.
+	// The container is its own sandbox.
+	if !routed {
+		if policy != nil {
+			checked, err := policy.apply(tl.pathAccess(), params)
+			if err != nil {
+				return "", &ToolError{ToolName: name, Err: err}
+			}
+			params = checked
+		} else if sandbox != "" {
+			params = t.rewritePathsForSandbox(params, sandbox)
+		}
 	}
 
 	// Build execution function
 	exec := func(ctx context.Context, params map[string]any) (string, error) {
-		return t.callFunction(tl.fn, ctx, params)
+		if routed {
+			return t.executeInContainer(ctx, name, params, cs)
+		}
+		return t.callFunction(tl.fn, tl.args, ctx, params)
 	}
 
-	// Apply middleware (in reverse order)
+	// Apply middleware (in reverse order), so approval and auditing see
+	// container-routed calls too.
 	for i := len(middleware) - 1; i >= 0; i-- {
 		exec = middleware[i](exec)
 	}
 
-	result, err := exec(ctx, params)
+	result, err := exec(context.WithValue(ctx, toolNameKey{}, name), params)
 	if err != nil {
+		if routed {
+			// Keep the command's output alongside its exit status.
+			return truncateOutput(SanitizeOutput(result), limit), err
+		}
 		return "", &ToolError{ToolName: name, Err: err}
 	}
 
-	return result, nil
+	// Command and MCP output can be binary or cut mid-character.
+	return truncateOutput(SanitizeOutput(result), limit), nil
 }
 
 // executeInContainer runs a tool in the project container.
@@ -434,6 +697,9 @@ func (t *Tools) Schema() []llm.ToolSchema {
 	seen := make(map[string]bool, len(localTools))
 	schemas := make([]llm.ToolSchema, 0, len(localTools))
 	for _, tl := range localTools {
+		if tl.removed.Load() {
+			continue
+		}
 		schemas = append(schemas, tl.schema)
 		seen[tl.name] = true
 	}
@@ -460,7 +726,40 @@ func (t *Tools) Schema() []llm.ToolSchema {
 	return schemas
 }
 
-// Filter returns a new Tools with only the specified tools.
+// Export returns a snapshot of every registered tool, sorted by name, with
+// its schema, source and sandbox status. Skill-provided tools reachable
+// only through a parent are not included.
+func (t *Tools) Export() []ToolInfo {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	sandbox := t.effectiveSandbox()
+	infos := make([]ToolInfo, 0, len(t.tools))
+	for name, tl := range t.tools {
+		if tl.removed.Load() {
+			continue
+		}
+		info := ToolInfo{
+			Name:        name,
+			Description: tl.description,
+			Source:      tl.source,
+			Params:      tl.params,
+			Schema:      tl.schema,
+			Server:      tl.server,
+			Sandboxed:   sandbox != "",
+		}
+		if t.container != nil && t.container.routedTools[name] {
+			info.ContainerRouted = true
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Filter returns a new Tools with only the specified tools. Executing any
+// other tool through it fails with ErrToolNotPermitted, unless a skill
+// matched by its skills reference declares the tool.
 func (t *Tools) Filter(names ...string) *Tools {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -469,18 +768,22 @@ func (t *Tools) Filter(names ...string) *Tools {
 		tools:      make(map[string]*tool),
 		middleware: t.middleware,
 		sandbox:    t.sandbox,
+		policy:     t.policy,
 		container:  t.container,
 		project:    t.project,
 		parent:     t,
+
+		maxResultBytes: t.maxResultBytes,
+		maxArgBytes:    t.maxArgBytes,
+		allowed:    make(map[string]bool, len(names)),
 	}
 
-	nameSet := make(map[string]bool)
 	for _, n := range names {
-		nameSet[n] = true
+		filtered.allowed[n] = true
 	}
 
 	for name, tl := range t.tools {
-		if nameSet[name] {
+		if filtered.allowed[name] {
 			filtered.tools[name] = tl
 		}
 	}
@@ -495,16 +798,70 @@ func (t *Tools) WithSkillsRef(sp SkillsRef) *Tools {
 		tools:      t.tools,
 		middleware: t.middleware,
 		sandbox:    t.sandbox,
+		policy:     t.policy,
 		container:  t.container,
 		project:    t.project,
 		mcpClients: t.mcpClients,
 		parent:     t.parent,
+		allowed:    t.allowed,
 		skillsRef:  sp,
+
+		maxResultBytes: t.maxResultBytes,
+		maxArgBytes:    t.maxArgBytes,
+	}
+}
+
+// skillDeclares reports whether a skill currently matched by sp lists the
+// named tool.
+func skillDeclares(sp SkillsRef, name string) bool {
+	if sp == nil {
+		return false
+	}
+	for _, m := range sp.GetMatchedSkills() {
+		if slices.Contains(m.Skill.Tools, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// contextType and errorType are the reflect types of context.Context and error.
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// argNames returns the schema property name for each parameter of fnType,
+// or "" for parameters that aren't bound by name: the context, struct and
+// map parameters, and a lone unnamed string (which callFunction matches
+// against common names instead). names supplies the names of the plain
+// value parameters in order; the rest default to "arg0", "arg1", ...
+func argNames(fnType reflect.Type, names []string) []string {
+	args := make([]string, fnType.NumIn())
+	var values []int
+	for i := range args {
+		inType := fnType.In(i)
+		if inType.Implements(contextType) || inType.Kind() == reflect.Struct || inType.Kind() == reflect.Map {
+			continue
+		}
+		values = append(values, i)
+	}
+	if len(names) == 0 && len(values) == 1 && fnType.In(values[0]).Kind() == reflect.String {
+		return args
+	}
+	for k, i := range values {
+		if k < len(names) && names[k] != "" {
+			args[i] = names[k]
+		} else {
+			args[i] = fmt.Sprintf("arg%d", k)
+		}
 	}
+	return args
 }
 
-// inferSchema infers a JSON schema from a function signature.
-func (t *Tools) inferSchema(name string, fn any) llm.ToolSchema {
+// inferSchema infers a JSON schema from a function signature. args holds
+// the property name for each parameter, as returned by argNames.
+func (t *Tools) inferSchema(name string, fn any, args []string) llm.ToolSchema {
 	schema := llm.ToolSchema{
 		Name:        name,
 		Description: name,
@@ -520,32 +877,38 @@ func (t *Tools) inferSchema(name string, fn any) llm.ToolSchema {
 		return schema
 	}
 
+	props := make(map[string]any)
+	required := []string{}
+
 	// Build description from signature
 	var paramNames []string
 	for i := 0; i < fnType.NumIn(); i++ {
 		inType := fnType.In(i)
 		// Skip context parameter
-		if inType.Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
+		if inType.Implements(contextType) {
+			continue
+		}
+		if i < len(args) && args[i] != "" {
+			paramNames = append(paramNames, args[i]+" "+inType.String())
+			props[args[i]] = map[string]any{"type": goTypeToJSONType(inType)}
+			if inType.Kind() != reflect.Pointer {
+				required = append(required, args[i])
+			}
 			continue
 		}
 		paramNames = append(paramNames, inType.Name())
-	}
-	schema.Description = fmt.Sprintf("%s(%s)", name, strings.Join(paramNames, ", "))
 
-	// Infer parameters from struct if applicable
-	if fnType.NumIn() > 0 {
-		lastParam := fnType.In(fnType.NumIn() - 1)
-		if lastParam.Kind() == reflect.Struct {
-			props := make(map[string]any)
-			required := []string{}
-
-			for i := 0; i < lastParam.NumField(); i++ {
-				field := lastParam.Field(i)
-				jsonTag := field.Tag.Get("json")
-				if jsonTag == "" || jsonTag == "-" {
-					jsonTag = strings.ToLower(field.Name)
+		// Infer parameters from struct fields
+		if inType.Kind() == reflect.Struct {
+			for j := 0; j < inType.NumField(); j++ {
+				field := inType.Field(j)
+				if !field.IsExported() {
+					continue
+				}
+				jsonTag := fieldParamName(field)
+				if jsonTag == "" {
+					continue
 				}
-				jsonTag = strings.Split(jsonTag, ",")[0]
 
 				prop := map[string]any{
 					"type": goTypeToJSONType(field.Type),
@@ -560,15 +923,28 @@ func (t *Tools) inferSchema(name string, fn any) llm.ToolSchema {
 					required = append(required, jsonTag)
 				}
 			}
-
-			schema.InputSchema["properties"] = props
-			schema.InputSchema["required"] = required
 		}
 	}
+	schema.Description = fmt.Sprintf("%s(%s)", name, strings.Join(paramNames, ", "))
+	schema.InputSchema["properties"] = props
+	schema.InputSchema["required"] = required
 
 	return schema
 }
 
+// fieldParamName returns the parameter name for a struct field: its json
+// tag name, or the lowercased field name. Fields tagged json:"-" return "".
+func fieldParamName(field reflect.StructField) string {
+	jsonTag := field.Tag.Get("json")
+	if jsonTag == "-" {
+		return ""
+	}
+	if name, _, _ := strings.Cut(jsonTag, ","); name != "" {
+		return name
+	}
+	return strings.ToLower(field.Name)
+}
+
 // buildSchema builds a schema from explicit definitions.
 func (t *Tools) buildSchema(name, description string, params map[string]ParamDef) llm.ToolSchema {
 	props := make(map[string]any)
@@ -602,8 +978,146 @@ func (t *Tools) buildSchema(name, description string, params map[string]ParamDef
 	}
 }
 
-// callFunction calls a tool function with parameters.
-func (t *Tools) callFunction(fn any, ctx context.Context, params map[string]any) (string, error) {
+// paramTypes are the JSON Schema types a ParamDef may declare.
+var paramTypes = []string{"string", "number", "integer", "boolean", "array", "object"}
+
+// validateParams checks that every ParamDef has a name and a valid JSON
+// Schema type, and that its enum and default agree with that type.
+func validateParams(params map[string]ParamDef) error {
+	for pname, pdef := range params {
+		if pname == "" {
+			return errors.New("param name is required")
+		}
+		if !slices.Contains(paramTypes, pdef.Type) {
+			return fmt.Errorf("param %q: invalid type %q (want one of %s)", pname, pdef.Type, strings.Join(paramTypes, ", "))
+		}
+		if len(pdef.Enum) > 0 && pdef.Type != "string" {
+			return fmt.Errorf("param %q: enum requires type string, got %q", pname, pdef.Type)
+		}
+		if pdef.Default == nil {
+			continue
+		}
+		if !matchesParamType(pdef.Default, pdef.Type) {
+			return fmt.Errorf("param %q: default %v is not of type %s", pname, pdef.Default, pdef.Type)
+		}
+		if len(pdef.Enum) > 0 && !slices.Contains(pdef.Enum, pdef.Default.(string)) {
+			return fmt.Errorf("param %q: default %q is not one of the enum values", pname, pdef.Default)
+		}
+	}
+	return nil
+}
+
+// validateArgs checks args against params and returns a copy with values
+// coerced to their declared types. Arguments without a matching ParamDef
+// pass through unchanged. All problems are reported together so the model
+// can fix them in one retry.
+func validateArgs(params map[string]ParamDef, args map[string]any) (map[string]any, error) {
+	out := make(map[string]any, len(args))
+	for k, v := range args {
+		out[k] = v
+	}
+
+	names := make([]string, 0, len(params))
+	for pname := range params {
+		names = append(names, pname)
+	}
+	slices.Sort(names)
+
+	var problems []string
+	for _, pname := range names {
+		pdef := params[pname]
+		v, ok := args[pname]
+		if !ok || v == nil {
+			if pdef.Required {
+				problems = append(problems, fmt.Sprintf("missing required param %q", pname))
+			}
+			continue
+		}
+		coerced, ok := coerceArg(v, pdef.Type)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("param %q must be of type %s, got %T %v", pname, pdef.Type, v, v))
+			continue
+		}
+		if len(pdef.Enum) > 0 {
+			s, isString := coerced.(string)
+			if !isString || !slices.Contains(pdef.Enum, s) {
+				problems = append(problems, fmt.Sprintf("param %q must be one of [%s], got %v", pname, strings.Join(pdef.Enum, ", "), coerced))
+				continue
+			}
+		}
+		out[pname] = coerced
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidArguments, strings.Join(problems, "; "))
+	}
+	return out, nil
+}
+
+// coerceArg converts v to the JSON Schema type typ where that is
+// unambiguous: numbers and booleans sent as strings are parsed, and
+// scalars are accepted for strings. It reports false if v doesn't fit.
+func coerceArg(v any, typ string) (any, bool) {
+	if matchesParamType(v, typ) {
+		return v, true
+	}
+	s, isString := v.(string)
+	switch typ {
+	case "string":
+		switch v.(type) {
+		case float64, float32, int, int64, int32, bool:
+			return fmt.Sprint(v), true
+		}
+	case "number", "integer":
+		if !isString {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || (typ == "integer" && f != math.Trunc(f)) {
+			return nil, false
+		}
+		return f, true
+	case "boolean":
+		if !isString {
+			return nil, false
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, false
+		}
+		return b, true
+	}
+	return nil, false
+}
+
+// matchesParamType reports whether v is a valid value for the JSON Schema
+// type typ. Integers decoded from JSON arrive as whole float64s, so those
+// count as integers too.
+func matchesParamType(v any, typ string) bool {
+	rv := reflect.ValueOf(v)
+	switch typ {
+	case "string":
+		return rv.Kind() == reflect.String
+	case "boolean":
+		return rv.Kind() == reflect.Bool
+	case "integer":
+		if rv.CanFloat() {
+			f := rv.Float()
+			return f == math.Trunc(f)
+		}
+		return rv.CanInt() || rv.CanUint()
+	case "number":
+		return rv.CanInt() || rv.CanUint() || rv.CanFloat()
+	case "array":
+		return rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array
+	case "object":
+		return rv.Kind() == reflect.Map || rv.Kind() == reflect.Struct
+	}
+	return false
+}
+
+// callFunction calls a tool function with parameters. argNames holds the
+// parameter name bound to each argument, as returned by argNames.
+func (t *Tools) callFunction(fn any, argNames []string, ctx context.Context, params map[string]any) (string, error) {
 	// Handle ToolFunc directly
 	if tf, ok := fn.(ToolFunc); ok {
 		return tf(ctx, params)
@@ -619,11 +1133,21 @@ func (t *Tools) callFunction(fn any, ctx context.Context, params map[string]any)
 		inType := fnType.In(i)
 
 		// Handle context
-		if inType.Implements(reflect.TypeOf((*context.Context)(nil)).Elem()) {
+		if inType.Implements(contextType) {
 			args = append(args, reflect.ValueOf(ctx))
 			continue
 		}
 
+		// Handle named value parameter
+		if i < len(argNames) && argNames[i] != "" {
+			arg, err := convertArg(params[argNames[i]], inType)
+			if err != nil {
+				return "", fmt.Errorf("param %q: %w", argNames[i], err)
+			}
+			args = append(args, arg)
+			continue
+		}
+
 		// Handle string parameter
 		if inType.Kind() == reflect.String {
 			// Try common param names first
@@ -659,16 +1183,19 @@ func (t *Tools) callFunction(fn any, ctx context.Context, params map[string]any)
 			structVal := reflect.New(inType).Elem()
 			for j := 0; j < inType.NumField(); j++ {
 				field := inType.Field(j)
-				jsonTag := field.Tag.Get("json")
+				jsonTag := fieldParamName(field)
 				if jsonTag == "" {
-					jsonTag = strings.ToLower(field.Name)
+					continue
 				}
-				jsonTag = strings.Split(jsonTag, ",")[0]
 
 				if v, ok := params[jsonTag]; ok {
 					fieldVal := structVal.Field(j)
 					if fieldVal.CanSet() {
-						fieldVal.Set(reflect.ValueOf(v).Convert(field.Type))
+						arg, err := convertArg(v, field.Type)
+						if err != nil {
+							return "", fmt.Errorf("param %q: %w", jsonTag, err)
+						}
+						fieldVal.Set(arg)
 					}
 				}
 			}
@@ -686,28 +1213,76 @@ func (t *Tools) callFunction(fn any, ctx context.Context, params map[string]any)
 	// Call function
 	results := fnVal.Call(args)
 
-	// Parse results
+	// Parse results: an optional value followed by an optional error.
+	var err error
+	if n := len(results); n > 0 && fnType.Out(n-1) == errorType {
+		if !results[n-1].IsNil() {
+			err = results[n-1].Interface().(error)
+		}
+		results = results[:n-1]
+	}
 	if len(results) == 0 {
-		return "", nil
+		return "", err
 	}
+	return fmt.Sprint(results[0].Interface()), err
+}
 
-	if len(results) == 1 {
-		return fmt.Sprint(results[0].Interface()), nil
+// validateFunc checks that fn has a signature callFunction can invoke: it
+// returns at most a value and an error, in that order.
+func validateFunc(fnType reflect.Type) error {
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return fmt.Errorf("want a function, got %v", fnType)
 	}
+	switch fnType.NumOut() {
+	case 0, 1:
+		return nil
+	case 2:
+		if fnType.Out(1) == errorType {
+			return nil
+		}
+	}
+	return fmt.Errorf("function must return (value), (error) or (value, error), got %s", fnType)
+}
 
-	// Assume (string, error)
-	result := fmt.Sprint(results[0].Interface())
-	if !results[1].IsNil() {
-		return result, results[1].Interface().(error)
+// convertArg converts a decoded JSON argument to the Go type t. Missing
+// values become the zero value; numbers convert between numeric kinds and
+// composite values (slices, maps, structs, pointers) go through JSON.
+func convertArg(v any, t reflect.Type) (reflect.Value, error) {
+	if v == nil {
+		return reflect.Zero(t), nil
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Type().AssignableTo(t) {
+		return rv, nil
 	}
-	return result, nil
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(fmt.Sprint(v)).Convert(t), nil
+	}
+	if isNumericKind(rv.Kind()) && isNumericKind(t.Kind()) {
+		return rv.Convert(t), nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	ptr := reflect.New(t)
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return reflect.Value{}, fmt.Errorf("cannot use %v as %s", v, t)
+	}
+	return ptr.Elem(), nil
+}
+
+// isNumericKind reports whether k is an integer or floating-point kind.
+func isNumericKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Float64
 }
 
 // rewritePathsForSandbox rewrites path parameters to be within sandbox.
 func (t *Tools) rewritePathsForSandbox(params map[string]any, sandbox string) map[string]any {
 	result := make(map[string]any)
 	for k, v := range params {
-		if k == "path" || strings.HasSuffix(k, "_path") || strings.HasSuffix(k, "Path") {
+		if isPathParam(k) {
 			if s, ok := v.(string); ok {
 				// Validate and rewrite path
 				clean := filepath.Clean(s)
@@ -733,6 +1308,8 @@ func (t *Tools) rewritePathsForSandbox(params map[string]any, sandbox string) ma
 // goTypeToJSONType converts Go types to JSON schema types.
 func goTypeToJSONType(t reflect.Type) string {
 	switch t.Kind() {
+	case reflect.Pointer:
+		return goTypeToJSONType(t.Elem())
 	case reflect.String:
 		return "string"
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
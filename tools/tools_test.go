@@ -0,0 +1,540 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/everydev1618/govega/internal/container"
+	"github.com/everydev1618/govega/internal/skills"
+)
+
+func noopTool(ctx context.Context, params map[string]any) (string, error) {
+	return "", nil
+}
+
+func TestRegisterValidatesParamDefs(t *testing.T) {
+	t.Run("accepts every JSON schema type", func(t *testing.T) {
+		ts := NewTools()
+		err := ts.Register("all_types", ToolDef{
+			Fn: ToolFunc(noopTool),
+			Params: map[string]ParamDef{
+				"s": {Type: "string", Default: "b", Enum: []string{"a", "b"}},
+				"n": {Type: "number", Default: 1.5},
+				"i": {Type: "integer", Default: float64(3)},
+				"b": {Type: "boolean", Default: true},
+				"a": {Type: "array", Default: []any{"x"}},
+				"o": {Type: "object", Default: map[string]any{"k": "v"}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+	})
+
+	tests := []struct {
+		name  string
+		param ParamDef
+	}{
+		{"misspelled type", ParamDef{Type: "strng"}},
+		{"missing type", ParamDef{Description: "no type"}},
+		{"enum on non-string", ParamDef{Type: "integer", Enum: []string{"1", "2"}}},
+		{"default wrong type", ParamDef{Type: "boolean", Default: "yes"}},
+		{"fractional integer default", ParamDef{Type: "integer", Default: 2.5}},
+		{"default outside enum", ParamDef{Type: "string", Default: "c", Enum: []string{"a", "b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewTools()
+			err := ts.Register("bad", ToolDef{
+				Fn:     ToolFunc(noopTool),
+				Params: map[string]ParamDef{"p": tt.param},
+			})
+			if !errors.Is(err, ErrInvalidParamDef) {
+				t.Fatalf("Register error = %v, want ErrInvalidParamDef", err)
+			}
+			if len(ts.Schema()) != 0 {
+				t.Error("tool with invalid params should not be registered")
+			}
+		})
+	}
+
+	t.Run("rejects unnamed param", func(t *testing.T) {
+		ts := NewTools()
+		err := ts.Register("unnamed", ToolDef{
+			Fn:     ToolFunc(noopTool),
+			Params: map[string]ParamDef{"": {Type: "string", Required: true}},
+		})
+		if !errors.Is(err, ErrInvalidParamDef) {
+			t.Fatalf("Register error = %v, want ErrInvalidParamDef", err)
+		}
+	})
+}
+
+func TestRegisterInfersMultiParamSchema(t *testing.T) {
+	ts := NewTools()
+	err := ts.Register("forecast", ToolDef{
+		Fn: func(ctx context.Context, city string, days int, metric *bool) string {
+			unit := "F"
+			if metric != nil && *metric {
+				unit = "C"
+			}
+			return fmt.Sprintf("%s for %d days in %s", city, days, unit)
+		},
+		Args: []string{"city", "days", "metric"},
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	schema := ts.Schema()[0].InputSchema
+	props := schema["properties"].(map[string]any)
+	wantTypes := map[string]string{"city": "string", "days": "integer", "metric": "boolean"}
+	for name, typ := range wantTypes {
+		prop, ok := props[name].(map[string]any)
+		if !ok || prop["type"] != typ {
+			t.Errorf("property %q = %v, want type %s", name, props[name], typ)
+		}
+	}
+	if got := schema["required"].([]string); !slices.Equal(got, []string{"city", "days"}) {
+		t.Errorf("required = %v, want [city days]", got)
+	}
+
+	// JSON numbers arrive as float64 and must convert to int.
+	out, err := ts.Execute(context.Background(), "forecast", map[string]any{"city": "Oslo", "days": float64(3), "metric": true})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if out != "Oslo for 3 days in C" {
+		t.Errorf("Execute = %q", out)
+	}
+}
+
+func TestRegisterUnnamedArgs(t *testing.T) {
+	ts := NewTools()
+	if err := ts.Register("add", func(a, b int) int { return a + b }); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	props := ts.Schema()[0].InputSchema["properties"].(map[string]any)
+	if len(props) != 2 || props["arg0"] == nil || props["arg1"] == nil {
+		t.Fatalf("properties = %v, want arg0 and arg1", props)
+	}
+	out, err := ts.Execute(context.Background(), "add", map[string]any{"arg0": float64(2), "arg1": float64(5)})
+	if err != nil || out != "7" {
+		t.Errorf("Execute = %q, %v; want 7", out, err)
+	}
+}
+
+func TestRegisterInfersStructSchema(t *testing.T) {
+	type searchParams struct {
+		Query  string   `json:"query" desc:"Search query" required:"true"`
+		Limit  int      `json:"limit,omitempty"`
+		Tags   []string `json:"tags"`
+		Secret string   `json:"-"`
+	}
+	ts := NewTools()
+	err := ts.Register("search", func(ctx context.Context, p searchParams) (string, error) {
+		return fmt.Sprintf("%s/%d/%s", p.Query, p.Limit, strings.Join(p.Tags, ",")), nil
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	schema := ts.Schema()[0].InputSchema
+	props := schema["properties"].(map[string]any)
+	if len(props) != 3 {
+		t.Errorf("properties = %v, want query, limit and tags", props)
+	}
+	query := props["query"].(map[string]any)
+	if query["type"] != "string" || query["description"] != "Search query" {
+		t.Errorf("query = %v", query)
+	}
+	if props["limit"].(map[string]any)["type"] != "integer" || props["tags"].(map[string]any)["type"] != "array" {
+		t.Errorf("limit/tags = %v/%v", props["limit"], props["tags"])
+	}
+	if got := schema["required"].([]string); !slices.Equal(got, []string{"query"}) {
+		t.Errorf("required = %v, want [query]", got)
+	}
+
+	out, err := ts.Execute(context.Background(), "search", map[string]any{
+		"query": "go", "limit": float64(5), "tags": []any{"a", "b"},
+	})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if out != "go/5/a,b" {
+		t.Errorf("Execute = %q", out)
+	}
+}
+
+type ctxKey struct{}
+
+func TestRegisterContextAndErrorSignatures(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "from-ctx")
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name    string
+		fn      any
+		want    string
+		wantErr error
+	}{
+		{"plain", func(name string) string { return "hi " + name }, "hi ada", nil},
+		{"error return", func(name string) (string, error) { return "", errBoom }, "", errBoom},
+		{"context", func(ctx context.Context, name string) string {
+			return ctx.Value(ctxKey{}).(string) + " " + name
+		}, "from-ctx ada", nil},
+		{"context and error", func(ctx context.Context, name string) (string, error) {
+			return ctx.Value(ctxKey{}).(string), ctx.Err()
+		}, "from-ctx", nil},
+		{"error only", func(ctx context.Context, name string) error { return errBoom }, "", errBoom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := NewTools()
+			if err := ts.Register("greet", tt.fn); err != nil {
+				t.Fatalf("Register: %v", err)
+			}
+			out, err := ts.Execute(ctx, "greet", map[string]any{"name": "ada"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute error = %v, want %v", err, tt.wantErr)
+			}
+			if out != tt.want {
+				t.Errorf("Execute = %q, want %q", out, tt.want)
+			}
+		})
+	}
+
+	t.Run("cancelled context", func(t *testing.T) {
+		ts := NewTools()
+		ts.Register("wait", func(ctx context.Context, name string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		cctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := ts.Execute(cctx, "wait", map[string]any{"name": "x"}); !errors.Is(err, context.Canceled) {
+			t.Errorf("Execute error = %v, want context.Canceled", err)
+		}
+	})
+
+	t.Run("rejects non-error second return", func(t *testing.T) {
+		ts := NewTools()
+		if err := ts.Register("bad", func(name string) (string, int) { return "", 0 }); err == nil {
+			t.Error("Register should reject (string, int) return")
+		}
+	})
+}
+
+// sortOrder is a named string type, which passes the string type check
+// but is not a plain string.
+type sortOrder string
+
+func TestExecuteValidatesArguments(t *testing.T) {
+	var got map[string]any
+	record := func(ctx context.Context, params map[string]any) (string, error) {
+		got = params
+		return "ok", nil
+	}
+	params := map[string]ParamDef{
+		"query": {Type: "string", Required: true},
+		"limit": {Type: "integer"},
+		"exact": {Type: "boolean"},
+		"sort":  {Type: "string", Enum: []string{"asc", "desc"}},
+	}
+	ts := NewTools()
+	if err := ts.Register("search", ToolDef{Fn: ToolFunc(record), Params: params}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Register("search_raw", ToolDef{Fn: ToolFunc(record), Params: params, RawArgs: true}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		args    map[string]any
+		wantErr string
+	}{
+		{"missing required", map[string]any{"limit": float64(3)}, `missing required param "query"`},
+		{"wrong type", map[string]any{"query": "go", "limit": "lots"}, `param "limit" must be of type integer`},
+		{"fractional integer", map[string]any{"query": "go", "limit": 2.5}, `param "limit" must be of type integer`},
+		{"enum violation", map[string]any{"query": "go", "sort": "random"}, `param "sort" must be one of [asc, desc]`},
+		{"enum with named string type", map[string]any{"query": "go", "sort": sortOrder("asc")}, `param "sort" must be one of [asc, desc]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			_, err := ts.Execute(ctx, "search", tt.args)
+			if !errors.Is(err, ErrInvalidArguments) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Execute error = %v, want %q", err, tt.wantErr)
+			}
+			if got != nil {
+				t.Error("tool ran despite invalid arguments")
+			}
+		})
+	}
+
+	t.Run("coerces scalar strings", func(t *testing.T) {
+		args := map[string]any{"query": float64(42), "limit": "10", "exact": "true", "sort": "asc", "extra": "kept"}
+		if _, err := ts.Execute(ctx, "search", args); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		want := map[string]any{"query": "42", "limit": float64(10), "exact": true, "sort": "asc", "extra": "kept"}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s = %#v, want %#v", k, got[k], v)
+			}
+		}
+		if args["limit"] != "10" {
+			t.Error("caller's args map was modified")
+		}
+	})
+
+	t.Run("raw args opt out", func(t *testing.T) {
+		if _, err := ts.Execute(ctx, "search_raw", map[string]any{"sort": "random"}); err != nil {
+			t.Fatalf("Execute: %v", err)
+		}
+		if got["sort"] != "random" {
+			t.Errorf("sort = %v, want raw value", got["sort"])
+		}
+	})
+}
+
+func TestUnregister(t *testing.T) {
+	ts := NewTools()
+	ctx := context.Background()
+	if err := ts.Register("echo", func(text string) string { return text }); err != nil {
+		t.Fatal(err)
+	}
+	filtered := ts.Filter("echo")
+
+	if out, err := ts.Execute(ctx, "echo", map[string]any{"text": "hi"}); err != nil || out != "hi" {
+		t.Fatalf("Execute before Unregister = %q, %v", out, err)
+	}
+
+	if err := ts.Unregister("echo"); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	for name, tools := range map[string]*Tools{"parent": ts, "filtered": filtered} {
+		if _, err := tools.Execute(ctx, "echo", map[string]any{"text": "hi"}); !errors.Is(err, ErrToolNotFound) {
+			t.Errorf("%s: Execute after Unregister error = %v, want ErrToolNotFound", name, err)
+		}
+		if len(tools.Schema()) != 0 {
+			t.Errorf("%s: Schema still lists the unregistered tool", name)
+		}
+	}
+	if err := ts.Unregister("echo"); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("second Unregister error = %v, want ErrToolNotFound", err)
+	}
+
+	// The name can be registered again.
+	if err := ts.Register("echo", func(text string) string { return "again: " + text }); err != nil {
+		t.Fatalf("re-Register: %v", err)
+	}
+	if out, _ := filtered.Execute(ctx, "echo", map[string]any{"text": "hi"}); out != "again: hi" {
+		t.Errorf("filtered Execute after re-Register = %q", out)
+	}
+}
+
+// matchedSkills is a SkillsRef with a fixed set of matches.
+type matchedSkills []skills.SkillMatch
+
+func (m matchedSkills) GetMatchedSkills() []skills.SkillMatch { return m }
+
+func TestFilterRejectsUnlistedTools(t *testing.T) {
+	ts := NewTools()
+	ctx := context.Background()
+	for _, name := range []string{"read", "exec", "lint"} {
+		if err := ts.Register(name, func(text string) string { return name + ": " + text }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filtered := ts.Filter("read")
+	if schema := filtered.Schema(); len(schema) != 1 || schema[0].Name != "read" {
+		t.Errorf("Schema = %v, want only read", schema)
+	}
+	if out, err := filtered.Execute(ctx, "read", map[string]any{"text": "hi"}); err != nil || out != "read: hi" {
+		t.Errorf("Execute(read) = %q, %v", out, err)
+	}
+	if _, err := filtered.Execute(ctx, "exec", map[string]any{"text": "rm -rf /"}); !errors.Is(err, ErrToolNotPermitted) {
+		t.Errorf("Execute(exec) error = %v, want ErrToolNotPermitted", err)
+	}
+	if _, err := filtered.Execute(ctx, "missing", nil); !errors.Is(err, ErrToolNotFound) {
+		t.Errorf("Execute(missing) error = %v, want ErrToolNotFound", err)
+	}
+
+	// A matched skill that declares a tool opens it up, and only it.
+	withSkill := filtered.WithSkillsRef(matchedSkills{{Skill: &skills.Skill{Name: "linting", Tools: []string{"lint"}}}})
+	if out, err := withSkill.Execute(ctx, "lint", map[string]any{"text": "x"}); err != nil || out != "lint: x" {
+		t.Errorf("Execute(lint) with skill = %q, %v", out, err)
+	}
+	if _, err := withSkill.Execute(ctx, "exec", map[string]any{"text": "x"}); !errors.Is(err, ErrToolNotPermitted) {
+		t.Errorf("Execute(exec) with skill error = %v, want ErrToolNotPermitted", err)
+	}
+}
+
+func TestUnregisterDuringExecution(t *testing.T) {
+	ts := NewTools()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ts.Register("slow", func(ctx context.Context, text string) string {
+		close(started)
+		<-release
+		return "done " + text
+	})
+
+	result := make(chan string)
+	go func() {
+		out, _ := ts.Execute(context.Background(), "slow", map[string]any{"text": "x"})
+		result <- out
+	}()
+
+	<-started
+	if err := ts.Unregister("slow"); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	close(release)
+	if out := <-result; out != "done x" {
+		t.Errorf("in-flight call = %q, want it to complete", out)
+	}
+}
+
+func TestExport(t *testing.T) {
+	ts := NewTools(WithSandbox(t.TempDir()))
+	ts.RegisterBuiltins()
+	if _, err := ts.ConnectBuiltinServer(context.Background(), "fetch"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.Register("greet", ToolDef{
+		Description: "Greet someone",
+		Fn:          func(name string) string { return "hi " + name },
+		Args:        []string{"name"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]ToolInfo)
+	for _, info := range ts.Export() {
+		byName[info.Name] = info
+	}
+
+	tests := []struct {
+		name   string
+		source ToolSource
+		server string
+	}{
+		{"read_file", ToolSourceBuiltin, ""},
+		{"exec", ToolSourceBuiltin, ""},
+		{"fetch__fetch", ToolSourceMCP, "fetch"},
+		{"greet", ToolSourceCustom, ""},
+	}
+	for _, tt := range tests {
+		info, ok := byName[tt.name]
+		if !ok {
+			t.Errorf("Export missing %s", tt.name)
+			continue
+		}
+		if info.Source != tt.source || info.Server != tt.server {
+			t.Errorf("%s: source %q server %q, want %q %q", tt.name, info.Source, info.Server, tt.source, tt.server)
+		}
+		if !info.Sandboxed {
+			t.Errorf("%s: Sandboxed = false with a sandbox set", tt.name)
+		}
+		if info.Schema.Name != tt.name {
+			t.Errorf("%s: schema name %q", tt.name, info.Schema.Name)
+		}
+	}
+	if byName["greet"].Description != "Greet someone" {
+		t.Errorf("greet description = %q", byName["greet"].Description)
+	}
+
+	data, err := json.Marshal(ts.Export())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(data), `"source":"mcp"`) {
+		t.Errorf("JSON export missing source tags: %s", data)
+	}
+}
+
+func TestWithApproval(t *testing.T) {
+	var ran []string
+	var asked []string
+
+	ts := NewTools()
+	ts.Use(WithApproval(func(ctx context.Context, toolName string, params map[string]any) (bool, error) {
+		asked = append(asked, toolName)
+		switch toolName {
+		case "rm":
+			return false, nil
+		case "broken":
+			return false, errors.New("approval service down")
+		}
+		return true, nil
+	}))
+	for _, name := range []string{"ls", "rm", "broken"} {
+		ts.Register(name, func(ctx context.Context, params map[string]any) (string, error) {
+			ran = append(ran, name)
+			return "ran " + name, nil
+		})
+	}
+
+	if got, err := ts.Execute(context.Background(), "ls", nil); err != nil || got != "ran ls" {
+		t.Errorf("approved call = %q, %v", got, err)
+	}
+	if got, err := ts.Execute(context.Background(), "rm", nil); err != nil || got != RejectedResult {
+		t.Errorf("rejected call = %q, %v; want RejectedResult", got, err)
+	}
+	if _, err := ts.Execute(context.Background(), "broken", nil); err == nil {
+		t.Error("approval error: expected error")
+	}
+
+	if !slices.Equal(asked, []string{"ls", "rm", "broken"}) {
+		t.Errorf("asked = %v", asked)
+	}
+	if !slices.Equal(ran, []string{"ls"}) {
+		t.Errorf("ran = %v, want only ls", ran)
+	}
+}
+
+// recordingContainer is a ContainerExecutor that records the commands it
+// is asked to run.
+type recordingContainer struct {
+	commands [][]string
+}
+
+func (c *recordingContainer) IsAvailable() bool { return true }
+
+func (c *recordingContainer) Exec(ctx context.Context, projectName string, command []string, workDir string) (*container.ExecResult, error) {
+	c.commands = append(c.commands, command)
+	return &container.ExecResult{Stdout: "ran in container"}, nil
+}
+
+func TestWithApprovalContainerRouted(t *testing.T) {
+	cm := &recordingContainer{}
+	ts := NewTools(WithContainer(cm), WithContainerRouting("exec"))
+	ts.SetProject("demo")
+	ts.Use(WithApproval(func(ctx context.Context, toolName string, params map[string]any) (bool, error) {
+		return params["command"] != "rm -rf /", nil
+	}))
+	ts.Register("exec", noopTool)
+
+	if got, err := ts.Execute(context.Background(), "exec", map[string]any{"command": "rm -rf /"}); err != nil || got != RejectedResult {
+		t.Errorf("rejected call = %q, %v; want RejectedResult", got, err)
+	}
+	if len(cm.commands) != 0 {
+		t.Fatalf("container ran %v after approval was refused", cm.commands)
+	}
+	if got, err := ts.Execute(context.Background(), "exec", map[string]any{"command": "ls -l"}); err != nil || got != "ran in container" {
+		t.Errorf("approved call = %q, %v", got, err)
+	}
+	if len(cm.commands) != 1 || !slices.Equal(cm.commands[0], []string{"ls", "-l"}) {
+		t.Errorf("container commands = %v, want [ls -l]", cm.commands)
+	}
+}
@@ -0,0 +1,527 @@
+package vega
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job is one workflow run waiting in a JobQueue.
+type Job struct {
+	ID        string         `json:"id"`
+	Workflow  string         `json:"workflow"`
+	Inputs    map[string]any `json:"inputs,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+
+	// Set when a worker claims the job
+	WorkerID  string    `json:"worker_id,omitempty"`
+	ClaimedAt time.Time `json:"claimed_at,omitempty"`
+}
+
+// JobResult is what a worker posts back when it finishes a job.
+type JobResult struct {
+	JobID      string    `json:"job_id"`
+	WorkerID   string    `json:"worker_id"`
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// JobQueue is the coordination point shared by workers.
+//
+// Claim must be atomic: when several workers claim at once, each pending
+// job goes to exactly one of them. It returns ErrNoJobs when nothing is
+// waiting. Finish records the outcome of a claimed job.
+type JobQueue interface {
+	Claim(ctx context.Context, workerID string) (*Job, error)
+	Finish(ctx context.Context, result JobResult) error
+}
+
+// JobFunc executes a claimed job and returns its result.
+type JobFunc func(ctx context.Context, job *Job) (string, error)
+
+// Worker pulls jobs from a JobQueue, runs them, and posts the results back.
+// Run many workers, on one machine or many, against the same queue to
+// scale workflow execution horizontally.
+type Worker struct {
+	id           string
+	queue        JobQueue
+	run          JobFunc
+	callback     *CallbackConfig
+	pollInterval time.Duration
+}
+
+// WorkerOption configures a Worker.
+type WorkerOption func(*Worker)
+
+// WithWorkerID sets the ID the worker claims jobs under. Defaults to the
+// hostname and process ID.
+func WithWorkerID(id string) WorkerOption {
+	return func(w *Worker) {
+		w.id = id
+	}
+}
+
+// WithPollInterval sets how long an idle worker waits before polling the
+// queue again. Defaults to one second.
+func WithPollInterval(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		w.pollInterval = d
+	}
+}
+
+// WithWorkerCallback makes the worker report started, completed and failed
+// events for each job through config, using the job ID as the ProcessID.
+func WithWorkerCallback(config *CallbackConfig) WorkerOption {
+	return func(w *Worker) {
+		w.callback = config
+	}
+}
+
+// NewWorker creates a worker that executes jobs from queue with run.
+func NewWorker(queue JobQueue, run JobFunc, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		queue:        queue,
+		run:          run,
+		pollInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.id == "" {
+		host, _ := os.Hostname()
+		w.id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return w
+}
+
+// ID returns the worker's ID.
+func (w *Worker) ID() string {
+	return w.id
+}
+
+// Run claims and executes jobs until ctx is cancelled. Errors talking to
+// the queue are logged and retried on the next poll.
+func (w *Worker) Run(ctx context.Context) error {
+	for {
+		ran, err := w.RunOnce(ctx)
+		if err != nil {
+			slog.Warn("worker: job queue error", "worker", w.id, "error", err)
+		}
+		if ran && err == nil {
+			continue // drain the queue before sleeping
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(w.pollInterval):
+		}
+	}
+}
+
+// RunOnce claims one job and executes it. It reports whether a job was
+// claimed; an empty queue is not an error. A job that fails is posted back
+// as a failed result, so the returned error only covers the queue itself.
+func (w *Worker) RunOnce(ctx context.Context) (bool, error) {
+	if ctx.Err() != nil {
+		return false, nil
+	}
+
+	job, err := w.queue.Claim(ctx, w.id)
+	if errors.Is(err, ErrNoJobs) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("claiming job: %w", err)
+	}
+
+	w.publish(ctx, Event{Type: EventStarted, ProcessID: job.ID, AgentName: job.Workflow})
+
+	result, runErr := w.run(ctx, job)
+
+	jr := JobResult{
+		JobID:      job.ID,
+		WorkerID:   w.id,
+		Result:     result,
+		FinishedAt: time.Now(),
+	}
+	event := Event{Type: EventCompleted, ProcessID: job.ID, AgentName: job.Workflow, Result: result}
+	if runErr != nil {
+		jr.Result = ""
+		jr.Error = runErr.Error()
+		event = Event{Type: EventFailed, ProcessID: job.ID, AgentName: job.Workflow, Error: runErr.Error()}
+	}
+
+	// Post the result even if ctx was cancelled mid-run, so the job isn't
+	// left claimed forever.
+	if err := w.queue.Finish(context.WithoutCancel(ctx), jr); err != nil {
+		return true, fmt.Errorf("finishing job %s: %w", job.ID, err)
+	}
+	w.publish(context.WithoutCancel(ctx), event)
+	return true, nil
+}
+
+// publish reports an event if a callback is configured. Delivery is best
+// effort: the queue, not the event, is the record of a job's outcome.
+func (w *Worker) publish(ctx context.Context, event Event) {
+	if w.callback == nil {
+		return
+	}
+	event.Data = map[string]string{"worker_id": w.id}
+	if err := PublishEvent(ctx, event, w.callback); err != nil {
+		slog.Warn("worker: publishing event failed", "worker", w.id, "job", event.ProcessID, "type", event.Type, "error", err)
+	}
+}
+
+// DirJobQueue is a JobQueue kept in a directory, for workers that share a
+// filesystem. Jobs move between subdirectories as they progress:
+//
+//	pending/  submitted, waiting for a worker
+//	claimed/  being run by a worker
+//	done/     finished; holds the JobResult
+//
+// Every move takes the job file with a rename into tmp/ first. Rename is
+// atomic within a filesystem, so when two workers race for the same job,
+// or a worker races a requeue, exactly one rename succeeds and the other
+// moves on. The winner then writes the job where it belongs, stamped with
+// its worker, so claimed/ only ever holds stamped jobs.
+//
+// With a claim timeout, a job that stays claimed longer than the timeout is
+// assumed to belong to a worker that died and goes back to pending/.
+type DirJobQueue struct {
+	dir          string
+	claimTimeout time.Duration
+}
+
+// DirJobQueueOption configures a DirJobQueue.
+type DirJobQueueOption func(*DirJobQueue)
+
+// WithClaimTimeout requeues jobs that have been claimed for longer than d,
+// so a job whose worker crashed is run again by another. Pick d longer than
+// any job runs: a job still running past it is handed to a second worker,
+// and the first worker's Finish then fails with ErrClaimExpired. Zero, the
+// default, leaves claimed jobs in place until they finish.
+func WithClaimTimeout(d time.Duration) DirJobQueueOption {
+	return func(q *DirJobQueue) {
+		q.claimTimeout = d
+	}
+}
+
+// NewDirJobQueue opens the queue in dir, creating its subdirectories.
+func NewDirJobQueue(dir string, opts ...DirJobQueueOption) (*DirJobQueue, error) {
+	for _, sub := range []string{"pending", "claimed", "done", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, fmt.Errorf("creating job queue: %w", err)
+		}
+	}
+	q := &DirJobQueue{dir: dir}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q, nil
+}
+
+// Submit adds a job to the queue and returns its ID. An empty ID is
+// generated.
+func (q *DirJobQueue) Submit(ctx context.Context, job Job) (string, error) {
+	if job.Workflow == "" {
+		return "", fmt.Errorf("%w: job has no workflow", ErrInvalidInput)
+	}
+	if job.ID == "" {
+		job.ID = uuid.New().String()
+	}
+	if err := checkJobID(job.ID); err != nil {
+		return "", err
+	}
+	if job.CreatedAt.IsZero() {
+		job.CreatedAt = time.Now()
+	}
+
+	// File names sort by submission time, so Claim hands out jobs FIFO.
+	name := fmt.Sprintf("%020d-%s.job", job.CreatedAt.UnixNano(), job.ID)
+	if err := q.writeJSON(filepath.Join(q.dir, "pending", name), job); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Claim moves the oldest pending job to claimed/ and returns it. With a
+// claim timeout, expired claims are requeued first.
+func (q *DirJobQueue) Claim(ctx context.Context, workerID string) (*Job, error) {
+	if q.claimTimeout > 0 {
+		if _, err := q.RequeueStale(q.claimTimeout); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(q.dir, "pending"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".job" {
+			continue
+		}
+
+		src := filepath.Join(q.dir, "pending", entry.Name())
+		taken, err := q.take(src)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // another worker claimed it first
+			}
+			return nil, err
+		}
+
+		job, err := readJob(taken)
+		if err != nil {
+			// Unreadable job: record it as failed rather than retrying forever.
+			id := jobIDFromName(entry.Name())
+			q.finish(JobResult{JobID: id, WorkerID: workerID, Error: "corrupt job: " + err.Error(), FinishedAt: time.Now()}, taken)
+			continue
+		}
+
+		job.WorkerID = workerID
+		job.ClaimedAt = time.Now()
+		if err := q.writeJSON(filepath.Join(q.dir, "claimed", entry.Name()), job); err != nil {
+			os.Rename(taken, src)
+			return nil, err
+		}
+		os.Remove(taken)
+		return job, nil
+	}
+	return nil, ErrNoJobs
+}
+
+// Finish records the result of a claimed job and removes it from claimed/.
+// It fails with ErrClaimExpired if the job was requeued and claimed by
+// another worker in the meantime.
+func (q *DirJobQueue) Finish(ctx context.Context, result JobResult) error {
+	if err := checkJobID(result.JobID); err != nil {
+		return err
+	}
+	claimed, err := q.findJob("claimed", result.JobID)
+	if err != nil {
+		return err
+	}
+	if claimed == "" {
+		return fmt.Errorf("%w: %s is not claimed", ErrJobNotFound, result.JobID)
+	}
+
+	taken, err := q.take(claimed)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s was requeued", ErrClaimExpired, result.JobID)
+		}
+		return err
+	}
+	if job, err := readJob(taken); err == nil &&
+		result.WorkerID != "" && job.WorkerID != "" && job.WorkerID != result.WorkerID {
+		os.Rename(taken, claimed)
+		return fmt.Errorf("%w: %s is now claimed by %s", ErrClaimExpired, result.JobID, job.WorkerID)
+	}
+	return q.finish(result, taken)
+}
+
+// RequeueStale moves jobs claimed longer than olderThan ago back to
+// pending/, keeping their place in line, and returns their IDs. Claim calls
+// it when the queue has a claim timeout; call it directly to recover jobs
+// from crashed workers on a queue without one.
+func (q *DirJobQueue) RequeueStale(olderThan time.Duration) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(q.dir, "claimed"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".job" {
+			continue
+		}
+		src := filepath.Join(q.dir, "claimed", entry.Name())
+
+		stale, err := readJob(src)
+		if err != nil || stale.ClaimedAt.IsZero() || time.Since(stale.ClaimedAt) < olderThan {
+			continue // finished while we looked, or still fresh
+		}
+
+		taken, err := q.take(src)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // finished or requeued by someone else
+			}
+			return ids, err
+		}
+
+		// Between the read and the take, another requeue may have sent the
+		// job round again and a worker claimed it afresh. That claim isn't
+		// stale, so put it back.
+		job, err := readJob(taken)
+		if err != nil || job.WorkerID != stale.WorkerID || !job.ClaimedAt.Equal(stale.ClaimedAt) {
+			os.Rename(taken, src)
+			continue
+		}
+
+		job.WorkerID = ""
+		job.ClaimedAt = time.Time{}
+		if err := q.writeJSON(filepath.Join(q.dir, "pending", entry.Name()), job); err != nil {
+			os.Rename(taken, src)
+			return ids, err
+		}
+		os.Remove(taken)
+		slog.Warn("job queue: requeued stale claim", "job", jobIDFromName(entry.Name()), "worker", stale.WorkerID, "claimed_at", stale.ClaimedAt)
+		ids = append(ids, jobIDFromName(entry.Name()))
+	}
+	return ids, nil
+}
+
+// take moves the job file at path into tmp/, where no other worker will
+// touch it, and returns its new path. It fails with fs.ErrNotExist when
+// someone else took the file first.
+func (q *DirJobQueue) take(path string) (string, error) {
+	taken := filepath.Join(q.dir, "tmp", uuid.New().String()+"~"+filepath.Base(path))
+	if err := os.Rename(path, taken); err != nil {
+		return "", err
+	}
+	return taken, nil
+}
+
+func (q *DirJobQueue) finish(result JobResult, taken string) error {
+	if err := q.writeJSON(filepath.Join(q.dir, "done", result.JobID+".json"), result); err != nil {
+		return err
+	}
+	return os.Remove(taken)
+}
+
+// readJob reads the job file at path.
+func readJob(path string) (*Job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Result returns the result of a finished job. It returns ErrNotCompleted
+// while the job is pending or claimed, and ErrJobNotFound for an unknown ID.
+func (q *DirJobQueue) Result(jobID string) (*JobResult, error) {
+	if err := checkJobID(jobID); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(q.dir, "done", jobID+".json"))
+	if err == nil {
+		var result JobResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	// A job in tmp/ is moving between pending/ and claimed/.
+	for _, sub := range []string{"pending", "claimed", "tmp"} {
+		path, err := q.findJob(sub, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if path != "" {
+			return nil, ErrNotCompleted
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// findJob returns the path of the job file with exactly the given ID in
+// subdirectory sub, or "" if there is none. IDs are compared whole, so one
+// job's ID never matches a longer ID that ends with it.
+func (q *DirJobQueue) findJob(sub, jobID string) (string, error) {
+	entries, err := os.ReadDir(filepath.Join(q.dir, sub))
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if sub == "tmp" {
+			_, name, _ = strings.Cut(name, "~")
+		}
+		if !entry.IsDir() && filepath.Ext(name) == ".job" && jobIDFromName(name) == jobID {
+			return filepath.Join(q.dir, sub, entry.Name()), nil
+		}
+	}
+	return "", nil
+}
+
+// Pending returns the IDs of jobs waiting for a worker, oldest first.
+func (q *DirJobQueue) Pending() ([]string, error) {
+	// ReadDir sorts by name, and names start with the submission time.
+	entries, err := os.ReadDir(filepath.Join(q.dir, "pending"))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) == ".job" {
+			ids = append(ids, jobIDFromName(entry.Name()))
+		}
+	}
+	return ids, nil
+}
+
+// writeJSON writes v to path through tmp/, so readers never see a
+// partially written file.
+func (q *DirJobQueue) writeJSON(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Join(q.dir, "tmp"), "*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// jobIDFromName extracts the job ID from a "<nanos>-<id>.job" file name.
+func jobIDFromName(name string) string {
+	name = strings.TrimSuffix(name, ".job")
+	if _, id, ok := strings.Cut(name, "-"); ok {
+		return id
+	}
+	return name
+}
+
+// checkJobID rejects job IDs that can't be used safely in a file name.
+func checkJobID(id string) error {
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return fmt.Errorf("%w: invalid job ID %q", ErrInvalidInput, id)
+	}
+	return nil
+}
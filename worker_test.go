@@ -0,0 +1,306 @@
+package vega
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDirJobQueueClaimIsExclusive(t *testing.T) {
+	q, err := NewDirJobQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirJobQueue: %v", err)
+	}
+
+	const jobs = 40
+	for n := range jobs {
+		if _, err := q.Submit(context.Background(), Job{Workflow: "wf", Inputs: map[string]any{"n": n}}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	run := func(ctx context.Context, job *Job) (string, error) {
+		mu.Lock()
+		runs[job.ID]++
+		mu.Unlock()
+		return "ok", nil
+	}
+
+	var wg sync.WaitGroup
+	for n := range 8 {
+		w := NewWorker(q, run, WithWorkerID(fmt.Sprintf("w%d", n)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				ran, err := w.RunOnce(context.Background())
+				if err != nil {
+					t.Errorf("RunOnce: %v", err)
+					return
+				}
+				if !ran {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(runs) != jobs {
+		t.Errorf("ran %d distinct jobs, want %d", len(runs), jobs)
+	}
+	for id, count := range runs {
+		if count != 1 {
+			t.Errorf("job %s ran %d times, want 1", id, count)
+		}
+		result, err := q.Result(id)
+		if err != nil {
+			t.Errorf("Result(%s): %v", id, err)
+			continue
+		}
+		if result.Result != "ok" || result.WorkerID == "" {
+			t.Errorf("Result(%s) = %+v, want ok from a worker", id, result)
+		}
+	}
+	if pending, _ := q.Pending(); len(pending) != 0 {
+		t.Errorf("pending = %v, want none", pending)
+	}
+}
+
+func TestWorkerRecordsFailedJob(t *testing.T) {
+	q, err := NewDirJobQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirJobQueue: %v", err)
+	}
+	id, err := q.Submit(context.Background(), Job{Workflow: "wf"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if _, err := q.Result(id); !errors.Is(err, ErrNotCompleted) {
+		t.Errorf("Result before run: err = %v, want ErrNotCompleted", err)
+	}
+
+	w := NewWorker(q, func(ctx context.Context, job *Job) (string, error) {
+		return "", errors.New("boom")
+	})
+	if ran, err := w.RunOnce(context.Background()); !ran || err != nil {
+		t.Fatalf("RunOnce = %v, %v; want a job run without queue error", ran, err)
+	}
+
+	result, err := q.Result(id)
+	if err != nil {
+		t.Fatalf("Result: %v", err)
+	}
+	if result.Error != "boom" {
+		t.Errorf("Error = %q, want boom", result.Error)
+	}
+	if _, err := q.Result("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Result(missing): err = %v, want ErrJobNotFound", err)
+	}
+	if ran, err := w.RunOnce(context.Background()); ran || err != nil {
+		t.Errorf("RunOnce on empty queue = %v, %v; want nothing claimed", ran, err)
+	}
+}
+
+func TestWorkerReportsEvents(t *testing.T) {
+	q, err := NewDirJobQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirJobQueue: %v", err)
+	}
+	id, err := q.Submit(context.Background(), Job{Workflow: "wf"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	callbacks := NewCallbackConfig(t.TempDir(), "")
+	orch := NewOrchestrator(WithCallbackConfig(callbacks))
+	defer orch.Shutdown(context.Background())
+
+	events := make(chan Event, 4)
+	orch.OnWorkerEvent(func(e Event) { events <- e })
+
+	w := NewWorker(q, func(ctx context.Context, job *Job) (string, error) {
+		return "done", nil
+	}, WithWorkerCallback(callbacks))
+	if _, err := w.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	seen := make(map[EventType]Event)
+	deadline := time.After(5 * time.Second)
+	for len(seen) < 2 {
+		select {
+		case e := <-events:
+			seen[e.Type] = e
+		case <-deadline:
+			t.Fatalf("got events %v, want started and completed", seen)
+		}
+	}
+	done := seen[EventCompleted]
+	if done.ProcessID != id || done.Result != "done" || done.Data["worker_id"] != w.ID() {
+		t.Errorf("completed event = %+v, want job %s from %s", done, id, w.ID())
+	}
+}
+
+func TestDirJobQueueMatchesWholeIDs(t *testing.T) {
+	q, err := NewDirJobQueue(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDirJobQueue: %v", err)
+	}
+	ctx := context.Background()
+	for _, id := range []string{"x-b", "b"} {
+		if _, err := q.Submit(ctx, Job{ID: id, Workflow: "wf"}); err != nil {
+			t.Fatalf("Submit(%s): %v", id, err)
+		}
+	}
+
+	job, err := q.Claim(ctx, "w1")
+	if err != nil || job.ID != "x-b" {
+		t.Fatalf("Claim = %+v, %v, want x-b", job, err)
+	}
+
+	// b is still pending; finishing it must not touch the claimed x-b.
+	if err := q.Finish(ctx, JobResult{JobID: "b", WorkerID: "w2"}); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Finish(b) = %v, want ErrJobNotFound", err)
+	}
+	for _, id := range []string{"x-b", "b"} {
+		if _, err := q.Result(id); !errors.Is(err, ErrNotCompleted) {
+			t.Errorf("Result(%s) = %v, want ErrNotCompleted", id, err)
+		}
+	}
+	for _, id := range []string{"*", "?-b", "[xb]"} {
+		if _, err := q.Result(id); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("Result(%q) = %v, want ErrJobNotFound", id, err)
+		}
+	}
+	if _, err := q.Result("../x"); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Result with a path = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestDirJobQueueRequeuesStaleClaims(t *testing.T) {
+	q, err := NewDirJobQueue(t.TempDir(), WithClaimTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewDirJobQueue: %v", err)
+	}
+	ctx := context.Background()
+	id, err := q.Submit(ctx, Job{Workflow: "wf"})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+
+	if job, err := q.Claim(ctx, "crashed"); err != nil || job.ID != id {
+		t.Fatalf("Claim = %+v, %v", job, err)
+	}
+	if _, err := q.Claim(ctx, "w2"); !errors.Is(err, ErrNoJobs) {
+		t.Fatalf("second Claim before the timeout = %v, want ErrNoJobs", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	job, err := q.Claim(ctx, "w2")
+	if err != nil || job.ID != id || job.WorkerID != "w2" {
+		t.Fatalf("Claim after the timeout = %+v, %v, want the requeued job", job, err)
+	}
+
+	if err := q.Finish(ctx, JobResult{JobID: id, WorkerID: "crashed", Result: "late"}); !errors.Is(err, ErrClaimExpired) {
+		t.Errorf("Finish by the expired worker = %v, want ErrClaimExpired", err)
+	}
+	if err := q.Finish(ctx, JobResult{JobID: id, WorkerID: "w2", Result: "ok"}); err != nil {
+		t.Fatalf("Finish: %v", err)
+	}
+	if result, err := q.Result(id); err != nil || result.Result != "ok" {
+		t.Errorf("Result = %+v, %v, want ok from w2", result, err)
+	}
+}
+
+func TestDirJobQueueClaimRacesRequeue(t *testing.T) {
+	dir := t.TempDir()
+	q, err := NewDirJobQueue(dir)
+	if err != nil {
+		t.Fatalf("NewDirJobQueue: %v", err)
+	}
+	ctx := context.Background()
+	const jobs = 20
+	for range jobs {
+		if _, err := q.Submit(ctx, Job{Workflow: "wf"}); err != nil {
+			t.Fatalf("Submit: %v", err)
+		}
+	}
+
+	// Workers claim and never finish while requeues hand every claim
+	// straight back, so jobs keep cycling between pending/ and claimed/.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for n := range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := q.Claim(ctx, fmt.Sprintf("w%d", n)); err != nil && !errors.Is(err, ErrNoJobs) {
+					t.Errorf("Claim: %v", err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := q.RequeueStale(0); err != nil {
+					t.Errorf("RequeueStale: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	seen := make(map[string]int)
+	for _, sub := range []string{"pending", "claimed", "tmp"} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if sub == "tmp" {
+				t.Errorf("tmp/ still holds %s", entry.Name())
+				continue
+			}
+			job, err := readJob(filepath.Join(dir, sub, entry.Name()))
+			if err != nil {
+				t.Errorf("%s/%s: %v", sub, entry.Name(), err)
+				continue
+			}
+			seen[job.ID]++
+			if stamped := job.WorkerID != "" && !job.ClaimedAt.IsZero(); stamped != (sub == "claimed") {
+				t.Errorf("%s/%s has worker %q claimed at %v", sub, entry.Name(), job.WorkerID, job.ClaimedAt)
+			}
+		}
+	}
+	if len(seen) != jobs {
+		t.Errorf("queue holds %d distinct jobs, want %d", len(seen), jobs)
+	}
+	for id, count := range seen {
+		if count != 1 {
+			t.Errorf("job %s is queued %d times, want once", id, count)
+		}
+	}
+}